@@ -1,17 +1,21 @@
 package main
 
 import (
+	"crypto/tls"
+	"net"
 	"os"
 	"time"
 
 	"github.com/ekf/one-on-one-backend/internal/config"
 	"github.com/ekf/one-on-one-backend/internal/handlers"
 	"github.com/ekf/one-on-one-backend/internal/middleware"
+	"github.com/ekf/one-on-one-backend/internal/services"
 	"github.com/ekf/one-on-one-backend/internal/utils"
 	"github.com/gofiber/fiber/v2"
 	"github.com/gofiber/fiber/v2/middleware/cors"
 	"github.com/gofiber/fiber/v2/middleware/logger"
 	"github.com/gofiber/fiber/v2/middleware/recover"
+	"github.com/gofiber/fiber/v2/middleware/requestid"
 	"github.com/gofiber/websocket/v2"
 )
 
@@ -22,6 +26,22 @@ func main() {
 	// Validate security settings (logs warnings for insecure configs)
 	cfg.ValidateSecuritySettings()
 
+	// One-off commands: server reanalyze [flags]
+	if len(os.Args) > 1 && os.Args[1] == "reanalyze" {
+		os.Exit(runReanalyze(cfg, os.Args[2:]))
+	}
+	// server migrate status|up|baseline
+	if len(os.Args) > 1 && os.Args[1] == "migrate" {
+		os.Exit(runMigrate(cfg, os.Args[2:]))
+	}
+
+	if cfg.MigrateOnStart && cfg.DatabaseURL != "" {
+		if err := applyMigrations(cfg); err != nil {
+			utils.GetLogger().Error("Schema migration failed", map[string]interface{}{"error": err.Error()})
+			os.Exit(1)
+		}
+	}
+
 	// Create handler
 	h := handlers.NewHandler(cfg)
 
@@ -34,6 +54,7 @@ func main() {
 
 	// Middleware
 	app.Use(recover.New())
+	app.Use(requestid.New())
 	app.Use(logger.New())
 
 	// CORS configuration
@@ -45,6 +66,7 @@ func main() {
 		AllowOrigins:     corsOrigins,
 		AllowMethods:     "GET,POST,PUT,DELETE,PATCH,OPTIONS",
 		AllowHeaders:     "Origin,Content-Type,Accept,Authorization,X-CSRF-Token",
+		ExposeHeaders:    "X-Total-Count,X-Next-Cursor,X-Request-ID",
 		AllowCredentials: true,
 		MaxAge:           3600,
 	}))
@@ -89,7 +111,7 @@ func main() {
 
 		// Check AI services
 		aiStatus := "not configured"
-		if cfg.OpenAIKey != "" || cfg.AnthropicKey != "" {
+		if cfg.OpenAIKey != "" || cfg.AnthropicKey != "" || cfg.LocalLLMURL != "" || cfg.LocalSTTURL != "" {
 			aiStatus = "configured"
 		}
 
@@ -105,8 +127,9 @@ func main() {
 		return c.Status(statusCode).JSON(response)
 	})
 
-	// API routes
-	api := app.Group("/api/v1")
+	// API routes. Handlers pass c.UserContext() to the database to stop queries after the deadline
+	api := app.Group("/api/v1", middleware.RequestTimeout(time.Duration(cfg.RequestTimeoutSeconds)*time.Second))
+	api.Use(middleware.AuditTrail(h.DB, h.Audit))
 
 	// Public routes (no JWT required)
 	publicAPI := api.Group("")
@@ -140,9 +163,19 @@ func main() {
 	protectedAPI.Get("/meetings", h.ListMeetings)
 	protectedAPI.Post("/meetings", h.CreateMeeting)
 	protectedAPI.Get("/meetings/:id", h.GetMeeting)
+	protectedAPI.Get("/meetings/:id/segments", h.GetMeetingSegments)
+	protectedAPI.Put("/meetings/:id/speakers", h.UpdateMeetingSpeakers)
+	protectedAPI.Post("/meetings/:id/reanalyze", h.ReanalyzeMeeting)
+	protectedAPI.Get("/meetings/:id/analysis-revisions", h.GetMeetingAnalysisRevisions)
+	protectedAPI.Post("/meetings/:id/prepare", h.PrepareMeeting)
+	protectedAPI.Get("/meetings/:id/agenda", h.GetMeetingAgenda)
+	protectedAPI.Post("/meetings/:id/agenda/send", h.SendMeetingAgenda)
 	protectedAPI.Get("/meeting-categories", h.ListMeetingCategories)
 	protectedAPI.Get("/ai/status", h.AIStatus)
 	protectedAPI.Post("/process-meeting", h.ProcessMeeting)
+	protectedAPI.Get("/meeting-jobs", h.ListMeetingJobs)
+	protectedAPI.Get("/meeting-jobs/:id", h.GetMeetingJob)
+	protectedAPI.Post("/meeting-jobs/:id/retry", h.RetryMeetingJob)
 
 	// Tasks
 	protectedAPI.Get("/tasks", h.ListTasks)
@@ -164,6 +197,16 @@ func main() {
 	// My time entries
 	protectedAPI.Get("/time-entries/me", h.GetMyTimeEntries)
 
+	// 1-on-1 cadence
+	protectedAPI.Get("/one-on-ones/cadence", h.GetOneOnOneCadence)
+	protectedAPI.Get("/one-on-ones/proposals", h.ListOneOnOneProposals)
+	protectedAPI.Post("/one-on-ones/proposals/:id/accept", h.AcceptOneOnOneProposal)
+	protectedAPI.Post("/one-on-ones/proposals/:id/decline", h.DeclineOneOnOneProposal)
+
+	// Agreements
+	protectedAPI.Get("/agreements", h.ListAgreements)
+	protectedAPI.Put("/agreements/:id/status", h.UpdateAgreementStatus)
+
 	// Kanban
 	protectedAPI.Get("/kanban", h.GetKanban)
 	protectedAPI.Put("/kanban/move", h.MoveTaskKanban)
@@ -212,6 +255,7 @@ func main() {
 
 	// AD Integration
 	protectedAPI.Post("/ad/sync", h.SyncADUsers)
+	protectedAPI.Get("/connector/commands/:id", h.GetConnectorCommand)
 	protectedAPI.Get("/ad/subordinates/:id", h.GetSubordinates)
 
 	// JWT Token Management
@@ -254,6 +298,14 @@ func main() {
 	protectedAPI.Post("/messages/:id/reactions", h.AddReaction)
 	protectedAPI.Get("/messages/:id/reactions", h.GetReactions)
 
+	// Notification inbox and preferences (new notifications arrive as "notification" WebSocket messages)
+	protectedAPI.Get("/notifications", h.ListNotifications)
+	protectedAPI.Get("/notifications/unread-count", h.GetUnreadNotificationCount)
+	protectedAPI.Get("/notifications/preferences", h.GetNotificationPreferences)
+	protectedAPI.Put("/notifications/preferences", h.UpdateNotificationPreferences)
+	protectedAPI.Post("/notifications/read-all", h.MarkAllNotificationsRead)
+	protectedAPI.Post("/notifications/:id/read", h.MarkNotificationRead)
+
 	// Telegram integration for channels (protected)
 	protectedAPI.Get("/channels/:channel_id/telegram", h.GetTelegramConfig)
 	protectedAPI.Post("/channels/:channel_id/telegram", h.ConfigureTelegramBot)
@@ -344,7 +396,45 @@ func main() {
 	adminAPI.Get("/settings", h.GetSystemSettings)
 	adminAPI.Put("/settings", h.UpdateSystemSetting)
 	adminAPI.Get("/audit-logs", h.GetAuditLogs)
+	adminAPI.Get("/audit-logs/export", h.ExportAuditLogs)
+	adminAPI.Get("/audit-logs/verify", h.VerifyAuditLogs)
 	adminAPI.Get("/departments", h.GetDepartments)
+	adminAPI.Get("/prompt-templates", h.ListPromptTemplates)
+	adminAPI.Get("/prompt-templates/:category", h.GetPromptTemplateHistory)
+	adminAPI.Post("/prompt-templates/:category", h.CreatePromptTemplateVersion)
+	adminAPI.Post("/prompt-templates/:category/preview", h.PreviewPromptTemplate)
+	adminAPI.Post("/prompt-templates/:category/versions/:version/activate", h.ActivatePromptTemplateVersion)
+	adminAPI.Delete("/prompt-templates/:category/active", h.DeactivatePromptTemplates)
+	adminAPI.Post("/meetings/reanalyze", h.ReanalyzeMeetingsBatch)
+	adminAPI.Get("/jobs", h.ListScheduledJobs)
+	adminAPI.Get("/jobs/:name/runs", h.GetScheduledJobRuns)
+	adminAPI.Put("/jobs/:name", h.UpdateScheduledJob)
+	adminAPI.Post("/jobs/:name/trigger", h.TriggerScheduledJob)
+	adminAPI.Post("/jobs/:name/pause", h.PauseScheduledJob)
+	adminAPI.Post("/jobs/:name/resume", h.ResumeScheduledJob)
+
+	// Outbound webhooks
+	adminAPI.Get("/webhooks", h.ListWebhooks)
+	adminAPI.Post("/webhooks", h.CreateWebhook)
+	adminAPI.Get("/webhooks/deliveries/:id", h.GetWebhookDelivery)
+	adminAPI.Post("/webhooks/deliveries/:id/replay", h.ReplayWebhookDelivery)
+	adminAPI.Put("/webhooks/:id", h.UpdateWebhook)
+	adminAPI.Delete("/webhooks/:id", h.DeleteWebhook)
+	adminAPI.Post("/webhooks/:id/rotate-secret", h.RotateWebhookSecret)
+	adminAPI.Post("/webhooks/:id/ping", h.PingWebhook)
+	adminAPI.Get("/webhooks/:id/deliveries", h.ListWebhookDeliveries)
+
+	// Domain event outbox (EVENT_OUTBOX_ENABLED)
+	adminAPI.Get("/events/outbox", h.ListEventOutbox)
+	adminAPI.Post("/events/outbox/:id/retry", h.RetryEventOutboxEntry)
+	// Connector credentials
+	adminAPI.Get("/connector-credentials", h.ListConnectorCredentials)
+	adminAPI.Post("/connector-credentials", h.CreateConnectorCredential)
+	adminAPI.Post("/connector-credentials/:id/rotate", h.RotateConnectorCredential)
+	adminAPI.Delete("/connector-credentials/:id", h.RevokeConnectorCredential)
+	// Connector commands queued while offline
+	adminAPI.Get("/connector/commands", h.ListConnectorCommands)
+	adminAPI.Delete("/connector/commands/:id", h.CancelConnectorCommand)
 
 	// WebSocket for messenger
 	app.Use("/ws/messenger", func(c *fiber.Ctx) error {
@@ -355,13 +445,14 @@ func main() {
 	})
 	app.Get("/ws/messenger", websocket.New(h.MessengerWebSocket))
 
-	// WebSocket for connector
-	app.Use("/ws/connector", func(c *fiber.Ctx) error {
+	// WebSocket for connector, authenticated before the upgrade
+	connectorUpgrade := func(c *fiber.Ctx) error {
 		if websocket.IsWebSocketUpgrade(c) {
 			return c.Next()
 		}
 		return fiber.ErrUpgradeRequired
-	})
+	}
+	app.Use("/ws/connector", connectorUpgrade, h.AuthenticateConnector)
 	app.Get("/ws/connector", websocket.New(h.ConnectorWebSocket))
 
 	// Start server
@@ -372,6 +463,31 @@ func main() {
 		"ad_configured":  cfg.ADURL != "",
 	})
 
+	// mTLS listener serving only the connector WebSocket
+	if cfg.ConnectorTLSPort != "" {
+		tlsConfig, err := services.NewConnectorTLSConfig(cfg.ConnectorTLSCert, cfg.ConnectorTLSKey, cfg.ConnectorTLSClientCA)
+		if err != nil {
+			log.Error("Invalid connector TLS configuration", map[string]interface{}{"error": err.Error()})
+			os.Exit(1)
+		}
+		ln, err := net.Listen("tcp", ":"+cfg.ConnectorTLSPort)
+		if err != nil {
+			log.Error("Connector TLS listener failed to start", map[string]interface{}{"error": err.Error()})
+			os.Exit(1)
+		}
+
+		connectorApp := fiber.New(fiber.Config{DisableStartupMessage: true})
+		connectorApp.Use(recover.New())
+		connectorApp.Use("/ws/connector", connectorUpgrade, h.AuthenticateConnector)
+		connectorApp.Get("/ws/connector", websocket.New(h.ConnectorWebSocket))
+		go func() {
+			if err := connectorApp.Listener(tls.NewListener(ln, tlsConfig)); err != nil {
+				log.Error("Connector TLS listener stopped", map[string]interface{}{"error": err.Error()})
+			}
+		}()
+		log.Info("Connector mTLS listener started", map[string]interface{}{"port": cfg.ConnectorTLSPort})
+	}
+
 	if err := app.Listen(":" + cfg.Port); err != nil {
 		log.Error("Server failed to start", map[string]interface{}{
 			"error": err.Error(),
@@ -0,0 +1,141 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+
+	"github.com/ekf/one-on-one-backend/internal/config"
+	"github.com/ekf/one-on-one-backend/internal/database"
+	"github.com/ekf/one-on-one-backend/internal/utils"
+	"github.com/ekf/one-on-one-backend/migrations"
+)
+
+const migrateUsage = `Usage:
+  server migrate status              list migrations and whether they are applied
+  server migrate up [-dry-run]       apply pending migrations (-dry-run only lists them)
+  server migrate baseline VERSION    mark migrations up to VERSION as applied without running them,
+                                     for databases whose schema was created by hand`
+
+// runMigrate manages the embedded schema migrations
+func runMigrate(cfg *config.Config, args []string) int {
+	if len(args) == 0 {
+		fmt.Fprintln(os.Stderr, migrateUsage)
+		return 2
+	}
+
+	fs := flag.NewFlagSet("migrate "+args[0], flag.ContinueOnError)
+	dryRun := fs.Bool("dry-run", false, "list pending migrations without applying them")
+	if err := fs.Parse(args[1:]); err != nil {
+		return 2
+	}
+
+	migrator, closeDB, err := newMigrator(cfg)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "migrate:", err)
+		return 1
+	}
+	defer closeDB()
+
+	ctx := context.Background()
+	switch args[0] {
+	case "status":
+		statuses, err := migrator.Status(ctx)
+		if err != nil {
+			fmt.Fprintln(os.Stderr, "migrate:", err)
+			return 1
+		}
+		pending := 0
+		for _, s := range statuses {
+			state := "pending"
+			switch {
+			case s.Missing:
+				state = "applied, missing from binary"
+			case s.Modified:
+				state = "applied, file modified since"
+			case s.Baseline:
+				state = "baseline"
+			case !s.Pending():
+				state = "applied"
+			}
+			when := ""
+			if s.AppliedAt != nil {
+				when = s.AppliedAt.Local().Format("2006-01-02 15:04:05")
+			} else {
+				pending++
+			}
+			fmt.Printf("%-45s %-30s %s\n", s.Version, state, when)
+		}
+		fmt.Printf("%d migrations, %d pending\n", len(statuses), pending)
+
+	case "up":
+		applied, err := migrator.Up(ctx, *dryRun, true)
+		for _, m := range applied {
+			if *dryRun {
+				fmt.Println("would apply", m.Version)
+			} else {
+				fmt.Println("applied", m.Version)
+			}
+		}
+		if err != nil {
+			fmt.Fprintln(os.Stderr, "migrate:", err)
+			return 1
+		}
+		if len(applied) == 0 {
+			fmt.Println("schema is up to date")
+		}
+
+	case "baseline":
+		if fs.NArg() != 1 {
+			fmt.Fprintln(os.Stderr, migrateUsage)
+			return 2
+		}
+		recorded, err := migrator.Baseline(ctx, fs.Arg(0))
+		if err != nil {
+			fmt.Fprintln(os.Stderr, "migrate:", err)
+			return 1
+		}
+		fmt.Printf("recorded %d migrations up to %s as applied\n", recorded, fs.Arg(0))
+
+	default:
+		fmt.Fprintln(os.Stderr, migrateUsage)
+		return 2
+	}
+	return 0
+}
+
+// applyMigrations brings the schema up to date before the server starts using it.
+// A database without migration history is left alone: it has to be baselined first.
+func applyMigrations(cfg *config.Config) error {
+	migrator, closeDB, err := newMigrator(cfg)
+	if err != nil {
+		return err
+	}
+	defer closeDB()
+
+	applied, err := migrator.Up(context.Background(), false, false)
+	for _, m := range applied {
+		utils.GetLogger().Info("Applied migration", map[string]interface{}{"version": m.Version})
+	}
+	if errors.Is(err, database.ErrNoMigrationHistory) {
+		return fmt.Errorf("%w: run 'server migrate baseline VERSION' with the last migration applied by hand, or 'server migrate up' on a new database", err)
+	}
+	return err
+}
+
+func newMigrator(cfg *config.Config) (*database.Migrator, func(), error) {
+	if cfg.DatabaseURL == "" {
+		return nil, nil, errors.New("DATABASE_URL is not set")
+	}
+	all, err := database.LoadMigrations(migrations.FS)
+	if err != nil {
+		return nil, nil, err
+	}
+	client, err := database.NewPostgresClient(cfg.DatabaseURL)
+	if err != nil {
+		return nil, nil, err
+	}
+	return database.NewMigrator(client, all), func() { client.Close() }, nil
+}
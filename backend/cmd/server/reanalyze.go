@@ -0,0 +1,63 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+
+	"github.com/ekf/one-on-one-backend/internal/config"
+	"github.com/ekf/one-on-one-backend/internal/handlers"
+)
+
+// runReanalyze re-runs analysis for stored meetings and prints progress.
+// Usage: server reanalyze [-meeting ID] [-employee ID] [-category CODE] [-from DATE] [-to DATE]
+// [-prompt-version N] [-provider NAME] [-reason TEXT]
+func runReanalyze(cfg *config.Config, args []string) int {
+	var opts handlers.ReanalysisOptions
+
+	fs := flag.NewFlagSet("reanalyze", flag.ContinueOnError)
+	fs.StringVar(&opts.MeetingID, "meeting", "", "meeting ID")
+	fs.StringVar(&opts.EmployeeID, "employee", "", "employee ID")
+	fs.StringVar(&opts.CategoryCode, "category", "", "meeting category code")
+	fs.StringVar(&opts.DateFrom, "from", "", "first meeting date (YYYY-MM-DD)")
+	fs.StringVar(&opts.DateTo, "to", "", "last meeting date (YYYY-MM-DD)")
+	fs.IntVar(&opts.PromptVersion, "prompt-version", 0, "stored prompt version (requires -category)")
+	fs.StringVar(&opts.Provider, "provider", "", "LLM provider instead of the configured chain")
+	fs.StringVar(&opts.Reason, "reason", "", "reason stored with archived revisions")
+	if err := fs.Parse(args); err != nil {
+		return 2
+	}
+
+	if opts.MeetingID == "" && opts.EmployeeID == "" && opts.DateFrom == "" && opts.DateTo == "" {
+		fmt.Fprintln(os.Stderr, "reanalyze: -meeting, -employee or a date range is required")
+		return 2
+	}
+
+	// Do not run background jobs in this process
+	cfg.MeetingJobWorkers = 0
+	cfg.SchedulerEnabled = false
+
+	h := handlers.NewHandler(cfg)
+	if h.DB == nil {
+		fmt.Fprintln(os.Stderr, "reanalyze: database not configured")
+		return 1
+	}
+
+	result, err := h.ReanalyzeMeetings(opts, "", func(done, total int, meetingID string, err error) {
+		status := "ok"
+		if err != nil {
+			status = "failed: " + err.Error()
+		}
+		fmt.Printf("[%d/%d] %s %s\n", done, total, meetingID, status)
+	})
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "reanalyze:", err)
+		return 1
+	}
+
+	fmt.Printf("Done: %d total, %d succeeded, %d failed\n", result.Total, result.Succeeded, result.Failed)
+	if result.Failed > 0 {
+		return 1
+	}
+	return 0
+}
@@ -0,0 +1,321 @@
+// Package audit records who changed what through the API. Entries form a hash chain:
+// every entry stores the hash of the previous one, so editing or deleting a stored
+// entry breaks the chain and is reported by Verify.
+package audit
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"strconv"
+	"sync/atomic"
+	"time"
+
+	"github.com/ekf/one-on-one-backend/internal/database"
+	"github.com/ekf/one-on-one-backend/internal/utils"
+	"github.com/gofiber/fiber/v2"
+)
+
+const (
+	queueSize    = 1000
+	dropLogEvery = 100
+	writeTimeout = 10 * time.Second
+	chainLock    = "audit_logs_chain"
+	loggedKey    = "audit_logged"
+)
+
+// Entry is an audit event
+type Entry struct {
+	UserID     string
+	Action     string
+	EntityType string
+	EntityID   string
+	OldValue   map[string]interface{}
+	NewValue   map[string]interface{}
+	IPAddress  string
+	UserAgent  string
+	RequestID  string
+	Method     string
+	Path       string
+	Status     int
+}
+
+// Log is a stored entry. JSONB values are returned by the database as strings.
+type Log struct {
+	ID         string    `json:"id"`
+	Seq        *int64    `json:"seq"`
+	UserID     *string   `json:"user_id"`
+	Action     string    `json:"action"`
+	EntityType *string   `json:"entity_type"`
+	EntityID   *string   `json:"entity_id"`
+	OldValue   *string   `json:"old_value"`
+	NewValue   *string   `json:"new_value"`
+	IPAddress  *string   `json:"ip_address"`
+	UserAgent  *string   `json:"user_agent"`
+	RequestID  *string   `json:"request_id"`
+	Method     *string   `json:"method"`
+	Path       *string   `json:"path"`
+	Status     *int      `json:"status"`
+	PrevHash   *string   `json:"prev_hash"`
+	Hash       *string   `json:"hash"`
+	CreatedAt  time.Time `json:"created_at"`
+}
+
+// Columns selects every column of Log
+const Columns = "id, seq, user_id, action, entity_type, entity_id, old_value, new_value, ip_address, user_agent, request_id, method, path, status, prev_hash, hash, created_at"
+
+// RequestEntry starts an entry with the actor and request details of c
+func RequestEntry(c *fiber.Ctx) Entry {
+	userID, _ := c.Locals("user_id").(string)
+	requestID, _ := c.Locals("requestid").(string)
+	return Entry{
+		UserID:    userID,
+		IPAddress: c.IP(),
+		UserAgent: c.Get("User-Agent"),
+		RequestID: requestID,
+		Method:    c.Method(),
+		Path:      c.Path(),
+	}
+}
+
+// MarkLogged tells the audit middleware that the handler recorded the request itself
+func MarkLogged(c *fiber.Ctx) {
+	c.Locals(loggedKey, true)
+}
+
+// Logged reports whether the handler recorded the request itself
+func Logged(c *fiber.Ctx) bool {
+	logged, _ := c.Locals(loggedKey).(bool)
+	return logged
+}
+
+// Recorder appends entries to audit_logs. Replicas share one chain: writes are
+// serialized by a transaction-level advisory lock.
+type Recorder struct {
+	db      database.DBClient
+	queue   chan Entry
+	dropped atomic.Int64
+}
+
+// NewRecorder creates a recorder; Start must be called before Record
+func NewRecorder(db database.DBClient) *Recorder {
+	return &Recorder{db: db, queue: make(chan Entry, queueSize)}
+}
+
+// Start writes queued entries in the background, in the order they were recorded
+func (r *Recorder) Start() {
+	go func() {
+		for entry := range r.queue {
+			ctx, cancel := context.WithTimeout(context.Background(), writeTimeout)
+			if err := r.Write(ctx, entry); err != nil {
+				utils.GetLogger().Warn("Failed to write audit log", map[string]interface{}{
+					"action": entry.Action,
+					"error":  err.Error(),
+				})
+			}
+			cancel()
+		}
+	}()
+}
+
+// Record queues an entry without blocking the request. While the queue is full the
+// entry is dropped and counted (see Dropped).
+func (r *Recorder) Record(entry Entry) {
+	select {
+	case r.queue <- entry:
+	default:
+		if dropped := r.dropped.Add(1); dropped%dropLogEvery == 1 {
+			utils.GetLogger().Warn("Audit log queue is full, dropping entries", map[string]interface{}{
+				"action":  entry.Action,
+				"dropped": dropped,
+			})
+		}
+	}
+}
+
+// Dropped returns the number of entries dropped since the recorder was created
+func (r *Recorder) Dropped() int64 {
+	return r.dropped.Load()
+}
+
+// Write appends an entry to the chain
+func (r *Recorder) Write(ctx context.Context, entry Entry) error {
+	return r.db.WithTx(ctx, func(tx database.DBClient) error {
+		if locker, ok := tx.(database.AdvisoryLocker); ok {
+			if err := locker.XactAdvisoryLock(ctx, chainLock); err != nil {
+				return err
+			}
+		}
+
+		var last []struct {
+			Seq  int64  `json:"seq"`
+			Hash string `json:"hash"`
+		}
+		if err := tx.From("audit_logs").Select("seq, hash").Not("seq", "is", "null").
+			Order("seq", true).Limit(1).Execute(&last); err != nil {
+			return err
+		}
+
+		log := Log{
+			Seq:        new(int64),
+			PrevHash:   new(string),
+			Action:     entry.Action,
+			UserID:     optional(entry.UserID),
+			EntityType: optional(entry.EntityType),
+			EntityID:   optional(entry.EntityID),
+			IPAddress:  optional(entry.IPAddress),
+			UserAgent:  optional(entry.UserAgent),
+			RequestID:  optional(entry.RequestID),
+			Method:     optional(entry.Method),
+			Path:       optional(entry.Path),
+			CreatedAt:  time.Now().UTC().Truncate(time.Microsecond),
+		}
+		*log.Seq = 1
+		if len(last) > 0 {
+			*log.Seq = last[0].Seq + 1
+			*log.PrevHash = last[0].Hash
+		}
+		if entry.Status != 0 {
+			log.Status = &entry.Status
+		}
+		var err error
+		if log.OldValue, err = marshalValue(entry.OldValue); err != nil {
+			return err
+		}
+		if log.NewValue, err = marshalValue(entry.NewValue); err != nil {
+			return err
+		}
+		hash := Hash(log)
+		log.Hash = &hash
+
+		_, err = tx.Insert("audit_logs", map[string]interface{}{
+			"seq":         *log.Seq,
+			"prev_hash":   *log.PrevHash,
+			"hash":        hash,
+			"user_id":     log.UserID,
+			"action":      log.Action,
+			"entity_type": log.EntityType,
+			"entity_id":   log.EntityID,
+			"old_value":   log.OldValue,
+			"new_value":   log.NewValue,
+			"ip_address":  log.IPAddress,
+			"user_agent":  log.UserAgent,
+			"request_id":  log.RequestID,
+			"method":      log.Method,
+			"path":        log.Path,
+			"status":      log.Status,
+			"created_at":  log.CreatedAt,
+		})
+		return err
+	})
+}
+
+// Hash computes the chain hash of an entry from its stored fields. JSON values are
+// normalized, so the result does not depend on how JSONB formats them.
+func Hash(log Log) string {
+	fields := []interface{}{
+		value(log.Seq), value(log.PrevHash), log.CreatedAt.UTC().Format(time.RFC3339Nano),
+		value(log.UserID), log.Action, value(log.EntityType), value(log.EntityID),
+		normalizeJSON(log.OldValue), normalizeJSON(log.NewValue),
+		value(log.IPAddress), value(log.UserAgent), value(log.RequestID),
+		value(log.Method), value(log.Path), value(log.Status),
+	}
+	data, _ := json.Marshal(fields)
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:])
+}
+
+// VerifyResult is the outcome of a chain check
+type VerifyResult struct {
+	Valid     bool   `json:"valid"`
+	Checked   int    `json:"checked"`
+	BrokenSeq *int64 `json:"broken_seq,omitempty"`
+	Reason    string `json:"reason,omitempty"`
+	// Entries this instance dropped because its queue was full; they are not in the chain
+	Dropped int64 `json:"dropped"`
+}
+
+// Verify walks the chain from the first entry and reports the first entry that was
+// modified, removed or inserted out of order
+func (r *Recorder) Verify(ctx context.Context) (*VerifyResult, error) {
+	const batchSize = 1000
+	result := &VerifyResult{Valid: true, Dropped: r.Dropped()}
+	db := r.db.WithContext(ctx)
+
+	var lastSeq int64
+	prevHash := ""
+	for {
+		var logs []Log
+		if err := db.From("audit_logs").Select(Columns).Gt("seq", strconv.FormatInt(lastSeq, 10)).
+			Order("seq", false).Limit(batchSize).Execute(&logs); err != nil {
+			return nil, err
+		}
+
+		for _, log := range logs {
+			seq := *log.Seq
+			reason := ""
+			switch {
+			case seq != lastSeq+1:
+				reason = "entries before this one were deleted"
+			case value(log.PrevHash) != prevHash:
+				reason = "previous hash does not match"
+			case log.Hash == nil || *log.Hash != Hash(log):
+				reason = "entry was modified"
+			}
+			if reason != "" {
+				result.Valid = false
+				result.BrokenSeq = &seq
+				result.Reason = reason
+				return result, nil
+			}
+
+			result.Checked++
+			lastSeq = seq
+			prevHash = *log.Hash
+		}
+
+		if len(logs) < batchSize {
+			return result, nil
+		}
+	}
+}
+
+func optional(s string) *string {
+	if s == "" {
+		return nil
+	}
+	return &s
+}
+
+func marshalValue(v map[string]interface{}) (*string, error) {
+	if v == nil {
+		return nil, nil
+	}
+	data, err := json.Marshal(v)
+	if err != nil {
+		return nil, err
+	}
+	s := string(data)
+	return &s, nil
+}
+
+// normalizeJSON decodes a JSON document into plain Go values, which marshal with sorted keys
+func normalizeJSON(s *string) interface{} {
+	if s == nil {
+		return nil
+	}
+	var v interface{}
+	if json.Unmarshal([]byte(*s), &v) != nil {
+		return *s
+	}
+	return v
+}
+
+// value dereferences optional fields for hashing, nil stays nil
+func value[T any](p *T) interface{} {
+	if p == nil {
+		return nil
+	}
+	return *p
+}
@@ -0,0 +1,235 @@
+package audit
+
+import (
+	"context"
+	"encoding/json"
+	"sort"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/ekf/one-on-one-backend/internal/database"
+)
+
+// chainDB serves audit_logs rows to Verify. Only the calls Verify makes are implemented.
+type chainDB struct {
+	database.DBClient
+	logs []Log
+}
+
+func (db *chainDB) WithContext(context.Context) database.DBClient { return db }
+
+func (db *chainDB) From(string) database.QueryBuilder { return &chainQuery{db: db} }
+
+type chainQuery struct {
+	database.QueryBuilder
+	db    *chainDB
+	after int64
+	limit int
+}
+
+func (q *chainQuery) Select(string) database.QueryBuilder      { return q }
+func (q *chainQuery) Order(string, bool) database.QueryBuilder { return q }
+
+func (q *chainQuery) Gt(_, value string) database.QueryBuilder {
+	q.after, _ = strconv.ParseInt(value, 10, 64)
+	return q
+}
+
+func (q *chainQuery) Limit(n int) database.QueryBuilder {
+	q.limit = n
+	return q
+}
+
+func (q *chainQuery) Execute(result interface{}) error {
+	var logs []Log
+	for _, log := range q.db.logs {
+		if *log.Seq > q.after {
+			logs = append(logs, log)
+		}
+	}
+	sort.Slice(logs, func(i, j int) bool { return *logs[i].Seq < *logs[j].Seq })
+	if q.limit > 0 && len(logs) > q.limit {
+		logs = logs[:q.limit]
+	}
+	data, err := json.Marshal(logs)
+	if err != nil {
+		return err
+	}
+	return json.Unmarshal(data, result)
+}
+
+// chain builds n correctly linked entries
+func chain(n int) []Log {
+	logs := make([]Log, n)
+	prev := ""
+	start := time.Date(2026, 10, 17, 9, 0, 0, 0, time.UTC)
+	for i := range logs {
+		seq := int64(i + 1)
+		prevHash := prev
+		user := "user-1"
+		newValue := `{"status": "done", "id": "` + strconv.Itoa(i) + `"}`
+		logs[i] = Log{
+			Seq:       &seq,
+			PrevHash:  &prevHash,
+			UserID:    &user,
+			Action:    "task.update",
+			NewValue:  &newValue,
+			CreatedAt: start.Add(time.Duration(i) * time.Second),
+		}
+		hash := Hash(logs[i])
+		logs[i].Hash = &hash
+		prev = hash
+	}
+	return logs
+}
+
+func ptr[T any](v T) *T { return &v }
+
+func TestHash(t *testing.T) {
+	base := chain(1)[0]
+	hash := Hash(base)
+	if len(hash) != 64 || Hash(base) != hash {
+		t.Fatalf("Hash() = %q is not a stable SHA-256", hash)
+	}
+
+	same := []struct {
+		name   string
+		modify func(l *Log)
+	}{
+		{"stored hash is not covered", func(l *Log) { l.Hash = ptr("other") }},
+		{"id is not covered", func(l *Log) { l.ID = "row-id" }},
+		{"JSON key order and spacing", func(l *Log) { l.NewValue = ptr(`{"id":"0","status":"done"}`) }},
+		{"time zone of created_at", func(l *Log) { l.CreatedAt = l.CreatedAt.In(time.FixedZone("MSK", 3*60*60)) }},
+	}
+	for _, tt := range same {
+		l := base
+		tt.modify(&l)
+		if got := Hash(l); got != hash {
+			t.Errorf("%s: hash changed", tt.name)
+		}
+	}
+
+	different := []struct {
+		name   string
+		modify func(l *Log)
+	}{
+		{"seq", func(l *Log) { l.Seq = ptr(int64(2)) }},
+		{"prev_hash", func(l *Log) { l.PrevHash = ptr("x") }},
+		{"created_at", func(l *Log) { l.CreatedAt = l.CreatedAt.Add(time.Microsecond) }},
+		{"user_id", func(l *Log) { l.UserID = ptr("user-2") }},
+		{"user_id removed", func(l *Log) { l.UserID = nil }},
+		{"user_id empty", func(l *Log) { l.UserID = ptr("") }},
+		{"action", func(l *Log) { l.Action = "task.delete" }},
+		{"entity", func(l *Log) { l.EntityID = ptr("t1") }},
+		{"old_value", func(l *Log) { l.OldValue = ptr(`{"status": "todo"}`) }},
+		{"new_value", func(l *Log) { l.NewValue = ptr(`{"status": "todo", "id": "0"}`) }},
+		{"ip_address", func(l *Log) { l.IPAddress = ptr("10.0.0.1") }},
+		{"status", func(l *Log) { l.Status = ptr(200) }},
+		{"path", func(l *Log) { l.Path = ptr("/api/v1/tasks") }},
+	}
+	for _, tt := range different {
+		l := base
+		tt.modify(&l)
+		if got := Hash(l); got == hash {
+			t.Errorf("%s: hash did not change", tt.name)
+		}
+	}
+}
+
+func TestVerify(t *testing.T) {
+	tests := []struct {
+		name      string
+		logs      func() []Log
+		checked   int
+		brokenSeq int64
+		reason    string
+	}{
+		{
+			name: "empty",
+			logs: func() []Log { return nil },
+		},
+		{
+			name:    "intact",
+			logs:    func() []Log { return chain(5) },
+			checked: 5,
+		},
+		{
+			name:    "longer than a batch",
+			logs:    func() []Log { return chain(1001) },
+			checked: 1001,
+		},
+		{
+			name: "modified entry",
+			logs: func() []Log {
+				logs := chain(5)
+				logs[2].Action = "task.delete"
+				return logs
+			},
+			checked: 2, brokenSeq: 3, reason: "entry was modified",
+		},
+		{
+			name: "modified and rehashed entry",
+			logs: func() []Log {
+				logs := chain(5)
+				logs[2].Action = "task.delete"
+				logs[2].Hash = ptr(Hash(logs[2]))
+				return logs
+			},
+			checked: 3, brokenSeq: 4, reason: "previous hash does not match",
+		},
+		{
+			name: "deleted entry",
+			logs: func() []Log {
+				logs := chain(5)
+				return append(logs[:2], logs[3:]...)
+			},
+			checked: 2, brokenSeq: 4, reason: "entries before this one were deleted",
+		},
+		{
+			name:      "deleted first entry",
+			logs:      func() []Log { return chain(3)[1:] },
+			brokenSeq: 2, reason: "entries before this one were deleted",
+		},
+		{
+			name: "missing hash",
+			logs: func() []Log {
+				logs := chain(2)
+				logs[1].Hash = nil
+				return logs
+			},
+			checked: 1, brokenSeq: 2, reason: "entry was modified",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := NewRecorder(&chainDB{logs: tt.logs()})
+			result, err := r.Verify(context.Background())
+			if err != nil {
+				t.Fatal(err)
+			}
+			if result.Checked != tt.checked {
+				t.Errorf("Checked = %d, want %d", result.Checked, tt.checked)
+			}
+			if tt.reason == "" {
+				if !result.Valid || result.BrokenSeq != nil {
+					t.Errorf("chain reported broken: %+v", result)
+				}
+				return
+			}
+			if result.Valid || result.BrokenSeq == nil || *result.BrokenSeq != tt.brokenSeq || result.Reason != tt.reason {
+				t.Errorf("result = %+v, want broken at %d: %s", result, tt.brokenSeq, tt.reason)
+			}
+		})
+	}
+}
+
+func TestRecordDropsWhenQueueIsFull(t *testing.T) {
+	r := NewRecorder(nil) // not started: nothing drains the queue
+	for i := 0; i < queueSize+3; i++ {
+		r.Record(Entry{Action: "task.update"})
+	}
+	if got := r.Dropped(); got != 3 {
+		t.Errorf("Dropped() = %d, want 3", got)
+	}
+}
@@ -0,0 +1,88 @@
+package audit
+
+import (
+	"reflect"
+
+	"github.com/ekf/one-on-one-backend/internal/database"
+)
+
+const maxValueLength = 1000
+
+// Columns that change on every write or are derived from other columns
+var ignoredFields = map[string]bool{
+	"updated_at":    true,
+	"search_vector": true,
+}
+
+// Columns whose values must not be copied into the log; only the fact of a change is kept
+var redactedFields = map[string]bool{
+	"password_hash":      true,
+	"encrypted_password": true,
+	"photo_base64":       true,
+}
+
+// Snapshot loads a row by ID as a map, nil if it does not exist
+func Snapshot(db database.DBClient, table, id string) map[string]interface{} {
+	var rows []map[string]interface{}
+	if err := db.From(table).Select("*").Eq("id", id).Limit(1).Execute(&rows); err != nil || len(rows) == 0 {
+		return nil
+	}
+	return rows[0]
+}
+
+// Diff returns the fields that differ between two snapshots of a row: their old and new
+// values. A nil before means the row was created, a nil after that it was deleted;
+// the whole row is returned then.
+func Diff(before, after map[string]interface{}) (map[string]interface{}, map[string]interface{}) {
+	switch {
+	case before == nil && after == nil:
+		return nil, nil
+	case before == nil:
+		return nil, sanitize(after, nil)
+	case after == nil:
+		return sanitize(before, nil), nil
+	}
+
+	changed := make(map[string]bool)
+	for field, old := range before {
+		if !ignoredFields[field] && !reflect.DeepEqual(old, after[field]) {
+			changed[field] = true
+		}
+	}
+	for field := range after {
+		if _, ok := before[field]; !ok && !ignoredFields[field] {
+			changed[field] = true
+		}
+	}
+	if len(changed) == 0 {
+		return nil, nil
+	}
+	return sanitize(before, changed), sanitize(after, changed)
+}
+
+// sanitize copies the selected fields (all when only is nil), hiding secrets and
+// shortening long texts such as transcripts
+func sanitize(row map[string]interface{}, only map[string]bool) map[string]interface{} {
+	result := make(map[string]interface{})
+	for field, v := range row {
+		if ignoredFields[field] || (only != nil && !only[field]) {
+			continue
+		}
+		if redactedFields[field] {
+			if v != nil {
+				v = "[redacted]"
+			}
+		} else if s, ok := v.(string); ok && len(s) > maxValueLength {
+			v = truncateUTF8(s, maxValueLength) + "…"
+		}
+		result[field] = v
+	}
+	return result
+}
+
+func truncateUTF8(s string, n int) string {
+	for n > 0 && n < len(s) && s[n]&0xC0 == 0x80 {
+		n--
+	}
+	return s[:n]
+}
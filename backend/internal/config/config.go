@@ -3,6 +3,7 @@ package config
 import (
 	"log"
 	"os"
+	"strconv"
 	"strings"
 )
 
@@ -14,7 +15,9 @@ type Config struct {
 	TelegramBotToken string
 	YandexAPIKey     string
 	YandexFolderID   string
-	ConnectorAPIKey  string
+	ConnectorAPIKey  string // Shared connector key; superseded by per-connector credentials
+	// Accept connectors without authentication while no key or credential is configured
+	ConnectorAllowUnauthenticated bool
 	// AD Configuration
 	ADURL          string // LDAP URL (e.g., ldap://172.20.0.33:389)
 	ADBaseDN       string // Base DN (e.g., OU=EKF-USERS,DC=ekfgroup,DC=ru)
@@ -46,6 +49,65 @@ type Config struct {
 	ConfluencePassword string // Confluence password
 	// GitHub Configuration
 	GitHubToken string // GitHub personal access token for API access
+	// Background processing
+	MeetingJobWorkers int // Number of concurrent meeting processing workers (0 - disabled)
+	// Speech-to-text provider selection
+	STTProviders           string // Comma-separated default providers (e.g., "whisper,yandex")
+	STTCategoryProviders   string // Per-category overrides (e.g., "one_on_one=local;interview=local")
+	LocalSTTURL            string // Self-hosted whisper-compatible server (e.g., http://whisper.internal:8000)
+	LocalSTTModel          string // Model name passed to the local server
+	LocalSTTAPIKey         string // Optional bearer token for the local server
+	LocalSTTTimeoutSeconds int    // Per-request timeout of the local server
+	// LLM provider selection
+	LLMProviders      string  // Comma-separated fallback chain (e.g., "local,anthropic")
+	LLMTemperature    float64 // Sampling temperature for analysis and merge
+	LLMMaxTokens      int     // Response token limit
+	LLMTimeoutSeconds int     // Per-request timeout
+	LLMRepairAttempts int     // Times an invalid JSON response is sent back for correction
+	AnthropicModel    string
+	OpenAIModel       string // Chat model used when "openai" is in the chain
+	LocalLLMURL       string // Self-hosted OpenAI-compatible endpoint (e.g., http://llm.internal:8000/v1)
+	LocalLLMModel     string
+	LocalLLMAPIKey    string // Optional bearer token for the local endpoint
+	// Agendas for upcoming 1-on-1 meetings
+	AgendaDeliveryHour     int    // Hour of the day before the meeting to send agendas (-1 - disabled)
+	AgendaDeliveryChannels string // Comma-separated: email, telegram
+	// 1-on-1 cadence enforcement
+	CadenceMode           string // off, propose (manager confirms a found slot) or book (create in Exchange)
+	CadenceCheckHour      int    // Hour of the day to look for overdue 1-on-1s
+	CadenceMeetingMinutes int    // Duration of an auto-booked 1-on-1
+	CadenceSearchDays     int    // Working days ahead to search for a free slot
+	CadenceWorkdayStart   int    // First hour a 1-on-1 may start
+	CadenceWorkdayEnd     int    // Hour a 1-on-1 must end by
+	CadenceDigestDay      string // Weekday of the overdue digest for managers (e.g., monday)
+	// Business timezone for scheduled jobs and working hours
+	Timezone string
+	// Background job scheduler (schedules are stored in scheduled_jobs)
+	SchedulerEnabled bool
+	// Apply pending embedded schema migrations at startup (off by default)
+	MigrateOnStart bool
+	// Store domain events in event_outbox before delivering them to subscribers
+	EventOutboxEnabled bool
+	// Outbound webhooks (endpoints are managed via /api/v1/admin/webhooks)
+	WebhooksEnabled       bool // Deliver queued webhooks from this instance
+	WebhookTimeoutSeconds int  // Per-delivery HTTP timeout
+	WebhookMaxAttempts    int  // Attempts before a delivery is marked failed
+	// Send queued email and Telegram notifications from this instance
+	NotificationsEnabled bool
+	// Frontend address for links in notifications, emails and bot messages (empty - no links)
+	AppURL string
+	// mTLS listener for connectors authenticating with client certificates (empty port - off).
+	// The files are reloaded when they change, so certificates rotate without a restart.
+	ConnectorTLSPort     string
+	ConnectorTLSCert     string // Server certificate, PEM
+	ConnectorTLSKey      string // Server private key, PEM
+	ConnectorTLSClientCA string // CA bundle client certificates are verified against
+	// How long AD syncs wait in the queue while no connector is online (0 - fail at once)
+	ConnectorQueueHours int
+	// Database query limits
+	DBQueryTimeoutSeconds int // Limit for a single statement (0 - none)
+	DBSlowQueryMs         int // Statements running longer are logged (0 - disabled)
+	RequestTimeoutSeconds int // Deadline of the request context that cancels queries of slow endpoints
 }
 
 func Load() *Config {
@@ -58,6 +120,8 @@ func Load() *Config {
 		YandexAPIKey:     getEnv("YANDEX_API_KEY", ""),
 		YandexFolderID:   getEnv("YANDEX_FOLDER_ID", ""),
 		ConnectorAPIKey:  getEnv("CONNECTOR_API_KEY", ""),
+		// Default FALSE: connectors are rejected until a key or credential is configured
+		ConnectorAllowUnauthenticated: getEnv("CONNECTOR_ALLOW_UNAUTHENTICATED", "false") == "true",
 		// AD Configuration
 		ADURL:          getEnv("AD_URL", "ldap://172.20.0.33:389"),
 		ADBaseDN:       getEnv("AD_BASE_DN", "OU=EKF-USERS,DC=ekfgroup,DC=ru"),
@@ -86,6 +150,53 @@ func Load() *Config {
 		ConfluenceUsername: getEnv("CONFLUENCE_USERNAME", ""),
 		ConfluencePassword: getEnv("CONFLUENCE_PASSWORD", ""),
 		GitHubToken:        getEnv("GITHUB_TOKEN", ""),
+		MeetingJobWorkers:  getEnvInt("MEETING_JOB_WORKERS", 2),
+		// Speech-to-text
+		STTProviders:           getEnv("STT_PROVIDERS", "whisper,yandex"),
+		STTCategoryProviders:   getEnv("STT_CATEGORY_PROVIDERS", ""),
+		LocalSTTURL:            getEnv("LOCAL_STT_URL", ""),
+		LocalSTTModel:          getEnv("LOCAL_STT_MODEL", ""),
+		LocalSTTAPIKey:         getEnv("LOCAL_STT_API_KEY", ""),
+		LocalSTTTimeoutSeconds: getEnvInt("LOCAL_STT_TIMEOUT_SECONDS", 1800),
+		// LLM
+		LLMProviders:      getEnv("LLM_PROVIDERS", "anthropic"),
+		LLMTemperature:    getEnvFloat("LLM_TEMPERATURE", 0.2),
+		LLMMaxTokens:      getEnvInt("LLM_MAX_TOKENS", 8000),
+		LLMTimeoutSeconds: getEnvInt("LLM_TIMEOUT_SECONDS", 180),
+		LLMRepairAttempts: getEnvInt("LLM_REPAIR_ATTEMPTS", 2),
+		AnthropicModel:    getEnv("ANTHROPIC_MODEL", "claude-sonnet-4-20250514"),
+		OpenAIModel:       getEnv("OPENAI_MODEL", "gpt-4o"),
+		LocalLLMURL:       getEnv("LOCAL_LLM_URL", ""),
+		LocalLLMModel:     getEnv("LOCAL_LLM_MODEL", ""),
+		LocalLLMAPIKey:    getEnv("LOCAL_LLM_API_KEY", ""),
+		// Agendas
+		AgendaDeliveryHour:     getEnvInt("AGENDA_DELIVERY_HOUR", 8),
+		AgendaDeliveryChannels: getEnv("AGENDA_DELIVERY_CHANNELS", "email,telegram"),
+		// 1-on-1 cadence
+		CadenceMode:           getEnv("CADENCE_MODE", "propose"),
+		CadenceCheckHour:      getEnvInt("CADENCE_CHECK_HOUR", 9),
+		CadenceMeetingMinutes: getEnvInt("CADENCE_MEETING_MINUTES", 30),
+		CadenceSearchDays:     getEnvInt("CADENCE_SEARCH_DAYS", 10),
+		CadenceWorkdayStart:   getEnvInt("CADENCE_WORKDAY_START", 10),
+		CadenceWorkdayEnd:     getEnvInt("CADENCE_WORKDAY_END", 18),
+		CadenceDigestDay:      getEnv("CADENCE_DIGEST_DAY", "monday"),
+		Timezone:              getEnv("TIMEZONE", "Europe/Moscow"),
+		SchedulerEnabled:      getEnv("SCHEDULER_ENABLED", "true") == "true",
+		MigrateOnStart:        getEnv("MIGRATE_ON_START", "false") == "true",
+		EventOutboxEnabled:    getEnv("EVENT_OUTBOX_ENABLED", "false") == "true",
+		WebhooksEnabled:       getEnv("WEBHOOKS_ENABLED", "true") == "true",
+		WebhookTimeoutSeconds: getEnvInt("WEBHOOK_TIMEOUT_SECONDS", 10),
+		WebhookMaxAttempts:    getEnvInt("WEBHOOK_MAX_ATTEMPTS", 8),
+		NotificationsEnabled:  getEnv("NOTIFICATIONS_ENABLED", "true") == "true",
+		AppURL:                getEnv("APP_URL", ""),
+		ConnectorTLSPort:      getEnv("CONNECTOR_TLS_PORT", ""),
+		ConnectorTLSCert:      getEnv("CONNECTOR_TLS_CERT", ""),
+		ConnectorTLSKey:       getEnv("CONNECTOR_TLS_KEY", ""),
+		ConnectorTLSClientCA:  getEnv("CONNECTOR_TLS_CLIENT_CA", ""),
+		ConnectorQueueHours:   getEnvInt("CONNECTOR_QUEUE_TTL_HOURS", 24),
+		DBQueryTimeoutSeconds: getEnvInt("DB_QUERY_TIMEOUT_SECONDS", 30),
+		DBSlowQueryMs:         getEnvInt("DB_SLOW_QUERY_MS", 500),
+		RequestTimeoutSeconds: getEnvInt("REQUEST_TIMEOUT_SECONDS", 60),
 	}
 }
 
@@ -96,6 +207,24 @@ func getEnv(key, defaultValue string) string {
 	return defaultValue
 }
 
+func getEnvInt(key string, defaultValue int) int {
+	if value := os.Getenv(key); value != "" {
+		if n, err := strconv.Atoi(value); err == nil {
+			return n
+		}
+	}
+	return defaultValue
+}
+
+func getEnvFloat(key string, defaultValue float64) float64 {
+	if value := os.Getenv(key); value != "" {
+		if f, err := strconv.ParseFloat(value, 64); err == nil {
+			return f
+		}
+	}
+	return defaultValue
+}
+
 // getEnvRequired returns env var value or panics if not set (for critical security configs)
 func getEnvRequired(key string) string {
 	value := os.Getenv(key)
@@ -138,6 +267,14 @@ func (c *Config) ValidateSecuritySettings() {
 		warnings = append(warnings, "JWT_SECRET is less than 32 characters: Use a stronger secret for production")
 	}
 
+	// Check the connector channel
+	if c.ConnectorAPIKey != "" {
+		warnings = append(warnings, "CONNECTOR_API_KEY is set: connectors without their own credential share one key sent in the URL - issue per-connector credentials via /api/v1/admin/connector-credentials and remove it")
+	}
+	if c.ConnectorAllowUnauthenticated {
+		warnings = append(warnings, "CONNECTOR_ALLOW_UNAUTHENTICATED=true: any connector may connect until a connector credential or CONNECTOR_API_KEY is configured")
+	}
+
 	// Log all warnings
 	if len(warnings) > 0 {
 		log.Println("=== SECURITY CONFIGURATION WARNINGS ===")
@@ -1,5 +1,10 @@
 package database
 
+import (
+	"context"
+	"time"
+)
+
 // DBClient is the interface for database operations (PostgreSQL)
 type DBClient interface {
 	From(table string) QueryBuilder
@@ -7,12 +12,20 @@ type DBClient interface {
 	Update(table, keyColumn, keyValue string, data map[string]interface{}) ([]byte, error)
 	Upsert(table string, data []map[string]interface{}, conflictColumn string) ([]byte, error)
 	Delete(table, column, value string) error
+	// WithTx runs fn in a transaction; tx must be used for every statement that belongs to it
+	WithTx(ctx context.Context, fn func(tx DBClient) error) error
+	// WithContext returns a client whose statements are cancelled together with ctx
+	WithContext(ctx context.Context) DBClient
 }
 
 // QueryBuilder is the interface for building queries
 type QueryBuilder interface {
 	Select(columns string) QueryBuilder
 	Eq(column string, value interface{}) QueryBuilder
+	Neq(column string, value interface{}) QueryBuilder
+	Or(filters string) QueryBuilder
+	Not(column, operator, value string) QueryBuilder
+	TextSearch(column, query, config string) QueryBuilder
 	Ilike(column string, pattern string) QueryBuilder
 	Limit(limit int) QueryBuilder
 	Single() QueryBuilder
@@ -20,7 +33,16 @@ type QueryBuilder interface {
 	In(column string, values []string) QueryBuilder
 	Gte(column, value string) QueryBuilder
 	Lte(column, value string) QueryBuilder
+	Lt(column, value string) QueryBuilder
+	Gt(column, value string) QueryBuilder
+	InHierarchy(column, table, parentColumn, rootID string) QueryBuilder
 	IsNull(column string) QueryBuilder
 	Offset(n int) QueryBuilder
+	Keyset(cursor string, desc bool, columns ...string) QueryBuilder
+	WithContext(ctx context.Context) QueryBuilder
+	Timeout(timeout time.Duration) QueryBuilder
 	Execute(result interface{}) error
+	Count() (int, error)
+	// Update sets data on the rows matching the filters and returns them
+	Update(data map[string]interface{}) ([]byte, error)
 }
@@ -0,0 +1,30 @@
+package database
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+)
+
+// ErrInvalidCursor is returned by Execute when the cursor passed to Keyset cannot be decoded
+var ErrInvalidCursor = errors.New("invalid cursor")
+
+// EncodeCursor builds an opaque keyset cursor from the values of the last row of a page,
+// in the order of the Keyset columns
+func EncodeCursor(values ...string) string {
+	data, _ := json.Marshal(values)
+	return base64.RawURLEncoding.EncodeToString(data)
+}
+
+// DecodeCursor returns the values stored by EncodeCursor
+func DecodeCursor(cursor string) ([]string, error) {
+	data, err := base64.RawURLEncoding.DecodeString(cursor)
+	if err != nil {
+		return nil, ErrInvalidCursor
+	}
+	var values []string
+	if err := json.Unmarshal(data, &values); err != nil {
+		return nil, ErrInvalidCursor
+	}
+	return values, nil
+}
@@ -0,0 +1,245 @@
+package database
+
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// Filters passed to Or and Not use the PostgREST syntax:
+//
+//	status.eq.todo
+//	due_date.is.null
+//	status.not.in.(done,cancelled)
+//	title.ilike.*report*
+//	search_vector.wfts(russian).квартальный отчёт
+//	and(priority.eq.high,status.neq.done)
+//
+// Values containing commas or parentheses must be double-quoted (see QuoteFilterValue).
+
+// filterOperators maps PostgREST comparison operators to SQL
+var filterOperators = map[string]string{
+	"eq":    "=",
+	"neq":   "<>",
+	"gt":    ">",
+	"gte":   ">=",
+	"lt":    "<",
+	"lte":   "<=",
+	"like":  "LIKE",
+	"ilike": "ILIKE",
+}
+
+// tsQueryFunctions maps PostgREST full-text operators to tsquery constructors
+var tsQueryFunctions = map[string]string{
+	"fts":   "to_tsquery",
+	"plfts": "plainto_tsquery",
+	"phfts": "phraseto_tsquery",
+	"wfts":  "websearch_to_tsquery",
+}
+
+var identifierRegex = regexp.MustCompile(`^\w+$`)
+
+// QuoteFilterValue quotes a value for use in an Or filter, so that user input
+// cannot change the structure of the filter
+func QuoteFilterValue(value string) string {
+	return strconv.Quote(value)
+}
+
+// filterParser turns filter expressions into SQL, numbering placeholders after args
+type filterParser struct {
+	args []interface{}
+}
+
+func (p *filterParser) arg(value interface{}) string {
+	p.args = append(p.args, value)
+	return fmt.Sprintf("$%d", len(p.args))
+}
+
+// group parses comma-separated filters joined by joiner (AND or OR)
+func (p *filterParser) group(filters, joiner string) (string, error) {
+	parts, err := splitFilters(filters)
+	if err != nil {
+		return "", err
+	}
+	if len(parts) == 0 {
+		return "", fmt.Errorf("empty filter group")
+	}
+
+	conditions := make([]string, 0, len(parts))
+	for _, part := range parts {
+		condition, err := p.filter(part)
+		if err != nil {
+			return "", err
+		}
+		conditions = append(conditions, condition)
+	}
+	return "(" + strings.Join(conditions, " "+joiner+" ") + ")", nil
+}
+
+// filter parses a single filter or a nested and(...)/or(...) group
+func (p *filterParser) filter(expr string) (string, error) {
+	negate := false
+	if rest, ok := strings.CutPrefix(expr, "not."); ok && (strings.HasPrefix(rest, "and(") || strings.HasPrefix(rest, "or(")) {
+		negate = true
+		expr = rest
+	}
+	for _, joiner := range []string{"and", "or"} {
+		if strings.HasPrefix(expr, joiner+"(") && strings.HasSuffix(expr, ")") {
+			condition, err := p.group(expr[len(joiner)+1:len(expr)-1], strings.ToUpper(joiner))
+			if negate {
+				condition = "NOT " + condition
+			}
+			return condition, err
+		}
+	}
+
+	column, rest, ok := strings.Cut(expr, ".")
+	if !ok {
+		return "", fmt.Errorf("invalid filter %q", expr)
+	}
+	if after, ok := strings.CutPrefix(rest, "not."); ok {
+		negate = true
+		rest = after
+	}
+	operator, value, ok := strings.Cut(rest, ".")
+	if !ok {
+		return "", fmt.Errorf("invalid filter %q", expr)
+	}
+
+	condition, err := p.condition(column, operator, value)
+	if err != nil {
+		return "", err
+	}
+	if negate {
+		condition = "NOT (" + condition + ")"
+	}
+	return condition, nil
+}
+
+// condition builds "column operator value"
+func (p *filterParser) condition(column, operator, value string) (string, error) {
+	if !identifierRegex.MatchString(column) {
+		return "", fmt.Errorf("invalid filter column %q", column)
+	}
+
+	// Full-text operators take an optional configuration: wfts(russian)
+	name, config := operator, ""
+	if i := strings.Index(operator, "("); i > 0 && strings.HasSuffix(operator, ")") {
+		name, config = operator[:i], operator[i+1:len(operator)-1]
+	}
+
+	if sqlOperator, ok := filterOperators[name]; ok {
+		v, err := unquoteFilterValue(value)
+		if err != nil {
+			return "", err
+		}
+		if name == "like" || name == "ilike" {
+			v = strings.ReplaceAll(v, "*", "%")
+		}
+		return fmt.Sprintf("%s %s %s", column, sqlOperator, p.arg(v)), nil
+	}
+
+	switch name {
+	case "is":
+		switch strings.ToLower(value) {
+		case "null", "true", "false", "unknown":
+			return fmt.Sprintf("%s IS %s", column, strings.ToUpper(value)), nil
+		}
+		return "", fmt.Errorf("invalid is value %q", value)
+
+	case "in":
+		if !strings.HasPrefix(value, "(") || !strings.HasSuffix(value, ")") {
+			return "", fmt.Errorf("in filter expects a list: %q", value)
+		}
+		items, err := splitFilters(value[1 : len(value)-1])
+		if err != nil {
+			return "", err
+		}
+		if len(items) == 0 {
+			return "FALSE", nil
+		}
+		placeholders := make([]string, len(items))
+		for i, item := range items {
+			v, err := unquoteFilterValue(item)
+			if err != nil {
+				return "", err
+			}
+			placeholders[i] = p.arg(v)
+		}
+		return fmt.Sprintf("%s IN (%s)", column, strings.Join(placeholders, ", ")), nil
+	}
+
+	if fn, ok := tsQueryFunctions[name]; ok {
+		v, err := unquoteFilterValue(value)
+		if err != nil {
+			return "", err
+		}
+		return p.textSearch(column, fn, config, v)
+	}
+
+	return "", fmt.Errorf("unsupported filter operator %q", operator)
+}
+
+// textSearch builds "column @@ fn([config,] query)"
+func (p *filterParser) textSearch(column, fn, config, query string) (string, error) {
+	if config == "" {
+		return fmt.Sprintf("%s @@ %s(%s)", column, fn, p.arg(query)), nil
+	}
+	if !identifierRegex.MatchString(config) {
+		return "", fmt.Errorf("invalid text search configuration %q", config)
+	}
+	return fmt.Sprintf("%s @@ %s(%s::regconfig, %s)", column, fn, p.arg(config), p.arg(query)), nil
+}
+
+// splitFilters splits on commas outside of parentheses and double quotes
+func splitFilters(s string) ([]string, error) {
+	var parts []string
+	depth, start, quoted := 0, 0, false
+	for i := 0; i < len(s); i++ {
+		switch s[i] {
+		case '\\':
+			if quoted {
+				i++
+			}
+		case '"':
+			quoted = !quoted
+		case '(':
+			if !quoted {
+				depth++
+			}
+		case ')':
+			if !quoted {
+				depth--
+				if depth < 0 {
+					return nil, fmt.Errorf("unbalanced parentheses in %q", s)
+				}
+			}
+		case ',':
+			if !quoted && depth == 0 {
+				if part := strings.TrimSpace(s[start:i]); part != "" {
+					parts = append(parts, part)
+				}
+				start = i + 1
+			}
+		}
+	}
+	if depth != 0 || quoted {
+		return nil, fmt.Errorf("unterminated filter %q", s)
+	}
+	if part := strings.TrimSpace(s[start:]); part != "" {
+		parts = append(parts, part)
+	}
+	return parts, nil
+}
+
+func unquoteFilterValue(value string) (string, error) {
+	if !strings.HasPrefix(value, `"`) {
+		return value, nil
+	}
+	v, err := strconv.Unquote(value)
+	if err != nil {
+		return "", fmt.Errorf("invalid quoted value %s", value)
+	}
+	return v, nil
+}
@@ -0,0 +1,119 @@
+package database
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestFilterGroup(t *testing.T) {
+	tests := []struct {
+		filters string
+		want    string
+		args    []interface{}
+	}{
+		{"status.eq.todo", "(status = $1)", []interface{}{"todo"}},
+		{"status.eq.todo,priority.gte.3", "(status = $1 OR priority >= $2)", []interface{}{"todo", "3"}},
+		{"status.neq.done", "(status <> $1)", []interface{}{"done"}},
+		{"title.ilike.*report*", "(title ILIKE $1)", []interface{}{"%report%"}},
+		{"due_date.is.null", "(due_date IS NULL)", nil},
+		{"is_epic.is.TRUE", "(is_epic IS TRUE)", nil},
+		{"status.not.eq.done", "(NOT (status = $1))", []interface{}{"done"}},
+		{"status.in.(done,cancelled)", "(status IN ($1, $2))", []interface{}{"done", "cancelled"}},
+		{"status.in.()", "(FALSE)", nil},
+		{`status.in.("a,b",c)`, "(status IN ($1, $2))", []interface{}{"a,b", "c"}},
+		{`title.eq."x,y)"`, "(title = $1)", []interface{}{"x,y)"}},
+		{
+			"status.eq.todo,and(priority.eq.1,status.neq.done)",
+			"(status = $1 OR (priority = $2 AND status <> $3))",
+			[]interface{}{"todo", "1", "done"},
+		},
+		{
+			"not.and(a.eq.1,b.eq.2),c.eq.3",
+			"(NOT (a = $1 AND b = $2) OR c = $3)",
+			[]interface{}{"1", "2", "3"},
+		},
+		{
+			"search_vector.wfts(russian).квартальный отчёт",
+			"(search_vector @@ websearch_to_tsquery($1::regconfig, $2))",
+			[]interface{}{"russian", "квартальный отчёт"},
+		},
+		{"search_vector.plfts.report", "(search_vector @@ plainto_tsquery($1))", []interface{}{"report"}},
+	}
+	for _, tt := range tests {
+		p := &filterParser{}
+		got, err := p.group(tt.filters, "OR")
+		if err != nil {
+			t.Errorf("group(%q): %v", tt.filters, err)
+			continue
+		}
+		if got != tt.want || !reflect.DeepEqual(p.args, tt.args) {
+			t.Errorf("group(%q) = %q %v, want %q %v", tt.filters, got, p.args, tt.want, tt.args)
+		}
+	}
+}
+
+func TestFilterGroupErrors(t *testing.T) {
+	tests := []string{
+		"",
+		"status",
+		"status.eq",
+		"status.between.1",
+		"status.is.maybe",
+		"status.in.done",
+		"status.in.(a",
+		"and(status.eq.1",
+		"a.eq.1)",
+		`title.eq."unterminated`,
+		`title.eq."bad \q escape"`,
+		// Identifiers are never taken from the filter as SQL
+		"id;drop table tasks.eq.1",
+		"(id).eq.1",
+		"id = 1 or 1.eq.1",
+		"search_vector.wfts(russian');drop).x",
+	}
+	for _, filters := range tests {
+		p := &filterParser{}
+		if got, err := p.group(filters, "OR"); err == nil {
+			t.Errorf("group(%q) = %q, want an error", filters, got)
+		}
+	}
+}
+
+func TestFilterPlaceholdersFollowExistingArgs(t *testing.T) {
+	qb := (&PostgresClient{}).From("tasks").Eq("assignee_id", "u1").
+		Or("status.eq.todo,status.eq.review").Not("priority", "in", "(1,2)").Ilike("title", "%x%")
+	where, args := qb.(*PostgresQueryBuilder).where(true)
+
+	want := " WHERE assignee_id = $1 AND (status = $2 OR status = $3) AND NOT (priority IN ($4, $5)) AND title ILIKE $6"
+	if where != want {
+		t.Errorf("where = %q, want %q", where, want)
+	}
+	if wantArgs := []interface{}{"u1", "todo", "review", "1", "2", "%x%"}; !reflect.DeepEqual(args, wantArgs) {
+		t.Errorf("args = %v, want %v", args, wantArgs)
+	}
+}
+
+func TestInvalidFilterIsReturnedByExecute(t *testing.T) {
+	qb := (&PostgresClient{}).From("tasks").Or("id;drop.eq.1")
+	var rows []map[string]interface{}
+	if err := qb.Execute(&rows); err == nil {
+		t.Error("Execute succeeded with an invalid filter")
+	}
+	if _, err := qb.Count(); err == nil {
+		t.Error("Count succeeded with an invalid filter")
+	}
+}
+
+func TestQuoteFilterValue(t *testing.T) {
+	for _, value := range []string{"plain", "a,b", "x)", `say "hi"`, `back\slash`, "status.eq.done"} {
+		p := &filterParser{}
+		got, err := p.group("title.eq."+QuoteFilterValue(value), "OR")
+		if err != nil {
+			t.Errorf("value %q: %v", value, err)
+			continue
+		}
+		if got != "(title = $1)" || !reflect.DeepEqual(p.args, []interface{}{value}) {
+			t.Errorf("value %q: got %q %v", value, got, p.args)
+		}
+	}
+}
@@ -0,0 +1,58 @@
+package database
+
+import (
+	"context"
+	"errors"
+	"hash/fnv"
+)
+
+// AdvisoryLocker provides cluster-wide mutual exclusion between backend replicas
+type AdvisoryLocker interface {
+	TryAdvisoryLock(ctx context.Context, name string) (unlock func(), acquired bool, err error)
+	XactAdvisoryLock(ctx context.Context, name string) error
+}
+
+// TryAdvisoryLock takes a session-level PostgreSQL advisory lock keyed by name without waiting.
+// The lock lives on a dedicated connection that is held until unlock is called.
+func (c *PostgresClient) TryAdvisoryLock(ctx context.Context, name string) (func(), bool, error) {
+	key := advisoryLockKey(name)
+
+	conn, err := c.db.Conn(ctx)
+	if err != nil {
+		return nil, false, err
+	}
+
+	var acquired bool
+	if err := conn.QueryRowContext(ctx, "SELECT pg_try_advisory_lock($1)", key).Scan(&acquired); err != nil {
+		conn.Close()
+		return nil, false, err
+	}
+	if !acquired {
+		conn.Close()
+		return nil, false, nil
+	}
+
+	unlock := func() {
+		conn.ExecContext(context.Background(), "SELECT pg_advisory_unlock($1)", key)
+		conn.Close()
+	}
+	return unlock, true, nil
+}
+
+// XactAdvisoryLock waits for a transaction-level advisory lock keyed by name.
+// It must be called on the client passed to a WithTx callback; the lock is
+// released when the transaction commits or rolls back.
+func (c *PostgresClient) XactAdvisoryLock(ctx context.Context, name string) error {
+	if c.tx == nil {
+		return errors.New("transaction advisory lock requires WithTx")
+	}
+	_, err := c.q.ExecContext(ctx, "SELECT pg_advisory_xact_lock($1)", advisoryLockKey(name))
+	return err
+}
+
+// advisoryLockKey maps a lock name to the int64 key space of pg_advisory_lock
+func advisoryLockKey(name string) int64 {
+	h := fnv.New64a()
+	h.Write([]byte(name))
+	return int64(h.Sum64())
+}
@@ -0,0 +1,258 @@
+package database
+
+import (
+	"context"
+	"crypto/sha256"
+	"database/sql"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io/fs"
+	"path"
+	"sort"
+	"strings"
+	"time"
+)
+
+const (
+	migrationsTable       = "schema_migrations"
+	migrationsLockName    = "schema_migrations"
+	migrationsLockTimeout = 5 * time.Minute
+)
+
+// ErrNoMigrationHistory is returned by Migrator.Up when nothing has been recorded in
+// schema_migrations yet and allowEmpty is false: the schema may have been created by hand,
+// so it should be baselined first
+var ErrNoMigrationHistory = errors.New("no migration history")
+
+// Migration is a schema change read from an .sql file.
+// Version is the file name without extension; migrations are applied in version order.
+type Migration struct {
+	Version  string
+	SQL      string
+	Checksum string
+}
+
+// MigrationStatus describes a migration in a database
+type MigrationStatus struct {
+	Version   string
+	AppliedAt *time.Time
+	Baseline  bool // recorded by Baseline without running
+	Modified  bool // the file changed after it was applied
+	Missing   bool // applied, but not known to this binary
+}
+
+// Pending reports whether the migration still has to be applied
+func (s MigrationStatus) Pending() bool {
+	return s.AppliedAt == nil
+}
+
+// LoadMigrations reads the *.sql files of fsys sorted by version
+func LoadMigrations(fsys fs.FS) ([]Migration, error) {
+	files, err := fs.Glob(fsys, "*.sql")
+	if err != nil {
+		return nil, err
+	}
+	sort.Strings(files)
+
+	migrations := make([]Migration, 0, len(files))
+	for _, file := range files {
+		data, err := fs.ReadFile(fsys, file)
+		if err != nil {
+			return nil, fmt.Errorf("read migration %s: %w", file, err)
+		}
+		sum := sha256.Sum256(data)
+		migrations = append(migrations, Migration{
+			Version:  strings.TrimSuffix(path.Base(file), ".sql"),
+			SQL:      string(data),
+			Checksum: hex.EncodeToString(sum[:]),
+		})
+	}
+	return migrations, nil
+}
+
+// Migrator applies migrations and records them in schema_migrations.
+// Every operation holds an advisory lock, so replicas starting together
+// apply each migration once.
+type Migrator struct {
+	db         *sql.DB
+	migrations []Migration
+}
+
+// NewMigrator creates a migrator for the given migrations
+func NewMigrator(c *PostgresClient, migrations []Migration) *Migrator {
+	return &Migrator{db: c.db, migrations: migrations}
+}
+
+type appliedMigration struct {
+	checksum  string
+	appliedAt time.Time
+	baseline  bool
+}
+
+// Status lists known and applied migrations in version order
+func (m *Migrator) Status(ctx context.Context) ([]MigrationStatus, error) {
+	var statuses []MigrationStatus
+	err := m.locked(ctx, func(conn *sql.Conn) error {
+		applied, err := m.applied(ctx, conn)
+		if err != nil {
+			return err
+		}
+
+		for _, mig := range m.migrations {
+			status := MigrationStatus{Version: mig.Version}
+			if a, ok := applied[mig.Version]; ok {
+				appliedAt := a.appliedAt
+				status.AppliedAt = &appliedAt
+				status.Baseline = a.baseline
+				status.Modified = !a.baseline && a.checksum != mig.Checksum
+				delete(applied, mig.Version)
+			}
+			statuses = append(statuses, status)
+		}
+		for version, a := range applied {
+			appliedAt := a.appliedAt
+			statuses = append(statuses, MigrationStatus{Version: version, AppliedAt: &appliedAt, Baseline: a.baseline, Missing: true})
+		}
+		sort.Slice(statuses, func(i, j int) bool { return statuses[i].Version < statuses[j].Version })
+		return nil
+	})
+	return statuses, err
+}
+
+// Up applies pending migrations in order, each in its own transaction, and returns them.
+// With dryRun nothing is executed and the pending migrations are returned.
+// allowEmpty permits applying everything to a database without migration history;
+// otherwise ErrNoMigrationHistory is returned for it.
+func (m *Migrator) Up(ctx context.Context, dryRun, allowEmpty bool) ([]Migration, error) {
+	var done []Migration
+	err := m.locked(ctx, func(conn *sql.Conn) error {
+		applied, err := m.applied(ctx, conn)
+		if err != nil {
+			return err
+		}
+		if len(applied) == 0 && !allowEmpty {
+			return ErrNoMigrationHistory
+		}
+
+		for _, mig := range m.migrations {
+			if _, ok := applied[mig.Version]; ok {
+				continue
+			}
+			if !dryRun {
+				if err := m.apply(ctx, conn, mig); err != nil {
+					return err
+				}
+			}
+			done = append(done, mig)
+		}
+		return nil
+	})
+	return done, err
+}
+
+// Baseline records all migrations up to and including version as applied without
+// running them, for databases whose schema was created by hand. It returns how many
+// versions were recorded.
+func (m *Migrator) Baseline(ctx context.Context, version string) (int, error) {
+	known := false
+	for _, mig := range m.migrations {
+		if mig.Version == version {
+			known = true
+		}
+	}
+	if !known {
+		return 0, fmt.Errorf("unknown migration version %q", version)
+	}
+
+	recorded := 0
+	err := m.locked(ctx, func(conn *sql.Conn) error {
+		for _, mig := range m.migrations {
+			if mig.Version > version {
+				break
+			}
+			result, err := conn.ExecContext(ctx,
+				"INSERT INTO "+migrationsTable+" (version, checksum, baseline) VALUES ($1, $2, true) ON CONFLICT (version) DO NOTHING",
+				mig.Version, mig.Checksum)
+			if err != nil {
+				return err
+			}
+			if n, _ := result.RowsAffected(); n > 0 {
+				recorded++
+			}
+		}
+		return nil
+	})
+	return recorded, err
+}
+
+// locked runs fn on a dedicated connection holding the migrations advisory lock,
+// after making sure the tracking table exists
+func (m *Migrator) locked(ctx context.Context, fn func(conn *sql.Conn) error) error {
+	conn, err := m.db.Conn(ctx)
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+
+	key := advisoryLockKey(migrationsLockName)
+	lockCtx, cancel := context.WithTimeout(ctx, migrationsLockTimeout)
+	defer cancel()
+	if _, err := conn.ExecContext(lockCtx, "SELECT pg_advisory_lock($1)", key); err != nil {
+		return fmt.Errorf("acquire migration lock: %w", err)
+	}
+	defer conn.ExecContext(context.Background(), "SELECT pg_advisory_unlock($1)", key)
+
+	if _, err := conn.ExecContext(ctx, `CREATE TABLE IF NOT EXISTS `+migrationsTable+` (
+		version TEXT PRIMARY KEY,
+		checksum TEXT NOT NULL,
+		baseline BOOLEAN NOT NULL DEFAULT false,
+		duration_ms BIGINT NOT NULL DEFAULT 0,
+		applied_at TIMESTAMPTZ NOT NULL DEFAULT NOW()
+	)`); err != nil {
+		return fmt.Errorf("create %s: %w", migrationsTable, err)
+	}
+
+	return fn(conn)
+}
+
+func (m *Migrator) applied(ctx context.Context, conn *sql.Conn) (map[string]appliedMigration, error) {
+	rows, err := conn.QueryContext(ctx, "SELECT version, checksum, applied_at, baseline FROM "+migrationsTable)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	applied := make(map[string]appliedMigration)
+	for rows.Next() {
+		var version string
+		var a appliedMigration
+		if err := rows.Scan(&version, &a.checksum, &a.appliedAt, &a.baseline); err != nil {
+			return nil, err
+		}
+		applied[version] = a
+	}
+	return applied, rows.Err()
+}
+
+// apply runs a migration and records it in the same transaction
+func (m *Migrator) apply(ctx context.Context, conn *sql.Conn, mig Migration) error {
+	started := time.Now()
+
+	tx, err := conn.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	// Without arguments the whole file is sent as one multi-statement query
+	if _, err := tx.ExecContext(ctx, mig.SQL); err != nil {
+		return fmt.Errorf("migration %s: %w", mig.Version, err)
+	}
+	if _, err := tx.ExecContext(ctx,
+		"INSERT INTO "+migrationsTable+" (version, checksum, duration_ms) VALUES ($1, $2, $3)",
+		mig.Version, mig.Checksum, time.Since(started).Milliseconds()); err != nil {
+		return fmt.Errorf("record migration %s: %w", mig.Version, err)
+	}
+	return tx.Commit()
+}
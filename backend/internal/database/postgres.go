@@ -4,17 +4,31 @@ import (
 	"context"
 	"database/sql"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"regexp"
 	"strings"
 	"time"
 
-	_ "github.com/lib/pq"
+	"github.com/ekf/one-on-one-backend/internal/utils"
+	"github.com/lib/pq"
 )
 
 // PostgresClient wraps database/sql for PostgreSQL
 type PostgresClient struct {
-	db *sql.DB
+	db  *sql.DB
+	q   querier         // db, or the transaction of a client created by WithTx
+	tx  *sql.Tx         // nil outside of a transaction
+	ctx context.Context // statements are cancelled with it (see WithContext and WithTx)
+
+	queryTimeout time.Duration // default limit for a single statement, 0 - none
+	slowQuery    time.Duration // statements running longer are logged, 0 - disabled
+}
+
+// querier is the part of *sql.DB and *sql.Tx used to run statements
+type querier interface {
+	QueryContext(ctx context.Context, query string, args ...interface{}) (*sql.Rows, error)
+	ExecContext(ctx context.Context, query string, args ...interface{}) (sql.Result, error)
 }
 
 // NewPostgresClient creates a new PostgreSQL client
@@ -34,7 +48,62 @@ func NewPostgresClient(connectionString string) (*PostgresClient, error) {
 	db.SetMaxIdleConns(5)
 	db.SetConnMaxLifetime(5 * time.Minute)
 
-	return &PostgresClient{db: db}, nil
+	return &PostgresClient{db: db, q: db, ctx: context.Background()}, nil
+}
+
+// SetQueryTimeout limits the duration of every statement (0 - no limit).
+// QueryBuilder.Timeout overrides it for a single query.
+func (c *PostgresClient) SetQueryTimeout(timeout time.Duration) {
+	c.queryTimeout = timeout
+}
+
+// SetSlowQueryThreshold logs statements that run longer than threshold (0 - disabled)
+func (c *PostgresClient) SetSlowQueryThreshold(threshold time.Duration) {
+	c.slowQuery = threshold
+}
+
+// WithContext returns a client whose statements are cancelled together with ctx,
+// e.g. when the HTTP request they serve times out
+func (c *PostgresClient) WithContext(ctx context.Context) DBClient {
+	client := *c
+	client.ctx = ctx
+	return &client
+}
+
+// WithTx runs fn in a transaction. Statements issued through tx run on the transaction
+// with ctx, so cancelling ctx aborts them. The transaction is committed if fn returns nil
+// and rolled back if fn returns an error or panics, or ctx is done.
+// Calling WithTx on a transaction client joins the outer transaction.
+func (c *PostgresClient) WithTx(ctx context.Context, fn func(tx DBClient) error) error {
+	if c.tx != nil {
+		return fn(c)
+	}
+
+	tx, err := c.db.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("begin transaction: %w", err)
+	}
+
+	committed := false
+	defer func() {
+		if !committed {
+			tx.Rollback()
+		}
+	}()
+
+	txClient := *c
+	txClient.q, txClient.tx, txClient.ctx = tx, tx, ctx
+	if err := fn(&txClient); err != nil {
+		return err
+	}
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("commit transaction: %w", err)
+	}
+	committed = true
+	return nil
 }
 
 // relationInfo stores information about a PostgREST-style relationship
@@ -45,6 +114,9 @@ type relationInfo struct {
 	columns    []string // e.g., ["id", "name"]
 }
 
+// ErrUnfilteredUpdate is returned by QueryBuilder.Update without filters
+var ErrUnfilteredUpdate = errors.New("update without filters")
+
 // PostgresQueryBuilder helps build SQL queries
 type PostgresQueryBuilder struct {
 	client       *PostgresClient
@@ -56,7 +128,17 @@ type PostgresQueryBuilder struct {
 	limitVal     int
 	offsetVal    int
 	orderBy      string
+	groupBy      string
 	single       bool
+	ctx          context.Context
+	timeout      time.Duration
+
+	// keyset pagination, see Keyset
+	keysetColumns []string
+	keysetValues  []string
+	keysetDesc    bool
+
+	err error // invalid filter or cursor, returned by Execute and Count
 }
 
 // From starts a query on a table
@@ -65,6 +147,7 @@ func (c *PostgresClient) From(table string) QueryBuilder {
 		client:  c,
 		table:   table,
 		columns: "*",
+		ctx:     c.ctx,
 	}
 }
 
@@ -147,14 +230,57 @@ func parsePostgRESTColumns(columns string) (string, []relationInfo) {
 	return strings.Join(cleanColumns, ", "), relations
 }
 
-// Select specifies which columns to select
+// Select specifies which columns to select.
+// Besides relations it accepts PostgREST-style aggregates: "count()", "mood_score.avg()"
+// or "avg_mood:mood_score.avg()"; the other selected columns become the GROUP BY list.
 func (qb *PostgresQueryBuilder) Select(columns string) QueryBuilder {
 	cleanCols, relations := parsePostgRESTColumns(columns)
-	qb.columns = cleanCols
+	qb.columns, qb.groupBy = parseAggregates(cleanCols)
 	qb.relations = relations
 	return qb
 }
 
+// aggregateRegex matches [alias:]count() and [alias:]column.fn()
+var aggregateRegex = regexp.MustCompile(`^(?:(\w+):)?(?:(\w+)\.)?(count|sum|avg|min|max)\(\)$`)
+
+// parseAggregates rewrites aggregate columns to SQL and returns the columns to group by
+// (empty when the select has no aggregates)
+func parseAggregates(columns string) (string, string) {
+	parts := strings.Split(columns, ",")
+	var selected, groupBy []string
+	hasAggregate := false
+
+	for _, part := range parts {
+		part = strings.TrimSpace(part)
+		match := aggregateRegex.FindStringSubmatch(part)
+		if match == nil {
+			selected = append(selected, part)
+			groupBy = append(groupBy, part)
+			continue
+		}
+
+		hasAggregate = true
+		alias, column, fn := match[1], match[2], match[3]
+		if alias == "" {
+			alias = fn
+		}
+		expr := fn + "(*)"
+		if column != "" {
+			expr = fmt.Sprintf("%s(%s)", fn, column)
+		}
+		// NUMERIC results would be scanned as strings
+		if fn == "avg" || fn == "sum" {
+			expr += "::float8"
+		}
+		selected = append(selected, fmt.Sprintf("%s AS %s", expr, alias))
+	}
+
+	if !hasAggregate {
+		return columns, ""
+	}
+	return strings.Join(selected, ", "), strings.Join(groupBy, ", ")
+}
+
 // Eq adds an equality WHERE clause
 func (qb *PostgresQueryBuilder) Eq(column string, value interface{}) QueryBuilder {
 	qb.whereClauses = append(qb.whereClauses, fmt.Sprintf("%s = $%d", column, len(qb.whereArgs)+1))
@@ -162,6 +288,49 @@ func (qb *PostgresQueryBuilder) Eq(column string, value interface{}) QueryBuilde
 	return qb
 }
 
+// Neq adds a != clause
+func (qb *PostgresQueryBuilder) Neq(column string, value interface{}) QueryBuilder {
+	qb.whereClauses = append(qb.whereClauses, fmt.Sprintf("%s <> $%d", column, len(qb.whereArgs)+1))
+	qb.whereArgs = append(qb.whereArgs, value)
+	return qb
+}
+
+// Or adds a group of PostgREST-style filters of which at least one must match,
+// e.g. Or("status.eq.todo,and(status.eq.review,due_date.lt.2026-01-01)")
+func (qb *PostgresQueryBuilder) Or(filters string) QueryBuilder {
+	p := &filterParser{args: append([]interface{}{}, qb.whereArgs...)}
+	condition, err := p.group(filters, "OR")
+	return qb.addFilter(condition, p.args, err)
+}
+
+// Not adds a negated PostgREST-style filter, e.g. Not("status", "in", "(done,cancelled)")
+func (qb *PostgresQueryBuilder) Not(column, operator, value string) QueryBuilder {
+	p := &filterParser{args: append([]interface{}{}, qb.whereArgs...)}
+	condition, err := p.condition(column, operator, value)
+	return qb.addFilter("NOT ("+condition+")", p.args, err)
+}
+
+// TextSearch matches a tsvector column against a web-search style query
+// ("quoted phrase", -excluded, or). config is the text search configuration
+// the column was built with; empty uses the database default.
+func (qb *PostgresQueryBuilder) TextSearch(column, query, config string) QueryBuilder {
+	p := &filterParser{args: append([]interface{}{}, qb.whereArgs...)}
+	condition, err := p.textSearch(column, tsQueryFunctions["wfts"], config, query)
+	return qb.addFilter(condition, p.args, err)
+}
+
+func (qb *PostgresQueryBuilder) addFilter(condition string, args []interface{}, err error) QueryBuilder {
+	if err != nil {
+		if qb.err == nil {
+			qb.err = err
+		}
+		return qb
+	}
+	qb.whereClauses = append(qb.whereClauses, condition)
+	qb.whereArgs = args
+	return qb
+}
+
 // Ilike adds a case-insensitive LIKE clause
 func (qb *PostgresQueryBuilder) Ilike(column string, pattern string) QueryBuilder {
 	qb.whereClauses = append(qb.whereClauses, fmt.Sprintf("%s ILIKE $%d", column, len(qb.whereArgs)+1))
@@ -204,6 +373,65 @@ func (qb *PostgresQueryBuilder) Lte(column, value string) QueryBuilder {
 	return qb
 }
 
+// Lt adds < clause
+func (qb *PostgresQueryBuilder) Lt(column, value string) QueryBuilder {
+	qb.whereClauses = append(qb.whereClauses, fmt.Sprintf("%s < $%d", column, len(qb.whereArgs)+1))
+	qb.whereArgs = append(qb.whereArgs, value)
+	return qb
+}
+
+// Gt adds > clause
+func (qb *PostgresQueryBuilder) Gt(column, value string) QueryBuilder {
+	qb.whereClauses = append(qb.whereClauses, fmt.Sprintf("%s > $%d", column, len(qb.whereArgs)+1))
+	qb.whereArgs = append(qb.whereArgs, value)
+	return qb
+}
+
+// InHierarchy keeps rows whose column references a descendant of rootID in a tree table
+// linked by parentColumn, e.g. InHierarchy("assignee_id", "employees", "manager_id", managerID)
+// for tasks of everyone below a manager. The tree is walked by a recursive CTE in the same
+// query; rootID itself is not included.
+func (qb *PostgresQueryBuilder) InHierarchy(column, table, parentColumn, rootID string) QueryBuilder {
+	qb.whereClauses = append(qb.whereClauses, fmt.Sprintf(
+		"%s IN (WITH RECURSIVE subtree AS ("+
+			"SELECT id FROM %s WHERE %s = $%d "+
+			"UNION SELECT t.id FROM %s t JOIN subtree s ON t.%s = s.id"+
+			") SELECT id FROM subtree)",
+		column, table, parentColumn, len(qb.whereArgs)+1, table, parentColumn))
+	qb.whereArgs = append(qb.whereArgs, rootID)
+	return qb
+}
+
+// Keyset orders by columns and, for a non-empty cursor, returns the rows after the one
+// the cursor was built from (see EncodeCursor). The last column must be unique, e.g.
+// Keyset(cursor, true, "created_at", "id"). Unlike Offset, pages stay stable while rows are
+// inserted and deep pages cost the same as the first one.
+func (qb *PostgresQueryBuilder) Keyset(cursor string, desc bool, columns ...string) QueryBuilder {
+	order := "ASC"
+	if desc {
+		order = "DESC"
+	}
+	orderBy := make([]string, len(columns))
+	for i, column := range columns {
+		orderBy[i] = column + " " + order
+	}
+	qb.orderBy = strings.Join(orderBy, ", ")
+
+	qb.keysetColumns, qb.keysetValues, qb.keysetDesc = columns, nil, desc
+	if cursor != "" {
+		values, err := DecodeCursor(cursor)
+		if err == nil && len(values) != len(columns) {
+			err = ErrInvalidCursor
+		}
+		if err != nil {
+			qb.err = err
+			return qb
+		}
+		qb.keysetValues = values
+	}
+	return qb
+}
+
 // IsNull adds IS NULL clause
 func (qb *PostgresQueryBuilder) IsNull(column string) QueryBuilder {
 	qb.whereClauses = append(qb.whereClauses, fmt.Sprintf("%s IS NULL", column))
@@ -229,12 +457,122 @@ func (qb *PostgresQueryBuilder) Single() QueryBuilder {
 	return qb
 }
 
+// WithContext cancels the query (and its relation lookups) together with ctx
+func (qb *PostgresQueryBuilder) WithContext(ctx context.Context) QueryBuilder {
+	qb.ctx = ctx
+	return qb
+}
+
+// Timeout overrides the client query timeout for this query
+func (qb *PostgresQueryBuilder) Timeout(timeout time.Duration) QueryBuilder {
+	qb.timeout = timeout
+	return qb
+}
+
+// where returns the WHERE part of the query and its arguments. The keyset
+// condition is left out when counting.
+func (qb *PostgresQueryBuilder) where(withKeyset bool) (string, []interface{}) {
+	clauses, args := qb.whereClauses, qb.whereArgs
+	if withKeyset && len(qb.keysetValues) > 0 {
+		args = append([]interface{}{}, args...)
+		placeholders := make([]string, len(qb.keysetValues))
+		for i, v := range qb.keysetValues {
+			args = append(args, v)
+			placeholders[i] = fmt.Sprintf("$%d", len(args))
+		}
+		cmp := ">"
+		if qb.keysetDesc {
+			cmp = "<"
+		}
+		clauses = append(append([]string{}, clauses...), fmt.Sprintf("(%s) %s (%s)",
+			strings.Join(qb.keysetColumns, ", "), cmp, strings.Join(placeholders, ", ")))
+	}
+
+	if len(clauses) == 0 {
+		return "", args
+	}
+	return " WHERE " + strings.Join(clauses, " AND "), args
+}
+
+// Count returns the number of rows (or groups, for aggregate selects) matching the
+// filters. Order, limit, offset and the keyset cursor are ignored, so it gives the
+// total of a paginated list.
+func (qb *PostgresQueryBuilder) Count() (int, error) {
+	if qb.err != nil {
+		return 0, qb.err
+	}
+
+	where, args := qb.where(false)
+	query := fmt.Sprintf("SELECT count(*) FROM %s%s", qb.table, where)
+	if qb.groupBy != "" {
+		query = fmt.Sprintf("SELECT count(*) FROM (SELECT 1 FROM %s%s GROUP BY %s) grouped", qb.table, where, qb.groupBy)
+	}
+
+	ctx, done := qb.client.statement(qb.ctx, qb.timeout, query)
+	defer done()
+
+	rows, err := qb.client.q.QueryContext(ctx, query, args...)
+	if err != nil {
+		return 0, statementError(ctx, "count", err)
+	}
+	defer rows.Close()
+
+	var count int
+	if rows.Next() {
+		if err := rows.Scan(&count); err != nil {
+			return 0, err
+		}
+	}
+	if err := rows.Err(); err != nil {
+		return 0, statementError(ctx, "count", err)
+	}
+	return count, nil
+}
+
+// Update sets data on every row matching the filters in one statement and returns the
+// updated rows, e.g. From("notifications").Eq("user_id", id).IsNull("read_at").Update(...).
+// Select, order, limit and the keyset cursor are ignored; a query without filters is
+// rejected, so a missing filter cannot update the whole table.
+func (qb *PostgresQueryBuilder) Update(data map[string]interface{}) ([]byte, error) {
+	if qb.err != nil {
+		return nil, qb.err
+	}
+	where, args := qb.where(false)
+	if where == "" {
+		return nil, ErrUnfilteredUpdate
+	}
+
+	args = append([]interface{}{}, args...)
+	setClauses := make([]string, 0, len(data))
+	for col, val := range data {
+		args = append(args, val)
+		setClauses = append(setClauses, fmt.Sprintf("%s = $%d", col, len(args)))
+	}
+	query := fmt.Sprintf("UPDATE %s SET %s%s RETURNING *", qb.table, strings.Join(setClauses, ", "), where)
+
+	ctx, done := qb.client.statement(qb.ctx, qb.timeout, query)
+	defer done()
+
+	rows, err := qb.client.q.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, statementError(ctx, "update", err)
+	}
+	defer rows.Close()
+
+	return updatedRows(rows)
+}
+
 // Execute executes the query and scans results into the provided slice
 func (qb *PostgresQueryBuilder) Execute(result interface{}) error {
-	query := fmt.Sprintf("SELECT %s FROM %s", qb.columns, qb.table)
+	if qb.err != nil {
+		return qb.err
+	}
+
+	where, args := qb.where(true)
+	query := fmt.Sprintf("SELECT %s FROM %s%s", qb.columns, qb.table, where)
 
-	if len(qb.whereClauses) > 0 {
-		query += " WHERE " + strings.Join(qb.whereClauses, " AND ")
+	if qb.groupBy != "" {
+		query += " GROUP BY " + qb.groupBy
 	}
 
 	if qb.orderBy != "" {
@@ -249,9 +587,12 @@ func (qb *PostgresQueryBuilder) Execute(result interface{}) error {
 		query += fmt.Sprintf(" OFFSET %d", qb.offsetVal)
 	}
 
-	rows, err := qb.client.db.Query(query, qb.whereArgs...)
+	ctx, done := qb.client.statement(qb.ctx, qb.timeout, query)
+	defer done()
+
+	rows, err := qb.client.q.QueryContext(ctx, query, args...)
 	if err != nil {
-		return fmt.Errorf("query failed: %w", err)
+		return statementError(ctx, "query", err)
 	}
 	defer rows.Close()
 
@@ -286,10 +627,13 @@ func (qb *PostgresQueryBuilder) Execute(result interface{}) error {
 		}
 		results = append(results, row)
 	}
+	if err := rows.Err(); err != nil {
+		return statementError(ctx, "query", err)
+	}
 
 	// Fetch related data for each relation
 	if len(qb.relations) > 0 {
-		results = qb.fetchRelations(results)
+		results = qb.fetchRelations(ctx, results)
 	}
 
 	// Marshal and unmarshal to convert to target type
@@ -314,7 +658,7 @@ func (qb *PostgresQueryBuilder) Execute(result interface{}) error {
 }
 
 // fetchRelations fetches related data for each result row
-func (qb *PostgresQueryBuilder) fetchRelations(results []map[string]interface{}) []map[string]interface{} {
+func (qb *PostgresQueryBuilder) fetchRelations(ctx context.Context, results []map[string]interface{}) []map[string]interface{} {
 	for _, rel := range qb.relations {
 		// Determine the foreign key column name
 		fkColumn := singularize(rel.alias) + "_id"
@@ -350,7 +694,7 @@ func (qb *PostgresQueryBuilder) fetchRelations(results []map[string]interface{})
 		}
 
 		// Fetch related data
-		relatedData := qb.fetchRelatedData(rel, fkValues)
+		relatedData := qb.fetchRelatedData(ctx, rel, fkValues)
 
 		// Merge related data into results
 		for i, row := range results {
@@ -371,7 +715,7 @@ func (qb *PostgresQueryBuilder) fetchRelations(results []map[string]interface{})
 }
 
 // fetchRelatedData fetches data from a related table
-func (qb *PostgresQueryBuilder) fetchRelatedData(rel relationInfo, fkValues map[string]bool) map[string]map[string]interface{} {
+func (qb *PostgresQueryBuilder) fetchRelatedData(ctx context.Context, rel relationInfo, fkValues map[string]bool) map[string]map[string]interface{} {
 	relatedData := make(map[string]map[string]interface{})
 
 	if len(fkValues) == 0 {
@@ -405,7 +749,7 @@ func (qb *PostgresQueryBuilder) fetchRelatedData(rel relationInfo, fkValues map[
 		strings.Join(placeholders, ", "),
 	)
 
-	rows, err := qb.client.db.Query(query, args...)
+	rows, err := qb.client.q.QueryContext(ctx, query, args...)
 	if err != nil {
 		return relatedData
 	}
@@ -482,9 +826,12 @@ func (c *PostgresClient) Insert(table string, data map[string]interface{}) ([]by
 		strings.Join(placeholders, ", "),
 	)
 
-	rows, err := c.db.Query(query, values...)
+	ctx, done := c.statement(c.ctx, 0, query)
+	defer done()
+
+	rows, err := c.q.QueryContext(ctx, query, values...)
 	if err != nil {
-		return nil, fmt.Errorf("insert failed: %w", err)
+		return nil, statementError(ctx, "insert", err)
 	}
 	defer rows.Close()
 
@@ -543,12 +890,20 @@ func (c *PostgresClient) Update(table, keyColumn, keyValue string, data map[stri
 		i,
 	)
 
-	rows, err := c.db.Query(query, values...)
+	ctx, done := c.statement(c.ctx, 0, query)
+	defer done()
+
+	rows, err := c.q.QueryContext(ctx, query, values...)
 	if err != nil {
-		return nil, fmt.Errorf("update failed: %w", err)
+		return nil, statementError(ctx, "update", err)
 	}
 	defer rows.Close()
 
+	return updatedRows(rows)
+}
+
+// updatedRows returns the RETURNING rows of an update as a JSON array
+func updatedRows(rows *sql.Rows) ([]byte, error) {
 	// Get actual column names from result
 	columnNames, err := rows.Columns()
 	if err != nil {
@@ -578,62 +933,113 @@ func (c *PostgresClient) Update(table, keyColumn, keyValue string, data map[stri
 		}
 		results = append(results, result)
 	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
 
 	return json.Marshal(results)
 }
 
-// Upsert upserts data (not implemented yet, just inserts)
+// Upsert inserts rows, updating existing ones on conflictColumn, in one transaction
 func (c *PostgresClient) Upsert(table string, data []map[string]interface{}, conflictColumn string) ([]byte, error) {
-	ctx := context.Background()
-	tx, err := c.db.BeginTx(ctx, nil)
-	if err != nil {
-		return nil, err
-	}
-	defer tx.Rollback()
-
-	for _, row := range data {
-		columns := []string{}
-		placeholders := []string{}
-		values := []interface{}{}
-		updateClauses := []string{}
-
-		i := 1
-		for col, val := range row {
-			columns = append(columns, col)
-			placeholders = append(placeholders, fmt.Sprintf("$%d", i))
-			values = append(values, val)
-			if col != conflictColumn {
-				updateClauses = append(updateClauses, fmt.Sprintf("%s = EXCLUDED.%s", col, col))
+	err := c.WithTx(c.ctx, func(tx DBClient) error {
+		txClient := tx.(*PostgresClient)
+		for _, row := range data {
+			columns := []string{}
+			placeholders := []string{}
+			values := []interface{}{}
+			updateClauses := []string{}
+
+			i := 1
+			for col, val := range row {
+				columns = append(columns, col)
+				placeholders = append(placeholders, fmt.Sprintf("$%d", i))
+				values = append(values, val)
+				if col != conflictColumn {
+					updateClauses = append(updateClauses, fmt.Sprintf("%s = EXCLUDED.%s", col, col))
+				}
+				i++
+			}
+
+			query := fmt.Sprintf(
+				"INSERT INTO %s (%s) VALUES (%s) ON CONFLICT (%s) DO UPDATE SET %s",
+				table,
+				strings.Join(columns, ", "),
+				strings.Join(placeholders, ", "),
+				conflictColumn,
+				strings.Join(updateClauses, ", "),
+			)
+
+			ctx, done := txClient.statement(txClient.ctx, 0, query)
+			_, err := txClient.q.ExecContext(ctx, query, values...)
+			done()
+			if err != nil {
+				return statementError(ctx, "upsert", err)
 			}
-			i++
 		}
+		return nil
+	})
+	return nil, err
+}
 
-		query := fmt.Sprintf(
-			"INSERT INTO %s (%s) VALUES (%s) ON CONFLICT (%s) DO UPDATE SET %s",
-			table,
-			strings.Join(columns, ", "),
-			strings.Join(placeholders, ", "),
-			conflictColumn,
-			strings.Join(updateClauses, ", "),
-		)
+// Delete deletes data from a table
+func (c *PostgresClient) Delete(table, column, value string) error {
+	query := fmt.Sprintf("DELETE FROM %s WHERE %s = $1", table, column)
+	ctx, done := c.statement(c.ctx, 0, query)
+	defer done()
 
-		if _, err := tx.Exec(query, values...); err != nil {
-			return nil, err
+	if _, err := c.q.ExecContext(ctx, query, value); err != nil {
+		return statementError(ctx, "delete", err)
+	}
+	return nil
+}
+
+// statement derives the context of a single statement from ctx and the query timeout
+// (timeout overrides the client default). done must be called once the statement and
+// its rows are finished; it logs the statement if it was slow.
+func (c *PostgresClient) statement(ctx context.Context, timeout time.Duration, query string) (context.Context, func()) {
+	if timeout == 0 {
+		timeout = c.queryTimeout
+	}
+	cancel := context.CancelFunc(func() {})
+	if timeout > 0 {
+		ctx, cancel = context.WithTimeout(ctx, timeout)
+	}
+
+	started := time.Now()
+	return ctx, func() {
+		cancel()
+		if elapsed := time.Since(started); c.slowQuery > 0 && elapsed >= c.slowQuery {
+			utils.GetLogger().Warn("Slow query", map[string]interface{}{
+				"query":       truncateQuery(query),
+				"duration_ms": elapsed.Milliseconds(),
+				"in_tx":       c.tx != nil,
+			})
 		}
 	}
+}
 
-	if err := tx.Commit(); err != nil {
-		return nil, err
+// statementError reports a cancelled or timed out statement as the context error,
+// so callers can check errors.Is(err, context.DeadlineExceeded)
+func statementError(ctx context.Context, op string, err error) error {
+	if ctxErr := ctx.Err(); ctxErr != nil {
+		return fmt.Errorf("%s cancelled: %w", op, ctxErr)
 	}
+	return fmt.Errorf("%s failed: %w", op, err)
+}
 
-	return nil, nil
+// IsUniqueViolation reports whether a statement failed on a unique constraint
+func IsUniqueViolation(err error) bool {
+	var pqErr *pq.Error
+	return errors.As(err, &pqErr) && pqErr.Code == "23505"
 }
 
-// Delete deletes data from a table
-func (c *PostgresClient) Delete(table, column, value string) error {
-	query := fmt.Sprintf("DELETE FROM %s WHERE %s = $1", table, column)
-	_, err := c.db.Exec(query, value)
-	return err
+func truncateQuery(query string) string {
+	const maxLen = 500
+	if len(query) > maxLen {
+		return query[:maxLen] + "..."
+	}
+	return query
 }
 
 // Close closes the database connection
@@ -0,0 +1,13 @@
+package database
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestUpdateRequiresFilters(t *testing.T) {
+	_, err := (&PostgresClient{}).From("notifications").Update(map[string]interface{}{"read_at": "now"})
+	if !errors.Is(err, ErrUnfilteredUpdate) {
+		t.Errorf("Update() error = %v, want ErrUnfilteredUpdate", err)
+	}
+}
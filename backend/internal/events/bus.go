@@ -0,0 +1,171 @@
+package events
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/ekf/one-on-one-backend/internal/database"
+	"github.com/ekf/one-on-one-backend/internal/utils"
+	"github.com/google/uuid"
+)
+
+const (
+	queueSize      = 1000
+	handlerTimeout = time.Minute
+)
+
+// ErrQueueFull is returned by Publish when the in-memory queue has no room for the event
+var ErrQueueFull = errors.New("event queue is full")
+
+// subscriber handles one event type. Events published in this process are passed as
+// event; events read from the outbox are passed as payload and decoded by handle.
+type subscriber struct {
+	name   string
+	handle func(ctx context.Context, meta Meta, event Event, payload []byte) error
+}
+
+type envelope struct {
+	meta  Meta
+	event Event
+}
+
+// Bus delivers published events to subscribers in the background, in publishing order
+type Bus struct {
+	db     database.DBClient
+	outbox *outbox // nil - events are kept in memory only
+
+	mu          sync.RWMutex
+	subscribers map[string][]subscriber
+	queue       chan envelope
+	started     bool
+}
+
+// New creates a bus. With useOutbox events are stored in event_outbox by Publish and
+// delivered from there with retries; otherwise they are lost on restart, dropped while
+// the in-memory queue is full and failed subscribers are only logged.
+func New(db database.DBClient, useOutbox bool) *Bus {
+	b := &Bus{
+		db:          db,
+		subscribers: make(map[string][]subscriber),
+		queue:       make(chan envelope, queueSize),
+	}
+	if useOutbox && db != nil {
+		b.outbox = newOutbox(b, db)
+	}
+	return b
+}
+
+// Durable reports whether events are stored in the outbox. Without it an event published
+// inside a transaction should be queued only after the commit.
+func (b *Bus) Durable() bool {
+	return b.outbox != nil
+}
+
+// Subscribe registers fn for events of type E under a subscriber name. The name identifies
+// the subscriber in the outbox: an event is retried only for subscribers that failed it.
+func Subscribe[E Event](b *Bus, name string, fn func(ctx context.Context, meta Meta, event E) error) {
+	var zero E
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.subscribers[zero.EventName()] = append(b.subscribers[zero.EventName()], subscriber{
+		name: name,
+		handle: func(ctx context.Context, meta Meta, event Event, payload []byte) error {
+			typed, ok := event.(E)
+			if !ok {
+				if err := json.Unmarshal(payload, &typed); err != nil {
+					return fmt.Errorf("decode %s: %w", meta.Name, err)
+				}
+			}
+			return fn(ctx, meta, typed)
+		},
+	})
+}
+
+// Start begins delivering events
+func (b *Bus) Start() {
+	b.mu.Lock()
+	if b.started {
+		b.mu.Unlock()
+		return
+	}
+	b.started = true
+	b.mu.Unlock()
+
+	if b.outbox != nil {
+		b.outbox.start()
+		return
+	}
+	go func() {
+		for e := range b.queue {
+			b.dispatch(e.meta, e.event, nil, nil)
+		}
+	}()
+}
+
+// Publish emits an event on behalf of actorID (empty for background jobs). With the outbox
+// the event is inserted through db, so inside a transaction it is stored only if the
+// transaction commits. An error means the event was not stored. Without the outbox
+// Publish never blocks: an event that does not fit into the queue is dropped with ErrQueueFull.
+func (b *Bus) Publish(db database.DBClient, actorID string, event Event) error {
+	meta := Meta{
+		ID:         uuid.New().String(),
+		Name:       event.EventName(),
+		ActorID:    actorID,
+		OccurredAt: time.Now().UTC(),
+	}
+
+	if b.outbox != nil {
+		if db == nil {
+			db = b.db
+		}
+		return b.outbox.store(db, meta, event)
+	}
+
+	select {
+	case b.queue <- envelope{meta: meta, event: event}:
+		return nil
+	default:
+		return ErrQueueFull
+	}
+}
+
+// dispatch runs the subscribers of an event, skipping those in done, and returns the
+// names of the ones that failed
+func (b *Bus) dispatch(meta Meta, event Event, payload []byte, done map[string]bool) map[string]error {
+	b.mu.RLock()
+	subscribers := b.subscribers[meta.Name]
+	b.mu.RUnlock()
+
+	failed := make(map[string]error)
+	for _, s := range subscribers {
+		if done[s.name] {
+			continue
+		}
+		if err := b.run(s, meta, event, payload); err != nil {
+			failed[s.name] = err
+			utils.GetLogger().Warn("Event subscriber failed", map[string]interface{}{
+				"event":      meta.Name,
+				"event_id":   meta.ID,
+				"subscriber": s.name,
+				"error":      err.Error(),
+			})
+		}
+	}
+	return failed
+}
+
+// run calls a subscriber with a timeout; a panic is reported as an error
+func (b *Bus) run(s subscriber, meta Meta, event Event, payload []byte) (err error) {
+	ctx, cancel := context.WithTimeout(context.Background(), handlerTimeout)
+	defer cancel()
+	defer func() {
+		if r := recover(); r != nil {
+			err = fmt.Errorf("panic: %v", r)
+		}
+	}()
+	return s.handle(ctx, meta, event, payload)
+}
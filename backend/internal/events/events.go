@@ -0,0 +1,131 @@
+// Package events is an in-process publish/subscribe bus for domain events. Handlers publish
+// typed events after a change; side effects (notifications, history, webhooks) subscribe to
+// them. With the outbox enabled events are stored in event_outbox first, so subscribers
+// that fail or miss events during a restart get them again.
+package events
+
+import (
+	"time"
+
+	"github.com/ekf/one-on-one-backend/internal/models"
+)
+
+// Event is a domain event. EventName must not depend on the receiver's fields.
+type Event interface {
+	EventName() string
+}
+
+// Meta describes a published event
+type Meta struct {
+	ID         string    `json:"id"`
+	Name       string    `json:"name"`
+	ActorID    string    `json:"actor_id,omitempty"` // empty for background jobs
+	OccurredAt time.Time `json:"occurred_at"`
+}
+
+// FieldChange is an old and new value of a changed field
+type FieldChange struct {
+	Field string      `json:"field"`
+	Old   interface{} `json:"old"`
+	New   interface{} `json:"new"`
+}
+
+// TaskCreated is published after a task is created
+type TaskCreated struct {
+	Task models.Task `json:"task"`
+}
+
+func (TaskCreated) EventName() string { return "task.created" }
+
+// TaskUpdated is published after task fields are changed
+type TaskUpdated struct {
+	TaskID  string        `json:"task_id"`
+	Changes []FieldChange `json:"changes"`
+}
+
+func (TaskUpdated) EventName() string { return "task.updated" }
+
+// TaskStatusChanged is published after a task moves to another status
+type TaskStatusChanged struct {
+	TaskID    string       `json:"task_id"`
+	OldStatus string       `json:"old_status"`
+	NewStatus string       `json:"new_status"`
+	Task      *models.Task `json:"task,omitempty"`
+}
+
+func (TaskStatusChanged) EventName() string { return "task.status_changed" }
+
+// MeetingAnalyzed is published after a meeting is analyzed, when processed from a recording
+// (JobID is set) or analyzed again (Revision is set)
+type MeetingAnalyzed struct {
+	MeetingID    string      `json:"meeting_id"`
+	JobID        string      `json:"job_id,omitempty"`
+	Revision     int         `json:"revision,omitempty"`
+	CategoryCode string      `json:"category_code"`
+	Summary      interface{} `json:"summary"`
+	MoodScore    interface{} `json:"mood_score"`
+	Provider     string      `json:"provider,omitempty"`
+	Model        string      `json:"model,omitempty"`
+}
+
+func (MeetingAnalyzed) EventName() string { return "meeting.analyzed" }
+
+// TicketCreated is published after a service ticket is created
+type TicketCreated struct {
+	Ticket models.ServiceTicket `json:"ticket"`
+}
+
+func (TicketCreated) EventName() string { return "ticket.created" }
+
+// TicketStatusChanged is published after a service ticket moves to another status
+type TicketStatusChanged struct {
+	TicketID  string `json:"ticket_id"`
+	OldStatus string `json:"old_status"`
+	NewStatus string `json:"new_status"`
+}
+
+func (TicketStatusChanged) EventName() string { return "ticket.status_changed" }
+
+// TicketAssigned is published after a service ticket is assigned
+type TicketAssigned struct {
+	TicketID   string `json:"ticket_id"`
+	AssigneeID string `json:"assignee_id"`
+}
+
+func (TicketAssigned) EventName() string { return "ticket.assigned" }
+
+// TicketSLABreached is published once per ticket whose SLA deadline has passed
+type TicketSLABreached struct {
+	TicketID    string    `json:"ticket_id"`
+	Number      string    `json:"number"`
+	Title       string    `json:"title"`
+	Priority    string    `json:"priority"`
+	AssigneeID  *string   `json:"assignee_id"`
+	SLADeadline time.Time `json:"sla_deadline"`
+}
+
+func (TicketSLABreached) EventName() string { return "ticket.sla_breached" }
+
+// ImprovementApproved is published after an improvement request passes an approval stage
+type ImprovementApproved struct {
+	RequestID  string                     `json:"request_id"`
+	ApproverID string                     `json:"approver_id"`
+	Stage      string                     `json:"stage"`
+	NewStatus  string                     `json:"new_status"`
+	Comment    *string                    `json:"comment,omitempty"`
+	Request    *models.ImprovementRequest `json:"request,omitempty"`
+}
+
+func (ImprovementApproved) EventName() string { return "improvement.approved" }
+
+// MessageSent is published after a messenger message is stored
+type MessageSent struct {
+	MessageID      string `json:"message_id"`
+	ConversationID string `json:"conversation_id"`
+	SenderID       string `json:"sender_id"`
+	SenderName     string `json:"sender_name"`
+	Content        string `json:"content"`
+	MessageType    string `json:"message_type"`
+}
+
+func (MessageSent) EventName() string { return "message.sent" }
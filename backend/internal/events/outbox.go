@@ -0,0 +1,284 @@
+package events
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/ekf/one-on-one-backend/internal/database"
+	"github.com/ekf/one-on-one-backend/internal/utils"
+)
+
+const (
+	relayInterval = time.Second
+	relayLock     = "events:outbox"
+	relayBatch    = 100
+	maxAttempts   = 10
+	baseBackoff   = 10 * time.Second
+	maxBackoff    = time.Hour
+)
+
+// Outbox entry statuses for OutboxEntries
+const (
+	OutboxPending   = "pending"
+	OutboxProcessed = "processed"
+	OutboxFailed    = "failed" // gave up after maxAttempts, see RetryOutboxEntry
+)
+
+var (
+	ErrOutboxDisabled = errors.New("event outbox is disabled")
+	ErrEntryNotFound  = errors.New("outbox entry not found")
+)
+
+// OutboxEntry is a stored event with its delivery state
+type OutboxEntry struct {
+	ID                   string          `json:"id"`
+	Name                 string          `json:"name"`
+	ActorID              *string         `json:"actor_id,omitempty"`
+	Payload              json.RawMessage `json:"payload"`
+	Attempts             int             `json:"attempts"`
+	CompletedSubscribers []string        `json:"completed_subscribers"`
+	LastError            *string         `json:"last_error,omitempty"`
+	OccurredAt           time.Time       `json:"occurred_at"`
+	NextAttemptAt        *time.Time      `json:"next_attempt_at,omitempty"`
+	ProcessedAt          *time.Time      `json:"processed_at,omitempty"`
+	FailedAt             *time.Time      `json:"failed_at,omitempty"`
+}
+
+// outboxRow is an event_outbox row; JSONB columns come back as strings
+type outboxRow struct {
+	OutboxEntry
+	Payload              string `json:"payload"`
+	CompletedSubscribers string `json:"completed_subscribers"`
+}
+
+func (r outboxRow) entry() OutboxEntry {
+	e := r.OutboxEntry
+	e.Payload = json.RawMessage(r.Payload)
+	e.CompletedSubscribers = []string{}
+	json.Unmarshal([]byte(r.CompletedSubscribers), &e.CompletedSubscribers)
+	return e
+}
+
+// outbox stores published events and relays them to subscribers. Replicas coordinate
+// through an advisory lock, so events are relayed in order by one of them at a time.
+type outbox struct {
+	bus    *Bus
+	db     database.DBClient
+	locker database.AdvisoryLocker
+	wake   chan struct{}
+}
+
+func newOutbox(bus *Bus, db database.DBClient) *outbox {
+	o := &outbox{bus: bus, db: db, wake: make(chan struct{}, 1)}
+	if locker, ok := db.(database.AdvisoryLocker); ok {
+		o.locker = locker
+	}
+	return o
+}
+
+func (o *outbox) store(db database.DBClient, meta Meta, event Event) error {
+	payload, err := json.Marshal(event)
+	if err != nil {
+		return err
+	}
+	data := map[string]interface{}{
+		"id":              meta.ID,
+		"name":            meta.Name,
+		"payload":         string(payload),
+		"occurred_at":     meta.OccurredAt,
+		"next_attempt_at": meta.OccurredAt,
+	}
+	if meta.ActorID != "" {
+		data["actor_id"] = meta.ActorID
+	}
+	if _, err := db.Insert("event_outbox", data); err != nil {
+		return err
+	}
+
+	// Relay now rather than on the next tick. Inside a transaction the row becomes
+	// visible on commit and is picked up by a later pass.
+	select {
+	case o.wake <- struct{}{}:
+	default:
+	}
+	return nil
+}
+
+func (o *outbox) start() {
+	go func() {
+		ticker := time.NewTicker(relayInterval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ticker.C:
+			case <-o.wake:
+			}
+			o.relay()
+		}
+	}()
+}
+
+// relay delivers due events, oldest first
+func (o *outbox) relay() {
+	if o.locker != nil {
+		unlock, acquired, err := o.locker.TryAdvisoryLock(context.Background(), relayLock)
+		if err != nil || !acquired {
+			return // another replica is relaying
+		}
+		defer unlock()
+	}
+
+	for {
+		var rows []outboxRow
+		err := o.db.From("event_outbox").Select("*").IsNull("processed_at").IsNull("failed_at").
+			Lte("next_attempt_at", time.Now().Format(time.RFC3339Nano)).
+			Order("seq", false).Limit(relayBatch).Execute(&rows)
+		if err != nil {
+			utils.GetLogger().Warn("Failed to load event outbox", map[string]interface{}{"error": err.Error()})
+			return
+		}
+
+		for _, row := range rows {
+			o.process(row)
+		}
+		if len(rows) < relayBatch {
+			return
+		}
+	}
+}
+
+// process runs the subscribers that have not handled an event yet and records the result
+func (o *outbox) process(row outboxRow) {
+	entry := row.entry()
+	meta := Meta{ID: entry.ID, Name: entry.Name, OccurredAt: entry.OccurredAt}
+	if entry.ActorID != nil {
+		meta.ActorID = *entry.ActorID
+	}
+
+	done := make(map[string]bool)
+	for _, name := range entry.CompletedSubscribers {
+		done[name] = true
+	}
+	failed := o.bus.dispatch(meta, nil, []byte(row.Payload), done)
+
+	completed := entry.CompletedSubscribers
+	for _, name := range o.bus.subscriberNames(meta.Name) {
+		if _, ok := failed[name]; !ok && !done[name] {
+			completed = append(completed, name)
+			done[name] = true
+		}
+	}
+	completedJSON, _ := json.Marshal(completed)
+
+	now := time.Now()
+	updates := map[string]interface{}{
+		"completed_subscribers": string(completedJSON),
+		"attempts":              entry.Attempts + 1,
+	}
+	if len(failed) == 0 {
+		updates["processed_at"] = now
+		updates["last_error"] = nil
+	} else {
+		messages := make([]string, 0, len(failed))
+		for name, err := range failed {
+			messages = append(messages, name+": "+err.Error())
+		}
+		sort.Strings(messages)
+		updates["last_error"] = strings.Join(messages, "; ")
+		if entry.Attempts+1 >= maxAttempts {
+			updates["failed_at"] = now
+		} else {
+			updates["next_attempt_at"] = now.Add(backoff(entry.Attempts + 1))
+		}
+	}
+
+	if _, err := o.db.Update("event_outbox", "id", entry.ID, updates); err != nil {
+		utils.GetLogger().Warn("Failed to update event outbox entry", map[string]interface{}{
+			"event_id": entry.ID,
+			"error":    err.Error(),
+		})
+	}
+}
+
+// OutboxEntries returns the latest stored events with the given status (all when empty)
+func (b *Bus) OutboxEntries(status string, limit int) ([]OutboxEntry, error) {
+	if b.outbox == nil {
+		return nil, ErrOutboxDisabled
+	}
+
+	query := b.db.From("event_outbox").Select("*")
+	switch status {
+	case OutboxPending:
+		query = query.IsNull("processed_at").IsNull("failed_at")
+	case OutboxProcessed:
+		query = query.Not("processed_at", "is", "null")
+	case OutboxFailed:
+		query = query.Not("failed_at", "is", "null")
+	}
+	if limit > 0 {
+		query = query.Limit(limit)
+	}
+
+	var rows []outboxRow
+	if err := query.Order("seq", true).Execute(&rows); err != nil {
+		return nil, err
+	}
+	entries := make([]OutboxEntry, len(rows))
+	for i, row := range rows {
+		entries[i] = row.entry()
+	}
+	return entries, nil
+}
+
+// RetryOutboxEntry delivers a failed event again to the subscribers that did not handle it
+func (b *Bus) RetryOutboxEntry(id string) error {
+	if b.outbox == nil {
+		return ErrOutboxDisabled
+	}
+
+	var rows []outboxRow
+	if err := b.db.From("event_outbox").Select("id").Eq("id", id).Limit(1).Execute(&rows); err != nil {
+		return err
+	}
+	if len(rows) == 0 {
+		return ErrEntryNotFound
+	}
+
+	_, err := b.db.Update("event_outbox", "id", id, map[string]interface{}{
+		"failed_at":       nil,
+		"processed_at":    nil,
+		"attempts":        0,
+		"next_attempt_at": time.Now(),
+	})
+	if err == nil {
+		select {
+		case b.outbox.wake <- struct{}{}:
+		default:
+		}
+	}
+	return err
+}
+
+// subscriberNames returns the names of the subscribers of an event
+func (b *Bus) subscriberNames(event string) []string {
+	b.mu.RLock()
+	defer b.mu.RUnlock()
+	names := make([]string, 0, len(b.subscribers[event]))
+	for _, s := range b.subscribers[event] {
+		names = append(names, s.name)
+	}
+	return names
+}
+
+// backoff returns the delay before retrying an event: 10s, 20s, 40s, ... up to 1h
+func backoff(attempt int) time.Duration {
+	delay := baseBackoff
+	for i := 1; i < attempt && delay < maxBackoff; i++ {
+		delay *= 2
+	}
+	return min(delay, maxBackoff)
+}
@@ -10,6 +10,8 @@ import (
 	"regexp"
 	"strings"
 	"time"
+
+	"github.com/ekf/one-on-one-backend/pkg/protocol"
 )
 
 // Client handles Exchange Web Services requests
@@ -19,32 +21,16 @@ type Client struct {
 	client *http.Client
 }
 
-// CalendarEvent represents a calendar event from Exchange
-type CalendarEvent struct {
-	ID          string     `json:"id"`
-	Subject     string     `json:"subject"`
-	Start       string     `json:"start"`
-	End         string     `json:"end"`
-	Location    string     `json:"location,omitempty"`
-	Organizer   *Person    `json:"organizer,omitempty"`
-	Attendees   []Attendee `json:"attendees,omitempty"`
-	IsRecurring bool       `json:"is_recurring"`
-	IsCancelled bool       `json:"is_cancelled"`
-}
-
-// Person represents a person (organizer)
-type Person struct {
-	Name  string `json:"name"`
-	Email string `json:"email"`
-}
-
-// Attendee represents a meeting attendee
-type Attendee struct {
-	Name     string `json:"name"`
-	Email    string `json:"email"`
-	Response string `json:"response,omitempty"`
-	Optional bool   `json:"optional"`
-}
+// Calendar and mail types, shared with the connector protocol so that results of either
+// path look the same
+type (
+	CalendarEvent = protocol.CalendarEvent
+	Person        = protocol.Person
+	Attendee      = protocol.Attendee
+	EmailMessage  = protocol.EmailMessage
+	MailFolder    = protocol.MailFolder
+	Attachment    = protocol.Attachment
+)
 
 // BusyTime represents a busy time slot
 type BusyTime struct {
@@ -53,42 +39,6 @@ type BusyTime struct {
 	Status string `json:"status"`
 }
 
-// EmailMessage represents an email from Exchange
-type EmailMessage struct {
-	ID             string   `json:"id"`
-	ChangeKey      string   `json:"change_key,omitempty"`
-	ConversationID string   `json:"conversation_id,omitempty"`
-	ItemClass      string   `json:"item_class,omitempty"`
-	Subject        string   `json:"subject"`
-	From           *Person  `json:"from,omitempty"`
-	To             []Person `json:"to,omitempty"`
-	CC             []Person `json:"cc,omitempty"`
-	Body           string   `json:"body"`
-	BodyPreview    string   `json:"body_preview,omitempty"`
-	ReceivedAt     string   `json:"received_at"`
-	IsRead         bool     `json:"is_read"`
-	HasAttach      bool     `json:"has_attachments"`
-	FolderID       string   `json:"folder_id,omitempty"`
-}
-
-// MailFolder represents a mail folder
-type MailFolder struct {
-	ID          string `json:"id"`
-	DisplayName string `json:"display_name"`
-	UnreadCount int    `json:"unread_count"`
-	TotalCount  int    `json:"total_count"`
-}
-
-// Attachment represents an email attachment
-type Attachment struct {
-	ID          string `json:"id"`
-	Name        string `json:"name"`
-	ContentType string `json:"content_type"`
-	Size        int    `json:"size"`
-	IsInline    bool   `json:"is_inline"`
-	ContentID   string `json:"content_id,omitempty"`
-}
-
 // NewClient creates a new EWS client
 // skipTLSVerify should only be true for development/internal certificates
 func NewClient(url, domain string, skipTLSVerify bool) *Client {
@@ -1204,16 +1154,7 @@ func (c *Client) parseRoomsResponse(xml string) []MeetingRoom {
 }
 
 // CreateMeetingRequest represents a request to create a meeting
-type CreateMeetingRequest struct {
-	Subject           string   `json:"subject"`
-	Body              string   `json:"body,omitempty"`
-	Start             string   `json:"start"` // ISO 8601 format
-	End               string   `json:"end"`   // ISO 8601 format
-	Location          string   `json:"location,omitempty"`
-	RequiredAttendees []string `json:"required_attendees,omitempty"` // Email addresses
-	OptionalAttendees []string `json:"optional_attendees,omitempty"` // Email addresses
-	IsOnlineMeeting   bool     `json:"is_online_meeting,omitempty"`
-}
+type CreateMeetingRequest = protocol.CalendarItem
 
 // NewCreateMeetingRequest creates a new CreateMeetingRequest with the provided data
 func (c *Client) NewCreateMeetingRequest(subject, body, start, end, location string, requiredAttendees, optionalAttendees []string, isOnlineMeeting bool) CreateMeetingRequest {
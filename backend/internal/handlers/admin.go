@@ -18,24 +18,6 @@ type AdminStats struct {
 	DepartmentsCount int `json:"departments_count"`
 }
 
-// AuditLog represents an audit log entry
-type AuditLog struct {
-	ID         string                 `json:"id"`
-	UserID     *string                `json:"user_id"`
-	Action     string                 `json:"action"`
-	EntityType *string                `json:"entity_type"`
-	EntityID   *string                `json:"entity_id"`
-	OldValue   map[string]interface{} `json:"old_value"`
-	NewValue   map[string]interface{} `json:"new_value"`
-	IPAddress  *string                `json:"ip_address"`
-	UserAgent  *string                `json:"user_agent"`
-	CreatedAt  time.Time              `json:"created_at"`
-	User       *struct {
-		ID   string `json:"id"`
-		Name string `json:"name"`
-	} `json:"user,omitempty"`
-}
-
 // SystemSetting represents a system setting
 type SystemSetting struct {
 	ID          string      `json:"id"`
@@ -245,35 +227,6 @@ func (h *Handler) UpdateSystemSetting(c *fiber.Ctx) error {
 	return c.JSON(fiber.Map{"success": true})
 }
 
-// GetAuditLogs returns audit logs with pagination
-func (h *Handler) GetAuditLogs(c *fiber.Ctx) error {
-	if h.DB == nil {
-		return c.Status(500).JSON(fiber.Map{"error": "Database not configured"})
-	}
-
-	limit := c.QueryInt("limit", 50)
-	offset := c.QueryInt("offset", 0)
-	action := c.Query("action")
-	entityType := c.Query("entity_type")
-
-	query := h.DB.From("audit_logs").Select("*, user:employees(id, name)")
-
-	if action != "" {
-		query = query.Ilike("action", action+"%")
-	}
-	if entityType != "" {
-		query = query.Eq("entity_type", entityType)
-	}
-
-	var logs []AuditLog
-	err := query.Order("created_at", true).Limit(limit).Offset(offset).Execute(&logs)
-	if err != nil {
-		return c.Status(500).JSON(fiber.Map{"error": err.Error()})
-	}
-
-	return c.JSON(logs)
-}
-
 // GetDepartments returns unique departments list
 func (h *Handler) GetDepartments(c *fiber.Ctx) error {
 	if h.DB == nil {
@@ -330,27 +283,6 @@ func (h *Handler) GetDepartments(c *fiber.Ctx) error {
 	return c.JSON(result)
 }
 
-// createAuditLog helper to create audit log entry
-func (h *Handler) createAuditLog(c *fiber.Ctx, userID, action, entityType, entityID string, oldValue, newValue map[string]interface{}) {
-	if h.DB == nil {
-		return
-	}
-
-	ipAddress := c.IP()
-	userAgent := c.Get("User-Agent")
-
-	h.DB.Insert("audit_logs", map[string]interface{}{
-		"user_id":     userID,
-		"action":      action,
-		"entity_type": entityType,
-		"entity_id":   entityID,
-		"old_value":   oldValue,
-		"new_value":   newValue,
-		"ip_address":  ipAddress,
-		"user_agent":  userAgent,
-	})
-}
-
 // GetCurrentUserRole returns the role of the current user
 func (h *Handler) GetCurrentUserRole(c *fiber.Ctx) error {
 	if h.DB == nil {
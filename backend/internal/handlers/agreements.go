@@ -0,0 +1,352 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/ekf/one-on-one-backend/internal/events"
+	"github.com/ekf/one-on-one-backend/internal/models"
+	"github.com/gofiber/fiber/v2"
+)
+
+// Agreement statuses (see STATE_MACHINES.md)
+const (
+	agreementPending    = "pending"
+	agreementInProgress = "in_progress"
+	agreementDone       = "done"
+	agreementDropped    = "dropped"
+)
+
+// agreementTransitions lists allowed status changes
+var agreementTransitions = map[string][]string{
+	agreementPending:    {agreementInProgress, agreementDone, agreementDropped},
+	agreementInProgress: {agreementPending, agreementDone, agreementDropped},
+	agreementDone:       {agreementPending, agreementInProgress}, // reopened with its task
+	agreementDropped:    {agreementPending},
+}
+
+// openAgreementStatuses are statuses of agreements that still need follow-up
+var openAgreementStatuses = []string{agreementPending, agreementInProgress}
+
+func canTransitionAgreement(from, to string) bool {
+	for _, s := range agreementTransitions[from] {
+		if s == to {
+			return true
+		}
+	}
+	return false
+}
+
+// agreementStatusForTask maps a task status to the agreement status
+func agreementStatusForTask(taskStatus string) string {
+	switch taskStatus {
+	case "in_progress", "review":
+		return agreementInProgress
+	case "done":
+		return agreementDone
+	default:
+		return agreementPending
+	}
+}
+
+// taskStatusForAgreement maps an agreement status to the task status ("" - leave task unchanged)
+func taskStatusForAgreement(status string) string {
+	switch status {
+	case agreementPending:
+		return "todo"
+	case agreementInProgress:
+		return "in_progress"
+	case agreementDone:
+		return "done"
+	default:
+		return ""
+	}
+}
+
+// ListAgreements returns agreements filtered by employee, meeting or status.
+// open=true returns pending and in_progress agreements.
+func (h *Handler) ListAgreements(c *fiber.Ctx) error {
+	if h.DB == nil {
+		return c.Status(500).JSON(fiber.Map{"error": "Database not configured"})
+	}
+
+	query := h.DB.From("agreements").Select("*, meetings(id, title, date, employee_id)")
+
+	if employeeID := c.Query("employee_id"); employeeID != "" {
+		var meetings []models.Meeting
+		h.DB.From("meetings").Select("id").Eq("employee_id", employeeID).Execute(&meetings)
+		if len(meetings) == 0 {
+			return c.JSON([]models.Agreement{})
+		}
+		ids := make([]string, 0, len(meetings))
+		for _, m := range meetings {
+			ids = append(ids, m.ID)
+		}
+		query = query.In("meeting_id", ids)
+	}
+	if meetingID := c.Query("meeting_id"); meetingID != "" {
+		query = query.Eq("meeting_id", meetingID)
+	}
+	if status := c.Query("status"); status != "" {
+		query = query.Eq("status", status)
+	} else if c.QueryBool("open") {
+		query = query.In("status", openAgreementStatuses)
+	}
+
+	var agreements []models.Agreement
+	if err := query.Order("deadline", false).Execute(&agreements); err != nil {
+		return c.Status(500).JSON(fiber.Map{"error": err.Error()})
+	}
+	if agreements == nil {
+		agreements = []models.Agreement{}
+	}
+
+	return c.JSON(agreements)
+}
+
+// UpdateAgreementStatus moves an agreement through its lifecycle and mirrors the change to its task
+func (h *Handler) UpdateAgreementStatus(c *fiber.Ctx) error {
+	if h.DB == nil {
+		return c.Status(500).JSON(fiber.Map{"error": "Database not configured"})
+	}
+
+	var req struct {
+		Status string `json:"status"`
+	}
+	if err := c.BodyParser(&req); err != nil {
+		return c.Status(400).JSON(fiber.Map{"error": "Invalid request body"})
+	}
+
+	var agreement models.Agreement
+	if err := h.DB.From("agreements").Select("*").Eq("id", c.Params("id")).Single().Execute(&agreement); err != nil {
+		return c.Status(404).JSON(fiber.Map{"error": "Agreement not found"})
+	}
+
+	if err := validateAgreementTransition(agreement.Status, req.Status); err != nil {
+		return c.Status(400).JSON(fiber.Map{"error": err.Error()})
+	}
+	userID, _ := c.Locals("user_id").(string)
+	err := h.inTx(c.UserContext(), func(tx *Handler) error {
+		return tx.setAgreementStatus(&agreement, req.Status, "", userID, true)
+	})
+	if err != nil {
+		return c.Status(500).JSON(fiber.Map{"error": err.Error()})
+	}
+
+	return c.JSON(agreement)
+}
+
+// validateAgreementTransition reports why an agreement cannot move from one status to another
+func validateAgreementTransition(from, to string) error {
+	if from == to {
+		return nil
+	}
+	if _, ok := agreementTransitions[to]; !ok {
+		return fmt.Errorf("invalid status: %s (use pending, in_progress, done or dropped)", to)
+	}
+	if !canTransitionAgreement(from, to) {
+		return fmt.Errorf("transition %s -> %s is not allowed", from, to)
+	}
+	return nil
+}
+
+// setAgreementStatus validates and applies a transition. completedInMeetingID is set when a
+// later meeting confirmed the agreement; syncTask mirrors the status to the linked task on
+// behalf of actorID. Call it inside inTx, so the agreement and its task change together.
+func (h *Handler) setAgreementStatus(agreement *models.Agreement, status, completedInMeetingID, actorID string, syncTask bool) error {
+	if agreement.Status == status {
+		return nil
+	}
+	if err := validateAgreementTransition(agreement.Status, status); err != nil {
+		return err
+	}
+
+	now := time.Now()
+	updates := map[string]interface{}{
+		"status":            status,
+		"status_changed_at": now,
+	}
+	if status == agreementDone {
+		updates["completed_at"] = now
+		updates["completed_in_meeting_id"] = nilIfEmpty(completedInMeetingID)
+	} else {
+		updates["completed_at"] = nil
+		updates["completed_in_meeting_id"] = nil
+	}
+
+	if _, err := h.DB.Update("agreements", "id", agreement.ID, updates); err != nil {
+		return err
+	}
+	if syncTask && agreement.TaskID != nil {
+		if err := h.syncTaskFromAgreement(*agreement.TaskID, status, actorID); err != nil {
+			return fmt.Errorf("sync task %s: %w", *agreement.TaskID, err)
+		}
+	}
+
+	agreement.Status = status
+	agreement.StatusChangedAt = &now
+	if status == agreementDone {
+		agreement.CompletedAt = &now
+		if completedInMeetingID != "" {
+			agreement.CompletedInMeetingID = &completedInMeetingID
+		}
+	} else {
+		agreement.CompletedAt = nil
+		agreement.CompletedInMeetingID = nil
+	}
+	return nil
+}
+
+// syncTaskFromAgreement mirrors an agreement status to its task like a status change made
+// by actorID: with the task history and task events. Runs in the transaction of
+// setAgreementStatus.
+func (h *Handler) syncTaskFromAgreement(taskID, status, actorID string) error {
+	taskStatus := taskStatusForAgreement(status)
+	if taskStatus == "" {
+		return nil
+	}
+
+	var task models.Task
+	if err := h.DB.From("tasks").Select("id, status").Eq("id", taskID).Single().Execute(&task); err != nil {
+		return err
+	}
+	// Review is still in progress from the agreement point of view
+	if task.Status == taskStatus || agreementStatusForTask(task.Status) == status {
+		return nil
+	}
+
+	updates := map[string]interface{}{"status": taskStatus}
+	if taskStatus == "done" {
+		updates["completed_at"] = time.Now().Format(time.RFC3339)
+	}
+	result, err := h.DB.Update("tasks", "id", taskID, updates)
+	if err != nil {
+		return err
+	}
+	var updated []models.Task
+	json.Unmarshal(result, &updated)
+
+	changes := []events.FieldChange{{Field: "status", Old: task.Status, New: taskStatus}}
+	if err := h.recordTaskHistory(taskID, changes); err != nil {
+		return err
+	}
+	if err := h.publish(actorID, events.TaskUpdated{TaskID: taskID, Changes: changes}); err != nil {
+		return err
+	}
+	return h.publishTaskStatusChanged(actorID, taskID, task.Status, taskStatus, updated)
+}
+
+// syncAgreementFromTask mirrors a task status change to agreements generated from the task.
+// Dropped agreements are not revived by task changes.
+func (h *Handler) syncAgreementFromTask(ctx context.Context, taskID, taskStatus string) error {
+	if h.DB == nil {
+		return nil
+	}
+
+	var agreements []models.Agreement
+	if err := h.DB.From("agreements").Select("*").Eq("task_id", taskID).Execute(&agreements); err != nil {
+		return err
+	}
+
+	status := agreementStatusForTask(taskStatus)
+	return h.inTx(ctx, func(tx *Handler) error {
+		for i := range agreements {
+			if agreements[i].Status == agreementDropped || !canTransitionAgreement(agreements[i].Status, status) {
+				continue
+			}
+			if err := tx.setAgreementStatus(&agreements[i], status, "", "", false); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+// openAgreements returns unfinished agreements from meetings with the employee
+// up to beforeDate, excluding excludeMeetingID
+func (h *Handler) openAgreements(employeeID, beforeDate, excludeMeetingID string) []models.Agreement {
+	query := h.DB.From("meetings").Select("id").Eq("employee_id", employeeID)
+	if beforeDate != "" {
+		query = query.Lte("date", beforeDate)
+	}
+	var meetings []models.Meeting
+	query.Execute(&meetings)
+
+	ids := make([]string, 0, len(meetings))
+	for _, m := range meetings {
+		if m.ID != excludeMeetingID {
+			ids = append(ids, m.ID)
+		}
+	}
+	if len(ids) == 0 {
+		return nil
+	}
+
+	var agreements []models.Agreement
+	h.DB.From("agreements").Select("*, meetings(id, date)").
+		In("meeting_id", ids).In("status", openAgreementStatuses).
+		Order("deadline", false).Execute(&agreements)
+	return agreements
+}
+
+// formatOpenAgreements renders open agreements for the analysis prompt with their IDs,
+// so the model can report which of them were fulfilled
+func formatOpenAgreements(agreements []models.Agreement) string {
+	today := time.Now().Format("2006-01-02")
+
+	var lines []string
+	for _, a := range agreements {
+		line := "- [id: " + a.ID + "] " + a.Task
+		if a.Responsible != "" {
+			line += " (отв.: " + a.Responsible + ")"
+		}
+		if a.Deadline != nil {
+			deadline := (*a.Deadline)[:min(10, len(*a.Deadline))]
+			line += ", срок " + deadline
+			if deadline < today {
+				line += " — ПРОСРОЧЕНО"
+			}
+		}
+		if a.Status == agreementInProgress {
+			line += ", в работе"
+		}
+		if a.Meeting != nil {
+			line += ", со встречи " + a.Meeting.Date[:min(10, len(a.Meeting.Date))]
+		}
+		lines = append(lines, line)
+	}
+	return strings.Join(lines, "\n")
+}
+
+// applyCompletedAgreements marks earlier agreements reported as fulfilled by the analysis
+// of meetingID on behalf of actorID. Only open agreements of the same employee are accepted.
+func (h *Handler) applyCompletedAgreements(employeeID, meetingID, actorID string, analysis map[string]interface{}) error {
+	items, ok := analysis["completed_agreements"].([]interface{})
+	if !ok || employeeID == "" {
+		return nil
+	}
+
+	open := make(map[string]models.Agreement)
+	for _, a := range h.openAgreements(employeeID, "", meetingID) {
+		open[a.ID] = a
+	}
+
+	for _, item := range items {
+		entry, ok := item.(map[string]interface{})
+		if !ok {
+			continue
+		}
+		id, _ := entry["id"].(string)
+		agreement, ok := open[id]
+		if !ok {
+			continue
+		}
+		if err := h.setAgreementStatus(&agreement, agreementDone, meetingID, actorID, true); err != nil {
+			return fmt.Errorf("agreement %s: %w", id, err)
+		}
+	}
+	return nil
+}
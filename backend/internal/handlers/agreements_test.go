@@ -0,0 +1,90 @@
+package handlers
+
+import "testing"
+
+func TestCanTransitionAgreement(t *testing.T) {
+	tests := []struct {
+		from, to string
+		want     bool
+	}{
+		{agreementPending, agreementInProgress, true},
+		{agreementPending, agreementDone, true},
+		{agreementPending, agreementDropped, true},
+		{agreementPending, agreementPending, false},
+		{agreementInProgress, agreementPending, true},
+		{agreementInProgress, agreementDone, true},
+		{agreementInProgress, agreementDropped, true},
+		{agreementDone, agreementPending, true},
+		{agreementDone, agreementInProgress, true},
+		{agreementDone, agreementDropped, false},
+		{agreementDropped, agreementPending, true},
+		{agreementDropped, agreementInProgress, false},
+		{agreementDropped, agreementDone, false},
+		{"unknown", agreementPending, false},
+		{agreementPending, "unknown", false},
+	}
+	for _, tt := range tests {
+		if got := canTransitionAgreement(tt.from, tt.to); got != tt.want {
+			t.Errorf("canTransitionAgreement(%q, %q) = %v, want %v", tt.from, tt.to, got, tt.want)
+		}
+	}
+}
+
+func TestAgreementTaskStatusMapping(t *testing.T) {
+	tests := []struct {
+		taskStatus string
+		want       string
+	}{
+		{"backlog", agreementPending},
+		{"todo", agreementPending},
+		{"in_progress", agreementInProgress},
+		{"review", agreementInProgress},
+		{"done", agreementDone},
+	}
+	for _, tt := range tests {
+		if got := agreementStatusForTask(tt.taskStatus); got != tt.want {
+			t.Errorf("agreementStatusForTask(%q) = %q, want %q", tt.taskStatus, got, tt.want)
+		}
+	}
+
+	// Every status set on a task from its agreement maps back to that agreement status
+	for _, status := range []string{agreementPending, agreementInProgress, agreementDone} {
+		if got := agreementStatusForTask(taskStatusForAgreement(status)); got != status {
+			t.Errorf("agreementStatusForTask(taskStatusForAgreement(%q)) = %q", status, got)
+		}
+	}
+	if got := taskStatusForAgreement(agreementDropped); got != "" {
+		t.Errorf("taskStatusForAgreement(dropped) = %q, want the task left unchanged", got)
+	}
+}
+
+// A task moving between any two statuses must not strand its agreement: the agreement
+// either follows the task or already has the matching status
+func TestAgreementFollowsTaskMoves(t *testing.T) {
+	taskStatuses := []string{"backlog", "todo", "in_progress", "review", "done"}
+	for _, from := range taskStatuses {
+		for _, to := range taskStatuses {
+			current, next := agreementStatusForTask(from), agreementStatusForTask(to)
+			if current != next && !canTransitionAgreement(current, next) {
+				t.Errorf("task %s -> %s: agreement cannot move %s -> %s", from, to, current, next)
+			}
+		}
+	}
+}
+
+func TestValidateAgreementTransition(t *testing.T) {
+	tests := []struct {
+		from, to string
+		wantErr  bool
+	}{
+		{agreementPending, agreementPending, false},
+		{agreementPending, agreementDone, false},
+		{agreementDropped, agreementDone, true},
+		{agreementPending, "closed", true},
+	}
+	for _, tt := range tests {
+		if err := validateAgreementTransition(tt.from, tt.to); (err != nil) != tt.wantErr {
+			t.Errorf("validateAgreementTransition(%q, %q) = %v, want error %v", tt.from, tt.to, err, tt.wantErr)
+		}
+	}
+}
@@ -1,165 +1,180 @@
 package handlers
 
 import (
+	"encoding/json"
 	"time"
 
+	"github.com/ekf/one-on-one-backend/internal/database"
 	"github.com/ekf/one-on-one-backend/internal/models"
 	"github.com/gofiber/fiber/v2"
 )
 
-// GetDashboard returns dashboard data
+// GetDashboard returns dashboard data.
+// With manager_id every query is limited to the manager's whole subtree by a recursive
+// CTE, and counters come from grouped aggregates, so the number of queries does not
+// depend on the size of the team.
 func (h *Handler) GetDashboard(c *fiber.Ctx) error {
 	if h.DB == nil {
 		return c.Status(500).JSON(fiber.Map{"error": "Database not configured"})
 	}
 
+	// Queries stop once the request deadline passes
+	ctx := c.UserContext()
+	db := h.DB.WithContext(ctx)
+
 	period := c.Query("period", "month")
 	startDate := getPeriodStart(period)
 	managerID := c.Query("manager_id")
 
-	// Get employees - filter by manager if specified
-	var employees []models.Employee
-	if managerID != "" {
-		// Get all subordinates recursively
-		employees = h.getSubordinatesRecursive(managerID)
-	} else {
-		h.DB.From("employees").Select("*").Execute(&employees)
+	// scope limits a query to rows whose column references the manager's subordinates
+	scope := func(q database.QueryBuilder, column string) database.QueryBuilder {
+		if managerID == "" {
+			return q
+		}
+		return q.InHierarchy(column, "employees", "manager_id", managerID)
 	}
 
+	// Get employees - all subordinates if manager specified
+	var employees []models.Employee
+	scope(db.From("employees").Select("*"), "id").Execute(&employees)
+
 	// Get active projects
 	var projects []models.Project
-	h.DB.From("projects").Select("*").Eq("status", "active").Execute(&projects)
+	db.From("projects").Select("*").Eq("status", "active").Execute(&projects)
 
-	// Get recent meetings - filter by employees if manager specified
+	// Get recent meetings
 	var meetings []models.Meeting
-	meetingQuery := h.DB.From("meetings").Select("*, employees(name), meeting_categories(name)")
-	if managerID != "" && len(employees) > 0 {
-		meetingQuery = meetingQuery.In("employee_id", getEmployeeIDs(employees))
-	}
-	meetingQuery.Order("date", true).Limit(50).Execute(&meetings)
+	scope(db.From("meetings").Select("id, title, employee_id, project_id, category_id, date, start_time, end_time, location, summary, mood_score, created_at, employees(name), meeting_categories(code, name)"), "employee_id").
+		Order("date", true).Limit(50).Execute(&meetings)
 
-	// Get task stats - filter by employees if manager specified
-	var tasks []struct {
-		Status  string  `json:"status"`
-		DueDate *string `json:"due_date"`
+	// Meeting counters per day of the period
+	var meetingDays []struct {
+		Date      string  `json:"date"`
+		Count     int     `json:"count"`
+		MoodCount int     `json:"mood_count"`
+		MoodSum   float64 `json:"mood_sum"`
 	}
-	taskQuery := h.DB.From("tasks").Select("status, due_date")
-	if managerID != "" && len(employees) > 0 {
-		taskQuery = taskQuery.In("assignee_id", getEmployeeIDs(employees))
+	dayQuery := scope(db.From("meetings").Select("date, count(), mood_count:mood_score.count(), mood_sum:mood_score.sum()"), "employee_id")
+	if startDate != "" {
+		dayQuery = dayQuery.Gte("date", startDate)
 	}
-	taskQuery.Execute(&tasks)
-
-	// Get agreement stats
-	var agreements []struct {
-		Status   string  `json:"status"`
-		Deadline *string `json:"deadline"`
-	}
-	h.DB.From("agreements").Select("status, deadline").Execute(&agreements)
-
-	today := time.Now().Format("2006-01-02")
-	totalTasks := len(tasks)
-	doneTasks := 0
-	inProgressTasks := 0
-	overdueTasks := 0
+	dayQuery.Order("date", false).Execute(&meetingDays)
 
-	for _, t := range tasks {
-		if t.DueDate != nil && startDate != "" && *t.DueDate < startDate {
-			continue
-		}
-		switch t.Status {
-		case "done":
-			doneTasks++
-		case "in_progress":
-			inProgressTasks++
+	startOfMonth := time.Now().Format("2006-01") + "-01"
+	var meetingsThisMonth, moodCount int
+	var moodSum float64
+	moodTrend := []fiber.Map{}
+	for _, d := range meetingDays {
+		date := d.Date[:min(10, len(d.Date))]
+		if date >= startOfMonth {
+			meetingsThisMonth += d.Count
 		}
-		if t.DueDate != nil && *t.DueDate < today && t.Status != "done" {
-			overdueTasks++
+		if d.MoodCount > 0 {
+			moodSum += d.MoodSum
+			moodCount += d.MoodCount
+			moodTrend = append(moodTrend, fiber.Map{
+				"date":  date,
+				"score": d.MoodSum / float64(d.MoodCount),
+			})
 		}
 	}
+	var avgMood float64
+	if moodCount > 0 {
+		avgMood = moodSum / float64(moodCount)
+	}
 
-	// Find red flags
-	var redFlags []fiber.Map
-	for _, m := range meetings {
-		if startDate != "" && m.Date < startDate {
-			continue
-		}
-		if m.Analysis != nil {
-			if flags, ok := m.Analysis["red_flags"].(map[string]interface{}); ok {
-				burnout, _ := flags["burnout_signs"].(string)
-				turnover, _ := flags["turnover_risk"].(string)
-				if burnout != "" || turnover == "medium" || turnover == "high" {
-					employeeName := ""
-					if m.Employee != nil {
-						employeeName = m.Employee.Name
-					}
-					redFlags = append(redFlags, fiber.Map{
-						"employee": employeeName,
-						"date":     m.Date,
-						"flags":    flags,
-					})
-				}
-			}
-		}
+	// Meetings by category
+	var categoryCounts []struct {
+		Count    int `json:"count"`
+		Category *struct {
+			Code string `json:"code"`
+		} `json:"meeting_categories"`
 	}
+	categoryQuery := scope(db.From("meetings").Select("category_id, count(), meeting_categories(code)"), "employee_id")
+	if startDate != "" {
+		categoryQuery = categoryQuery.Gte("date", startDate)
+	}
+	categoryQuery.Execute(&categoryCounts)
 
-	// Calculate average mood
-	var avgMood float64
-	var moodCount int
-	var moodTrend []fiber.Map
-	for _, m := range meetings {
-		if startDate != "" && m.Date < startDate {
-			continue
-		}
-		if m.MoodScore != nil {
-			avgMood += float64(*m.MoodScore)
-			moodCount++
-			moodTrend = append(moodTrend, fiber.Map{
-				"date":  m.Date,
-				"score": float64(*m.MoodScore),
-			})
+	meetingsByCategory := make(map[string]int)
+	for _, cc := range categoryCounts {
+		if cc.Category != nil {
+			meetingsByCategory[cc.Category.Code] += cc.Count
 		}
 	}
-	if moodCount > 0 {
-		avgMood /= float64(moodCount)
+
+	// Find red flags - only the red_flags part of the analysis is loaded
+	var flagged []struct {
+		Date     string  `json:"date"`
+		RedFlags *string `json:"red_flags"`
+		Employee *struct {
+			Name string `json:"name"`
+		} `json:"employees"`
 	}
+	flagQuery := scope(db.From("meetings").Select("date, employee_id, analysis->'red_flags' AS red_flags, employees(name)"), "employee_id")
+	if startDate != "" {
+		flagQuery = flagQuery.Gte("date", startDate)
+	}
+	flagQuery.Order("date", true).Execute(&flagged)
 
-	// Get meetings this month
-	startOfMonth := time.Now().Format("2006-01") + "-01"
-	var meetingsThisMonth int
-	for _, m := range meetings {
-		if startDate != "" && m.Date < startDate {
+	redFlags := []fiber.Map{}
+	for _, m := range flagged {
+		if m.RedFlags == nil {
+			continue
+		}
+		var flags map[string]interface{}
+		if json.Unmarshal([]byte(*m.RedFlags), &flags) != nil {
 			continue
 		}
-		if m.Date >= startOfMonth {
-			meetingsThisMonth++
+		burnout, _ := flags["burnout_signs"].(string)
+		turnover, _ := flags["turnover_risk"].(string)
+		if burnout != "" || turnover == "medium" || turnover == "high" {
+			employeeName := ""
+			if m.Employee != nil {
+				employeeName = m.Employee.Name
+			}
+			redFlags = append(redFlags, fiber.Map{
+				"employee": employeeName,
+				"date":     m.Date[:min(10, len(m.Date))],
+				"flags":    flags,
+			})
 		}
 	}
 
-	// Meetings by category
-	meetingsByCategory := make(map[string]int)
-	for _, m := range meetings {
-		if startDate != "" && m.Date < startDate {
-			continue
-		}
-		if m.Category != nil {
-			meetingsByCategory[m.Category.Code]++
+	today := time.Now().Format("2006-01-02")
+
+	// Task stats
+	tasks, overdueByStatus := periodStatusCounts(func() database.QueryBuilder {
+		return scope(db.From("tasks").Select("status, count()"), "assignee_id")
+	}, "due_date", startDate, today)
+
+	totalTasks := 0
+	for _, n := range tasks {
+		totalTasks += n
+	}
+	doneTasks := tasks["done"]
+	inProgressTasks := tasks["in_progress"]
+	overdueTasks := 0
+	for status, n := range overdueByStatus {
+		if status != "done" {
+			overdueTasks += n
 		}
 	}
 
+	// Agreement stats
+	agreements, overdueAgreementsByStatus := periodStatusCounts(func() database.QueryBuilder {
+		return db.From("agreements").Select("status, count()")
+	}, "deadline", startDate, today)
+
 	totalAgreements := 0
-	completedAgreements := 0
+	for _, n := range agreements {
+		totalAgreements += n
+	}
+	completedAgreements := agreements["completed"] + agreements["done"]
 	overdueAgreements := 0
-	for _, a := range agreements {
-		if a.Deadline != nil && startDate != "" && *a.Deadline < startDate {
-			continue
-		}
-		totalAgreements++
-		if a.Status == "completed" || a.Status == "done" {
-			completedAgreements++
-			continue
-		}
-		if a.Deadline != nil && *a.Deadline < today {
-			overdueAgreements++
+	for status, n := range overdueAgreementsByStatus {
+		if status != "completed" && status != "done" {
+			overdueAgreements += n
 		}
 	}
 
@@ -167,12 +182,8 @@ func (h *Handler) GetDashboard(c *fiber.Ctx) error {
 	var employeesNeedingAttention []fiber.Map
 	// TODO: calculate based on last meeting date
 
-	// Ensure arrays are not nil
-	if redFlags == nil {
-		redFlags = []fiber.Map{}
-	}
-	if moodTrend == nil {
-		moodTrend = []fiber.Map{}
+	if ctx.Err() != nil {
+		return c.Status(503).JSON(fiber.Map{"error": "Request timed out"})
 	}
 
 	return c.JSON(fiber.Map{
@@ -201,12 +212,46 @@ func (h *Handler) GetDashboard(c *fiber.Ctx) error {
 	})
 }
 
+// periodStatusCounts counts rows per status for a dashboard period. query must return a
+// fresh "status, count()" builder on every call. Rows whose dateColumn lies before the
+// period are left out, rows without a date are kept; overdue counts the rows of the
+// period whose date is before today.
+func periodStatusCounts(query func() database.QueryBuilder, dateColumn, startDate, today string) (map[string]int, map[string]int) {
+	counts := statusCounts(query())
+	overdueQuery := query().Lt(dateColumn, today)
+	if startDate != "" {
+		for status, n := range statusCounts(query().Lt(dateColumn, startDate)) {
+			counts[status] -= n
+		}
+		overdueQuery = overdueQuery.Gte(dateColumn, startDate)
+	}
+	return counts, statusCounts(overdueQuery)
+}
+
+// statusCounts executes a "status, count()" query
+func statusCounts(query database.QueryBuilder) map[string]int {
+	var rows []struct {
+		Status string `json:"status"`
+		Count  int    `json:"count"`
+	}
+	query.Execute(&rows)
+
+	counts := make(map[string]int, len(rows))
+	for _, r := range rows {
+		counts[r.Status] += r.Count
+	}
+	return counts
+}
+
 // GetEmployeeAnalytics returns analytics for an employee
 func (h *Handler) GetEmployeeAnalytics(c *fiber.Ctx) error {
 	if h.DB == nil {
 		return c.Status(500).JSON(fiber.Map{"error": "Database not configured"})
 	}
 
+	ctx := c.UserContext()
+	db := h.DB.WithContext(ctx)
+
 	period := c.Query("period", "month")
 	startDate := getPeriodStart(period)
 
@@ -214,7 +259,7 @@ func (h *Handler) GetEmployeeAnalytics(c *fiber.Ctx) error {
 
 	// Get meetings
 	var meetings []models.Meeting
-	h.DB.From("meetings").Select("id, date, mood_score, analysis").Eq("employee_id", employeeID).Order("date", false).Execute(&meetings)
+	db.From("meetings").Select("id, date, mood_score, analysis").Eq("employee_id", employeeID).Order("date", false).Execute(&meetings)
 
 	filteredMeetingsCount := 0
 
@@ -258,7 +303,7 @@ func (h *Handler) GetEmployeeAnalytics(c *fiber.Ctx) error {
 		Status string  `json:"status"`
 		Due    *string `json:"due_date"`
 	}
-	h.DB.From("tasks").Select("status, due_date").Eq("assignee_id", employeeID).Execute(&tasks)
+	db.From("tasks").Select("status, due_date").Eq("assignee_id", employeeID).Execute(&tasks)
 
 	totalTasks := 0
 	doneTasks := 0
@@ -290,7 +335,7 @@ func (h *Handler) GetEmployeeAnalytics(c *fiber.Ctx) error {
 		Deadline *string `json:"deadline"`
 	}
 	if len(meetingIDs) > 0 {
-		h.DB.From("agreements").Select("status, deadline").In("meeting_id", meetingIDs).Execute(&agreements)
+		db.From("agreements").Select("status, deadline").In("meeting_id", meetingIDs).Execute(&agreements)
 	}
 
 	today := time.Now().Format("2006-01-02")
@@ -302,7 +347,7 @@ func (h *Handler) GetEmployeeAnalytics(c *fiber.Ctx) error {
 	for _, a := range agreements {
 		if a.Status == "completed" || a.Status == "done" {
 			completedAgreements++
-		} else if a.Status == "pending" {
+		} else if a.Status == "pending" || a.Status == "in_progress" {
 			pendingAgreements++
 			if a.Deadline != nil && *a.Deadline < today {
 				overdueAgreements++
@@ -318,6 +363,10 @@ func (h *Handler) GetEmployeeAnalytics(c *fiber.Ctx) error {
 		redFlagsHistory = []fiber.Map{}
 	}
 
+	if ctx.Err() != nil {
+		return c.Status(503).JSON(fiber.Map{"error": "Request timed out"})
+	}
+
 	return c.JSON(fiber.Map{
 		"mood_history":      moodHistory,
 		"red_flags_history": redFlagsHistory,
@@ -358,24 +407,6 @@ func getPeriodStart(period string) string {
 	}
 }
 
-// getSubordinatesRecursive gets all subordinates recursively for a manager
-func (h *Handler) getSubordinatesRecursive(managerID string) []models.Employee {
-	var result []models.Employee
-
-	// Get direct subordinates
-	var directSubordinates []models.Employee
-	h.DB.From("employees").Select("*").Eq("manager_id", managerID).Execute(&directSubordinates)
-
-	for _, sub := range directSubordinates {
-		result = append(result, sub)
-		// Recursively get their subordinates
-		subOfSub := h.getSubordinatesRecursive(sub.ID)
-		result = append(result, subOfSub...)
-	}
-
-	return result
-}
-
 // getEmployeeIDs extracts IDs from employee slice
 func getEmployeeIDs(employees []models.Employee) []string {
 	ids := make([]string, len(employees))
@@ -391,15 +422,18 @@ func (h *Handler) GetEmployeeAnalyticsByCategory(c *fiber.Ctx) error {
 		return c.Status(500).JSON(fiber.Map{"error": "Database not configured"})
 	}
 
+	ctx := c.UserContext()
+	db := h.DB.WithContext(ctx)
+
 	employeeID := c.Params("id")
 
 	// Get categories
 	var categories []models.MeetingCategory
-	h.DB.From("meeting_categories").Select("*").Execute(&categories)
+	db.From("meeting_categories").Select("*").Execute(&categories)
 
 	// Get meetings
 	var meetings []models.Meeting
-	h.DB.From("meetings").Select("*, meeting_categories(code, name)").Eq("employee_id", employeeID).Execute(&meetings)
+	db.From("meetings").Select("*, meeting_categories(code, name)").Eq("employee_id", employeeID).Execute(&meetings)
 
 	var result []fiber.Map
 	for _, cat := range categories {
@@ -447,6 +481,10 @@ func (h *Handler) GetEmployeeAnalyticsByCategory(c *fiber.Ctx) error {
 		})
 	}
 
+	if ctx.Err() != nil {
+		return c.Status(503).JSON(fiber.Map{"error": "Request timed out"})
+	}
+
 	return c.JSON(result)
 }
 
@@ -456,6 +494,9 @@ func (h *Handler) GetTeamStats(c *fiber.Ctx) error {
 		return c.Status(500).JSON(fiber.Map{"error": "Database not configured"})
 	}
 
+	ctx := c.UserContext()
+	db := h.DB.WithContext(ctx)
+
 	managerID := c.Params("id")
 	if managerID == "" {
 		return c.Status(400).JSON(fiber.Map{"error": "Manager ID required"})
@@ -463,52 +504,77 @@ func (h *Handler) GetTeamStats(c *fiber.Ctx) error {
 
 	// Get direct subordinates
 	var subordinates []models.Employee
-	h.DB.From("employees").Select("id, name, position, photo_base64").Eq("manager_id", managerID).Execute(&subordinates)
+	db.From("employees").Select("id, name, position, photo_base64").Eq("manager_id", managerID).Execute(&subordinates)
 
-	today := time.Now().Format("2006-01-02")
 	var result []fiber.Map
+	if len(subordinates) == 0 {
+		return c.JSON(result)
+	}
+	ids := getEmployeeIDs(subordinates)
+	today := time.Now().Format("2006-01-02")
+	startOfMonth := time.Now().Format("2006-01") + "-01"
 
-	for _, emp := range subordinates {
-		// Count subordinates of this employee
-		var subCount int
-		var subs []struct{ ID string }
-		h.DB.From("employees").Select("id").Eq("manager_id", emp.ID).Execute(&subs)
-		subCount = len(subs)
-
-		// Get task stats
-		var tasks []struct {
-			Status  string  `json:"status"`
-			DueDate *string `json:"due_date"`
+	// Count subordinates of each team member
+	var subCounts []struct {
+		ManagerID string `json:"manager_id"`
+		Count     int    `json:"count"`
+	}
+	db.From("employees").Select("manager_id, count()").In("manager_id", ids).Execute(&subCounts)
+
+	// Task counts per assignee and status, all and overdue
+	type taskCount struct {
+		AssigneeID string `json:"assignee_id"`
+		Status     string `json:"status"`
+		Count      int    `json:"count"`
+	}
+	var taskCounts, overdueCounts []taskCount
+	db.From("tasks").Select("assignee_id, status, count()").In("assignee_id", ids).Execute(&taskCounts)
+	db.From("tasks").Select("assignee_id, status, count()").In("assignee_id", ids).Lt("due_date", today).Execute(&overdueCounts)
+
+	// Meetings this month per employee
+	var meetingCounts []struct {
+		EmployeeID string `json:"employee_id"`
+		Count      int    `json:"count"`
+	}
+	db.From("meetings").Select("employee_id, count()").In("employee_id", ids).Gte("date", startOfMonth).Execute(&meetingCounts)
+
+	subordinateCount := make(map[string]int)
+	for _, sc := range subCounts {
+		subordinateCount[sc.ManagerID] = sc.Count
+	}
+	openTasks := make(map[string]int)
+	for _, tc := range taskCounts {
+		if tc.Status != "done" {
+			openTasks[tc.AssigneeID] += tc.Count
 		}
-		h.DB.From("tasks").Select("status, due_date").Eq("assignee_id", emp.ID).Execute(&tasks)
-
-		openTasks := 0
-		overdueTasks := 0
-		for _, t := range tasks {
-			if t.Status != "done" {
-				openTasks++
-				if t.DueDate != nil && *t.DueDate < today {
-					overdueTasks++
-				}
-			}
+	}
+	overdueTasks := make(map[string]int)
+	for _, tc := range overdueCounts {
+		if tc.Status != "done" {
+			overdueTasks[tc.AssigneeID] += tc.Count
 		}
+	}
+	meetingCount := make(map[string]int)
+	for _, mc := range meetingCounts {
+		meetingCount[mc.EmployeeID] = mc.Count
+	}
 
-		// Get meetings count (from meetings table for this month)
-		startOfMonth := time.Now().Format("2006-01") + "-01"
-		var meetings []struct{ ID string }
-		h.DB.From("meetings").Select("id").Eq("employee_id", emp.ID).Gte("date", startOfMonth).Execute(&meetings)
-
+	for _, emp := range subordinates {
 		result = append(result, fiber.Map{
 			"id":            emp.ID,
 			"name":          emp.Name,
 			"position":      emp.Position,
 			"photo_base64":  emp.PhotoBase64,
-			"subordinates":  subCount,
-			"open_tasks":    openTasks,
-			"overdue_tasks": overdueTasks,
-			"meetings":      len(meetings),
+			"subordinates":  subordinateCount[emp.ID],
+			"open_tasks":    openTasks[emp.ID],
+			"overdue_tasks": overdueTasks[emp.ID],
+			"meetings":      meetingCount[emp.ID],
 		})
 	}
 
+	if ctx.Err() != nil {
+		return c.Status(503).JSON(fiber.Map{"error": "Request timed out"})
+	}
+
 	return c.JSON(result)
 }
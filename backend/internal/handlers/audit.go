@@ -0,0 +1,310 @@
+package handlers
+
+import (
+	"bufio"
+	"context"
+	"encoding/csv"
+	"encoding/json"
+	"strconv"
+	"time"
+
+	"github.com/ekf/one-on-one-backend/internal/audit"
+	"github.com/ekf/one-on-one-backend/internal/database"
+	"github.com/ekf/one-on-one-backend/internal/utils"
+	"github.com/gofiber/fiber/v2"
+)
+
+const (
+	auditLogColumns     = audit.Columns + ", user:employees(id, name)"
+	auditExportBatch    = 1000
+	auditExportTimeout  = 5 * time.Minute
+	defaultAuditLogPage = 50
+)
+
+// AuditLog represents an audit log entry
+type AuditLog struct {
+	audit.Log
+	OldValue json.RawMessage `json:"old_value"`
+	NewValue json.RawMessage `json:"new_value"`
+	User     *auditLogUser   `json:"user,omitempty"`
+}
+
+type auditLogUser struct {
+	ID   string `json:"id"`
+	Name string `json:"name"`
+}
+
+// auditLogRow is an audit_logs row as loaded, with JSONB values as strings
+type auditLogRow struct {
+	audit.Log
+	User *auditLogUser `json:"user"`
+}
+
+func (r auditLogRow) toAuditLog() AuditLog {
+	log := AuditLog{Log: r.Log, User: r.User}
+	if r.OldValue != nil {
+		log.OldValue = json.RawMessage(*r.OldValue)
+	}
+	if r.NewValue != nil {
+		log.NewValue = json.RawMessage(*r.NewValue)
+	}
+	return log
+}
+
+// auditLogFilters reads the filters of the audit log endpoints: action (prefix), entity_type,
+// entity_id, user_id (actor), request_id and the created_at range from (inclusive) - to
+// (exclusive) as dates or RFC 3339 times. The returned function applies them to a query.
+func auditLogFilters(c *fiber.Ctx) (func(database.QueryBuilder) database.QueryBuilder, error) {
+	var from, to time.Time
+	for _, p := range []struct {
+		name string
+		t    *time.Time
+	}{{"from", &from}, {"to", &to}} {
+		value := c.Query(p.name)
+		if value == "" {
+			continue
+		}
+		t, err := time.Parse(time.RFC3339, value)
+		if err != nil {
+			if t, err = time.Parse("2006-01-02", value); err != nil {
+				return nil, fiber.NewError(fiber.StatusBadRequest, "Invalid "+p.name+" time")
+			}
+		}
+		*p.t = t
+	}
+
+	action := c.Query("action")
+	eq := map[string]string{
+		"entity_type": c.Query("entity_type"),
+		"entity_id":   c.Query("entity_id"),
+		"user_id":     c.Query("user_id"),
+		"request_id":  c.Query("request_id"),
+	}
+
+	return func(query database.QueryBuilder) database.QueryBuilder {
+		if action != "" {
+			query = query.Ilike("action", action+"%")
+		}
+		for column, value := range eq {
+			if value != "" {
+				query = query.Eq(column, value)
+			}
+		}
+		if !from.IsZero() {
+			query = query.Gte("created_at", from.UTC().Format(time.RFC3339Nano))
+		}
+		if !to.IsZero() {
+			query = query.Lt("created_at", to.UTC().Format(time.RFC3339Nano))
+		}
+		return query
+	}, nil
+}
+
+// GetAuditLogs returns audit logs, newest first, filtered by entity, actor and time range
+func (h *Handler) GetAuditLogs(c *fiber.Ctx) error {
+	if h.DB == nil {
+		return c.Status(500).JSON(fiber.Map{"error": "Database not configured"})
+	}
+
+	filter, err := auditLogFilters(c)
+	if err != nil {
+		return c.Status(400).JSON(fiber.Map{"error": err.Error()})
+	}
+
+	query, page, err := paginate(c, filter(h.DB.From("audit_logs").Select(auditLogColumns)), defaultAuditLogPage)
+	if err != nil {
+		return listError(c, err)
+	}
+	if offset := c.QueryInt("offset", 0); offset > 0 {
+		query = query.Offset(offset)
+	}
+
+	var rows []auditLogRow
+	if err := query.Execute(&rows); err != nil {
+		return listError(c, err)
+	}
+
+	logs := make([]AuditLog, len(rows))
+	for i, row := range rows {
+		logs[i] = row.toAuditLog()
+	}
+
+	page.setHeaders(c, len(logs), func() string {
+		last := logs[len(logs)-1]
+		return pageCursor(&last.CreatedAt, last.ID)
+	})
+	return c.JSON(logs)
+}
+
+// ExportAuditLogs streams the audit logs matching the filters of GetAuditLogs, oldest first,
+// as CSV (?format=csv, default) or a JSON array (?format=json)
+func (h *Handler) ExportAuditLogs(c *fiber.Ctx) error {
+	if h.DB == nil {
+		return c.Status(500).JSON(fiber.Map{"error": "Database not configured"})
+	}
+
+	filter, err := auditLogFilters(c)
+	if err != nil {
+		return c.Status(400).JSON(fiber.Map{"error": err.Error()})
+	}
+
+	format := c.Query("format", "csv")
+	if format != "csv" && format != "json" {
+		return c.Status(400).JSON(fiber.Map{"error": "format must be csv or json"})
+	}
+
+	filename := "audit-logs-" + time.Now().Format("2006-01-02") + "." + format
+	if format == "csv" {
+		c.Set(fiber.HeaderContentType, "text/csv; charset=utf-8")
+	} else {
+		c.Set(fiber.HeaderContentType, fiber.MIMEApplicationJSONCharsetUTF8)
+	}
+	c.Set(fiber.HeaderContentDisposition, `attachment; filename="`+filename+`"`)
+
+	// The body is written after the handler returns, so the request context is not used
+	db := h.DB
+	c.Context().SetBodyStreamWriter(func(w *bufio.Writer) {
+		ctx, cancel := context.WithTimeout(context.Background(), auditExportTimeout)
+		defer cancel()
+
+		write := writeAuditJSON
+		if format == "csv" {
+			write = writeAuditCSV
+		}
+		if err := write(w, func(yield func(AuditLog) error) error {
+			return eachAuditLog(ctx, db, filter, yield)
+		}); err != nil {
+			utils.GetLogger().Error("Audit log export failed", map[string]interface{}{
+				"error": err.Error(),
+			})
+		}
+	})
+	return nil
+}
+
+// eachAuditLog calls fn for every matching audit log in batches, oldest first
+func eachAuditLog(ctx context.Context, db database.DBClient, filter func(database.QueryBuilder) database.QueryBuilder, fn func(AuditLog) error) error {
+	db = db.WithContext(ctx)
+	cursor := ""
+	for {
+		var rows []auditLogRow
+		query := filter(db.From("audit_logs").Select(auditLogColumns)).
+			Keyset(cursor, false, "created_at", "id").Limit(auditExportBatch)
+		if err := query.Execute(&rows); err != nil {
+			return err
+		}
+
+		for _, row := range rows {
+			if err := fn(row.toAuditLog()); err != nil {
+				return err
+			}
+		}
+		if len(rows) < auditExportBatch {
+			return nil
+		}
+		last := rows[len(rows)-1]
+		cursor = pageCursor(&last.CreatedAt, last.ID)
+	}
+}
+
+func writeAuditJSON(w *bufio.Writer, each func(func(AuditLog) error) error) error {
+	w.WriteString("[")
+	first := true
+	err := each(func(log AuditLog) error {
+		if !first {
+			w.WriteString(",")
+		}
+		first = false
+		data, err := json.Marshal(log)
+		if err != nil {
+			return err
+		}
+		_, err = w.Write(data)
+		return err
+	})
+	w.WriteString("]")
+	if flushErr := w.Flush(); err == nil {
+		err = flushErr
+	}
+	return err
+}
+
+func writeAuditCSV(w *bufio.Writer, each func(func(AuditLog) error) error) error {
+	out := csv.NewWriter(w)
+	out.Write([]string{
+		"seq", "created_at", "user_id", "user_name", "action", "entity_type", "entity_id",
+		"old_value", "new_value", "ip_address", "user_agent", "request_id", "method", "path",
+		"status", "hash",
+	})
+	err := each(func(log AuditLog) error {
+		userName := ""
+		if log.User != nil {
+			userName = log.User.Name
+		}
+		seq, status := "", ""
+		if log.Seq != nil {
+			seq = strconv.FormatInt(*log.Seq, 10)
+		}
+		if log.Status != nil {
+			status = strconv.Itoa(*log.Status)
+		}
+		return out.Write([]string{
+			seq, log.CreatedAt.UTC().Format(time.RFC3339Nano), stringValue(log.UserID), userName,
+			log.Action, stringValue(log.EntityType), stringValue(log.EntityID),
+			string(log.OldValue), string(log.NewValue), stringValue(log.IPAddress),
+			stringValue(log.UserAgent), stringValue(log.RequestID), stringValue(log.Method),
+			stringValue(log.Path), status, stringValue(log.Hash),
+		})
+	})
+	out.Flush()
+	if err == nil {
+		err = out.Error()
+	}
+	if flushErr := w.Flush(); err == nil {
+		err = flushErr
+	}
+	return err
+}
+
+func stringValue(s *string) string {
+	if s == nil {
+		return ""
+	}
+	return *s
+}
+
+// VerifyAuditLogs checks that the audit log hash chain is intact
+func (h *Handler) VerifyAuditLogs(c *fiber.Ctx) error {
+	if h.Audit == nil {
+		return c.Status(500).JSON(fiber.Map{"error": "Database not configured"})
+	}
+
+	// The whole chain is read, which can outlast the request timeout
+	ctx, cancel := context.WithTimeout(context.Background(), auditExportTimeout)
+	defer cancel()
+
+	result, err := h.Audit.Verify(ctx)
+	if err != nil {
+		return c.Status(500).JSON(fiber.Map{"error": err.Error()})
+	}
+	return c.JSON(result)
+}
+
+// createAuditLog records an action with its own values in place of the entry the audit
+// middleware would derive from the request path
+func (h *Handler) createAuditLog(c *fiber.Ctx, userID, action, entityType, entityID string, oldValue, newValue map[string]interface{}) {
+	if h.Audit == nil {
+		return
+	}
+
+	entry := audit.RequestEntry(c)
+	entry.UserID = userID
+	entry.Action = action
+	entry.EntityType = entityType
+	entry.EntityID = entityID
+	entry.OldValue = oldValue
+	entry.NewValue = newValue
+	entry.Status = fiber.StatusOK
+	h.Audit.Record(entry)
+	audit.MarkLogged(c)
+}
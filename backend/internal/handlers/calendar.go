@@ -6,9 +6,11 @@ import (
 	"strings"
 	"time"
 
+	"github.com/ekf/one-on-one-backend/internal/ews"
 	"github.com/ekf/one-on-one-backend/internal/middleware"
 	"github.com/ekf/one-on-one-backend/internal/models"
 	"github.com/ekf/one-on-one-backend/internal/utils"
+	"github.com/ekf/one-on-one-backend/pkg/protocol"
 	"github.com/gofiber/fiber/v2"
 )
 
@@ -321,6 +323,33 @@ func (h *Handler) SyncCalendar(c *fiber.Ctx) error {
 		return c.Status(400).JSON(fiber.Map{"error": "Invalid request"})
 	}
 
+	result, agendas, err := h.syncEmployeeCalendar(req)
+	if err != nil {
+		if syncErr, ok := err.(*calendarSyncError); ok {
+			return c.Status(syncErr.status).JSON(syncErr.body)
+		}
+		return c.Status(500).JSON(fiber.Map{"error": err.Error()})
+	}
+	go h.prepareSyncedAgendas(agendas)
+
+	return c.JSON(result)
+}
+
+// calendarSyncError carries the HTTP status and response body of a failed calendar sync
+type calendarSyncError struct {
+	status int
+	body   fiber.Map
+}
+
+func (e *calendarSyncError) Error() string {
+	message, _ := e.body["error"].(string)
+	return message
+}
+
+// syncEmployeeCalendar imports the employee's Exchange meetings into the database and
+// returns the upcoming ones to prepare agendas for. Used by SyncCalendar and the scheduled
+// calendar_sync job.
+func (h *Handler) syncEmployeeCalendar(req CalendarSyncRequest) (fiber.Map, []syncedMeeting, error) {
 	if req.DaysBack == 0 {
 		req.DaysBack = 7
 	}
@@ -332,13 +361,20 @@ func (h *Handler) SyncCalendar(c *fiber.Ctx) error {
 	var employees []models.Employee
 	err := h.DB.From("employees").Select("*").Eq("id", req.EmployeeID).Limit(1).Execute(&employees)
 	if err != nil {
-		return c.Status(500).JSON(fiber.Map{"error": "Ошибка базы данных: " + err.Error(), "employee_id": req.EmployeeID})
+		return nil, nil, &calendarSyncError{500, fiber.Map{"error": "Ошибка базы данных: " + err.Error(), "employee_id": req.EmployeeID}}
 	}
 	if len(employees) == 0 {
-		return c.Status(404).JSON(fiber.Map{"error": "Сотрудник не найден", "employee_id": req.EmployeeID})
+		return nil, nil, &calendarSyncError{404, fiber.Map{"error": "Сотрудник не найден", "employee_id": req.EmployeeID}}
 	}
 	employee := employees[0]
 
+	// encrypted_password is hidden from JSON, so Select("*") does not load it
+	var creds struct {
+		EncryptedPassword *string `json:"encrypted_password"`
+	}
+	h.DB.From("employees").Select("encrypted_password").Eq("id", employee.ID).Single().Execute(&creds)
+	employee.EncryptedPassword = creds.EncryptedPassword
+
 	// Determine the email to use for EWS
 	var ewsEmail string
 	if employee.Email != "" {
@@ -350,11 +386,11 @@ func (h *Handler) SyncCalendar(c *fiber.Ctx) error {
 	}
 
 	if ewsEmail == "" {
-		return c.Status(400).JSON(fiber.Map{
+		return nil, nil, &calendarSyncError{400, fiber.Map{
 			"error":       "Не удалось определить email для синхронизации. Обратитесь к администратору.",
 			"employee_id": employee.ID,
 			"name":        employee.Name,
-		})
+		}}
 	}
 
 	var events interface{}
@@ -378,13 +414,13 @@ func (h *Handler) SyncCalendar(c *fiber.Ctx) error {
 			log.Printf("WARNING: No encrypted password for %s in SyncCalendar", ewsEmail)
 		}
 
-		result, err := h.Connector.SendCommand("sync_calendar", map[string]interface{}{
-			"email":        ewsEmail,
-			"username":     username,
-			"password":     password,
-			"days_back":    req.DaysBack,
-			"days_forward": req.DaysForward,
-		}, 120*time.Second)
+		result, err := h.Connector.SyncCalendar(protocol.CalendarParams{
+			Email:       ewsEmail,
+			Username:    username,
+			Password:    password,
+			DaysBack:    req.DaysBack,
+			DaysForward: req.DaysForward,
+		})
 
 		if err == nil {
 			events = result
@@ -398,33 +434,33 @@ func (h *Handler) SyncCalendar(c *fiber.Ctx) error {
 		ewsEvents, err := h.EWS.GetCalendarEvents(ewsEmail, h.Config.EWSUsername, h.Config.EWSPassword, req.DaysBack, req.DaysForward)
 		if err != nil {
 			if getErr != nil {
-				return c.Status(500).JSON(fiber.Map{
+				return nil, nil, &calendarSyncError{500, fiber.Map{
 					"error":           "Ошибка подключения к Exchange",
 					"connector_error": getErr.Error(),
 					"ews_error":       err.Error(),
 					"ews_email":       ewsEmail,
 					"ews_url":         h.Config.EWSURL,
-				})
+				}}
 			}
-			return c.Status(500).JSON(fiber.Map{
+			return nil, nil, &calendarSyncError{500, fiber.Map{
 				"error":     "Ошибка подключения к Exchange: " + err.Error(),
 				"ews_email": ewsEmail,
 				"ews_url":   h.Config.EWSURL,
-			})
+			}}
 		}
 		events = ewsEvents
 	}
 
 	if events == nil {
-		return c.Status(500).JSON(fiber.Map{
+		return nil, nil, &calendarSyncError{500, fiber.Map{
 			"error": "Не удалось получить календарь: коннектор недоступен и прямое подключение не настроено",
-		})
+		}}
 	}
 
-	// Convert events to slice for processing
-	eventsList, ok := events.([]interface{})
-	if !ok {
-		return c.Status(500).JSON(fiber.Map{"error": "Invalid events format"})
+	// Events are imported in their JSON form, which is also kept as exchange_data
+	var eventsList []interface{}
+	if data, err := json.Marshal(events); err != nil || json.Unmarshal(data, &eventsList) != nil {
+		return nil, nil, &calendarSyncError{500, fiber.Map{"error": "Invalid events format"}}
 	}
 
 	// If we successfully connected and employee had no email, save it
@@ -433,7 +469,7 @@ func (h *Handler) SyncCalendar(c *fiber.Ctx) error {
 	}
 
 	if len(eventsList) == 0 {
-		return c.JSON(fiber.Map{"synced": 0, "message": "No events found", "ews_email": ewsEmail})
+		return fiber.Map{"synced": 0, "message": "No events found", "ews_email": ewsEmail}, nil, nil
 	}
 
 	// Build email lookup
@@ -458,6 +494,7 @@ func (h *Handler) SyncCalendar(c *fiber.Ctx) error {
 	}
 
 	synced := 0
+	var agendas []syncedMeeting
 	for _, ev := range eventsList {
 		eventMap, ok := ev.(map[string]interface{})
 		if !ok {
@@ -471,15 +508,21 @@ func (h *Handler) SyncCalendar(c *fiber.Ctx) error {
 
 		// Check existing
 		var existing []struct {
-			ID string `json:"id"`
+			ID        string  `json:"id"`
+			Title     *string `json:"title"`
+			StartTime *string `json:"start_time"`
 		}
-		h.DB.From("meetings").Select("id").Eq("exchange_id", eventID).Execute(&existing)
+		h.DB.From("meetings").Select("id, title, start_time").Eq("exchange_id", eventID).Execute(&existing)
 
 		subject, _ := eventMap["subject"].(string)
 		start, _ := eventMap["start"].(string)
 		end, _ := eventMap["end"].(string)
 		location, _ := eventMap["location"].(string)
 
+		// A new, renamed or moved meeting needs a fresh agenda
+		changed := len(existing) == 0 || existing[0].Title == nil || *existing[0].Title != subject ||
+			existing[0].StartTime == nil || !sameInstant(*existing[0].StartTime, start)
+
 		meetingData := map[string]interface{}{
 			"exchange_id":   eventID,
 			"title":         subject,
@@ -498,6 +541,13 @@ func (h *Handler) SyncCalendar(c *fiber.Ctx) error {
 			}
 		}
 
+		// A new meeting with exactly one attendee besides the organizer is a 1-on-1 with them
+		if len(existing) == 0 {
+			if employeeID := oneOnOneAttendee(eventMap, emailToEmp); employeeID != "" {
+				meetingData["employee_id"] = employeeID
+			}
+		}
+
 		var meetingID string
 		if len(existing) > 0 {
 			h.DB.Update("meetings", "id", existing[0].ID, meetingData)
@@ -527,15 +577,59 @@ func (h *Handler) SyncCalendar(c *fiber.Ctx) error {
 			}
 		}
 
+		if meetingID != "" && agendaDue(start, time.Now()) {
+			agendas = append(agendas, syncedMeeting{id: meetingID, changed: changed})
+		}
+
 		synced++
 	}
 
-	return c.JSON(fiber.Map{
+	return fiber.Map{
 		"synced":         synced,
 		"total_events":   len(eventsList),
 		"employee_email": employee.Email,
 		"source":         "connector_ews",
-	})
+	}, agendas, nil
+}
+
+// sameInstant compares two timestamps that may be formatted differently
+func sameInstant(a, b string) bool {
+	ta, errA := time.Parse(time.RFC3339, a)
+	tb, errB := time.Parse(time.RFC3339, b)
+	if errA != nil || errB != nil {
+		return a == b
+	}
+	return ta.Equal(tb)
+}
+
+// oneOnOneAttendee returns the employee ID of the only attendee other than the organizer,
+// or "" if the event has more attendees or the attendee is not an employee
+func oneOnOneAttendee(eventMap map[string]interface{}, emailToEmp map[string]struct {
+	ID   string
+	Name string
+}) string {
+	organizerEmail := ""
+	if organizer, ok := eventMap["organizer"].(map[string]interface{}); ok {
+		organizerEmail, _ = organizer["email"].(string)
+	}
+
+	attendees, _ := eventMap["attendees"].([]interface{})
+	var others []string
+	for _, att := range attendees {
+		attendeeMap, ok := att.(map[string]interface{})
+		if !ok {
+			continue
+		}
+		email, _ := attendeeMap["email"].(string)
+		if email != "" && !strings.EqualFold(email, organizerEmail) {
+			others = append(others, strings.ToLower(email))
+		}
+	}
+
+	if organizerEmail == "" || len(others) != 1 {
+		return ""
+	}
+	return emailToEmp[others[0]].ID
 }
 
 // CreateMeetingRequest represents the request body for creating a meeting
@@ -552,10 +646,6 @@ type CreateMeetingRequest struct {
 
 // CreateCalendarEvent creates a new meeting in Exchange calendar via EWS
 func (h *Handler) CreateCalendarEvent(c *fiber.Ctx) error {
-	if h.EWS == nil {
-		return c.Status(500).JSON(fiber.Map{"error": "EWS not configured"})
-	}
-
 	var req CreateMeetingRequest
 	if err := c.BodyParser(&req); err != nil {
 		return c.Status(400).JSON(fiber.Map{"error": "Invalid request body: " + err.Error()})
@@ -619,10 +709,24 @@ func (h *Handler) CreateCalendarEvent(c *fiber.Ctx) error {
 		}
 	}
 
-	// Create meeting request for EWS
-	ewsReq := h.EWS.NewCreateMeetingRequest(req.Subject, req.Body, req.Start, req.End, req.Location, requiredEmails, optionalEmails, req.IsOnlineMeeting)
+	mail := h.exchange(protocol.CommandCreateCalendarItem, username)
+	if mail == nil {
+		return c.Status(500).JSON(fiber.Map{"error": "EWS not configured"})
+	}
 
-	itemID, err := h.EWS.CreateCalendarItem(username, password, ewsReq)
+	// Create meeting request for EWS
+	ewsReq := ews.CreateMeetingRequest{
+		Subject:           req.Subject,
+		Body:              req.Body,
+		Start:             req.Start,
+		End:               req.End,
+		Location:          req.Location,
+		RequiredAttendees: requiredEmails,
+		OptionalAttendees: optionalEmails,
+		IsOnlineMeeting:   req.IsOnlineMeeting,
+	}
+
+	itemID, err := mail.CreateCalendarItem(username, password, ewsReq)
 	if err != nil {
 		log.Printf("Failed to create meeting in Exchange: %v", err)
 		return c.Status(500).JSON(fiber.Map{"error": "Failed to create meeting: " + err.Error()})
@@ -647,10 +751,6 @@ type UpdateCalendarEventRequest struct {
 
 // UpdateCalendarEvent updates an existing meeting in Exchange calendar via EWS
 func (h *Handler) UpdateCalendarEvent(c *fiber.Ctx) error {
-	if h.EWS == nil {
-		return c.Status(500).JSON(fiber.Map{"error": "EWS not configured"})
-	}
-
 	var req UpdateCalendarEventRequest
 	if err := c.BodyParser(&req); err != nil {
 		return c.Status(400).JSON(fiber.Map{"error": "Invalid request body: " + err.Error()})
@@ -705,7 +805,12 @@ func (h *Handler) UpdateCalendarEvent(c *fiber.Ctx) error {
 		updates["location"] = req.Location
 	}
 
-	if err := h.EWS.UpdateCalendarItem(username, password, req.ItemID, req.ChangeKey, updates); err != nil {
+	mail := h.exchange(protocol.CommandUpdateCalendarItem, username)
+	if mail == nil {
+		return c.Status(500).JSON(fiber.Map{"error": "EWS not configured"})
+	}
+
+	if err := mail.UpdateCalendarItem(username, password, req.ItemID, req.ChangeKey, updates); err != nil {
 		log.Printf("Failed to update meeting in Exchange: %v", err)
 		return c.Status(500).JSON(fiber.Map{"error": "Failed to update meeting: " + err.Error()})
 	}
@@ -725,10 +830,6 @@ type DeleteCalendarEventRequest struct {
 
 // DeleteCalendarEvent deletes a meeting from Exchange calendar via EWS
 func (h *Handler) DeleteCalendarEvent(c *fiber.Ctx) error {
-	if h.EWS == nil {
-		return c.Status(500).JSON(fiber.Map{"error": "EWS not configured"})
-	}
-
 	var req DeleteCalendarEventRequest
 	if err := c.BodyParser(&req); err != nil {
 		return c.Status(400).JSON(fiber.Map{"error": "Invalid request body: " + err.Error()})
@@ -768,7 +869,12 @@ func (h *Handler) DeleteCalendarEvent(c *fiber.Ctx) error {
 
 	username := "ekfgroup\\" + *employee.ADLogin
 
-	if err := h.EWS.DeleteCalendarItem(username, password, req.ItemID, req.ChangeKey, req.SendCancellations); err != nil {
+	mail := h.exchange(protocol.CommandDeleteCalendarItem, username)
+	if mail == nil {
+		return c.Status(500).JSON(fiber.Map{"error": "EWS not configured"})
+	}
+
+	if err := mail.DeleteCalendarItem(username, password, req.ItemID, req.ChangeKey, req.SendCancellations); err != nil {
 		log.Printf("Failed to delete meeting in Exchange: %v", err)
 		return c.Status(500).JSON(fiber.Map{"error": "Failed to delete meeting: " + err.Error()})
 	}
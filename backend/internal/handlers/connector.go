@@ -3,11 +3,15 @@ package handlers
 import (
 	"crypto/rand"
 	"encoding/json"
+	"errors"
 	"strings"
 	"time"
 
+	"github.com/ekf/one-on-one-backend/internal/ad"
 	"github.com/ekf/one-on-one-backend/internal/middleware"
+	"github.com/ekf/one-on-one-backend/internal/services"
 	"github.com/ekf/one-on-one-backend/internal/utils"
+	"github.com/ekf/one-on-one-backend/pkg/protocol"
 	"github.com/gofiber/fiber/v2"
 	"github.com/gofiber/websocket/v2"
 )
@@ -72,7 +76,8 @@ func (h *Handler) ConnectorStatus(c *fiber.Ctx) error {
 
 	return c.JSON(fiber.Map{
 		"connected":            h.Connector.IsConnected(),
-		"pending_requests":     0,
+		"connectors":           h.Connector.Status(),
+		"pending_requests":     h.Connector.PendingRequests(),
 		"ad_status":            adStatus,
 		"ad_sync_enabled":      false,
 		"last_sync":            "",
@@ -92,17 +97,24 @@ func (h *Handler) ConnectorStatus(c *fiber.Ctx) error {
 	})
 }
 
-// ConnectorWebSocket handles WebSocket connection from on-prem connector
+// ConnectorWebSocket handles WebSocket connection from on-prem connector, authenticated by
+// AuthenticateConnector
 func (h *Handler) ConnectorWebSocket(conn *websocket.Conn) {
-	// Get API key from query
-	apiKey := conn.Query("token")
-
-	if err := h.Connector.Connect(conn, apiKey); err != nil {
+	auth, ok := conn.Locals("connector_auth").(services.ConnectorAuth)
+	if !ok {
 		conn.Close()
 		return
 	}
 
-	defer h.Connector.Disconnect()
+	// A credential fixes the connector's name, capabilities and sites; connectors without
+	// one describe themselves in the query
+	info := services.NewConnectorInfo(conn.Query("name"), conn.Query("capabilities"), conn.Query("sites"))
+	if auth.CredentialID != "" {
+		info = services.NewConnectorInfo(auth.Name, auth.Capabilities, auth.Sites)
+	}
+	connector := h.Connector.Connect(conn, info, auth)
+
+	defer h.Connector.Disconnect(connector)
 
 	for {
 		messageType, data, err := conn.ReadMessage()
@@ -110,7 +122,9 @@ func (h *Handler) ConnectorWebSocket(conn *websocket.Conn) {
 			break
 		}
 
-		h.Connector.HandleMessage(messageType, data)
+		if err := h.Connector.HandleMessage(connector, messageType, data); err != nil {
+			break
+		}
 	}
 }
 
@@ -180,23 +194,7 @@ func (h *Handler) SyncADUsersDirect(c *fiber.Ctx) error {
 
 		isExisting := existingEmails[strings.ToLower(user.Email)]
 
-		userData := map[string]interface{}{
-			"name":       user.Name,
-			"email":      user.Email,
-			"position":   user.Title,
-			"department": user.Department,
-			"ad_dn":      user.DN,
-			"manager_dn": user.ManagerDN,
-			"ad_login":   user.Login,
-			"phone":      user.Phone,
-			"mobile":     user.Mobile,
-		}
-
-		if includePhotos && user.PhotoBase64 != "" {
-			userData["photo_base64"] = user.PhotoBase64
-		}
-
-		batch = append(batch, userData)
+		batch = append(batch, adUserRecord(user, includePhotos))
 
 		if isExisting {
 			updatedCount++
@@ -214,31 +212,7 @@ func (h *Handler) SyncADUsersDirect(c *fiber.Ctx) error {
 	}
 
 	// Update manager relationships
-	var employees []struct {
-		ID        string  `json:"id"`
-		ADDN      *string `json:"ad_dn"`
-		ManagerDN *string `json:"manager_dn"`
-	}
-	h.DB.From("employees").Select("id, ad_dn, manager_dn").Execute(&employees)
-
-	dnToID := make(map[string]string)
-	for _, e := range employees {
-		if e.ADDN != nil {
-			dnToID[*e.ADDN] = e.ID
-		}
-	}
-
-	managersUpdated := 0
-	for _, emp := range employees {
-		if emp.ManagerDN != nil {
-			if managerID, ok := dnToID[*emp.ManagerDN]; ok {
-				h.DB.Update("employees", "id", emp.ID, map[string]interface{}{
-					"manager_id": managerID,
-				})
-				managersUpdated++
-			}
-		}
-	}
+	managersUpdated := h.updateManagerLinks()
 
 	stats["new_users"] = newCount
 	stats["updated_users"] = updatedCount
@@ -251,33 +225,64 @@ func (h *Handler) SyncADUsersDirect(c *fiber.Ctx) error {
 	})
 }
 
-// SyncADUsers syncs users from Active Directory
+// SyncADUsers syncs users from Active Directory. While no connector is connected the sync
+// is queued (see ConnectorQueue) and 202 is returned with the queued command.
 func (h *Handler) SyncADUsers(c *fiber.Ctx) error {
 	if h.DB == nil {
 		return c.Status(500).JSON(fiber.Map{"error": "Database not configured"})
 	}
 
-	mode := c.Query("mode", "full")
-	includePhotos := c.Query("include_photos", "true") == "true"
-	requireDepartment := c.Query("require_department", "true") == "true"
+	options := adSyncOptions{
+		Mode:          c.Query("mode", "full"),
+		IncludePhotos: c.Query("include_photos", "true") == "true",
+	}
+	params := protocol.SyncUsersParams{
+		IncludePhotos:     options.IncludePhotos,
+		RequireDepartment: c.Query("require_department", "true") == "true",
+		RequireEmail:      true,
+	}
 
-	// Stats tracking
-	stats := fiber.Map{
-		"mode":               mode,
-		"total_in_ad":        0,
-		"with_department":    0,
-		"without_department": 0,
-		"filtered_out":       0,
-		"new_users":          0,
-		"updated_users":      0,
-		"skipped_existing":   0,
-		"managers_updated":   0,
-		"errors":             []string{},
+	if !h.Connector.IsConnected() && h.ConnectorQueue != nil && h.Config.ConnectorQueueHours > 0 {
+		userID, _ := c.Locals("user_id").(string)
+		cmd, err := h.ConnectorQueue.Enqueue(protocol.CommandSyncUsers, params, options,
+			time.Duration(h.Config.ConnectorQueueHours)*time.Hour, userID)
+		if err != nil {
+			return c.Status(500).JSON(fiber.Map{"error": err.Error()})
+		}
+		return c.Status(202).JSON(fiber.Map{"success": true, "queued": true, "command": cmd})
 	}
 
+	// Fetch users from the connectors of every AD site
+	users, connStats, errs, err := h.connectorSyncUsers(params)
+	if err != nil {
+		return c.Status(500).JSON(fiber.Map{"error": err.Error()})
+	}
+
+	stats, err := h.importConnectorUsers(users, connStats, options)
+	if err != nil {
+		return c.Status(500).JSON(fiber.Map{"error": err.Error()})
+	}
+	stats["errors"] = errs
+
+	return c.JSON(fiber.Map{
+		"success":  true,
+		"imported": stats["new_users"].(int) + stats["updated_users"].(int),
+		"stats":    stats,
+	})
+}
+
+// adSyncOptions are the options of an AD sync through the connector, kept with queued syncs
+type adSyncOptions struct {
+	Mode          string `json:"mode"` // full, new_only (skip existing employees) or changes
+	IncludePhotos bool   `json:"include_photos"`
+}
+
+// importConnectorUsers upserts the users returned by sync_users into employees, links
+// managers and returns the sync stats
+func (h *Handler) importConnectorUsers(users []protocol.User, connStats protocol.SyncStats, options adSyncOptions) (fiber.Map, error) {
 	// Get existing emails
 	existingEmails := make(map[string]bool)
-	if mode == "new_only" || mode == "changes" {
+	if options.Mode == "new_only" || options.Mode == "changes" {
 		var existing []struct {
 			Email string `json:"email"`
 		}
@@ -289,69 +294,28 @@ func (h *Handler) SyncADUsers(c *fiber.Ctx) error {
 		}
 	}
 
-	// Fetch users from connector
-	result, err := h.Connector.SendCommand("sync_users", map[string]interface{}{
-		"include_photo":      includePhotos,
-		"require_department": requireDepartment,
-		"require_email":      true,
-		"mode":               mode,
-	}, 300*time.Second)
-
-	if err != nil {
-		return c.Status(500).JSON(fiber.Map{"error": err.Error()})
-	}
-
-	users, _ := result["users"].([]interface{})
-	if connStats, ok := result["stats"].(map[string]interface{}); ok {
-		stats["total_in_ad"] = connStats["total_in_ad"]
-		stats["with_department"] = connStats["with_department"]
-		stats["without_department"] = connStats["without_department"]
-		stats["filtered_out"] = connStats["filtered_out"]
-	}
-
 	// Process users
 	var batch []map[string]interface{}
+	seen := make(map[string]bool)
 	newCount := 0
 	updatedCount := 0
 	skippedCount := 0
 
-	for _, u := range users {
-		user, ok := u.(map[string]interface{})
-		if !ok {
-			continue
-		}
-
-		email, _ := user["email"].(string)
-		if email == "" {
+	for _, user := range users {
+		email := strings.ToLower(user.Email)
+		if email == "" || seen[email] {
 			continue
 		}
+		seen[email] = true
 
-		isExisting := existingEmails[strings.ToLower(email)]
+		isExisting := existingEmails[email]
 
-		if mode == "new_only" && isExisting {
+		if options.Mode == "new_only" && isExisting {
 			skippedCount++
 			continue
 		}
 
-		userData := map[string]interface{}{
-			"name":       user["name"],
-			"email":      email,
-			"position":   user["title"],
-			"department": user["department"],
-			"ad_dn":      user["dn"],
-			"manager_dn": user["manager_dn"],
-			"ad_login":   user["login"],
-			"phone":      user["phone"],
-			"mobile":     user["mobile"],
-		}
-
-		if includePhotos {
-			if photo, ok := user["photo_base64"].(string); ok && photo != "" {
-				userData["photo_base64"] = photo
-			}
-		}
-
-		batch = append(batch, userData)
+		batch = append(batch, connectorUserRecord(user, options.IncludePhotos))
 
 		if isExisting {
 			updatedCount++
@@ -362,13 +326,131 @@ func (h *Handler) SyncADUsers(c *fiber.Ctx) error {
 
 	// Upsert batch
 	if len(batch) > 0 {
-		_, err := h.DB.Upsert("employees", batch, "email")
-		if err != nil {
-			return c.Status(500).JSON(fiber.Map{"error": err.Error()})
+		if _, err := h.DB.Upsert("employees", batch, "email"); err != nil {
+			return nil, err
 		}
 	}
 
 	// Update manager relationships
+	managersUpdated := h.updateManagerLinks()
+
+	return fiber.Map{
+		"mode":               options.Mode,
+		"total_in_ad":        connStats.TotalInAD,
+		"with_department":    connStats.WithDepartment,
+		"without_department": connStats.WithoutDepartment,
+		"filtered_out":       connStats.FilteredOut,
+		"new_users":          newCount,
+		"updated_users":      updatedCount,
+		"skipped_existing":   skippedCount,
+		"managers_updated":   managersUpdated,
+		"errors":             []string{},
+	}, nil
+}
+
+// importQueuedSyncUsers handles the result of a sync_users command queued while the
+// connector was offline
+func (h *Handler) importQueuedSyncUsers(result, rawOptions json.RawMessage) (interface{}, error) {
+	var users protocol.SyncUsersResult
+	if err := json.Unmarshal(result, &users); err != nil {
+		return nil, err
+	}
+	options := adSyncOptions{Mode: "full", IncludePhotos: true}
+	json.Unmarshal(rawOptions, &options)
+
+	var stats protocol.SyncStats
+	if users.Stats != nil {
+		stats = *users.Stats
+	}
+	return h.importConnectorUsers(users.Users, stats, options)
+}
+
+// connectorSyncUsers runs sync_users on a connector of every AD site and merges the users
+// and stats. It fails only when no site answered; the errors of the others are returned
+// as "site: error".
+func (h *Handler) connectorSyncUsers(params protocol.SyncUsersParams) ([]protocol.User, protocol.SyncStats, []string, error) {
+	var stats protocol.SyncStats
+	sites := h.Connector.Sites(services.CapabilityAD)
+	if len(sites) == 0 {
+		return nil, stats, nil, services.ErrConnectorNotConnected
+	}
+
+	var users []protocol.User
+	seen := make(map[string]bool)
+	errs := []string{}
+	for _, site := range sites {
+		params.Domain = site
+		result, err := h.Connector.SyncUsers(params)
+		if err != nil {
+			if site == "" {
+				site = services.DefaultConnectorName
+			}
+			errs = append(errs, site+": "+err.Error())
+			continue
+		}
+		for _, user := range result.Users {
+			// Offices may share accounts; the employees upsert takes each email once
+			if key := strings.ToLower(user.Email); !seen[key] {
+				seen[key] = true
+				users = append(users, user)
+			}
+		}
+		if result.Stats != nil {
+			stats.TotalInAD += result.Stats.TotalInAD
+			stats.WithDepartment += result.Stats.WithDepartment
+			stats.WithoutDepartment += result.Stats.WithoutDepartment
+			stats.FilteredOut += result.Stats.FilteredOut
+		}
+	}
+
+	if len(errs) == len(sites) {
+		return nil, stats, errs, errors.New(strings.Join(errs, "; "))
+	}
+	return users, stats, errs, nil
+}
+
+// adUserRecord maps a user read from AD to an employees row
+func adUserRecord(user *ad.User, includePhotos bool) map[string]interface{} {
+	userData := map[string]interface{}{
+		"name":       user.Name,
+		"email":      user.Email,
+		"position":   user.Title,
+		"department": user.Department,
+		"ad_dn":      user.DN,
+		"manager_dn": user.ManagerDN,
+		"ad_login":   user.Login,
+		"phone":      user.Phone,
+		"mobile":     user.Mobile,
+	}
+
+	if includePhotos && user.PhotoBase64 != "" {
+		userData["photo_base64"] = user.PhotoBase64
+	}
+	return userData
+}
+
+// connectorUserRecord maps a user returned by the connector's sync_users command to an employees row
+func connectorUserRecord(user protocol.User, includePhotos bool) map[string]interface{} {
+	userData := map[string]interface{}{
+		"name":       user.Name,
+		"email":      user.Email,
+		"position":   user.Title,
+		"department": user.Department,
+		"ad_dn":      user.DN,
+		"manager_dn": user.ManagerDN,
+		"ad_login":   user.Login,
+		"phone":      user.Phone,
+		"mobile":     user.Mobile,
+	}
+
+	if includePhotos && user.PhotoBase64 != "" {
+		userData["photo_base64"] = user.PhotoBase64
+	}
+	return userData
+}
+
+// updateManagerLinks sets employees.manager_id from the manager DN read from AD
+func (h *Handler) updateManagerLinks() int {
 	var employees []struct {
 		ID        string  `json:"id"`
 		ADDN      *string `json:"ad_dn"`
@@ -394,17 +476,7 @@ func (h *Handler) SyncADUsers(c *fiber.Ctx) error {
 			}
 		}
 	}
-
-	stats["new_users"] = newCount
-	stats["updated_users"] = updatedCount
-	stats["skipped_existing"] = skippedCount
-	stats["managers_updated"] = managersUpdated
-
-	return c.JSON(fiber.Map{
-		"success":  true,
-		"imported": newCount + updatedCount,
-		"stats":    stats,
-	})
+	return managersUpdated
 }
 
 // AuthenticateAD authenticates a user against AD only
@@ -514,16 +586,12 @@ func (h *Handler) AuthenticateAD(c *fiber.Ctx) error {
 
 	// Fall back to connector-based AD authentication
 	if h.Connector.IsConnected() {
-		result, err := h.Connector.SendCommand("authenticate", map[string]interface{}{
-			"username": username,
-			"password": password,
-		}, 30*time.Second)
+		result, err := h.Connector.Authenticate(username, password)
 
 		if err == nil {
-			authenticated, _ := result["authenticated"].(bool)
-			if authenticated {
-				user, _ := result["user"].(map[string]interface{})
-				email, _ := user["email"].(string)
+			if result.Authenticated && result.User != nil {
+				user := result.User
+				email := user.Email
 
 				var employee map[string]interface{}
 
@@ -551,11 +619,11 @@ func (h *Handler) AuthenticateAD(c *fiber.Ctx) error {
 					} else {
 						// Auto-create from AD
 						empData := map[string]interface{}{
-							"name":               user["name"],
+							"name":               user.Name,
 							"email":              email,
-							"position":           user["title"],
-							"ad_dn":              user["dn"],
-							"ad_login":           user["login"],
+							"position":           user.Title,
+							"ad_dn":              user.DN,
+							"ad_login":           user.Login,
 							"encrypted_password": encryptedPassword,
 						}
 						insertResult, _ := h.DB.Insert("employees", empData)
@@ -587,8 +655,7 @@ func (h *Handler) AuthenticateAD(c *fiber.Ctx) error {
 					"token":         token, // Still return for backwards compatibility
 				})
 			}
-			errMsg, _ := result["error"].(string)
-			return c.JSON(fiber.Map{"authenticated": false, "error": errMsg})
+			return c.JSON(fiber.Map{"authenticated": false, "error": result.Error})
 		}
 	}
 
@@ -696,15 +763,13 @@ func (h *Handler) GetSubordinates(c *fiber.Ctx) error {
 		return c.Status(404).JSON(fiber.Map{"error": "Employee not found or not synced from AD"})
 	}
 
-	result, err := h.Connector.SendCommand("get_subordinates", map[string]interface{}{
-		"manager_dn": *employee.ADDN,
-	}, 30*time.Second)
+	subordinates, err := h.Connector.GetSubordinates(*employee.ADDN)
 
 	if err != nil {
 		return c.Status(500).JSON(fiber.Map{"error": err.Error()})
 	}
 
-	return c.JSON(result)
+	return c.JSON(fiber.Map{"subordinates": subordinates})
 }
 
 func generateToken() string {
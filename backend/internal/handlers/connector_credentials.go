@@ -0,0 +1,200 @@
+package handlers
+
+import (
+	"crypto/subtle"
+	"errors"
+	"strings"
+	"time"
+
+	"github.com/ekf/one-on-one-backend/internal/services"
+	"github.com/ekf/one-on-one-backend/internal/utils"
+	"github.com/gofiber/fiber/v2"
+)
+
+// defaultRotationGraceHours is how long a rotated credential stays valid by default
+const defaultRotationGraceHours = 24
+
+// AuthenticateConnector authenticates a connector before the WebSocket upgrade: by a
+// client certificate on the mTLS listener, by a token signed with its credential in the
+// Authorization header or, for connectors without a credential of their own, by the
+// shared CONNECTOR_API_KEY in the "token" query parameter. With none of them configured
+// connectors are rejected unless CONNECTOR_ALLOW_UNAUTHENTICATED is set.
+func (h *Handler) AuthenticateConnector(c *fiber.Ctx) error {
+	name := strings.TrimSpace(c.Query("name"))
+	auth, err := h.connectorAuth(c, name)
+	if err != nil {
+		utils.GetLogger().Warn("Connector authentication failed", map[string]interface{}{
+			"name":  name,
+			"ip":    c.IP(),
+			"error": err.Error(),
+		})
+		return c.Status(401).JSON(fiber.Map{"error": "Connector authentication failed"})
+	}
+	if auth.Name != "" && name != "" && name != auth.Name {
+		return c.Status(403).JSON(fiber.Map{"error": "The credential is issued for connector " + auth.Name})
+	}
+
+	c.Locals("connector_auth", *auth)
+	return c.Next()
+}
+
+func (h *Handler) connectorAuth(c *fiber.Ctx, name string) (*services.ConnectorAuth, error) {
+	if state := c.Context().TLSConnectionState(); state != nil && len(state.VerifiedChains) > 0 {
+		if h.ConnectorCredentials == nil {
+			return nil, errors.New("connector credentials need a database")
+		}
+		return h.ConnectorCredentials.AuthenticateCertificate(state.PeerCertificates[0])
+	}
+	if token, ok := strings.CutPrefix(c.Get("Authorization"), "Bearer "); ok {
+		if h.ConnectorCredentials == nil {
+			return nil, errors.New("connector credentials need a database")
+		}
+		return h.ConnectorCredentials.AuthenticateToken(token)
+	}
+
+	// A connector with credentials must use them, so the shared key cannot impersonate it
+	if h.ConnectorCredentials != nil {
+		if name == "" {
+			name = services.DefaultConnectorName
+		}
+		if active, err := h.ConnectorCredentials.HasActive(name); err != nil || active {
+			return nil, errors.New("connector has credentials, the shared key is not accepted")
+		}
+	}
+	if key := h.Config.ConnectorAPIKey; key != "" {
+		if subtle.ConstantTimeCompare([]byte(c.Query("token")), []byte(key)) != 1 {
+			return nil, errors.New("invalid API key")
+		}
+		return &services.ConnectorAuth{Method: services.ConnectorAuthSharedKey}, nil
+	}
+	// Nothing configured yet: open only when explicitly allowed, until the first
+	// credential is issued
+	if !h.Config.ConnectorAllowUnauthenticated {
+		return nil, errors.New("no connector credential or CONNECTOR_API_KEY is configured")
+	}
+	if h.ConnectorCredentials != nil {
+		if active, err := h.ConnectorCredentials.HasActive(""); err != nil || active {
+			return nil, errors.New("credential required")
+		}
+	}
+	return &services.ConnectorAuth{Method: services.ConnectorAuthNone}, nil
+}
+
+// ListConnectorCredentials returns the credentials issued to connectors, including
+// revoked ones
+func (h *Handler) ListConnectorCredentials(c *fiber.Ctx) error {
+	if h.ConnectorCredentials == nil {
+		return c.Status(500).JSON(fiber.Map{"error": "Database not configured"})
+	}
+
+	credentials, err := h.ConnectorCredentials.List()
+	if err != nil {
+		return c.Status(500).JSON(fiber.Map{"error": err.Error()})
+	}
+
+	return c.JSON(fiber.Map{
+		"credentials":       credentials,
+		"shared_key_active": h.Config.ConnectorAPIKey != "",
+		"mtls_enabled":      h.Config.ConnectorTLSPort != "",
+	})
+}
+
+// CreateConnectorCredential issues a credential to a connector. The secret of a token
+// credential is returned only here and by RotateConnectorCredential.
+func (h *Handler) CreateConnectorCredential(c *fiber.Ctx) error {
+	if h.ConnectorCredentials == nil {
+		return c.Status(500).JSON(fiber.Map{"error": "Database not configured"})
+	}
+
+	var input services.ConnectorCredentialInput
+	if err := c.BodyParser(&input); err != nil {
+		return c.Status(400).JSON(fiber.Map{"error": "Invalid request body"})
+	}
+
+	userID, _ := c.Locals("user_id").(string)
+	credential, secret, err := h.ConnectorCredentials.Issue(input, userID)
+	if err != nil {
+		return connectorCredentialError(c, err)
+	}
+
+	h.createAuditLog(c, userID, "connector_credential.create", "connector_credential", credential.ID, nil,
+		map[string]interface{}{"connector_name": credential.ConnectorName, "kind": credential.Kind, "cert_fingerprint": credential.CertFingerprint})
+
+	return c.Status(201).JSON(fiber.Map{"credential": credential, "secret": secret})
+}
+
+// RotateConnectorCredential issues a replacement for a credential. The old one keeps
+// working for grace_hours (24 by default, 0 - revoke at once) while the connector is
+// switched to the new one.
+func (h *Handler) RotateConnectorCredential(c *fiber.Ctx) error {
+	if h.ConnectorCredentials == nil {
+		return c.Status(500).JSON(fiber.Map{"error": "Database not configured"})
+	}
+
+	var req struct {
+		services.ConnectorCredentialInput
+		GraceHours *int `json:"grace_hours"`
+	}
+	if err := c.BodyParser(&req); err != nil {
+		return c.Status(400).JSON(fiber.Map{"error": "Invalid request body"})
+	}
+	grace := defaultRotationGraceHours
+	if req.GraceHours != nil && *req.GraceHours >= 0 {
+		grace = *req.GraceHours
+	}
+
+	id := c.Params("id")
+	userID, _ := c.Locals("user_id").(string)
+	credential, secret, err := h.ConnectorCredentials.Rotate(id, req.ConnectorCredentialInput, time.Duration(grace)*time.Hour, userID)
+	if err != nil {
+		return connectorCredentialError(c, err)
+	}
+	previous, err := h.ConnectorCredentials.Get(id)
+	if err != nil {
+		return connectorCredentialError(c, err)
+	}
+	switch {
+	case previous.RevokedAt != nil:
+		h.Connector.DisconnectCredential(id)
+	case previous.ExpiresAt != nil:
+		h.Connector.ExpireCredential(id, *previous.ExpiresAt)
+	}
+
+	h.createAuditLog(c, userID, "connector_credential.rotate", "connector_credential", id,
+		map[string]interface{}{"credential_id": id},
+		map[string]interface{}{"credential_id": credential.ID, "grace_hours": grace})
+
+	return c.JSON(fiber.Map{"credential": credential, "secret": secret, "previous": previous})
+}
+
+// RevokeConnectorCredential makes a credential unusable and disconnects the connectors
+// that authenticated with it
+func (h *Handler) RevokeConnectorCredential(c *fiber.Ctx) error {
+	if h.ConnectorCredentials == nil {
+		return c.Status(500).JSON(fiber.Map{"error": "Database not configured"})
+	}
+
+	id := c.Params("id")
+	credential, err := h.ConnectorCredentials.Revoke(id)
+	if err != nil {
+		return connectorCredentialError(c, err)
+	}
+	h.Connector.DisconnectCredential(id)
+
+	userID, _ := c.Locals("user_id").(string)
+	h.createAuditLog(c, userID, "connector_credential.revoke", "connector_credential", id,
+		map[string]interface{}{"connector_name": credential.ConnectorName, "kind": credential.Kind}, nil)
+
+	return c.JSON(credential)
+}
+
+func connectorCredentialError(c *fiber.Ctx, err error) error {
+	switch {
+	case errors.Is(err, services.ErrCredentialNotFound):
+		return c.Status(404).JSON(fiber.Map{"error": "Credential not found"})
+	case errors.Is(err, services.ErrCredentialRevoked), errors.Is(err, services.ErrCredentialExpired),
+		errors.Is(err, services.ErrInvalidCredential):
+		return c.Status(400).JSON(fiber.Map{"error": err.Error()})
+	}
+	return c.Status(500).JSON(fiber.Map{"error": err.Error()})
+}
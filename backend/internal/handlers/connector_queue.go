@@ -0,0 +1,71 @@
+package handlers
+
+import (
+	"errors"
+
+	"github.com/ekf/one-on-one-backend/internal/services"
+	"github.com/gofiber/fiber/v2"
+)
+
+// GetConnectorCommand returns a command queued by the current user while the connector was
+// offline, with its progress and, once done, the result summary
+func (h *Handler) GetConnectorCommand(c *fiber.Ctx) error {
+	if h.ConnectorQueue == nil {
+		return c.Status(500).JSON(fiber.Map{"error": "Database not configured"})
+	}
+
+	cmd, err := h.ConnectorQueue.Get(c.Params("id"))
+	if err != nil {
+		return connectorCommandError(c, err)
+	}
+	userID, _ := c.Locals("user_id").(string)
+	if cmd.CreatedBy == nil || *cmd.CreatedBy != userID {
+		return connectorCommandError(c, services.ErrQueuedCommandNotFound)
+	}
+
+	return c.JSON(cmd)
+}
+
+// ListConnectorCommands returns the latest queued connector commands, optionally filtered
+// by status
+func (h *Handler) ListConnectorCommands(c *fiber.Ctx) error {
+	if h.ConnectorQueue == nil {
+		return c.Status(500).JSON(fiber.Map{"error": "Database not configured"})
+	}
+
+	commands, err := h.ConnectorQueue.List(c.Query("status"), min(c.QueryInt("limit", 50), maxPageSize))
+	if err != nil {
+		return c.Status(500).JSON(fiber.Map{"error": err.Error()})
+	}
+
+	return c.JSON(fiber.Map{"commands": commands, "connected": h.Connector.IsConnected()})
+}
+
+// CancelConnectorCommand removes a command from the queue before it is sent
+func (h *Handler) CancelConnectorCommand(c *fiber.Ctx) error {
+	if h.ConnectorQueue == nil {
+		return c.Status(500).JSON(fiber.Map{"error": "Database not configured"})
+	}
+
+	id := c.Params("id")
+	cmd, err := h.ConnectorQueue.Cancel(id)
+	if err != nil {
+		return connectorCommandError(c, err)
+	}
+
+	userID, _ := c.Locals("user_id").(string)
+	h.createAuditLog(c, userID, "connector_command.cancel", "connector_command", id,
+		map[string]interface{}{"command": cmd.Command, "status": services.QueuedCommandQueued}, nil)
+
+	return c.JSON(cmd)
+}
+
+func connectorCommandError(c *fiber.Ctx, err error) error {
+	switch {
+	case errors.Is(err, services.ErrQueuedCommandNotFound):
+		return c.Status(404).JSON(fiber.Map{"error": "Command not found"})
+	case errors.Is(err, services.ErrQueuedCommandClosed):
+		return c.Status(409).JSON(fiber.Map{"error": err.Error()})
+	}
+	return c.Status(500).JSON(fiber.Map{"error": err.Error()})
+}
@@ -0,0 +1,109 @@
+package handlers
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/ekf/one-on-one-backend/internal/events"
+	"github.com/gofiber/fiber/v2"
+)
+
+// registerEventSubscribers attaches the side effects of domain events. Subscriber names
+// are stored in the outbox, so renaming one makes it receive pending events again.
+func (h *Handler) registerEventSubscribers() {
+	bus := h.Events
+
+	h.registerNotificationSubscribers()
+
+	events.Subscribe(bus, "agreement_sync", func(ctx context.Context, _ events.Meta, e events.TaskStatusChanged) error {
+		return h.syncAgreementFromTask(ctx, e.TaskID, e.NewStatus)
+	})
+
+	events.Subscribe(bus, "ticket_activity", func(_ context.Context, _ events.Meta, e events.TicketCreated) error {
+		return h.recordTicketActivity(e.Ticket.ID, e.Ticket.RequesterID, "created", nil, nil)
+	})
+	events.Subscribe(bus, "ticket_activity", func(_ context.Context, meta events.Meta, e events.TicketStatusChanged) error {
+		return h.recordTicketActivity(e.TicketID, meta.ActorID, "status_changed", e.OldStatus, e.NewStatus)
+	})
+	events.Subscribe(bus, "ticket_activity", func(_ context.Context, meta events.Meta, e events.TicketAssigned) error {
+		return h.recordTicketActivity(e.TicketID, meta.ActorID, "assigned", nil, e.AssigneeID)
+	})
+	events.Subscribe(bus, "ticket_activity", func(_ context.Context, _ events.Meta, e events.TicketSLABreached) error {
+		return h.recordTicketActivity(e.TicketID, "", "sla_breached", nil, e.SLADeadline.Format(time.RFC3339))
+	})
+
+	events.Subscribe(bus, "telegram_forward", func(_ context.Context, _ events.Meta, e events.MessageSent) error {
+		return h.ForwardToTelegram(e.ConversationID, e.SenderName, e.Content, e.MessageType)
+	})
+
+	forwardToWebhooks[events.TaskCreated](h)
+	forwardToWebhooks[events.TaskStatusChanged](h)
+	forwardToWebhooks[events.TicketSLABreached](h)
+	forwardToWebhooks[events.ImprovementApproved](h)
+	forwardToWebhooks[events.MeetingAnalyzed](h)
+}
+
+// forwardToWebhooks queues deliveries of events of type E to the webhook endpoints
+// subscribed to them. Event names are the webhook event names.
+func forwardToWebhooks[E events.Event](h *Handler) {
+	events.Subscribe(h.Events, "webhooks", func(_ context.Context, meta events.Meta, e E) error {
+		if h.Webhooks == nil {
+			return nil
+		}
+		return h.Webhooks.Publish(meta.ID, meta.Name, meta.OccurredAt, e)
+	})
+}
+
+// recordTicketActivity adds an entry to the service ticket activity log
+func (h *Handler) recordTicketActivity(ticketID, actorID, action string, oldValue, newValue interface{}) error {
+	if h.DB == nil {
+		return nil
+	}
+	data := map[string]interface{}{
+		"ticket_id": ticketID,
+		"action":    action,
+		"actor_id":  nilIfEmpty(actorID),
+	}
+	if oldValue != nil {
+		data["old_value"] = oldValue
+	}
+	if newValue != nil {
+		data["new_value"] = newValue
+	}
+	_, err := h.DB.Insert("service_ticket_activity", data)
+	return err
+}
+
+// ListEventOutbox returns the latest stored domain events, filtered by status
+// (pending, processed, failed)
+func (h *Handler) ListEventOutbox(c *fiber.Ctx) error {
+	entries, err := h.Events.OutboxEntries(c.Query("status"), min(c.QueryInt("limit", 50), maxPageSize))
+	if err != nil {
+		return eventOutboxError(c, err)
+	}
+	return c.JSON(entries)
+}
+
+// RetryEventOutboxEntry delivers a failed event again to the subscribers that did not handle it
+func (h *Handler) RetryEventOutboxEntry(c *fiber.Ctx) error {
+	id := c.Params("id")
+	if err := h.Events.RetryOutboxEntry(id); err != nil {
+		return eventOutboxError(c, err)
+	}
+
+	userID, _ := c.Locals("user_id").(string)
+	h.createAuditLog(c, userID, "event_outbox.retry", "event_outbox", id, nil, nil)
+
+	return c.Status(202).JSON(fiber.Map{"success": true})
+}
+
+func eventOutboxError(c *fiber.Ctx, err error) error {
+	switch {
+	case errors.Is(err, events.ErrOutboxDisabled):
+		return c.Status(409).JSON(fiber.Map{"error": "Event outbox is disabled (EVENT_OUTBOX_ENABLED)"})
+	case errors.Is(err, events.ErrEntryNotFound):
+		return c.Status(404).JSON(fiber.Map{"error": "Event not found"})
+	}
+	return c.Status(500).JSON(fiber.Map{"error": err.Error()})
+}
@@ -0,0 +1,164 @@
+package handlers
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+
+	"github.com/ekf/one-on-one-backend/internal/ews"
+	"github.com/ekf/one-on-one-backend/internal/notifications"
+	"github.com/ekf/one-on-one-backend/internal/services"
+	"github.com/ekf/one-on-one-backend/pkg/protocol"
+)
+
+// exchangeClient is the Exchange API of the mail and calendar handlers, implemented by
+// the direct EWS client and by connectorExchange
+type exchangeClient interface {
+	GetMailFolders(email, username, password string) ([]ews.MailFolder, error)
+	GetEmails(email, username, password, folderID string, limit int) ([]ews.EmailMessage, error)
+	GetEmailBody(username, password, itemID, changeKey string) (string, error)
+	GetAttachments(username, password, itemID, changeKey string) ([]ews.Attachment, error)
+	GetAttachmentContent(username, password, attachmentID string) (string, string, []byte, error)
+	SendEmail(username, password, subject string, toEmails []string, body string, ccEmails []string) error
+	SendEmailWithAttachments(username, password, subject string, toEmails []string, body string, ccEmails []string, attachments []ews.EmailAttachment) error
+	MarkEmailAsRead(username, password, itemID, changeKey string) error
+	DeleteEmail(username, password, itemID, changeKey string) error
+	RespondToMeetingRequest(username, password, itemID, changeKey, response string) error
+	CreateCalendarItem(username, password string, req ews.CreateMeetingRequest) (string, error)
+	UpdateCalendarItem(username, password, itemID, changeKey string, updates map[string]interface{}) error
+	DeleteCalendarItem(username, password, itemID, changeKey string, sendCancellations bool) error
+}
+
+// exchange returns the Exchange client to run a command as username with: a connected
+// connector that can run it, so deployments where the backend cannot reach Exchange keep
+// mail, else the direct EWS client. Nil when there is neither.
+func (h *Handler) exchange(command, username string) exchangeClient {
+	params, _ := json.Marshal(protocol.Mailbox{Username: username})
+	if h.Connector.CanRun(command, params) {
+		return connectorExchange{h.Connector}
+	}
+	if h.EWS == nil {
+		return nil
+	}
+	return h.EWS
+}
+
+// errEmailNotConfigured means neither a connector nor the EWS service account can send email
+var errEmailNotConfigured = fmt.Errorf("%w: EWS not configured", notifications.ErrUnavailable)
+
+// sendServiceEmail sends an email from the EWS service account. A connector sends it from
+// its own service account unless EWS_USERNAME is set.
+func (h *Handler) sendServiceEmail(subject string, to []string, body string) error {
+	mail := h.exchange(protocol.CommandSendEmail, h.Config.EWSUsername)
+	if _, direct := mail.(*ews.Client); mail == nil || (direct && h.Config.EWSUsername == "") {
+		return errEmailNotConfigured
+	}
+	return mail.SendEmail(h.Config.EWSUsername, h.Config.EWSPassword, subject, to, body, nil)
+}
+
+// connectorExchange runs Exchange requests on a connector
+type connectorExchange struct {
+	m *services.ConnectorManager
+}
+
+func (x connectorExchange) GetMailFolders(_, username, password string) ([]ews.MailFolder, error) {
+	return x.m.GetMailFolders(protocol.Mailbox{Username: username, Password: password})
+}
+
+func (x connectorExchange) GetEmails(_, username, password, folderID string, limit int) ([]ews.EmailMessage, error) {
+	return x.m.GetEmails(protocol.EmailsParams{
+		Mailbox:  protocol.Mailbox{Username: username, Password: password},
+		FolderID: folderID,
+		Limit:    limit,
+	})
+}
+
+func (x connectorExchange) GetEmailBody(username, password, itemID, changeKey string) (string, error) {
+	return x.m.GetEmailBody(itemParams(username, password, itemID, changeKey))
+}
+
+func (x connectorExchange) GetAttachments(username, password, itemID, changeKey string) ([]ews.Attachment, error) {
+	return x.m.GetAttachments(itemParams(username, password, itemID, changeKey))
+}
+
+func (x connectorExchange) GetAttachmentContent(username, password, attachmentID string) (string, string, []byte, error) {
+	result, err := x.m.GetAttachmentContent(protocol.AttachmentContentParams{
+		Mailbox:      protocol.Mailbox{Username: username, Password: password},
+		AttachmentID: attachmentID,
+	})
+	if err != nil {
+		return "", "", nil, err
+	}
+	return result.Name, result.ContentType, []byte(result.Content), nil
+}
+
+func (x connectorExchange) SendEmail(username, password, subject string, toEmails []string, body string, ccEmails []string) error {
+	return x.SendEmailWithAttachments(username, password, subject, toEmails, body, ccEmails, nil)
+}
+
+func (x connectorExchange) SendEmailWithAttachments(username, password, subject string, toEmails []string, body string, ccEmails []string, attachments []ews.EmailAttachment) error {
+	params := protocol.SendEmailParams{
+		Mailbox: protocol.Mailbox{Username: username, Password: password},
+		To:      toEmails,
+		CC:      ccEmails,
+		Subject: subject,
+		Body:    body,
+	}
+	for _, att := range attachments {
+		params.Attachments = append(params.Attachments, protocol.EmailAttachment{
+			Name:    att.Name,
+			Content: base64.StdEncoding.EncodeToString(att.Content),
+		})
+	}
+	return x.m.SendEmail(params)
+}
+
+func (x connectorExchange) MarkEmailAsRead(username, password, itemID, changeKey string) error {
+	return x.m.MarkEmailRead(itemParams(username, password, itemID, changeKey))
+}
+
+func (x connectorExchange) DeleteEmail(username, password, itemID, changeKey string) error {
+	return x.m.DeleteEmail(itemParams(username, password, itemID, changeKey))
+}
+
+func (x connectorExchange) RespondToMeetingRequest(username, password, itemID, changeKey, response string) error {
+	return x.m.RespondToMeeting(protocol.MeetingResponseParams{
+		Mailbox:   protocol.Mailbox{Username: username, Password: password},
+		ItemID:    itemID,
+		ChangeKey: changeKey,
+		Response:  response,
+	})
+}
+
+func (x connectorExchange) CreateCalendarItem(username, password string, req ews.CreateMeetingRequest) (string, error) {
+	return x.m.CreateCalendarItem(protocol.CreateCalendarItemParams{
+		Mailbox: protocol.Mailbox{Username: username, Password: password},
+		Item:    req,
+	})
+}
+
+func (x connectorExchange) UpdateCalendarItem(username, password, itemID, changeKey string, updates map[string]interface{}) error {
+	return x.m.UpdateCalendarItem(protocol.UpdateCalendarItemParams{
+		Mailbox:   protocol.Mailbox{Username: username, Password: password},
+		ItemID:    itemID,
+		ChangeKey: changeKey,
+		Updates:   updates,
+	})
+}
+
+func (x connectorExchange) DeleteCalendarItem(username, password, itemID, changeKey string, sendCancellations bool) error {
+	return x.m.DeleteCalendarItem(protocol.DeleteCalendarItemParams{
+		Mailbox:           protocol.Mailbox{Username: username, Password: password},
+		ItemID:            itemID,
+		ChangeKey:         changeKey,
+		SendCancellations: sendCancellations,
+	})
+}
+
+func itemParams(username, password, itemID, changeKey string) protocol.ItemParams {
+	return protocol.ItemParams{
+		Mailbox:   protocol.Mailbox{Username: username, Password: password},
+		ItemID:    itemID,
+		ChangeKey: changeKey,
+	}
+}
@@ -1,34 +1,61 @@
 package handlers
 
 import (
+	"context"
+	"fmt"
+	"time"
+
 	"github.com/ekf/one-on-one-backend/internal/ad"
+	"github.com/ekf/one-on-one-backend/internal/audit"
 	"github.com/ekf/one-on-one-backend/internal/config"
 	"github.com/ekf/one-on-one-backend/internal/database"
+	"github.com/ekf/one-on-one-backend/internal/events"
 	"github.com/ekf/one-on-one-backend/internal/ews"
+	"github.com/ekf/one-on-one-backend/internal/notifications"
+	"github.com/ekf/one-on-one-backend/internal/scheduler"
 	"github.com/ekf/one-on-one-backend/internal/services"
 	"github.com/ekf/one-on-one-backend/internal/services/confluence"
 	"github.com/ekf/one-on-one-backend/internal/services/github"
 	"github.com/ekf/one-on-one-backend/internal/storage"
+	"github.com/ekf/one-on-one-backend/internal/templates"
+	"github.com/ekf/one-on-one-backend/internal/utils"
+	"github.com/ekf/one-on-one-backend/internal/webhooks"
 	"github.com/ekf/one-on-one-backend/pkg/ai"
 	"github.com/ekf/one-on-one-backend/pkg/auth"
 	"github.com/ekf/one-on-one-backend/pkg/camunda"
+	"github.com/ekf/one-on-one-backend/pkg/protocol"
 	"github.com/ekf/one-on-one-backend/pkg/telegram"
 )
 
 // Handler holds all handler dependencies
 type Handler struct {
-	Config     *config.Config
-	DB         database.DBClient
-	Storage    *storage.MinIOClient
-	AI         *ai.Client
-	AD         *ad.Client
-	EWS        *ews.Client
-	Telegram   *telegram.Client
-	Connector  *services.ConnectorManager
-	JWT        *auth.JWTManager
-	Camunda    *camunda.Client
-	Confluence *confluence.Client
-	GitHub     *github.Client
+	Config        *config.Config
+	DB            database.DBClient
+	Storage       *storage.MinIOClient
+	AI            *ai.Client
+	AD            *ad.Client
+	EWS           *ews.Client
+	Telegram      *telegram.Client
+	Connector     *services.ConnectorManager
+	JWT           *auth.JWTManager
+	Camunda       *camunda.Client
+	Confluence    *confluence.Client
+	GitHub        *github.Client
+	Scheduler     *scheduler.Scheduler
+	Audit         *audit.Recorder
+	Webhooks      *webhooks.Dispatcher
+	Events        *events.Bus
+	Notifications *notifications.Service
+	Templates     *templates.Renderer
+
+	// Credentials connectors authenticate with and commands queued while no connector is
+	// online; nil without a database
+	ConnectorCredentials *services.ConnectorCredentials
+	ConnectorQueue       *services.ConnectorQueue
+
+	meetingJobs chan string    // Queue of meeting processing job IDs
+	location    *time.Location // Business timezone (TIMEZONE) for schedules and working hours
+	afterCommit *[]func()      // Set inside inTx: run once the transaction commits
 }
 
 // NewHandler creates a new handler with all dependencies
@@ -42,6 +69,8 @@ func NewHandler(cfg *config.Config) *Handler {
 			// Log error but continue - some handlers may not need DB
 			println("Warning: Failed to connect to database:", err.Error())
 		} else {
+			pgClient.SetQueryTimeout(time.Duration(cfg.DBQueryTimeoutSeconds) * time.Second)
+			pgClient.SetSlowQueryThreshold(time.Duration(cfg.DBSlowQueryMs) * time.Millisecond)
 			db = pgClient
 		}
 	}
@@ -52,13 +81,38 @@ func NewHandler(cfg *config.Config) *Handler {
 		cfg.YandexAPIKey,
 		cfg.YandexFolderID,
 	)
+	if cfg.LocalSTTURL != "" {
+		aiClient.RegisterTranscriber(ai.NewLocalWhisperTranscriber(cfg.LocalSTTURL, cfg.LocalSTTModel, cfg.LocalSTTAPIKey,
+			time.Duration(cfg.LocalSTTTimeoutSeconds)*time.Second))
+	}
+	aiClient.SetTranscriberRouting(
+		ai.ParseProviderList(cfg.STTProviders),
+		ai.ParseTranscriberRouting(cfg.STTCategoryProviders),
+	)
+
+	llmOptions := ai.LLMOptions{
+		Temperature: cfg.LLMTemperature,
+		MaxTokens:   cfg.LLMMaxTokens,
+		Timeout:     time.Duration(cfg.LLMTimeoutSeconds) * time.Second,
+	}
+	anthropicOptions, openaiOptions, localOptions := llmOptions, llmOptions, llmOptions
+	anthropicOptions.Model = cfg.AnthropicModel
+	openaiOptions.Model = cfg.OpenAIModel
+	localOptions.Model = cfg.LocalLLMModel
+	aiClient.RegisterLLMProvider(ai.NewAnthropicProvider(cfg.AnthropicKey, anthropicOptions))
+	aiClient.RegisterLLMProvider(ai.NewOpenAICompatibleProvider(ai.LLMProviderOpenAI, "https://api.openai.com/v1", cfg.OpenAIKey, openaiOptions))
+	if cfg.LocalLLMURL != "" {
+		aiClient.RegisterLLMProvider(ai.NewOpenAICompatibleProvider(ai.LLMProviderLocal, cfg.LocalLLMURL, cfg.LocalLLMAPIKey, localOptions))
+	}
+	aiClient.SetLLMChain(ai.ParseProviderList(cfg.LLMProviders))
+	aiClient.SetRepairAttempts(cfg.LLMRepairAttempts)
 
 	// Initialize AD and EWS clients for direct access (no connector needed)
 	adClient := ad.NewClient(cfg.ADURL, cfg.ADBaseDN, cfg.ADBindUser, cfg.ADBindPassword, cfg.ADSkipVerify)
 	ewsClient := ews.NewClient(cfg.EWSURL, cfg.EWSDomain, cfg.EWSSkipTLSVerify)
 
 	tgClient := telegram.NewClient(cfg.TelegramBotToken)
-	connector := services.NewConnectorManager(cfg.ConnectorAPIKey)
+	connector := services.NewConnectorManager()
 	jwtManager := auth.NewJWTManager(cfg.JWTSecret, 24) // 24 hours expiration
 	camundaClient := camunda.NewClient(cfg.CamundaURL, cfg.CamundaUser, cfg.CamundaPassword)
 
@@ -86,7 +140,7 @@ func NewHandler(cfg *config.Config) *Handler {
 	// Initialize GitHub client
 	githubClient := github.NewClient(cfg.GitHubToken)
 
-	return &Handler{
+	h := &Handler{
 		Config:     cfg,
 		DB:         db,
 		Storage:    storageClient,
@@ -99,5 +153,125 @@ func NewHandler(cfg *config.Config) *Handler {
 		Camunda:    camundaClient,
 		Confluence: confluenceClient,
 		GitHub:     githubClient,
+		location:   loadLocation(cfg.Timezone),
+	}
+
+	// Start background processing of uploaded meeting recordings
+	h.startMeetingJobWorkers(cfg.MeetingJobWorkers)
+	// Audit trail, written in the background so requests do not wait for the hash chain
+	if db != nil {
+		h.Audit = audit.NewRecorder(db)
+		h.Audit.Start()
+	}
+	// Credentials connectors authenticate with; signing keys are encrypted like webhook secrets
+	if db != nil {
+		h.ConnectorCredentials = services.NewConnectorCredentials(db, cfg.JWTSecret)
+	}
+	// Connector commands queued while offline, sent when a connector connects
+	if db != nil {
+		h.ConnectorQueue = services.NewConnectorQueue(db, h.Connector)
+		h.ConnectorQueue.Handle(protocol.CommandSyncUsers, h.importQueuedSyncUsers)
+		h.ConnectorQueue.Start()
+	}
+	// Outbound webhooks, fed by the event bus
+	if db != nil {
+		h.Webhooks = webhooks.New(db, cfg.JWTSecret,
+			time.Duration(cfg.WebhookTimeoutSeconds)*time.Second, cfg.WebhookMaxAttempts)
+		if cfg.WebhooksEnabled {
+			h.Webhooks.Start()
+		}
+	}
+	// Localized templates of notifications, emails and bot messages
+	h.Templates = templates.New(cfg.AppURL, h.location)
+	// User notifications; email and Telegram are sent in the background
+	if db != nil {
+		h.Notifications = notifications.New(db, h.location, h.Templates, pushNotification, map[string]notifications.Sender{
+			notifications.ChannelEmail:    notifications.EmailSender(h.sendServiceEmail),
+			notifications.ChannelTelegram: notifications.TelegramSender(tgClient, cfg.TelegramBotToken),
+		})
+		if cfg.NotificationsEnabled {
+			h.Notifications.Start()
+		}
+	}
+	// Domain events: side effects of handlers run as subscribers (see events.go)
+	h.Events = events.New(db, cfg.EventOutboxEnabled)
+	h.registerEventSubscribers()
+	h.Events.Start()
+	// Periodic jobs: agendas, 1-on-1 cadence, SLA checks, AD and calendar sync
+	if db != nil {
+		h.Scheduler = scheduler.New(db, h.location)
+		h.registerScheduledJobs()
+		if cfg.SchedulerEnabled {
+			h.Scheduler.Start()
+		}
+	}
+
+	return h
+}
+
+// publish emits a domain event through h.DB. Inside inTx the event is part of the
+// transaction: with the outbox it is stored only if the transaction commits, and the
+// returned error should roll the transaction back; the in-memory bus gets it after the
+// commit, so subscribers never see a change that was rolled back. Outside a transaction
+// a failure is only logged: the change that caused the event stands.
+func (h *Handler) publish(actorID string, event events.Event) error {
+	switch {
+	case h.afterCommit == nil:
+		logPublishError(event, h.Events.Publish(h.DB, actorID, event))
+	case h.Events.Durable():
+		if err := h.Events.Publish(h.DB, actorID, event); err != nil {
+			return fmt.Errorf("publish %s: %w", event.EventName(), err)
+		}
+	default:
+		bus := h.Events
+		*h.afterCommit = append(*h.afterCommit, func() {
+			logPublishError(event, bus.Publish(nil, actorID, event))
+		})
+	}
+	return nil
+}
+
+// logPublishError logs an event that could not be published
+func logPublishError(event events.Event, err error) {
+	if err != nil {
+		utils.GetLogger().Warn("Failed to publish event", map[string]interface{}{
+			"event": event.EventName(),
+			"error": err.Error(),
+		})
+	}
+}
+
+// inTx runs fn in a database transaction. fn gets a copy of the handler whose DB is the
+// transaction, so helpers that use h.DB (and tx.publish) take part in it.
+func (h *Handler) inTx(ctx context.Context, fn func(tx *Handler) error) error {
+	if h.afterCommit != nil {
+		return fn(h) // Joins the outer transaction, like WithTx
+	}
+	var afterCommit []func()
+	err := h.DB.WithTx(ctx, func(db database.DBClient) error {
+		txHandler := *h
+		txHandler.DB = db
+		txHandler.afterCommit = &afterCommit
+		return fn(&txHandler)
+	})
+	if err != nil {
+		return err
+	}
+	for _, run := range afterCommit {
+		run()
+	}
+	return nil
+}
+
+// loadLocation returns the named timezone, falling back to server time
+func loadLocation(name string) *time.Location {
+	loc, err := time.LoadLocation(name)
+	if err != nil {
+		utils.GetLogger().Warn("Unknown timezone, using server time", map[string]interface{}{
+			"timezone": name,
+			"error":    err.Error(),
+		})
+		return time.Local
 	}
+	return loc
 }
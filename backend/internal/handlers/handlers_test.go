@@ -0,0 +1,72 @@
+package handlers
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/ekf/one-on-one-backend/internal/database"
+	"github.com/ekf/one-on-one-backend/internal/events"
+)
+
+// txDB runs WithTx without a database; fn's error is returned like a rollback
+type txDB struct {
+	database.DBClient
+}
+
+func (db txDB) WithTx(_ context.Context, fn func(tx database.DBClient) error) error {
+	return fn(db)
+}
+
+func TestInTxQueuesEventsAfterCommit(t *testing.T) {
+	bus := events.New(nil, false)
+	published := make(chan events.TaskCreated, 10)
+	events.Subscribe(bus, "test", func(_ context.Context, _ events.Meta, e events.TaskCreated) error {
+		published <- e
+		return nil
+	})
+	bus.Start()
+	h := &Handler{DB: txDB{}, Events: bus}
+
+	noEvent := func(when string) {
+		t.Helper()
+		select {
+		case e := <-published:
+			t.Errorf("event %s delivered %s", e.Task.ID, when)
+		case <-time.After(50 * time.Millisecond):
+		}
+	}
+
+	errRollback := errors.New("rollback")
+	err := h.inTx(context.Background(), func(tx *Handler) error {
+		if err := tx.publish("", events.TaskCreated{}); err != nil {
+			t.Fatalf("publish: %v", err)
+		}
+		return errRollback
+	})
+	if !errors.Is(err, errRollback) {
+		t.Fatalf("inTx = %v, want the error of fn", err)
+	}
+	noEvent("after a rollback")
+
+	err = h.inTx(context.Background(), func(tx *Handler) error {
+		tx.publish("", events.TaskCreated{})
+		// A nested inTx joins the transaction and its events wait for the same commit
+		return tx.inTx(context.Background(), func(nested *Handler) error {
+			nested.publish("", events.TaskCreated{})
+			noEvent("before the commit")
+			return nil
+		})
+	})
+	if err != nil {
+		t.Fatalf("inTx: %v", err)
+	}
+	for i := 0; i < 2; i++ {
+		select {
+		case <-published:
+		case <-time.After(time.Second):
+			t.Fatalf("got %d events after the commit, want 2", i)
+		}
+	}
+}
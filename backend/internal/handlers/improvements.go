@@ -5,6 +5,8 @@ import (
 	"fmt"
 	"time"
 
+	"github.com/ekf/one-on-one-backend/internal/database"
+	"github.com/ekf/one-on-one-backend/internal/events"
 	"github.com/ekf/one-on-one-backend/internal/models"
 	"github.com/gofiber/fiber/v2"
 )
@@ -36,13 +38,28 @@ func (h *Handler) ListImprovementRequests(c *fiber.Ctx) error {
 	if priority := c.Query("priority"); priority != "" {
 		query = query.Eq("priority", priority)
 	}
+	if participantID := c.Query("participant_id"); participantID != "" {
+		id := database.QuoteFilterValue(participantID)
+		query = query.Or("initiator_id.eq." + id + ",sponsor_id.eq." + id)
+	}
+	if q := c.Query("q"); q != "" {
+		query = query.TextSearch("search_vector", q, searchConfig)
+	}
 
-	var requests []models.ImprovementRequest
-	err := query.Order("created_at", true).Execute(&requests)
+	query, page, err := paginate(c, query, 0)
 	if err != nil {
-		return c.Status(500).JSON(fiber.Map{"error": err.Error()})
+		return listError(c, err)
 	}
 
+	var requests []models.ImprovementRequest
+	if err := query.Execute(&requests); err != nil {
+		return listError(c, err)
+	}
+
+	page.setHeaders(c, len(requests), func() string {
+		last := requests[len(requests)-1]
+		return pageCursor(last.CreatedAt, last.ID)
+	})
 	return c.JSON(requests)
 }
 
@@ -389,6 +406,20 @@ func (h *Handler) ApproveImprovementRequest(c *fiber.Ctx) error {
 
 	var updated []models.ImprovementRequest
 	json.Unmarshal(result, &updated)
+
+	event := events.ImprovementApproved{
+		RequestID:  id,
+		ApproverID: input.ApproverID,
+		Stage:      request.Status,
+		NewStatus:  nextStatus,
+		Comment:    input.Comment,
+	}
+	if len(updated) > 0 {
+		event.Request = &updated[0]
+	}
+	userID, _ := c.Locals("user_id").(string)
+	h.publish(userID, event)
+
 	if len(updated) == 0 {
 		return c.JSON(fiber.Map{"status": nextStatus})
 	}
@@ -494,47 +525,54 @@ func (h *Handler) CreateProjectFromRequest(c *fiber.Ctx) error {
 		return c.Status(400).JSON(fiber.Map{"error": "Project already created for this request"})
 	}
 
-	// Create project
-	projectData := map[string]interface{}{
-		"name":        request.Title,
-		"description": request.Description,
-		"status":      "planning",
-		"start_date":  request.EstimatedStart,
-		"end_date":    request.EstimatedEnd,
-	}
-
-	projectResult, err := h.DB.Insert("projects", projectData)
-	if err != nil {
-		return c.Status(500).JSON(fiber.Map{"error": "Failed to create project: " + err.Error()})
-	}
-
+	// Project, request link and activity are saved together
 	var createdProjects []models.Project
-	json.Unmarshal(projectResult, &createdProjects)
-	if len(createdProjects) == 0 {
-		return c.Status(500).JSON(fiber.Map{"error": "Failed to create project"})
-	}
-
-	projectID := createdProjects[0].ID
-
-	// Update request with project link
-	updateData := map[string]interface{}{
-		"project_id": projectID,
-		"status":     "in_progress",
-		"updated_at": time.Now(),
-	}
-
-	result, err := h.DB.Update("improvement_requests", "id", id, updateData)
+	var result []byte
+	err = h.DB.WithTx(c.UserContext(), func(tx database.DBClient) error {
+		projectData := map[string]interface{}{
+			"name":        request.Title,
+			"description": request.Description,
+			"status":      "planning",
+			"start_date":  request.EstimatedStart,
+			"end_date":    request.EstimatedEnd,
+		}
+
+		projectResult, err := tx.Insert("projects", projectData)
+		if err != nil {
+			return fmt.Errorf("failed to create project: %w", err)
+		}
+
+		json.Unmarshal(projectResult, &createdProjects)
+		if len(createdProjects) == 0 {
+			return fmt.Errorf("failed to create project")
+		}
+
+		// Update request with project link
+		updateData := map[string]interface{}{
+			"project_id": createdProjects[0].ID,
+			"status":     "in_progress",
+			"updated_at": time.Now(),
+		}
+
+		result, err = tx.Update("improvement_requests", "id", id, updateData)
+		if err != nil {
+			return err
+		}
+
+		// Log activity
+		_, err = tx.Insert("improvement_request_activity", map[string]interface{}{
+			"request_id": id,
+			"actor_id":   nilIfEmpty(input.ActorID),
+			"action":     "project_created",
+			"new_value":  createdProjects[0].ID,
+		})
+		return err
+	})
 	if err != nil {
 		return c.Status(500).JSON(fiber.Map{"error": err.Error()})
 	}
 
-	// Log activity
-	h.DB.Insert("improvement_request_activity", map[string]interface{}{
-		"request_id": id,
-		"actor_id":   input.ActorID,
-		"action":     "project_created",
-		"new_value":  projectID,
-	})
+	projectID := createdProjects[0].ID
 
 	var updated []models.ImprovementRequest
 	json.Unmarshal(result, &updated)
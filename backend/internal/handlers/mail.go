@@ -4,6 +4,7 @@ import (
 	"encoding/base64"
 
 	"github.com/ekf/one-on-one-backend/internal/ews"
+	"github.com/ekf/one-on-one-backend/pkg/protocol"
 	"github.com/gofiber/fiber/v2"
 )
 
@@ -17,10 +18,6 @@ type MailCredentialsRequest struct {
 
 // GetMailFolders returns user's mail folders
 func (h *Handler) GetMailFolders(c *fiber.Ctx) error {
-	if h.EWS == nil {
-		return c.Status(503).JSON(fiber.Map{"error": "EWS not configured"})
-	}
-
 	var req MailCredentialsRequest
 	if err := c.BodyParser(&req); err != nil {
 		return c.Status(400).JSON(fiber.Map{"error": "Invalid request body"})
@@ -30,7 +27,12 @@ func (h *Handler) GetMailFolders(c *fiber.Ctx) error {
 		return c.Status(400).JSON(fiber.Map{"error": "username and password required"})
 	}
 
-	folders, err := h.EWS.GetMailFolders("", req.Username, req.Password)
+	mail := h.exchange(protocol.CommandGetMailFolders, req.Username)
+	if mail == nil {
+		return c.Status(503).JSON(fiber.Map{"error": "EWS not configured"})
+	}
+
+	folders, err := mail.GetMailFolders("", req.Username, req.Password)
 	if err != nil {
 		return c.Status(500).JSON(fiber.Map{"error": err.Error()})
 	}
@@ -40,10 +42,6 @@ func (h *Handler) GetMailFolders(c *fiber.Ctx) error {
 
 // GetEmails returns emails from a folder
 func (h *Handler) GetEmails(c *fiber.Ctx) error {
-	if h.EWS == nil {
-		return c.Status(503).JSON(fiber.Map{"error": "EWS not configured"})
-	}
-
 	var req MailCredentialsRequest
 	if err := c.BodyParser(&req); err != nil {
 		return c.Status(400).JSON(fiber.Map{"error": "Invalid request body"})
@@ -58,7 +56,12 @@ func (h *Handler) GetEmails(c *fiber.Ctx) error {
 		limit = 50
 	}
 
-	emails, err := h.EWS.GetEmails("", req.Username, req.Password, req.FolderID, limit)
+	mail := h.exchange(protocol.CommandGetEmails, req.Username)
+	if mail == nil {
+		return c.Status(503).JSON(fiber.Map{"error": "EWS not configured"})
+	}
+
+	emails, err := mail.GetEmails("", req.Username, req.Password, req.FolderID, limit)
 	if err != nil {
 		return c.Status(500).JSON(fiber.Map{"error": err.Error()})
 	}
@@ -85,10 +88,6 @@ type SendMailRequest struct {
 
 // SendEmail sends an email
 func (h *Handler) SendEmail(c *fiber.Ctx) error {
-	if h.EWS == nil {
-		return c.Status(503).JSON(fiber.Map{"error": "EWS not configured"})
-	}
-
 	var req SendMailRequest
 	if err := c.BodyParser(&req); err != nil {
 		return c.Status(400).JSON(fiber.Map{"error": "Invalid request body"})
@@ -115,12 +114,17 @@ func (h *Handler) SendEmail(c *fiber.Ctx) error {
 		})
 	}
 
+	mail := h.exchange(protocol.CommandSendEmail, req.Username)
+	if mail == nil {
+		return c.Status(503).JSON(fiber.Map{"error": "EWS not configured"})
+	}
+
 	// Send with or without attachments
 	var err error
 	if len(ewsAttachments) > 0 {
-		err = h.EWS.SendEmailWithAttachments(req.Username, req.Password, req.Subject, req.To, req.Body, req.CC, ewsAttachments)
+		err = mail.SendEmailWithAttachments(req.Username, req.Password, req.Subject, req.To, req.Body, req.CC, ewsAttachments)
 	} else {
-		err = h.EWS.SendEmail(req.Username, req.Password, req.Subject, req.To, req.Body, req.CC)
+		err = mail.SendEmail(req.Username, req.Password, req.Subject, req.To, req.Body, req.CC)
 	}
 
 	if err != nil {
@@ -140,10 +144,6 @@ type MarkEmailReadRequest struct {
 
 // MarkEmailAsRead marks an email as read
 func (h *Handler) MarkEmailAsRead(c *fiber.Ctx) error {
-	if h.EWS == nil {
-		return c.Status(503).JSON(fiber.Map{"error": "EWS not configured"})
-	}
-
 	var req MarkEmailReadRequest
 	if err := c.BodyParser(&req); err != nil {
 		return c.Status(400).JSON(fiber.Map{"error": "Invalid request body"})
@@ -153,7 +153,12 @@ func (h *Handler) MarkEmailAsRead(c *fiber.Ctx) error {
 		return c.Status(400).JSON(fiber.Map{"error": "username, password, and item_id required"})
 	}
 
-	if err := h.EWS.MarkEmailAsRead(req.Username, req.Password, req.ItemID, req.ChangeKey); err != nil {
+	mail := h.exchange(protocol.CommandMarkEmailRead, req.Username)
+	if mail == nil {
+		return c.Status(503).JSON(fiber.Map{"error": "EWS not configured"})
+	}
+
+	if err := mail.MarkEmailAsRead(req.Username, req.Password, req.ItemID, req.ChangeKey); err != nil {
 		return c.Status(500).JSON(fiber.Map{"error": err.Error()})
 	}
 
@@ -170,10 +175,6 @@ type DeleteEmailRequest struct {
 
 // DeleteEmail moves an email to deleted items
 func (h *Handler) DeleteEmail(c *fiber.Ctx) error {
-	if h.EWS == nil {
-		return c.Status(503).JSON(fiber.Map{"error": "EWS not configured"})
-	}
-
 	var req DeleteEmailRequest
 	if err := c.BodyParser(&req); err != nil {
 		return c.Status(400).JSON(fiber.Map{"error": "Invalid request body"})
@@ -183,7 +184,12 @@ func (h *Handler) DeleteEmail(c *fiber.Ctx) error {
 		return c.Status(400).JSON(fiber.Map{"error": "username, password, and item_id required"})
 	}
 
-	if err := h.EWS.DeleteEmail(req.Username, req.Password, req.ItemID, req.ChangeKey); err != nil {
+	mail := h.exchange(protocol.CommandDeleteEmail, req.Username)
+	if mail == nil {
+		return c.Status(503).JSON(fiber.Map{"error": "EWS not configured"})
+	}
+
+	if err := mail.DeleteEmail(req.Username, req.Password, req.ItemID, req.ChangeKey); err != nil {
 		return c.Status(500).JSON(fiber.Map{"error": err.Error()})
 	}
 
@@ -200,10 +206,6 @@ type GetEmailBodyRequest struct {
 
 // GetEmailBody returns the full body of an email
 func (h *Handler) GetEmailBody(c *fiber.Ctx) error {
-	if h.EWS == nil {
-		return c.Status(503).JSON(fiber.Map{"error": "EWS not configured"})
-	}
-
 	var req GetEmailBodyRequest
 	if err := c.BodyParser(&req); err != nil {
 		return c.Status(400).JSON(fiber.Map{"error": "Invalid request body"})
@@ -213,7 +215,12 @@ func (h *Handler) GetEmailBody(c *fiber.Ctx) error {
 		return c.Status(400).JSON(fiber.Map{"error": "username, password, and item_id required"})
 	}
 
-	body, err := h.EWS.GetEmailBody(req.Username, req.Password, req.ItemID, req.ChangeKey)
+	mail := h.exchange(protocol.CommandGetEmailBody, req.Username)
+	if mail == nil {
+		return c.Status(503).JSON(fiber.Map{"error": "EWS not configured"})
+	}
+
+	body, err := mail.GetEmailBody(req.Username, req.Password, req.ItemID, req.ChangeKey)
 	if err != nil {
 		return c.Status(500).JSON(fiber.Map{"error": err.Error()})
 	}
@@ -231,10 +238,6 @@ type GetAttachmentsRequest struct {
 
 // GetAttachments returns the list of attachments for an email
 func (h *Handler) GetAttachments(c *fiber.Ctx) error {
-	if h.EWS == nil {
-		return c.Status(503).JSON(fiber.Map{"error": "EWS not configured"})
-	}
-
 	var req GetAttachmentsRequest
 	if err := c.BodyParser(&req); err != nil {
 		return c.Status(400).JSON(fiber.Map{"error": "Invalid request body"})
@@ -244,7 +247,12 @@ func (h *Handler) GetAttachments(c *fiber.Ctx) error {
 		return c.Status(400).JSON(fiber.Map{"error": "username, password, and item_id required"})
 	}
 
-	attachments, err := h.EWS.GetAttachments(req.Username, req.Password, req.ItemID, req.ChangeKey)
+	mail := h.exchange(protocol.CommandGetAttachments, req.Username)
+	if mail == nil {
+		return c.Status(503).JSON(fiber.Map{"error": "EWS not configured"})
+	}
+
+	attachments, err := mail.GetAttachments(req.Username, req.Password, req.ItemID, req.ChangeKey)
 	if err != nil {
 		return c.Status(500).JSON(fiber.Map{"error": err.Error()})
 	}
@@ -261,10 +269,6 @@ type GetAttachmentContentRequest struct {
 
 // GetAttachmentContent returns the content of a specific attachment
 func (h *Handler) GetAttachmentContent(c *fiber.Ctx) error {
-	if h.EWS == nil {
-		return c.Status(503).JSON(fiber.Map{"error": "EWS not configured"})
-	}
-
 	var req GetAttachmentContentRequest
 	if err := c.BodyParser(&req); err != nil {
 		return c.Status(400).JSON(fiber.Map{"error": "Invalid request body"})
@@ -274,7 +278,12 @@ func (h *Handler) GetAttachmentContent(c *fiber.Ctx) error {
 		return c.Status(400).JSON(fiber.Map{"error": "username, password, and attachment_id required"})
 	}
 
-	name, contentType, content, err := h.EWS.GetAttachmentContent(req.Username, req.Password, req.AttachmentID)
+	mail := h.exchange(protocol.CommandGetAttachmentContent, req.Username)
+	if mail == nil {
+		return c.Status(503).JSON(fiber.Map{"error": "EWS not configured"})
+	}
+
+	name, contentType, content, err := mail.GetAttachmentContent(req.Username, req.Password, req.AttachmentID)
 	if err != nil {
 		return c.Status(500).JSON(fiber.Map{"error": err.Error()})
 	}
@@ -297,10 +306,6 @@ type RespondToMeetingRequest struct {
 
 // RespondToMeeting handles Accept/Decline/Tentative for meeting invitations
 func (h *Handler) RespondToMeeting(c *fiber.Ctx) error {
-	if h.EWS == nil {
-		return c.Status(503).JSON(fiber.Map{"error": "EWS not configured"})
-	}
-
 	var req RespondToMeetingRequest
 	if err := c.BodyParser(&req); err != nil {
 		return c.Status(400).JSON(fiber.Map{"error": "Invalid request body"})
@@ -315,7 +320,12 @@ func (h *Handler) RespondToMeeting(c *fiber.Ctx) error {
 		return c.Status(400).JSON(fiber.Map{"error": "response must be Accept, Decline, or Tentative"})
 	}
 
-	err := h.EWS.RespondToMeetingRequest(req.Username, req.Password, req.ItemID, req.ChangeKey, req.Response)
+	mail := h.exchange(protocol.CommandRespondToMeeting, req.Username)
+	if mail == nil {
+		return c.Status(503).JSON(fiber.Map{"error": "EWS not configured"})
+	}
+
+	err := mail.RespondToMeetingRequest(req.Username, req.Password, req.ItemID, req.ChangeKey, req.Response)
 	if err != nil {
 		return c.Status(500).JSON(fiber.Map{"error": err.Error()})
 	}
@@ -0,0 +1,489 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/ekf/one-on-one-backend/internal/models"
+	"github.com/ekf/one-on-one-backend/internal/templates"
+	"github.com/ekf/one-on-one-backend/internal/utils"
+	"github.com/ekf/one-on-one-backend/pkg/ai"
+	"github.com/gofiber/fiber/v2"
+)
+
+const agendaMaxDeliveryAttempts = 3
+
+// agendaPrepareDays is how far ahead calendar sync prepares agendas, so Friday's sync
+// covers Monday while agendas still reflect recent tasks and agreements
+const agendaPrepareDays = 4
+
+// Delivery channels for agendas and digests
+const (
+	channelEmail    = "email"
+	channelTelegram = "telegram"
+)
+
+var errNotOneOnOne = errors.New("не удалось определить сотрудника и руководителя встречи 1-на-1")
+
+// agendaMeeting is the part of a meetings row needed to prepare an agenda
+type agendaMeeting struct {
+	ID          string  `json:"id"`
+	Title       *string `json:"title"`
+	Date        string  `json:"date"`
+	StartTime   *string `json:"start_time"`
+	EmployeeID  *string `json:"employee_id"`
+	OrganizerID *string `json:"organizer_id"`
+	CategoryID  *string `json:"category_id"`
+	ExchangeID  *string `json:"exchange_id"`
+}
+
+const agendaMeetingColumns = "id, title, date, start_time, employee_id, organizer_id, category_id, exchange_id"
+
+// PrepareMeeting generates (or regenerates) the agenda for an upcoming 1-on-1
+func (h *Handler) PrepareMeeting(c *fiber.Ctx) error {
+	if h.DB == nil {
+		return c.Status(500).JSON(fiber.Map{"error": "Database not configured"})
+	}
+	if h.AI == nil || !h.AI.LLMConfigured() {
+		return c.Status(500).JSON(fiber.Map{"error": "AI not configured"})
+	}
+
+	var meeting agendaMeeting
+	if err := h.DB.From("meetings").Select(agendaMeetingColumns).Eq("id", c.Params("id")).Single().Execute(&meeting); err != nil {
+		return c.Status(404).JSON(fiber.Map{"error": "Meeting not found"})
+	}
+
+	employeeID, recipientID, err := h.agendaParticipants(meeting)
+	if err != nil {
+		return c.Status(400).JSON(fiber.Map{"error": err.Error()})
+	}
+
+	userID, _ := c.Locals("user_id").(string)
+	agenda, err := h.generateMeetingAgenda(meeting, employeeID, recipientID, userID)
+	if err != nil {
+		return c.Status(500).JSON(fiber.Map{"error": err.Error()})
+	}
+
+	return c.JSON(agendaResponse(agenda))
+}
+
+// GetMeetingAgenda returns the prepared agenda of a meeting
+func (h *Handler) GetMeetingAgenda(c *fiber.Ctx) error {
+	if h.DB == nil {
+		return c.Status(500).JSON(fiber.Map{"error": "Database not configured"})
+	}
+
+	agenda, err := h.meetingAgenda(c.Params("id"))
+	if err != nil {
+		return c.Status(404).JSON(fiber.Map{"error": "Agenda not prepared"})
+	}
+
+	return c.JSON(agendaResponse(agenda))
+}
+
+// SendMeetingAgenda delivers the prepared agenda now.
+// Channels default to AGENDA_DELIVERY_CHANNELS.
+func (h *Handler) SendMeetingAgenda(c *fiber.Ctx) error {
+	if h.DB == nil {
+		return c.Status(500).JSON(fiber.Map{"error": "Database not configured"})
+	}
+
+	var req struct {
+		Channels []string `json:"channels"`
+	}
+	c.BodyParser(&req)
+	if len(req.Channels) == 0 {
+		req.Channels = parseAgendaChannels(h.Config.AgendaDeliveryChannels)
+	}
+	for _, ch := range req.Channels {
+		if ch != channelEmail && ch != channelTelegram {
+			return c.Status(400).JSON(fiber.Map{"error": "Unknown channel: " + ch + " (use email or telegram)"})
+		}
+	}
+
+	agenda, err := h.meetingAgenda(c.Params("id"))
+	if err != nil {
+		return c.Status(404).JSON(fiber.Map{"error": "Agenda not prepared"})
+	}
+
+	if err := h.deliverMeetingAgenda(agenda, req.Channels); err != nil {
+		return c.Status(502).JSON(fiber.Map{"error": err.Error()})
+	}
+
+	agenda, _ = h.meetingAgenda(agenda.MeetingID)
+	return c.JSON(agendaResponse(agenda))
+}
+
+// syncedMeeting is an upcoming meeting saved by calendar sync. changed is set when the
+// meeting is new or was renamed or moved.
+type syncedMeeting struct {
+	id      string
+	changed bool
+}
+
+// agendaDue reports whether calendar sync should prepare the agenda of a meeting starting at start
+func agendaDue(start string, now time.Time) bool {
+	t, err := time.Parse(time.RFC3339, start)
+	return err == nil && t.After(now) && t.Before(now.AddDate(0, 0, agendaPrepareDays))
+}
+
+// prepareSyncedAgendas generates agendas of 1-on-1s saved by calendar sync: new and changed
+// meetings get a fresh one, others only if they have none yet. Delivered agendas are kept.
+func (h *Handler) prepareSyncedAgendas(meetings []syncedMeeting) {
+	if len(meetings) == 0 || h.AI == nil || !h.AI.LLMConfigured() {
+		return
+	}
+
+	for _, sm := range meetings {
+		if agenda, err := h.meetingAgenda(sm.id); err == nil && (agenda.DeliveredAt != nil || !sm.changed) {
+			continue
+		}
+
+		var m agendaMeeting
+		if err := h.DB.From("meetings").Select(agendaMeetingColumns).Eq("id", sm.id).Single().Execute(&m); err != nil {
+			continue
+		}
+		employeeID, recipientID, err := h.agendaParticipants(m)
+		if err != nil {
+			continue // not a 1-on-1
+		}
+		if _, err := h.generateMeetingAgenda(m, employeeID, recipientID, ""); err != nil {
+			utils.GetLogger().Warn("Failed to prepare meeting agenda", map[string]interface{}{
+				"meeting_id": m.ID,
+				"error":      err.Error(),
+			})
+		}
+	}
+}
+
+// deliverUpcomingAgendas sends the agendas prepared by calendar sync for tomorrow's meetings.
+// Agendas that could not be delivered are reported in the error, so the job run fails.
+func (h *Handler) deliverUpcomingAgendas(ctx context.Context, now time.Time) error {
+	tomorrow := now.AddDate(0, 0, 1).Format("2006-01-02")
+
+	var meetings []agendaMeeting
+	if err := h.DB.From("meetings").Select("id, exchange_id").Eq("date", tomorrow).Execute(&meetings); err != nil {
+		return err
+	}
+
+	ids := make([]string, 0, len(meetings))
+	for _, m := range meetings {
+		if m.ExchangeID != nil {
+			ids = append(ids, m.ID)
+		}
+	}
+	if len(ids) == 0 {
+		return nil
+	}
+
+	var agendas []models.MeetingAgenda
+	if err := h.DB.From("meeting_agendas").Select("*").In("meeting_id", ids).IsNull("delivered_at").Execute(&agendas); err != nil {
+		return err
+	}
+
+	var failed []string
+	channels := parseAgendaChannels(h.Config.AgendaDeliveryChannels)
+	for i := range agendas {
+		agenda := &agendas[i]
+		if agenda.DeliveryAttempts >= agendaMaxDeliveryAttempts {
+			continue
+		}
+		if ctx.Err() != nil {
+			return ctx.Err()
+		}
+		if err := h.deliverMeetingAgenda(agenda, channels); err != nil {
+			failed = append(failed, agenda.MeetingID+": "+err.Error())
+		}
+	}
+
+	if len(failed) > 0 {
+		return fmt.Errorf("agenda delivery failed for %d meetings: %s", len(failed), truncate(strings.Join(failed, "; "), 1000))
+	}
+	return nil
+}
+
+// agendaParticipants returns the employee the 1-on-1 is with and the manager who receives
+// the agenda. Synced meetings have no employee_id, so a meeting with exactly one participant
+// besides the organizer is treated as a 1-on-1 with that participant.
+func (h *Handler) agendaParticipants(m agendaMeeting) (string, string, error) {
+	if m.CategoryID != nil && h.categoryCodes()[*m.CategoryID] != "one_on_one" {
+		return "", "", errNotOneOnOne
+	}
+
+	organizerID := ""
+	if m.OrganizerID != nil {
+		organizerID = *m.OrganizerID
+	}
+
+	employeeID := ""
+	if m.EmployeeID != nil {
+		employeeID = *m.EmployeeID
+	} else {
+		var participants []models.MeetingParticipant
+		h.DB.From("meeting_participants").Select("employee_id").Eq("meeting_id", m.ID).Execute(&participants)
+
+		others := make(map[string]bool)
+		for _, p := range participants {
+			if p.EmployeeID != organizerID {
+				others[p.EmployeeID] = true
+			}
+		}
+		if len(others) == 1 {
+			for id := range others {
+				employeeID = id
+			}
+		}
+	}
+	if employeeID == "" {
+		return "", "", errNotOneOnOne
+	}
+
+	recipientID := organizerID
+	if recipientID == "" {
+		var employee models.Employee
+		h.DB.From("employees").Select("id, manager_id").Eq("id", employeeID).Single().Execute(&employee)
+		if employee.ManagerID != nil {
+			recipientID = *employee.ManagerID
+		}
+	}
+	if recipientID == "" || recipientID == employeeID {
+		return "", "", errNotOneOnOne
+	}
+
+	return employeeID, recipientID, nil
+}
+
+// generateMeetingAgenda builds the agenda from employee context, history, open agreements
+// and tasks, and stores it. Delivery state of an existing agenda is kept.
+func (h *Handler) generateMeetingAgenda(m agendaMeeting, employeeID, recipientID, userID string) (*models.MeetingAgenda, error) {
+	date := m.Date[:min(10, len(m.Date))]
+	when := date
+	if m.StartTime != nil {
+		if start, err := time.Parse(time.RFC3339, *m.StartTime); err == nil {
+			when += " " + start.Format("15:04")
+		}
+	}
+	title := "Встреча 1-на-1"
+	if m.Title != nil && *m.Title != "" {
+		title = *m.Title
+	}
+
+	content, run, err := h.AI.GenerateAgenda(ai.AgendaContext{
+		MeetingTitle:      title,
+		MeetingDate:       when,
+		EmployeeContext:   h.getEmployeeContext(employeeID),
+		MeetingsHistory:   h.getEmployeeMeetingsHistory(employeeID, date, m.ID),
+		OpenTasks:         h.agendaOpenTasks(employeeID),
+		PreviousQuestions: h.previousQuestions(employeeID, date, m.ID),
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	contentJSON, _ := json.Marshal(content)
+	_, err = h.DB.Upsert("meeting_agendas", []map[string]interface{}{{
+		"meeting_id":        m.ID,
+		"employee_id":       employeeID,
+		"recipient_id":      recipientID,
+		"agenda":            string(contentJSON),
+		"analysis_provider": run.Provider,
+		"analysis_model":    run.Model,
+		"generated_by":      nilIfEmpty(userID),
+		"generated_at":      time.Now(),
+	}}, "meeting_id")
+	if err != nil {
+		return nil, err
+	}
+
+	return h.meetingAgenda(m.ID)
+}
+
+// agendaOpenTasks lists unfinished tasks of the employee, overdue first
+func (h *Handler) agendaOpenTasks(employeeID string) string {
+	var tasks []models.Task
+	h.DB.From("tasks").Select("title, status, due_date").Eq("assignee_id", employeeID).
+		Order("due_date", false).Execute(&tasks)
+
+	today := getCurrentDate()
+	var overdue, other []string
+	for _, t := range tasks {
+		if t.Status == "done" {
+			continue
+		}
+		line := "- " + t.Title + " (" + t.Status
+		if t.DueDate != nil {
+			line += ", срок " + (*t.DueDate)[:min(10, len(*t.DueDate))]
+		}
+		line += ")"
+		if t.DueDate != nil && *t.DueDate < today {
+			overdue = append(overdue, line+" — ПРОСРОЧЕНО")
+		} else {
+			other = append(other, line)
+		}
+	}
+
+	lines := append(overdue, other...)
+	if len(lines) == 0 {
+		return "Открытых задач нет"
+	}
+	if len(lines) > 20 {
+		lines = append(lines[:20], "... и ещё "+itoa(len(lines)-20))
+	}
+	return strings.Join(lines, "\n")
+}
+
+// previousQuestions returns questions suggested by the analysis of the last meeting before date
+func (h *Handler) previousQuestions(employeeID, beforeDate, excludeMeetingID string) string {
+	var meetings []storedAnalysis
+	h.DB.From("meetings").Select("id, analysis").Eq("employee_id", employeeID).
+		Lte("date", beforeDate).Order("date", true).Limit(2).Execute(&meetings)
+
+	for _, m := range meetings {
+		if m.ID == excludeMeetingID || m.Analysis == nil {
+			continue
+		}
+		var analysis map[string]interface{}
+		if json.Unmarshal([]byte(*m.Analysis), &analysis) != nil {
+			continue
+		}
+
+		var lines []string
+		for _, key := range []string{"questions_to_ask", "open_questions"} {
+			items, _ := analysis[key].([]interface{})
+			for _, item := range items {
+				if q, ok := item.(string); ok && q != "" {
+					lines = append(lines, "- "+q)
+				}
+			}
+		}
+		return strings.Join(lines, "\n")
+	}
+	return ""
+}
+
+// deliverMeetingAgenda sends the agenda to its recipient over the given channels and records
+// the outcome. The agenda counts as delivered when at least one channel succeeded.
+func (h *Handler) deliverMeetingAgenda(agenda *models.MeetingAgenda, channels []string) error {
+	if agenda.RecipientID == nil {
+		return fmt.Errorf("agenda has no recipient")
+	}
+
+	var recipient models.Employee
+	if err := h.DB.From("employees").Select("id, name, email, telegram_chat_id, locale").Eq("id", *agenda.RecipientID).Single().Execute(&recipient); err != nil {
+		return fmt.Errorf("recipient not found")
+	}
+
+	var meeting agendaMeeting
+	h.DB.From("meetings").Select(agendaMeetingColumns).Eq("id", agenda.MeetingID).Single().Execute(&meeting)
+
+	var employee models.Employee
+	if agenda.EmployeeID != nil {
+		h.DB.From("employees").Select("id, name").Eq("id", *agenda.EmployeeID).Single().Execute(&employee)
+	}
+
+	var content map[string]interface{}
+	json.Unmarshal([]byte(agenda.Agenda), &content)
+	data := map[string]interface{}{
+		"employee_name": employee.Name,
+		"date":          meeting.Date,
+		"meeting_title": stringValue(meeting.Title),
+		"content":       content,
+		"link":          "/meetings/" + agenda.MeetingID,
+	}
+
+	delivered, errs := h.sendToEmployee(recipient, "meeting.agenda", data, channels)
+
+	updates := map[string]interface{}{
+		"delivery_attempts": agenda.DeliveryAttempts + 1,
+		"delivery_error":    nilIfEmpty(strings.Join(errs, "; ")),
+	}
+	if len(delivered) > 0 {
+		updates["delivered_at"] = time.Now()
+		updates["delivery_channels"] = strings.Join(delivered, ",")
+	}
+	h.DB.Update("meeting_agendas", "id", agenda.ID, updates)
+
+	if len(delivered) == 0 {
+		return fmt.Errorf("agenda not delivered: %s", strings.Join(errs, "; "))
+	}
+	return nil
+}
+
+// sendToEmployee renders a template in the employee's language and sends it over the given
+// channels (email via the EWS service account, Telegram via the bot). It returns the
+// channels that succeeded and the errors of the others.
+func (h *Handler) sendToEmployee(recipient models.Employee, template string, data map[string]interface{}, channels []string) ([]string, []string) {
+	var delivered, errs []string
+	for _, ch := range channels {
+		var err error
+		var msg templates.Rendered
+		switch ch {
+		case channelEmail:
+			if recipient.Email == "" {
+				err = fmt.Errorf("recipient has no email")
+			} else if msg, err = h.Templates.Render(template, recipient.Locale, templates.Email, data); err == nil {
+				err = h.sendServiceEmail(msg.Subject, []string{recipient.Email}, msg.Body)
+			}
+		case channelTelegram:
+			switch {
+			case h.Telegram == nil || h.Config.TelegramBotToken == "":
+				err = fmt.Errorf("Telegram bot not configured")
+			case recipient.TelegramChatID == nil:
+				err = fmt.Errorf("recipient has no linked Telegram")
+			default:
+				if msg, err = h.Templates.Render(template, recipient.Locale, templates.Telegram, data); err == nil {
+					err = h.Telegram.SendMessage(*recipient.TelegramChatID, msg.Body)
+				}
+			}
+		default:
+			err = fmt.Errorf("unknown channel")
+		}
+		if err != nil {
+			errs = append(errs, ch+": "+err.Error())
+		} else {
+			delivered = append(delivered, ch)
+		}
+	}
+	return delivered, errs
+}
+
+func (h *Handler) meetingAgenda(meetingID string) (*models.MeetingAgenda, error) {
+	var agenda models.MeetingAgenda
+	if err := h.DB.From("meeting_agendas").Select("*").Eq("meeting_id", meetingID).Single().Execute(&agenda); err != nil {
+		return nil, err
+	}
+	return &agenda, nil
+}
+
+func agendaResponse(agenda *models.MeetingAgenda) fiber.Map {
+	var content map[string]interface{}
+	json.Unmarshal([]byte(agenda.Agenda), &content)
+	return fiber.Map{
+		"id":                agenda.ID,
+		"meeting_id":        agenda.MeetingID,
+		"employee_id":       agenda.EmployeeID,
+		"recipient_id":      agenda.RecipientID,
+		"agenda":            content,
+		"analysis_provider": agenda.AnalysisProvider,
+		"analysis_model":    agenda.AnalysisModel,
+		"generated_by":      agenda.GeneratedBy,
+		"generated_at":      agenda.GeneratedAt,
+		"delivered_at":      agenda.DeliveredAt,
+		"delivery_channels": agenda.DeliveryChannels,
+		"delivery_error":    agenda.DeliveryError,
+	}
+}
+
+func parseAgendaChannels(value string) []string {
+	var channels []string
+	for _, ch := range strings.Split(value, ",") {
+		if ch = strings.TrimSpace(strings.ToLower(ch)); ch != "" {
+			channels = append(channels, ch)
+		}
+	}
+	return channels
+}
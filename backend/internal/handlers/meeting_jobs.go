@@ -0,0 +1,904 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/ekf/one-on-one-backend/internal/database"
+	"github.com/ekf/one-on-one-backend/internal/events"
+	"github.com/ekf/one-on-one-backend/internal/models"
+	"github.com/ekf/one-on-one-backend/internal/storage"
+	"github.com/ekf/one-on-one-backend/internal/utils"
+	"github.com/ekf/one-on-one-backend/pkg/ai"
+	"github.com/gofiber/fiber/v2"
+	"github.com/google/uuid"
+)
+
+// Meeting processing stages. One transcribe_<provider> stage runs per selected
+// speech-to-text provider, followed by the fixed stages in this order.
+const (
+	stageTranscribePrefix = "transcribe_"
+	stageDiarize          = "diarize"
+	stageMerge            = "merge"
+	stageAnalyze          = "analyze"
+	stageSave             = "save"
+)
+
+// meetingJobStages returns the stage list for the given transcriber names
+func meetingJobStages(transcribers []string) []string {
+	var stages []string
+	for _, name := range transcribers {
+		stages = append(stages, stageTranscribePrefix+name)
+	}
+	return append(stages, stageDiarize, stageMerge, stageAnalyze, stageSave)
+}
+
+// isOptionalStage reports whether a job can continue after the stage fails:
+// merge uses whatever transcripts are available, and without diarization the
+// meeting is saved without speaker segments.
+func isOptionalStage(stage string) bool {
+	return strings.HasPrefix(stage, stageTranscribePrefix) || stage == stageDiarize
+}
+
+const (
+	meetingJobsDir         = "/tmp/meeting_jobs" // Local audio storage when MinIO is not configured
+	meetingJobQueueSize    = 100
+	maxStageAttempts       = 3
+	stageRetryBaseDelay    = 5 * time.Second
+	meetingJobProgressType = "meeting_job_progress"
+)
+
+// errNoTranscript is returned by the merge stage when all transcriptions failed.
+// Retrying merge cannot help, so the job fails immediately.
+var errNoTranscript = errors.New("no transcript available")
+
+// errStageSkipped marks a stage that does not apply to the job (e.g. no provider
+// among the selected ones supports diarization)
+var errStageSkipped = errors.New("stage skipped")
+
+// meetingJobState carries stage outputs between stages of one job run
+type meetingJobState struct {
+	job         *models.MeetingJob
+	params      models.MeetingJobParams
+	audioFile   string
+	transcripts map[string]string // provider name -> transcript
+	segments    []ai.TranscriptSegment
+	merged      string
+	analysis    map[string]interface{}
+	prompt      *models.PromptTemplate // nil - built-in prompt
+	analysisRun ai.AnalysisRun
+	meetingID   string
+	errors      []string
+}
+
+// actorID returns the user the job runs for, empty if unknown
+func (s *meetingJobState) actorID() string {
+	if s.job.CreatedBy == nil {
+		return ""
+	}
+	return *s.job.CreatedBy
+}
+
+// analysisStageOutput is stored as the analyze stage output so the prompt version
+// survives restarts between analysis and save
+type analysisStageOutput struct {
+	Analysis map[string]interface{} `json:"analysis"`
+	Prompt   *models.PromptTemplate `json:"prompt,omitempty"`
+	Run      ai.AnalysisRun         `json:"run"`
+}
+
+// startMeetingJobWorkers starts background workers and re-queues unfinished jobs
+// Zero workers disables processing in this process (used by one-off commands).
+func (h *Handler) startMeetingJobWorkers(workers int) {
+	if h.DB == nil || workers < 1 {
+		return
+	}
+
+	h.meetingJobs = make(chan string, meetingJobQueueSize)
+	for i := 0; i < workers; i++ {
+		go h.meetingJobWorker()
+	}
+
+	// Jobs left in queued/running state were interrupted by a restart
+	go func() {
+		var pending []models.MeetingJob
+		h.DB.From("meeting_jobs").Select("id").In("status", []string{"queued", "running"}).
+			Order("created_at", false).Execute(&pending)
+		for _, job := range pending {
+			h.enqueueMeetingJob(job.ID)
+		}
+	}()
+}
+
+func (h *Handler) enqueueMeetingJob(jobID string) {
+	if h.meetingJobs == nil {
+		return
+	}
+	go func() { h.meetingJobs <- jobID }()
+}
+
+func (h *Handler) meetingJobWorker() {
+	for jobID := range h.meetingJobs {
+		h.runMeetingJob(jobID)
+	}
+}
+
+// ProcessMeeting accepts an audio file and queues it for background processing
+func (h *Handler) ProcessMeeting(c *fiber.Ctx) error {
+	if h.DB == nil {
+		return c.Status(500).JSON(fiber.Map{"error": "Database not configured"})
+	}
+
+	userID, _ := c.Locals("user_id").(string)
+
+	// Parse form
+	params := models.MeetingJobParams{
+		CategoryCode: c.FormValue("category_code", "one_on_one"),
+		EmployeeID:   c.FormValue("employee_id"),
+		ProjectID:    c.FormValue("project_id"),
+		MeetingDate:  c.FormValue("meeting_date"),
+		Title:        c.FormValue("title"),
+	}
+	json.Unmarshal([]byte(c.FormValue("participant_ids", "[]")), &params.ParticipantIDs)
+
+	if params.MeetingDate == "" {
+		params.MeetingDate = getCurrentDate()
+	}
+
+	// Get uploaded file
+	file, err := c.FormFile("file")
+	if err != nil {
+		return c.Status(400).JSON(fiber.Map{"error": "Audio file required"})
+	}
+
+	// Check if AI client is configured
+	if h.AI == nil {
+		return c.Status(500).JSON(fiber.Map{
+			"error":   "AI client not configured",
+			"details": "AI клиент не инициализирован. Проверьте переменные окружения.",
+			"hint":    "Установите OPENAI_API_KEY или YANDEX_API_KEY + YANDEX_FOLDER_ID",
+		})
+	}
+
+	// Resolve speech-to-text providers for the category now, so retries use the same ones
+	for _, t := range h.AI.TranscribersFor(params.CategoryCode) {
+		params.Transcribers = append(params.Transcribers, t.Name())
+	}
+	if len(params.Transcribers) == 0 {
+		return c.Status(500).JSON(fiber.Map{
+			"error":     "Transcription not configured",
+			"details":   "Нет настроенных провайдеров распознавания речи для категории " + params.CategoryCode,
+			"hint":      "Проверьте STT_PROVIDERS / STT_CATEGORY_PROVIDERS и ключи провайдеров",
+			"providers": h.AI.TranscriberNamesFor(params.CategoryCode),
+		})
+	}
+
+	jobID := uuid.New().String()
+	ext := strings.ToLower(filepath.Ext(file.Filename))
+
+	// Persist audio so the job survives restarts and can be retried
+	audioStorage := "local"
+	var audioPath string
+	if h.Storage != nil {
+		src, err := file.Open()
+		if err != nil {
+			return c.Status(500).JSON(fiber.Map{"error": "Failed to open uploaded file"})
+		}
+		defer src.Close()
+
+		audioStorage = "minio"
+		audioPath = storage.GeneratePath("meeting_jobs", jobID, jobID, ext)
+		contentType := file.Header.Get("Content-Type")
+		if _, err := h.Storage.Upload(context.Background(), audioPath, src, file.Size, contentType); err != nil {
+			return c.Status(500).JSON(fiber.Map{"error": "Failed to store audio file", "details": err.Error()})
+		}
+	} else {
+		if err := os.MkdirAll(meetingJobsDir, 0755); err != nil {
+			return c.Status(500).JSON(fiber.Map{"error": "Failed to create jobs directory"})
+		}
+		audioPath = filepath.Join(meetingJobsDir, jobID+ext)
+		if err := c.SaveFile(file, audioPath); err != nil {
+			return c.Status(500).JSON(fiber.Map{"error": "Failed to save file"})
+		}
+	}
+
+	// A job without its stages would never run, so both are created together
+	paramsJSON, _ := json.Marshal(params)
+	err = h.DB.WithTx(c.UserContext(), func(tx database.DBClient) error {
+		_, err := tx.Insert("meeting_jobs", map[string]interface{}{
+			"id":            jobID,
+			"status":        "queued",
+			"progress":      0,
+			"params":        string(paramsJSON),
+			"audio_path":    audioPath,
+			"audio_storage": audioStorage,
+			"created_by":    nilIfEmpty(userID),
+		})
+		if err != nil {
+			return err
+		}
+
+		for i, stage := range meetingJobStages(params.Transcribers) {
+			_, err := tx.Insert("meeting_job_stages", map[string]interface{}{
+				"job_id":   jobID,
+				"stage":    stage,
+				"position": i,
+				"status":   "pending",
+			})
+			if err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		h.removeMeetingJobAudio(audioStorage, audioPath)
+		return c.Status(500).JSON(fiber.Map{"error": "Failed to create job", "details": err.Error()})
+	}
+
+	h.enqueueMeetingJob(jobID)
+
+	return c.Status(202).JSON(fiber.Map{
+		"job_id": jobID,
+		"status": "queued",
+	})
+}
+
+// ListMeetingJobs returns processing jobs of the current user
+func (h *Handler) ListMeetingJobs(c *fiber.Ctx) error {
+	if h.DB == nil {
+		return c.Status(500).JSON(fiber.Map{"error": "Database not configured"})
+	}
+
+	userID, _ := c.Locals("user_id").(string)
+	query := h.DB.From("meeting_jobs").Select("*").Eq("created_by", userID)
+
+	if status := c.Query("status"); status != "" {
+		query = query.Eq("status", status)
+	}
+
+	var jobs []models.MeetingJob
+	err := query.Order("created_at", true).Limit(c.QueryInt("limit", 50)).Execute(&jobs)
+	if err != nil {
+		return c.Status(500).JSON(fiber.Map{"error": err.Error()})
+	}
+
+	if jobs == nil {
+		jobs = []models.MeetingJob{}
+	}
+	return c.JSON(jobs)
+}
+
+// GetMeetingJob returns a processing job with its stages
+func (h *Handler) GetMeetingJob(c *fiber.Ctx) error {
+	if h.DB == nil {
+		return c.Status(500).JSON(fiber.Map{"error": "Database not configured"})
+	}
+
+	job, err := h.loadMeetingJob(c.Params("id"))
+	if err != nil {
+		return c.Status(404).JSON(fiber.Map{"error": "Job not found"})
+	}
+
+	userID, _ := c.Locals("user_id").(string)
+	if job.CreatedBy != nil && *job.CreatedBy != userID {
+		return c.Status(403).JSON(fiber.Map{"error": "Access denied"})
+	}
+
+	return c.JSON(job)
+}
+
+// RetryMeetingJob re-queues a failed job, re-running only the stages that did not complete
+func (h *Handler) RetryMeetingJob(c *fiber.Ctx) error {
+	if h.DB == nil {
+		return c.Status(500).JSON(fiber.Map{"error": "Database not configured"})
+	}
+
+	job, err := h.loadMeetingJob(c.Params("id"))
+	if err != nil {
+		return c.Status(404).JSON(fiber.Map{"error": "Job not found"})
+	}
+
+	userID, _ := c.Locals("user_id").(string)
+	if job.CreatedBy != nil && *job.CreatedBy != userID {
+		return c.Status(403).JSON(fiber.Map{"error": "Access denied"})
+	}
+	if job.Status != "failed" {
+		return c.Status(400).JSON(fiber.Map{"error": "Only failed jobs can be retried"})
+	}
+
+	for _, stage := range job.Stages {
+		if stage.Status == "failed" {
+			h.DB.Update("meeting_job_stages", "id", stage.ID, map[string]interface{}{
+				"status":   "pending",
+				"attempts": 0,
+				"error":    nil,
+			})
+		}
+	}
+
+	h.DB.Update("meeting_jobs", "id", job.ID, map[string]interface{}{
+		"status":     "queued",
+		"error":      nil,
+		"updated_at": time.Now(),
+	})
+	h.enqueueMeetingJob(job.ID)
+
+	return c.JSON(fiber.Map{"job_id": job.ID, "status": "queued"})
+}
+
+// meetingJobRow is a meeting_jobs row with the audio location, which the model hides
+// from API responses
+type meetingJobRow struct {
+	models.MeetingJob
+	AudioPath    string `json:"audio_path"`
+	AudioStorage string `json:"audio_storage"`
+}
+
+// meetingJobStageRow is a meeting_job_stages row with the stage output
+type meetingJobStageRow struct {
+	models.MeetingJobStage
+	Output *string `json:"output"`
+}
+
+func (h *Handler) loadMeetingJob(jobID string) (*models.MeetingJob, error) {
+	var row meetingJobRow
+	if err := h.DB.From("meeting_jobs").Select("*").Eq("id", jobID).Single().Execute(&row); err != nil {
+		return nil, err
+	}
+	job := row.MeetingJob
+	job.AudioPath = row.AudioPath
+	job.AudioStorage = row.AudioStorage
+
+	var stages []meetingJobStageRow
+	if err := h.DB.From("meeting_job_stages").Select("*").Eq("job_id", jobID).Order("position", false).Execute(&stages); err != nil {
+		return nil, err
+	}
+	for _, stage := range stages {
+		stage.MeetingJobStage.Output = stage.Output
+		job.Stages = append(job.Stages, stage.MeetingJobStage)
+	}
+	return &job, nil
+}
+
+// runMeetingJob executes all unfinished stages of a job
+func (h *Handler) runMeetingJob(jobID string) {
+	log := utils.GetLogger()
+
+	// Every replica re-queues interrupted jobs at startup; the lock makes sure a job
+	// still running on another replica is not run twice
+	if locker, ok := h.DB.(database.AdvisoryLocker); ok {
+		unlock, acquired, err := locker.TryAdvisoryLock(context.Background(), "meeting_job:"+jobID)
+		if err != nil {
+			log.Warn("Failed to lock meeting job", map[string]interface{}{"job_id": jobID, "error": err.Error()})
+			return
+		}
+		if !acquired {
+			return // running on another replica
+		}
+		defer unlock()
+	}
+
+	job, err := h.loadMeetingJob(jobID)
+	if err != nil || job.Status == "completed" {
+		return
+	}
+
+	state := &meetingJobState{job: job, transcripts: make(map[string]string)}
+	json.Unmarshal([]byte(job.Params), &state.params)
+
+	if job.MeetingID != nil {
+		state.meetingID = *job.MeetingID
+	}
+
+	h.updateMeetingJob(state, map[string]interface{}{"status": "running"})
+	defer func() {
+		if state.audioFile != "" && job.AudioStorage == "minio" {
+			os.Remove(state.audioFile)
+		}
+	}()
+
+	for i := range job.Stages {
+		stage := &job.Stages[i]
+
+		if stage.Status == "completed" || stage.Status == "skipped" {
+			restoreStageOutput(state, stage)
+			continue
+		}
+
+		h.updateMeetingJob(state, map[string]interface{}{"current_stage": stage.Stage})
+
+		output, err := h.runStageWithRetry(state, stage)
+		if err != nil {
+			log.Warn("Meeting job stage failed", map[string]interface{}{
+				"job_id":   jobID,
+				"stage":    stage.Stage,
+				"attempts": stage.Attempts,
+				"error":    err.Error(),
+			})
+
+			if isOptionalStage(stage.Stage) {
+				state.errors = append(state.errors, stage.Stage+": "+err.Error())
+				continue
+			}
+
+			h.updateMeetingJob(state, map[string]interface{}{
+				"status": "failed",
+				"error":  err.Error(),
+			})
+			return
+		}
+
+		stage.Output = &output
+		restoreStageOutput(state, stage)
+		h.updateMeetingJob(state, map[string]interface{}{
+			"progress": (i + 1) * 100 / len(job.Stages),
+		})
+	}
+
+	h.removeMeetingJobAudio(job.AudioStorage, job.AudioPath)
+	h.updateMeetingJob(state, map[string]interface{}{
+		"status":        "completed",
+		"progress":      100,
+		"current_stage": nil,
+		"meeting_id":    nilIfEmpty(state.meetingID),
+		"completed_at":  time.Now(),
+	})
+}
+
+// runStageWithRetry runs a stage up to maxStageAttempts times with linear backoff
+func (h *Handler) runStageWithRetry(state *meetingJobState, stage *models.MeetingJobStage) (string, error) {
+	lastErr := fmt.Errorf("stage %s exhausted retries", stage.Stage)
+	if stage.Error != nil {
+		lastErr = errors.New(*stage.Error)
+	}
+
+	for stage.Attempts < maxStageAttempts {
+		stage.Attempts++
+		h.DB.Update("meeting_job_stages", "id", stage.ID, map[string]interface{}{
+			"status":     "running",
+			"attempts":   stage.Attempts,
+			"started_at": time.Now(),
+		})
+
+		output, err := h.runStage(state, stage.Stage)
+		if errors.Is(err, errStageSkipped) {
+			h.DB.Update("meeting_job_stages", "id", stage.ID, map[string]interface{}{
+				"status":      "skipped",
+				"finished_at": time.Now(),
+			})
+			stage.Status = "skipped"
+			return "", nil
+		}
+		if err == nil {
+			h.DB.Update("meeting_job_stages", "id", stage.ID, map[string]interface{}{
+				"status":      "completed",
+				"output":      output,
+				"error":       nil,
+				"finished_at": time.Now(),
+			})
+			stage.Status = "completed"
+			return output, nil
+		}
+
+		lastErr = err
+		if errors.Is(err, errNoTranscript) {
+			break
+		}
+		if stage.Attempts < maxStageAttempts {
+			time.Sleep(stageRetryBaseDelay * time.Duration(stage.Attempts))
+		}
+	}
+
+	h.DB.Update("meeting_job_stages", "id", stage.ID, map[string]interface{}{
+		"status":      "failed",
+		"error":       lastErr.Error(),
+		"finished_at": time.Now(),
+	})
+	stage.Status = "failed"
+	return "", lastErr
+}
+
+func (h *Handler) runStage(state *meetingJobState, stage string) (string, error) {
+	if name, ok := strings.CutPrefix(stage, stageTranscribePrefix); ok {
+		transcriber, ok := h.AI.Transcriber(name)
+		if !ok {
+			return "", fmt.Errorf("speech-to-text provider %q is not registered", name)
+		}
+		audioFile, err := h.meetingJobAudioFile(state)
+		if err != nil {
+			return "", err
+		}
+		return transcriber.Transcribe(audioFile)
+	}
+
+	switch stage {
+	case stageDiarize:
+		// Only providers selected for the job are used, so on-prem recordings stay on-prem
+		var segmenter ai.SegmentTranscriber
+		for _, name := range state.params.Transcribers {
+			if t, ok := h.AI.Transcriber(name); ok && t.Configured() {
+				if st, ok := t.(ai.SegmentTranscriber); ok {
+					segmenter = st
+					break
+				}
+			}
+		}
+		if segmenter == nil {
+			return "", errStageSkipped
+		}
+
+		audioFile, err := h.meetingJobAudioFile(state)
+		if err != nil {
+			return "", err
+		}
+		segments, err := segmenter.TranscribeSegments(audioFile)
+		if err != nil {
+			return "", err
+		}
+		data, err := json.Marshal(segments)
+		if err != nil {
+			return "", err
+		}
+		return string(data), nil
+
+	case stageMerge:
+		var available []string
+		for _, name := range state.params.Transcribers {
+			if text := state.transcripts[name]; text != "" {
+				available = append(available, text)
+			}
+		}
+		if len(available) >= 2 {
+			merged, err := h.AI.MergeTranscripts(available[0], available[1])
+			if err != nil || merged == "" {
+				return available[0], nil
+			}
+			return merged, nil
+		}
+		if len(available) == 1 {
+			return available[0], nil
+		}
+		details := "Транскрипция не удалась"
+		if len(state.errors) > 0 {
+			details += ". Ошибки: " + strings.Join(state.errors, "; ")
+		}
+		return "", fmt.Errorf("%w: %s", errNoTranscript, details)
+
+	case stageAnalyze:
+		prompt := h.activePromptTemplate(state.params.CategoryCode)
+		promptTemplate := ai.DefaultPrompt(state.params.CategoryCode)
+		if prompt != nil {
+			promptTemplate = prompt.Template
+		}
+
+		analysis, run, err := h.AI.AnalyzeWithProviders(state.params.CategoryCode, promptTemplate,
+			h.buildAnalysisContext(state.params, "", state.merged, state.segments), nil)
+		if err != nil {
+			return "", err
+		}
+		data, err := json.Marshal(analysisStageOutput{Analysis: analysis, Prompt: prompt, Run: run})
+		if err != nil {
+			return "", err
+		}
+		return string(data), nil
+
+	case stageSave:
+		return h.saveProcessedMeeting(state)
+	}
+
+	return "", fmt.Errorf("unknown stage: %s", stage)
+}
+
+// restoreStageOutput copies a completed stage output into the job state
+func restoreStageOutput(state *meetingJobState, stage *models.MeetingJobStage) {
+	if stage.Output == nil {
+		return
+	}
+	if name, ok := strings.CutPrefix(stage.Stage, stageTranscribePrefix); ok {
+		state.transcripts[name] = *stage.Output
+		return
+	}
+
+	switch stage.Stage {
+	case stageDiarize:
+		json.Unmarshal([]byte(*stage.Output), &state.segments)
+	case stageMerge:
+		state.merged = *stage.Output
+	case stageAnalyze:
+		var output analysisStageOutput
+		json.Unmarshal([]byte(*stage.Output), &output)
+		state.analysis = output.Analysis
+		state.prompt = output.Prompt
+		state.analysisRun = output.Run
+	case stageSave:
+		state.meetingID = *stage.Output
+	}
+}
+
+// meetingJobAudioFile returns a local path to the job recording, downloading it from MinIO if needed
+func (h *Handler) meetingJobAudioFile(state *meetingJobState) (string, error) {
+	if state.audioFile != "" {
+		return state.audioFile, nil
+	}
+
+	if state.job.AudioStorage != "minio" {
+		state.audioFile = state.job.AudioPath
+		return state.audioFile, nil
+	}
+
+	if h.Storage == nil {
+		return "", fmt.Errorf("storage not configured")
+	}
+
+	data, _, err := h.Storage.Download(context.Background(), state.job.AudioPath)
+	if err != nil {
+		return "", err
+	}
+
+	tmpFile, err := os.CreateTemp("", "audio-*"+filepath.Ext(state.job.AudioPath))
+	if err != nil {
+		return "", err
+	}
+	defer tmpFile.Close()
+
+	if _, err := tmpFile.Write(data); err != nil {
+		os.Remove(tmpFile.Name())
+		return "", err
+	}
+
+	state.audioFile = tmpFile.Name()
+	return state.audioFile, nil
+}
+
+func (h *Handler) removeMeetingJobAudio(audioStorage, audioPath string) {
+	if audioStorage == "minio" {
+		if h.Storage != nil {
+			h.Storage.Delete(context.Background(), audioPath)
+		}
+		return
+	}
+	os.Remove(audioPath)
+}
+
+// buildAnalysisContext collects employee/project history for the analysis prompt.
+// History is limited to meetings up to params.MeetingDate; meetingID is set when
+// an existing meeting is analyzed again.
+func (h *Handler) buildAnalysisContext(params models.MeetingJobParams, meetingID, transcript string, segments []ai.TranscriptSegment) ai.AnalysisContext {
+	ctx := ai.AnalysisContext{
+		Transcript: transcript,
+	}
+
+	if len(segments) > 0 {
+		ctx.SpeakerTranscript = ai.FormatSegments(segments, nil)
+		ctx.SpeakerStats = ai.FormatSpeakerStats(segments, nil)
+	}
+
+	if params.EmployeeID != "" {
+		ctx.EmployeeContext = h.getEmployeeContext(params.EmployeeID)
+		ctx.MeetingsHistory = h.getEmployeeMeetingsHistory(params.EmployeeID, params.MeetingDate, meetingID)
+	}
+	if params.ProjectID != "" {
+		ctx.ProjectContext = h.getProjectContext(params.ProjectID)
+		if ctx.MeetingsHistory == "" {
+			ctx.MeetingsHistory = h.getProjectMeetingsHistory(params.ProjectID, params.MeetingDate, meetingID)
+		}
+	}
+	if len(params.ParticipantIDs) > 0 {
+		ctx.Participants = h.getParticipantsInfo(params.ParticipantIDs)
+	}
+
+	return ctx
+}
+
+// saveProcessedMeeting creates the meeting with participants, agreements and tasks
+func (h *Handler) saveProcessedMeeting(state *meetingJobState) (string, error) {
+	// A resumed job already has the meeting if the save committed before the stage was marked done
+	if state.meetingID != "" {
+		return state.meetingID, nil
+	}
+
+	params := state.params
+	analysis := state.analysis
+	analysisJSON, _ := json.Marshal(analysis)
+
+	// Get category ID
+	var category models.MeetingCategory
+	h.DB.From("meeting_categories").Select("id").Eq("code", params.CategoryCode).Single().Execute(&category)
+
+	meetingData := map[string]interface{}{
+		"title":              params.Title,
+		"employee_id":        nilIfEmpty(params.EmployeeID),
+		"project_id":         nilIfEmpty(params.ProjectID),
+		"category_id":        nilIfEmpty(category.ID),
+		"date":               params.MeetingDate,
+		"transcript_whisper": state.transcripts[ai.TranscriberWhisper],
+		"transcript_yandex":  state.transcripts[ai.TranscriberYandex],
+		"transcript_merged":  state.merged,
+		"transcript":         state.merged,
+		"summary":            analysis["summary"],
+		"mood_score":         analysis["mood_score"],
+		"analysis":           string(analysisJSON),
+		"analysis_provider":  nilIfEmpty(state.analysisRun.Provider),
+		"analysis_model":     nilIfEmpty(state.analysisRun.Model),
+	}
+	if state.prompt != nil {
+		meetingData["prompt_template_id"] = state.prompt.ID
+		meetingData["prompt_version"] = state.prompt.Version
+	}
+
+	if params.Title == "" {
+		meetingData["title"] = params.CategoryCode + " - " + params.MeetingDate
+	}
+
+	// The meeting, its agreements, tasks and meeting.analyzed are saved atomically, so a
+	// failed attempt leaves nothing behind and the save stage can simply be retried
+	var meetingID string
+	err := h.inTx(context.Background(), func(tx *Handler) error {
+		id, err := tx.insertProcessedMeeting(state, meetingData)
+		if err != nil {
+			return err
+		}
+		meetingID = id
+		if analysis == nil {
+			return nil
+		}
+		return tx.publish(state.actorID(), events.MeetingAnalyzed{
+			MeetingID:    id,
+			JobID:        state.job.ID,
+			CategoryCode: params.CategoryCode,
+			Summary:      analysis["summary"],
+			MoodScore:    analysisMoodScore(analysis),
+			Provider:     state.analysisRun.Provider,
+			Model:        state.analysisRun.Model,
+		})
+	})
+	if err != nil {
+		return "", err
+	}
+
+	state.meetingID = meetingID
+	return meetingID, nil
+}
+
+// insertProcessedMeeting inserts the meeting with participants, transcript segments,
+// agreements and tasks. Runs inside the transaction of saveProcessedMeeting.
+func (h *Handler) insertProcessedMeeting(state *meetingJobState, meetingData map[string]interface{}) (string, error) {
+	params := state.params
+	analysis := state.analysis
+
+	result, err := h.DB.Insert("meetings", meetingData)
+	if err != nil {
+		return "", err
+	}
+
+	var created []map[string]interface{}
+	json.Unmarshal(result, &created)
+	if len(created) == 0 {
+		return "", fmt.Errorf("meeting was not created")
+	}
+
+	meetingID, _ := created[0]["id"].(string)
+	if _, err := h.DB.Update("meeting_jobs", "id", state.job.ID, map[string]interface{}{"meeting_id": meetingID}); err != nil {
+		return "", err
+	}
+
+	// Add participants
+	for _, pid := range params.ParticipantIDs {
+		_, err := h.DB.Insert("meeting_participants", map[string]interface{}{
+			"meeting_id":  meetingID,
+			"employee_id": pid,
+		})
+		if err != nil {
+			return "", err
+		}
+	}
+
+	if err := h.saveTranscriptSegments(meetingID, state.segments); err != nil {
+		return "", err
+	}
+
+	// Save agreements/action items
+	for _, item := range getActionItems(analysis) {
+		task, _ := item["task"].(string)
+		if task == "" {
+			task, _ = item["improvement"].(string)
+		}
+		if task == "" {
+			continue
+		}
+
+		// Create the task first so the agreement can be linked to it
+		taskData := map[string]interface{}{
+			"title":       task,
+			"description": "Из встречи: " + params.MeetingDate,
+			"status":      "todo",
+			"priority":    3,
+			"meeting_id":  meetingID,
+			"project_id":  nilIfEmpty(params.ProjectID),
+			"due_date":    item["deadline"],
+		}
+		if params.EmployeeID != "" {
+			taskData["assignee_id"] = params.EmployeeID
+		}
+		taskResult, err := h.DB.Insert("tasks", taskData)
+		if err != nil {
+			return "", fmt.Errorf("failed to create task: %w", err)
+		}
+		var createdTasks []models.Task
+		json.Unmarshal(taskResult, &createdTasks)
+
+		agreementData := map[string]interface{}{
+			"meeting_id":  meetingID,
+			"task":        task,
+			"responsible": item["responsible"],
+			"deadline":    item["deadline"],
+			"status":      agreementPending,
+		}
+		if len(createdTasks) > 0 {
+			agreementData["task_id"] = createdTasks[0].ID
+		}
+		if timestamp, ok := item["timestamp"].(string); ok {
+			if offset, ok := ai.ParseOffset(timestamp); ok {
+				agreementData["source_offset_seconds"] = offset
+			}
+		}
+		if _, err := h.DB.Insert("agreements", agreementData); err != nil {
+			return "", fmt.Errorf("failed to create agreement: %w", err)
+		}
+	}
+
+	// Close earlier agreements the analysis found fulfilled
+	if err := h.applyCompletedAgreements(params.EmployeeID, meetingID, state.actorID(), analysis); err != nil {
+		return "", fmt.Errorf("failed to close completed agreements: %w", err)
+	}
+
+	return meetingID, nil
+}
+
+// updateMeetingJob persists job fields and pushes progress to the job owner over WebSocket
+func (h *Handler) updateMeetingJob(state *meetingJobState, updates map[string]interface{}) {
+	job := state.job
+	updates["updated_at"] = time.Now()
+	h.DB.Update("meeting_jobs", "id", job.ID, updates)
+
+	if status, ok := updates["status"].(string); ok {
+		job.Status = status
+	}
+	if progress, ok := updates["progress"].(int); ok {
+		job.Progress = progress
+	}
+	if stage, ok := updates["current_stage"].(string); ok {
+		job.CurrentStage = &stage
+	}
+	if errMsg, ok := updates["error"].(string); ok {
+		job.Error = &errMsg
+	}
+
+	if job.CreatedBy == nil {
+		return
+	}
+
+	data := fiber.Map{
+		"job_id":        job.ID,
+		"status":        job.Status,
+		"progress":      job.Progress,
+		"current_stage": job.CurrentStage,
+		"error":         job.Error,
+	}
+	if state.meetingID != "" {
+		data["meeting_id"] = state.meetingID
+	}
+
+	hub.broadcast <- WSMessage{
+		Type:       meetingJobProgressType,
+		Data:       data,
+		Recipients: []string{*job.CreatedBy},
+	}
+}
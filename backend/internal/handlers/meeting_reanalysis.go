@@ -0,0 +1,413 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"math"
+	"strconv"
+
+	"github.com/ekf/one-on-one-backend/internal/events"
+	"github.com/ekf/one-on-one-backend/internal/models"
+	"github.com/ekf/one-on-one-backend/internal/utils"
+	"github.com/ekf/one-on-one-backend/pkg/ai"
+	"github.com/gofiber/fiber/v2"
+)
+
+const meetingReanalysisProgressType = "meeting_reanalysis_progress"
+
+// ReanalysisOptions selects stored meetings to analyze again and how.
+// Agreements and tasks created from the original analysis are not touched.
+type ReanalysisOptions struct {
+	MeetingID     string `json:"meeting_id"`
+	EmployeeID    string `json:"employee_id"`
+	CategoryCode  string `json:"category_code"`
+	DateFrom      string `json:"date_from"`
+	DateTo        string `json:"date_to"`
+	PromptVersion int    `json:"prompt_version"` // Stored version, requires category_code; 0 - active prompt
+	Provider      string `json:"provider"`       // LLM provider override; empty - configured chain
+	Reason        string `json:"reason"`
+}
+
+// ReanalysisResult summarizes a re-analysis run
+type ReanalysisResult struct {
+	Total     int               `json:"total"`
+	Succeeded int               `json:"succeeded"`
+	Failed    int               `json:"failed"`
+	Errors    map[string]string `json:"errors,omitempty"` // meeting ID -> error
+}
+
+// storedAnalysis is the analysis part of a meetings row
+type storedAnalysis struct {
+	ID               string   `json:"id"`
+	CategoryID       *string  `json:"category_id"`
+	Analysis         *string  `json:"analysis"`
+	Summary          *string  `json:"summary"`
+	MoodScore        *float64 `json:"mood_score"`
+	PromptTemplateID *string  `json:"prompt_template_id"`
+	PromptVersion    *int     `json:"prompt_version"`
+	AnalysisProvider *string  `json:"analysis_provider"`
+	AnalysisModel    *string  `json:"analysis_model"`
+}
+
+const storedAnalysisColumns = "id, category_id, analysis, summary, mood_score, prompt_template_id, prompt_version, analysis_provider, analysis_model"
+
+// ReanalyzeMeeting runs analysis again for one meeting and archives the previous result
+func (h *Handler) ReanalyzeMeeting(c *fiber.Ctx) error {
+	if h.DB == nil {
+		return c.Status(500).JSON(fiber.Map{"error": "Database not configured"})
+	}
+	if h.AI == nil {
+		return c.Status(500).JSON(fiber.Map{"error": "AI not configured"})
+	}
+
+	userID, _ := c.Locals("user_id").(string)
+
+	var opts ReanalysisOptions
+	if len(c.Body()) > 0 {
+		if err := c.BodyParser(&opts); err != nil {
+			return c.Status(400).JSON(fiber.Map{"error": "Invalid request body"})
+		}
+	}
+	opts.MeetingID = c.Params("id")
+
+	if err := h.validateReanalysisOptions(opts); err != nil {
+		return c.Status(400).JSON(fiber.Map{"error": err.Error()})
+	}
+
+	var meeting storedAnalysis
+	if err := h.DB.From("meetings").Select(storedAnalysisColumns).Eq("id", opts.MeetingID).Single().Execute(&meeting); err != nil {
+		return c.Status(404).JSON(fiber.Map{"error": "Meeting not found"})
+	}
+
+	analysis, revision, err := h.reanalyzeMeeting(meeting, h.categoryCodes(), opts, userID)
+	if err != nil {
+		return c.Status(500).JSON(fiber.Map{"error": err.Error()})
+	}
+
+	return c.JSON(fiber.Map{
+		"meeting_id":        opts.MeetingID,
+		"analysis":          analysis,
+		"archived_revision": revision,
+	})
+}
+
+// ReanalyzeMeetingsBatch starts re-analysis of meetings matching the filter in background.
+// Progress is pushed to the caller over WebSocket.
+func (h *Handler) ReanalyzeMeetingsBatch(c *fiber.Ctx) error {
+	if h.DB == nil {
+		return c.Status(500).JSON(fiber.Map{"error": "Database not configured"})
+	}
+	if h.AI == nil {
+		return c.Status(500).JSON(fiber.Map{"error": "AI not configured"})
+	}
+
+	userID, _ := c.Locals("user_id").(string)
+
+	var opts ReanalysisOptions
+	if err := c.BodyParser(&opts); err != nil {
+		return c.Status(400).JSON(fiber.Map{"error": "Invalid request body"})
+	}
+	if opts.MeetingID == "" && opts.EmployeeID == "" && opts.DateFrom == "" && opts.DateTo == "" {
+		return c.Status(400).JSON(fiber.Map{"error": "meeting_id, employee_id or date range is required"})
+	}
+	if err := h.validateReanalysisOptions(opts); err != nil {
+		return c.Status(400).JSON(fiber.Map{"error": err.Error()})
+	}
+
+	meetings, err := h.meetingsForReanalysis(opts)
+	if err != nil {
+		return c.Status(500).JSON(fiber.Map{"error": err.Error()})
+	}
+
+	go func() {
+		result := h.reanalyzeMeetings(meetings, opts, userID, func(done, total int, meetingID string, err error) {
+			data := fiber.Map{"done": done, "total": total, "meeting_id": meetingID}
+			if err != nil {
+				data["error"] = err.Error()
+			}
+			h.notifyReanalysis(userID, data)
+		})
+		h.notifyReanalysis(userID, fiber.Map{"done": result.Total, "total": result.Total, "result": result})
+	}()
+
+	return c.Status(202).JSON(fiber.Map{"total": len(meetings), "status": "started"})
+}
+
+// GetMeetingAnalysisRevisions returns archived analyses of a meeting, newest first
+func (h *Handler) GetMeetingAnalysisRevisions(c *fiber.Ctx) error {
+	if h.DB == nil {
+		return c.Status(500).JSON(fiber.Map{"error": "Database not configured"})
+	}
+
+	var revisions []models.MeetingAnalysisRevision
+	err := h.DB.From("meeting_analysis_revisions").Select("*").
+		Eq("meeting_id", c.Params("id")).Order("revision", true).Execute(&revisions)
+	if err != nil {
+		return c.Status(500).JSON(fiber.Map{"error": err.Error()})
+	}
+
+	result := make([]fiber.Map, 0, len(revisions))
+	for _, r := range revisions {
+		var analysis map[string]interface{}
+		json.Unmarshal([]byte(r.Analysis), &analysis)
+		result = append(result, fiber.Map{
+			"id":                 r.ID,
+			"revision":           r.Revision,
+			"analysis":           analysis,
+			"summary":            r.Summary,
+			"mood_score":         r.MoodScore,
+			"prompt_template_id": r.PromptTemplateID,
+			"prompt_version":     r.PromptVersion,
+			"analysis_provider":  r.AnalysisProvider,
+			"analysis_model":     r.AnalysisModel,
+			"reason":             r.Reason,
+			"archived_by":        r.ArchivedBy,
+			"archived_at":        r.ArchivedAt,
+		})
+	}
+
+	return c.JSON(result)
+}
+
+// ReanalyzeMeetings re-analyzes all matching meetings sequentially.
+// progress is called after each meeting and may be nil. Used by the API and the reanalyze command.
+func (h *Handler) ReanalyzeMeetings(opts ReanalysisOptions, userID string, progress func(done, total int, meetingID string, err error)) (ReanalysisResult, error) {
+	if err := h.validateReanalysisOptions(opts); err != nil {
+		return ReanalysisResult{}, err
+	}
+
+	meetings, err := h.meetingsForReanalysis(opts)
+	if err != nil {
+		return ReanalysisResult{}, err
+	}
+	return h.reanalyzeMeetings(meetings, opts, userID, progress), nil
+}
+
+// reanalyzeMeetings re-analyzes meetings already selected by meetingsForReanalysis
+func (h *Handler) reanalyzeMeetings(meetings []storedAnalysis, opts ReanalysisOptions, userID string, progress func(done, total int, meetingID string, err error)) ReanalysisResult {
+	result := ReanalysisResult{Total: len(meetings), Errors: make(map[string]string)}
+
+	log := utils.GetLogger()
+	categories := h.categoryCodes()
+
+	for i, meeting := range meetings {
+		_, _, err := h.reanalyzeMeeting(meeting, categories, opts, userID)
+		if err != nil {
+			result.Failed++
+			result.Errors[meeting.ID] = err.Error()
+			log.Warn("Meeting re-analysis failed", map[string]interface{}{
+				"meeting_id": meeting.ID,
+				"error":      err.Error(),
+			})
+		} else {
+			result.Succeeded++
+		}
+		if progress != nil {
+			progress(i+1, len(meetings), meeting.ID, err)
+		}
+	}
+
+	return result
+}
+
+func (h *Handler) validateReanalysisOptions(opts ReanalysisOptions) error {
+	if opts.PromptVersion > 0 && opts.CategoryCode == "" {
+		return fmt.Errorf("category_code is required with prompt_version")
+	}
+	if opts.Provider != "" {
+		provider, ok := h.AI.LLMProvider(opts.Provider)
+		if !ok || !provider.Configured() {
+			return fmt.Errorf("LLM provider is not configured: %s", opts.Provider)
+		}
+	}
+	return nil
+}
+
+// meetingsForReanalysis returns meetings matching the filter
+func (h *Handler) meetingsForReanalysis(opts ReanalysisOptions) ([]storedAnalysis, error) {
+	query := h.DB.From("meetings").Select(storedAnalysisColumns)
+
+	if opts.MeetingID != "" {
+		query = query.Eq("id", opts.MeetingID)
+	}
+	if opts.EmployeeID != "" {
+		query = query.Eq("employee_id", opts.EmployeeID)
+	}
+	if opts.DateFrom != "" {
+		query = query.Gte("date", opts.DateFrom)
+	}
+	if opts.DateTo != "" {
+		query = query.Lte("date", opts.DateTo)
+	}
+	if opts.CategoryCode != "" {
+		var category models.MeetingCategory
+		if err := h.DB.From("meeting_categories").Select("id").Eq("code", opts.CategoryCode).Single().Execute(&category); err != nil {
+			return nil, fmt.Errorf("unknown meeting category: %s", opts.CategoryCode)
+		}
+		query = query.Eq("category_id", category.ID)
+	}
+
+	var meetings []storedAnalysis
+	if err := query.Order("date", false).Execute(&meetings); err != nil {
+		return nil, err
+	}
+	return meetings, nil
+}
+
+// reanalyzeMeeting analyzes a stored meeting again, archives its current analysis
+// and returns the new analysis with the archived revision number (0 if there was nothing to archive)
+func (h *Handler) reanalyzeMeeting(meeting storedAnalysis, categories map[string]string, opts ReanalysisOptions, userID string) (map[string]interface{}, int, error) {
+	categoryCode := "default"
+	if meeting.CategoryID != nil && categories[*meeting.CategoryID] != "" {
+		categoryCode = categories[*meeting.CategoryID]
+	}
+
+	var prompt *models.PromptTemplate
+	if opts.PromptVersion > 0 {
+		if opts.CategoryCode != categoryCode {
+			return nil, 0, fmt.Errorf("prompt version belongs to category %s, meeting is %s", opts.CategoryCode, categoryCode)
+		}
+		template, err := h.promptTemplateVersion(categoryCode, strconv.Itoa(opts.PromptVersion))
+		if err != nil {
+			return nil, 0, fmt.Errorf("prompt version %d not found", opts.PromptVersion)
+		}
+		prompt = template
+	} else {
+		prompt = h.activePromptTemplate(categoryCode)
+	}
+
+	promptTemplate := ai.DefaultPrompt(categoryCode)
+	if prompt != nil {
+		promptTemplate = prompt.Template
+	}
+
+	analysisCtx, err := h.meetingAnalysisContext(meeting.ID)
+	if err != nil {
+		return nil, 0, fmt.Errorf("meeting has no transcript")
+	}
+
+	var providers []string
+	if opts.Provider != "" {
+		providers = []string{opts.Provider}
+	}
+
+	analysis, run, err := h.AI.AnalyzeWithProviders(categoryCode, promptTemplate, analysisCtx, providers)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	analysisJSON, _ := json.Marshal(analysis)
+	updates := map[string]interface{}{
+		"analysis":           string(analysisJSON),
+		"summary":            analysis["summary"],
+		"mood_score":         analysisMoodScore(analysis),
+		"prompt_template_id": nil,
+		"prompt_version":     nil,
+		"analysis_provider":  nilIfEmpty(run.Provider),
+		"analysis_model":     nilIfEmpty(run.Model),
+	}
+	if prompt != nil {
+		updates["prompt_template_id"] = prompt.ID
+		updates["prompt_version"] = prompt.Version
+	}
+
+	// The previous analysis is archived only if the new one is stored, and vice versa
+	var revision int
+	err = h.inTx(context.Background(), func(tx *Handler) error {
+		archived, err := tx.archiveMeetingAnalysis(meeting, opts.Reason, userID)
+		if err != nil {
+			return err
+		}
+		revision = archived
+		if _, err := tx.DB.Update("meetings", "id", meeting.ID, updates); err != nil {
+			return err
+		}
+		return tx.publish(userID, events.MeetingAnalyzed{
+			MeetingID:    meeting.ID,
+			Revision:     revision,
+			CategoryCode: categoryCode,
+			Summary:      analysis["summary"],
+			MoodScore:    analysisMoodScore(analysis),
+			Provider:     run.Provider,
+			Model:        run.Model,
+		})
+	})
+	if err != nil {
+		return nil, 0, err
+	}
+
+	return analysis, revision, nil
+}
+
+// archiveMeetingAnalysis stores the current meeting analysis as the next revision
+func (h *Handler) archiveMeetingAnalysis(meeting storedAnalysis, reason, userID string) (int, error) {
+	if meeting.Analysis == nil || *meeting.Analysis == "" {
+		return 0, nil
+	}
+
+	var latest []models.MeetingAnalysisRevision
+	err := h.DB.From("meeting_analysis_revisions").Select("revision").
+		Eq("meeting_id", meeting.ID).Order("revision", true).Limit(1).Execute(&latest)
+	if err != nil {
+		return 0, err
+	}
+	revision := 1
+	if len(latest) > 0 {
+		revision = latest[0].Revision + 1
+	}
+
+	var moodScore interface{}
+	if meeting.MoodScore != nil {
+		moodScore = int(math.Round(*meeting.MoodScore))
+	}
+
+	_, err = h.DB.Insert("meeting_analysis_revisions", map[string]interface{}{
+		"meeting_id":         meeting.ID,
+		"revision":           revision,
+		"analysis":           *meeting.Analysis,
+		"summary":            meeting.Summary,
+		"mood_score":         moodScore,
+		"prompt_template_id": meeting.PromptTemplateID,
+		"prompt_version":     meeting.PromptVersion,
+		"analysis_provider":  meeting.AnalysisProvider,
+		"analysis_model":     meeting.AnalysisModel,
+		"reason":             nilIfEmpty(reason),
+		"archived_by":        nilIfEmpty(userID),
+	})
+	if err != nil {
+		return 0, err
+	}
+	return revision, nil
+}
+
+// categoryCodes maps meeting category IDs to codes
+func (h *Handler) categoryCodes() map[string]string {
+	var categories []models.MeetingCategory
+	h.DB.From("meeting_categories").Select("id, code").Execute(&categories)
+
+	codes := make(map[string]string, len(categories))
+	for _, cat := range categories {
+		codes[cat.ID] = cat.Code
+	}
+	return codes
+}
+
+// analysisMoodScore returns mood_score rounded to an integer, nil if absent
+func analysisMoodScore(analysis map[string]interface{}) interface{} {
+	if score, ok := analysis["mood_score"].(float64); ok {
+		return int(math.Round(score))
+	}
+	return nil
+}
+
+func (h *Handler) notifyReanalysis(userID string, data fiber.Map) {
+	if userID == "" {
+		return
+	}
+	hub.broadcast <- WSMessage{
+		Type:       meetingReanalysisProgressType,
+		Data:       data,
+		Recipients: []string{userID},
+	}
+}
@@ -0,0 +1,215 @@
+package handlers
+
+import (
+	"fmt"
+
+	"github.com/ekf/one-on-one-backend/internal/models"
+	"github.com/ekf/one-on-one-backend/pkg/ai"
+	"github.com/gofiber/fiber/v2"
+)
+
+// GetMeetingSegments returns the diarized transcript with speaker mapping and talk-time stats
+func (h *Handler) GetMeetingSegments(c *fiber.Ctx) error {
+	if h.DB == nil {
+		return c.Status(500).JSON(fiber.Map{"error": "Database not configured"})
+	}
+
+	meetingID := c.Params("id")
+
+	var segments []models.TranscriptSegment
+	err := h.DB.From("meeting_transcript_segments").Select("*").
+		Eq("meeting_id", meetingID).Order("position", false).Execute(&segments)
+	if err != nil {
+		return c.Status(500).JSON(fiber.Map{"error": err.Error()})
+	}
+
+	speakers := h.getMeetingSpeakers(meetingID)
+
+	speakerEmployees := make(map[string]string)
+	names := make(map[string]string)
+	for _, s := range speakers {
+		if s.EmployeeID != nil {
+			speakerEmployees[s.SpeakerLabel] = *s.EmployeeID
+		}
+		if s.Employee != nil {
+			names[s.SpeakerLabel] = s.Employee.Name
+		}
+	}
+
+	aiSegments := make([]ai.TranscriptSegment, 0, len(segments))
+	for i, s := range segments {
+		label := ""
+		if s.SpeakerLabel != nil {
+			label = *s.SpeakerLabel
+		}
+		if employeeID, ok := speakerEmployees[label]; ok {
+			segments[i].EmployeeID = &employeeID
+		}
+		aiSegments = append(aiSegments, ai.TranscriptSegment{
+			Speaker: label,
+			Start:   s.StartSeconds,
+			End:     s.EndSeconds,
+			Text:    s.Text,
+		})
+	}
+
+	var talkTime []fiber.Map
+	for label, seconds := range ai.SpeakerTalkTime(aiSegments) {
+		entry := fiber.Map{
+			"speaker_label": label,
+			"seconds":       seconds,
+		}
+		if employeeID, ok := speakerEmployees[label]; ok {
+			entry["employee_id"] = employeeID
+			entry["name"] = names[label]
+		}
+		talkTime = append(talkTime, entry)
+	}
+
+	if segments == nil {
+		segments = []models.TranscriptSegment{}
+	}
+	if speakers == nil {
+		speakers = []models.MeetingSpeaker{}
+	}
+	if talkTime == nil {
+		talkTime = []fiber.Map{}
+	}
+
+	return c.JSON(fiber.Map{
+		"segments":  segments,
+		"speakers":  speakers,
+		"talk_time": talkTime,
+	})
+}
+
+// UpdateMeetingSpeakers maps speaker labels to meeting participants
+func (h *Handler) UpdateMeetingSpeakers(c *fiber.Ctx) error {
+	if h.DB == nil {
+		return c.Status(500).JSON(fiber.Map{"error": "Database not configured"})
+	}
+
+	meetingID := c.Params("id")
+
+	var req struct {
+		Speakers map[string]*string `json:"speakers"` // speaker label -> employee ID (null to unassign)
+	}
+	if err := c.BodyParser(&req); err != nil {
+		return c.Status(400).JSON(fiber.Map{"error": "Invalid request body"})
+	}
+
+	// Speakers can only be mapped to employees who took part in the meeting
+	var meeting models.Meeting
+	if err := h.DB.From("meetings").Select("id, employee_id").Eq("id", meetingID).Single().Execute(&meeting); err != nil {
+		return c.Status(404).JSON(fiber.Map{"error": "Meeting not found"})
+	}
+
+	allowed := make(map[string]bool)
+	if meeting.EmployeeID != nil {
+		allowed[*meeting.EmployeeID] = true
+	}
+	var participants []models.MeetingParticipant
+	h.DB.From("meeting_participants").Select("employee_id").Eq("meeting_id", meetingID).Execute(&participants)
+	for _, p := range participants {
+		allowed[p.EmployeeID] = true
+	}
+
+	for label, employeeID := range req.Speakers {
+		if label == "" {
+			return c.Status(400).JSON(fiber.Map{"error": "Speaker label is required"})
+		}
+		if employeeID != nil && !allowed[*employeeID] {
+			return c.Status(400).JSON(fiber.Map{"error": "Employee is not a participant of the meeting: " + *employeeID})
+		}
+	}
+
+	existing := make(map[string]string)
+	for _, s := range h.getMeetingSpeakers(meetingID) {
+		existing[s.SpeakerLabel] = s.ID
+	}
+
+	for label, employeeID := range req.Speakers {
+		var value interface{}
+		if employeeID != nil {
+			value = *employeeID
+		}
+
+		var err error
+		if id, ok := existing[label]; ok {
+			_, err = h.DB.Update("meeting_speakers", "id", id, map[string]interface{}{"employee_id": value})
+		} else {
+			_, err = h.DB.Insert("meeting_speakers", map[string]interface{}{
+				"meeting_id":    meetingID,
+				"speaker_label": label,
+				"employee_id":   value,
+			})
+		}
+		if err != nil {
+			return c.Status(500).JSON(fiber.Map{"error": err.Error()})
+		}
+	}
+
+	return c.JSON(h.getMeetingSpeakers(meetingID))
+}
+
+// meetingSegments loads stored segments of a meeting for analysis
+func (h *Handler) meetingSegments(meetingID string) []ai.TranscriptSegment {
+	var segments []models.TranscriptSegment
+	h.DB.From("meeting_transcript_segments").Select("*").
+		Eq("meeting_id", meetingID).Order("position", false).Execute(&segments)
+
+	result := make([]ai.TranscriptSegment, 0, len(segments))
+	for _, s := range segments {
+		label := ""
+		if s.SpeakerLabel != nil {
+			label = *s.SpeakerLabel
+		}
+		result = append(result, ai.TranscriptSegment{
+			Speaker: label,
+			Start:   s.StartSeconds,
+			End:     s.EndSeconds,
+			Text:    s.Text,
+		})
+	}
+	return result
+}
+
+func (h *Handler) getMeetingSpeakers(meetingID string) []models.MeetingSpeaker {
+	var speakers []models.MeetingSpeaker
+	h.DB.From("meeting_speakers").Select("*, employee:employees(id, name, position)").
+		Eq("meeting_id", meetingID).Order("speaker_label", false).Execute(&speakers)
+	return speakers
+}
+
+// saveTranscriptSegments stores diarized segments and registers their speaker labels
+func (h *Handler) saveTranscriptSegments(meetingID string, segments []ai.TranscriptSegment) error {
+	labels := make(map[string]bool)
+	for i, s := range segments {
+		_, err := h.DB.Insert("meeting_transcript_segments", map[string]interface{}{
+			"meeting_id":    meetingID,
+			"position":      i,
+			"speaker_label": nilIfEmpty(s.Speaker),
+			"start_seconds": s.Start,
+			"end_seconds":   s.End,
+			"text":          s.Text,
+		})
+		if err != nil {
+			return fmt.Errorf("failed to save transcript segment %d: %w", i, err)
+		}
+		if s.Speaker != "" {
+			labels[s.Speaker] = true
+		}
+	}
+
+	// Unassigned speaker rows let the UI show which labels still need mapping
+	for label := range labels {
+		_, err := h.DB.Insert("meeting_speakers", map[string]interface{}{
+			"meeting_id":    meetingID,
+			"speaker_label": label,
+		})
+		if err != nil {
+			return fmt.Errorf("failed to save speaker %s: %w", label, err)
+		}
+	}
+	return nil
+}
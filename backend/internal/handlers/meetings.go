@@ -2,9 +2,6 @@ package handlers
 
 import (
 	"encoding/json"
-	"io"
-	"os"
-	"path/filepath"
 	"strconv"
 	"strings"
 	"time"
@@ -189,6 +186,7 @@ func (h *Handler) AIStatus(c *fiber.Ctx) error {
 			"whisper": h.AI != nil && h.Config.OpenAIKey != "",
 			"yandex":  h.AI != nil && h.Config.YandexAPIKey != "" && h.Config.YandexFolderID != "",
 		},
+		"diarization": h.AI != nil && h.Config.OpenAIKey != "",
 		"analysis": fiber.Map{
 			"openai":    h.AI != nil && h.Config.OpenAIKey != "",
 			"anthropic": h.AI != nil && h.Config.AnthropicKey != "",
@@ -196,220 +194,41 @@ func (h *Handler) AIStatus(c *fiber.Ctx) error {
 		"available": h.AI != nil && (h.Config.OpenAIKey != "" || (h.Config.YandexAPIKey != "" && h.Config.YandexFolderID != "")),
 	}
 
-	if !status["available"].(bool) {
-		status["hint"] = "Для работы транскрипции необходимо установить OPENAI_API_KEY или YANDEX_API_KEY + YANDEX_FOLDER_ID"
-	}
-
-	return c.JSON(status)
-}
-
-// ProcessMeeting processes an audio file and creates a meeting
-func (h *Handler) ProcessMeeting(c *fiber.Ctx) error {
-	if h.DB == nil {
-		return c.Status(500).JSON(fiber.Map{"error": "Database not configured"})
-	}
-
-	// Parse form
-	categoryCode := c.FormValue("category_code", "one_on_one")
-	employeeID := c.FormValue("employee_id")
-	projectID := c.FormValue("project_id")
-	meetingDate := c.FormValue("meeting_date")
-	title := c.FormValue("title")
-	participantIDsJSON := c.FormValue("participant_ids", "[]")
-
-	var participantIDs []string
-	json.Unmarshal([]byte(participantIDsJSON), &participantIDs)
-
-	// Get uploaded file
-	file, err := c.FormFile("file")
-	if err != nil {
-		return c.Status(400).JSON(fiber.Map{"error": "Audio file required"})
-	}
-
-	// Save to temp file
-	tmpFile, err := os.CreateTemp("", "audio-*"+filepath.Ext(file.Filename))
-	if err != nil {
-		return c.Status(500).JSON(fiber.Map{"error": "Failed to create temp file"})
-	}
-	defer os.Remove(tmpFile.Name())
-	defer tmpFile.Close()
-
-	src, err := file.Open()
-	if err != nil {
-		return c.Status(500).JSON(fiber.Map{"error": "Failed to open uploaded file"})
-	}
-	defer src.Close()
-
-	if _, err := io.Copy(tmpFile, src); err != nil {
-		return c.Status(500).JSON(fiber.Map{"error": "Failed to save file"})
-	}
-	tmpFile.Close()
-
-	// Check if AI client is configured
-	if h.AI == nil {
-		return c.Status(500).JSON(fiber.Map{
-			"error":   "AI client not configured",
-			"details": "AI клиент не инициализирован. Проверьте переменные окружения.",
-			"hint":    "Установите OPENAI_API_KEY или YANDEX_API_KEY + YANDEX_FOLDER_ID",
-		})
-	}
-
-	// Transcribe with both services
-	var whisperErr, yandexErr error
-	whisperTranscript, whisperErr := h.AI.TranscribeWhisper(tmpFile.Name())
-	yandexTranscript, yandexErr := h.AI.TranscribeYandex(tmpFile.Name())
-
-	// Log errors for debugging
-	var errorDetails []string
-	if whisperErr != nil {
-		errorDetails = append(errorDetails, "Whisper: "+whisperErr.Error())
-	}
-	if yandexErr != nil {
-		errorDetails = append(errorDetails, "Yandex: "+yandexErr.Error())
-	}
-
-	// Merge transcripts
-	var mergedTranscript string
-	if whisperTranscript != "" && yandexTranscript != "" {
-		mergedTranscript, _ = h.AI.MergeTranscripts(whisperTranscript, yandexTranscript)
-	} else if whisperTranscript != "" {
-		mergedTranscript = whisperTranscript
-	} else {
-		mergedTranscript = yandexTranscript
-	}
-
-	if mergedTranscript == "" {
-		// Provide detailed error message
-		aiStatus := "Транскрипция не удалась. "
-		if h.Config.OpenAIKey == "" && (h.Config.YandexAPIKey == "" || h.Config.YandexFolderID == "") {
-			aiStatus += "API ключи не настроены. "
+	if h.AI != nil {
+		providers := fiber.Map{}
+		for _, t := range h.AI.Transcribers() {
+			providers[t.Name()] = t.Configured()
 		}
-		if len(errorDetails) > 0 {
-			aiStatus += "Ошибки: " + strings.Join(errorDetails, "; ")
-		}
-		return c.Status(500).JSON(fiber.Map{
-			"error":   "Transcription failed",
-			"details": aiStatus,
-			"hint":    "Установите OPENAI_API_KEY или YANDEX_API_KEY + YANDEX_FOLDER_ID в переменных окружения",
-			"errors":  errorDetails,
-		})
-	}
+		status["stt_providers"] = providers
 
-	// Build context
-	ctx := ai.AnalysisContext{
-		Transcript: mergedTranscript,
-	}
-
-	if employeeID != "" {
-		ctx.EmployeeContext = h.getEmployeeContext(employeeID)
-		ctx.MeetingsHistory = h.getEmployeeMeetingsHistory(employeeID)
-	}
-	if projectID != "" {
-		ctx.ProjectContext = h.getProjectContext(projectID)
-		if ctx.MeetingsHistory == "" {
-			ctx.MeetingsHistory = h.getProjectMeetingsHistory(projectID)
+		routing := fiber.Map{}
+		var categories []models.MeetingCategory
+		if h.DB != nil {
+			h.DB.From("meeting_categories").Select("code").Execute(&categories)
 		}
-	}
-	if len(participantIDs) > 0 {
-		ctx.Participants = h.getParticipantsInfo(participantIDs)
-	}
-
-	// Analyze
-	analysis, err := h.AI.Analyze(categoryCode, ctx)
-	if err != nil {
-		return c.Status(500).JSON(fiber.Map{"error": "Analysis failed: " + err.Error()})
-	}
-
-	// Get category ID
-	var category models.MeetingCategory
-	h.DB.From("meeting_categories").Select("id").Eq("code", categoryCode).Single().Execute(&category)
-
-	// Create meeting
-	meetingData := map[string]interface{}{
-		"title":              title,
-		"employee_id":        nilIfEmpty(employeeID),
-		"project_id":         nilIfEmpty(projectID),
-		"category_id":        nilIfEmpty(category.ID),
-		"date":               meetingDate,
-		"transcript_whisper": whisperTranscript,
-		"transcript_yandex":  yandexTranscript,
-		"transcript_merged":  mergedTranscript,
-		"transcript":         mergedTranscript,
-		"summary":            analysis["summary"],
-		"mood_score":         analysis["mood_score"],
-		"analysis":           analysis,
-	}
-
-	if title == "" {
-		meetingData["title"] = categoryCode + " - " + meetingDate
-	}
-
-	result, err := h.DB.Insert("meetings", meetingData)
-	if err != nil {
-		return c.Status(500).JSON(fiber.Map{"error": err.Error()})
-	}
-
-	var created []map[string]interface{}
-	json.Unmarshal(result, &created)
-
-	var meetingID string
-	if len(created) > 0 {
-		meetingID, _ = created[0]["id"].(string)
-	}
-
-	// Add participants
-	for _, pid := range participantIDs {
-		h.DB.Insert("meeting_participants", map[string]interface{}{
-			"meeting_id":  meetingID,
-			"employee_id": pid,
-		})
-	}
-
-	// Save agreements/action items
-	actionItems := getActionItems(analysis)
-	for _, item := range actionItems {
-		task, _ := item["task"].(string)
-		if task == "" {
-			task, _ = item["improvement"].(string)
+		for _, cat := range categories {
+			routing[cat.Code] = h.AI.TranscriberNamesFor(cat.Code)
 		}
-		if task == "" {
-			continue
+		status["stt_routing"] = routing
+		status["stt_default"] = h.AI.TranscriberNamesFor("")
+		if local, ok := h.AI.Transcriber(ai.TranscriberLocal); ok && local.Configured() {
+			status["available"] = true
 		}
 
-		agreementData := map[string]interface{}{
-			"meeting_id":  meetingID,
-			"task":        task,
-			"responsible": item["responsible"],
-			"deadline":    item["deadline"],
-			"status":      "pending",
-		}
-		h.DB.Insert("agreements", agreementData)
-
-		// Also create task
-		taskData := map[string]interface{}{
-			"title":       task,
-			"description": "Из встречи: " + meetingDate,
-			"status":      "todo",
-			"priority":    3,
-			"meeting_id":  meetingID,
-			"project_id":  nilIfEmpty(projectID),
-			"due_date":    item["deadline"],
-		}
-		if employeeID != "" {
-			taskData["assignee_id"] = employeeID
+		llm := fiber.Map{}
+		for _, p := range h.AI.LLMProviders() {
+			llm[p.Name()] = fiber.Map{"configured": p.Configured(), "model": p.Model()}
 		}
-		h.DB.Insert("tasks", taskData)
+		status["llm_providers"] = llm
+		status["llm_chain"] = h.AI.LLMChain()
+		status["analysis_available"] = h.AI.LLMConfigured()
 	}
 
-	return c.JSON(fiber.Map{
-		"meeting_id": meetingID,
-		"transcript": fiber.Map{
-			"whisper": truncate(whisperTranscript, 500),
-			"yandex":  truncate(yandexTranscript, 500),
-			"merged":  mergedTranscript,
-		},
-		"analysis": analysis,
-	})
+	if !status["available"].(bool) {
+		status["hint"] = "Для работы транскрипции необходимо установить OPENAI_API_KEY или YANDEX_API_KEY + YANDEX_FOLDER_ID"
+	}
+
+	return c.JSON(status)
 }
 
 func (h *Handler) getEmployeeContext(employeeID string) string {
@@ -442,12 +261,19 @@ func (h *Handler) getEmployeeContext(employeeID string) string {
 		"СТАТИСТИКА ЗАДАЧ: всего " + itoa(total) + ", выполнено " + itoa(done) + ", в работе " + itoa(inProgress) + ", просрочено " + itoa(overdue)
 }
 
-func (h *Handler) getEmployeeMeetingsHistory(employeeID string) string {
+// getEmployeeMeetingsHistory summarizes the last meetings up to beforeDate (all if empty)
+// and lists agreements still open, skipping excludeMeetingID so a re-analyzed meeting
+// does not see itself
+func (h *Handler) getEmployeeMeetingsHistory(employeeID, beforeDate, excludeMeetingID string) string {
+	query := h.DB.From("meetings").Select("id, date, summary, mood_score").Eq("employee_id", employeeID)
+	if beforeDate != "" {
+		query = query.Lte("date", beforeDate)
+	}
 	var meetings []models.Meeting
-	h.DB.From("meetings").Select("date, summary, mood_score").Eq("employee_id", employeeID).Order("date", true).Limit(5).Execute(&meetings)
+	query.Order("date", true).Limit(6).Execute(&meetings)
 
 	var history []string
-	for _, m := range meetings {
+	for _, m := range previousMeetings(meetings, excludeMeetingID, 5) {
 		mood := "?"
 		if m.MoodScore != nil {
 			mood = itoa(*m.MoodScore)
@@ -462,6 +288,11 @@ func (h *Handler) getEmployeeMeetingsHistory(employeeID string) string {
 	if len(history) == 0 {
 		return "Предыдущих встреч не найдено"
 	}
+
+	// Open agreements let the analysis check whether they were fulfilled
+	if open := h.openAgreements(employeeID, beforeDate, excludeMeetingID); len(open) > 0 {
+		history = append(history, "ОТКРЫТЫЕ ДОГОВОРЁННОСТИ С ПРОШЛЫХ ВСТРЕЧ:\n"+formatOpenAgreements(open))
+	}
 	return strings.Join(history, "\n\n")
 }
 
@@ -497,12 +328,16 @@ func (h *Handler) getProjectContext(projectID string) string {
 		"ПРОГРЕСС: " + itoa(progress) + "% (" + itoa(done) + "/" + itoa(total) + " задач)"
 }
 
-func (h *Handler) getProjectMeetingsHistory(projectID string) string {
+func (h *Handler) getProjectMeetingsHistory(projectID, beforeDate, excludeMeetingID string) string {
+	query := h.DB.From("meetings").Select("id, date, title, summary").Eq("project_id", projectID)
+	if beforeDate != "" {
+		query = query.Lte("date", beforeDate)
+	}
 	var meetings []models.Meeting
-	h.DB.From("meetings").Select("date, title, summary").Eq("project_id", projectID).Order("date", true).Limit(5).Execute(&meetings)
+	query.Order("date", true).Limit(6).Execute(&meetings)
 
 	var history []string
-	for _, m := range meetings {
+	for _, m := range previousMeetings(meetings, excludeMeetingID, 5) {
 		title := ""
 		if m.Title != nil {
 			title = *m.Title
@@ -520,6 +355,17 @@ func (h *Handler) getProjectMeetingsHistory(projectID string) string {
 	return strings.Join(history, "\n\n")
 }
 
+// previousMeetings drops the excluded meeting and keeps at most limit entries
+func previousMeetings(meetings []models.Meeting, excludeMeetingID string, limit int) []models.Meeting {
+	var result []models.Meeting
+	for _, m := range meetings {
+		if m.ID != excludeMeetingID && len(result) < limit {
+			result = append(result, m)
+		}
+	}
+	return result
+}
+
 func (h *Handler) getParticipantsInfo(ids []string) string {
 	var employees []models.Employee
 	h.DB.From("employees").Select("name, position").In("id", ids).Execute(&employees)
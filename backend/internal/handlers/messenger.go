@@ -6,6 +6,7 @@ import (
 	"sync"
 	"time"
 
+	"github.com/ekf/one-on-one-backend/internal/events"
 	"github.com/ekf/one-on-one-backend/internal/middleware"
 	"github.com/ekf/one-on-one-backend/internal/models"
 	"github.com/gofiber/fiber/v2"
@@ -202,8 +203,14 @@ func (h *Handler) handleSendMessage(senderID, conversationID, content, replyToID
 		Recipients:     recipients,
 	}
 
-	// Forward to Telegram if channel has it enabled (async, non-blocking)
-	go h.ForwardToTelegram(conversationID, sender.Name, content, "text")
+	h.publish(senderID, events.MessageSent{
+		MessageID:      newMsg.ID,
+		ConversationID: conversationID,
+		SenderID:       senderID,
+		SenderName:     sender.Name,
+		Content:        content,
+		MessageType:    "text",
+	})
 }
 
 func (h *Handler) handleTyping(userID, conversationID string) {
@@ -650,8 +657,14 @@ func (h *Handler) SendMessage(c *fiber.Ctx) error {
 		}
 	}
 
-	// Forward to Telegram if channel has it enabled (async, non-blocking)
-	go h.ForwardToTelegram(req.ConversationID, sender.Name, req.Content, msgType)
+	h.publish(req.SenderID, events.MessageSent{
+		MessageID:      newMsg.ID,
+		ConversationID: req.ConversationID,
+		SenderID:       req.SenderID,
+		SenderName:     sender.Name,
+		Content:        req.Content,
+		MessageType:    msgType,
+	})
 
 	return c.Status(201).JSON(newMsg)
 }
@@ -0,0 +1,316 @@
+package handlers
+
+import (
+	"context"
+	"errors"
+
+	"github.com/ekf/one-on-one-backend/internal/events"
+	"github.com/ekf/one-on-one-backend/internal/models"
+	"github.com/ekf/one-on-one-backend/internal/notifications"
+	"github.com/ekf/one-on-one-backend/internal/utils"
+	"github.com/gofiber/fiber/v2"
+)
+
+// notificationType is the WebSocket message type of a new inbox notification
+const notificationType = "notification"
+
+// pushNotification shows a new notification to the recipient's open sessions
+func pushNotification(n notifications.Notification) {
+	hub.broadcast <- WSMessage{
+		Type:       notificationType,
+		Data:       n,
+		Recipients: []string{n.UserID},
+	}
+}
+
+// notify sends a notification outside of an event subscriber, logging a failure
+func (h *Handler) notify(msg notifications.Message, userIDs ...string) {
+	if h.Notifications == nil {
+		return
+	}
+	if err := h.Notifications.Notify(msg, userIDs...); err != nil {
+		utils.GetLogger().Warn("Failed to send notification", map[string]interface{}{
+			"type":  msg.Type,
+			"error": err.Error(),
+		})
+	}
+}
+
+// registerNotificationSubscribers turns domain events into user notifications
+func (h *Handler) registerNotificationSubscribers() {
+	if h.Notifications == nil {
+		return
+	}
+	bus := h.Events
+
+	events.Subscribe(bus, "notifications", func(_ context.Context, meta events.Meta, e events.TaskCreated) error {
+		return h.Notifications.Notify(notifications.Message{
+			Type:    notifications.TypeTaskAssigned,
+			EventID: meta.ID,
+			Link:    "/tasks",
+			Data: map[string]interface{}{
+				"task_id":  e.Task.ID,
+				"title":    e.Task.Title,
+				"due_date": stringValue(e.Task.DueDate),
+			},
+		}, exceptActor(meta.ActorID, e.Task.AssigneeID, e.Task.CoAssigneeID)...)
+	})
+
+	events.Subscribe(bus, "notifications", func(_ context.Context, meta events.Meta, e events.TaskStatusChanged) error {
+		task := e.Task
+		if task == nil {
+			var rows []models.Task
+			if err := h.DB.From("tasks").Select("id, title, assignee_id, creator_id").Eq("id", e.TaskID).Limit(1).Execute(&rows); err != nil {
+				return err
+			}
+			if len(rows) == 0 {
+				return nil
+			}
+			task = &rows[0]
+		}
+		return h.Notifications.Notify(notifications.Message{
+			Type:    notifications.TypeTaskStatusChanged,
+			EventID: meta.ID,
+			Link:    "/tasks",
+			Data: map[string]interface{}{
+				"task_id":    e.TaskID,
+				"title":      task.Title,
+				"old_status": e.OldStatus,
+				"new_status": e.NewStatus,
+			},
+		}, exceptActor(meta.ActorID, task.AssigneeID, task.CreatorID)...)
+	})
+
+	events.Subscribe(bus, "notifications", func(_ context.Context, meta events.Meta, e events.TicketAssigned) error {
+		var rows []models.ServiceTicket
+		if err := h.DB.From("service_tickets").Select("id, number, title").Eq("id", e.TicketID).Limit(1).Execute(&rows); err != nil {
+			return err
+		}
+		if len(rows) == 0 {
+			return nil
+		}
+		return h.Notifications.Notify(notifications.Message{
+			Type:    notifications.TypeTicketAssigned,
+			EventID: meta.ID,
+			Link:    "/service-desk",
+			Data:    map[string]interface{}{"ticket_id": e.TicketID, "number": rows[0].Number, "title": rows[0].Title},
+		}, exceptActor(meta.ActorID, &e.AssigneeID)...)
+	})
+
+	events.Subscribe(bus, "notifications", h.notifySLABreach)
+
+	events.Subscribe(bus, "notifications", func(_ context.Context, meta events.Meta, e events.ImprovementApproved) error {
+		request := e.Request
+		if request == nil {
+			var rows []models.ImprovementRequest
+			if err := h.DB.From("improvement_requests").Select("id, number, title, initiator_id").Eq("id", e.RequestID).Limit(1).Execute(&rows); err != nil {
+				return err
+			}
+			if len(rows) == 0 {
+				return nil
+			}
+			request = &rows[0]
+		}
+		return h.Notifications.Notify(notifications.Message{
+			Type:    notifications.TypeImprovementApproved,
+			EventID: meta.ID,
+			Link:    "/improvements/" + e.RequestID,
+			Data: map[string]interface{}{
+				"request_id": e.RequestID,
+				"number":     request.Number,
+				"title":      request.Title,
+				"stage":      e.Stage,
+				"status":     e.NewStatus,
+			},
+		}, exceptActor(meta.ActorID, &request.InitiatorID)...)
+	})
+
+	events.Subscribe(bus, "notifications", func(_ context.Context, meta events.Meta, e events.MeetingAnalyzed) error {
+		// Only recordings processed in the background; reanalysis reports its own progress
+		if e.JobID == "" || meta.ActorID == "" {
+			return nil
+		}
+		var rows []models.Meeting
+		if err := h.DB.From("meetings").Select("id, title, date").Eq("id", e.MeetingID).Limit(1).Execute(&rows); err != nil {
+			return err
+		}
+		data := map[string]interface{}{"meeting_id": e.MeetingID, "job_id": e.JobID}
+		if len(rows) > 0 {
+			data["meeting_title"] = stringValue(rows[0].Title)
+			data["date"] = rows[0].Date
+		}
+		return h.Notifications.Notify(notifications.Message{
+			Type:    notifications.TypeMeetingAnalyzed,
+			EventID: meta.ID,
+			Link:    "/meetings/" + e.MeetingID,
+			Data:    data,
+		}, meta.ActorID)
+	})
+}
+
+// notifySLABreach notifies the assignee of a ticket past its SLA deadline, or admins when
+// the ticket is unassigned
+func (h *Handler) notifySLABreach(_ context.Context, meta events.Meta, e events.TicketSLABreached) error {
+	var recipients []string
+	if e.AssigneeID != nil {
+		recipients = []string{*e.AssigneeID}
+	} else {
+		var admins []struct {
+			ID string `json:"id"`
+		}
+		if err := h.DB.From("employees").Select("id").In("role", []string{"admin", "super_admin"}).Execute(&admins); err != nil {
+			return err
+		}
+		for _, a := range admins {
+			recipients = append(recipients, a.ID)
+		}
+	}
+
+	return h.Notifications.Notify(notifications.Message{
+		Type:    notifications.TypeTicketSLABreached,
+		EventID: meta.ID,
+		Link:    "/service-desk",
+		Data: map[string]interface{}{
+			"ticket_id":    e.TicketID,
+			"number":       e.Number,
+			"title":        e.Title,
+			"priority":     e.Priority,
+			"sla_deadline": e.SLADeadline,
+		},
+	}, recipients...)
+}
+
+// exceptActor returns the set IDs other than the user who caused the event
+func exceptActor(actorID string, ids ...*string) []string {
+	var result []string
+	for _, id := range ids {
+		if id != nil && *id != "" && *id != actorID {
+			result = append(result, *id)
+		}
+	}
+	return result
+}
+
+// ListNotifications returns the user's inbox, newest first; ?unread=true for unread only
+func (h *Handler) ListNotifications(c *fiber.Ctx) error {
+	if h.Notifications == nil {
+		return c.Status(500).JSON(fiber.Map{"error": "Database not configured"})
+	}
+
+	userID, _ := c.Locals("user_id").(string)
+	query, page, err := paginate(c, h.Notifications.Inbox(userID, c.QueryBool("unread")), 50)
+	if err != nil {
+		return listError(c, err)
+	}
+	list, err := notifications.Scan(query)
+	if err != nil {
+		return listError(c, err)
+	}
+
+	page.setHeaders(c, len(list), func() string {
+		last := list[len(list)-1]
+		return pageCursor(last.CreatedAt, last.ID)
+	})
+	return c.JSON(list)
+}
+
+// GetUnreadNotificationCount returns the number of unread notifications of the user
+func (h *Handler) GetUnreadNotificationCount(c *fiber.Ctx) error {
+	if h.Notifications == nil {
+		return c.Status(500).JSON(fiber.Map{"error": "Database not configured"})
+	}
+
+	userID, _ := c.Locals("user_id").(string)
+	count, err := h.Notifications.UnreadCount(userID)
+	if err != nil {
+		return c.Status(500).JSON(fiber.Map{"error": err.Error()})
+	}
+	return c.JSON(fiber.Map{"count": count})
+}
+
+// MarkNotificationRead marks a notification of the user as read
+func (h *Handler) MarkNotificationRead(c *fiber.Ctx) error {
+	if h.Notifications == nil {
+		return c.Status(500).JSON(fiber.Map{"error": "Database not configured"})
+	}
+
+	userID, _ := c.Locals("user_id").(string)
+	if err := h.Notifications.MarkRead(userID, c.Params("id")); err != nil {
+		return notificationError(c, err)
+	}
+	return c.JSON(fiber.Map{"success": true})
+}
+
+// MarkAllNotificationsRead marks the whole inbox of the user as read
+func (h *Handler) MarkAllNotificationsRead(c *fiber.Ctx) error {
+	if h.Notifications == nil {
+		return c.Status(500).JSON(fiber.Map{"error": "Database not configured"})
+	}
+
+	userID, _ := c.Locals("user_id").(string)
+	updated, err := h.Notifications.MarkAllRead(userID)
+	if err != nil {
+		return c.Status(500).JSON(fiber.Map{"error": err.Error()})
+	}
+	return c.JSON(fiber.Map{"updated": updated})
+}
+
+// notificationPreferences is the body of the preferences endpoints
+type notificationPreferences struct {
+	Preferences []notifications.Preference `json:"preferences"`
+	Settings    *notifications.Settings    `json:"settings,omitempty"`
+}
+
+// GetNotificationPreferences returns the channels of the user per notification type,
+// quiet hours and digest time
+func (h *Handler) GetNotificationPreferences(c *fiber.Ctx) error {
+	if h.Notifications == nil {
+		return c.Status(500).JSON(fiber.Map{"error": "Database not configured"})
+	}
+
+	userID, _ := c.Locals("user_id").(string)
+	prefs, err := h.Notifications.Preferences(userID)
+	if err != nil {
+		return c.Status(500).JSON(fiber.Map{"error": err.Error()})
+	}
+	settings, err := h.Notifications.Settings(userID)
+	if err != nil {
+		return c.Status(500).JSON(fiber.Map{"error": err.Error()})
+	}
+	return c.JSON(notificationPreferences{Preferences: prefs, Settings: &settings})
+}
+
+// UpdateNotificationPreferences changes the given types and, when present, the settings
+func (h *Handler) UpdateNotificationPreferences(c *fiber.Ctx) error {
+	if h.Notifications == nil {
+		return c.Status(500).JSON(fiber.Map{"error": "Database not configured"})
+	}
+
+	var input notificationPreferences
+	if err := c.BodyParser(&input); err != nil {
+		return c.Status(400).JSON(fiber.Map{"error": "Invalid request body"})
+	}
+
+	userID, _ := c.Locals("user_id").(string)
+	if input.Settings != nil {
+		if err := h.Notifications.UpdateSettings(userID, *input.Settings); err != nil {
+			return notificationError(c, err)
+		}
+	}
+	if err := h.Notifications.UpdatePreferences(userID, input.Preferences); err != nil {
+		return notificationError(c, err)
+	}
+
+	return h.GetNotificationPreferences(c)
+}
+
+// notificationError maps notification errors to responses
+func notificationError(c *fiber.Ctx, err error) error {
+	switch {
+	case errors.Is(err, notifications.ErrNotFound):
+		return c.Status(404).JSON(fiber.Map{"error": "Notification not found"})
+	case errors.Is(err, notifications.ErrUnknownType), errors.Is(err, notifications.ErrInvalidSettings):
+		return c.Status(400).JSON(fiber.Map{"error": err.Error()})
+	}
+	return c.Status(500).JSON(fiber.Map{"error": err.Error()})
+}
@@ -0,0 +1,639 @@
+package handlers
+
+import (
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/ekf/one-on-one-backend/internal/ews"
+	"github.com/ekf/one-on-one-backend/internal/models"
+	"github.com/ekf/one-on-one-backend/internal/notifications"
+	"github.com/ekf/one-on-one-backend/internal/utils"
+	"github.com/ekf/one-on-one-backend/pkg/protocol"
+	"github.com/gofiber/fiber/v2"
+)
+
+// Cadence modes (CADENCE_MODE); any other value, e.g. "off", only reports overdue 1-on-1s
+const (
+	cadencePropose = "propose"
+	cadenceBook    = "book"
+)
+
+// One-on-one proposal statuses
+const (
+	proposalProposed = "proposed"
+	proposalBooked   = "booked"
+	proposalDeclined = "declined"
+	proposalExpired  = "expired"
+)
+
+const (
+	cadenceHistoryDays = 180 // meetings older than this count as "no 1-on-1 found"
+	slotStep           = 30 * time.Minute
+)
+
+// cadenceDays maps employees.meeting_frequency to days between 1-on-1s
+var cadenceDays = map[string]int{
+	"weekly":   7,
+	"biweekly": 14,
+	"monthly":  30,
+}
+
+func cadenceInterval(frequency string) int {
+	if days, ok := cadenceDays[frequency]; ok {
+		return days
+	}
+	return cadenceDays["weekly"]
+}
+
+// CadenceStatus describes how a report's 1-on-1s keep up with their cadence
+type CadenceStatus struct {
+	EmployeeID   string  `json:"employee_id"`
+	EmployeeName string  `json:"employee_name"`
+	ManagerID    string  `json:"manager_id"`
+	Frequency    string  `json:"frequency"`
+	MeetingDay   *string `json:"meeting_day,omitempty"`
+	LastMeeting  *string `json:"last_meeting,omitempty"` // nil - no 1-on-1 in the last 180 days
+	NextMeeting  *string `json:"next_meeting,omitempty"` // already scheduled 1-on-1
+	DueDate      string  `json:"due_date"`
+	Overdue      bool    `json:"overdue"`
+	DaysOverdue  int     `json:"days_overdue"`
+}
+
+// GetOneOnOneCadence returns cadence status of the current user's reports.
+// overdue=true returns only reports without a timely 1-on-1.
+func (h *Handler) GetOneOnOneCadence(c *fiber.Ctx) error {
+	if h.DB == nil {
+		return c.Status(500).JSON(fiber.Map{"error": "Database not configured"})
+	}
+
+	userID, _ := c.Locals("user_id").(string)
+	statuses := h.cadenceStatuses(userID, time.Now().In(h.location))
+
+	if c.QueryBool("overdue") {
+		overdue := []CadenceStatus{}
+		for _, s := range statuses {
+			if s.Overdue {
+				overdue = append(overdue, s)
+			}
+		}
+		statuses = overdue
+	}
+	if statuses == nil {
+		statuses = []CadenceStatus{}
+	}
+
+	return c.JSON(statuses)
+}
+
+// ListOneOnOneProposals returns slots proposed to the current user for overdue 1-on-1s
+func (h *Handler) ListOneOnOneProposals(c *fiber.Ctx) error {
+	if h.DB == nil {
+		return c.Status(500).JSON(fiber.Map{"error": "Database not configured"})
+	}
+
+	userID, _ := c.Locals("user_id").(string)
+	query := h.DB.From("one_on_one_proposals").Select("*, employees(id, name, position)").Eq("manager_id", userID)
+	if status := c.Query("status"); status != "" {
+		query = query.Eq("status", status)
+	}
+
+	var proposals []models.OneOnOneProposal
+	if err := query.Order("start_time", true).Limit(100).Execute(&proposals); err != nil {
+		return c.Status(500).JSON(fiber.Map{"error": err.Error()})
+	}
+	if proposals == nil {
+		proposals = []models.OneOnOneProposal{}
+	}
+
+	return c.JSON(proposals)
+}
+
+// AcceptOneOnOneProposal books the proposed slot in Exchange on behalf of the manager
+func (h *Handler) AcceptOneOnOneProposal(c *fiber.Ctx) error {
+	if h.DB == nil {
+		return c.Status(500).JSON(fiber.Map{"error": "Database not configured"})
+	}
+	if h.EWS == nil && !h.Connector.IsConnected() {
+		return c.Status(500).JSON(fiber.Map{"error": "EWS not configured"})
+	}
+
+	userID, _ := c.Locals("user_id").(string)
+	proposal, err := h.openProposal(c.Params("id"), userID)
+	if err != nil {
+		return c.Status(404).JSON(fiber.Map{"error": err.Error()})
+	}
+	if proposal.StartTime.Before(time.Now()) {
+		h.DB.Update("one_on_one_proposals", "id", proposal.ID, map[string]interface{}{"status": proposalExpired})
+		return c.Status(409).JSON(fiber.Map{"error": "Proposed slot is in the past"})
+	}
+
+	if err := h.bookOneOnOne(proposal, userID); err != nil {
+		return c.Status(502).JSON(fiber.Map{"error": err.Error()})
+	}
+
+	return c.JSON(proposal)
+}
+
+// DeclineOneOnOneProposal rejects a proposed slot; a new one is proposed on the next check
+// if the 1-on-1 is still overdue
+func (h *Handler) DeclineOneOnOneProposal(c *fiber.Ctx) error {
+	if h.DB == nil {
+		return c.Status(500).JSON(fiber.Map{"error": "Database not configured"})
+	}
+
+	userID, _ := c.Locals("user_id").(string)
+	proposal, err := h.openProposal(c.Params("id"), userID)
+	if err != nil {
+		return c.Status(404).JSON(fiber.Map{"error": err.Error()})
+	}
+
+	now := time.Now()
+	_, err = h.DB.Update("one_on_one_proposals", "id", proposal.ID, map[string]interface{}{
+		"status":     proposalDeclined,
+		"decided_by": userID,
+		"decided_at": now,
+	})
+	if err != nil {
+		return c.Status(500).JSON(fiber.Map{"error": err.Error()})
+	}
+
+	proposal.Status = proposalDeclined
+	proposal.DecidedBy = &userID
+	proposal.DecidedAt = &now
+	return c.JSON(proposal)
+}
+
+// openProposal loads a proposal of the manager that is still waiting for a decision
+func (h *Handler) openProposal(id, managerID string) (*models.OneOnOneProposal, error) {
+	var proposal models.OneOnOneProposal
+	err := h.DB.From("one_on_one_proposals").Select("*").Eq("id", id).Eq("manager_id", managerID).Single().Execute(&proposal)
+	if err != nil {
+		return nil, fmt.Errorf("proposal not found")
+	}
+	if proposal.Status != proposalProposed {
+		return nil, fmt.Errorf("proposal is already %s", proposal.Status)
+	}
+	return &proposal, nil
+}
+
+// cadenceStatuses computes cadence of every report of managerID (all managers if empty),
+// most overdue first
+func (h *Handler) cadenceStatuses(managerID string, now time.Time) []CadenceStatus {
+	query := h.DB.From("employees").Select("id, name, manager_id, meeting_frequency, meeting_day")
+	if managerID != "" {
+		query = query.Eq("manager_id", managerID)
+	}
+	var employees []models.Employee
+	query.Execute(&employees)
+
+	ids := make([]string, 0, len(employees))
+	for _, e := range employees {
+		if e.ManagerID != nil {
+			ids = append(ids, e.ID)
+		}
+	}
+	if len(ids) == 0 {
+		return nil
+	}
+
+	today := now.Format("2006-01-02")
+	var meetings []struct {
+		EmployeeID string `json:"employee_id"`
+		Date       string `json:"date"`
+	}
+	h.DB.From("meetings").Select("employee_id, date").In("employee_id", ids).
+		Gte("date", now.AddDate(0, 0, -cadenceHistoryDays).Format("2006-01-02")).Execute(&meetings)
+
+	last := make(map[string]string)
+	next := make(map[string]string)
+	for _, m := range meetings {
+		date := m.Date[:min(10, len(m.Date))]
+		if date < today {
+			if date > last[m.EmployeeID] {
+				last[m.EmployeeID] = date
+			}
+		} else if next[m.EmployeeID] == "" || date < next[m.EmployeeID] {
+			next[m.EmployeeID] = date
+		}
+	}
+
+	todayDate, _ := time.ParseInLocation("2006-01-02", today, h.location)
+	var statuses []CadenceStatus
+	for _, e := range employees {
+		if e.ManagerID == nil {
+			continue
+		}
+
+		status := CadenceStatus{
+			EmployeeID:   e.ID,
+			EmployeeName: e.Name,
+			ManagerID:    *e.ManagerID,
+			Frequency:    e.MeetingFrequency,
+			MeetingDay:   e.MeetingDay,
+			DueDate:      today,
+		}
+		if date, ok := last[e.ID]; ok {
+			status.LastMeeting = &date
+			lastDate, _ := time.ParseInLocation("2006-01-02", date, h.location)
+			status.DueDate = lastDate.AddDate(0, 0, cadenceInterval(e.MeetingFrequency)).Format("2006-01-02")
+		}
+		if date, ok := next[e.ID]; ok {
+			status.NextMeeting = &date
+		}
+
+		if status.NextMeeting == nil && (status.LastMeeting == nil || status.DueDate < today) {
+			status.Overdue = true
+			dueDate, _ := time.ParseInLocation("2006-01-02", status.DueDate, h.location)
+			status.DaysOverdue = int(todayDate.Sub(dueDate).Hours() / 24)
+		}
+		statuses = append(statuses, status)
+	}
+
+	sort.SliceStable(statuses, func(i, j int) bool {
+		if statuses[i].Overdue != statuses[j].Overdue {
+			return statuses[i].Overdue
+		}
+		return statuses[i].DaysOverdue > statuses[j].DaysOverdue
+	})
+	return statuses
+}
+
+// enforceCadence expires stale proposals and, for each overdue 1-on-1 without an open
+// proposal, finds a free slot and proposes or books it depending on CADENCE_MODE
+func (h *Handler) enforceCadence(now time.Time) {
+	var stale []models.OneOnOneProposal
+	h.DB.From("one_on_one_proposals").Select("id").Eq("status", proposalProposed).
+		Lte("start_time", now.Format(time.RFC3339)).Execute(&stale)
+	for _, p := range stale {
+		h.DB.Update("one_on_one_proposals", "id", p.ID, map[string]interface{}{"status": proposalExpired})
+	}
+
+	mode := strings.ToLower(h.Config.CadenceMode)
+	if mode != cadencePropose && mode != cadenceBook {
+		return
+	}
+
+	// Open proposals keep their slots: a manager with several overdue reports must not get
+	// the same slot proposed (or booked) for each of them
+	var open []models.OneOnOneProposal
+	h.DB.From("one_on_one_proposals").Select("employee_id, manager_id, start_time, end_time").
+		Eq("status", proposalProposed).Execute(&open)
+	hasProposal := make(map[string]bool, len(open))
+	reserved := make(map[string][]busyInterval)
+	reserve := func(p *models.OneOnOneProposal) {
+		slot := busyInterval{p.StartTime, p.EndTime}
+		reserved[p.ManagerID] = append(reserved[p.ManagerID], slot)
+		reserved[p.EmployeeID] = append(reserved[p.EmployeeID], slot)
+	}
+	for i := range open {
+		hasProposal[open[i].EmployeeID] = true
+		reserve(&open[i])
+	}
+
+	var employees []models.Employee
+	h.DB.From("employees").Select("id, name, email").Execute(&employees)
+	emails := make(map[string]string, len(employees))
+	for _, e := range employees {
+		emails[e.ID] = e.Email
+	}
+
+	for _, status := range h.cadenceStatuses("", now) {
+		if !status.Overdue || hasProposal[status.EmployeeID] {
+			continue
+		}
+
+		taken := append(append([]busyInterval(nil), reserved[status.ManagerID]...), reserved[status.EmployeeID]...)
+		start, end, err := h.findOneOnOneSlot(emails[status.ManagerID], emails[status.EmployeeID], status.MeetingDay, now, taken)
+		if err != nil {
+			utils.GetLogger().Warn("No slot found for overdue 1-on-1", map[string]interface{}{
+				"employee_id": status.EmployeeID,
+				"manager_id":  status.ManagerID,
+				"error":       err.Error(),
+			})
+			continue
+		}
+
+		proposal, err := h.createProposal(status, start, end)
+		if err != nil {
+			utils.GetLogger().Warn("Failed to save 1-on-1 proposal", map[string]interface{}{
+				"employee_id": status.EmployeeID,
+				"error":       err.Error(),
+			})
+			continue
+		}
+		reserve(proposal)
+
+		// Booking needs the manager's stored EWS credentials; without them the slot stays proposed
+		if mode == cadenceBook {
+			h.bookOneOnOne(proposal, "")
+		}
+		h.notifyProposal(proposal, status.EmployeeName)
+	}
+}
+
+func (h *Handler) createProposal(status CadenceStatus, start, end time.Time) (*models.OneOnOneProposal, error) {
+	result, err := h.DB.Insert("one_on_one_proposals", map[string]interface{}{
+		"employee_id":       status.EmployeeID,
+		"manager_id":        status.ManagerID,
+		"start_time":        start,
+		"end_time":          end,
+		"status":            proposalProposed,
+		"last_meeting_date": status.LastMeeting,
+		"due_date":          status.DueDate,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	var created []models.OneOnOneProposal
+	if err := json.Unmarshal(result, &created); err != nil || len(created) == 0 {
+		return nil, fmt.Errorf("failed to read created proposal")
+	}
+	return &created[0], nil
+}
+
+// busyInterval is a time range in which a participant cannot meet
+type busyInterval struct{ start, end time.Time }
+
+// findOneOnOneSlot returns the first working-hours slot after now when both the manager
+// and the employee are free, trying the employee's meeting_day first. reserved are slots
+// taken in addition to the EWS free/busy, such as open proposals.
+func (h *Handler) findOneOnOneSlot(managerEmail, employeeEmail string, meetingDay *string, now time.Time, reserved []busyInterval) (time.Time, time.Time, error) {
+	if h.EWS == nil || h.Config.EWSUsername == "" {
+		return time.Time{}, time.Time{}, fmt.Errorf("EWS not configured")
+	}
+	if managerEmail == "" || employeeEmail == "" {
+		return time.Time{}, time.Time{}, fmt.Errorf("manager or employee has no email")
+	}
+
+	// Working days starting tomorrow, preferred weekday first
+	var preferred, other []time.Time
+	day := time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, h.location)
+	for len(preferred)+len(other) < h.Config.CadenceSearchDays {
+		day = day.AddDate(0, 0, 1)
+		if day.Weekday() == time.Saturday || day.Weekday() == time.Sunday {
+			continue
+		}
+		if meetingDay != nil && strings.EqualFold(day.Weekday().String(), *meetingDay) {
+			preferred = append(preferred, day)
+		} else {
+			other = append(other, day)
+		}
+	}
+	days := append(preferred, other...)
+	if len(days) == 0 {
+		return time.Time{}, time.Time{}, fmt.Errorf("no working days to search")
+	}
+
+	first, last := days[0], days[0]
+	for _, d := range days {
+		if d.Before(first) {
+			first = d
+		}
+		if d.After(last) {
+			last = d
+		}
+	}
+
+	emails := []string{managerEmail, employeeEmail}
+	freeBusy, err := h.EWS.GetFreeBusy(emails, h.Config.EWSUsername, h.Config.EWSPassword,
+		first.Format("2006-01-02"), last.Format("2006-01-02"))
+	if err != nil {
+		return time.Time{}, time.Time{}, fmt.Errorf("free/busy: %w", err)
+	}
+
+	busy := reserved
+	for _, email := range emails {
+		for _, b := range freeBusy[email] {
+			if strings.EqualFold(b.Status, "Free") {
+				continue
+			}
+			start, err1 := h.parseEWSTime(b.Start)
+			end, err2 := h.parseEWSTime(b.End)
+			if err1 == nil && err2 == nil {
+				busy = append(busy, busyInterval{start, end})
+			}
+		}
+	}
+
+	duration := time.Duration(h.Config.CadenceMeetingMinutes) * time.Minute
+	start, ok := firstFreeSlot(days, busy, h.Config.CadenceWorkdayStart, h.Config.CadenceWorkdayEnd, duration)
+	if !ok {
+		return time.Time{}, time.Time{}, fmt.Errorf("no common free slot in the next %d working days", h.Config.CadenceSearchDays)
+	}
+	return start, start.Add(duration), nil
+}
+
+// firstFreeSlot returns the start of the first slot of duration within working hours
+// of days, in their order, that overlaps no busy interval
+func firstFreeSlot(days []time.Time, busy []busyInterval, dayStartHour, dayEndHour int, duration time.Duration) (time.Time, bool) {
+	for _, d := range days {
+		dayEnd := d.Add(time.Duration(dayEndHour) * time.Hour)
+		for start := d.Add(time.Duration(dayStartHour) * time.Hour); !start.Add(duration).After(dayEnd); start = start.Add(slotStep) {
+			end := start.Add(duration)
+			free := true
+			for _, b := range busy {
+				if start.Before(b.end) && end.After(b.start) {
+					free = false
+					break
+				}
+			}
+			if free {
+				return start, true
+			}
+		}
+	}
+	return time.Time{}, false
+}
+
+// parseEWSTime parses free/busy times, which EWS returns in the request timezone without offset
+func (h *Handler) parseEWSTime(value string) (time.Time, error) {
+	if t, err := time.Parse(time.RFC3339, value); err == nil {
+		return t, nil
+	}
+	return time.ParseInLocation("2006-01-02T15:04:05", value, h.location)
+}
+
+// bookOneOnOne creates the meeting in Exchange with the manager as organizer and records it.
+// decidedBy is empty when booked by the scheduler. On failure the error is kept on the
+// proposal, which stays open for the manager.
+func (h *Handler) bookOneOnOne(proposal *models.OneOnOneProposal, decidedBy string) error {
+	err := h.createOneOnOneMeeting(proposal, decidedBy)
+	if err != nil {
+		h.DB.Update("one_on_one_proposals", "id", proposal.ID, map[string]interface{}{"error": err.Error()})
+		msg := err.Error()
+		proposal.Error = &msg
+	}
+	return err
+}
+
+func (h *Handler) createOneOnOneMeeting(proposal *models.OneOnOneProposal, decidedBy string) error {
+	var manager struct {
+		Name              string  `json:"name"`
+		ADLogin           *string `json:"ad_login"`
+		EncryptedPassword *string `json:"encrypted_password"`
+	}
+	if err := h.DB.From("employees").Select("name, ad_login, encrypted_password").Eq("id", proposal.ManagerID).Single().Execute(&manager); err != nil {
+		return fmt.Errorf("manager not found")
+	}
+	if manager.ADLogin == nil || manager.EncryptedPassword == nil || *manager.EncryptedPassword == "" {
+		return fmt.Errorf("EWS credentials not configured for the manager")
+	}
+	password, err := utils.DecryptPassword(*manager.EncryptedPassword, h.Config.JWTSecret)
+	if err != nil {
+		return fmt.Errorf("failed to decrypt manager credentials")
+	}
+
+	var employee models.Employee
+	if err := h.DB.From("employees").Select("id, name, email").Eq("id", proposal.EmployeeID).Single().Execute(&employee); err != nil || employee.Email == "" {
+		return fmt.Errorf("employee has no email")
+	}
+
+	start := proposal.StartTime.In(h.location)
+	end := proposal.EndTime.In(h.location)
+	subject := "1-на-1: " + manager.Name + " / " + employee.Name
+	ewsReq := ews.CreateMeetingRequest{
+		Subject:           subject,
+		Start:             start.Format(time.RFC3339),
+		End:               end.Format(time.RFC3339),
+		RequiredAttendees: []string{employee.Email},
+		IsOnlineMeeting:   true,
+	}
+
+	username := h.Config.EWSDomain + "\\" + *manager.ADLogin
+	mail := h.exchange(protocol.CommandCreateCalendarItem, username)
+	if mail == nil {
+		return fmt.Errorf("EWS not configured")
+	}
+	itemID, err := mail.CreateCalendarItem(username, password, ewsReq)
+	if err != nil {
+		return err
+	}
+
+	var categories []models.MeetingCategory
+	h.DB.From("meeting_categories").Select("id, code").Eq("code", "one_on_one").Execute(&categories)
+	meetingData := map[string]interface{}{
+		"title":        subject,
+		"date":         start.Format("2006-01-02"),
+		"start_time":   start.Format(time.RFC3339),
+		"end_time":     end.Format(time.RFC3339),
+		"employee_id":  proposal.EmployeeID,
+		"organizer_id": proposal.ManagerID,
+		"exchange_id":  nilIfEmpty(itemID),
+	}
+	if len(categories) > 0 {
+		meetingData["category_id"] = categories[0].ID
+	}
+
+	meetingID := ""
+	if result, err := h.DB.Insert("meetings", meetingData); err == nil {
+		var created []map[string]interface{}
+		json.Unmarshal(result, &created)
+		if len(created) > 0 {
+			meetingID, _ = created[0]["id"].(string)
+		}
+	}
+	if meetingID != "" {
+		for _, id := range []string{proposal.ManagerID, proposal.EmployeeID} {
+			h.DB.Insert("meeting_participants", map[string]interface{}{"meeting_id": meetingID, "employee_id": id})
+		}
+	}
+
+	now := time.Now()
+	h.DB.Update("one_on_one_proposals", "id", proposal.ID, map[string]interface{}{
+		"status":      proposalBooked,
+		"meeting_id":  nilIfEmpty(meetingID),
+		"exchange_id": nilIfEmpty(itemID),
+		"error":       nil,
+		"decided_by":  nilIfEmpty(decidedBy),
+		"decided_at":  now,
+	})
+
+	proposal.Status = proposalBooked
+	proposal.Error = nil
+	proposal.DecidedAt = &now
+	if meetingID != "" {
+		proposal.MeetingID = &meetingID
+	}
+	if itemID != "" {
+		proposal.ExchangeID = &itemID
+	}
+	if decidedBy != "" {
+		proposal.DecidedBy = &decidedBy
+	}
+	return nil
+}
+
+// notifyProposal tells the manager about a booked or proposed 1-on-1
+func (h *Handler) notifyProposal(proposal *models.OneOnOneProposal, employeeName string) {
+	h.notify(notifications.Message{
+		Type: notifications.TypeOneOnOneProposal,
+		Link: "/employees/" + proposal.EmployeeID,
+		Data: map[string]interface{}{
+			"proposal_id":   proposal.ID,
+			"employee_id":   proposal.EmployeeID,
+			"employee_name": employeeName,
+			"status":        proposal.Status,
+			"start_time":    proposal.StartTime,
+			"error":         stringValue(proposal.Error),
+		},
+	}, proposal.ManagerID)
+}
+
+// sendCadenceDigests sends each manager the list of reports with overdue 1-on-1s, once a week
+func (h *Handler) sendCadenceDigests(now time.Time) {
+	weekStart := now.AddDate(0, 0, -((int(now.Weekday()) + 6) % 7)).Format("2006-01-02")
+
+	var sent []struct {
+		ManagerID string `json:"manager_id"`
+	}
+	h.DB.From("one_on_one_digests").Select("manager_id").Eq("week_start", weekStart).Execute(&sent)
+	alreadySent := make(map[string]bool, len(sent))
+	for _, s := range sent {
+		alreadySent[s.ManagerID] = true
+	}
+
+	overdue := make(map[string][]CadenceStatus)
+	for _, s := range h.cadenceStatuses("", now) {
+		if s.Overdue && !alreadySent[s.ManagerID] {
+			overdue[s.ManagerID] = append(overdue[s.ManagerID], s)
+		}
+	}
+
+	channels := []string{channelEmail, channelTelegram}
+	for managerID, statuses := range overdue {
+		var manager models.Employee
+		if err := h.DB.From("employees").Select("id, name, email, telegram_chat_id, locale").Eq("id", managerID).Single().Execute(&manager); err != nil {
+			continue
+		}
+
+		items := make([]map[string]interface{}, len(statuses))
+		for i, s := range statuses {
+			items[i] = map[string]interface{}{
+				"employee_name": s.EmployeeName,
+				"link":          "/employees/" + s.EmployeeID,
+				"last_meeting":  stringValue(s.LastMeeting),
+				"days_overdue":  s.DaysOverdue,
+			}
+		}
+		data := map[string]interface{}{"statuses": items, "history_days": cadenceHistoryDays}
+
+		delivered, errs := h.sendToEmployee(manager, "one_on_one.digest", data, channels)
+		if len(delivered) == 0 {
+			utils.GetLogger().Warn("Failed to deliver 1-on-1 digest", map[string]interface{}{
+				"manager_id": managerID,
+				"error":      strings.Join(errs, "; "),
+			})
+		}
+
+		// Recorded even when undelivered so a manager without contacts is not retried all day
+		h.DB.Insert("one_on_one_digests", map[string]interface{}{
+			"manager_id":    managerID,
+			"week_start":    weekStart,
+			"overdue_count": len(statuses),
+		})
+	}
+}
@@ -0,0 +1,45 @@
+package handlers
+
+import (
+	"testing"
+	"time"
+)
+
+func TestFirstFreeSlot(t *testing.T) {
+	monday := time.Date(2026, 3, 2, 0, 0, 0, 0, time.UTC)
+	tuesday := monday.AddDate(0, 0, 1)
+	at := func(day time.Time, hour, minute int) time.Time {
+		return day.Add(time.Duration(hour)*time.Hour + time.Duration(minute)*time.Minute)
+	}
+	hour := time.Hour
+
+	tests := []struct {
+		name   string
+		days   []time.Time
+		busy   []busyInterval
+		want   time.Time
+		wantOK bool
+	}{
+		{"free day", []time.Time{monday}, nil, at(monday, 10, 0), true},
+		{"busy morning", []time.Time{monday}, []busyInterval{{at(monday, 10, 0), at(monday, 11, 15)}}, at(monday, 11, 30), true},
+		{"slot touching a busy interval", []time.Time{monday}, []busyInterval{{at(monday, 11, 0), at(monday, 12, 0)}}, at(monday, 10, 0), true},
+		{
+			// A slot proposed earlier in the same run is busy for the next report
+			"reserved proposals",
+			[]time.Time{monday},
+			[]busyInterval{{at(monday, 10, 0), at(monday, 11, 0)}, {at(monday, 11, 0), at(monday, 12, 0)}},
+			at(monday, 12, 0), true,
+		},
+		{"preferred day first", []time.Time{tuesday, monday}, nil, at(tuesday, 10, 0), true},
+		{"full day", []time.Time{monday}, []busyInterval{{at(monday, 9, 0), at(monday, 19, 0)}}, time.Time{}, false},
+		{"no days", nil, nil, time.Time{}, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, ok := firstFreeSlot(tt.days, tt.busy, 10, 18, hour)
+			if ok != tt.wantOK || !got.Equal(tt.want) {
+				t.Errorf("firstFreeSlot() = %v, %v, want %v, %v", got, ok, tt.want, tt.wantOK)
+			}
+		})
+	}
+}
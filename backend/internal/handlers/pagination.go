@@ -0,0 +1,67 @@
+package handlers
+
+import (
+	"errors"
+	"strconv"
+	"time"
+
+	"github.com/ekf/one-on-one-backend/internal/database"
+	"github.com/gofiber/fiber/v2"
+)
+
+// List endpoints use keyset pagination, newest first: ?limit=50 returns the first page
+// and an X-Next-Cursor header, which is passed back as ?cursor= for the next page.
+// X-Total-Count holds the number of rows matching the filters. Without limit the default
+// page size of the endpoint applies, 0 returns the whole list.
+
+const maxPageSize = 200
+
+// searchConfig is the text search configuration of the search_vector columns
+const searchConfig = "russian"
+
+type listPage struct {
+	limit int
+	total int
+}
+
+// paginate counts the rows matching query and applies the limit and cursor query parameters
+func paginate(c *fiber.Ctx, query database.QueryBuilder, defaultLimit int) (database.QueryBuilder, *listPage, error) {
+	total, err := query.Count()
+	if err != nil {
+		return nil, nil, err
+	}
+
+	page := &listPage{limit: min(c.QueryInt("limit", defaultLimit), maxPageSize), total: total}
+	query = query.Keyset(c.Query("cursor"), true, "created_at", "id")
+	if page.limit > 0 {
+		query = query.Limit(page.limit)
+	}
+	return query, page, nil
+}
+
+// setHeaders sets the total and, when the page is full, the cursor of the next page.
+// next is only called then and builds the cursor from the last row.
+func (p *listPage) setHeaders(c *fiber.Ctx, rows int, next func() string) {
+	c.Set("X-Total-Count", strconv.Itoa(p.total))
+	if p.limit > 0 && rows == p.limit {
+		if cursor := next(); cursor != "" {
+			c.Set("X-Next-Cursor", cursor)
+		}
+	}
+}
+
+// pageCursor builds the cursor of the page following a row
+func pageCursor(createdAt *time.Time, id string) string {
+	if createdAt == nil {
+		return ""
+	}
+	return database.EncodeCursor(createdAt.Format(time.RFC3339Nano), id)
+}
+
+// listError reports a failed list query
+func listError(c *fiber.Ctx, err error) error {
+	if errors.Is(err, database.ErrInvalidCursor) {
+		return c.Status(400).JSON(fiber.Map{"error": "Invalid cursor"})
+	}
+	return c.Status(500).JSON(fiber.Map{"error": err.Error()})
+}
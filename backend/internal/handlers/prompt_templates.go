@@ -0,0 +1,407 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strconv"
+
+	"github.com/ekf/one-on-one-backend/internal/database"
+	"github.com/ekf/one-on-one-backend/internal/models"
+	"github.com/ekf/one-on-one-backend/pkg/ai"
+	"github.com/gofiber/fiber/v2"
+)
+
+// promptVersionAttempts bounds retries of a create that lost the race for a version number
+const promptVersionAttempts = 3
+
+// ListPromptTemplates returns analysis prompt status for every meeting category
+func (h *Handler) ListPromptTemplates(c *fiber.Ctx) error {
+	if h.DB == nil {
+		return c.Status(500).JSON(fiber.Map{"error": "Database not configured"})
+	}
+
+	var active []models.PromptTemplate
+	err := h.DB.From("prompt_templates").Select("*").Eq("is_active", true).Execute(&active)
+	if err != nil {
+		return c.Status(500).JSON(fiber.Map{"error": err.Error()})
+	}
+	activeByCategory := make(map[string]models.PromptTemplate)
+	for _, t := range active {
+		activeByCategory[t.CategoryCode] = t
+	}
+
+	var result []fiber.Map
+	for _, code := range h.promptCategories() {
+		entry := fiber.Map{
+			"category_code":  code,
+			"active_version": nil,
+			"source":         "builtin",
+		}
+		if t, ok := activeByCategory[code]; ok {
+			entry["active_version"] = t.Version
+			entry["source"] = "database"
+			entry["updated_at"] = t.CreatedAt
+		}
+		result = append(result, entry)
+	}
+
+	return c.JSON(result)
+}
+
+// GetPromptTemplateHistory returns all versions of a category prompt and the built-in default
+func (h *Handler) GetPromptTemplateHistory(c *fiber.Ctx) error {
+	if h.DB == nil {
+		return c.Status(500).JSON(fiber.Map{"error": "Database not configured"})
+	}
+
+	category := c.Params("category")
+
+	var versions []models.PromptTemplate
+	err := h.DB.From("prompt_templates").Select("*").
+		Eq("category_code", category).Order("version", true).Execute(&versions)
+	if err != nil {
+		return c.Status(500).JSON(fiber.Map{"error": err.Error()})
+	}
+	if versions == nil {
+		versions = []models.PromptTemplate{}
+	}
+
+	return c.JSON(fiber.Map{
+		"category_code":    category,
+		"builtin_template": ai.DefaultPrompt(category),
+		"versions":         versions,
+	})
+}
+
+// CreatePromptTemplateVersion saves a new prompt version, optionally making it active
+func (h *Handler) CreatePromptTemplateVersion(c *fiber.Ctx) error {
+	if h.DB == nil {
+		return c.Status(500).JSON(fiber.Map{"error": "Database not configured"})
+	}
+
+	userID, _ := c.Locals("user_id").(string)
+	category := c.Params("category")
+
+	var req struct {
+		Template string `json:"template"`
+		Comment  string `json:"comment"`
+		Activate bool   `json:"activate"`
+	}
+	if err := c.BodyParser(&req); err != nil {
+		return c.Status(400).JSON(fiber.Map{"error": "Invalid request body"})
+	}
+
+	if !h.isPromptCategory(category) {
+		return c.Status(400).JSON(fiber.Map{"error": "Unknown meeting category: " + category})
+	}
+	if req.Template == "" {
+		return c.Status(400).JSON(fiber.Map{"error": "Template is required"})
+	}
+	if err := ai.ValidatePromptTemplate(req.Template); err != nil {
+		return c.Status(400).JSON(fiber.Map{"error": "Invalid template: " + err.Error()})
+	}
+
+	// Concurrent creates may pick the same version; the loser retries with the next one
+	var created models.PromptTemplate
+	var err error
+	for attempt := 0; attempt < promptVersionAttempts; attempt++ {
+		err = h.inTx(c.UserContext(), func(tx *Handler) error {
+			template, err := tx.insertPromptTemplate(category, req.Template, req.Comment, userID)
+			if err != nil {
+				return err
+			}
+			if req.Activate {
+				if err := tx.activatePromptTemplate(category, template.ID); err != nil {
+					return err
+				}
+				template.IsActive = true
+			}
+			created = *template
+			return nil
+		})
+		if !database.IsUniqueViolation(err) {
+			break
+		}
+	}
+	if err != nil {
+		return c.Status(500).JSON(fiber.Map{"error": err.Error()})
+	}
+
+	h.createAuditLog(c, userID, "prompt_template.create", "prompt_template", created.ID, nil,
+		map[string]interface{}{"category_code": category, "version": created.Version, "active": req.Activate})
+
+	return c.Status(201).JSON(created)
+}
+
+// ActivatePromptTemplateVersion makes a stored version active (also used for rollback)
+func (h *Handler) ActivatePromptTemplateVersion(c *fiber.Ctx) error {
+	if h.DB == nil {
+		return c.Status(500).JSON(fiber.Map{"error": "Database not configured"})
+	}
+
+	userID, _ := c.Locals("user_id").(string)
+	category := c.Params("category")
+
+	template, err := h.promptTemplateVersion(category, c.Params("version"))
+	if err != nil {
+		return c.Status(404).JSON(fiber.Map{"error": "Prompt version not found"})
+	}
+
+	var previous []models.PromptTemplate
+	h.DB.From("prompt_templates").Select("version").
+		Eq("category_code", category).Eq("is_active", true).Execute(&previous)
+
+	if err := h.activatePromptTemplate(category, template.ID); err != nil {
+		return c.Status(500).JSON(fiber.Map{"error": err.Error()})
+	}
+
+	oldValue := map[string]interface{}{"version": nil}
+	if len(previous) > 0 {
+		oldValue["version"] = previous[0].Version
+	}
+	h.createAuditLog(c, userID, "prompt_template.activate", "prompt_template", template.ID,
+		oldValue, map[string]interface{}{"version": template.Version})
+
+	template.IsActive = true
+	return c.JSON(template)
+}
+
+// DeactivatePromptTemplates switches a category back to the built-in prompt
+func (h *Handler) DeactivatePromptTemplates(c *fiber.Ctx) error {
+	if h.DB == nil {
+		return c.Status(500).JSON(fiber.Map{"error": "Database not configured"})
+	}
+
+	userID, _ := c.Locals("user_id").(string)
+	category := c.Params("category")
+
+	active := h.activePromptTemplate(category)
+	if active == nil || active.CategoryCode != category {
+		return c.JSON(fiber.Map{"success": true})
+	}
+
+	if _, err := h.DB.Update("prompt_templates", "id", active.ID, map[string]interface{}{"is_active": false}); err != nil {
+		return c.Status(500).JSON(fiber.Map{"error": err.Error()})
+	}
+
+	h.createAuditLog(c, userID, "prompt_template.deactivate", "prompt_template", active.ID,
+		map[string]interface{}{"version": active.Version}, map[string]interface{}{"version": nil})
+
+	return c.JSON(fiber.Map{"success": true})
+}
+
+// PreviewPromptTemplate renders a prompt against a past meeting and, unless dry_run is set,
+// runs the analysis without saving anything. The template comes from the request body,
+// a stored version, or the currently active prompt, in that order.
+func (h *Handler) PreviewPromptTemplate(c *fiber.Ctx) error {
+	if h.DB == nil {
+		return c.Status(500).JSON(fiber.Map{"error": "Database not configured"})
+	}
+	if h.AI == nil {
+		return c.Status(500).JSON(fiber.Map{"error": "AI not configured"})
+	}
+
+	category := c.Params("category")
+
+	var req struct {
+		MeetingID string `json:"meeting_id"`
+		Template  string `json:"template"`
+		Version   int    `json:"version"`
+		DryRun    bool   `json:"dry_run"` // Only render the prompt, do not call the LLM
+	}
+	if err := c.BodyParser(&req); err != nil {
+		return c.Status(400).JSON(fiber.Map{"error": "Invalid request body"})
+	}
+	if req.MeetingID == "" {
+		return c.Status(400).JSON(fiber.Map{"error": "meeting_id is required"})
+	}
+
+	promptTemplate := req.Template
+	switch {
+	case promptTemplate != "":
+	case req.Version > 0:
+		template, err := h.promptTemplateVersion(category, strconv.Itoa(req.Version))
+		if err != nil {
+			return c.Status(404).JSON(fiber.Map{"error": "Prompt version not found"})
+		}
+		promptTemplate = template.Template
+	default:
+		promptTemplate = ai.DefaultPrompt(category)
+		if active := h.activePromptTemplate(category); active != nil {
+			promptTemplate = active.Template
+		}
+	}
+	if err := ai.ValidatePromptTemplate(promptTemplate); err != nil {
+		return c.Status(400).JSON(fiber.Map{"error": "Invalid template: " + err.Error()})
+	}
+
+	analysisCtx, err := h.meetingAnalysisContext(req.MeetingID)
+	if err != nil {
+		return c.Status(404).JSON(fiber.Map{"error": "Meeting not found or has no transcript"})
+	}
+
+	prompt, err := h.AI.RenderPrompt(promptTemplate, analysisCtx)
+	if err != nil {
+		return c.Status(400).JSON(fiber.Map{"error": "Invalid template: " + err.Error()})
+	}
+
+	response := fiber.Map{
+		"category_code": category,
+		"meeting_id":    req.MeetingID,
+		"prompt":        prompt,
+	}
+	if req.DryRun {
+		return c.JSON(response)
+	}
+
+	analysis, err := h.AI.AnalyzeWithPrompt(category, promptTemplate, analysisCtx)
+	if err != nil {
+		response["error"] = err.Error()
+		return c.Status(502).JSON(response)
+	}
+	response["analysis"] = analysis
+
+	return c.JSON(response)
+}
+
+// activePromptTemplate returns the active prompt for a category, nil if the built-in prompt applies.
+// Categories without a built-in prompt fall back to the active "default" version.
+func (h *Handler) activePromptTemplate(categoryCode string) *models.PromptTemplate {
+	if h.DB == nil {
+		return nil
+	}
+
+	codes := []string{categoryCode}
+	if _, ok := ai.Prompts[categoryCode]; !ok && categoryCode != "default" {
+		codes = append(codes, "default")
+	}
+
+	for _, code := range codes {
+		var template models.PromptTemplate
+		err := h.DB.From("prompt_templates").Select("*").
+			Eq("category_code", code).Eq("is_active", true).Single().Execute(&template)
+		if err == nil {
+			return &template
+		}
+	}
+	return nil
+}
+
+func (h *Handler) promptTemplateVersion(category, version string) (*models.PromptTemplate, error) {
+	var template models.PromptTemplate
+	err := h.DB.From("prompt_templates").Select("*").
+		Eq("category_code", category).Eq("version", version).Single().Execute(&template)
+	if err != nil {
+		return nil, err
+	}
+	return &template, nil
+}
+
+// insertPromptTemplate stores a template as the next version of the category
+func (h *Handler) insertPromptTemplate(category, template, comment, userID string) (*models.PromptTemplate, error) {
+	var latest []models.PromptTemplate
+	err := h.DB.From("prompt_templates").Select("version").
+		Eq("category_code", category).Order("version", true).Limit(1).Execute(&latest)
+	if err != nil {
+		return nil, err
+	}
+	version := 1
+	if len(latest) > 0 {
+		version = latest[0].Version + 1
+	}
+
+	result, err := h.DB.Insert("prompt_templates", map[string]interface{}{
+		"category_code": category,
+		"version":       version,
+		"template":      template,
+		"comment":       nilIfEmpty(comment),
+		"created_by":    nilIfEmpty(userID),
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	var created []models.PromptTemplate
+	json.Unmarshal(result, &created)
+	if len(created) == 0 {
+		return nil, fmt.Errorf("prompt version was not created")
+	}
+	return &created[0], nil
+}
+
+// activatePromptTemplate deactivates all versions of the category, then activates one,
+// in a single transaction
+func (h *Handler) activatePromptTemplate(category, id string) error {
+	return h.DB.WithTx(context.Background(), func(tx database.DBClient) error {
+		if _, err := tx.Update("prompt_templates", "category_code", category, map[string]interface{}{"is_active": false}); err != nil {
+			return err
+		}
+		_, err := tx.Update("prompt_templates", "id", id, map[string]interface{}{"is_active": true})
+		return err
+	})
+}
+
+// promptCategories returns meeting category codes plus "default"
+func (h *Handler) promptCategories() []string {
+	var categories []models.MeetingCategory
+	h.DB.From("meeting_categories").Select("code").Order("code", false).Execute(&categories)
+
+	codes := make([]string, 0, len(categories)+1)
+	for _, cat := range categories {
+		codes = append(codes, cat.Code)
+	}
+	return append(codes, "default")
+}
+
+func (h *Handler) isPromptCategory(code string) bool {
+	for _, c := range h.promptCategories() {
+		if c == code {
+			return true
+		}
+	}
+	return false
+}
+
+// meetingAnalysisContext rebuilds the analysis context of a stored meeting
+func (h *Handler) meetingAnalysisContext(meetingID string) (ai.AnalysisContext, error) {
+	var meeting struct {
+		ID               string  `json:"id"`
+		Date             string  `json:"date"`
+		EmployeeID       *string `json:"employee_id"`
+		ProjectID        *string `json:"project_id"`
+		Transcript       *string `json:"transcript"`
+		TranscriptMerged *string `json:"transcript_merged"`
+	}
+	err := h.DB.From("meetings").Select("id, date, employee_id, project_id, transcript, transcript_merged").
+		Eq("id", meetingID).Single().Execute(&meeting)
+	if err != nil {
+		return ai.AnalysisContext{}, err
+	}
+
+	transcript := ""
+	if meeting.TranscriptMerged != nil {
+		transcript = *meeting.TranscriptMerged
+	} else if meeting.Transcript != nil {
+		transcript = *meeting.Transcript
+	}
+	if transcript == "" {
+		return ai.AnalysisContext{}, fiber.ErrNotFound
+	}
+
+	params := models.MeetingJobParams{MeetingDate: meeting.Date}
+	if meeting.EmployeeID != nil {
+		params.EmployeeID = *meeting.EmployeeID
+	}
+	if meeting.ProjectID != nil {
+		params.ProjectID = *meeting.ProjectID
+	}
+
+	var participants []models.MeetingParticipant
+	h.DB.From("meeting_participants").Select("employee_id").Eq("meeting_id", meetingID).Execute(&participants)
+	for _, p := range participants {
+		params.ParticipantIDs = append(params.ParticipantIDs, p.EmployeeID)
+	}
+
+	return h.buildAnalysisContext(params, meetingID, transcript, h.meetingSegments(meetingID)), nil
+}
@@ -0,0 +1,397 @@
+package handlers
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/ekf/one-on-one-backend/internal/events"
+	"github.com/ekf/one-on-one-backend/internal/scheduler"
+	"github.com/ekf/one-on-one-backend/internal/utils"
+	"github.com/ekf/one-on-one-backend/pkg/protocol"
+	"github.com/gofiber/fiber/v2"
+)
+
+// Names of background jobs
+const (
+	jobMeetingAgendas  = "meeting_agendas"
+	jobOneOnOneCadence = "one_on_one_cadence"
+	jobOneOnOneDigest  = "one_on_one_digest"
+	jobSLABreachCheck  = "sla_breach_check"
+	jobADSync          = "ad_sync"
+	jobCalendarSync    = "calendar_sync"
+)
+
+const calendarSyncDaysForward = 30
+
+// openTicketStatuses are service ticket statuses the SLA still applies to
+var openTicketStatuses = []string{"new", "in_progress", "pending"}
+
+// registerScheduledJobs registers periodic jobs. The schedules here are defaults for the first
+// start; afterwards admins change them in scheduled_jobs via the admin API.
+func (h *Handler) registerScheduledJobs() {
+	var jobs []scheduler.Job
+
+	// Agendas go out every 15 minutes from AgendaDeliveryHour, so a restart or a late
+	// calendar sync still gets the agenda out the same day
+	if hour := h.Config.AgendaDeliveryHour; hour >= 0 && hour <= 23 && h.AI != nil {
+		jobs = append(jobs, scheduler.Job{
+			Name:        jobMeetingAgendas,
+			Description: "Send the prepared agendas of tomorrow's 1-on-1 meetings",
+			Schedule:    fmt.Sprintf("*/15 %d-23 * * *", hour),
+			Run: func(ctx context.Context) error {
+				return h.deliverUpcomingAgendas(ctx, time.Now().In(h.location))
+			},
+		})
+	}
+
+	if hour := h.Config.CadenceCheckHour; hour >= 0 && hour <= 23 {
+		jobs = append(jobs, scheduler.Job{
+			Name:        jobOneOnOneCadence,
+			Description: "Find overdue 1-on-1s and propose or book a slot",
+			Schedule:    fmt.Sprintf("0 %d * * *", hour),
+			Run: func(ctx context.Context) error {
+				h.enforceCadence(time.Now().In(h.location))
+				return nil
+			},
+		})
+
+		if day, ok := parseWeekday(h.Config.CadenceDigestDay); ok {
+			jobs = append(jobs, scheduler.Job{
+				Name:        jobOneOnOneDigest,
+				Description: "Send managers the weekly digest of overdue 1-on-1s",
+				Schedule:    fmt.Sprintf("0 %d * * %d", hour, day),
+				Run: func(ctx context.Context) error {
+					h.sendCadenceDigests(time.Now().In(h.location))
+					return nil
+				},
+			})
+		}
+	}
+
+	jobs = append(jobs,
+		scheduler.Job{
+			Name:        jobSLABreachCheck,
+			Description: "Notify assignees about service tickets past their SLA deadline",
+			Schedule:    "*/10 * * * *",
+			Timeout:     5 * time.Minute,
+			Run:         h.checkSLABreaches,
+		},
+		scheduler.Job{
+			Name:        jobADSync,
+			Description: "Import employees and managers from Active Directory",
+			Schedule:    "0 3 * * *",
+			Timeout:     15 * time.Minute,
+			Run:         h.syncADUsersJob,
+		},
+		scheduler.Job{
+			Name:        jobCalendarSync,
+			Description: "Import Exchange meetings of users who signed in with AD credentials and prepare 1-on-1 agendas",
+			Schedule:    "0 7-20 * * 1-5",
+			Run:         h.syncCalendarsJob,
+		},
+	)
+
+	for _, job := range jobs {
+		if err := h.Scheduler.Register(job); err != nil {
+			utils.GetLogger().Warn("Failed to register scheduled job", map[string]interface{}{
+				"job":   job.Name,
+				"error": err.Error(),
+			})
+		}
+	}
+}
+
+// parseWeekday returns the cron day of week (0 - Sunday) of an English weekday name
+func parseWeekday(name string) (int, bool) {
+	for day := time.Sunday; day <= time.Saturday; day++ {
+		if strings.EqualFold(day.String(), name) {
+			return int(day), true
+		}
+	}
+	return 0, false
+}
+
+// checkSLABreaches publishes ticket.sla_breached once per ticket whose SLA deadline has
+// passed; the assignee (admins for unassigned tickets) is notified by its subscribers
+func (h *Handler) checkSLABreaches(ctx context.Context) error {
+	var tickets []struct {
+		ID          string    `json:"id"`
+		Number      string    `json:"number"`
+		Title       string    `json:"title"`
+		Priority    string    `json:"priority"`
+		AssigneeID  *string   `json:"assignee_id"`
+		SLADeadline time.Time `json:"sla_deadline"`
+	}
+	err := h.DB.From("service_tickets").Select("id, number, title, priority, assignee_id, sla_deadline").
+		In("status", openTicketStatuses).Lte("sla_deadline", time.Now().Format(time.RFC3339)).
+		IsNull("sla_breach_notified_at").Execute(&tickets)
+	if err != nil {
+		return err
+	}
+	if len(tickets) == 0 {
+		return nil
+	}
+
+	var failed []string
+	for _, t := range tickets {
+		if ctx.Err() != nil {
+			return ctx.Err()
+		}
+
+		// Notifications and the activity entry are sent by subscribers of the event; the
+		// mark and the event are stored together, so a breach is neither lost nor repeated
+		err := h.inTx(ctx, func(tx *Handler) error {
+			_, err := tx.DB.Update("service_tickets", "id", t.ID, map[string]interface{}{"sla_breach_notified_at": time.Now()})
+			if err != nil {
+				return err
+			}
+			return tx.publish("", events.TicketSLABreached{
+				TicketID:    t.ID,
+				Number:      t.Number,
+				Title:       t.Title,
+				Priority:    t.Priority,
+				AssigneeID:  t.AssigneeID,
+				SLADeadline: t.SLADeadline,
+			})
+		})
+		if err != nil {
+			failed = append(failed, t.Number+": "+err.Error())
+		}
+	}
+
+	if len(failed) > 0 {
+		return fmt.Errorf("SLA breach not recorded for %d tickets: %s", len(failed), truncate(strings.Join(failed, "; "), 1000))
+	}
+	return nil
+}
+
+// syncADUsersJob imports employees from AD with the bind account, or through the connector
+// when no bind account is configured. While the connector is offline the sync is queued.
+func (h *Handler) syncADUsersJob(ctx context.Context) error {
+	var batch []map[string]interface{}
+
+	switch {
+	case h.AD != nil && h.Config.ADBindUser != "" && h.Config.ADBindPassword != "":
+		users, err := h.AD.GetAllUsers(true)
+		if err != nil {
+			return err
+		}
+		for _, user := range users {
+			if user.Email != "" {
+				batch = append(batch, adUserRecord(user, true))
+			}
+		}
+	case h.Connector.IsConnected():
+		users, _, errs, err := h.connectorSyncUsers(protocol.SyncUsersParams{
+			IncludePhotos:     true,
+			RequireDepartment: true,
+			RequireEmail:      true,
+		})
+		if err != nil {
+			return err
+		}
+		if len(errs) > 0 {
+			utils.GetLogger().Warn("AD sync failed for some sites", map[string]interface{}{"errors": errs})
+		}
+		for _, user := range users {
+			if user.Email != "" {
+				batch = append(batch, connectorUserRecord(user, true))
+			}
+		}
+	case h.ConnectorQueue != nil && h.Config.ConnectorQueueHours > 0:
+		params := protocol.SyncUsersParams{IncludePhotos: true, RequireDepartment: true, RequireEmail: true}
+		_, err := h.ConnectorQueue.Enqueue(protocol.CommandSyncUsers, params, adSyncOptions{Mode: "full", IncludePhotos: true},
+			time.Duration(h.Config.ConnectorQueueHours)*time.Hour, "")
+		return err
+	default:
+		return errors.New("AD bind account is not configured and the connector is offline")
+	}
+
+	if ctx.Err() != nil {
+		return ctx.Err()
+	}
+	if len(batch) > 0 {
+		if _, err := h.DB.Upsert("employees", batch, "email"); err != nil {
+			return err
+		}
+	}
+	h.updateManagerLinks()
+	return nil
+}
+
+// syncCalendarsJob imports meetings for every user with stored AD credentials
+func (h *Handler) syncCalendarsJob(ctx context.Context) error {
+	var employees []struct {
+		ID                string  `json:"id"`
+		EncryptedPassword *string `json:"encrypted_password"`
+	}
+	if err := h.DB.From("employees").Select("id, encrypted_password").Execute(&employees); err != nil {
+		return err
+	}
+
+	// A meeting shows up in the calendars of both participants; its agenda is prepared once
+	var failed []string
+	var agendas []syncedMeeting
+	seen := make(map[string]int)
+	for _, e := range employees {
+		if e.EncryptedPassword == nil || *e.EncryptedPassword == "" {
+			continue
+		}
+		if ctx.Err() != nil {
+			return ctx.Err()
+		}
+
+		_, synced, err := h.syncEmployeeCalendar(CalendarSyncRequest{
+			EmployeeID:  e.ID,
+			DaysBack:    1,
+			DaysForward: calendarSyncDaysForward,
+		})
+		if err != nil {
+			failed = append(failed, e.ID+": "+err.Error())
+		}
+		for _, m := range synced {
+			if i, ok := seen[m.id]; ok {
+				agendas[i].changed = agendas[i].changed || m.changed
+				continue
+			}
+			seen[m.id] = len(agendas)
+			agendas = append(agendas, m)
+		}
+	}
+	h.prepareSyncedAgendas(agendas)
+
+	if len(failed) > 0 {
+		return fmt.Errorf("calendar sync failed for %d employees: %s", len(failed), truncate(strings.Join(failed, "; "), 1000))
+	}
+	return nil
+}
+
+// ListScheduledJobs returns background jobs with their schedules and last run status
+func (h *Handler) ListScheduledJobs(c *fiber.Ctx) error {
+	if h.Scheduler == nil {
+		return c.Status(500).JSON(fiber.Map{"error": "Database not configured"})
+	}
+
+	jobs, err := h.Scheduler.Jobs()
+	if err != nil {
+		return c.Status(500).JSON(fiber.Map{"error": err.Error()})
+	}
+
+	return c.JSON(fiber.Map{
+		"jobs":    jobs,
+		"enabled": h.Config.SchedulerEnabled,
+	})
+}
+
+// GetScheduledJobRuns returns the latest runs of a job
+func (h *Handler) GetScheduledJobRuns(c *fiber.Ctx) error {
+	if h.Scheduler == nil {
+		return c.Status(500).JSON(fiber.Map{"error": "Database not configured"})
+	}
+
+	name := c.Params("name")
+	if _, err := h.Scheduler.Job(name); err != nil {
+		return c.Status(404).JSON(fiber.Map{"error": "Job not found"})
+	}
+
+	runs, err := h.Scheduler.Runs(name, c.QueryInt("limit", 50))
+	if err != nil {
+		return c.Status(500).JSON(fiber.Map{"error": err.Error()})
+	}
+
+	return c.JSON(runs)
+}
+
+// TriggerScheduledJob starts a job immediately, even if it is paused
+func (h *Handler) TriggerScheduledJob(c *fiber.Ctx) error {
+	if h.Scheduler == nil {
+		return c.Status(500).JSON(fiber.Map{"error": "Database not configured"})
+	}
+
+	userID, _ := c.Locals("user_id").(string)
+	job, err := h.Scheduler.Job(c.Params("name"))
+	if err != nil {
+		return c.Status(404).JSON(fiber.Map{"error": "Job not found"})
+	}
+
+	if err := h.Scheduler.Trigger(job.Name, userID); err != nil {
+		if errors.Is(err, scheduler.ErrJobRunning) {
+			return c.Status(409).JSON(fiber.Map{"error": "Job is already running"})
+		}
+		return c.Status(500).JSON(fiber.Map{"error": err.Error()})
+	}
+
+	h.createAuditLog(c, userID, "scheduled_job.trigger", "scheduled_job", job.ID, nil,
+		map[string]interface{}{"name": job.Name})
+
+	return c.Status(202).JSON(fiber.Map{"success": true, "status": scheduler.StatusRunning})
+}
+
+// PauseScheduledJob stops scheduled runs of a job
+func (h *Handler) PauseScheduledJob(c *fiber.Ctx) error {
+	return h.setScheduledJobPaused(c, true)
+}
+
+// ResumeScheduledJob resumes scheduled runs of a job from the next scheduled time
+func (h *Handler) ResumeScheduledJob(c *fiber.Ctx) error {
+	return h.setScheduledJobPaused(c, false)
+}
+
+func (h *Handler) setScheduledJobPaused(c *fiber.Ctx, paused bool) error {
+	if h.Scheduler == nil {
+		return c.Status(500).JSON(fiber.Map{"error": "Database not configured"})
+	}
+
+	userID, _ := c.Locals("user_id").(string)
+	job, err := h.Scheduler.Job(c.Params("name"))
+	if err != nil {
+		return c.Status(404).JSON(fiber.Map{"error": "Job not found"})
+	}
+
+	if err := h.Scheduler.SetPaused(job.Name, paused); err != nil {
+		return c.Status(500).JSON(fiber.Map{"error": err.Error()})
+	}
+
+	action := "scheduled_job.resume"
+	if paused {
+		action = "scheduled_job.pause"
+	}
+	h.createAuditLog(c, userID, action, "scheduled_job", job.ID,
+		map[string]interface{}{"paused": job.Paused}, map[string]interface{}{"paused": paused})
+
+	updated, _ := h.Scheduler.Job(job.Name)
+	return c.JSON(updated)
+}
+
+// UpdateScheduledJob changes the cron schedule of a job
+func (h *Handler) UpdateScheduledJob(c *fiber.Ctx) error {
+	if h.Scheduler == nil {
+		return c.Status(500).JSON(fiber.Map{"error": "Database not configured"})
+	}
+
+	var req struct {
+		Schedule string `json:"schedule"`
+	}
+	if err := c.BodyParser(&req); err != nil || req.Schedule == "" {
+		return c.Status(400).JSON(fiber.Map{"error": "schedule is required"})
+	}
+
+	userID, _ := c.Locals("user_id").(string)
+	job, err := h.Scheduler.Job(c.Params("name"))
+	if err != nil {
+		return c.Status(404).JSON(fiber.Map{"error": "Job not found"})
+	}
+
+	if err := h.Scheduler.SetSchedule(job.Name, req.Schedule); err != nil {
+		return c.Status(400).JSON(fiber.Map{"error": err.Error()})
+	}
+
+	h.createAuditLog(c, userID, "scheduled_job.update", "scheduled_job", job.ID,
+		map[string]interface{}{"schedule": job.Schedule}, map[string]interface{}{"schedule": req.Schedule})
+
+	updated, _ := h.Scheduler.Job(job.Name)
+	return c.JSON(updated)
+}
@@ -5,6 +5,8 @@ import (
 	"fmt"
 	"time"
 
+	"github.com/ekf/one-on-one-backend/internal/database"
+	"github.com/ekf/one-on-one-backend/internal/events"
 	"github.com/ekf/one-on-one-backend/internal/models"
 	"github.com/gofiber/fiber/v2"
 )
@@ -26,6 +28,15 @@ func (h *Handler) ListServiceTickets(c *fiber.Ctx) error {
 	}
 	if status := c.Query("status"); status != "" {
 		query = query.Eq("status", status)
+	} else if c.Query("open") == "true" {
+		query = query.Not("status", "in", "(resolved,closed)")
+	}
+	if involvedID := c.Query("involved_id"); involvedID != "" {
+		id := database.QuoteFilterValue(involvedID)
+		query = query.Or("requester_id.eq." + id + ",assignee_id.eq." + id)
+	}
+	if q := c.Query("q"); q != "" {
+		query = query.TextSearch("search_vector", q, searchConfig)
 	}
 	if ticketType := c.Query("type"); ticketType != "" {
 		query = query.Eq("type", ticketType)
@@ -37,12 +48,20 @@ func (h *Handler) ListServiceTickets(c *fiber.Ctx) error {
 		query = query.Eq("category_id", categoryID)
 	}
 
-	var tickets []models.ServiceTicket
-	err := query.Order("created_at", true).Execute(&tickets)
+	query, page, err := paginate(c, query, 0)
 	if err != nil {
-		return c.Status(500).JSON(fiber.Map{"error": err.Error()})
+		return listError(c, err)
 	}
 
+	var tickets []models.ServiceTicket
+	if err := query.Execute(&tickets); err != nil {
+		return listError(c, err)
+	}
+
+	page.setHeaders(c, len(tickets), func() string {
+		last := tickets[len(tickets)-1]
+		return pageCursor(last.CreatedAt, last.ID)
+	})
 	return c.JSON(tickets)
 }
 
@@ -168,24 +187,25 @@ func (h *Handler) CreateServiceTicket(c *fiber.Ctx) error {
 		"sla_deadline": slaDeadline,
 	}
 
-	result, err := h.DB.Insert("service_tickets", data)
+	var created []models.ServiceTicket
+	err := h.inTx(c.UserContext(), func(tx *Handler) error {
+		result, err := tx.DB.Insert("service_tickets", data)
+		if err != nil {
+			return err
+		}
+		json.Unmarshal(result, &created)
+		if len(created) == 0 {
+			return nil
+		}
+		return tx.publish(input.RequesterID, events.TicketCreated{Ticket: created[0]})
+	})
 	if err != nil {
 		return c.Status(500).JSON(fiber.Map{"error": err.Error()})
 	}
-
-	var created []models.ServiceTicket
-	json.Unmarshal(result, &created)
 	if len(created) == 0 {
 		return c.Status(201).JSON(fiber.Map{"status": "created", "number": ticketNumber})
 	}
 
-	// Log activity
-	h.DB.Insert("service_ticket_activity", map[string]interface{}{
-		"ticket_id": created[0].ID,
-		"actor_id":  input.RequesterID,
-		"action":    "created",
-	})
-
 	return c.Status(201).JSON(created[0])
 }
 
@@ -220,20 +240,7 @@ func (h *Handler) UpdateServiceTicket(c *fiber.Ctx) error {
 		"updated_at": time.Now(),
 	}
 
-	// Track changes for activity log
-	var actorID *string = input.ActorID
-
 	if input.Status != nil {
-		// Log status change
-		if currentTicket.Status != *input.Status {
-			h.DB.Insert("service_ticket_activity", map[string]interface{}{
-				"ticket_id": id,
-				"actor_id":  actorID,
-				"action":    "status_changed",
-				"old_value": currentTicket.Status,
-				"new_value": *input.Status,
-			})
-		}
 		data["status"] = *input.Status
 
 		// Set resolved/closed timestamps
@@ -250,13 +257,6 @@ func (h *Handler) UpdateServiceTicket(c *fiber.Ctx) error {
 		data["priority"] = *input.Priority
 	}
 	if input.AssigneeID != nil {
-		// Log assignment
-		h.DB.Insert("service_ticket_activity", map[string]interface{}{
-			"ticket_id": id,
-			"actor_id":  actorID,
-			"action":    "assigned",
-			"new_value": *input.AssigneeID,
-		})
 		data["assignee_id"] = *input.AssigneeID
 	}
 	if input.Resolution != nil {
@@ -272,13 +272,37 @@ func (h *Handler) UpdateServiceTicket(c *fiber.Ctx) error {
 		data["description"] = *input.Description
 	}
 
-	result, err := h.DB.Update("service_tickets", "id", id, data)
-	if err != nil {
-		return c.Status(500).JSON(fiber.Map{"error": err.Error()})
+	// The activity log is written by subscribers of the events
+	actorID, _ := c.Locals("user_id").(string)
+	if input.ActorID != nil {
+		actorID = *input.ActorID
 	}
 
 	var updated []models.ServiceTicket
-	json.Unmarshal(result, &updated)
+	err := h.inTx(c.UserContext(), func(tx *Handler) error {
+		result, err := tx.DB.Update("service_tickets", "id", id, data)
+		if err != nil {
+			return err
+		}
+		json.Unmarshal(result, &updated)
+		if len(updated) == 0 {
+			return nil
+		}
+
+		if input.Status != nil && currentTicket.Status != *input.Status {
+			err := tx.publish(actorID, events.TicketStatusChanged{TicketID: id, OldStatus: currentTicket.Status, NewStatus: *input.Status})
+			if err != nil {
+				return err
+			}
+		}
+		if input.AssigneeID != nil {
+			return tx.publish(actorID, events.TicketAssigned{TicketID: id, AssigneeID: *input.AssigneeID})
+		}
+		return nil
+	})
+	if err != nil {
+		return c.Status(500).JSON(fiber.Map{"error": err.Error()})
+	}
 	if len(updated) == 0 {
 		return c.Status(404).JSON(fiber.Map{"error": "Ticket not found"})
 	}
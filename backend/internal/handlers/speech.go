@@ -8,6 +8,7 @@ import (
 	"path/filepath"
 	"strings"
 
+	"github.com/ekf/one-on-one-backend/pkg/ai"
 	"github.com/gofiber/fiber/v2"
 	"github.com/google/uuid"
 )
@@ -155,28 +156,38 @@ func (h *Handler) TranscribeAudio(c *fiber.Ctx) error {
 	var transcribeErr error
 
 	switch service {
-	case "whisper":
-		transcript, transcribeErr = h.AI.TranscribeWhisper(tempPath)
-	case "yandex":
-		transcript, transcribeErr = h.AI.TranscribeYandex(tempPath)
 	case "auto", "both":
-		// Try both and merge if possible
-		whisperResult, _ = h.AI.TranscribeWhisper(tempPath)
-		yandexResult, _ = h.AI.TranscribeYandex(tempPath)
-
-		if whisperResult != "" && yandexResult != "" {
-			transcript, transcribeErr = h.AI.MergeTranscripts(whisperResult, yandexResult)
-		} else if whisperResult != "" {
-			transcript = whisperResult
-		} else if yandexResult != "" {
-			transcript = yandexResult
+		// Try the deployment default providers and merge if possible
+		var results []string
+		for _, t := range h.AI.TranscribersFor("") {
+			text, _ := t.Transcribe(tempPath)
+			switch t.Name() {
+			case ai.TranscriberWhisper:
+				whisperResult = text
+			case ai.TranscriberYandex:
+				yandexResult = text
+			}
+			if text != "" {
+				results = append(results, text)
+			}
+		}
+
+		if len(results) >= 2 {
+			transcript, transcribeErr = h.AI.MergeTranscripts(results[0], results[1])
+		} else if len(results) == 1 {
+			transcript = results[0]
 		} else {
 			transcribeErr = fmt.Errorf("no transcription service available")
 		}
 	default:
-		return c.Status(400).JSON(fiber.Map{
-			"error": "Invalid service. Use: whisper, yandex, auto, or both",
-		})
+		// Any registered speech-to-text provider (whisper, yandex, local, ...)
+		transcriber, ok := h.AI.Transcriber(service)
+		if !ok {
+			return c.Status(400).JSON(fiber.Map{
+				"error": "Invalid service. Use: whisper, yandex, local, auto, or both",
+			})
+		}
+		transcript, transcribeErr = transcriber.Transcribe(tempPath)
 	}
 
 	if transcribeErr != nil {
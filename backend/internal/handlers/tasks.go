@@ -4,6 +4,7 @@ import (
 	"encoding/json"
 	"time"
 
+	"github.com/ekf/one-on-one-backend/internal/events"
 	"github.com/ekf/one-on-one-backend/internal/models"
 	"github.com/gofiber/fiber/v2"
 )
@@ -25,6 +26,12 @@ func (h *Handler) ListTasks(c *fiber.Ctx) error {
 	if status := c.Query("status"); status != "" {
 		query = query.Eq("status", status)
 	}
+	if c.Query("overdue") == "true" {
+		query = query.Lt("due_date", time.Now().Format("2006-01-02")).Neq("status", "done")
+	}
+	if q := c.Query("q"); q != "" {
+		query = query.TextSearch("search_vector", q, searchConfig)
+	}
 	if sprintID := c.Query("sprint_id"); sprintID != "" {
 		query = query.Eq("sprint_id", sprintID)
 	}
@@ -40,12 +47,20 @@ func (h *Handler) ListTasks(c *fiber.Ctx) error {
 		query = query.Eq("is_epic", isEpic)
 	}
 
-	var tasks []models.Task
-	err := query.Order("created_at", true).Execute(&tasks)
+	query, page, err := paginate(c, query, 0)
 	if err != nil {
-		return c.Status(500).JSON(fiber.Map{"error": err.Error()})
+		return listError(c, err)
+	}
+
+	var tasks []models.Task
+	if err := query.Execute(&tasks); err != nil {
+		return listError(c, err)
 	}
 
+	page.setHeaders(c, len(tasks), func() string {
+		last := tasks[len(tasks)-1]
+		return pageCursor(last.CreatedAt, last.ID)
+	})
 	return c.JSON(tasks)
 }
 
@@ -205,32 +220,40 @@ func (h *Handler) CreateTask(c *fiber.Ctx) error {
 		taskData["original_due_date"] = input.DueDate
 	}
 
-	result, err := h.DB.Insert("tasks", taskData)
-	if err != nil {
-		return c.Status(500).JSON(fiber.Map{"error": err.Error()})
-	}
-
+	userID, _ := c.Locals("user_id").(string)
 	var created []models.Task
-	json.Unmarshal(result, &created)
+	err := h.inTx(c.UserContext(), func(tx *Handler) error {
+		result, err := tx.DB.Insert("tasks", taskData)
+		if err != nil {
+			return err
+		}
+		json.Unmarshal(result, &created)
+		if len(created) == 0 {
+			return nil
+		}
 
-	if len(created) > 0 {
 		// Add tags
 		for _, tagName := range input.Tags {
 			var tag models.Tag
-			h.DB.From("tags").Select("id").Eq("name", tagName).Single().Execute(&tag)
+			tx.DB.From("tags").Select("id").Eq("name", tagName).Single().Execute(&tag)
 			if tag.ID != "" {
-				h.DB.Insert("task_tags", map[string]interface{}{
+				_, err := tx.DB.Insert("task_tags", map[string]interface{}{
 					"task_id": created[0].ID,
 					"tag_id":  tag.ID,
 				})
+				if err != nil {
+					return err
+				}
 			}
 		}
 
-		// Notify assignee
-		if input.AssigneeID != nil && h.Telegram != nil {
-			go h.notifyNewTask(created[0].ID, *input.AssigneeID, input.Title)
-		}
+		return tx.publish(userID, events.TaskCreated{Task: created[0]})
+	})
+	if err != nil {
+		return c.Status(500).JSON(fiber.Map{"error": err.Error()})
+	}
 
+	if len(created) > 0 {
 		return c.Status(201).JSON(created[0])
 	}
 
@@ -257,7 +280,8 @@ func (h *Handler) UpdateTask(c *fiber.Ctx) error {
 	delete(updates, "id")
 	delete(updates, "created_at")
 
-	// Track history
+	// Changes for the task history
+	var changes []events.FieldChange
 	for field, newValue := range updates {
 		var oldValue interface{}
 		switch field {
@@ -280,12 +304,7 @@ func (h *Handler) UpdateTask(c *fiber.Ctx) error {
 		}
 
 		if oldValue != newValue {
-			h.DB.Insert("task_history", map[string]interface{}{
-				"task_id":    id,
-				"field_name": field,
-				"old_value":  oldValue,
-				"new_value":  newValue,
-			})
+			changes = append(changes, events.FieldChange{Field: field, Old: oldValue, New: newValue})
 		}
 	}
 
@@ -294,14 +313,32 @@ func (h *Handler) UpdateTask(c *fiber.Ctx) error {
 		updates["completed_at"] = time.Now().Format(time.RFC3339)
 	}
 
-	result, err := h.DB.Update("tasks", "id", id, updates)
+	userID, _ := c.Locals("user_id").(string)
+	var updated []models.Task
+	err := h.inTx(c.UserContext(), func(tx *Handler) error {
+		result, err := tx.DB.Update("tasks", "id", id, updates)
+		if err != nil {
+			return err
+		}
+		json.Unmarshal(result, &updated)
+		if err := tx.recordTaskHistory(id, changes); err != nil {
+			return err
+		}
+
+		if len(changes) > 0 {
+			if err := tx.publish(userID, events.TaskUpdated{TaskID: id, Changes: changes}); err != nil {
+				return err
+			}
+		}
+		if newStatus, ok := updates["status"].(string); ok && newStatus != current.Status {
+			return tx.publishTaskStatusChanged(userID, id, current.Status, newStatus, updated)
+		}
+		return nil
+	})
 	if err != nil {
 		return c.Status(500).JSON(fiber.Map{"error": err.Error()})
 	}
 
-	var updated []models.Task
-	json.Unmarshal(result, &updated)
-
 	if len(updated) > 0 {
 		return c.JSON(updated[0])
 	}
@@ -381,23 +418,33 @@ func (h *Handler) MoveTaskKanban(c *fiber.Ctx) error {
 		updates["completed_at"] = time.Now().Format(time.RFC3339)
 	}
 
-	result, err := h.DB.Update("tasks", "id", taskID, updates)
-	if err != nil {
-		return c.Status(500).JSON(fiber.Map{"error": err.Error()})
-	}
-
-	// Track history
+	userID, _ := c.Locals("user_id").(string)
+	var changes []events.FieldChange
 	if current.Status != newStatus {
-		h.DB.Insert("task_history", map[string]interface{}{
-			"task_id":    taskID,
-			"field_name": "status",
-			"old_value":  current.Status,
-			"new_value":  newStatus,
-		})
+		changes = []events.FieldChange{{Field: "status", Old: current.Status, New: newStatus}}
 	}
-
 	var updated []models.Task
-	json.Unmarshal(result, &updated)
+	err := h.inTx(c.UserContext(), func(tx *Handler) error {
+		result, err := tx.DB.Update("tasks", "id", taskID, updates)
+		if err != nil {
+			return err
+		}
+		json.Unmarshal(result, &updated)
+		if err := tx.recordTaskHistory(taskID, changes); err != nil {
+			return err
+		}
+
+		if len(changes) == 0 {
+			return nil
+		}
+		if err := tx.publish(userID, events.TaskUpdated{TaskID: taskID, Changes: changes}); err != nil {
+			return err
+		}
+		return tx.publishTaskStatusChanged(userID, taskID, current.Status, newStatus, updated)
+	})
+	if err != nil {
+		return c.Status(500).JSON(fiber.Map{"error": err.Error()})
+	}
 
 	if len(updated) > 0 {
 		return c.JSON(updated[0])
@@ -406,17 +453,31 @@ func (h *Handler) MoveTaskKanban(c *fiber.Ctx) error {
 	return c.JSON(fiber.Map{"status": "updated"})
 }
 
-func (h *Handler) notifyNewTask(taskID, assigneeID, title string) {
-	if h.DB == nil || h.Telegram == nil {
-		return
+// recordTaskHistory stores changed task fields in task_history. It is written together
+// with the task update, so the history is never behind the task.
+func (h *Handler) recordTaskHistory(taskID string, changes []events.FieldChange) error {
+	for _, change := range changes {
+		_, err := h.DB.Insert("task_history", map[string]interface{}{
+			"task_id":    taskID,
+			"field_name": change.Field,
+			"old_value":  change.Old,
+			"new_value":  change.New,
+		})
+		if err != nil {
+			return err
+		}
 	}
+	return nil
+}
 
-	var user models.TelegramUser
-	h.DB.From("telegram_users").Select("telegram_chat_id").Eq("employee_id", assigneeID).Eq("notifications_enabled", "true").Single().Execute(&user)
-
-	if user.TelegramChatID > 0 {
-		h.Telegram.SendMessage(user.TelegramChatID, "📌 <b>Новая задача:</b>\n\n"+title)
+// publishTaskStatusChanged publishes task.status_changed; updated is the result of the
+// update, empty if it could not be read
+func (h *Handler) publishTaskStatusChanged(actorID, taskID, oldStatus, newStatus string, updated []models.Task) error {
+	event := events.TaskStatusChanged{TaskID: taskID, OldStatus: oldStatus, NewStatus: newStatus}
+	if len(updated) > 0 {
+		event.Task = &updated[0]
 	}
+	return h.publish(actorID, event)
 }
 
 // TaskDependency represents a dependency between tasks
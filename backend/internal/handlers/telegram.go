@@ -8,6 +8,7 @@ import (
 	"time"
 
 	"github.com/ekf/one-on-one-backend/internal/models"
+	"github.com/ekf/one-on-one-backend/internal/templates"
 	"github.com/gofiber/fiber/v2"
 )
 
@@ -268,19 +269,14 @@ func (h *Handler) ForwardToTelegram(conversationID, senderName, content, message
 		return nil // Not properly configured
 	}
 
-	// Format message based on type
-	var text string
-	switch messageType {
-	case "voice":
-		text = fmt.Sprintf("🎤 *%s* отправил голосовое сообщение", senderName)
-	case "video":
-		text = fmt.Sprintf("📹 *%s* отправил видеосообщение", senderName)
-	case "file":
-		text = fmt.Sprintf("📎 *%s* отправил файл", senderName)
-	case "gif":
-		text = fmt.Sprintf("🎬 *%s* отправил GIF", senderName)
-	default:
-		text = fmt.Sprintf("*%s*: %s", senderName, content)
+	// The linked chat is shared by the conversation, so it gets the default language
+	msg, err := h.Templates.Render("messenger.forward", "", templates.Telegram, map[string]interface{}{
+		"type":    messageType,
+		"sender":  senderName,
+		"content": content,
+	})
+	if err != nil {
+		return err
 	}
 
 	// Send to Telegram
@@ -288,8 +284,8 @@ func (h *Handler) ForwardToTelegram(conversationID, senderName, content, message
 
 	payload := map[string]interface{}{
 		"chat_id":    *conv.TelegramChatID,
-		"text":       text,
-		"parse_mode": "Markdown",
+		"text":       msg.Body,
+		"parse_mode": "HTML",
 	}
 
 	jsonPayload, _ := json.Marshal(payload)
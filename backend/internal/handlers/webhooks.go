@@ -0,0 +1,207 @@
+package handlers
+
+import (
+	"errors"
+
+	"github.com/ekf/one-on-one-backend/internal/webhooks"
+	"github.com/gofiber/fiber/v2"
+)
+
+// ListWebhooks returns registered webhook endpoints and the events they can subscribe to
+func (h *Handler) ListWebhooks(c *fiber.Ctx) error {
+	if h.Webhooks == nil {
+		return c.Status(500).JSON(fiber.Map{"error": "Database not configured"})
+	}
+
+	list, err := h.Webhooks.List()
+	if err != nil {
+		return c.Status(500).JSON(fiber.Map{"error": err.Error()})
+	}
+
+	return c.JSON(fiber.Map{
+		"webhooks": list,
+		"events":   webhooks.Events,
+		"enabled":  h.Config.WebhooksEnabled,
+	})
+}
+
+// CreateWebhook registers an endpoint. The signing secret is returned only here
+// and by RotateWebhookSecret.
+func (h *Handler) CreateWebhook(c *fiber.Ctx) error {
+	if h.Webhooks == nil {
+		return c.Status(500).JSON(fiber.Map{"error": "Database not configured"})
+	}
+
+	var input webhooks.Input
+	if err := c.BodyParser(&input); err != nil {
+		return c.Status(400).JSON(fiber.Map{"error": "Invalid request body"})
+	}
+
+	userID, _ := c.Locals("user_id").(string)
+	webhook, secret, err := h.Webhooks.Create(input, userID)
+	if err != nil {
+		return webhookError(c, err)
+	}
+
+	h.createAuditLog(c, userID, "webhook.create", "webhook", webhook.ID, nil,
+		map[string]interface{}{"name": webhook.Name, "url": webhook.URL, "events": webhook.Events})
+
+	return c.Status(201).JSON(fiber.Map{"webhook": webhook, "secret": secret})
+}
+
+// UpdateWebhook changes the name, URL, events or active flag of an endpoint
+func (h *Handler) UpdateWebhook(c *fiber.Ctx) error {
+	if h.Webhooks == nil {
+		return c.Status(500).JSON(fiber.Map{"error": "Database not configured"})
+	}
+
+	var input webhooks.Input
+	if err := c.BodyParser(&input); err != nil {
+		return c.Status(400).JSON(fiber.Map{"error": "Invalid request body"})
+	}
+
+	id := c.Params("id")
+	before, err := h.Webhooks.Get(id)
+	if err != nil {
+		return webhookError(c, err)
+	}
+	webhook, err := h.Webhooks.Update(id, input)
+	if err != nil {
+		return webhookError(c, err)
+	}
+
+	userID, _ := c.Locals("user_id").(string)
+	h.createAuditLog(c, userID, "webhook.update", "webhook", id,
+		map[string]interface{}{"name": before.Name, "url": before.URL, "events": before.Events, "active": before.Active},
+		map[string]interface{}{"name": webhook.Name, "url": webhook.URL, "events": webhook.Events, "active": webhook.Active})
+
+	return c.JSON(webhook)
+}
+
+// DeleteWebhook removes an endpoint with its delivery history
+func (h *Handler) DeleteWebhook(c *fiber.Ctx) error {
+	if h.Webhooks == nil {
+		return c.Status(500).JSON(fiber.Map{"error": "Database not configured"})
+	}
+
+	id := c.Params("id")
+	webhook, err := h.Webhooks.Get(id)
+	if err != nil {
+		return webhookError(c, err)
+	}
+	if err := h.Webhooks.Delete(id); err != nil {
+		return webhookError(c, err)
+	}
+
+	userID, _ := c.Locals("user_id").(string)
+	h.createAuditLog(c, userID, "webhook.delete", "webhook", id,
+		map[string]interface{}{"name": webhook.Name, "url": webhook.URL}, nil)
+
+	return c.JSON(fiber.Map{"success": true})
+}
+
+// RotateWebhookSecret issues a new signing secret; the old one stops working immediately
+func (h *Handler) RotateWebhookSecret(c *fiber.Ctx) error {
+	if h.Webhooks == nil {
+		return c.Status(500).JSON(fiber.Map{"error": "Database not configured"})
+	}
+
+	id := c.Params("id")
+	secret, err := h.Webhooks.RotateSecret(id)
+	if err != nil {
+		return webhookError(c, err)
+	}
+
+	userID, _ := c.Locals("user_id").(string)
+	h.createAuditLog(c, userID, "webhook.rotate_secret", "webhook", id, nil, nil)
+
+	return c.JSON(fiber.Map{"secret": secret})
+}
+
+// PingWebhook queues a test "ping" event for an endpoint
+func (h *Handler) PingWebhook(c *fiber.Ctx) error {
+	if h.Webhooks == nil {
+		return c.Status(500).JSON(fiber.Map{"error": "Database not configured"})
+	}
+
+	delivery, err := h.Webhooks.Ping(c.Params("id"))
+	if err != nil {
+		return webhookError(c, err)
+	}
+
+	return c.Status(202).JSON(delivery)
+}
+
+// ListWebhookDeliveries returns the latest deliveries of an endpoint, filtered by status and event
+func (h *Handler) ListWebhookDeliveries(c *fiber.Ctx) error {
+	if h.Webhooks == nil {
+		return c.Status(500).JSON(fiber.Map{"error": "Database not configured"})
+	}
+
+	id := c.Params("id")
+	if _, err := h.Webhooks.Get(id); err != nil {
+		return webhookError(c, err)
+	}
+
+	deliveries, err := h.Webhooks.Deliveries(webhooks.DeliveryFilter{
+		WebhookID: id,
+		Status:    c.Query("status"),
+		Event:     c.Query("event"),
+		Limit:     min(c.QueryInt("limit", 50), maxPageSize),
+	})
+	if err != nil {
+		return c.Status(500).JSON(fiber.Map{"error": err.Error()})
+	}
+
+	return c.JSON(deliveries)
+}
+
+// GetWebhookDelivery returns a delivery with the log of its attempts
+func (h *Handler) GetWebhookDelivery(c *fiber.Ctx) error {
+	if h.Webhooks == nil {
+		return c.Status(500).JSON(fiber.Map{"error": "Database not configured"})
+	}
+
+	delivery, err := h.Webhooks.Delivery(c.Params("id"))
+	if err != nil {
+		return webhookError(c, err)
+	}
+	attempts, err := h.Webhooks.Attempts(delivery.ID)
+	if err != nil {
+		return c.Status(500).JSON(fiber.Map{"error": err.Error()})
+	}
+
+	return c.JSON(fiber.Map{"delivery": delivery, "attempts": attempts})
+}
+
+// ReplayWebhookDelivery sends the payload of a delivery again, e.g. after the receiver
+// was fixed
+func (h *Handler) ReplayWebhookDelivery(c *fiber.Ctx) error {
+	if h.Webhooks == nil {
+		return c.Status(500).JSON(fiber.Map{"error": "Database not configured"})
+	}
+
+	delivery, err := h.Webhooks.Replay(c.Params("id"))
+	if err != nil {
+		return webhookError(c, err)
+	}
+
+	userID, _ := c.Locals("user_id").(string)
+	h.createAuditLog(c, userID, "webhook.replay", "webhook", delivery.WebhookID, nil,
+		map[string]interface{}{"delivery_id": delivery.ID, "replay_of": c.Params("id"), "event": delivery.Event})
+
+	return c.Status(202).JSON(delivery)
+}
+
+// webhookError maps webhook errors to responses
+func webhookError(c *fiber.Ctx, err error) error {
+	switch {
+	case errors.Is(err, webhooks.ErrNotFound):
+		return c.Status(404).JSON(fiber.Map{"error": "Webhook not found"})
+	case errors.Is(err, webhooks.ErrDeliveryNotFound):
+		return c.Status(404).JSON(fiber.Map{"error": "Delivery not found"})
+	case errors.Is(err, webhooks.ErrInvalidURL), errors.Is(err, webhooks.ErrUnknownEvent), errors.Is(err, webhooks.ErrMissingFields):
+		return c.Status(400).JSON(fiber.Map{"error": err.Error()})
+	}
+	return c.Status(500).JSON(fiber.Map{"error": err.Error()})
+}
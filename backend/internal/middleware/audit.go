@@ -0,0 +1,176 @@
+package middleware
+
+import (
+	"encoding/json"
+	"errors"
+	"regexp"
+	"strings"
+
+	"github.com/ekf/one-on-one-backend/internal/audit"
+	"github.com/ekf/one-on-one-backend/internal/database"
+	"github.com/gofiber/fiber/v2"
+)
+
+// auditEntity maps an API path prefix to the entity and table it changes
+type auditEntity struct {
+	path       string
+	entityType string
+	table      string
+}
+
+// Longer prefixes first. Rows of these tables are diffed before and after the call.
+var auditEntities = []auditEntity{
+	{"service-desk/tickets", "service_ticket", "service_tickets"},
+	{"resources/allocations", "resource_allocation", "resource_allocations"},
+	{"resources/absences", "employee_absence", "employee_absences"},
+	{"improvements", "improvement_request", "improvement_requests"},
+	{"tasks", "task", "tasks"},
+	{"employees", "employee", "employees"},
+	{"users", "employee", "employees"},
+	{"projects", "project", "projects"},
+	{"meetings", "meeting", "meetings"},
+	{"agreements", "agreement", "agreements"},
+	{"versions", "version", "versions"},
+	{"sprints", "sprint", "sprints"},
+}
+
+var uuidRegex = regexp.MustCompile(`^[0-9a-fA-F]{8}-[0-9a-fA-F]{4}-[0-9a-fA-F]{4}-[0-9a-fA-F]{4}-[0-9a-fA-F]{12}$`)
+
+// auditTarget is what a request changes, derived from its path
+type auditTarget struct {
+	action     string
+	entityType string
+	entityID   string
+	table      string // empty - no diff
+}
+
+// AuditTrail records every mutating API call: actor, IP, request ID, status and, for known
+// entities, the changed fields of the affected row. Handlers that write a more specific
+// entry themselves call audit.MarkLogged and are skipped.
+func AuditTrail(db database.DBClient, recorder *audit.Recorder) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		method := c.Method()
+		if db == nil || recorder == nil || method == fiber.MethodGet || method == fiber.MethodHead || method == fiber.MethodOptions {
+			return c.Next()
+		}
+
+		target := resolveAuditTarget(method, c.Path())
+		var before map[string]interface{}
+		if target.table != "" && target.entityID != "" {
+			before = audit.Snapshot(db, target.table, target.entityID)
+		}
+
+		err := c.Next()
+		if audit.Logged(c) {
+			return err
+		}
+
+		// Identity is known only after the JWT middleware has run
+		entry := audit.RequestEntry(c)
+		entry.Action = target.action
+		entry.EntityType = target.entityType
+		entry.EntityID = target.entityID
+		entry.Status = c.Response().StatusCode()
+		var fe *fiber.Error
+		if errors.As(err, &fe) {
+			entry.Status = fe.Code
+		}
+
+		if target.table != "" && err == nil && entry.Status < 400 {
+			if entry.EntityID == "" {
+				entry.EntityID = createdID(c.Response().Body())
+			}
+			var after map[string]interface{}
+			if entry.EntityID != "" && method != fiber.MethodDelete {
+				after = audit.Snapshot(db, target.table, entry.EntityID)
+			}
+			if before != nil || after != nil {
+				entry.OldValue, entry.NewValue = audit.Diff(before, after)
+			}
+		}
+
+		recorder.Record(entry)
+		return err
+	}
+}
+
+// resolveAuditTarget derives the entity and action from a path such as
+// /api/v1/improvements/<id>/approve -> improvement_request.approve
+func resolveAuditTarget(method, path string) auditTarget {
+	path = strings.TrimPrefix(path, "/api/v1/")
+	path = strings.TrimPrefix(path, "admin/")
+	path = strings.Trim(path, "/")
+
+	var target auditTarget
+	rest := path
+	for _, e := range auditEntities {
+		if path == e.path || strings.HasPrefix(path, e.path+"/") {
+			target.entityType, target.table = e.entityType, e.table
+			rest = strings.TrimPrefix(strings.TrimPrefix(path, e.path), "/")
+			break
+		}
+	}
+
+	var segments []string
+	if rest != "" {
+		segments = strings.Split(rest, "/")
+	}
+	if target.entityType == "" && len(segments) > 0 {
+		target.entityType = strings.ReplaceAll(segments[0], "-", "_")
+		segments = segments[1:]
+	}
+	if len(segments) > 0 && uuidRegex.MatchString(segments[0]) {
+		target.entityID = segments[0]
+		segments = segments[1:]
+	}
+
+	// Remaining segments name the operation (approve, comments, status, ...),
+	// dropping further IDs
+	var parts []string
+	for _, s := range segments {
+		if !uuidRegex.MatchString(s) {
+			parts = append(parts, strings.ReplaceAll(s, "-", "_"))
+		}
+	}
+
+	verb := "create"
+	switch method {
+	case fiber.MethodPut, fiber.MethodPatch:
+		verb = "update"
+	case fiber.MethodDelete:
+		verb = "delete"
+	}
+	if method == fiber.MethodPost && len(parts) > 0 {
+		verb = ""
+	}
+
+	action := append([]string{target.entityType}, parts...)
+	if verb != "" {
+		action = append(action, verb)
+	}
+	target.action = strings.Join(action, ".")
+
+	// Nested operations with their own IDs are not rows of the entity table
+	if target.entityID == "" && len(parts) > 0 {
+		target.table = ""
+	}
+	return target
+}
+
+// createdID returns the id field of a JSON response: an object or a single-element
+// array (Insert results)
+func createdID(body []byte) string {
+	var object map[string]interface{}
+	if json.Unmarshal(body, &object) == nil {
+		id, _ := object["id"].(string)
+		return id
+	}
+
+	var array []map[string]interface{}
+	if json.Unmarshal(body, &array) == nil && len(array) == 1 {
+		if id, ok := array[0]["id"].(string); ok {
+			return id
+		}
+	}
+	return ""
+}
@@ -20,6 +20,7 @@ type Employee struct {
 	Mobile                *string    `json:"mobile,omitempty"`
 	TelegramUsername      *string    `json:"telegram_username,omitempty"`
 	TelegramChatID        *int64     `json:"telegram_chat_id,omitempty"`
+	Locale                string     `json:"locale,omitempty"` // language of notifications and emails: ru, en
 	HourlyRate            *float64   `json:"hourly_rate,omitempty"`
 	EncryptedPassword     *string    `json:"-"` // Never expose in JSON - for EWS access only
 	CreatedAt             *time.Time `json:"created_at,omitempty"`
@@ -47,6 +48,18 @@ type MeetingCategory struct {
 	Description *string `json:"description,omitempty"`
 }
 
+// PromptTemplate is a version of the analysis prompt for a meeting category
+type PromptTemplate struct {
+	ID           string     `json:"id"`
+	CategoryCode string     `json:"category_code"`
+	Version      int        `json:"version"`
+	Template     string     `json:"template"`
+	IsActive     bool       `json:"is_active"`
+	Comment      *string    `json:"comment,omitempty"`
+	CreatedBy    *string    `json:"created_by,omitempty"`
+	CreatedAt    *time.Time `json:"created_at,omitempty"`
+}
+
 // Meeting represents a recorded meeting
 type Meeting struct {
 	ID                string                 `json:"id"`
@@ -68,6 +81,10 @@ type Meeting struct {
 	Summary           *string                `json:"summary,omitempty"`
 	MoodScore         *int                   `json:"mood_score,omitempty"`
 	Analysis          map[string]interface{} `json:"analysis,omitempty"`
+	PromptTemplateID  *string                `json:"prompt_template_id,omitempty"` // nil - built-in prompt
+	PromptVersion     *int                   `json:"prompt_version,omitempty"`
+	AnalysisProvider  *string                `json:"analysis_provider,omitempty"`
+	AnalysisModel     *string                `json:"analysis_model,omitempty"`
 	CreatedAt         *time.Time             `json:"created_at,omitempty"`
 
 	// Joined fields - tags must match PostgreSQL relation names
@@ -76,6 +93,92 @@ type Meeting struct {
 	Category *MeetingCategory `json:"meeting_categories,omitempty"`
 }
 
+// MeetingAnalysisRevision is a superseded analysis kept when a meeting is re-analyzed
+type MeetingAnalysisRevision struct {
+	ID               string     `json:"id"`
+	MeetingID        string     `json:"meeting_id"`
+	Revision         int        `json:"revision"`
+	Analysis         string     `json:"analysis"` // JSON-encoded analysis
+	Summary          *string    `json:"summary,omitempty"`
+	MoodScore        *int       `json:"mood_score,omitempty"`
+	PromptTemplateID *string    `json:"prompt_template_id,omitempty"`
+	PromptVersion    *int       `json:"prompt_version,omitempty"`
+	AnalysisProvider *string    `json:"analysis_provider,omitempty"`
+	AnalysisModel    *string    `json:"analysis_model,omitempty"`
+	Reason           *string    `json:"reason,omitempty"`
+	ArchivedBy       *string    `json:"archived_by,omitempty"`
+	ArchivedAt       *time.Time `json:"archived_at,omitempty"`
+}
+
+// MeetingAgenda is a prepared agenda for an upcoming 1-on-1
+type MeetingAgenda struct {
+	ID               string     `json:"id"`
+	MeetingID        string     `json:"meeting_id"`
+	EmployeeID       *string    `json:"employee_id,omitempty"`
+	RecipientID      *string    `json:"recipient_id,omitempty"`
+	Agenda           string     `json:"agenda"` // JSON-encoded agenda
+	AnalysisProvider *string    `json:"analysis_provider,omitempty"`
+	AnalysisModel    *string    `json:"analysis_model,omitempty"`
+	GeneratedBy      *string    `json:"generated_by,omitempty"` // nil - scheduler
+	GeneratedAt      *time.Time `json:"generated_at,omitempty"`
+	DeliveredAt      *time.Time `json:"delivered_at,omitempty"`
+	DeliveryChannels *string    `json:"delivery_channels,omitempty"`
+	DeliveryAttempts int        `json:"delivery_attempts"`
+	DeliveryError    *string    `json:"delivery_error,omitempty"`
+}
+
+// OneOnOneProposal is a slot found for an overdue 1-on-1
+type OneOnOneProposal struct {
+	ID              string     `json:"id"`
+	EmployeeID      string     `json:"employee_id"`
+	ManagerID       string     `json:"manager_id"`
+	StartTime       time.Time  `json:"start_time"`
+	EndTime         time.Time  `json:"end_time"`
+	Status          string     `json:"status"` // proposed, booked, declined, expired, failed
+	LastMeetingDate *string    `json:"last_meeting_date,omitempty"`
+	DueDate         string     `json:"due_date"`
+	MeetingID       *string    `json:"meeting_id,omitempty"`
+	ExchangeID      *string    `json:"exchange_id,omitempty"`
+	Error           *string    `json:"error,omitempty"`
+	DecidedBy       *string    `json:"decided_by,omitempty"`
+	DecidedAt       *time.Time `json:"decided_at,omitempty"`
+	CreatedAt       *time.Time `json:"created_at,omitempty"`
+
+	Employee *Employee `json:"employees,omitempty"`
+}
+
+// ScheduledJob is a background job definition with its last run status
+type ScheduledJob struct {
+	ID             string     `json:"id"`
+	Name           string     `json:"name"`
+	Description    *string    `json:"description,omitempty"`
+	Schedule       string     `json:"schedule"` // cron expression in the business timezone
+	Paused         bool       `json:"paused"`
+	NextRunAt      *time.Time `json:"next_run_at,omitempty"`
+	LastRunAt      *time.Time `json:"last_run_at,omitempty"`
+	LastFinishedAt *time.Time `json:"last_finished_at,omitempty"`
+	LastStatus     *string    `json:"last_status,omitempty"` // running, success, failed
+	LastError      *string    `json:"last_error,omitempty"`
+	LastDurationMs *int64     `json:"last_duration_ms,omitempty"`
+	LastInstance   *string    `json:"last_instance,omitempty"`
+	CreatedAt      *time.Time `json:"created_at,omitempty"`
+	UpdatedAt      *time.Time `json:"updated_at,omitempty"`
+}
+
+// ScheduledJobRun is a single execution of a scheduled job
+type ScheduledJobRun struct {
+	ID          string     `json:"id"`
+	JobName     string     `json:"job_name"`
+	Trigger     string     `json:"trigger"` // schedule, manual
+	TriggeredBy *string    `json:"triggered_by,omitempty"`
+	Instance    string     `json:"instance"`
+	Status      string     `json:"status"` // running, success, failed
+	Error       *string    `json:"error,omitempty"`
+	StartedAt   time.Time  `json:"started_at"`
+	FinishedAt  *time.Time `json:"finished_at,omitempty"`
+	DurationMs  *int64     `json:"duration_ms,omitempty"`
+}
+
 // MeetingParticipant links employees to meetings
 type MeetingParticipant struct {
 	ID         string    `json:"id"`
@@ -86,13 +189,40 @@ type MeetingParticipant struct {
 
 // Agreement represents a commitment from a meeting
 type Agreement struct {
-	ID          string   `json:"id"`
-	MeetingID   string   `json:"meeting_id"`
-	Task        string   `json:"task"`
-	Responsible string   `json:"responsible"`
-	Deadline    *string  `json:"deadline,omitempty"`
-	Status      string   `json:"status"`
-	Meeting     *Meeting `json:"meetings,omitempty"`
+	ID          string  `json:"id"`
+	MeetingID   string  `json:"meeting_id"`
+	Task        string  `json:"task"`
+	Responsible string  `json:"responsible"`
+	Deadline    *string `json:"deadline,omitempty"`
+	Status      string  `json:"status"` // pending, in_progress, done, dropped
+	// Offset in the recording where the agreement was made (seconds)
+	SourceOffsetSeconds  *float64   `json:"source_offset_seconds,omitempty"`
+	TaskID               *string    `json:"task_id,omitempty"` // Generated task kept in sync with status
+	StatusChangedAt      *time.Time `json:"status_changed_at,omitempty"`
+	CompletedAt          *time.Time `json:"completed_at,omitempty"`
+	CompletedInMeetingID *string    `json:"completed_in_meeting_id,omitempty"`
+	Meeting              *Meeting   `json:"meetings,omitempty"`
+}
+
+// TranscriptSegment is a diarized piece of a meeting transcript
+type TranscriptSegment struct {
+	ID           string  `json:"id"`
+	MeetingID    string  `json:"meeting_id"`
+	Position     int     `json:"position"`
+	SpeakerLabel *string `json:"speaker_label,omitempty"`
+	StartSeconds float64 `json:"start_seconds"`
+	EndSeconds   float64 `json:"end_seconds"`
+	Text         string  `json:"text"`
+	EmployeeID   *string `json:"employee_id,omitempty"` // Populated at runtime from speaker mapping
+}
+
+// MeetingSpeaker maps a diarization speaker label to an employee
+type MeetingSpeaker struct {
+	ID           string    `json:"id"`
+	MeetingID    string    `json:"meeting_id"`
+	SpeakerLabel string    `json:"speaker_label"`
+	EmployeeID   *string   `json:"employee_id,omitempty"`
+	Employee     *Employee `json:"employee,omitempty"`
 }
 
 // Task represents a work item
@@ -476,3 +606,49 @@ type ResourceAllocationStats struct {
 	UnderutilizedCnt  int     `json:"underutilized_count"` // < 50%
 	AvgUtilization    float64 `json:"avg_utilization"`
 }
+
+// ======== Meeting Processing Jobs ========
+
+// MeetingJob represents a background processing job for an uploaded recording
+type MeetingJob struct {
+	ID           string     `json:"id"`
+	Status       string     `json:"status"` // queued, running, completed, failed
+	CurrentStage *string    `json:"current_stage,omitempty"`
+	Progress     int        `json:"progress"` // percentage
+	Params       string     `json:"params"`   // JSON-encoded MeetingJobParams
+	AudioPath    string     `json:"-"`
+	AudioStorage string     `json:"-"` // local, minio
+	MeetingID    *string    `json:"meeting_id,omitempty"`
+	Error        *string    `json:"error,omitempty"`
+	CreatedBy    *string    `json:"created_by,omitempty"`
+	CreatedAt    *time.Time `json:"created_at,omitempty"`
+	UpdatedAt    *time.Time `json:"updated_at,omitempty"`
+	CompletedAt  *time.Time `json:"completed_at,omitempty"`
+	// Populated at runtime
+	Stages []MeetingJobStage `json:"stages,omitempty"`
+}
+
+// MeetingJobParams holds the form values submitted with the recording
+type MeetingJobParams struct {
+	CategoryCode   string   `json:"category_code"`
+	EmployeeID     string   `json:"employee_id,omitempty"`
+	ProjectID      string   `json:"project_id,omitempty"`
+	MeetingDate    string   `json:"meeting_date"`
+	Title          string   `json:"title,omitempty"`
+	ParticipantIDs []string `json:"participant_ids,omitempty"`
+	Transcribers   []string `json:"transcribers,omitempty"` // Speech-to-text providers resolved for the category
+}
+
+// MeetingJobStage represents a single stage of a meeting processing job
+type MeetingJobStage struct {
+	ID         string     `json:"id"`
+	JobID      string     `json:"job_id"`
+	Stage      string     `json:"stage"` // transcribe_<provider>, diarize, merge, analyze, save
+	Position   int        `json:"position"`
+	Status     string     `json:"status"` // pending, running, completed, failed, skipped
+	Attempts   int        `json:"attempts"`
+	Output     *string    `json:"-"`
+	Error      *string    `json:"error,omitempty"`
+	StartedAt  *time.Time `json:"started_at,omitempty"`
+	FinishedAt *time.Time `json:"finished_at,omitempty"`
+}
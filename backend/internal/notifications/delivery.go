@@ -0,0 +1,254 @@
+package notifications
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"html"
+	"time"
+
+	"github.com/ekf/one-on-one-backend/internal/templates"
+	"github.com/ekf/one-on-one-backend/internal/utils"
+	"github.com/ekf/one-on-one-backend/pkg/telegram"
+)
+
+const (
+	pollInterval = 10 * time.Second
+	deliverLock  = "notifications:deliver"
+	batchSize    = 200
+	maxAttempts  = 5
+	retryDelay   = 5 * time.Minute
+
+	digestTemplate = "notifications.digest"
+)
+
+// ErrUnavailable means a channel cannot reach the recipient: it is not configured or the
+// user has no address for it. Such deliveries are skipped rather than retried.
+var ErrUnavailable = errors.New("channel unavailable")
+
+// Recipient is a user a notification is sent to
+type Recipient struct {
+	ID             string `json:"id"`
+	Name           string `json:"name"`
+	Email          string `json:"email"`
+	TelegramChatID *int64 `json:"telegram_chat_id"`
+	Locale         string `json:"locale"`
+}
+
+// Sender delivers a message over an external channel
+type Sender interface {
+	// Format is the template format the channel takes
+	Format() templates.Format
+	Send(to Recipient, subject, body string) error
+}
+
+type emailSender struct {
+	send func(subject string, to []string, body string) error
+}
+
+// EmailSender sends notifications by email with send, which sends from the EWS service
+// account, directly or through a connector, and fails with ErrUnavailable when email is
+// not configured
+func EmailSender(send func(subject string, to []string, body string) error) Sender {
+	return &emailSender{send: send}
+}
+
+func (s *emailSender) Format() templates.Format { return templates.Email }
+
+func (s *emailSender) Send(to Recipient, subject, body string) error {
+	if to.Email == "" {
+		return fmt.Errorf("%w: recipient has no email", ErrUnavailable)
+	}
+	return s.send(subject, []string{to.Email}, body)
+}
+
+type telegramSender struct {
+	client *telegram.Client
+	token  string
+}
+
+// TelegramSender sends notifications to the Telegram chat linked to an employee
+func TelegramSender(client *telegram.Client, token string) Sender {
+	return &telegramSender{client: client, token: token}
+}
+
+func (s *telegramSender) Format() templates.Format { return templates.Telegram }
+
+func (s *telegramSender) Send(to Recipient, _, text string) error {
+	if s.client == nil || s.token == "" {
+		return fmt.Errorf("%w: Telegram bot not configured", ErrUnavailable)
+	}
+	if to.TelegramChatID == nil {
+		return fmt.Errorf("%w: recipient has no linked Telegram", ErrUnavailable)
+	}
+	return s.client.SendMessage(*to.TelegramChatID, text)
+}
+
+// deliveryRow is a due notification_deliveries row with its notification
+type deliveryRow struct {
+	ID           string                `json:"id"`
+	UserID       string                `json:"user_id"`
+	Channel      string                `json:"channel"`
+	Digest       bool                  `json:"digest"`
+	Attempts     int                   `json:"attempts"`
+	Notification *deliveryNotification `json:"notification"`
+}
+
+type deliveryNotification struct {
+	Type  string  `json:"type"`
+	Title string  `json:"title"`
+	Body  *string `json:"body"`
+	Link  *string `json:"link"`
+	Data  string  `json:"data"` // JSONB template parameters
+}
+
+func (n *deliveryNotification) link() string {
+	if n.Link == nil {
+		return ""
+	}
+	return *n.Link
+}
+
+// Start begins sending due email and Telegram deliveries in the background
+func (s *Service) Start() {
+	s.mu.Lock()
+	if s.started {
+		s.mu.Unlock()
+		return
+	}
+	s.started = true
+	s.mu.Unlock()
+
+	go func() {
+		ticker := time.NewTicker(pollInterval)
+		defer ticker.Stop()
+		for range ticker.C {
+			s.deliverDue()
+		}
+	}()
+}
+
+// deliverDue sends pending deliveries whose time has come. Digest deliveries of a user
+// and channel are sent together as one message.
+func (s *Service) deliverDue() {
+	if s.locker != nil {
+		unlock, acquired, err := s.locker.TryAdvisoryLock(context.Background(), deliverLock)
+		if err != nil || !acquired {
+			return // another replica is sending
+		}
+		defer unlock()
+	}
+
+	var due []deliveryRow
+	err := s.db.From("notification_deliveries").
+		Select("id, user_id, channel, digest, attempts, notification:notifications(type, title, body, link, data)").
+		Eq("status", StatusPending).Lte("send_after", time.Now().Format(time.RFC3339)).
+		Order("created_at", false).Limit(batchSize).Execute(&due)
+	if err != nil {
+		utils.GetLogger().Warn("Failed to load due notifications", map[string]interface{}{"error": err.Error()})
+		return
+	}
+
+	digests := make(map[string][]deliveryRow) // user_id/channel -> deliveries
+	var digestKeys []string
+	recipients := make(map[string]*Recipient)
+	for _, d := range due {
+		if d.Notification == nil {
+			continue
+		}
+		if d.Digest {
+			key := d.UserID + "/" + d.Channel
+			if _, ok := digests[key]; !ok {
+				digestKeys = append(digestKeys, key)
+			}
+			digests[key] = append(digests[key], d)
+			continue
+		}
+		var data map[string]interface{}
+		json.Unmarshal([]byte(d.Notification.Data), &data)
+		s.send([]deliveryRow{d}, s.recipient(recipients, d.UserID), d.Notification.Type,
+			templateData(data, d.Notification.link()))
+	}
+
+	// A digest lists the inbox texts of its notifications
+	for _, key := range digestKeys {
+		group := digests[key]
+		items := make([]map[string]interface{}, len(group))
+		for i, d := range group {
+			body := ""
+			if d.Notification.Body != nil {
+				body = *d.Notification.Body
+			}
+			items[i] = map[string]interface{}{"title": d.Notification.Title, "body": body, "link": d.Notification.link()}
+		}
+		s.send(group, s.recipient(recipients, group[0].UserID), digestTemplate,
+			map[string]interface{}{"count": len(group), "items": items})
+	}
+}
+
+// recipient loads the contacts of a user once per pass; nil if the user is gone
+func (s *Service) recipient(cache map[string]*Recipient, userID string) *Recipient {
+	if r, ok := cache[userID]; ok {
+		return r
+	}
+	var rows []Recipient
+	s.db.From("employees").Select("id, name, email, telegram_chat_id, locale").Eq("id", userID).Limit(1).Execute(&rows)
+	var r *Recipient
+	if len(rows) > 0 {
+		r = &rows[0]
+	}
+	cache[userID] = r
+	return r
+}
+
+// send renders a template for a group of deliveries of the same user and channel, sends
+// it as one message and records the outcome on each of them
+func (s *Service) send(group []deliveryRow, to *Recipient, template string, data map[string]interface{}) {
+	channel := group[0].Channel
+	var err error
+	switch sender := s.senders[channel]; {
+	case to == nil:
+		err = fmt.Errorf("%w: recipient not found", ErrUnavailable)
+	case sender == nil:
+		err = fmt.Errorf("%w: no sender for %s", ErrUnavailable, channel)
+	default:
+		var msg templates.Rendered
+		msg, err = s.renderer.Render(template, to.Locale, sender.Format(), data)
+		if err == nil {
+			if sender.Format() == templates.Telegram && template != digestTemplate {
+				msg.Body = "<b>" + html.EscapeString(msg.Subject) + "</b>\n\n" + msg.Body
+			}
+			err = sender.Send(*to, msg.Subject, msg.Body)
+		}
+	}
+
+	now := time.Now()
+	for _, d := range group {
+		updates := map[string]interface{}{"attempts": d.Attempts + 1}
+		switch {
+		case err == nil:
+			updates["status"] = StatusSent
+			updates["sent_at"] = now
+			updates["error"] = nil
+		case errors.Is(err, ErrUnavailable):
+			updates["status"] = StatusSkipped
+			updates["error"] = err.Error()
+		case d.Attempts+1 >= maxAttempts:
+			updates["status"] = StatusFailed
+			updates["error"] = err.Error()
+		default:
+			updates["send_after"] = now.Add(retryDelay)
+			updates["error"] = err.Error()
+		}
+		s.db.Update("notification_deliveries", "id", d.ID, updates)
+	}
+
+	if err != nil && !errors.Is(err, ErrUnavailable) {
+		utils.GetLogger().Warn("Failed to send notification", map[string]interface{}{
+			"user_id": group[0].UserID,
+			"channel": channel,
+			"error":   err.Error(),
+		})
+	}
+}
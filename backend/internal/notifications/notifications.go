@@ -0,0 +1,334 @@
+// Package notifications delivers notifications to users over in-app (inbox and WebSocket),
+// email and Telegram channels. Users choose channels per notification type, quiet hours
+// when email and Telegram are held back, and whether a type is sent as a daily digest.
+package notifications
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"sync"
+	"time"
+
+	"github.com/ekf/one-on-one-backend/internal/database"
+	"github.com/ekf/one-on-one-backend/internal/templates"
+)
+
+// Notification types
+const (
+	TypeTaskAssigned        = "task.assigned"
+	TypeTaskStatusChanged   = "task.status_changed"
+	TypeTicketAssigned      = "ticket.assigned"
+	TypeTicketSLABreached   = "ticket.sla_breached"
+	TypeImprovementApproved = "improvement.approved"
+	TypeMeetingAnalyzed     = "meeting.analyzed"
+	TypeOneOnOneProposal    = "one_on_one.proposal"
+)
+
+// Types lists the notification types users can configure
+var Types = []string{
+	TypeTaskAssigned,
+	TypeTaskStatusChanged,
+	TypeTicketAssigned,
+	TypeTicketSLABreached,
+	TypeImprovementApproved,
+	TypeMeetingAnalyzed,
+	TypeOneOnOneProposal,
+}
+
+// Channels
+const (
+	ChannelInApp    = "in_app"
+	ChannelEmail    = "email"
+	ChannelTelegram = "telegram"
+)
+
+// Delivery statuses
+const (
+	StatusPending = "pending"
+	StatusSent    = "sent"
+	StatusSkipped = "skipped" // the channel is not configured or the user has no address for it
+	StatusFailed  = "failed"
+)
+
+var (
+	ErrNotFound        = errors.New("notification not found")
+	ErrUnknownType     = errors.New("unknown notification type")
+	ErrInvalidSettings = errors.New("quiet hours must be HH:MM, digest_hour 0-23 and locale ru or en")
+)
+
+// Notification is an inbox entry
+type Notification struct {
+	ID        string          `json:"id"`
+	UserID    string          `json:"user_id"`
+	Type      string          `json:"type"`
+	Title     string          `json:"title"`
+	Body      *string         `json:"body,omitempty"`
+	Link      *string         `json:"link,omitempty"`
+	Data      json.RawMessage `json:"data,omitempty"`
+	ReadAt    *time.Time      `json:"read_at,omitempty"`
+	CreatedAt *time.Time      `json:"created_at,omitempty"`
+}
+
+// Message is a notification to send. It is rendered in the recipient's locale from the
+// template named after its type; Data holds the template parameters.
+type Message struct {
+	Type    string
+	EventID string // domain event the notification is about, so a redelivered event is skipped
+	Link    string // frontend route of the subject, "link" in the templates
+	Data    map[string]interface{}
+}
+
+// Pusher shows a new notification to a connected user
+type Pusher func(n Notification)
+
+// Service stores notifications and sends queued email and Telegram deliveries.
+// Replicas coordinate through an advisory lock, so only one of them sends at a time.
+type Service struct {
+	db       database.DBClient
+	locker   database.AdvisoryLocker
+	location *time.Location // quiet hours and digest times are in the business timezone
+	renderer *templates.Renderer
+	push     Pusher
+	senders  map[string]Sender
+
+	mu      sync.Mutex
+	started bool
+}
+
+// New creates a service. push is called for notifications shown in the app; senders
+// deliver the email and Telegram channels.
+func New(db database.DBClient, location *time.Location, renderer *templates.Renderer, push Pusher, senders map[string]Sender) *Service {
+	s := &Service{db: db, location: location, renderer: renderer, push: push, senders: senders}
+	if locker, ok := db.(database.AdvisoryLocker); ok {
+		s.locker = locker
+	}
+	return s
+}
+
+// Notify sends a message to users according to their preferences for its type
+func (s *Service) Notify(msg Message, userIDs ...string) error {
+	if msg.Data == nil {
+		msg.Data = map[string]interface{}{}
+	}
+	data, err := json.Marshal(msg.Data)
+	if err != nil {
+		return err
+	}
+
+	now := time.Now()
+	seen := make(map[string]bool, len(userIDs))
+	for _, userID := range userIDs {
+		if userID == "" || seen[userID] {
+			continue
+		}
+		seen[userID] = true
+		if err := s.notify(msg, data, userID, now); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (s *Service) notify(msg Message, data []byte, userID string, now time.Time) error {
+	if msg.EventID != "" {
+		var existing []struct {
+			ID string `json:"id"`
+		}
+		err := s.db.From("notifications").Select("id").Eq("user_id", userID).Eq("type", msg.Type).
+			Eq("event_id", msg.EventID).Limit(1).Execute(&existing)
+		if err != nil {
+			return err
+		}
+		if len(existing) > 0 {
+			return nil
+		}
+	}
+
+	pref, err := s.preference(userID, msg.Type)
+	if err != nil {
+		return err
+	}
+	if !pref.InApp && !pref.Email && !pref.Telegram {
+		return nil
+	}
+
+	// The inbox keeps the plain text in the recipient's language at the time of sending
+	rendered, err := s.renderer.Render(msg.Type, s.locale(userID), templates.Text, templateData(msg.Data, msg.Link))
+	if err != nil {
+		return err
+	}
+
+	var channels []string
+	if pref.Email {
+		channels = append(channels, ChannelEmail)
+	}
+	if pref.Telegram {
+		channels = append(channels, ChannelTelegram)
+	}
+	sendAfter := now
+	if len(channels) > 0 {
+		settings, err := s.Settings(userID)
+		if err != nil {
+			return err
+		}
+		if pref.Digest {
+			sendAfter = settings.nextDigest(now, s.location)
+		}
+		sendAfter = settings.afterQuietHours(sendAfter, s.location)
+	}
+
+	row := map[string]interface{}{
+		"user_id": userID,
+		"type":    msg.Type,
+		"title":   rendered.Subject,
+		"body":    nilIfEmpty(rendered.Body),
+		"link":    nilIfEmpty(msg.Link),
+		"data":    string(data),
+		"in_app":  pref.InApp,
+	}
+	if msg.EventID != "" {
+		row["event_id"] = msg.EventID
+	}
+
+	// The notification and its deliveries are stored together, so a notification is
+	// never left without the email or Telegram message it was meant to send
+	var n Notification
+	err = s.db.WithTx(context.Background(), func(tx database.DBClient) error {
+		result, err := tx.Insert("notifications", row)
+		if err != nil {
+			return err
+		}
+		var created []notificationRow
+		if err := json.Unmarshal(result, &created); err != nil || len(created) == 0 {
+			return errors.New("failed to read stored notification")
+		}
+		n = created[0].notification()
+
+		for _, channel := range channels {
+			_, err := tx.Insert("notification_deliveries", map[string]interface{}{
+				"notification_id": n.ID,
+				"user_id":         userID,
+				"channel":         channel,
+				"digest":          pref.Digest,
+				"send_after":      sendAfter,
+			})
+			if err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
+	if pref.InApp && s.push != nil {
+		s.push(n)
+	}
+	return nil
+}
+
+// notificationRow is a notifications row; data is JSONB and comes back as a string
+type notificationRow struct {
+	Notification
+	Data string `json:"data"`
+}
+
+func (r notificationRow) notification() Notification {
+	n := r.Notification
+	if r.Data != "" && r.Data != "{}" {
+		n.Data = json.RawMessage(r.Data)
+	}
+	return n
+}
+
+// Inbox returns the query of the notifications shown to a user in the app
+func (s *Service) Inbox(userID string, unreadOnly bool) database.QueryBuilder {
+	query := s.db.From("notifications").Select("id, user_id, type, title, body, link, data, read_at, created_at").
+		Eq("user_id", userID).Eq("in_app", "true")
+	if unreadOnly {
+		query = query.IsNull("read_at")
+	}
+	return query
+}
+
+// Scan executes an Inbox query
+func Scan(query database.QueryBuilder) ([]Notification, error) {
+	var rows []notificationRow
+	if err := query.Execute(&rows); err != nil {
+		return nil, err
+	}
+	list := make([]Notification, len(rows))
+	for i, row := range rows {
+		list[i] = row.notification()
+	}
+	return list, nil
+}
+
+// UnreadCount returns the number of unread notifications in a user's inbox
+func (s *Service) UnreadCount(userID string) (int, error) {
+	return s.Inbox(userID, true).Count()
+}
+
+// MarkRead marks a notification of a user as read
+func (s *Service) MarkRead(userID, id string) error {
+	var rows []notificationRow
+	err := s.db.From("notifications").Select("id, read_at").Eq("id", id).Eq("user_id", userID).
+		Limit(1).Execute(&rows)
+	if err != nil {
+		return err
+	}
+	if len(rows) == 0 {
+		return ErrNotFound
+	}
+	if rows[0].ReadAt != nil {
+		return nil
+	}
+	_, err = s.db.Update("notifications", "id", id, map[string]interface{}{"read_at": time.Now()})
+	return err
+}
+
+// MarkAllRead marks every unread notification of a user as read and returns their number
+func (s *Service) MarkAllRead(userID string) (int, error) {
+	result, err := s.Inbox(userID, true).Update(map[string]interface{}{"read_at": time.Now()})
+	if err != nil {
+		return 0, err
+	}
+	var marked []struct {
+		ID string `json:"id"`
+	}
+	if err := json.Unmarshal(result, &marked); err != nil {
+		return 0, err
+	}
+	return len(marked), nil
+}
+
+// locale returns the language of a user's notifications
+func (s *Service) locale(userID string) string {
+	var rows []struct {
+		Locale string `json:"locale"`
+	}
+	s.db.From("employees").Select("locale").Eq("id", userID).Limit(1).Execute(&rows)
+	if len(rows) == 0 {
+		return templates.DefaultLocale
+	}
+	return rows[0].Locale
+}
+
+// templateData returns the template parameters of a notification with its link
+func templateData(data map[string]interface{}, link string) map[string]interface{} {
+	params := make(map[string]interface{}, len(data)+1)
+	for k, v := range data {
+		params[k] = v
+	}
+	params["link"] = link
+	return params
+}
+
+func nilIfEmpty(s string) interface{} {
+	if s == "" {
+		return nil
+	}
+	return s
+}
@@ -0,0 +1,200 @@
+package notifications
+
+import (
+	"fmt"
+	"slices"
+	"time"
+
+	"github.com/ekf/one-on-one-backend/internal/templates"
+)
+
+const defaultDigestHour = 9
+
+// Preference is the set of channels a user receives a notification type over
+type Preference struct {
+	Type     string `json:"type"`
+	InApp    bool   `json:"in_app"`
+	Email    bool   `json:"email"`
+	Telegram bool   `json:"telegram"`
+	Digest   bool   `json:"digest"` // email and Telegram once a day at Settings.DigestHour
+}
+
+// defaults are the channels of types a user has not configured: everything is shown in
+// the app, and what used to be sent to Telegram still is
+var defaults = map[string]Preference{
+	TypeTaskAssigned:        {InApp: true, Telegram: true},
+	TypeTaskStatusChanged:   {InApp: true},
+	TypeTicketAssigned:      {InApp: true, Telegram: true},
+	TypeTicketSLABreached:   {InApp: true, Telegram: true},
+	TypeImprovementApproved: {InApp: true},
+	TypeMeetingAnalyzed:     {InApp: true},
+	TypeOneOnOneProposal:    {InApp: true, Telegram: true},
+}
+
+// Settings are the per-user options shared by all notification types
+type Settings struct {
+	QuietHoursStart *string `json:"quiet_hours_start"` // "22:00", nil - no quiet hours
+	QuietHoursEnd   *string `json:"quiet_hours_end"`   // "08:00"
+	DigestHour      int     `json:"digest_hour"`
+	Locale          string  `json:"locale"` // employees.locale; empty in an update keeps it
+}
+
+// Preferences returns the effective preferences of a user for every type
+func (s *Service) Preferences(userID string) ([]Preference, error) {
+	var stored []Preference
+	if err := s.db.From("notification_preferences").Select("*").Eq("user_id", userID).Execute(&stored); err != nil {
+		return nil, err
+	}
+
+	prefs := make([]Preference, len(Types))
+	for i, t := range Types {
+		prefs[i] = defaults[t]
+		prefs[i].Type = t
+		for _, p := range stored {
+			if p.Type == t {
+				prefs[i] = p
+			}
+		}
+	}
+	return prefs, nil
+}
+
+// UpdatePreferences stores the given preferences of a user; other types are left as they are
+func (s *Service) UpdatePreferences(userID string, prefs []Preference) error {
+	rows := make([]map[string]interface{}, 0, len(prefs))
+	for _, p := range prefs {
+		if !slices.Contains(Types, p.Type) {
+			return fmt.Errorf("%w: %s", ErrUnknownType, p.Type)
+		}
+		rows = append(rows, map[string]interface{}{
+			"user_id":    userID,
+			"type":       p.Type,
+			"in_app":     p.InApp,
+			"email":      p.Email,
+			"telegram":   p.Telegram,
+			"digest":     p.Digest,
+			"updated_at": time.Now(),
+		})
+	}
+	if len(rows) == 0 {
+		return nil
+	}
+	_, err := s.db.Upsert("notification_preferences", rows, "user_id, type")
+	return err
+}
+
+// Settings returns the quiet hours, digest time and language of a user
+func (s *Service) Settings(userID string) (Settings, error) {
+	var rows []Settings
+	err := s.db.From("notification_settings").Select("quiet_hours_start, quiet_hours_end, digest_hour").
+		Eq("user_id", userID).Limit(1).Execute(&rows)
+	if err != nil {
+		return Settings{}, err
+	}
+	settings := Settings{DigestHour: defaultDigestHour}
+	if len(rows) > 0 {
+		settings = rows[0]
+	}
+	settings.Locale = templates.NormalizeLocale(s.locale(userID))
+	return settings, nil
+}
+
+// UpdateSettings stores the quiet hours, digest time and language of a user. Quiet hours
+// are set or cleared together.
+func (s *Service) UpdateSettings(userID string, settings Settings) error {
+	if (settings.QuietHoursStart == nil) != (settings.QuietHoursEnd == nil) ||
+		settings.DigestHour < 0 || settings.DigestHour > 23 {
+		return ErrInvalidSettings
+	}
+	for _, v := range []*string{settings.QuietHoursStart, settings.QuietHoursEnd} {
+		if v != nil {
+			if _, err := parseClock(*v); err != nil {
+				return ErrInvalidSettings
+			}
+		}
+	}
+	if settings.Locale != "" && !slices.Contains(templates.Locales, settings.Locale) {
+		return ErrInvalidSettings
+	}
+
+	_, err := s.db.Upsert("notification_settings", []map[string]interface{}{{
+		"user_id":           userID,
+		"quiet_hours_start": settings.QuietHoursStart,
+		"quiet_hours_end":   settings.QuietHoursEnd,
+		"digest_hour":       settings.DigestHour,
+		"updated_at":        time.Now(),
+	}}, "user_id")
+	if err != nil || settings.Locale == "" {
+		return err
+	}
+	_, err = s.db.Update("employees", "id", userID, map[string]interface{}{"locale": settings.Locale})
+	return err
+}
+
+// preference returns the channels of a user for a notification type
+func (s *Service) preference(userID, notificationType string) (Preference, error) {
+	var stored []Preference
+	err := s.db.From("notification_preferences").Select("*").Eq("user_id", userID).
+		Eq("type", notificationType).Limit(1).Execute(&stored)
+	if err != nil {
+		return Preference{}, err
+	}
+	if len(stored) > 0 {
+		return stored[0], nil
+	}
+	pref, ok := defaults[notificationType]
+	if !ok {
+		pref = Preference{InApp: true}
+	}
+	pref.Type = notificationType
+	return pref, nil
+}
+
+// afterQuietHours moves t to the end of the quiet hours if it falls into them
+func (st Settings) afterQuietHours(t time.Time, loc *time.Location) time.Time {
+	if st.QuietHoursStart == nil || st.QuietHoursEnd == nil {
+		return t
+	}
+	start, err1 := parseClock(*st.QuietHoursStart)
+	end, err2 := parseClock(*st.QuietHoursEnd)
+	if err1 != nil || err2 != nil || start == end {
+		return t
+	}
+
+	local := t.In(loc)
+	minute := local.Hour()*60 + local.Minute()
+	var quiet bool
+	if start < end {
+		quiet = minute >= start && minute < end
+	} else { // over midnight, e.g. 22:00-08:00
+		quiet = minute >= start || minute < end
+	}
+	if !quiet {
+		return t
+	}
+
+	until := time.Date(local.Year(), local.Month(), local.Day(), end/60, end%60, 0, 0, loc)
+	if !until.After(local) {
+		until = until.AddDate(0, 0, 1)
+	}
+	return until
+}
+
+// nextDigest returns the next digest time after t
+func (st Settings) nextDigest(t time.Time, loc *time.Location) time.Time {
+	local := t.In(loc)
+	digest := time.Date(local.Year(), local.Month(), local.Day(), st.DigestHour, 0, 0, 0, loc)
+	if !digest.After(local) {
+		digest = digest.AddDate(0, 0, 1)
+	}
+	return digest
+}
+
+// parseClock parses "HH:MM" into minutes since midnight
+func parseClock(v string) (int, error) {
+	t, err := time.Parse("15:04", v)
+	if err != nil {
+		return 0, err
+	}
+	return t.Hour()*60 + t.Minute(), nil
+}
@@ -0,0 +1,88 @@
+package notifications
+
+import (
+	"testing"
+	"time"
+)
+
+var msk = time.FixedZone("MSK", 3*60*60)
+
+func at(day, hour, minute int) time.Time {
+	return time.Date(2026, 10, day, hour, minute, 0, 0, msk)
+}
+
+func quietHours(start, end string) Settings {
+	return Settings{QuietHoursStart: &start, QuietHoursEnd: &end}
+}
+
+func TestAfterQuietHours(t *testing.T) {
+	overnight := quietHours("22:00", "08:00")
+	lunch := quietHours("13:00", "14:00")
+
+	tests := []struct {
+		name     string
+		settings Settings
+		t        time.Time
+		want     time.Time
+	}{
+		{"no quiet hours", Settings{}, at(17, 23, 0), at(17, 23, 0)},
+		{"only start set", Settings{QuietHoursStart: overnight.QuietHoursStart}, at(17, 23, 0), at(17, 23, 0)},
+		{"invalid clock", quietHours("25:00", "08:00"), at(17, 23, 0), at(17, 23, 0)},
+		{"empty interval", quietHours("08:00", "08:00"), at(17, 8, 0), at(17, 8, 0)},
+
+		{"before overnight", overnight, at(17, 21, 59), at(17, 21, 59)},
+		{"start of overnight", overnight, at(17, 22, 0), at(18, 8, 0)},
+		{"evening", overnight, at(17, 23, 30), at(18, 8, 0)},
+		{"after midnight", overnight, at(18, 2, 0), at(18, 8, 0)},
+		{"end is not quiet", overnight, at(18, 8, 0), at(18, 8, 0)},
+		{"daytime", overnight, at(18, 12, 0), at(18, 12, 0)},
+		// 20:30 UTC is 23:30 in Moscow
+		{"other time zone", overnight, time.Date(2026, 10, 17, 20, 30, 0, 0, time.UTC), at(18, 8, 0)},
+
+		{"before daytime interval", lunch, at(17, 12, 59), at(17, 12, 59)},
+		{"inside daytime interval", lunch, at(17, 13, 30), at(17, 14, 0)},
+		{"after daytime interval", lunch, at(17, 14, 0), at(17, 14, 0)},
+	}
+	for _, tt := range tests {
+		if got := tt.settings.afterQuietHours(tt.t, msk); !got.Equal(tt.want) {
+			t.Errorf("%s: afterQuietHours(%s) = %s, want %s", tt.name, tt.t, got, tt.want)
+		}
+	}
+}
+
+func TestNextDigest(t *testing.T) {
+	settings := Settings{DigestHour: 9}
+	tests := []struct {
+		name string
+		t    time.Time
+		want time.Time
+	}{
+		{"before the digest", at(17, 8, 0), at(17, 9, 0)},
+		{"at the digest", at(17, 9, 0), at(18, 9, 0)},
+		{"after the digest", at(17, 9, 1), at(18, 9, 0)},
+		{"late evening", at(17, 23, 59), at(18, 9, 0)},
+		{"end of month", time.Date(2026, 10, 31, 10, 0, 0, 0, msk), time.Date(2026, 11, 1, 9, 0, 0, 0, msk)},
+		// 22:00 UTC on the 17th is already the 18th in Moscow
+		{"other time zone", time.Date(2026, 10, 17, 22, 0, 0, 0, time.UTC), at(18, 9, 0)},
+	}
+	for _, tt := range tests {
+		if got := settings.nextDigest(tt.t, msk); !got.Equal(tt.want) {
+			t.Errorf("%s: nextDigest(%s) = %s, want %s", tt.name, tt.t, got, tt.want)
+		}
+	}
+
+	midnight := Settings{DigestHour: 0}
+	if got := midnight.nextDigest(at(17, 0, 0), msk); !got.Equal(at(18, 0, 0)) {
+		t.Errorf("midnight digest: got %s", got)
+	}
+}
+
+// A digest scheduled inside quiet hours is held back until they end, as notify does
+func TestDigestDuringQuietHours(t *testing.T) {
+	settings := quietHours("22:00", "10:00")
+	settings.DigestHour = 9
+	sendAfter := settings.afterQuietHours(settings.nextDigest(at(17, 12, 0), msk), msk)
+	if want := at(18, 10, 0); !sendAfter.Equal(want) {
+		t.Errorf("send after %s, want %s", sendAfter, want)
+	}
+}
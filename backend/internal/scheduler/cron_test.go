@@ -0,0 +1,96 @@
+package scheduler
+
+import (
+	"testing"
+	"time"
+)
+
+func TestParseScheduleErrors(t *testing.T) {
+	tests := []string{
+		"",
+		"* * * *",
+		"* * * * * *",
+		"60 * * * *",
+		"* 24 * * *",
+		"* * 0 * *",
+		"* * 32 * *",
+		"* * * 0 *",
+		"* * * 13 *",
+		"* * * * 8",
+		"*/0 * * * *",
+		"*/x * * * *",
+		"5-1 * * * *",
+		"1-x * * * *",
+		"a * * * *",
+		"1,,2 * * * *",
+		"@yearly",
+	}
+	for _, expr := range tests {
+		if _, err := ParseSchedule(expr); err == nil {
+			t.Errorf("ParseSchedule(%q) succeeded, want an error", expr)
+		}
+	}
+}
+
+func TestScheduleNext(t *testing.T) {
+	// Saturday
+	base := time.Date(2026, 10, 17, 10, 7, 30, 0, time.UTC)
+	at := func(month time.Month, day, hour, minute int) time.Time {
+		return time.Date(2026, month, day, hour, minute, 0, 0, time.UTC)
+	}
+
+	tests := []struct {
+		expr string
+		from time.Time
+		want time.Time
+	}{
+		{"* * * * *", base, at(10, 17, 10, 8)},
+		{"*/15 * * * *", base, at(10, 17, 10, 15)},
+		{"*/15 * * * *", at(10, 17, 10, 15), at(10, 17, 10, 30)}, // strictly after
+		{"30 10 * * *", base, at(10, 17, 10, 30)},
+		{"0 10 * * *", base, at(10, 18, 10, 0)},
+		{"0 8-18/2 * * *", base, at(10, 17, 12, 0)},
+		{"5,50 * * * *", base, at(10, 17, 10, 50)},
+		{"@hourly", base, at(10, 17, 11, 0)},
+		{"@daily", base, at(10, 18, 0, 0)},
+		{"@weekly", base, at(10, 18, 0, 0)},
+		{"@monthly", base, at(11, 1, 0, 0)},
+		{"0 9 * * 1-5", base, at(10, 19, 9, 0)},
+		{"0 0 * * 7", base, at(10, 18, 0, 0)}, // 7 is Sunday too
+		{"0 0 * * 6", base, at(10, 24, 0, 0)},
+		{"0 0 13 * *", base, at(11, 13, 0, 0)},
+		// Both day fields restricted: either matches (the next Friday comes before the 13th)
+		{"0 12 13 * 5", base, at(10, 23, 12, 0)},
+		// Only day of week restricted: day of month does not widen it
+		{"0 12 * * 5", base, at(10, 23, 12, 0)},
+		{"0 0 1 1 *", base, time.Date(2027, 1, 1, 0, 0, 0, 0, time.UTC)},
+		{"0 0 31 * *", base, at(10, 31, 0, 0)},
+		{"0 0 29 2 *", base, time.Date(2028, 2, 29, 0, 0, 0, 0, time.UTC)}, // leap year
+		{"0 0 30 2 *", base, time.Time{}},
+		{"0 0 31 11 *", base, time.Time{}},
+	}
+	for _, tt := range tests {
+		s, err := ParseSchedule(tt.expr)
+		if err != nil {
+			t.Errorf("ParseSchedule(%q): %v", tt.expr, err)
+			continue
+		}
+		if got := s.Next(tt.from); !got.Equal(tt.want) {
+			t.Errorf("%q.Next(%s) = %s, want %s", tt.expr, tt.from.Format(time.RFC3339), got, tt.want)
+		}
+	}
+}
+
+func TestScheduleNextKeepsLocation(t *testing.T) {
+	loc := time.FixedZone("MSK", 3*60*60)
+	s, err := ParseSchedule("0 9 * * *")
+	if err != nil {
+		t.Fatal(err)
+	}
+	// 08:30 in Moscow is 05:30 UTC: the next run is 09:00 Moscow time the same day
+	got := s.Next(time.Date(2026, 10, 19, 8, 30, 0, 0, loc))
+	want := time.Date(2026, 10, 19, 9, 0, 0, 0, loc)
+	if !got.Equal(want) || got.Location() != loc {
+		t.Errorf("Next() = %s, want %s", got, want)
+	}
+}
@@ -0,0 +1,413 @@
+package scheduler
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/ekf/one-on-one-backend/internal/database"
+	"github.com/ekf/one-on-one-backend/internal/models"
+	"github.com/ekf/one-on-one-backend/internal/utils"
+)
+
+// Job run statuses
+const (
+	StatusRunning = "running"
+	StatusSuccess = "success"
+	StatusFailed  = "failed"
+)
+
+// Run triggers
+const (
+	TriggerSchedule = "schedule"
+	TriggerManual   = "manual"
+)
+
+const (
+	tickInterval   = 30 * time.Second
+	defaultTimeout = 30 * time.Minute
+	lockPrefix     = "scheduler:"
+)
+
+var (
+	ErrJobNotFound = errors.New("job not found")
+	ErrJobRunning  = errors.New("job is already running")
+)
+
+// JobFunc is the body of a job. ctx is cancelled when the job timeout expires.
+type JobFunc func(ctx context.Context) error
+
+// Job is a registered background job. Schedule is the default used when the job is
+// first stored; afterwards the schedule in scheduled_jobs (editable by admins) wins.
+type Job struct {
+	Name        string
+	Description string
+	Schedule    string
+	Timeout     time.Duration
+	Run         JobFunc
+}
+
+// Scheduler runs registered jobs on their cron schedules. Job definitions and run
+// history live in PostgreSQL; every run takes an advisory lock so that only one
+// backend replica executes a job at a time.
+type Scheduler struct {
+	db       database.DBClient
+	locker   database.AdvisoryLocker
+	location *time.Location
+	instance string
+
+	mu      sync.Mutex
+	jobs    map[string]*Job
+	running map[string]bool
+	started bool
+}
+
+// New creates a scheduler evaluating schedules in loc
+func New(db database.DBClient, loc *time.Location) *Scheduler {
+	hostname, _ := os.Hostname()
+	s := &Scheduler{
+		db:       db,
+		location: loc,
+		instance: fmt.Sprintf("%s-%d", hostname, os.Getpid()),
+		jobs:     make(map[string]*Job),
+		running:  make(map[string]bool),
+	}
+	if locker, ok := db.(database.AdvisoryLocker); ok {
+		s.locker = locker
+	}
+	return s
+}
+
+// Register adds a job and stores its definition if it is not stored yet
+func (s *Scheduler) Register(job Job) error {
+	schedule, err := ParseSchedule(job.Schedule)
+	if err != nil {
+		return fmt.Errorf("job %s: %w", job.Name, err)
+	}
+	if job.Timeout == 0 {
+		job.Timeout = defaultTimeout
+	}
+
+	s.mu.Lock()
+	s.jobs[job.Name] = &job
+	s.mu.Unlock()
+
+	stored, err := s.job(job.Name)
+	if err != nil {
+		_, err = s.db.Insert("scheduled_jobs", map[string]interface{}{
+			"name":        job.Name,
+			"description": job.Description,
+			"schedule":    job.Schedule,
+			"next_run_at": schedule.Next(time.Now().In(s.location)),
+		})
+		return err
+	}
+
+	updates := map[string]interface{}{}
+	if stored.Description == nil || *stored.Description != job.Description {
+		updates["description"] = job.Description
+	}
+	if stored.NextRunAt == nil && !stored.Paused {
+		if next, err := s.nextRun(stored.Schedule, time.Now()); err == nil {
+			updates["next_run_at"] = next
+		}
+	}
+	if len(updates) > 0 {
+		updates["updated_at"] = time.Now()
+		_, err = s.db.Update("scheduled_jobs", "name", job.Name, updates)
+	}
+	return err
+}
+
+// Start begins checking for due jobs in the background
+func (s *Scheduler) Start() {
+	s.mu.Lock()
+	if s.started {
+		s.mu.Unlock()
+		return
+	}
+	s.started = true
+	s.mu.Unlock()
+
+	go func() {
+		ticker := time.NewTicker(tickInterval)
+		defer ticker.Stop()
+		for now := range ticker.C {
+			s.runDue(now)
+		}
+	}()
+}
+
+// Jobs returns stored job definitions with their last run status
+func (s *Scheduler) Jobs() ([]models.ScheduledJob, error) {
+	var jobs []models.ScheduledJob
+	if err := s.db.From("scheduled_jobs").Select("*").Order("name", false).Execute(&jobs); err != nil {
+		return nil, err
+	}
+	return jobs, nil
+}
+
+// Job returns a stored job definition
+func (s *Scheduler) Job(name string) (*models.ScheduledJob, error) {
+	if !s.registered(name) {
+		return nil, ErrJobNotFound
+	}
+	job, err := s.job(name)
+	if err != nil {
+		return nil, ErrJobNotFound
+	}
+	return job, nil
+}
+
+// Runs returns the latest runs of a job, newest first
+func (s *Scheduler) Runs(name string, limit int) ([]models.ScheduledJobRun, error) {
+	var runs []models.ScheduledJobRun
+	err := s.db.From("scheduled_job_runs").Select("*").Eq("job_name", name).
+		Order("started_at", true).Limit(limit).Execute(&runs)
+	return runs, err
+}
+
+// Trigger runs a job now regardless of its schedule and pause state.
+// The job runs in the background; ErrJobRunning is returned if any replica is running it.
+func (s *Scheduler) Trigger(name, userID string) error {
+	job := s.registeredJob(name)
+	if job == nil {
+		return ErrJobNotFound
+	}
+
+	unlock, err := s.acquire(name)
+	if err != nil {
+		return err
+	}
+
+	go s.execute(job, unlock, TriggerManual, userID)
+	return nil
+}
+
+// SetPaused pauses or resumes scheduled runs of a job. Resuming schedules
+// the next run from now, so missed runs are not caught up.
+func (s *Scheduler) SetPaused(name string, paused bool) error {
+	job, err := s.Job(name)
+	if err != nil {
+		return err
+	}
+
+	updates := map[string]interface{}{
+		"paused":     paused,
+		"updated_at": time.Now(),
+	}
+	if !paused {
+		next, err := s.nextRun(job.Schedule, time.Now())
+		if err != nil {
+			return err
+		}
+		updates["next_run_at"] = next
+	}
+	_, err = s.db.Update("scheduled_jobs", "name", name, updates)
+	return err
+}
+
+// SetSchedule changes the cron expression of a job
+func (s *Scheduler) SetSchedule(name, expr string) error {
+	if _, err := s.Job(name); err != nil {
+		return err
+	}
+	next, err := s.nextRun(expr, time.Now())
+	if err != nil {
+		return err
+	}
+
+	_, err = s.db.Update("scheduled_jobs", "name", name, map[string]interface{}{
+		"schedule":    expr,
+		"next_run_at": next,
+		"updated_at":  time.Now(),
+	})
+	return err
+}
+
+// runDue starts every unpaused job whose next run time has come
+func (s *Scheduler) runDue(now time.Time) {
+	var stored []models.ScheduledJob
+	if err := s.db.From("scheduled_jobs").Select("name, schedule, paused, next_run_at").Execute(&stored); err != nil {
+		utils.GetLogger().Warn("Failed to load scheduled jobs", map[string]interface{}{"error": err.Error()})
+		return
+	}
+
+	sort.Slice(stored, func(i, j int) bool { return stored[i].Name < stored[j].Name })
+	for _, row := range stored {
+		job := s.registeredJob(row.Name)
+		if job == nil || row.Paused || row.NextRunAt == nil || row.NextRunAt.After(now) {
+			continue
+		}
+
+		unlock, err := s.acquire(row.Name)
+		if err != nil {
+			continue // running here or on another replica
+		}
+
+		// Another replica may have finished the run between loading and locking
+		current, err := s.job(row.Name)
+		if err != nil || current.Paused || current.NextRunAt == nil || current.NextRunAt.After(now) {
+			s.release(row.Name, unlock)
+			continue
+		}
+
+		go s.execute(job, unlock, TriggerSchedule, "")
+	}
+}
+
+// acquire marks the job as running in this process and takes its cluster-wide lock
+func (s *Scheduler) acquire(name string) (func(), error) {
+	s.mu.Lock()
+	if s.running[name] {
+		s.mu.Unlock()
+		return nil, ErrJobRunning
+	}
+	s.running[name] = true
+	s.mu.Unlock()
+
+	unlock := func() {}
+	if s.locker != nil {
+		ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+		defer cancel()
+
+		dbUnlock, acquired, err := s.locker.TryAdvisoryLock(ctx, lockPrefix+name)
+		if err != nil || !acquired {
+			s.release(name, nil)
+			if err != nil {
+				return nil, err
+			}
+			return nil, ErrJobRunning
+		}
+		unlock = dbUnlock
+	}
+	return unlock, nil
+}
+
+func (s *Scheduler) release(name string, unlock func()) {
+	if unlock != nil {
+		unlock()
+	}
+	s.mu.Lock()
+	delete(s.running, name)
+	s.mu.Unlock()
+}
+
+// execute runs a locked job and records the run
+func (s *Scheduler) execute(job *Job, unlock func(), trigger, userID string) {
+	defer s.release(job.Name, unlock)
+
+	started := time.Now()
+	jobUpdates := map[string]interface{}{
+		"last_run_at":   started,
+		"last_status":   StatusRunning,
+		"last_error":    nil,
+		"last_instance": s.instance,
+		"updated_at":    started,
+	}
+	if stored, err := s.job(job.Name); err == nil {
+		if next, err := s.nextRun(stored.Schedule, started); err == nil {
+			jobUpdates["next_run_at"] = next
+		}
+	}
+	s.db.Update("scheduled_jobs", "name", job.Name, jobUpdates)
+
+	var runID string
+	run := map[string]interface{}{
+		"job_name":   job.Name,
+		"trigger":    trigger,
+		"instance":   s.instance,
+		"status":     StatusRunning,
+		"started_at": started,
+	}
+	if userID != "" {
+		run["triggered_by"] = userID
+	}
+	if result, err := s.db.Insert("scheduled_job_runs", run); err == nil {
+		var created []map[string]interface{}
+		json.Unmarshal(result, &created)
+		if len(created) > 0 {
+			runID, _ = created[0]["id"].(string)
+		}
+	}
+
+	err := s.runJob(job)
+
+	finished := time.Now()
+	duration := finished.Sub(started).Milliseconds()
+	status := StatusSuccess
+	var errText interface{}
+	if err != nil {
+		status = StatusFailed
+		errText = err.Error()
+		utils.GetLogger().Warn("Scheduled job failed", map[string]interface{}{
+			"job":   job.Name,
+			"error": err.Error(),
+		})
+	}
+
+	s.db.Update("scheduled_jobs", "name", job.Name, map[string]interface{}{
+		"last_finished_at": finished,
+		"last_status":      status,
+		"last_error":       errText,
+		"last_duration_ms": duration,
+		"updated_at":       finished,
+	})
+	if runID != "" {
+		s.db.Update("scheduled_job_runs", "id", runID, map[string]interface{}{
+			"status":      status,
+			"error":       errText,
+			"finished_at": finished,
+			"duration_ms": duration,
+		})
+	}
+}
+
+// runJob calls the job function with its timeout, turning panics into errors
+func (s *Scheduler) runJob(job *Job) (err error) {
+	ctx, cancel := context.WithTimeout(context.Background(), job.Timeout)
+	defer cancel()
+
+	defer func() {
+		if r := recover(); r != nil {
+			err = fmt.Errorf("panic: %v", r)
+		}
+	}()
+	return job.Run(ctx)
+}
+
+func (s *Scheduler) job(name string) (*models.ScheduledJob, error) {
+	var job models.ScheduledJob
+	if err := s.db.From("scheduled_jobs").Select("*").Eq("name", name).Single().Execute(&job); err != nil {
+		return nil, err
+	}
+	return &job, nil
+}
+
+func (s *Scheduler) registered(name string) bool {
+	return s.registeredJob(name) != nil
+}
+
+func (s *Scheduler) registeredJob(name string) *Job {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.jobs[name]
+}
+
+// nextRun returns the first run time of expr after t, in the scheduler timezone
+func (s *Scheduler) nextRun(expr string, t time.Time) (time.Time, error) {
+	schedule, err := ParseSchedule(expr)
+	if err != nil {
+		return time.Time{}, err
+	}
+	next := schedule.Next(t.In(s.location))
+	if next.IsZero() {
+		return time.Time{}, fmt.Errorf("schedule %q never fires", expr)
+	}
+	return next, nil
+}
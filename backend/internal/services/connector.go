@@ -3,181 +3,670 @@ package services
 import (
 	"encoding/json"
 	"errors"
+	"fmt"
+	"sort"
+	"strings"
 	"sync"
 	"time"
 
+	"github.com/ekf/one-on-one-backend/pkg/protocol"
 	"github.com/gofiber/websocket/v2"
 	"github.com/google/uuid"
 )
 
-// ConnectorManager manages WebSocket connection to on-prem connector
+// Connector capabilities
+const (
+	CapabilityAD  = "ad"
+	CapabilityEWS = "ews"
+)
+
+// DefaultConnectorName is the name of a connector that does not send one
+const DefaultConnectorName = "default"
+
+const (
+	// connectorStaleAfter is how long a connector may stay silent before it is considered
+	// unhealthy; connectors send a heartbeat every 15-30 seconds
+	connectorStaleAfter = 90 * time.Second
+	// connectorMaxFailures is the number of timeouts in a row after which a connector is
+	// considered unhealthy until it answers again
+	connectorMaxFailures = 3
+	// connectorReconnectWait is how long a command waits for a connector to (re)connect
+	// before it fails
+	connectorReconnectWait = 15 * time.Second
+)
+
+var (
+	ErrConnectorNotConnected = errors.New("connector not connected")
+	ErrUnsupportedCommand    = errors.New("no connected connector supports the command; upgrade the connector")
+	// ErrDomainNotServed means connectors that can run the command are connected, but none
+	// of them serves the domain the command targets
+	ErrDomainNotServed       = errors.New("no connector serves")
+	errConnectorDisconnected = errors.New("connector disconnected")
+	errRequestTimeout        = errors.New("request timeout")
+	// ErrCommandInterrupted means the connector dropped after receiving a command that is
+	// not sent again, so whether it ran is unknown
+	ErrCommandInterrupted = errors.New("connector disconnected while running the command; it may have completed")
+)
+
+// ConnectorManager manages WebSocket connections to on-prem connectors. Each office runs
+// its own connector with access to its AD and Exchange; commands are routed to a
+// connector that has the capability the command needs and serves the target domain.
 type ConnectorManager struct {
-	connector       *websocket.Conn
-	pendingRequests map[string]chan ConnectorResponse
-	connected       bool
+	connectors      map[string]*Connector // by name
+	pendingRequests map[string]*pendingRequest
+	connected       chan struct{} // closed and replaced when a connector connects
 	mutex           sync.RWMutex
-	apiKey          string
 }
 
-// ConnectorCommand represents a command to send to the connector
-type ConnectorCommand struct {
-	Command   string                 `json:"command"`
-	RequestID string                 `json:"request_id"`
-	Params    map[string]interface{} `json:"params"`
+// ConnectorInfo describes a connector, sent by it when connecting
+type ConnectorInfo struct {
+	Name         string
+	Capabilities []string // ad, ews
+	Sites        []string // AD/mail domains it serves, e.g. "ekfgroup", "ekf.su"; none - any
+}
+
+// NewConnectorInfo builds the info from comma-separated lists. A connector without a name
+// is "default", one without capabilities has all of them, as before connectors sent them.
+func NewConnectorInfo(name, capabilities, sites string) ConnectorInfo {
+	info := ConnectorInfo{
+		Name:         strings.TrimSpace(name),
+		Capabilities: splitList(capabilities),
+		Sites:        splitList(sites),
+	}
+	if info.Name == "" {
+		info.Name = DefaultConnectorName
+	}
+	if len(info.Capabilities) == 0 {
+		info.Capabilities = []string{CapabilityAD, CapabilityEWS}
+	}
+	return info
+}
+
+// Connector is a connected on-prem connector
+type Connector struct {
+	info        ConnectorInfo
+	conn        *websocket.Conn
+	writeMu     sync.Mutex // a WebSocket allows one writer at a time
+	connectedAt time.Time
+
+	// Guarded by ConnectorManager.mutex
+	auth      ConnectorAuth
+	version   int      // protocol version, 1 until the connector says hello
+	commands  []string // commands the connector handles
+	software  string
+	lastSeen  time.Time
+	lastUsed  time.Time
+	inFlight  int
+	completed int
+	failed    int
+	failures  int // timeouts in a row
+	lastError string
+}
+
+// ConnectorStatus is the health of a connector
+type ConnectorStatus struct {
+	Name         string           `json:"name"`
+	Capabilities []string         `json:"capabilities"`
+	Sites        []string         `json:"sites"`
+	Auth         ConnectorAuth    `json:"auth"`
+	Version      int              `json:"protocol_version"`
+	Software     string           `json:"software,omitempty"`
+	Commands     []string         `json:"commands"`
+	Healthy      bool             `json:"healthy"`
+	ConnectedAt  time.Time        `json:"connected_at"`
+	LastSeen     time.Time        `json:"last_seen"`
+	InFlight     int              `json:"in_flight"`
+	Completed    int              `json:"completed"`
+	Failed       int              `json:"failed"`
+	LastError    string           `json:"last_error,omitempty"`
+	Running      []RunningCommand `json:"running"`
+}
+
+// RunningCommand is a command a connector is working on
+type RunningCommand struct {
+	Command   string             `json:"command"`
+	StartedAt time.Time          `json:"started_at"`
+	Progress  *protocol.Progress `json:"progress,omitempty"`
+}
+
+type pendingRequest struct {
+	connector *Connector
+	command   string
+	startedAt time.Time
+	response  chan connectorReply
+	// Streaming, guarded by ConnectorManager.mutex
+	activity   chan struct{} // signalled by progress and chunks, restarts the timeout
+	progress   *protocol.Progress
+	onProgress func(protocol.Progress)
+	chunks     int
+	result     []byte
 }
 
-// ConnectorResponse represents a response from the connector
-type ConnectorResponse struct {
-	Type      string                 `json:"type"`
-	RequestID string                 `json:"request_id"`
-	Command   string                 `json:"command"`
-	Success   bool                   `json:"success"`
-	Error     string                 `json:"error,omitempty"`
-	Result    map[string]interface{} `json:"result,omitempty"`
-	Timestamp string                 `json:"timestamp"`
+// connectorReply is a response or the transport failure that ended the wait for it
+type connectorReply struct {
+	protocol.Response
+	err error
 }
 
 // NewConnectorManager creates a new connector manager
-func NewConnectorManager(apiKey string) *ConnectorManager {
+func NewConnectorManager() *ConnectorManager {
 	return &ConnectorManager{
-		pendingRequests: make(map[string]chan ConnectorResponse),
-		apiKey:          apiKey,
+		connectors:      make(map[string]*Connector),
+		pendingRequests: make(map[string]*pendingRequest),
+		connected:       make(chan struct{}),
 	}
 }
 
-// Connect handles a new connector connection
-func (m *ConnectorManager) Connect(conn *websocket.Conn, apiKey string) error {
-	if m.apiKey != "" && apiKey != m.apiKey {
-		return errors.New("invalid API key")
+// Connect registers a connection authenticated by auth. A connector reconnecting under the
+// same name replaces its previous connection.
+func (m *ConnectorManager) Connect(conn *websocket.Conn, info ConnectorInfo, auth ConnectorAuth) *Connector {
+	now := time.Now()
+	c := &Connector{
+		info:        info,
+		auth:        auth,
+		conn:        conn,
+		connectedAt: now,
+		version:     1,
+		commands:    protocol.LegacyCommands,
+		lastSeen:    now,
 	}
 
 	m.mutex.Lock()
-	m.connector = conn
-	m.connected = true
+	previous := m.connectors[info.Name]
+	m.connectors[info.Name] = c
+	if previous != nil {
+		m.failPending(previous)
+	}
+	close(m.connected)
+	m.connected = make(chan struct{})
 	m.mutex.Unlock()
 
-	return nil
+	if previous != nil {
+		previous.conn.Close()
+	}
+	return c
 }
 
-// Disconnect handles connector disconnect
-func (m *ConnectorManager) Disconnect() {
+// Disconnect removes a connector and fails its pending requests
+func (m *ConnectorManager) Disconnect(c *Connector) {
 	m.mutex.Lock()
 	defer m.mutex.Unlock()
 
-	m.connector = nil
-	m.connected = false
+	if m.connectors[c.info.Name] == c {
+		delete(m.connectors, c.info.Name)
+	}
+	m.failPending(c)
+}
 
-	// Fail all pending requests
-	for _, ch := range m.pendingRequests {
-		ch <- ConnectorResponse{
-			Success: false,
-			Error:   "Connector disconnected",
+// DisconnectCredential closes the connections authenticated with a credential, after it
+// was revoked
+func (m *ConnectorManager) DisconnectCredential(credentialID string) {
+	m.mutex.RLock()
+	var conns []*websocket.Conn
+	for _, c := range m.connectors {
+		if c.auth.CredentialID == credentialID {
+			conns = append(conns, c.conn)
 		}
-		close(ch)
 	}
-	m.pendingRequests = make(map[string]chan ConnectorResponse)
+	m.mutex.RUnlock()
+
+	// The read loops of the connections end and disconnect them
+	for _, conn := range conns {
+		conn.Close()
+	}
 }
 
-// IsConnected returns connector status
-func (m *ConnectorManager) IsConnected() bool {
+// ExpireCredential closes the connections authenticated with a credential once it expires,
+// after its expiry was brought forward by a rotation
+func (m *ConnectorManager) ExpireCredential(credentialID string, expiresAt time.Time) {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+	for _, c := range m.connectors {
+		if c.auth.CredentialID == credentialID {
+			c.auth.ExpiresAt = &expiresAt
+		}
+	}
+}
+
+// failPending fails the pending requests of a connector. The caller holds the lock.
+func (m *ConnectorManager) failPending(c *Connector) {
+	for id, p := range m.pendingRequests {
+		if p.connector == c {
+			delete(m.pendingRequests, id)
+			p.response <- connectorReply{err: errConnectorDisconnected}
+		}
+	}
+}
+
+// Connected returns a channel closed when the next connector connects
+func (m *ConnectorManager) Connected() <-chan struct{} {
 	m.mutex.RLock()
 	defer m.mutex.RUnlock()
 	return m.connected
 }
 
-// SendCommand sends a command to the connector and waits for response
-func (m *ConnectorManager) SendCommand(command string, params map[string]interface{}, timeout time.Duration) (map[string]interface{}, error) {
+// IsConnected reports whether any connector is connected
+func (m *ConnectorManager) IsConnected() bool {
 	m.mutex.RLock()
-	if !m.connected || m.connector == nil {
-		m.mutex.RUnlock()
-		return nil, errors.New("connector not connected")
+	defer m.mutex.RUnlock()
+	return len(m.connectors) > 0
+}
+
+// Status returns the health of the connected connectors, sorted by name
+func (m *ConnectorManager) Status() []ConnectorStatus {
+	m.mutex.RLock()
+	defer m.mutex.RUnlock()
+
+	now := time.Now()
+	running := make(map[*Connector][]RunningCommand)
+	for _, p := range m.pendingRequests {
+		running[p.connector] = append(running[p.connector], RunningCommand{
+			Command:   p.command,
+			StartedAt: p.startedAt,
+			Progress:  p.progress,
+		})
 	}
-	conn := m.connector
-	m.mutex.RUnlock()
 
+	statuses := make([]ConnectorStatus, 0, len(m.connectors))
+	for _, c := range m.connectors {
+		sort.Slice(running[c], func(i, j int) bool { return running[c][i].StartedAt.Before(running[c][j].StartedAt) })
+		statuses = append(statuses, ConnectorStatus{
+			Name:         c.info.Name,
+			Capabilities: c.info.Capabilities,
+			Sites:        c.info.Sites,
+			Auth:         c.auth,
+			Version:      c.version,
+			Software:     c.software,
+			Commands:     c.commands,
+			Healthy:      c.healthy(now),
+			ConnectedAt:  c.connectedAt,
+			LastSeen:     c.lastSeen,
+			InFlight:     c.inFlight,
+			Completed:    c.completed,
+			Failed:       c.failed,
+			LastError:    c.lastError,
+			Running:      append([]RunningCommand{}, running[c]...),
+		})
+	}
+	sort.Slice(statuses, func(i, j int) bool { return statuses[i].Name < statuses[j].Name })
+	return statuses
+}
+
+// PendingRequests returns the number of commands waiting for a response
+func (m *ConnectorManager) PendingRequests() int {
+	m.mutex.RLock()
+	defer m.mutex.RUnlock()
+	return len(m.pendingRequests)
+}
+
+// Sites returns one site per group of connectors with a capability that serve the same
+// sites, so that a command sent to each reaches every office once. "" stands for the
+// connectors that serve any domain.
+func (m *ConnectorManager) Sites(capability string) []string {
+	m.mutex.RLock()
+	defer m.mutex.RUnlock()
+
+	seen := make(map[string]bool)
+	var sites []string
+	for _, c := range m.connectors {
+		if !c.can(capability) {
+			continue
+		}
+		key := strings.Join(c.info.Sites, ",")
+		if seen[key] {
+			continue
+		}
+		seen[key] = true
+		if len(c.info.Sites) == 0 {
+			sites = append(sites, "")
+		} else {
+			sites = append(sites, c.info.Sites[0])
+		}
+	}
+	sort.Strings(sites)
+	return sites
+}
+
+// SendCommand sends a command to a connector and decodes the result into result, which may
+// be nil. The connector is chosen by the capability the command needs and the domain of its
+// params (see routeDomain); among equal candidates the least busy one is used. When a
+// connector drops or stays silent for timeout before answering, the command is retried on
+// the next one, unless it must not run twice (ErrCommandInterrupted, or the timeout error);
+// when none is connected, it waits a little for one to reconnect. timeout is how long the
+// connector may stay silent: progress and chunks of a streamed result restart it.
+func (m *ConnectorManager) SendCommand(command string, params, result interface{}, timeout time.Duration) error {
+	return m.SendCommandWithProgress(command, params, result, timeout, nil)
+}
+
+// SendCommandWithProgress is SendCommand that calls onProgress with the progress the
+// connector reports
+func (m *ConnectorManager) SendCommandWithProgress(command string, params, result interface{}, timeout time.Duration, onProgress func(protocol.Progress)) error {
+	raw, err := json.Marshal(params)
+	if err != nil {
+		return err
+	}
+	var fields map[string]interface{}
+	json.Unmarshal(raw, &fields)
+	domain := routeDomain(fields)
+
+	deadline := time.Now().Add(connectorReconnectWait)
+	timedOut := make(map[*Connector]bool) // hung connectors are not tried again
+	err = ErrConnectorNotConnected
+	for {
+		connected := m.Connected()
+		candidates := m.route(command, domain)
+		if len(candidates) == 0 && m.IsConnected() && !errors.Is(err, errConnectorDisconnected) {
+			return m.unroutable(command, domain)
+		}
+
+		for _, c := range candidates {
+			if timedOut[c] {
+				continue
+			}
+			var data json.RawMessage
+			data, err = m.send(c, command, raw, timeout, onProgress)
+			if errors.Is(err, errConnectorDisconnected) {
+				continue
+			}
+			if errors.Is(err, errRequestTimeout) && !notRetried[command] {
+				timedOut[c] = true
+				continue
+			}
+			if err != nil || result == nil {
+				return err
+			}
+			if err := json.Unmarshal(data, result); err != nil {
+				return fmt.Errorf("invalid %s result: %w", command, err)
+			}
+			return nil
+		}
+
+		if errors.Is(err, errRequestTimeout) {
+			return err // every candidate is connected but hung
+		}
+
+		// Every candidate dropped, or none is connected: wait for a connector to connect
+		select {
+		case <-connected:
+		case <-time.After(time.Until(deadline)):
+			if errors.Is(err, errConnectorDisconnected) {
+				return err
+			}
+			return ErrConnectorNotConnected
+		}
+	}
+}
+
+// send sends a command to one connector and returns its result
+func (m *ConnectorManager) send(c *Connector, command string, params json.RawMessage, timeout time.Duration, onProgress func(protocol.Progress)) (json.RawMessage, error) {
 	requestID := uuid.New().String()
-	responseChan := make(chan ConnectorResponse, 1)
+	responseChan := make(chan connectorReply, 1)
+	p := &pendingRequest{
+		connector:  c,
+		command:    command,
+		startedAt:  time.Now(),
+		response:   responseChan,
+		activity:   make(chan struct{}, 1),
+		onProgress: onProgress,
+	}
 
 	m.mutex.Lock()
-	m.pendingRequests[requestID] = responseChan
+	if m.connectors[c.info.Name] != c {
+		m.mutex.Unlock()
+		return nil, errConnectorDisconnected
+	}
+	m.pendingRequests[requestID] = p
+	c.inFlight++
+	c.lastUsed = p.startedAt
 	m.mutex.Unlock()
 
+	var resp connectorReply
 	defer func() {
 		m.mutex.Lock()
 		delete(m.pendingRequests, requestID)
+		c.inFlight--
+		c.record(resp.err)
 		m.mutex.Unlock()
 	}()
 
-	cmd := ConnectorCommand{
+	err := c.write(protocol.Command{
+		Type:      protocol.TypeCommand,
 		Command:   command,
 		RequestID: requestID,
 		Params:    params,
-	}
-
-	data, err := json.Marshal(cmd)
+	})
 	if err != nil {
-		return nil, err
+		resp.err = errConnectorDisconnected
+		return nil, resp.err
 	}
 
-	if err := conn.WriteMessage(websocket.TextMessage, data); err != nil {
-		return nil, err
+	timer := time.NewTimer(timeout)
+	defer timer.Stop()
+wait:
+	for {
+		select {
+		case resp = <-responseChan:
+			break wait
+		case <-p.activity:
+			if !timer.Stop() {
+				<-timer.C
+			}
+			timer.Reset(timeout)
+		case <-timer.C:
+			resp.err = errRequestTimeout
+			return nil, resp.err
+		}
+	}
+	if errors.Is(resp.err, errConnectorDisconnected) && notRetried[command] {
+		resp.err = ErrCommandInterrupted
+	}
+	if resp.err != nil {
+		return nil, resp.err
 	}
 
-	select {
-	case resp := <-responseChan:
-		if !resp.Success {
-			errMsg := resp.Error
-			if errMsg == "" {
-				if resp.Result != nil {
-					if e, ok := resp.Result["error"].(string); ok {
-						errMsg = e
-					}
-				}
-			}
-			if errMsg == "" {
-				errMsg = "Unknown error"
+	if !resp.Success {
+		errMsg := resp.Error
+		if errMsg == "" {
+			var result struct {
+				Error string `json:"error"`
 			}
-			return nil, errors.New(errMsg)
+			json.Unmarshal(resp.Result, &result)
+			errMsg = result.Error
 		}
-		return resp.Result, nil
-	case <-time.After(timeout):
-		return nil, errors.New("request timeout")
+		if errMsg == "" {
+			errMsg = "Unknown error"
+		}
+		return nil, errors.New(errMsg)
 	}
+	return resp.Result, nil
 }
 
 // HandleResponse processes a response from the connector
 func (m *ConnectorManager) HandleResponse(data []byte) {
-	var resp ConnectorResponse
+	var resp protocol.Response
 	if err := json.Unmarshal(data, &resp); err != nil {
 		return
 	}
 
-	m.mutex.RLock()
-	ch, exists := m.pendingRequests[resp.RequestID]
-	m.mutex.RUnlock()
+	m.mutex.Lock()
+	p, exists := m.pendingRequests[resp.RequestID]
+	delete(m.pendingRequests, resp.RequestID)
+	m.mutex.Unlock()
+	if !exists {
+		return
+	}
 
-	if exists {
-		ch <- resp
+	reply := connectorReply{Response: resp}
+	if resp.Chunks > 0 {
+		if p.chunks != resp.Chunks {
+			reply.err = fmt.Errorf("streamed result incomplete: %d of %d chunks", p.chunks, resp.Chunks)
+		}
+		reply.Result = p.result
 	}
+	p.response <- reply
 }
 
-// HandleMessage processes incoming messages from connector
-func (m *ConnectorManager) HandleMessage(messageType int, data []byte) {
-	if messageType != websocket.TextMessage {
-		return
+// handleStream records the progress or a result chunk of a running command
+func (m *ConnectorManager) handleStream(messageType string, data []byte) {
+	var requestID string
+	var progress protocol.Progress
+	var chunk protocol.Chunk
+	if messageType == protocol.TypeProgress {
+		if json.Unmarshal(data, &progress) != nil {
+			return
+		}
+		requestID = progress.RequestID
+	} else {
+		if json.Unmarshal(data, &chunk) != nil {
+			return
+		}
+		requestID = chunk.RequestID
 	}
 
-	var msg map[string]interface{}
-	if err := json.Unmarshal(data, &msg); err != nil {
+	m.mutex.Lock()
+	p, exists := m.pendingRequests[requestID]
+	if !exists {
+		m.mutex.Unlock()
 		return
 	}
+	var onProgress func(protocol.Progress)
+	if messageType == protocol.TypeProgress {
+		p.progress = &progress
+		onProgress = p.onProgress
+	} else if chunk.Seq == p.chunks {
+		p.result = append(p.result, chunk.Data...)
+		p.chunks++
+	} else {
+		// A lost chunk cannot be recovered; fail the command rather than decode a broken result
+		delete(m.pendingRequests, requestID)
+		m.mutex.Unlock()
+		p.response <- connectorReply{err: fmt.Errorf("streamed result: chunk %d received, %d expected", chunk.Seq, p.chunks)}
+		return
+	}
+	m.mutex.Unlock()
+
+	select {
+	case p.activity <- struct{}{}:
+	default:
+	}
+	if onProgress != nil {
+		onProgress(progress)
+	}
+}
 
-	msgType, _ := msg["type"].(string)
+// HandleMessage processes incoming messages from a connector. An error means the
+// connection must be closed.
+func (m *ConnectorManager) HandleMessage(c *Connector, messageType int, data []byte) error {
+	now := time.Now()
+	m.mutex.Lock()
+	c.lastSeen = now
+	expiresAt := c.auth.ExpiresAt
+	m.mutex.Unlock()
 
-	switch msgType {
-	case "heartbeat":
+	if expiresAt != nil && now.After(*expiresAt) {
+		return ErrCredentialExpired
+	}
+
+	if messageType != websocket.TextMessage {
+		return nil
+	}
+
+	var msg protocol.Message
+	if err := json.Unmarshal(data, &msg); err != nil {
+		return nil
+	}
+
+	switch msg.Type {
+	case protocol.TypeHello:
+		return m.handshake(c, data)
+	case protocol.TypeHeartbeat:
 		// Just acknowledge, no action needed
-	case "response":
+	case protocol.TypeResponse:
 		m.HandleResponse(data)
+	case protocol.TypeProgress, protocol.TypeChunk:
+		m.handleStream(msg.Type, data)
+	}
+	return nil
+}
+
+// handshake negotiates the protocol version with a connector that said hello and answers
+// with a welcome, or with an error when they have no version in common
+func (m *ConnectorManager) handshake(c *Connector, data []byte) error {
+	var hello protocol.Hello
+	if err := json.Unmarshal(data, &hello); err != nil {
+		return err
+	}
+
+	version, err := protocol.Negotiate(hello.MinVersion, hello.Version)
+	if err != nil {
+		c.write(protocol.Error{Type: protocol.TypeError, Error: err.Error(), Version: protocol.Version})
+		return err
+	}
+
+	m.mutex.Lock()
+	c.version = version
+	c.commands = hello.Commands
+	c.software = hello.Software
+	m.mutex.Unlock()
+
+	return c.write(protocol.Welcome{Type: protocol.TypeWelcome, Version: version, Commands: protocol.Commands})
+}
+
+// write sends a message to the connector
+func (c *Connector) write(msg interface{}) error {
+	data, err := json.Marshal(msg)
+	if err != nil {
+		return err
+	}
+	c.writeMu.Lock()
+	defer c.writeMu.Unlock()
+	return c.conn.WriteMessage(websocket.TextMessage, data)
+}
+
+// supports reports whether the connector handles a command. The caller holds the lock.
+func (c *Connector) supports(command string) bool {
+	for _, name := range c.commands {
+		if name == command {
+			return true
+		}
+	}
+	return false
+}
+
+func (c *Connector) can(capability string) bool {
+	if capability == "" {
+		return true
+	}
+	for _, cp := range c.info.Capabilities {
+		if cp == capability {
+			return true
+		}
+	}
+	return false
+}
+
+func (c *Connector) healthy(now time.Time) bool {
+	return now.Sub(c.lastSeen) < connectorStaleAfter && c.failures < connectorMaxFailures
+}
+
+// record counts the outcome of a command. The caller holds the manager lock.
+func (c *Connector) record(err error) {
+	if err == nil {
+		c.completed++
+		c.failures = 0
+		return
+	}
+	c.failed++
+	c.failures++
+	c.lastError = err.Error()
+}
+
+func splitList(value string) []string {
+	var items []string
+	for _, item := range strings.Split(value, ",") {
+		if item = strings.ToLower(strings.TrimSpace(item)); item != "" {
+			items = append(items, item)
+		}
 	}
+	return items
 }
@@ -0,0 +1,133 @@
+package services
+
+import (
+	"time"
+
+	"github.com/ekf/one-on-one-backend/pkg/protocol"
+)
+
+// Typed connector commands
+
+// SyncUsers reads the users of the AD site params.Domain ("" - any)
+func (m *ConnectorManager) SyncUsers(params protocol.SyncUsersParams) (*protocol.SyncUsersResult, error) {
+	var result protocol.SyncUsersResult
+	if err := m.SendCommand(protocol.CommandSyncUsers, params, &result, 300*time.Second); err != nil {
+		return nil, err
+	}
+	return &result, nil
+}
+
+// Authenticate checks AD credentials. Wrong credentials are not an error: the result is
+// not Authenticated.
+func (m *ConnectorManager) Authenticate(username, password string) (*protocol.AuthenticateResult, error) {
+	var result protocol.AuthenticateResult
+	err := m.SendCommand(protocol.CommandAuthenticate, protocol.AuthenticateParams{
+		Username: username,
+		Password: password,
+	}, &result, 30*time.Second)
+	if err != nil {
+		return nil, err
+	}
+	return &result, nil
+}
+
+// GetSubordinates returns the AD users reporting to a manager
+func (m *ConnectorManager) GetSubordinates(managerDN string) ([]protocol.User, error) {
+	var result protocol.SubordinatesResult
+	err := m.SendCommand(protocol.CommandGetSubordinates, protocol.SubordinatesParams{ManagerDN: managerDN}, &result, 30*time.Second)
+	return result.Subordinates, err
+}
+
+// SyncCalendar returns the Exchange calendar events of a mailbox
+func (m *ConnectorManager) SyncCalendar(params protocol.CalendarParams) ([]protocol.CalendarEvent, error) {
+	var result protocol.CalendarResult
+	err := m.SendCommand(protocol.CommandSyncCalendar, params, &result, 120*time.Second)
+	return result.Events, err
+}
+
+// Exchange mail and calendar items. Commands that change the mailbox are not sent again
+// when the connector drops before answering, see notRetried.
+
+// notRetried are the commands that are not safe to run twice: sending mail or a meeting
+// response, and creating a meeting, which sends invitations
+var notRetried = map[string]bool{
+	protocol.CommandSendEmail:          true,
+	protocol.CommandRespondToMeeting:   true,
+	protocol.CommandCreateCalendarItem: true,
+	protocol.CommandUpdateCalendarItem: true,
+	protocol.CommandDeleteCalendarItem: true,
+}
+
+// GetMailFolders returns the folders under the mailbox root
+func (m *ConnectorManager) GetMailFolders(mailbox protocol.Mailbox) ([]protocol.MailFolder, error) {
+	var result protocol.MailFoldersResult
+	err := m.SendCommand(protocol.CommandGetMailFolders, mailbox, &result, 60*time.Second)
+	return result.Folders, err
+}
+
+// GetEmails returns the latest emails of a folder
+func (m *ConnectorManager) GetEmails(params protocol.EmailsParams) ([]protocol.EmailMessage, error) {
+	var result protocol.EmailsResult
+	err := m.SendCommand(protocol.CommandGetEmails, params, &result, 60*time.Second)
+	return result.Emails, err
+}
+
+// GetEmailBody returns the HTML body of an email
+func (m *ConnectorManager) GetEmailBody(params protocol.ItemParams) (string, error) {
+	var result protocol.EmailBodyResult
+	err := m.SendCommand(protocol.CommandGetEmailBody, params, &result, 60*time.Second)
+	return result.Body, err
+}
+
+// SendEmail sends an email, with attachments if any
+func (m *ConnectorManager) SendEmail(params protocol.SendEmailParams) error {
+	return m.SendCommand(protocol.CommandSendEmail, params, nil, 120*time.Second)
+}
+
+// MarkEmailRead marks an email as read
+func (m *ConnectorManager) MarkEmailRead(params protocol.ItemParams) error {
+	return m.SendCommand(protocol.CommandMarkEmailRead, params, nil, 30*time.Second)
+}
+
+// DeleteEmail moves an email to Deleted Items
+func (m *ConnectorManager) DeleteEmail(params protocol.ItemParams) error {
+	return m.SendCommand(protocol.CommandDeleteEmail, params, nil, 30*time.Second)
+}
+
+// GetAttachments lists the attachments of an email
+func (m *ConnectorManager) GetAttachments(params protocol.ItemParams) ([]protocol.Attachment, error) {
+	var result protocol.AttachmentsResult
+	err := m.SendCommand(protocol.CommandGetAttachments, params, &result, 60*time.Second)
+	return result.Attachments, err
+}
+
+// GetAttachmentContent returns an attachment with its base64 content
+func (m *ConnectorManager) GetAttachmentContent(params protocol.AttachmentContentParams) (*protocol.AttachmentContentResult, error) {
+	var result protocol.AttachmentContentResult
+	if err := m.SendCommand(protocol.CommandGetAttachmentContent, params, &result, 120*time.Second); err != nil {
+		return nil, err
+	}
+	return &result, nil
+}
+
+// RespondToMeeting accepts, declines or tentatively accepts a meeting invitation
+func (m *ConnectorManager) RespondToMeeting(params protocol.MeetingResponseParams) error {
+	return m.SendCommand(protocol.CommandRespondToMeeting, params, nil, 60*time.Second)
+}
+
+// CreateCalendarItem creates a meeting, sends the invitations and returns the item ID
+func (m *ConnectorManager) CreateCalendarItem(params protocol.CreateCalendarItemParams) (string, error) {
+	var result protocol.CreateCalendarItemResult
+	err := m.SendCommand(protocol.CommandCreateCalendarItem, params, &result, 60*time.Second)
+	return result.ItemID, err
+}
+
+// UpdateCalendarItem changes a meeting and notifies the attendees
+func (m *ConnectorManager) UpdateCalendarItem(params protocol.UpdateCalendarItemParams) error {
+	return m.SendCommand(protocol.CommandUpdateCalendarItem, params, nil, 60*time.Second)
+}
+
+// DeleteCalendarItem deletes a meeting, optionally sending cancellations
+func (m *ConnectorManager) DeleteCalendarItem(params protocol.DeleteCalendarItemParams) error {
+	return m.SendCommand(protocol.CommandDeleteCalendarItem, params, nil, 60*time.Second)
+}
@@ -0,0 +1,394 @@
+package services
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/hex"
+	"encoding/json"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/ekf/one-on-one-backend/internal/database"
+	"github.com/ekf/one-on-one-backend/internal/utils"
+	"github.com/ekf/one-on-one-backend/pkg/protocol"
+)
+
+// Connector credential kinds
+const (
+	CredentialToken       = "token"       // a key the connector signs short-lived tokens with
+	CredentialCertificate = "certificate" // a pinned client certificate presented over mTLS
+)
+
+// How a connector connection was authenticated
+const (
+	ConnectorAuthCertificate = "certificate"
+	ConnectorAuthToken       = "token"
+	ConnectorAuthSharedKey   = "shared_key" // CONNECTOR_API_KEY, deprecated
+	ConnectorAuthNone        = "none"       // nothing is configured yet, CONNECTOR_ALLOW_UNAUTHENTICATED
+)
+
+var (
+	ErrCredentialNotFound = errors.New("connector credential not found")
+	ErrCredentialRevoked  = errors.New("connector credential revoked")
+	ErrCredentialExpired  = errors.New("connector credential expired")
+	ErrInvalidCredential  = errors.New("invalid connector credential")
+)
+
+// ConnectorCredential is a credential a connector authenticates with. The signing key of
+// a token credential is only returned when it is issued.
+type ConnectorCredential struct {
+	ID              string     `json:"id"`
+	ConnectorName   string     `json:"connector_name"`
+	Kind            string     `json:"kind"`
+	Description     *string    `json:"description,omitempty"`
+	CertFingerprint *string    `json:"cert_fingerprint,omitempty"`
+	CertSubject     *string    `json:"cert_subject,omitempty"`
+	Capabilities    *string    `json:"capabilities,omitempty"` // comma-separated; empty - all
+	Sites           *string    `json:"sites,omitempty"`        // comma-separated; empty - any
+	ExpiresAt       *time.Time `json:"expires_at,omitempty"`
+	RevokedAt       *time.Time `json:"revoked_at,omitempty"`
+	LastUsedAt      *time.Time `json:"last_used_at,omitempty"`
+	RotatedFrom     *string    `json:"rotated_from,omitempty"`
+	CreatedBy       *string    `json:"created_by,omitempty"`
+	CreatedAt       *time.Time `json:"created_at,omitempty"`
+}
+
+// check returns why the credential cannot be used, or nil
+func (c *ConnectorCredential) check(now time.Time) error {
+	switch {
+	case c.RevokedAt != nil:
+		return ErrCredentialRevoked
+	case c.ExpiresAt != nil && now.After(*c.ExpiresAt):
+		return ErrCredentialExpired
+	}
+	return nil
+}
+
+// credentialRow is a connector_credentials row
+type credentialRow struct {
+	ConnectorCredential
+	EncryptedSecret *string `json:"encrypted_secret"`
+}
+
+// ConnectorCredentialInput describes a credential to issue
+type ConnectorCredentialInput struct {
+	ConnectorName string     `json:"connector_name"`
+	Kind          string     `json:"kind"`
+	Description   string     `json:"description"`
+	Certificate   string     `json:"certificate"`  // PEM client certificate, for certificate credentials
+	Capabilities  []string   `json:"capabilities"` // what the connector may do (ad, ews); empty - all
+	Sites         []string   `json:"sites"`        // AD/mail domains it serves; empty - any
+	ExpiresAt     *time.Time `json:"expires_at"`
+}
+
+// ConnectorAuth is how a connection was authenticated
+type ConnectorAuth struct {
+	Method       string     `json:"method"`
+	CredentialID string     `json:"credential_id,omitempty"`
+	Name         string     `json:"-"`                    // the name the credential is bound to
+	Capabilities string     `json:"-"`                    // granted by the credential, comma-separated
+	Sites        string     `json:"-"`                    // served under the credential, comma-separated
+	ExpiresAt    *time.Time `json:"expires_at,omitempty"` // the connection is closed after it
+}
+
+// ConnectorCredentials issues, revokes and checks connector credentials
+type ConnectorCredentials struct {
+	db        database.DBClient
+	secretKey string // encrypts the signing keys at rest
+}
+
+// NewConnectorCredentials creates the credential store. Signing keys are encrypted with
+// secretKey.
+func NewConnectorCredentials(db database.DBClient, secretKey string) *ConnectorCredentials {
+	return &ConnectorCredentials{db: db, secretKey: secretKey}
+}
+
+// List returns all credentials, newest first, including revoked ones
+func (s *ConnectorCredentials) List() ([]ConnectorCredential, error) {
+	var rows []credentialRow
+	if err := s.db.From("connector_credentials").Select("*").Order("created_at", true).Execute(&rows); err != nil {
+		return nil, err
+	}
+	credentials := make([]ConnectorCredential, len(rows))
+	for i, row := range rows {
+		credentials[i] = row.ConnectorCredential
+	}
+	return credentials, nil
+}
+
+// Get returns a credential
+func (s *ConnectorCredentials) Get(id string) (*ConnectorCredential, error) {
+	row, err := s.row(id)
+	if err != nil {
+		return nil, err
+	}
+	return &row.ConnectorCredential, nil
+}
+
+// HasActive reports whether a connector, or any connector when name is empty, has a
+// credential that can be used. Such connectors may no longer connect without one.
+func (s *ConnectorCredentials) HasActive(name string) (bool, error) {
+	query := s.db.From("connector_credentials").Select("id, expires_at, revoked_at").IsNull("revoked_at")
+	if name != "" {
+		query = query.Eq("connector_name", name)
+	}
+	var rows []ConnectorCredential
+	if err := query.Execute(&rows); err != nil {
+		return false, err
+	}
+	now := time.Now()
+	for _, c := range rows {
+		if c.check(now) == nil {
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+// Issue creates a credential. For a token credential it also returns the credential the
+// connector is configured with, "<key id>.<secret>", which is not returned again.
+func (s *ConnectorCredentials) Issue(input ConnectorCredentialInput, userID string) (*ConnectorCredential, string, error) {
+	return s.issue(input, userID, "")
+}
+
+// Rotate issues a new credential for the connector of credential id, of the same kind;
+// a certificate credential needs the new certificate in input. Description, capabilities
+// and sites are kept unless input changes them. The old credential stays valid for grace
+// so the connector can switch without downtime, then expires; without grace it is revoked
+// at once. Both changes are made in one transaction.
+func (s *ConnectorCredentials) Rotate(id string, input ConnectorCredentialInput, grace time.Duration, userID string) (*ConnectorCredential, string, error) {
+	var credential *ConnectorCredential
+	var secret string
+	err := s.db.WithTx(context.Background(), func(tx database.DBClient) error {
+		store := &ConnectorCredentials{db: tx, secretKey: s.secretKey}
+		old, err := store.row(id)
+		if err != nil {
+			return err
+		}
+		if err := old.check(time.Now()); err != nil {
+			return err
+		}
+
+		input.ConnectorName = old.ConnectorName
+		input.Kind = old.Kind
+		if input.Description == "" && old.Description != nil {
+			input.Description = *old.Description
+		}
+		if input.Capabilities == nil && old.Capabilities != nil {
+			input.Capabilities = splitList(*old.Capabilities)
+		}
+		if input.Sites == nil && old.Sites != nil {
+			input.Sites = splitList(*old.Sites)
+		}
+		if credential, secret, err = store.issue(input, userID, id); err != nil {
+			return err
+		}
+
+		if grace <= 0 {
+			_, err = tx.Update("connector_credentials", "id", id, map[string]interface{}{"revoked_at": time.Now()})
+		} else if expires := time.Now().Add(grace); old.ExpiresAt == nil || expires.Before(*old.ExpiresAt) {
+			_, err = tx.Update("connector_credentials", "id", id, map[string]interface{}{"expires_at": expires})
+		}
+		return err
+	})
+	if err != nil {
+		return nil, "", err
+	}
+	return credential, secret, nil
+}
+
+// Revoke makes a credential unusable at once
+func (s *ConnectorCredentials) Revoke(id string) (*ConnectorCredential, error) {
+	row, err := s.row(id)
+	if err != nil {
+		return nil, err
+	}
+	if row.RevokedAt == nil {
+		if _, err := s.db.Update("connector_credentials", "id", id, map[string]interface{}{"revoked_at": time.Now()}); err != nil {
+			return nil, err
+		}
+	}
+	return s.Get(id)
+}
+
+// AuthenticateToken checks a token signed by a connector
+func (s *ConnectorCredentials) AuthenticateToken(token string) (*ConnectorAuth, error) {
+	var credential *ConnectorCredential
+	_, err := protocol.ParseToken(token, func(keyID string) (string, error) {
+		row, err := s.active(keyID)
+		if err != nil {
+			return "", err
+		}
+		if row.Kind != CredentialToken || row.EncryptedSecret == nil {
+			return "", ErrCredentialNotFound
+		}
+		credential = &row.ConnectorCredential
+		return utils.DecryptPassword(*row.EncryptedSecret, s.secretKey)
+	}, time.Now())
+	if err != nil {
+		return nil, err
+	}
+	return s.used(credential, ConnectorAuthToken), nil
+}
+
+// AuthenticateCertificate checks a client certificate already verified by the TLS
+// listener against the pinned certificates
+func (s *ConnectorCredentials) AuthenticateCertificate(cert *x509.Certificate) (*ConnectorAuth, error) {
+	var rows []credentialRow
+	err := s.db.From("connector_credentials").Select("*").
+		Eq("cert_fingerprint", certFingerprint(cert)).IsNull("revoked_at").Limit(1).Execute(&rows)
+	if err != nil {
+		return nil, err
+	}
+	if len(rows) == 0 {
+		return nil, ErrCredentialNotFound
+	}
+	if err := rows[0].check(time.Now()); err != nil {
+		return nil, err
+	}
+	return s.used(&rows[0].ConnectorCredential, ConnectorAuthCertificate), nil
+}
+
+// used records the use of a credential and returns the authentication it grants
+func (s *ConnectorCredentials) used(c *ConnectorCredential, method string) *ConnectorAuth {
+	s.db.Update("connector_credentials", "id", c.ID, map[string]interface{}{"last_used_at": time.Now()})
+	auth := &ConnectorAuth{Method: method, CredentialID: c.ID, Name: c.ConnectorName, ExpiresAt: c.ExpiresAt}
+	if c.Capabilities != nil {
+		auth.Capabilities = *c.Capabilities
+	}
+	if c.Sites != nil {
+		auth.Sites = *c.Sites
+	}
+	return auth
+}
+
+func (s *ConnectorCredentials) issue(input ConnectorCredentialInput, userID, rotatedFrom string) (*ConnectorCredential, string, error) {
+	input.ConnectorName = strings.TrimSpace(input.ConnectorName)
+	if input.ConnectorName == "" {
+		return nil, "", fmt.Errorf("%w: connector_name is required", ErrInvalidCredential)
+	}
+	if input.ExpiresAt != nil && input.ExpiresAt.Before(time.Now()) {
+		return nil, "", fmt.Errorf("%w: expires_at is in the past", ErrInvalidCredential)
+	}
+
+	data := map[string]interface{}{
+		"connector_name": input.ConnectorName,
+		"kind":           input.Kind,
+	}
+	if input.Description != "" {
+		data["description"] = input.Description
+	}
+	if rotatedFrom != "" {
+		data["rotated_from"] = rotatedFrom
+	}
+	if userID != "" {
+		data["created_by"] = userID
+	}
+	capabilities := splitList(strings.Join(input.Capabilities, ","))
+	for _, capability := range capabilities {
+		if capability != CapabilityAD && capability != CapabilityEWS {
+			return nil, "", fmt.Errorf("%w: unknown capability %q, use %q or %q", ErrInvalidCredential, capability, CapabilityAD, CapabilityEWS)
+		}
+	}
+	if len(capabilities) > 0 {
+		data["capabilities"] = strings.Join(capabilities, ",")
+	}
+	if sites := splitList(strings.Join(input.Sites, ",")); len(sites) > 0 {
+		data["sites"] = strings.Join(sites, ",")
+	}
+	expiresAt := input.ExpiresAt
+
+	var secret string
+	switch input.Kind {
+	case CredentialToken:
+		key := make([]byte, 32)
+		if _, err := rand.Read(key); err != nil {
+			return nil, "", err
+		}
+		secret = hex.EncodeToString(key)
+		encrypted, err := utils.EncryptPassword(secret, s.secretKey)
+		if err != nil {
+			return nil, "", err
+		}
+		data["encrypted_secret"] = encrypted
+	case CredentialCertificate:
+		cert, err := parseCertificate(input.Certificate)
+		if err != nil {
+			return nil, "", err
+		}
+		if time.Now().After(cert.NotAfter) {
+			return nil, "", fmt.Errorf("%w: the certificate has expired", ErrInvalidCredential)
+		}
+		data["cert_fingerprint"] = certFingerprint(cert)
+		data["cert_subject"] = cert.Subject.String()
+		if expiresAt == nil || cert.NotAfter.Before(*expiresAt) {
+			expiresAt = &cert.NotAfter
+		}
+	default:
+		return nil, "", fmt.Errorf("%w: kind must be %q or %q", ErrInvalidCredential, CredentialToken, CredentialCertificate)
+	}
+	if expiresAt != nil {
+		data["expires_at"] = *expiresAt
+	}
+
+	result, err := s.db.Insert("connector_credentials", data)
+	if err != nil {
+		return nil, "", err
+	}
+	var created []credentialRow
+	if err := json.Unmarshal(result, &created); err != nil || len(created) == 0 {
+		return nil, "", errors.New("failed to read created credential")
+	}
+	credential := created[0].ConnectorCredential
+	if secret != "" {
+		secret = protocol.FormatCredential(credential.ID, secret)
+	}
+	return &credential, secret, nil
+}
+
+func (s *ConnectorCredentials) row(id string) (*credentialRow, error) {
+	var rows []credentialRow
+	if err := s.db.From("connector_credentials").Select("*").Eq("id", id).Limit(1).Execute(&rows); err != nil {
+		return nil, err
+	}
+	if len(rows) == 0 {
+		return nil, ErrCredentialNotFound
+	}
+	return &rows[0], nil
+}
+
+// active returns a credential that can be used
+func (s *ConnectorCredentials) active(id string) (*credentialRow, error) {
+	row, err := s.row(id)
+	if err != nil {
+		return nil, err
+	}
+	if err := row.check(time.Now()); err != nil {
+		return nil, err
+	}
+	return row, nil
+}
+
+// parseCertificate reads a PEM client certificate
+func parseCertificate(data string) (*x509.Certificate, error) {
+	block, _ := pem.Decode([]byte(data))
+	if block == nil || block.Type != "CERTIFICATE" {
+		return nil, fmt.Errorf("%w: certificate must be a PEM certificate", ErrInvalidCredential)
+	}
+	cert, err := x509.ParseCertificate(block.Bytes)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInvalidCredential, err)
+	}
+	return cert, nil
+}
+
+// certFingerprint is the SHA-256 of a certificate, hex
+func certFingerprint(cert *x509.Certificate) string {
+	sum := sha256.Sum256(cert.Raw)
+	return hex.EncodeToString(sum[:])
+}
@@ -0,0 +1,426 @@
+package services
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"sync"
+	"time"
+
+	"github.com/ekf/one-on-one-backend/internal/database"
+	"github.com/ekf/one-on-one-backend/internal/utils"
+	"github.com/ekf/one-on-one-backend/pkg/protocol"
+)
+
+// Queued command statuses
+const (
+	QueuedCommandQueued    = "queued"
+	QueuedCommandRunning   = "running"
+	QueuedCommandDone      = "done"
+	QueuedCommandFailed    = "failed"
+	QueuedCommandExpired   = "expired"
+	QueuedCommandCancelled = "cancelled"
+)
+
+const (
+	queuePollInterval = 30 * time.Second
+	queueLock         = "connector:queue"
+	queueBatchSize    = 50
+	// queuedCommandTimeout is how long a queued command may run without the connector
+	// reporting progress
+	queuedCommandTimeout = 5 * time.Minute
+	// queueStaleAfter is how long a running command may go without an update before it is
+	// considered lost with the replica that ran it and queued again
+	queueStaleAfter = 30 * time.Minute
+	// queueMaxAttempts is the number of runs lost to disconnects before a command fails
+	queueMaxAttempts = 3
+	// queueProgressInterval limits how often progress is written to the row
+	queueProgressInterval = 10 * time.Second
+)
+
+var (
+	ErrQueuedCommandNotFound = errors.New("queued command not found")
+	ErrQueuedCommandClosed   = errors.New("queued command already finished")
+)
+
+// QueuedCommandHandler processes the result of a queued command with the options it was
+// queued with and returns the summary kept on the row
+type QueuedCommandHandler func(result json.RawMessage, options json.RawMessage) (interface{}, error)
+
+// QueuedCommand is a command waiting for, or run on, a connector
+type QueuedCommand struct {
+	ID          string             `json:"id"`
+	Command     string             `json:"command"`
+	Status      string             `json:"status"`
+	Attempts    int                `json:"attempts"`
+	Progress    *protocol.Progress `json:"progress,omitempty"`
+	Result      json.RawMessage    `json:"result,omitempty"`
+	Error       *string            `json:"error,omitempty"`
+	ExpiresAt   time.Time          `json:"expires_at"`
+	CreatedBy   *string            `json:"created_by,omitempty"`
+	CreatedAt   *time.Time         `json:"created_at,omitempty"`
+	StartedAt   *time.Time         `json:"started_at,omitempty"`
+	CompletedAt *time.Time         `json:"completed_at,omitempty"`
+}
+
+// queuedCommandRow is a connector_commands row; JSONB columns come back as strings
+type queuedCommandRow struct {
+	ID          string     `json:"id"`
+	Command     string     `json:"command"`
+	Params      string     `json:"params"`
+	Options     string     `json:"options"`
+	Status      string     `json:"status"`
+	Attempts    int        `json:"attempts"`
+	Progress    *string    `json:"progress"`
+	Result      *string    `json:"result"`
+	Error       *string    `json:"error"`
+	ExpiresAt   time.Time  `json:"expires_at"`
+	CreatedBy   *string    `json:"created_by"`
+	CreatedAt   *time.Time `json:"created_at"`
+	StartedAt   *time.Time `json:"started_at"`
+	UpdatedAt   *time.Time `json:"updated_at"`
+	CompletedAt *time.Time `json:"completed_at"`
+}
+
+func (r queuedCommandRow) command() QueuedCommand {
+	q := QueuedCommand{
+		ID:          r.ID,
+		Command:     r.Command,
+		Status:      r.Status,
+		Attempts:    r.Attempts,
+		Error:       r.Error,
+		ExpiresAt:   r.ExpiresAt,
+		CreatedBy:   r.CreatedBy,
+		CreatedAt:   r.CreatedAt,
+		StartedAt:   r.StartedAt,
+		CompletedAt: r.CompletedAt,
+	}
+	if r.Progress != nil {
+		json.Unmarshal([]byte(*r.Progress), &q.Progress)
+	}
+	if r.Result != nil {
+		q.Result = json.RawMessage(*r.Result)
+	}
+	return q
+}
+
+// ConnectorQueue keeps commands in connector_commands while no connector can run them and
+// sends them when one connects, until they expire. Replicas coordinate through an
+// advisory lock when claiming commands; a command runs on the replica the connector is
+// connected to.
+//
+// Delivery is at least once: a run lost with its connector or replica is queued again (up
+// to queueMaxAttempts), even if the connector had already carried the command out. Queued
+// commands must therefore be idempotent, and so must their result handlers.
+type ConnectorQueue struct {
+	db       database.DBClient
+	locker   database.AdvisoryLocker
+	manager  *ConnectorManager
+	handlers map[string]QueuedCommandHandler
+
+	mu         sync.Mutex
+	started    bool
+	dispatchMu sync.Mutex // one dispatch pass at a time in this replica
+}
+
+// NewConnectorQueue creates the queue of the commands sent through manager
+func NewConnectorQueue(db database.DBClient, manager *ConnectorManager) *ConnectorQueue {
+	q := &ConnectorQueue{db: db, manager: manager, handlers: make(map[string]QueuedCommandHandler)}
+	if locker, ok := db.(database.AdvisoryLocker); ok {
+		q.locker = locker
+	}
+	return q
+}
+
+// Handle sets the handler of the results of a command. Without one the result itself is
+// kept on the row, so only commands with small results should go without.
+func (q *ConnectorQueue) Handle(command string, handler QueuedCommandHandler) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	q.handlers[command] = handler
+}
+
+// Enqueue queues a command for ttl. An identical command still waiting is returned instead
+// of queueing another, so periodic jobs do not pile up while the connector is offline.
+// The command may run more than once, see ConnectorQueue.
+func (q *ConnectorQueue) Enqueue(command string, params, options interface{}, ttl time.Duration, userID string) (*QueuedCommand, error) {
+	rawParams, err := json.Marshal(params)
+	if err != nil {
+		return nil, err
+	}
+	rawOptions, err := json.Marshal(options)
+	if err != nil {
+		return nil, err
+	}
+
+	var waiting []queuedCommandRow
+	err = q.db.From("connector_commands").Select("*").
+		Eq("command", command).Eq("params", string(rawParams)).Eq("options", string(rawOptions)).
+		Eq("status", QueuedCommandQueued).Limit(1).Execute(&waiting)
+	if err != nil {
+		return nil, err
+	}
+	if len(waiting) > 0 && waiting[0].ExpiresAt.After(time.Now()) {
+		cmd := waiting[0].command()
+		return &cmd, nil
+	}
+
+	data := map[string]interface{}{
+		"command":    command,
+		"params":     string(rawParams),
+		"options":    string(rawOptions),
+		"status":     QueuedCommandQueued,
+		"expires_at": time.Now().Add(ttl),
+	}
+	if userID != "" {
+		data["created_by"] = userID
+	}
+	result, err := q.db.Insert("connector_commands", data)
+	if err != nil {
+		return nil, err
+	}
+	var created []queuedCommandRow
+	if err := json.Unmarshal(result, &created); err != nil || len(created) == 0 {
+		return nil, errors.New("failed to read queued command")
+	}
+	cmd := created[0].command()
+
+	go q.dispatch() // a connector may be connected already
+	return &cmd, nil
+}
+
+// Get returns a queued command
+func (q *ConnectorQueue) Get(id string) (*QueuedCommand, error) {
+	row, err := q.row(id)
+	if err != nil {
+		return nil, err
+	}
+	cmd := row.command()
+	return &cmd, nil
+}
+
+// List returns the latest commands, newest first, optionally with a status
+func (q *ConnectorQueue) List(status string, limit int) ([]QueuedCommand, error) {
+	query := q.db.From("connector_commands").Select("*")
+	if status != "" {
+		query = query.Eq("status", status)
+	}
+	var rows []queuedCommandRow
+	if err := query.Order("created_at", true).Limit(limit).Execute(&rows); err != nil {
+		return nil, err
+	}
+	commands := make([]QueuedCommand, len(rows))
+	for i, row := range rows {
+		commands[i] = row.command()
+	}
+	return commands, nil
+}
+
+// Cancel removes a command that has not been sent yet from the queue
+func (q *ConnectorQueue) Cancel(id string) (*QueuedCommand, error) {
+	row, err := q.row(id)
+	if err != nil {
+		return nil, err
+	}
+	if row.Status != QueuedCommandQueued {
+		return nil, ErrQueuedCommandClosed
+	}
+	if err := q.finish(id, QueuedCommandCancelled, nil, nil); err != nil {
+		return nil, err
+	}
+	return q.Get(id)
+}
+
+// Start begins sending queued commands in the background: when a connector connects and
+// every poll interval
+func (q *ConnectorQueue) Start() {
+	q.mu.Lock()
+	if q.started {
+		q.mu.Unlock()
+		return
+	}
+	q.started = true
+	q.mu.Unlock()
+
+	go func() {
+		ticker := time.NewTicker(queuePollInterval)
+		defer ticker.Stop()
+		for {
+			q.dispatch()
+			select {
+			case <-ticker.C:
+			case <-q.manager.Connected():
+			}
+		}
+	}()
+}
+
+// dispatch expires old commands, requeues lost ones and starts those a connected
+// connector can run
+func (q *ConnectorQueue) dispatch() {
+	q.dispatchMu.Lock()
+	defer q.dispatchMu.Unlock()
+	if q.locker != nil {
+		unlock, acquired, err := q.locker.TryAdvisoryLock(context.Background(), queueLock)
+		if err != nil || !acquired {
+			return // another replica is claiming
+		}
+		defer unlock()
+	}
+
+	var rows []queuedCommandRow
+	err := q.db.From("connector_commands").Select("*").
+		In("status", []string{QueuedCommandQueued, QueuedCommandRunning}).
+		Order("created_at", false).Limit(queueBatchSize).Execute(&rows)
+	if err != nil {
+		utils.GetLogger().Warn("Failed to load queued connector commands", map[string]interface{}{"error": err.Error()})
+		return
+	}
+
+	now := time.Now()
+	for _, row := range rows {
+		switch {
+		case row.Status == QueuedCommandRunning:
+			if row.UpdatedAt != nil && now.Sub(*row.UpdatedAt) > queueStaleAfter {
+				q.requeue(row, errors.New("lost with the replica that ran it"))
+			}
+		case now.After(row.ExpiresAt):
+			err := q.finish(row.ID, QueuedCommandExpired, nil, errors.New("no connector ran the command before it expired"))
+			logQueueUpdateError(row.ID, err)
+		case q.manager.CanRun(row.Command, json.RawMessage(row.Params)):
+			if q.claim(row, now) {
+				row.Attempts++
+				go q.run(row)
+			}
+		}
+	}
+}
+
+// claim marks a queued command as running. It reports false when the command is no longer
+// queued, e.g. it was cancelled after the batch was loaded, or the update failed.
+func (q *ConnectorQueue) claim(row queuedCommandRow, now time.Time) bool {
+	result, err := q.db.From("connector_commands").Eq("id", row.ID).Eq("status", QueuedCommandQueued).
+		Update(map[string]interface{}{
+			"status":     QueuedCommandRunning,
+			"attempts":   row.Attempts + 1,
+			"started_at": now,
+			"updated_at": now,
+		})
+	if err != nil {
+		logQueueUpdateError(row.ID, err)
+		return false
+	}
+	var claimed []struct {
+		ID string `json:"id"`
+	}
+	if err := json.Unmarshal(result, &claimed); err != nil {
+		logQueueUpdateError(row.ID, err)
+		return false
+	}
+	return len(claimed) > 0
+}
+
+// run sends a claimed command and records the outcome
+func (q *ConnectorQueue) run(row queuedCommandRow) {
+	var lastWrite time.Time
+	onProgress := func(p protocol.Progress) {
+		if time.Since(lastWrite) < queueProgressInterval {
+			return
+		}
+		lastWrite = time.Now()
+		progress, _ := json.Marshal(p)
+		_, err := q.db.Update("connector_commands", "id", row.ID, map[string]interface{}{
+			"progress":   string(progress),
+			"updated_at": lastWrite,
+		})
+		logQueueUpdateError(row.ID, err)
+	}
+
+	var result json.RawMessage
+	err := q.manager.SendCommandWithProgress(row.Command, json.RawMessage(row.Params), &result, queuedCommandTimeout, onProgress)
+	if errors.Is(err, ErrConnectorNotConnected) || errors.Is(err, errConnectorDisconnected) {
+		q.requeue(row, err)
+		return
+	}
+	if err != nil {
+		logQueueUpdateError(row.ID, q.finish(row.ID, QueuedCommandFailed, nil, err))
+		return
+	}
+
+	q.mu.Lock()
+	handler := q.handlers[row.Command]
+	q.mu.Unlock()
+
+	var summary interface{} = result
+	if handler != nil {
+		summary, err = handler(result, json.RawMessage(row.Options))
+	}
+	if err != nil {
+		logQueueUpdateError(row.ID, q.finish(row.ID, QueuedCommandFailed, nil, err))
+		return
+	}
+	logQueueUpdateError(row.ID, q.finish(row.ID, QueuedCommandDone, summary, nil))
+}
+
+// requeue puts back a command whose connector dropped, unless it ran out of attempts
+func (q *ConnectorQueue) requeue(row queuedCommandRow, cause error) {
+	if row.Attempts >= queueMaxAttempts {
+		logQueueUpdateError(row.ID, q.finish(row.ID, QueuedCommandFailed, nil, cause))
+		return
+	}
+	_, err := q.db.Update("connector_commands", "id", row.ID, map[string]interface{}{
+		"status":     QueuedCommandQueued,
+		"error":      cause.Error(),
+		"updated_at": time.Now(),
+	})
+	logQueueUpdateError(row.ID, err)
+}
+
+// logQueueUpdateError logs a failed write of a command's state; the row keeps its previous
+// state, so the command is picked up again as stale or expired
+func logQueueUpdateError(id string, err error) {
+	if err == nil {
+		return
+	}
+	utils.GetLogger().Warn("Failed to update queued connector command", map[string]interface{}{
+		"id":    id,
+		"error": err.Error(),
+	})
+}
+
+func (q *ConnectorQueue) finish(id, status string, summary interface{}, cause error) error {
+	now := time.Now()
+	data := map[string]interface{}{
+		"status":       status,
+		"updated_at":   now,
+		"completed_at": now,
+		"error":        nil,
+	}
+	if summary != nil {
+		raw, err := json.Marshal(summary)
+		if err != nil {
+			return err
+		}
+		data["result"] = string(raw)
+	}
+	if cause != nil {
+		data["error"] = cause.Error()
+		utils.GetLogger().Warn("Queued connector command "+status, map[string]interface{}{
+			"id":    id,
+			"error": cause.Error(),
+		})
+	}
+	_, err := q.db.Update("connector_commands", "id", id, data)
+	return err
+}
+
+func (q *ConnectorQueue) row(id string) (*queuedCommandRow, error) {
+	var rows []queuedCommandRow
+	if err := q.db.From("connector_commands").Select("*").Eq("id", id).Limit(1).Execute(&rows); err != nil {
+		return nil, err
+	}
+	if len(rows) == 0 {
+		return nil, ErrQueuedCommandNotFound
+	}
+	return &rows[0], nil
+}
@@ -0,0 +1,136 @@
+package services
+
+import (
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/ekf/one-on-one-backend/pkg/protocol"
+)
+
+// commandCapabilities is the capability a connector needs to run a command; commands not
+// listed can run on any connector
+var commandCapabilities = map[string]string{
+	protocol.CommandSyncUsers:       CapabilityAD,
+	protocol.CommandAuthenticate:    CapabilityAD,
+	protocol.CommandGetSubordinates: CapabilityAD,
+	protocol.CommandGetCalendar:     CapabilityEWS,
+	protocol.CommandSyncCalendar:    CapabilityEWS,
+	protocol.CommandFindFreeSlots:   CapabilityEWS,
+
+	protocol.CommandGetMailFolders:       CapabilityEWS,
+	protocol.CommandGetEmails:            CapabilityEWS,
+	protocol.CommandGetEmailBody:         CapabilityEWS,
+	protocol.CommandSendEmail:            CapabilityEWS,
+	protocol.CommandMarkEmailRead:        CapabilityEWS,
+	protocol.CommandDeleteEmail:          CapabilityEWS,
+	protocol.CommandGetAttachments:       CapabilityEWS,
+	protocol.CommandGetAttachmentContent: CapabilityEWS,
+	protocol.CommandRespondToMeeting:     CapabilityEWS,
+	protocol.CommandCreateCalendarItem:   CapabilityEWS,
+	protocol.CommandUpdateCalendarItem:   CapabilityEWS,
+	protocol.CommandDeleteCalendarItem:   CapabilityEWS,
+}
+
+// routeDomain returns the domain a command targets: the "domain" parameter, else the
+// domain of "email", "username" (DOMAIN\user or user@domain) or "manager_dn". Empty when
+// the command does not name one.
+func routeDomain(params map[string]interface{}) string {
+	if domain, _ := params["domain"].(string); domain != "" {
+		return strings.ToLower(domain)
+	}
+	if email, _ := params["email"].(string); strings.Contains(email, "@") {
+		return strings.ToLower(email[strings.LastIndex(email, "@")+1:])
+	}
+	if username, _ := params["username"].(string); username != "" {
+		if i := strings.Index(username, `\`); i > 0 {
+			return strings.ToLower(username[:i])
+		}
+		if i := strings.LastIndex(username, "@"); i >= 0 {
+			return strings.ToLower(username[i+1:])
+		}
+	}
+	if dn, _ := params["manager_dn"].(string); dn != "" {
+		var parts []string
+		for _, rdn := range strings.Split(dn, ",") {
+			if k, v, ok := strings.Cut(strings.TrimSpace(rdn), "="); ok && strings.EqualFold(k, "DC") {
+				parts = append(parts, v)
+			}
+		}
+		return strings.ToLower(strings.Join(parts, "."))
+	}
+	return ""
+}
+
+// route returns the connectors that handle a command and have the capability it needs,
+// best first for a domain. Connectors listing the domain among their sites come first,
+// then those serving any domain; a command without a domain prefers the latter but may
+// run anywhere. Within a group healthy connectors come first, then the least busy and
+// least recently used.
+func (m *ConnectorManager) route(command, domain string) []*Connector {
+	m.mutex.RLock()
+	defer m.mutex.RUnlock()
+
+	var matching, catchAll, other []*Connector
+	for _, c := range m.connectors {
+		if !c.supports(command) || !c.can(commandCapabilities[command]) {
+			continue
+		}
+		switch {
+		case len(c.info.Sites) == 0:
+			catchAll = append(catchAll, c)
+		case domain != "" && c.servesDomain(domain):
+			matching = append(matching, c)
+		case domain == "":
+			other = append(other, c)
+		}
+	}
+
+	now := time.Now()
+	for _, group := range [][]*Connector{matching, catchAll, other} {
+		sort.SliceStable(group, func(i, j int) bool {
+			a, b := group[i], group[j]
+			if ha, hb := a.healthy(now), b.healthy(now); ha != hb {
+				return ha
+			}
+			if a.inFlight != b.inFlight {
+				return a.inFlight < b.inFlight
+			}
+			return a.lastUsed.Before(b.lastUsed)
+		})
+	}
+	return append(append(matching, catchAll...), other...)
+}
+
+// unroutable explains why route found no connector for a command while some are
+// connected: none of them supports it, or those that do serve other domains
+func (m *ConnectorManager) unroutable(command, domain string) error {
+	m.mutex.RLock()
+	defer m.mutex.RUnlock()
+
+	for _, c := range m.connectors {
+		if c.supports(command) && c.can(commandCapabilities[command]) {
+			return fmt.Errorf("%w %s", ErrDomainNotServed, domain)
+		}
+	}
+	return fmt.Errorf("%w: %s", ErrUnsupportedCommand, command)
+}
+
+// CanRun reports whether a connected connector can run a command with params now
+func (m *ConnectorManager) CanRun(command string, params json.RawMessage) bool {
+	var fields map[string]interface{}
+	json.Unmarshal(params, &fields)
+	return len(m.route(command, routeDomain(fields))) > 0
+}
+
+// servesDomain reports whether one of the connector's sites is the domain or its parent
+func (c *Connector) servesDomain(domain string) bool {
+	for _, site := range c.info.Sites {
+		if site == domain || strings.HasSuffix(domain, "."+site) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,54 @@
+package services
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/ekf/one-on-one-backend/pkg/protocol"
+)
+
+func TestSendCommandWithoutRoute(t *testing.T) {
+	params := protocol.CalendarParams{Email: "ivanov@branch.example"}
+	tests := []struct {
+		name    string
+		info    ConnectorInfo
+		command []string
+		want    error
+		wantMsg string
+	}{
+		{
+			name:    "domain served by no connector",
+			info:    ConnectorInfo{Name: "hq", Capabilities: []string{CapabilityEWS}, Sites: []string{"hq.example"}},
+			command: []string{protocol.CommandSyncCalendar},
+			want:    ErrDomainNotServed,
+			wantMsg: "no connector serves branch.example",
+		},
+		{
+			name:    "command not supported",
+			info:    ConnectorInfo{Name: "old", Capabilities: []string{CapabilityEWS}},
+			command: []string{protocol.CommandGetCalendar},
+			want:    ErrUnsupportedCommand,
+		},
+		{
+			name:    "capability missing",
+			info:    ConnectorInfo{Name: "ad-only", Capabilities: []string{CapabilityAD}},
+			command: []string{protocol.CommandSyncCalendar},
+			want:    ErrUnsupportedCommand,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			m := NewConnectorManager()
+			m.connectors[tt.info.Name] = &Connector{info: tt.info, commands: tt.command, lastSeen: time.Now()}
+
+			err := m.SendCommand(protocol.CommandSyncCalendar, params, nil, time.Second)
+			if !errors.Is(err, tt.want) {
+				t.Fatalf("SendCommand() = %v, want %v", err, tt.want)
+			}
+			if tt.wantMsg != "" && err.Error() != tt.wantMsg {
+				t.Errorf("error %q, want %q", err, tt.wantMsg)
+			}
+		})
+	}
+}
@@ -0,0 +1,104 @@
+package services
+
+import (
+	"encoding/json"
+	"strings"
+	"testing"
+
+	"github.com/ekf/one-on-one-backend/pkg/protocol"
+)
+
+// streamRequest registers a pending request as SendCommand does and returns its reply
+// channel
+func streamRequest(m *ConnectorManager, requestID string) chan connectorReply {
+	response := make(chan connectorReply, 1)
+	m.pendingRequests[requestID] = &pendingRequest{
+		command:  "sync_users",
+		response: response,
+		activity: make(chan struct{}, 1),
+	}
+	return response
+}
+
+func chunkMessage(t *testing.T, requestID string, seq int, data string) []byte {
+	t.Helper()
+	msg, err := json.Marshal(protocol.Chunk{Type: protocol.TypeChunk, RequestID: requestID, Seq: seq, Data: data})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return msg
+}
+
+func responseMessage(t *testing.T, requestID string, chunks int) []byte {
+	t.Helper()
+	msg, err := json.Marshal(protocol.Response{Type: protocol.TypeResponse, RequestID: requestID, Success: true, Chunks: chunks})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return msg
+}
+
+func TestStreamedResultReassembly(t *testing.T) {
+	result := `{"users":[` + strings.Repeat(`{"name":"Иванов Иван","email":"ivanov@ekf.su"},`, 40) + `{}]}`
+	chunks := protocol.SplitChunks([]byte(result), 64)
+
+	tests := []struct {
+		name    string
+		order   []int // chunk indexes in the order they arrive
+		wantErr bool
+	}{
+		{name: "in order", order: sequence(len(chunks))},
+		{name: "lost chunk", order: append(sequence(3), 4), wantErr: true},
+		{name: "out of order", order: []int{1, 0}, wantErr: true},
+		{name: "duplicated chunk", order: []int{0, 0}, wantErr: true},
+		{name: "missing last chunk", order: sequence(len(chunks) - 1), wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			m := NewConnectorManager()
+			response := streamRequest(m, "r1")
+			for _, i := range tt.order {
+				m.handleStream(protocol.TypeChunk, chunkMessage(t, "r1", i, chunks[i]))
+			}
+			m.HandleResponse(responseMessage(t, "r1", len(chunks)))
+
+			reply := <-response
+			if tt.wantErr {
+				if reply.err == nil {
+					t.Error("broken stream was accepted")
+				}
+				return
+			}
+			if reply.err != nil {
+				t.Fatal(reply.err)
+			}
+			if string(reply.Result) != result {
+				t.Errorf("result = %s, want %s", reply.Result, result)
+			}
+			if !json.Valid(reply.Result) {
+				t.Error("reassembled result is not valid JSON")
+			}
+		})
+	}
+}
+
+func TestStreamIgnoresOtherRequests(t *testing.T) {
+	m := NewConnectorManager()
+	response := streamRequest(m, "r1")
+	m.handleStream(protocol.TypeChunk, chunkMessage(t, "other", 0, `{"x":1}`))
+	m.handleStream(protocol.TypeChunk, chunkMessage(t, "r1", 0, `{"ok":true}`))
+	m.HandleResponse(responseMessage(t, "r1", 1))
+
+	reply := <-response
+	if reply.err != nil || string(reply.Result) != `{"ok":true}` {
+		t.Errorf("reply = %s, %v", reply.Result, reply.err)
+	}
+}
+
+func sequence(n int) []int {
+	s := make([]int, n)
+	for i := range s {
+		s[i] = i
+	}
+	return s
+}
@@ -0,0 +1,91 @@
+package services
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/ekf/one-on-one-backend/internal/utils"
+)
+
+// connectorTLSFiles holds the TLS configuration of the connector listener, reloaded when
+// one of its files changes
+type connectorTLSFiles struct {
+	certFile, keyFile, clientCAFile string
+
+	mu      sync.Mutex
+	modTime time.Time
+	config  *tls.Config
+}
+
+// NewConnectorTLSConfig returns the TLS configuration of the mTLS listener for connectors.
+// Client certificates are optional, so that connectors with tokens can use the listener
+// too; a certificate that is presented must be signed by a CA of clientCAFile. The files
+// are checked on every handshake and reloaded when changed; while they cannot be read, the
+// last good configuration is used.
+func NewConnectorTLSConfig(certFile, keyFile, clientCAFile string) (*tls.Config, error) {
+	if certFile == "" || keyFile == "" || clientCAFile == "" {
+		return nil, errors.New("connector TLS needs a certificate, a key and a client CA")
+	}
+	f := &connectorTLSFiles{certFile: certFile, keyFile: keyFile, clientCAFile: clientCAFile}
+	if _, err := f.load(); err != nil {
+		return nil, err
+	}
+
+	return &tls.Config{
+		MinVersion: tls.VersionTLS12,
+		GetConfigForClient: func(*tls.ClientHelloInfo) (*tls.Config, error) {
+			config, err := f.load()
+			if err != nil {
+				utils.GetLogger().Warn("Failed to reload connector TLS files", map[string]interface{}{"error": err.Error()})
+			}
+			return config, nil
+		},
+	}, nil
+}
+
+// load returns the configuration, reading the files again if they changed since the last
+// load. On error it returns the last good configuration with the error.
+func (f *connectorTLSFiles) load() (*tls.Config, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	var modTime time.Time
+	for _, name := range []string{f.certFile, f.keyFile, f.clientCAFile} {
+		info, err := os.Stat(name)
+		if err != nil {
+			return f.config, err
+		}
+		if info.ModTime().After(modTime) {
+			modTime = info.ModTime()
+		}
+	}
+	if f.config != nil && modTime.Equal(f.modTime) {
+		return f.config, nil
+	}
+
+	cert, err := tls.LoadX509KeyPair(f.certFile, f.keyFile)
+	if err != nil {
+		return f.config, err
+	}
+	caPEM, err := os.ReadFile(f.clientCAFile)
+	if err != nil {
+		return f.config, err
+	}
+	clientCAs := x509.NewCertPool()
+	if !clientCAs.AppendCertsFromPEM(caPEM) {
+		return f.config, errors.New("no certificates in " + f.clientCAFile)
+	}
+
+	f.config = &tls.Config{
+		MinVersion:   tls.VersionTLS12,
+		Certificates: []tls.Certificate{cert},
+		ClientAuth:   tls.VerifyClientCertIfGiven,
+		ClientCAs:    clientCAs,
+	}
+	f.modTime = modTime
+	return f.config, nil
+}
@@ -0,0 +1,69 @@
+package templates
+
+// labels are localized names of stored codes, by locale, group and code. A code missing
+// here is shown as it is.
+var labels = map[string]map[string]map[string]string{
+	LocaleRU: {
+		"task_status": {
+			"backlog":     "Бэклог",
+			"todo":        "К выполнению",
+			"in_progress": "В работе",
+			"review":      "На проверке",
+			"done":        "Готово",
+		},
+		"ticket_priority": {
+			"low":      "низкий",
+			"medium":   "средний",
+			"high":     "высокий",
+			"critical": "критический",
+		},
+		"improvement_status": {
+			"screening":        "первичная оценка",
+			"evaluation":       "оценка",
+			"manager_approval": "согласование руководителем",
+			"committee_review": "рассмотрение комитетом",
+			"budgeting":        "бюджетирование",
+			"project_created":  "проект создан",
+		},
+		"agenda_priority": {
+			"high":   "важно",
+			"medium": "средний приоритет",
+			"low":    "если останется время",
+		},
+	},
+	LocaleEN: {
+		"task_status": {
+			"backlog":     "Backlog",
+			"todo":        "To do",
+			"in_progress": "In progress",
+			"review":      "In review",
+			"done":        "Done",
+		},
+		"ticket_priority": {
+			"low":      "low",
+			"medium":   "medium",
+			"high":     "high",
+			"critical": "critical",
+		},
+		"improvement_status": {
+			"screening":        "screening",
+			"evaluation":       "evaluation",
+			"manager_approval": "manager approval",
+			"committee_review": "committee review",
+			"budgeting":        "budgeting",
+			"project_created":  "project created",
+		},
+		"agenda_priority": {
+			"high":   "important",
+			"medium": "medium priority",
+			"low":    "if time permits",
+		},
+	},
+}
+
+func label(locale, group, code string) string {
+	if name, ok := labels[locale][group][code]; ok {
+		return name
+	}
+	return code
+}
@@ -0,0 +1,229 @@
+// Package templates renders user-facing messages (notifications, emails, bot messages) from
+// localized templates. Each locale is a file in locales/ defining "<name>.subject" and
+// "<name>.body" templates; a name missing in a locale falls back to the default locale.
+//
+// The same template renders as plain text, Telegram HTML or an HTML email. Templates format
+// with functions instead of markup, so each format gets its own output:
+//
+//	bold, italic       emphasis
+//	link path text     link to a frontend route (plain text in the Text format)
+//	url path           absolute URL of a frontend route
+//	date, datetime     a time or RFC3339/date string in the business timezone
+//	label group key    localized name of a status or priority, see labels.go
+//	add a b            a + b, for numbering
+package templates
+
+import (
+	"bytes"
+	"embed"
+	"errors"
+	"fmt"
+	htmltemplate "html/template"
+	"strings"
+	texttemplate "text/template"
+	"time"
+)
+
+//go:embed locales/*.tmpl
+var files embed.FS
+
+// Locales
+const (
+	LocaleRU      = "ru"
+	LocaleEN      = "en"
+	DefaultLocale = LocaleRU
+)
+
+// Locales lists the supported locales
+var Locales = []string{LocaleRU, LocaleEN}
+
+// Format is an output format of a template
+type Format string
+
+const (
+	Text     Format = "text"     // plain text, e.g. the notification inbox
+	Telegram Format = "telegram" // Telegram HTML: b, i and a tags, line breaks
+	Email    Format = "email"    // HTML email body
+)
+
+var ErrUnknownTemplate = errors.New("unknown template")
+
+// Rendered is a rendered template. Subject is always plain text.
+type Rendered struct {
+	Subject string
+	Body    string
+}
+
+// Renderer renders the embedded templates
+type Renderer struct {
+	baseURL  string
+	location *time.Location
+
+	text map[string]*texttemplate.Template
+	html map[string]*htmltemplate.Template
+}
+
+// New parses the templates. Links point to baseURL, the address of the frontend; without
+// it they are rendered as plain text. Times are shown in location. The templates are
+// embedded, so a parse error is a bug and panics.
+func New(baseURL string, location *time.Location) *Renderer {
+	r := &Renderer{
+		baseURL:  strings.TrimRight(baseURL, "/"),
+		location: location,
+		text:     make(map[string]*texttemplate.Template),
+		html:     make(map[string]*htmltemplate.Template),
+	}
+	for _, locale := range Locales {
+		src, err := files.ReadFile("locales/" + locale + ".tmpl")
+		if err != nil {
+			panic(err)
+		}
+		r.text[locale] = texttemplate.Must(texttemplate.New(locale).Funcs(r.textFuncs(locale)).Parse(string(src)))
+		r.html[locale] = htmltemplate.Must(htmltemplate.New(locale).Funcs(r.htmlFuncs(locale)).Parse(string(src)))
+	}
+	return r
+}
+
+// NormalizeLocale maps a locale such as "en-US" to a supported one, the default otherwise
+func NormalizeLocale(locale string) string {
+	locale = strings.ToLower(locale)
+	if i := strings.IndexAny(locale, "-_"); i > 0 {
+		locale = locale[:i]
+	}
+	for _, l := range Locales {
+		if l == locale {
+			return l
+		}
+	}
+	return DefaultLocale
+}
+
+// Render renders the template name in a locale and format. data is usually a map; fields
+// missing from it render empty when guarded with if/with in the template.
+func (r *Renderer) Render(name, locale string, format Format, data interface{}) (Rendered, error) {
+	locale = r.resolve(name, NormalizeLocale(locale))
+	if locale == "" {
+		return Rendered{}, fmt.Errorf("%w: %s", ErrUnknownTemplate, name)
+	}
+
+	var subject bytes.Buffer
+	if t := r.text[locale].Lookup(name + ".subject"); t != nil {
+		if err := t.Execute(&subject, data); err != nil {
+			return Rendered{}, err
+		}
+	}
+
+	var body bytes.Buffer
+	var err error
+	if format == Text {
+		err = r.text[locale].ExecuteTemplate(&body, name+".body", data)
+	} else {
+		err = r.html[locale].ExecuteTemplate(&body, name+".body", data)
+	}
+	if err != nil {
+		return Rendered{}, err
+	}
+
+	result := Rendered{
+		Subject: strings.Join(strings.Fields(subject.String()), " "),
+		Body:    strings.TrimSpace(body.String()),
+	}
+	if format == Email {
+		result.Body = `<div style="font-family: Arial, sans-serif">` +
+			strings.ReplaceAll(result.Body, "\n", "<br>") + `</div>`
+	}
+	return result, nil
+}
+
+// resolve returns the locale to render name in, empty if no locale has it
+func (r *Renderer) resolve(name, locale string) string {
+	for _, l := range []string{locale, DefaultLocale} {
+		if r.text[l].Lookup(name+".body") != nil {
+			return l
+		}
+	}
+	return ""
+}
+
+// URL returns the absolute address of a frontend route, empty without a base URL
+func (r *Renderer) URL(path string) string {
+	if r.baseURL == "" || path == "" {
+		return ""
+	}
+	return r.baseURL + "/" + strings.TrimLeft(path, "/")
+}
+
+// commonFuncs are the functions whose result does not depend on the format
+func (r *Renderer) commonFuncs(locale string) map[string]interface{} {
+	return map[string]interface{}{
+		"url":      r.URL,
+		"date":     func(v interface{}) string { return r.formatTime(v, locale, false) },
+		"datetime": func(v interface{}) string { return r.formatTime(v, locale, true) },
+		"label":    func(group string, key interface{}) string { return label(locale, group, fmt.Sprint(key)) },
+		"add":      func(a, b int) int { return a + b },
+	}
+}
+
+func (r *Renderer) textFuncs(locale string) texttemplate.FuncMap {
+	funcs := texttemplate.FuncMap(r.commonFuncs(locale))
+	funcs["bold"] = fmt.Sprint
+	funcs["italic"] = fmt.Sprint
+	funcs["link"] = func(_ string, text interface{}) string { return fmt.Sprint(text) }
+	return funcs
+}
+
+func (r *Renderer) htmlFuncs(locale string) htmltemplate.FuncMap {
+	funcs := htmltemplate.FuncMap(r.commonFuncs(locale))
+	funcs["bold"] = func(v interface{}) htmltemplate.HTML {
+		return htmltemplate.HTML("<b>" + htmltemplate.HTMLEscapeString(fmt.Sprint(v)) + "</b>")
+	}
+	funcs["italic"] = func(v interface{}) htmltemplate.HTML {
+		return htmltemplate.HTML("<i>" + htmltemplate.HTMLEscapeString(fmt.Sprint(v)) + "</i>")
+	}
+	funcs["link"] = func(path string, text interface{}) htmltemplate.HTML {
+		escaped := htmltemplate.HTMLEscapeString(fmt.Sprint(text))
+		if u := r.URL(path); u != "" {
+			return htmltemplate.HTML(`<a href="` + htmltemplate.HTMLEscapeString(u) + `">` + escaped + `</a>`)
+		}
+		return htmltemplate.HTML(escaped)
+	}
+	return funcs
+}
+
+var timeLayouts = map[string][2]string{
+	LocaleRU: {"02.01.2006", "02.01.2006 15:04"},
+	LocaleEN: {"Jan 2, 2006", "Jan 2, 2006 15:04"},
+}
+
+// formatTime formats a time.Time, *time.Time or RFC3339/"2006-01-02" string; other
+// values are printed as they are
+func (r *Renderer) formatTime(v interface{}, locale string, withTime bool) string {
+	var t time.Time
+	switch value := v.(type) {
+	case time.Time:
+		t = value.In(r.location)
+	case *time.Time:
+		if value == nil {
+			return ""
+		}
+		t = value.In(r.location)
+	case string:
+		if parsed, err := time.Parse(time.RFC3339Nano, value); err == nil {
+			t = parsed.In(r.location)
+		} else if parsed, err := time.Parse("2006-01-02", value[:min(10, len(value))]); err == nil {
+			t, withTime = parsed, false
+		} else {
+			return value
+		}
+	case nil:
+		return ""
+	default:
+		return fmt.Sprint(v)
+	}
+
+	layouts := timeLayouts[locale]
+	if withTime {
+		return t.Format(layouts[1])
+	}
+	return t.Format(layouts[0])
+}
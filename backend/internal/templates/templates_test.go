@@ -0,0 +1,202 @@
+package templates
+
+import (
+	"errors"
+	"regexp"
+	"sort"
+	"strings"
+	"testing"
+	"time"
+)
+
+// hostile is user data that must be escaped in the HTML formats
+const hostile = `<script>alert("x")</script> & Co`
+
+// sampleData fills every field the templates use, user-provided text with hostile;
+// overrides select branches, such as the status of a proposal
+func sampleData(overrides map[string]interface{}) map[string]interface{} {
+	data := map[string]interface{}{
+		"link":          "/tasks/1",
+		"title":         hostile,
+		"number":        "INC-2026-0001",
+		"due_date":      "2026-03-02",
+		"old_status":    "todo",
+		"new_status":    "done",
+		"sla_deadline":  time.Date(2026, 3, 2, 9, 30, 0, 0, time.UTC),
+		"priority":      "high",
+		"status":        "proposed",
+		"meeting_title": hostile,
+		"date":          "2026-03-02",
+		"employee_name": hostile,
+		"start_time":    "2026-03-02T10:00:00Z",
+		"error":         hostile,
+		"count":         2,
+		"items": []map[string]interface{}{
+			{"link": "/tasks/1", "title": hostile, "body": hostile},
+		},
+		"statuses": []map[string]interface{}{
+			{"link": "/employees/1", "employee_name": hostile, "last_meeting": "2026-01-10", "days_overdue": 5},
+			{"link": "/employees/2", "employee_name": hostile, "last_meeting": "", "days_overdue": 0},
+		},
+		"history_days": 180,
+		"content": map[string]interface{}{
+			"summary": hostile,
+			"items": []map[string]interface{}{
+				{"topic": hostile, "minutes": 15.0, "priority": "high", "details": hostile, "reason": hostile},
+			},
+			"agreements_to_review": []map[string]interface{}{{"task": hostile, "note": hostile}},
+			"questions_to_ask":     []string{hostile},
+			"risks":                []string{hostile},
+		},
+		"type":   "text",
+		"sender": hostile,
+	}
+	for key, value := range overrides {
+		data[key] = value
+	}
+	return data
+}
+
+// variants select the branches of the templates
+var variants = []map[string]interface{}{
+	nil,
+	{"status": "booked"},
+	{"type": "voice"},
+	{"type": "video"},
+	{"type": "file"},
+	{"type": "gif"},
+}
+
+// templateNames returns the names of the templates a locale defines, without suffixes
+func templateNames(t *testing.T, r *Renderer, locale string) []string {
+	t.Helper()
+	var names []string
+	for _, tmpl := range r.text[locale].Templates() {
+		if name, ok := strings.CutSuffix(tmpl.Name(), ".body"); ok {
+			names = append(names, name)
+		}
+	}
+	sort.Strings(names)
+	return names
+}
+
+var telegramTag = regexp.MustCompile(`</?([a-z]+)`)
+
+func TestLocalesDefineTheSameTemplates(t *testing.T) {
+	r := New("https://hub.example.com", time.UTC)
+	ru, en := templateNames(t, r, LocaleRU), templateNames(t, r, LocaleEN)
+	if strings.Join(ru, ",") != strings.Join(en, ",") {
+		t.Errorf("ru templates %v, en templates %v", ru, en)
+	}
+	if len(ru) == 0 {
+		t.Fatal("no templates parsed")
+	}
+}
+
+func TestRenderEveryTemplate(t *testing.T) {
+	r := New("https://hub.example.com", time.UTC)
+	for _, locale := range Locales {
+		for _, name := range templateNames(t, r, locale) {
+			for _, overrides := range variants {
+				for _, format := range []Format{Text, Telegram, Email} {
+					out, err := r.Render(name, locale, format, sampleData(overrides))
+					if err != nil {
+						t.Errorf("%s/%s/%s: %v", locale, name, format, err)
+						continue
+					}
+					checkRendered(t, locale+"/"+name+"/"+string(format), format, out)
+				}
+			}
+		}
+	}
+}
+
+func checkRendered(t *testing.T, where string, format Format, out Rendered) {
+	t.Helper()
+	if out.Body == "" {
+		t.Errorf("%s: empty body", where)
+	}
+	for _, text := range []string{out.Subject, out.Body} {
+		if strings.Contains(text, "<no value>") || strings.Contains(text, "%!") {
+			t.Errorf("%s: field missing or misformatted in %q", where, text)
+		}
+	}
+	if format == Text {
+		return
+	}
+
+	if strings.Contains(out.Body, "<script>") || !strings.Contains(out.Body, "&lt;script&gt;") {
+		t.Errorf("%s: user data is not escaped in %q", where, out.Body)
+	}
+	if format == Telegram {
+		// Telegram rejects messages with tags other than the ones it supports
+		for _, m := range telegramTag.FindAllStringSubmatch(out.Body, -1) {
+			if m[1] != "b" && m[1] != "i" && m[1] != "a" {
+				t.Errorf("%s: tag <%s> is not supported by Telegram", where, m[1])
+			}
+		}
+	}
+}
+
+func TestRenderLinks(t *testing.T) {
+	data := map[string]interface{}{"link": "/tasks/1", "title": "Fix & ship"}
+	tests := []struct {
+		baseURL string
+		format  Format
+		want    string
+	}{
+		{"https://hub.example.com/", Telegram, `📌 <a href="https://hub.example.com/tasks/1">Fix &amp; ship</a>`},
+		{"https://hub.example.com", Text, "📌 Fix & ship"},
+		{"", Telegram, "📌 Fix &amp; ship"},
+	}
+	for _, tt := range tests {
+		out, err := New(tt.baseURL, time.UTC).Render("task.assigned", LocaleRU, tt.format, data)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if out.Body != tt.want {
+			t.Errorf("base URL %q, %s: body %q, want %q", tt.baseURL, tt.format, out.Body, tt.want)
+		}
+	}
+}
+
+func TestRenderLocaleFallback(t *testing.T) {
+	r := New("", time.UTC)
+	data := sampleData(nil)
+
+	want, err := r.Render("task.status_changed", DefaultLocale, Text, data)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, locale := range []string{"de", "", "xx-YY"} {
+		got, err := r.Render("task.status_changed", locale, Text, data)
+		if err != nil || got != want {
+			t.Errorf("locale %q: %+v, %v, want the default locale %+v", locale, got, err, want)
+		}
+	}
+
+	en, _ := r.Render("task.status_changed", LocaleEN, Text, data)
+	if got, _ := r.Render("task.status_changed", "en-US", Text, data); got != en {
+		t.Errorf("en-US: %+v, want %+v", got, en)
+	}
+	if en == want {
+		t.Error("en renders like the default locale")
+	}
+
+	if _, err := r.Render("no.such.template", LocaleEN, Text, data); !errors.Is(err, ErrUnknownTemplate) {
+		t.Errorf("unknown template: %v, want ErrUnknownTemplate", err)
+	}
+}
+
+func TestNormalizeLocale(t *testing.T) {
+	tests := map[string]string{
+		"ru": LocaleRU, "RU": LocaleRU, "ru-RU": LocaleRU,
+		"en": LocaleEN, "en_GB": LocaleEN, "EN-us": LocaleEN,
+		"de": DefaultLocale, "": DefaultLocale, "-en": DefaultLocale,
+	}
+	for locale, want := range tests {
+		if got := NormalizeLocale(locale); got != want {
+			t.Errorf("NormalizeLocale(%q) = %q, want %q", locale, got, want)
+		}
+	}
+}
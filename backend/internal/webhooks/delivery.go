@@ -0,0 +1,391 @@
+package webhooks
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/ekf/one-on-one-backend/internal/database"
+	"github.com/ekf/one-on-one-backend/internal/utils"
+)
+
+const (
+	pollInterval    = 5 * time.Second
+	deliverLock     = "webhooks:deliver"
+	batchSize       = 100
+	workers         = 4
+	baseBackoff     = 30 * time.Second
+	maxBackoff      = 6 * time.Hour
+	maxResponseBody = 2048
+)
+
+// Request headers sent with every delivery
+const (
+	HeaderEvent     = "X-Webhook-Event"
+	HeaderDelivery  = "X-Webhook-Delivery"
+	HeaderTimestamp = "X-Webhook-Timestamp"
+	HeaderSignature = "X-Webhook-Signature"
+)
+
+var ErrDeliveryNotFound = errors.New("delivery not found")
+
+// Delivery is an event queued for one endpoint with the result of its last attempt
+type Delivery struct {
+	ID             string          `json:"id"`
+	WebhookID      string          `json:"webhook_id"`
+	EventID        string          `json:"event_id"`
+	Event          string          `json:"event"`
+	Payload        json.RawMessage `json:"payload"`
+	Status         string          `json:"status"` // pending, success, failed
+	Attempts       int             `json:"attempts"`
+	NextAttemptAt  *time.Time      `json:"next_attempt_at,omitempty"`
+	LastAttemptAt  *time.Time      `json:"last_attempt_at,omitempty"`
+	ResponseStatus *int            `json:"response_status,omitempty"`
+	ResponseBody   *string         `json:"response_body,omitempty"`
+	Error          *string         `json:"error,omitempty"`
+	ReplayOf       *string         `json:"replay_of,omitempty"`
+	CreatedAt      *time.Time      `json:"created_at,omitempty"`
+	DeliveredAt    *time.Time      `json:"delivered_at,omitempty"`
+}
+
+// Attempt is a single HTTP request made for a delivery
+type Attempt struct {
+	ID             string     `json:"id"`
+	DeliveryID     string     `json:"delivery_id"`
+	Attempt        int        `json:"attempt"`
+	ResponseStatus *int       `json:"response_status,omitempty"`
+	ResponseBody   *string    `json:"response_body,omitempty"`
+	Error          *string    `json:"error,omitempty"`
+	DurationMs     *int64     `json:"duration_ms,omitempty"`
+	CreatedAt      *time.Time `json:"created_at,omitempty"`
+}
+
+// deliveryRow is a webhook_deliveries row; payload is JSONB and comes back as a string
+type deliveryRow struct {
+	Delivery
+	Payload string          `json:"payload"`
+	Webhook *deliveryTarget `json:"webhook"`
+}
+
+type deliveryTarget struct {
+	URL             string `json:"url"`
+	EncryptedSecret string `json:"encrypted_secret"`
+	Active          bool   `json:"active"`
+}
+
+func (r deliveryRow) delivery() Delivery {
+	d := r.Delivery
+	d.Payload = json.RawMessage(r.Payload)
+	return d
+}
+
+// DeliveryFilter selects deliveries; empty fields match everything
+type DeliveryFilter struct {
+	WebhookID string
+	EventID   string
+	Event     string
+	Status    string
+	Limit     int
+}
+
+// Dispatcher manages endpoints and sends queued deliveries. Replicas coordinate through
+// an advisory lock, so only one of them sends at a time.
+type Dispatcher struct {
+	db          database.DBClient
+	locker      database.AdvisoryLocker
+	secretKey   string // encrypts the signing secrets at rest
+	client      *http.Client
+	maxAttempts int
+
+	mu      sync.Mutex
+	started bool
+}
+
+// New creates a dispatcher. Signing secrets are encrypted with secretKey.
+func New(db database.DBClient, secretKey string, timeout time.Duration, maxAttempts int) *Dispatcher {
+	d := &Dispatcher{
+		db:          db,
+		secretKey:   secretKey,
+		client:      &http.Client{Timeout: timeout},
+		maxAttempts: max(maxAttempts, 1),
+	}
+	if locker, ok := db.(database.AdvisoryLocker); ok {
+		d.locker = locker
+	}
+	return d
+}
+
+// Start begins sending due deliveries in the background
+func (d *Dispatcher) Start() {
+	d.mu.Lock()
+	if d.started {
+		d.mu.Unlock()
+		return
+	}
+	d.started = true
+	d.mu.Unlock()
+
+	go func() {
+		ticker := time.NewTicker(pollInterval)
+		defer ticker.Stop()
+		for range ticker.C {
+			d.deliverDue()
+		}
+	}()
+}
+
+// Deliveries returns deliveries matching the filter, newest first
+func (d *Dispatcher) Deliveries(filter DeliveryFilter) ([]Delivery, error) {
+	query := d.db.From("webhook_deliveries").Select("*")
+	if filter.WebhookID != "" {
+		query = query.Eq("webhook_id", filter.WebhookID)
+	}
+	if filter.EventID != "" {
+		query = query.Eq("event_id", filter.EventID)
+	}
+	if filter.Event != "" {
+		query = query.Eq("event", filter.Event)
+	}
+	if filter.Status != "" {
+		query = query.Eq("status", filter.Status)
+	}
+	if filter.Limit > 0 {
+		query = query.Limit(filter.Limit)
+	}
+
+	var rows []deliveryRow
+	if err := query.Order("created_at", true).Execute(&rows); err != nil {
+		return nil, err
+	}
+	deliveries := make([]Delivery, len(rows))
+	for i, row := range rows {
+		deliveries[i] = row.delivery()
+	}
+	return deliveries, nil
+}
+
+// Delivery returns a delivery
+func (d *Dispatcher) Delivery(id string) (*Delivery, error) {
+	row, err := d.deliveryRow(id)
+	if err != nil {
+		return nil, err
+	}
+	delivery := row.delivery()
+	return &delivery, nil
+}
+
+// Attempts returns the request log of a delivery
+func (d *Dispatcher) Attempts(deliveryID string) ([]Attempt, error) {
+	var attempts []Attempt
+	err := d.db.From("webhook_delivery_attempts").Select("*").Eq("delivery_id", deliveryID).
+		Order("attempt", false).Execute(&attempts)
+	return attempts, err
+}
+
+// Replay queues the payload of a delivery again as a new delivery to the same endpoint.
+// The event ID is kept, so receivers can recognize the event.
+func (d *Dispatcher) Replay(deliveryID string) (*Delivery, error) {
+	original, err := d.deliveryRow(deliveryID)
+	if err != nil {
+		return nil, err
+	}
+
+	result, err := d.db.Insert("webhook_deliveries", map[string]interface{}{
+		"webhook_id":      original.WebhookID,
+		"event_id":        original.EventID,
+		"event":           original.Event,
+		"payload":         original.Payload,
+		"status":          StatusPending,
+		"next_attempt_at": time.Now(),
+		"replay_of":       original.ID,
+	})
+	if err != nil {
+		return nil, err
+	}
+	var created []deliveryRow
+	if err := json.Unmarshal(result, &created); err != nil || len(created) == 0 {
+		return nil, errors.New("failed to read queued delivery")
+	}
+	delivery := created[0].delivery()
+	return &delivery, nil
+}
+
+func (d *Dispatcher) deliveryRow(id string) (*deliveryRow, error) {
+	var rows []deliveryRow
+	if err := d.db.From("webhook_deliveries").Select("*").Eq("id", id).Limit(1).Execute(&rows); err != nil {
+		return nil, err
+	}
+	if len(rows) == 0 {
+		return nil, ErrDeliveryNotFound
+	}
+	return &rows[0], nil
+}
+
+// Sign computes the X-Webhook-Signature value: the hex HMAC-SHA256 of
+// "<timestamp>.<body>" keyed with the endpoint secret, prefixed with "sha256=".
+// Receivers recompute it and reject stale timestamps to prevent replays by third parties.
+func Sign(secret string, timestamp int64, body []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(strconv.FormatInt(timestamp, 10) + "."))
+	mac.Write(body)
+	return "sha256=" + hex.EncodeToString(mac.Sum(nil))
+}
+
+// deliverDue sends pending deliveries whose next attempt time has come
+func (d *Dispatcher) deliverDue() {
+	if d.locker != nil {
+		unlock, acquired, err := d.locker.TryAdvisoryLock(context.Background(), deliverLock)
+		if err != nil || !acquired {
+			return // another replica is sending
+		}
+		defer unlock()
+	}
+
+	var due []deliveryRow
+	err := d.db.From("webhook_deliveries").Select("*, webhook:webhooks(url, encrypted_secret, active)").
+		Eq("status", StatusPending).Lte("next_attempt_at", time.Now().Format(time.RFC3339)).
+		Order("next_attempt_at", false).Limit(batchSize).Execute(&due)
+	if err != nil {
+		utils.GetLogger().Warn("Failed to load due webhook deliveries", map[string]interface{}{"error": err.Error()})
+		return
+	}
+
+	sem := make(chan struct{}, workers)
+	var wg sync.WaitGroup
+	for _, row := range due {
+		wg.Add(1)
+		sem <- struct{}{}
+		go func(row deliveryRow) {
+			defer func() {
+				<-sem
+				wg.Done()
+			}()
+			d.deliver(row)
+		}(row)
+	}
+	wg.Wait()
+}
+
+// deliver makes one attempt and schedules the next one if it failed
+func (d *Dispatcher) deliver(row deliveryRow) {
+	attempt := row.Attempts + 1
+	started := time.Now()
+	status, body, err := d.send(row)
+	duration := time.Since(started).Milliseconds()
+
+	var responseStatus interface{}
+	if status != 0 {
+		responseStatus = status
+	}
+	var errorText interface{}
+	if err != nil {
+		errorText = err.Error()
+	} else if status < 200 || status > 299 {
+		errorText = fmt.Sprintf("endpoint responded with status %d", status)
+	}
+
+	d.db.Insert("webhook_delivery_attempts", map[string]interface{}{
+		"delivery_id":     row.ID,
+		"attempt":         attempt,
+		"response_status": responseStatus,
+		"response_body":   body,
+		"error":           errorText,
+		"duration_ms":     duration,
+	})
+
+	now := time.Now()
+	updates := map[string]interface{}{
+		"attempts":        attempt,
+		"last_attempt_at": now,
+		"response_status": responseStatus,
+		"response_body":   body,
+		"error":           errorText,
+	}
+	var permanent *permanentError
+	switch {
+	case errorText == nil:
+		updates["status"] = StatusSuccess
+		updates["delivered_at"] = now
+		updates["next_attempt_at"] = nil
+	case attempt >= d.maxAttempts || errors.As(err, &permanent):
+		updates["status"] = StatusFailed
+		updates["next_attempt_at"] = nil
+		utils.GetLogger().Warn("Webhook delivery failed", map[string]interface{}{
+			"delivery_id": row.ID,
+			"webhook_id":  row.WebhookID,
+			"event":       row.Event,
+			"attempts":    attempt,
+			"error":       errorText,
+		})
+	default:
+		updates["next_attempt_at"] = now.Add(backoff(attempt))
+	}
+
+	if _, err := d.db.Update("webhook_deliveries", "id", row.ID, updates); err != nil {
+		utils.GetLogger().Warn("Failed to update webhook delivery", map[string]interface{}{
+			"delivery_id": row.ID,
+			"error":       err.Error(),
+		})
+	}
+}
+
+// permanentError fails a delivery without further retries
+type permanentError struct{ msg string }
+
+func (e *permanentError) Error() string { return e.msg }
+
+// send posts the payload and returns the response status and the beginning of its body
+func (d *Dispatcher) send(row deliveryRow) (int, string, error) {
+	target := row.Webhook
+	if target == nil {
+		return 0, "", &permanentError{"webhook was deleted"}
+	}
+	if !target.Active && row.Event != EventPing {
+		return 0, "", &permanentError{"webhook is disabled"}
+	}
+	secret, err := utils.DecryptPassword(target.EncryptedSecret, d.secretKey)
+	if err != nil {
+		return 0, "", &permanentError{"cannot decrypt webhook secret"}
+	}
+
+	body := []byte(row.Payload)
+	timestamp := time.Now().Unix()
+	req, err := http.NewRequest(http.MethodPost, target.URL, bytes.NewReader(body))
+	if err != nil {
+		return 0, "", &permanentError{err.Error()}
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("User-Agent", "EKF-Hub-Webhooks/1.0")
+	req.Header.Set(HeaderEvent, row.Event)
+	req.Header.Set(HeaderDelivery, row.ID)
+	req.Header.Set(HeaderTimestamp, strconv.FormatInt(timestamp, 10))
+	req.Header.Set(HeaderSignature, Sign(secret, timestamp, body))
+
+	resp, err := d.client.Do(req)
+	if err != nil {
+		return 0, "", err
+	}
+	defer resp.Body.Close()
+
+	data, _ := io.ReadAll(io.LimitReader(resp.Body, maxResponseBody))
+	return resp.StatusCode, string(bytes.ToValidUTF8(data, nil)), nil
+}
+
+// backoff returns the delay after a failed attempt: 30s, 1m, 2m, ... up to 6h
+func backoff(attempt int) time.Duration {
+	delay := baseBackoff
+	for i := 1; i < attempt && delay < maxBackoff; i++ {
+		delay *= 2
+	}
+	return min(delay, maxBackoff)
+}
@@ -0,0 +1,135 @@
+package webhooks
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/ekf/one-on-one-backend/internal/database"
+	"github.com/ekf/one-on-one-backend/internal/utils"
+)
+
+const testKey = "test-secret-key"
+
+// deliveryDB records what deliver writes. Only the calls deliver makes are implemented.
+type deliveryDB struct {
+	database.DBClient
+	attempts []map[string]interface{}
+	updates  []map[string]interface{}
+}
+
+func (db *deliveryDB) Insert(table string, data map[string]interface{}) ([]byte, error) {
+	db.attempts = append(db.attempts, data)
+	return []byte("[]"), nil
+}
+
+func (db *deliveryDB) Update(table, keyColumn, keyValue string, data map[string]interface{}) ([]byte, error) {
+	db.updates = append(db.updates, data)
+	return []byte("[]"), nil
+}
+
+func TestBackoff(t *testing.T) {
+	tests := []struct {
+		attempt int
+		want    time.Duration
+	}{
+		{1, 30 * time.Second},
+		{2, time.Minute},
+		{3, 2 * time.Minute},
+		{4, 4 * time.Minute},
+		{10, 256 * time.Minute},
+		{11, 6 * time.Hour},
+		{50, 6 * time.Hour},
+	}
+	for _, tt := range tests {
+		if got := backoff(tt.attempt); got != tt.want {
+			t.Errorf("backoff(%d) = %v, want %v", tt.attempt, got, tt.want)
+		}
+	}
+}
+
+func TestDeliver(t *testing.T) {
+	const secret = "whsec_test"
+	encrypted, err := utils.EncryptPassword(secret, testKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// The endpoint answers with the status in the path and checks the signature
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		timestamp, _ := strconv.ParseInt(r.Header.Get(HeaderTimestamp), 10, 64)
+		if r.Header.Get(HeaderSignature) != Sign(secret, timestamp, body) {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		status, _ := strconv.Atoi(r.URL.Path[1:])
+		w.WriteHeader(status)
+		io.WriteString(w, "ok")
+	}))
+	defer server.Close()
+	closed := httptest.NewServer(http.NotFoundHandler())
+	closed.Close()
+
+	target := func(url string, active bool) *deliveryTarget {
+		return &deliveryTarget{URL: url, EncryptedSecret: encrypted, Active: active}
+	}
+
+	tests := []struct {
+		name       string
+		target     *deliveryTarget
+		event      string
+		attempts   int // made before this one
+		wantStatus interface{}
+		wantRetry  bool
+	}{
+		{"2xx succeeds", target(server.URL+"/200", true), EventTaskCreated, 0, StatusSuccess, false},
+		{"204 succeeds", target(server.URL+"/204", true), EventTaskCreated, 2, StatusSuccess, false},
+		{"5xx is retried", target(server.URL+"/503", true), EventTaskCreated, 0, nil, true},
+		{"network error is retried", target(closed.URL, true), EventTaskCreated, 1, nil, true},
+		{"last attempt fails", target(server.URL+"/500", true), EventTaskCreated, 2, StatusFailed, false},
+		{"deleted webhook fails at once", nil, EventTaskCreated, 0, StatusFailed, false},
+		{"disabled webhook fails at once", target(server.URL+"/200", false), EventTaskCreated, 0, StatusFailed, false},
+		{"disabled webhook gets pings", target(server.URL+"/200", false), EventPing, 0, StatusSuccess, false},
+		{"undecryptable secret fails at once", &deliveryTarget{URL: server.URL + "/200", EncryptedSecret: "garbage"}, EventTaskCreated, 0, StatusFailed, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			db := &deliveryDB{}
+			d := New(db, testKey, time.Second, 3)
+			row := deliveryRow{Payload: `{"id":"evt_1"}`, Webhook: tt.target}
+			row.ID, row.Event, row.Attempts = "d1", tt.event, tt.attempts
+
+			before := time.Now()
+			d.deliver(row)
+
+			if len(db.attempts) != 1 || len(db.updates) != 1 {
+				t.Fatalf("got %d attempts and %d updates, want one of each", len(db.attempts), len(db.updates))
+			}
+			update := db.updates[0]
+			if update["attempts"] != tt.attempts+1 {
+				t.Errorf("attempts = %v, want %d", update["attempts"], tt.attempts+1)
+			}
+			if update["status"] != tt.wantStatus {
+				t.Errorf("status = %v, want %v", update["status"], tt.wantStatus)
+			}
+
+			next, retried := update["next_attempt_at"].(time.Time)
+			if retried != tt.wantRetry {
+				t.Fatalf("next_attempt_at = %v, want a retry: %v", update["next_attempt_at"], tt.wantRetry)
+			}
+			if retried {
+				delay := backoff(tt.attempts + 1)
+				if next.Before(before.Add(delay)) || next.After(time.Now().Add(delay)) {
+					t.Errorf("next attempt in %v, want %v", next.Sub(before), delay)
+				}
+			}
+			if (update["error"] == nil) != (tt.wantStatus == StatusSuccess) {
+				t.Errorf("error = %v with status %v", update["error"], tt.wantStatus)
+			}
+		})
+	}
+}
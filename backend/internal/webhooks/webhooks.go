@@ -0,0 +1,355 @@
+// Package webhooks notifies external systems about domain events. Admins register
+// endpoints subscribed to events; Publish queues a delivery per subscribed endpoint and
+// the dispatcher sends them with an HMAC signature, retrying failures with backoff.
+package webhooks
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/url"
+	"slices"
+	"time"
+
+	"github.com/ekf/one-on-one-backend/internal/database"
+	"github.com/ekf/one-on-one-backend/internal/utils"
+	"github.com/google/uuid"
+)
+
+// Domain events that can be subscribed to
+const (
+	EventTaskCreated         = "task.created"
+	EventTaskStatusChanged   = "task.status_changed"
+	EventTicketSLABreached   = "ticket.sla_breached"
+	EventImprovementApproved = "improvement.approved"
+	EventMeetingAnalyzed     = "meeting.analyzed"
+	// EventPing is sent by Ping to test an endpoint, regardless of its subscriptions
+	EventPing = "ping"
+)
+
+// AllEvents subscribes an endpoint to every event
+const AllEvents = "*"
+
+// Events lists the events endpoints can subscribe to
+var Events = []string{
+	EventTaskCreated,
+	EventTaskStatusChanged,
+	EventTicketSLABreached,
+	EventImprovementApproved,
+	EventMeetingAnalyzed,
+}
+
+// Delivery statuses
+const (
+	StatusPending = "pending"
+	StatusSuccess = "success"
+	StatusFailed  = "failed"
+)
+
+var (
+	ErrNotFound      = errors.New("webhook not found")
+	ErrInvalidURL    = errors.New("url must be an absolute http or https URL")
+	ErrUnknownEvent  = errors.New("unknown event")
+	ErrMissingFields = errors.New("name and url are required")
+)
+
+// Webhook is a registered endpoint
+type Webhook struct {
+	ID        string     `json:"id"`
+	Name      string     `json:"name"`
+	URL       string     `json:"url"`
+	Events    []string   `json:"events"`
+	Active    bool       `json:"active"`
+	CreatedBy *string    `json:"created_by,omitempty"`
+	CreatedAt *time.Time `json:"created_at,omitempty"`
+	UpdatedAt *time.Time `json:"updated_at,omitempty"`
+}
+
+// webhookRow is a webhooks row; events is JSONB and comes back as a string
+type webhookRow struct {
+	ID              string     `json:"id"`
+	Name            string     `json:"name"`
+	URL             string     `json:"url"`
+	EncryptedSecret string     `json:"encrypted_secret"`
+	Events          string     `json:"events"`
+	Active          bool       `json:"active"`
+	CreatedBy       *string    `json:"created_by"`
+	CreatedAt       *time.Time `json:"created_at"`
+	UpdatedAt       *time.Time `json:"updated_at"`
+}
+
+func (r webhookRow) webhook() Webhook {
+	w := Webhook{
+		ID:        r.ID,
+		Name:      r.Name,
+		URL:       r.URL,
+		Events:    []string{},
+		Active:    r.Active,
+		CreatedBy: r.CreatedBy,
+		CreatedAt: r.CreatedAt,
+		UpdatedAt: r.UpdatedAt,
+	}
+	json.Unmarshal([]byte(r.Events), &w.Events)
+	return w
+}
+
+func (r webhookRow) subscribed(event string) bool {
+	events := r.webhook().Events
+	return slices.Contains(events, AllEvents) || slices.Contains(events, event)
+}
+
+// Input holds the editable fields of a webhook; nil fields are left unchanged on update
+type Input struct {
+	Name   *string  `json:"name"`
+	URL    *string  `json:"url"`
+	Events []string `json:"events"`
+	Active *bool    `json:"active"`
+}
+
+// Payload is the JSON body sent to endpoints
+type Payload struct {
+	ID         string      `json:"id"` // event ID, the same in retries and replays
+	Event      string      `json:"event"`
+	OccurredAt time.Time   `json:"occurred_at"`
+	Data       interface{} `json:"data"`
+}
+
+// List returns all registered endpoints
+func (d *Dispatcher) List() ([]Webhook, error) {
+	var rows []webhookRow
+	if err := d.db.From("webhooks").Select("*").Order("created_at", false).Execute(&rows); err != nil {
+		return nil, err
+	}
+	webhooks := make([]Webhook, len(rows))
+	for i, row := range rows {
+		webhooks[i] = row.webhook()
+	}
+	return webhooks, nil
+}
+
+// Get returns an endpoint
+func (d *Dispatcher) Get(id string) (*Webhook, error) {
+	row, err := d.row(id)
+	if err != nil {
+		return nil, err
+	}
+	w := row.webhook()
+	return &w, nil
+}
+
+// Create registers an endpoint and returns it with its signing secret, which is not
+// returned again
+func (d *Dispatcher) Create(input Input, userID string) (*Webhook, string, error) {
+	if input.Name == nil || *input.Name == "" || input.URL == nil {
+		return nil, "", ErrMissingFields
+	}
+	if err := validate(input); err != nil {
+		return nil, "", err
+	}
+
+	secret, encrypted, err := d.newSecret()
+	if err != nil {
+		return nil, "", err
+	}
+	events, _ := json.Marshal(nonNil(input.Events))
+	data := map[string]interface{}{
+		"name":             *input.Name,
+		"url":              *input.URL,
+		"encrypted_secret": encrypted,
+		"events":           string(events),
+		"active":           input.Active == nil || *input.Active,
+	}
+	if userID != "" {
+		data["created_by"] = userID
+	}
+
+	result, err := d.db.Insert("webhooks", data)
+	if err != nil {
+		return nil, "", err
+	}
+	var created []webhookRow
+	if err := json.Unmarshal(result, &created); err != nil || len(created) == 0 {
+		return nil, "", errors.New("failed to read created webhook")
+	}
+	w := created[0].webhook()
+	return &w, secret, nil
+}
+
+// Update changes the given fields of an endpoint
+func (d *Dispatcher) Update(id string, input Input) (*Webhook, error) {
+	if _, err := d.row(id); err != nil {
+		return nil, err
+	}
+	if err := validate(input); err != nil {
+		return nil, err
+	}
+
+	data := map[string]interface{}{"updated_at": time.Now()}
+	if input.Name != nil && *input.Name != "" {
+		data["name"] = *input.Name
+	}
+	if input.URL != nil {
+		data["url"] = *input.URL
+	}
+	if input.Events != nil {
+		events, _ := json.Marshal(input.Events)
+		data["events"] = string(events)
+	}
+	if input.Active != nil {
+		data["active"] = *input.Active
+	}
+	if _, err := d.db.Update("webhooks", "id", id, data); err != nil {
+		return nil, err
+	}
+	return d.Get(id)
+}
+
+// RotateSecret replaces the signing secret of an endpoint and returns the new one
+func (d *Dispatcher) RotateSecret(id string) (string, error) {
+	if _, err := d.row(id); err != nil {
+		return "", err
+	}
+	secret, encrypted, err := d.newSecret()
+	if err != nil {
+		return "", err
+	}
+	_, err = d.db.Update("webhooks", "id", id, map[string]interface{}{
+		"encrypted_secret": encrypted,
+		"updated_at":       time.Now(),
+	})
+	return secret, err
+}
+
+// Delete removes an endpoint with its delivery history
+func (d *Dispatcher) Delete(id string) error {
+	if _, err := d.row(id); err != nil {
+		return err
+	}
+	return d.db.Delete("webhooks", "id", id)
+}
+
+// Publish queues an event for every active endpoint subscribed to it. id identifies the
+// event to receivers and stays the same in retries and replays.
+func (d *Dispatcher) Publish(id, event string, occurredAt time.Time, data interface{}) error {
+	var rows []webhookRow
+	if err := d.db.From("webhooks").Select("id, events").Eq("active", true).Execute(&rows); err != nil {
+		return err
+	}
+
+	var targets []string
+	for _, row := range rows {
+		if row.subscribed(event) {
+			targets = append(targets, row.ID)
+		}
+	}
+	if len(targets) == 0 {
+		return nil
+	}
+
+	payload := Payload{ID: id, Event: event, OccurredAt: occurredAt.UTC(), Data: data}
+	_, err := d.enqueue(payload, targets...)
+	return err
+}
+
+// Ping queues a test event for an endpoint, whether or not it is active
+func (d *Dispatcher) Ping(id string) (*Delivery, error) {
+	if _, err := d.row(id); err != nil {
+		return nil, err
+	}
+	deliveries, err := d.enqueue(newPayload(EventPing, map[string]interface{}{"webhook_id": id}), id)
+	if err != nil {
+		return nil, err
+	}
+	return &deliveries[0], nil
+}
+
+func (d *Dispatcher) row(id string) (*webhookRow, error) {
+	var rows []webhookRow
+	if err := d.db.From("webhooks").Select("*").Eq("id", id).Limit(1).Execute(&rows); err != nil {
+		return nil, err
+	}
+	if len(rows) == 0 {
+		return nil, ErrNotFound
+	}
+	return &rows[0], nil
+}
+
+// enqueue stores a pending delivery of payload for each endpoint
+func (d *Dispatcher) enqueue(payload Payload, webhookIDs ...string) ([]Delivery, error) {
+	body, err := json.Marshal(payload)
+	if err != nil {
+		return nil, err
+	}
+
+	var deliveries []Delivery
+	err = d.db.WithTx(context.Background(), func(tx database.DBClient) error {
+		for _, id := range webhookIDs {
+			result, err := tx.Insert("webhook_deliveries", map[string]interface{}{
+				"webhook_id":      id,
+				"event_id":        payload.ID,
+				"event":           payload.Event,
+				"payload":         string(body),
+				"status":          StatusPending,
+				"next_attempt_at": time.Now(),
+			})
+			if err != nil {
+				return err
+			}
+			var created []deliveryRow
+			if err := json.Unmarshal(result, &created); err != nil || len(created) == 0 {
+				return errors.New("failed to read queued delivery")
+			}
+			deliveries = append(deliveries, created[0].delivery())
+		}
+		return nil
+	})
+	return deliveries, err
+}
+
+func newPayload(event string, data interface{}) Payload {
+	return Payload{
+		ID:         uuid.New().String(),
+		Event:      event,
+		OccurredAt: time.Now().UTC(),
+		Data:       data,
+	}
+}
+
+// newSecret generates a signing secret and its encrypted form for storage
+func (d *Dispatcher) newSecret() (string, string, error) {
+	key := make([]byte, 32)
+	if _, err := rand.Read(key); err != nil {
+		return "", "", err
+	}
+	secret := "whsec_" + hex.EncodeToString(key)
+	encrypted, err := utils.EncryptPassword(secret, d.secretKey)
+	if err != nil {
+		return "", "", err
+	}
+	return secret, encrypted, nil
+}
+
+func validate(input Input) error {
+	if input.URL != nil {
+		u, err := url.Parse(*input.URL)
+		if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+			return ErrInvalidURL
+		}
+	}
+	for _, event := range input.Events {
+		if event != AllEvents && !slices.Contains(Events, event) {
+			return fmt.Errorf("%w: %s", ErrUnknownEvent, event)
+		}
+	}
+	return nil
+}
+
+func nonNil(events []string) []string {
+	if events == nil {
+		return []string{}
+	}
+	return events
+}
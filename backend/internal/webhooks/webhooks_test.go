@@ -0,0 +1,79 @@
+package webhooks
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestSign(t *testing.T) {
+	body := []byte(`{"id":"evt_1","event":"ping"}`)
+	tests := []struct {
+		secret    string
+		timestamp int64
+		body      []byte
+		want      string
+	}{
+		{"whsec_test", 1700000000, body, "sha256=21be02980bdc406710677f86bd02cd3b81c0453943d2e471b9d2cab22e7d28f7"},
+		{"whsec_other", 1700000000, body, "sha256=334bede68cbf06d7c1d64483604be9ec000fb59f633430ff9af208862f9379dc"},
+		{"key", 0, nil, "sha256=85841b4efc3cd7776c3c8f9b7cca9e281c550e5d19889d78e9e669c6337f000d"},
+	}
+	for _, tt := range tests {
+		if got := Sign(tt.secret, tt.timestamp, tt.body); got != tt.want {
+			t.Errorf("Sign(%q, %d, %q) = %s, want %s", tt.secret, tt.timestamp, tt.body, got, tt.want)
+		}
+	}
+
+	// The timestamp is signed, so a replayed body with a new timestamp does not verify
+	if Sign("whsec_test", 1700000001, body) == tests[0].want {
+		t.Error("signature does not depend on the timestamp")
+	}
+}
+
+func TestSubscribed(t *testing.T) {
+	tests := []struct {
+		events string
+		event  string
+		want   bool
+	}{
+		{`["task.created"]`, EventTaskCreated, true},
+		{`["task.created"]`, EventTaskStatusChanged, false},
+		{`["*"]`, EventMeetingAnalyzed, true},
+		{`["ticket.sla_breached","*"]`, EventTaskCreated, true},
+		{`[]`, EventTaskCreated, false},
+		{``, EventTaskCreated, false},
+	}
+	for _, tt := range tests {
+		row := webhookRow{Events: tt.events}
+		if got := row.subscribed(tt.event); got != tt.want {
+			t.Errorf("subscribed(%s) with events %s = %v, want %v", tt.event, tt.events, got, tt.want)
+		}
+	}
+}
+
+func TestValidate(t *testing.T) {
+	str := func(s string) *string { return &s }
+	tests := []struct {
+		name  string
+		input Input
+		want  error
+	}{
+		{"empty update", Input{}, nil},
+		{"https", Input{URL: str("https://example.com/hooks")}, nil},
+		{"http with port", Input{URL: str("http://10.0.0.5:8080/hook")}, nil},
+		{"relative url", Input{URL: str("/hooks")}, ErrInvalidURL},
+		{"no host", Input{URL: str("https://")}, ErrInvalidURL},
+		{"other scheme", Input{URL: str("ftp://example.com")}, ErrInvalidURL},
+		{"unparsable", Input{URL: str("http://[::1")}, ErrInvalidURL},
+		{"known events", Input{Events: []string{EventTaskCreated, EventTicketSLABreached}}, nil},
+		{"all events", Input{Events: []string{AllEvents}}, nil},
+		{"ping is not subscribable", Input{Events: []string{EventPing}}, ErrUnknownEvent},
+		{"unknown event", Input{Events: []string{"task.deleted"}}, ErrUnknownEvent},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := validate(tt.input); !errors.Is(err, tt.want) {
+				t.Errorf("validate() = %v, want %v", err, tt.want)
+			}
+		})
+	}
+}
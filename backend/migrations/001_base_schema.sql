@@ -0,0 +1,237 @@
+-- Base schema: the core tables that later migrations extend
+-- Built from _legacy/backend/schema.sql and _legacy/backend/migrations/001_ad_exchange_integration.sql,
+-- which were applied by hand before migrations were tracked. Existing databases record this
+-- version with `server migrate baseline`; new ones get the tables from here.
+-- Legacy objects the backend no longer uses (task_links, telegram_users, user_sessions,
+-- org_tree, update_overdue_agreements) and the Supabase RLS policies are not recreated.
+
+-- Supabase provides auth.role(), which the RLS policies of later migrations call.
+-- Plain PostgreSQL gets an equivalent that reads the role claim of the request.
+DO $$
+BEGIN
+    IF NOT EXISTS (
+        SELECT 1 FROM pg_proc p JOIN pg_namespace n ON n.oid = p.pronamespace
+        WHERE n.nspname = 'auth' AND p.proname = 'role'
+    ) THEN
+        CREATE SCHEMA IF NOT EXISTS auth;
+        CREATE FUNCTION auth.role() RETURNS TEXT
+            LANGUAGE sql STABLE
+            AS $fn$ SELECT current_setting('request.jwt.claim.role', true) $fn$;
+    END IF;
+END $$;
+
+-- ============ CORE TABLES ============
+
+CREATE TABLE IF NOT EXISTS employees (
+    id UUID DEFAULT gen_random_uuid() PRIMARY KEY,
+    name VARCHAR(255) NOT NULL,
+    position VARCHAR(255) NOT NULL,
+    meeting_frequency VARCHAR(50) DEFAULT 'weekly',
+    meeting_day VARCHAR(20),
+    development_priorities TEXT,
+    -- Active Directory
+    email VARCHAR(255) UNIQUE,
+    ad_dn TEXT,
+    ad_login VARCHAR(100),
+    department VARCHAR(255),
+    manager_id UUID REFERENCES employees(id),
+    manager_dn TEXT,
+    photo_base64 TEXT,
+    phone VARCHAR(50),
+    mobile VARCHAR(50),
+    last_ad_sync TIMESTAMP WITH TIME ZONE,
+    created_at TIMESTAMP WITH TIME ZONE DEFAULT NOW()
+);
+
+CREATE TABLE IF NOT EXISTS projects (
+    id UUID DEFAULT gen_random_uuid() PRIMARY KEY,
+    name VARCHAR(255) NOT NULL,
+    description TEXT,
+    status VARCHAR(50) DEFAULT 'active' CHECK (status IN ('active', 'completed', 'on_hold', 'cancelled')),
+    start_date DATE,
+    end_date DATE,
+    created_at TIMESTAMP WITH TIME ZONE DEFAULT NOW()
+);
+
+CREATE TABLE IF NOT EXISTS meeting_categories (
+    id UUID DEFAULT gen_random_uuid() PRIMARY KEY,
+    code VARCHAR(50) UNIQUE NOT NULL,
+    name VARCHAR(100) NOT NULL,
+    description TEXT,
+    prompt_template TEXT,
+    analysis_fields JSONB,
+    is_system BOOLEAN DEFAULT false
+);
+
+INSERT INTO meeting_categories (code, name, description, is_system) VALUES
+    ('one_on_one', '1-на-1', 'Индивидуальная встреча руководителя с сотрудником', true),
+    ('team_meeting', 'Совещание', 'Командное совещание по проекту или задачам', true),
+    ('planning', 'Планирование', 'Планирование спринта/проекта', true),
+    ('retro', 'Ретроспектива', 'Анализ прошедшего периода, уроки', true),
+    ('kickoff', 'Kickoff', 'Старт проекта или инициативы', true),
+    ('interview', 'Интервью', 'Собеседование с кандидатом', true),
+    ('status', 'Статус-митинг', 'Регулярный статус по проекту', true),
+    ('demo', 'Демо', 'Демонстрация результатов', true)
+ON CONFLICT (code) DO NOTHING;
+
+CREATE TABLE IF NOT EXISTS meetings (
+    id UUID DEFAULT gen_random_uuid() PRIMARY KEY,
+    title VARCHAR(500),
+    employee_id UUID REFERENCES employees(id) ON DELETE SET NULL,
+    project_id UUID REFERENCES projects(id) ON DELETE SET NULL,
+    category_id UUID REFERENCES meeting_categories(id) ON DELETE SET NULL,
+    date DATE NOT NULL,
+    duration_minutes INTEGER,
+    duration_seconds INTEGER,
+    mood_score INTEGER CHECK (mood_score >= 1 AND mood_score <= 10),
+    -- Transcripts
+    transcript TEXT,
+    transcript_whisper TEXT,
+    transcript_yandex TEXT,
+    transcript_merged TEXT,
+    -- Analysis
+    summary TEXT,
+    analysis JSONB,
+    audio_url TEXT,
+    -- Exchange
+    exchange_id VARCHAR(500),
+    exchange_data JSONB,
+    location VARCHAR(500),
+    start_time TIMESTAMP WITH TIME ZONE,
+    end_time TIMESTAMP WITH TIME ZONE,
+    organizer_id UUID REFERENCES employees(id),
+    is_recurring BOOLEAN DEFAULT false,
+    recurrence_pattern JSONB,
+    last_exchange_sync TIMESTAMP WITH TIME ZONE,
+    created_at TIMESTAMP WITH TIME ZONE DEFAULT NOW()
+);
+
+CREATE TABLE IF NOT EXISTS meeting_participants (
+    id UUID DEFAULT gen_random_uuid() PRIMARY KEY,
+    meeting_id UUID REFERENCES meetings(id) ON DELETE CASCADE,
+    employee_id UUID REFERENCES employees(id) ON DELETE CASCADE,
+    created_at TIMESTAMP WITH TIME ZONE DEFAULT NOW(),
+    UNIQUE(meeting_id, employee_id)
+);
+
+CREATE TABLE IF NOT EXISTS agreements (
+    id UUID DEFAULT gen_random_uuid() PRIMARY KEY,
+    meeting_id UUID REFERENCES meetings(id) ON DELETE CASCADE,
+    task TEXT NOT NULL,
+    responsible VARCHAR(255) NOT NULL,
+    deadline DATE,
+    status VARCHAR(50) DEFAULT 'pending' CHECK (status IN ('pending', 'completed', 'overdue', 'cancelled')),
+    created_at TIMESTAMP WITH TIME ZONE DEFAULT NOW()
+);
+
+-- ============ TASK MANAGEMENT ============
+
+CREATE TABLE IF NOT EXISTS tags (
+    id UUID DEFAULT gen_random_uuid() PRIMARY KEY,
+    name VARCHAR(50) NOT NULL UNIQUE,
+    color VARCHAR(20) DEFAULT 'gray',
+    is_system BOOLEAN DEFAULT false,
+    created_at TIMESTAMP WITH TIME ZONE DEFAULT NOW()
+);
+
+INSERT INTO tags (name, color, is_system) VALUES
+    ('срочно', 'red', true),
+    ('важно', 'orange', true),
+    ('блокер', 'red', true),
+    ('баг', 'red', true),
+    ('улучшение', 'blue', true),
+    ('документация', 'purple', true)
+ON CONFLICT (name) DO NOTHING;
+
+CREATE TABLE IF NOT EXISTS tasks (
+    id UUID DEFAULT gen_random_uuid() PRIMARY KEY,
+    title VARCHAR(500) NOT NULL,
+    description TEXT,
+    status VARCHAR(50) DEFAULT 'backlog' CHECK (status IN ('backlog', 'todo', 'in_progress', 'review', 'done')),
+    priority INTEGER DEFAULT 3 CHECK (priority >= 1 AND priority <= 5),
+    flag_color VARCHAR(20),
+    assignee_id UUID REFERENCES employees(id) ON DELETE SET NULL,
+    co_assignee_id UUID REFERENCES employees(id) ON DELETE SET NULL,
+    creator_id UUID REFERENCES employees(id) ON DELETE SET NULL,
+    meeting_id UUID REFERENCES meetings(id) ON DELETE SET NULL,
+    project_id UUID REFERENCES projects(id) ON DELETE SET NULL,
+    parent_id UUID REFERENCES tasks(id) ON DELETE SET NULL,
+    is_epic BOOLEAN DEFAULT false,
+    due_date DATE,
+    original_due_date DATE,
+    completed_at TIMESTAMP WITH TIME ZONE,
+    created_at TIMESTAMP WITH TIME ZONE DEFAULT NOW(),
+    updated_at TIMESTAMP WITH TIME ZONE DEFAULT NOW()
+);
+
+CREATE TABLE IF NOT EXISTS task_tags (
+    id UUID DEFAULT gen_random_uuid() PRIMARY KEY,
+    task_id UUID REFERENCES tasks(id) ON DELETE CASCADE,
+    tag_id UUID REFERENCES tags(id) ON DELETE CASCADE,
+    created_at TIMESTAMP WITH TIME ZONE DEFAULT NOW(),
+    UNIQUE(task_id, tag_id)
+);
+
+CREATE TABLE IF NOT EXISTS task_comments (
+    id UUID DEFAULT gen_random_uuid() PRIMARY KEY,
+    task_id UUID REFERENCES tasks(id) ON DELETE CASCADE,
+    author_id UUID REFERENCES employees(id) ON DELETE SET NULL,
+    content TEXT NOT NULL,
+    created_at TIMESTAMP WITH TIME ZONE DEFAULT NOW()
+);
+
+CREATE TABLE IF NOT EXISTS task_history (
+    id UUID DEFAULT gen_random_uuid() PRIMARY KEY,
+    task_id UUID REFERENCES tasks(id) ON DELETE CASCADE,
+    field_name VARCHAR(100) NOT NULL,
+    old_value TEXT,
+    new_value TEXT,
+    changed_by UUID REFERENCES employees(id) ON DELETE SET NULL,
+    created_at TIMESTAMP WITH TIME ZONE DEFAULT NOW()
+);
+
+-- ============ INDEXES ============
+
+CREATE INDEX IF NOT EXISTS idx_employees_email ON employees(email);
+CREATE INDEX IF NOT EXISTS idx_employees_ad_dn ON employees(ad_dn);
+CREATE INDEX IF NOT EXISTS idx_employees_ad_login ON employees(ad_login);
+CREATE INDEX IF NOT EXISTS idx_employees_manager_id ON employees(manager_id);
+
+CREATE INDEX IF NOT EXISTS idx_meetings_employee_id ON meetings(employee_id);
+CREATE INDEX IF NOT EXISTS idx_meetings_project_id ON meetings(project_id);
+CREATE INDEX IF NOT EXISTS idx_meetings_category_id ON meetings(category_id);
+CREATE INDEX IF NOT EXISTS idx_meetings_date ON meetings(date DESC);
+CREATE INDEX IF NOT EXISTS idx_meetings_exchange_id ON meetings(exchange_id);
+CREATE INDEX IF NOT EXISTS idx_meetings_organizer_id ON meetings(organizer_id);
+CREATE INDEX IF NOT EXISTS idx_meetings_start_time ON meetings(start_time);
+
+CREATE INDEX IF NOT EXISTS idx_agreements_meeting_id ON agreements(meeting_id);
+CREATE INDEX IF NOT EXISTS idx_agreements_status ON agreements(status);
+CREATE INDEX IF NOT EXISTS idx_agreements_deadline ON agreements(deadline);
+
+CREATE INDEX IF NOT EXISTS idx_tasks_assignee_id ON tasks(assignee_id);
+CREATE INDEX IF NOT EXISTS idx_tasks_project_id ON tasks(project_id);
+CREATE INDEX IF NOT EXISTS idx_tasks_status ON tasks(status);
+CREATE INDEX IF NOT EXISTS idx_tasks_parent_id ON tasks(parent_id);
+CREATE INDEX IF NOT EXISTS idx_tasks_due_date ON tasks(due_date);
+
+CREATE INDEX IF NOT EXISTS idx_meeting_participants_meeting ON meeting_participants(meeting_id);
+CREATE INDEX IF NOT EXISTS idx_meeting_participants_employee ON meeting_participants(employee_id);
+
+CREATE INDEX IF NOT EXISTS idx_task_history_task_id ON task_history(task_id);
+
+-- ============ TRIGGERS ============
+
+CREATE OR REPLACE FUNCTION update_task_timestamp()
+RETURNS TRIGGER AS $$
+BEGIN
+    NEW.updated_at = NOW();
+    RETURN NEW;
+END;
+$$ LANGUAGE plpgsql;
+
+DROP TRIGGER IF EXISTS trigger_update_task_timestamp ON tasks;
+CREATE TRIGGER trigger_update_task_timestamp
+    BEFORE UPDATE ON tasks
+    FOR EACH ROW
+    EXECUTE FUNCTION update_task_timestamp();
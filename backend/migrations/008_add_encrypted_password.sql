@@ -1,3 +1,4 @@
+-- Ported from supabase/migrations/20260116125924_add_encrypted_password.sql
 -- Add encrypted_password column to employees table for storing user EWS passwords
 ALTER TABLE employees ADD COLUMN IF NOT EXISTS encrypted_password TEXT;
 
@@ -0,0 +1,41 @@
+-- Asynchronous meeting processing jobs
+-- Upload returns a job ID; each stage (transcription, merge, analysis, save)
+-- is stored and retried independently by the backend worker.
+
+CREATE TABLE IF NOT EXISTS meeting_jobs (
+    id UUID DEFAULT gen_random_uuid() PRIMARY KEY,
+    status VARCHAR(20) NOT NULL DEFAULT 'queued' CHECK (status IN ('queued', 'running', 'completed', 'failed')),
+    current_stage VARCHAR(50),
+    progress INTEGER NOT NULL DEFAULT 0,
+    params JSONB NOT NULL DEFAULT '{}'::jsonb,  -- category_code, employee_id, project_id, meeting_date, title, participant_ids
+    audio_path TEXT NOT NULL,
+    audio_storage VARCHAR(20) NOT NULL DEFAULT 'local' CHECK (audio_storage IN ('local', 'minio')),
+    meeting_id UUID REFERENCES meetings(id) ON DELETE SET NULL,
+    error TEXT,
+    created_by UUID REFERENCES employees(id),
+    created_at TIMESTAMPTZ DEFAULT NOW(),
+    updated_at TIMESTAMPTZ DEFAULT NOW(),
+    completed_at TIMESTAMPTZ
+);
+
+CREATE TABLE IF NOT EXISTS meeting_job_stages (
+    id UUID DEFAULT gen_random_uuid() PRIMARY KEY,
+    job_id UUID NOT NULL REFERENCES meeting_jobs(id) ON DELETE CASCADE,
+    stage VARCHAR(50) NOT NULL,
+    position INTEGER NOT NULL,
+    status VARCHAR(20) NOT NULL DEFAULT 'pending' CHECK (status IN ('pending', 'running', 'completed', 'failed', 'skipped')),
+    attempts INTEGER NOT NULL DEFAULT 0,
+    output TEXT,
+    error TEXT,
+    started_at TIMESTAMPTZ,
+    finished_at TIMESTAMPTZ,
+    UNIQUE (job_id, stage)
+);
+
+CREATE INDEX IF NOT EXISTS idx_meeting_jobs_status ON meeting_jobs(status);
+CREATE INDEX IF NOT EXISTS idx_meeting_jobs_created_by ON meeting_jobs(created_by);
+CREATE INDEX IF NOT EXISTS idx_meeting_job_stages_job ON meeting_job_stages(job_id);
+
+COMMENT ON TABLE meeting_jobs IS 'Background processing of uploaded meeting recordings';
+COMMENT ON COLUMN meeting_jobs.audio_storage IS 'local - file on backend disk, minio - object in media bucket';
+COMMENT ON COLUMN meeting_job_stages.output IS 'Stage result: transcript text, analysis JSON or created meeting ID';
@@ -0,0 +1,33 @@
+-- Speaker diarization: timestamped transcript segments per meeting
+-- Speaker labels ("A", "B", ...) come from the STT engine and are mapped to employees separately
+
+CREATE TABLE IF NOT EXISTS meeting_transcript_segments (
+    id UUID DEFAULT gen_random_uuid() PRIMARY KEY,
+    meeting_id UUID NOT NULL REFERENCES meetings(id) ON DELETE CASCADE,
+    position INTEGER NOT NULL,
+    speaker_label VARCHAR(50),
+    start_seconds DOUBLE PRECISION NOT NULL,
+    end_seconds DOUBLE PRECISION NOT NULL,
+    text TEXT NOT NULL,
+    created_at TIMESTAMPTZ DEFAULT NOW()
+);
+
+-- Mapping of speaker labels to meeting participants
+CREATE TABLE IF NOT EXISTS meeting_speakers (
+    id UUID DEFAULT gen_random_uuid() PRIMARY KEY,
+    meeting_id UUID NOT NULL REFERENCES meetings(id) ON DELETE CASCADE,
+    speaker_label VARCHAR(50) NOT NULL,
+    employee_id UUID REFERENCES employees(id) ON DELETE SET NULL,
+    created_at TIMESTAMPTZ DEFAULT NOW(),
+    UNIQUE (meeting_id, speaker_label)
+);
+
+-- Link agreements back to the moment they were made
+ALTER TABLE agreements ADD COLUMN IF NOT EXISTS source_offset_seconds DOUBLE PRECISION;
+
+CREATE INDEX IF NOT EXISTS idx_transcript_segments_meeting ON meeting_transcript_segments(meeting_id, position);
+CREATE INDEX IF NOT EXISTS idx_meeting_speakers_meeting ON meeting_speakers(meeting_id);
+
+COMMENT ON TABLE meeting_transcript_segments IS 'Diarized transcript: who spoke when';
+COMMENT ON COLUMN meeting_transcript_segments.start_seconds IS 'Offset from recording start';
+COMMENT ON COLUMN agreements.source_offset_seconds IS 'Offset in the recording where the agreement was made';
@@ -0,0 +1,24 @@
+-- Admin-editable analysis prompts
+-- Each save creates a new version; exactly one version per category is active.
+-- Categories without an active version use the prompt built into the backend.
+
+CREATE TABLE IF NOT EXISTS prompt_templates (
+    id UUID DEFAULT gen_random_uuid() PRIMARY KEY,
+    category_code VARCHAR(50) NOT NULL,  -- meeting_categories.code or 'default'
+    version INTEGER NOT NULL,
+    template TEXT NOT NULL,
+    is_active BOOLEAN NOT NULL DEFAULT false,
+    comment TEXT,
+    created_by UUID REFERENCES employees(id),
+    created_at TIMESTAMPTZ DEFAULT NOW(),
+    UNIQUE (category_code, version)
+);
+
+CREATE UNIQUE INDEX IF NOT EXISTS idx_prompt_templates_active ON prompt_templates(category_code) WHERE is_active;
+
+-- Prompt that produced meetings.analysis (NULL - built-in prompt)
+ALTER TABLE meetings ADD COLUMN IF NOT EXISTS prompt_template_id UUID REFERENCES prompt_templates(id) ON DELETE SET NULL;
+ALTER TABLE meetings ADD COLUMN IF NOT EXISTS prompt_version INTEGER;
+
+COMMENT ON TABLE prompt_templates IS 'Versioned analysis prompt templates (Go text/template over AnalysisContext)';
+COMMENT ON COLUMN meetings.prompt_version IS 'prompt_templates.version used for analysis, NULL if built-in prompt was used';
@@ -0,0 +1,28 @@
+-- Re-analysis of stored meetings
+-- Before a meeting is analyzed again its current analysis is archived as a revision,
+-- so dossier and analytics can compare results of different prompts and models.
+
+ALTER TABLE meetings ADD COLUMN IF NOT EXISTS analysis_provider VARCHAR(50);
+ALTER TABLE meetings ADD COLUMN IF NOT EXISTS analysis_model VARCHAR(100);
+
+CREATE TABLE IF NOT EXISTS meeting_analysis_revisions (
+    id UUID DEFAULT gen_random_uuid() PRIMARY KEY,
+    meeting_id UUID NOT NULL REFERENCES meetings(id) ON DELETE CASCADE,
+    revision INTEGER NOT NULL,  -- 1 - first analysis of the meeting
+    analysis JSONB,
+    summary TEXT,
+    mood_score INTEGER,
+    prompt_template_id UUID REFERENCES prompt_templates(id) ON DELETE SET NULL,
+    prompt_version INTEGER,
+    analysis_provider VARCHAR(50),
+    analysis_model VARCHAR(100),
+    reason TEXT,  -- why the meeting was re-analyzed
+    archived_by UUID REFERENCES employees(id),
+    archived_at TIMESTAMPTZ DEFAULT NOW(),
+    UNIQUE (meeting_id, revision)
+);
+
+CREATE INDEX IF NOT EXISTS idx_meeting_analysis_revisions_meeting ON meeting_analysis_revisions(meeting_id);
+
+COMMENT ON TABLE meeting_analysis_revisions IS 'Superseded meeting analyses; current one stays in meetings.analysis';
+COMMENT ON COLUMN meetings.analysis_model IS 'LLM model that produced meetings.analysis';
@@ -0,0 +1,29 @@
+-- Agreement lifecycle: pending -> in_progress -> done / dropped
+-- Each agreement is linked to the task generated from it and kept in sync with it.
+-- Overdue is derived from deadline, not stored as a status.
+
+ALTER TABLE agreements DROP CONSTRAINT IF EXISTS agreements_status_check;
+
+UPDATE agreements SET status = 'done' WHERE status = 'completed';
+UPDATE agreements SET status = 'dropped' WHERE status = 'cancelled';
+UPDATE agreements SET status = 'pending' WHERE status = 'overdue' OR status IS NULL;
+
+ALTER TABLE agreements ADD CONSTRAINT agreements_status_check
+    CHECK (status IN ('pending', 'in_progress', 'done', 'dropped'));
+
+ALTER TABLE agreements ADD COLUMN IF NOT EXISTS task_id UUID REFERENCES tasks(id) ON DELETE SET NULL;
+ALTER TABLE agreements ADD COLUMN IF NOT EXISTS status_changed_at TIMESTAMPTZ;
+ALTER TABLE agreements ADD COLUMN IF NOT EXISTS completed_at TIMESTAMPTZ;
+ALTER TABLE agreements ADD COLUMN IF NOT EXISTS completed_in_meeting_id UUID REFERENCES meetings(id) ON DELETE SET NULL;
+
+-- Link existing agreements to tasks created from them by meeting processing
+UPDATE agreements a SET task_id = t.id
+FROM tasks t
+WHERE a.task_id IS NULL AND t.meeting_id = a.meeting_id AND t.title = a.task;
+
+UPDATE agreements SET completed_at = NOW() WHERE status = 'done' AND completed_at IS NULL;
+
+CREATE INDEX IF NOT EXISTS idx_agreements_task ON agreements(task_id);
+
+COMMENT ON COLUMN agreements.task_id IS 'Task generated from the agreement; status changes are mirrored both ways';
+COMMENT ON COLUMN agreements.completed_in_meeting_id IS 'Later meeting whose analysis confirmed the agreement was fulfilled';
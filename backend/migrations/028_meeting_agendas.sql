@@ -0,0 +1,24 @@
+-- Prepared agendas for upcoming 1-on-1 meetings
+-- Generated on demand or by the scheduler for meetings synced from the Exchange calendar,
+-- then delivered to the organizer by mail and/or Telegram the morning before the meeting.
+
+CREATE TABLE IF NOT EXISTS meeting_agendas (
+    id UUID DEFAULT gen_random_uuid() PRIMARY KEY,
+    meeting_id UUID NOT NULL UNIQUE REFERENCES meetings(id) ON DELETE CASCADE,
+    employee_id UUID REFERENCES employees(id) ON DELETE SET NULL,
+    recipient_id UUID REFERENCES employees(id) ON DELETE SET NULL,  -- who prepares the meeting
+    agenda JSONB NOT NULL,
+    analysis_provider VARCHAR(50),
+    analysis_model VARCHAR(100),
+    generated_by UUID REFERENCES employees(id) ON DELETE SET NULL,  -- NULL - scheduler
+    generated_at TIMESTAMPTZ DEFAULT NOW(),
+    delivered_at TIMESTAMPTZ,
+    delivery_channels VARCHAR(100),  -- e.g. 'email,telegram'
+    delivery_attempts INTEGER DEFAULT 0,
+    delivery_error TEXT
+);
+
+CREATE INDEX IF NOT EXISTS idx_meeting_agendas_undelivered ON meeting_agendas(generated_at) WHERE delivered_at IS NULL;
+
+COMMENT ON TABLE meeting_agendas IS 'AI-prepared agendas for upcoming 1-on-1 meetings';
+COMMENT ON COLUMN meeting_agendas.delivery_attempts IS 'Scheduler stops retrying delivery after a few failed attempts';
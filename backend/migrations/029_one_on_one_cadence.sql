@@ -0,0 +1,39 @@
+-- 1-on-1 cadence enforcement
+-- employees.meeting_frequency (weekly, biweekly, monthly) and meeting_day are checked daily:
+-- for a report whose last 1-on-1 is older than the cadence a free slot is found in Exchange
+-- and either proposed to the manager or booked directly.
+
+CREATE TABLE IF NOT EXISTS one_on_one_proposals (
+    id UUID DEFAULT gen_random_uuid() PRIMARY KEY,
+    employee_id UUID NOT NULL REFERENCES employees(id) ON DELETE CASCADE,
+    manager_id UUID NOT NULL REFERENCES employees(id) ON DELETE CASCADE,
+    start_time TIMESTAMPTZ NOT NULL,
+    end_time TIMESTAMPTZ NOT NULL,
+    status VARCHAR(20) NOT NULL DEFAULT 'proposed'
+        CHECK (status IN ('proposed', 'booked', 'declined', 'expired', 'failed')),
+    last_meeting_date DATE,  -- NULL - no 1-on-1 found
+    due_date DATE NOT NULL,
+    meeting_id UUID REFERENCES meetings(id) ON DELETE SET NULL,
+    exchange_id TEXT,
+    error TEXT,
+    decided_by UUID REFERENCES employees(id) ON DELETE SET NULL,  -- NULL - booked by the scheduler
+    decided_at TIMESTAMPTZ,
+    created_at TIMESTAMPTZ DEFAULT NOW()
+);
+
+CREATE INDEX IF NOT EXISTS idx_one_on_one_proposals_manager ON one_on_one_proposals(manager_id, status);
+CREATE UNIQUE INDEX IF NOT EXISTS idx_one_on_one_proposals_open
+    ON one_on_one_proposals(employee_id) WHERE status = 'proposed';
+
+-- Weekly digests already sent, so a restart does not send them twice
+CREATE TABLE IF NOT EXISTS one_on_one_digests (
+    id UUID DEFAULT gen_random_uuid() PRIMARY KEY,
+    manager_id UUID NOT NULL REFERENCES employees(id) ON DELETE CASCADE,
+    week_start DATE NOT NULL,
+    overdue_count INTEGER NOT NULL DEFAULT 0,
+    sent_at TIMESTAMPTZ DEFAULT NOW(),
+    UNIQUE (manager_id, week_start)
+);
+
+COMMENT ON TABLE one_on_one_proposals IS 'Slots found for overdue 1-on-1s: proposed to the manager or booked in Exchange';
+COMMENT ON TABLE one_on_one_digests IS 'Weekly overdue 1-on-1 digests sent to managers';
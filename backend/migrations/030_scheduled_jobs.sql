@@ -0,0 +1,42 @@
+-- Background job scheduler
+-- Jobs are registered by the backend on startup; the row keeps the schedule (editable by admins),
+-- pause flag and last run status. Replicas coordinate through pg_try_advisory_lock, so only one
+-- of them executes a job at a time.
+
+CREATE TABLE IF NOT EXISTS scheduled_jobs (
+    id UUID DEFAULT gen_random_uuid() PRIMARY KEY,
+    name VARCHAR(100) NOT NULL UNIQUE,
+    description TEXT,
+    schedule VARCHAR(100) NOT NULL,  -- cron expression in TIMEZONE
+    paused BOOLEAN NOT NULL DEFAULT false,
+    next_run_at TIMESTAMPTZ,
+    last_run_at TIMESTAMPTZ,
+    last_finished_at TIMESTAMPTZ,
+    last_status VARCHAR(20) CHECK (last_status IN ('running', 'success', 'failed')),
+    last_error TEXT,
+    last_duration_ms BIGINT,
+    last_instance VARCHAR(255),
+    created_at TIMESTAMPTZ DEFAULT NOW(),
+    updated_at TIMESTAMPTZ DEFAULT NOW()
+);
+
+CREATE TABLE IF NOT EXISTS scheduled_job_runs (
+    id UUID DEFAULT gen_random_uuid() PRIMARY KEY,
+    job_name VARCHAR(100) NOT NULL REFERENCES scheduled_jobs(name) ON DELETE CASCADE,
+    trigger VARCHAR(20) NOT NULL DEFAULT 'schedule' CHECK (trigger IN ('schedule', 'manual')),
+    triggered_by UUID REFERENCES employees(id) ON DELETE SET NULL,
+    instance VARCHAR(255) NOT NULL,
+    status VARCHAR(20) NOT NULL DEFAULT 'running' CHECK (status IN ('running', 'success', 'failed')),
+    error TEXT,
+    started_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    finished_at TIMESTAMPTZ,
+    duration_ms BIGINT
+);
+
+CREATE INDEX IF NOT EXISTS idx_scheduled_job_runs_job ON scheduled_job_runs(job_name, started_at DESC);
+
+-- SLA breach notifications are sent once per ticket
+ALTER TABLE service_tickets ADD COLUMN IF NOT EXISTS sla_breach_notified_at TIMESTAMPTZ;
+
+COMMENT ON TABLE scheduled_jobs IS 'Background job definitions and last run status';
+COMMENT ON TABLE scheduled_job_runs IS 'History of background job runs';
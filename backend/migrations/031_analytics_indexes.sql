@@ -0,0 +1,7 @@
+-- Indexes for aggregate analytics queries
+-- The dashboard walks the org tree with a recursive CTE over employees.manager_id
+-- and groups meetings per employee and date.
+
+CREATE INDEX IF NOT EXISTS idx_employees_manager_id ON employees(manager_id);
+CREATE INDEX IF NOT EXISTS idx_meetings_employee_date ON meetings(employee_id, date);
+CREATE INDEX IF NOT EXISTS idx_tasks_assignee_status ON tasks(assignee_id, status);
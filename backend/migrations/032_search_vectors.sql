@@ -0,0 +1,30 @@
+-- Full-text search for tasks, service desk tickets and improvement requests
+-- List endpoints filter on search_vector with websearch_to_tsquery('russian', q);
+-- the configuration must match the one used here.
+
+ALTER TABLE tasks ADD COLUMN IF NOT EXISTS search_vector tsvector
+    GENERATED ALWAYS AS (
+        setweight(to_tsvector('russian', coalesce(title, '')), 'A') ||
+        setweight(to_tsvector('russian', coalesce(description, '')), 'B')
+    ) STORED;
+
+ALTER TABLE service_tickets ADD COLUMN IF NOT EXISTS search_vector tsvector
+    GENERATED ALWAYS AS (
+        setweight(to_tsvector('russian', coalesce(number, '') || ' ' || coalesce(title, '')), 'A') ||
+        setweight(to_tsvector('russian', coalesce(description, '')), 'B')
+    ) STORED;
+
+ALTER TABLE improvement_requests ADD COLUMN IF NOT EXISTS search_vector tsvector
+    GENERATED ALWAYS AS (
+        setweight(to_tsvector('russian', coalesce(number, '') || ' ' || coalesce(title, '')), 'A') ||
+        setweight(to_tsvector('russian', coalesce(description, '') || ' ' || coalesce(business_value, '') || ' ' || coalesce(expected_effect, '')), 'B')
+    ) STORED;
+
+CREATE INDEX IF NOT EXISTS idx_tasks_search ON tasks USING GIN (search_vector);
+CREATE INDEX IF NOT EXISTS idx_service_tickets_search ON service_tickets USING GIN (search_vector);
+CREATE INDEX IF NOT EXISTS idx_improvement_requests_search ON improvement_requests USING GIN (search_vector);
+
+-- Keyset pagination of list endpoints orders by (created_at, id)
+CREATE INDEX IF NOT EXISTS idx_tasks_created ON tasks(created_at DESC, id DESC);
+CREATE INDEX IF NOT EXISTS idx_service_tickets_created ON service_tickets(created_at DESC, id DESC);
+CREATE INDEX IF NOT EXISTS idx_improvement_requests_created ON improvement_requests(created_at DESC, id DESC);
@@ -0,0 +1,36 @@
+-- Audit trail for every mutating API call
+-- Entries are written by the backend in one chain: seq increases by one, prev_hash is the
+-- hash of the previous entry and hash covers the entry itself (see internal/audit), so
+-- modified or deleted rows are detected by GET /api/v1/admin/audit-logs/verify.
+-- Rows written before this migration have no seq and are outside the chain.
+
+-- Entity IDs are not always UUIDs (e.g. system setting keys)
+ALTER TABLE audit_logs ALTER COLUMN entity_id TYPE TEXT;
+
+-- Entries outlive the employees who made them: the log is append-only, so a foreign key
+-- would block deleting any employee that has ever made a change
+ALTER TABLE audit_logs DROP CONSTRAINT IF EXISTS audit_logs_user_id_fkey;
+
+ALTER TABLE audit_logs ADD COLUMN IF NOT EXISTS request_id VARCHAR(100);
+ALTER TABLE audit_logs ADD COLUMN IF NOT EXISTS method VARCHAR(10);
+ALTER TABLE audit_logs ADD COLUMN IF NOT EXISTS path TEXT;
+ALTER TABLE audit_logs ADD COLUMN IF NOT EXISTS status INTEGER;
+ALTER TABLE audit_logs ADD COLUMN IF NOT EXISTS seq BIGINT;
+ALTER TABLE audit_logs ADD COLUMN IF NOT EXISTS prev_hash VARCHAR(64);
+ALTER TABLE audit_logs ADD COLUMN IF NOT EXISTS hash VARCHAR(64);
+
+CREATE UNIQUE INDEX IF NOT EXISTS idx_audit_logs_seq ON audit_logs(seq);
+CREATE INDEX IF NOT EXISTS idx_audit_logs_request ON audit_logs(request_id);
+
+-- The log is append-only for the application
+CREATE OR REPLACE FUNCTION audit_logs_append_only() RETURNS TRIGGER AS $$
+BEGIN
+    RAISE EXCEPTION 'audit_logs is append-only';
+END;
+$$ LANGUAGE plpgsql;
+
+DROP TRIGGER IF EXISTS trigger_audit_logs_append_only ON audit_logs;
+CREATE TRIGGER trigger_audit_logs_append_only
+    BEFORE UPDATE OR DELETE ON audit_logs
+    FOR EACH ROW
+    EXECUTE FUNCTION audit_logs_append_only();
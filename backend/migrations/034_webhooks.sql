@@ -0,0 +1,54 @@
+-- Outbound webhooks
+-- Admins register endpoints subscribed to domain events (task.created, ticket.sla_breached, ...).
+-- Every event produces a delivery per subscribed endpoint; the backend sends pending deliveries
+-- with an HMAC-SHA256 signature and retries failures with exponential backoff.
+
+CREATE TABLE IF NOT EXISTS webhooks (
+    id UUID DEFAULT gen_random_uuid() PRIMARY KEY,
+    name VARCHAR(255) NOT NULL,
+    url TEXT NOT NULL,
+    encrypted_secret TEXT NOT NULL,          -- HMAC key, AES-GCM encrypted
+    events JSONB NOT NULL DEFAULT '[]',      -- event names, "*" - all events
+    active BOOLEAN NOT NULL DEFAULT true,
+    created_by UUID REFERENCES employees(id) ON DELETE SET NULL,
+    created_at TIMESTAMPTZ DEFAULT NOW(),
+    updated_at TIMESTAMPTZ DEFAULT NOW()
+);
+
+CREATE TABLE IF NOT EXISTS webhook_deliveries (
+    id UUID DEFAULT gen_random_uuid() PRIMARY KEY,
+    webhook_id UUID NOT NULL REFERENCES webhooks(id) ON DELETE CASCADE,
+    event_id UUID NOT NULL,                  -- same for every delivery of one event and its replays
+    event VARCHAR(100) NOT NULL,
+    payload JSONB NOT NULL,
+    status VARCHAR(20) NOT NULL DEFAULT 'pending' CHECK (status IN ('pending', 'success', 'failed')),
+    attempts INTEGER NOT NULL DEFAULT 0,
+    next_attempt_at TIMESTAMPTZ DEFAULT NOW(),
+    last_attempt_at TIMESTAMPTZ,
+    response_status INTEGER,
+    response_body TEXT,
+    error TEXT,
+    replay_of UUID REFERENCES webhook_deliveries(id) ON DELETE SET NULL,
+    created_at TIMESTAMPTZ DEFAULT NOW(),
+    delivered_at TIMESTAMPTZ
+);
+
+CREATE INDEX IF NOT EXISTS idx_webhook_deliveries_due ON webhook_deliveries(next_attempt_at) WHERE status = 'pending';
+CREATE INDEX IF NOT EXISTS idx_webhook_deliveries_webhook ON webhook_deliveries(webhook_id, created_at DESC);
+
+CREATE TABLE IF NOT EXISTS webhook_delivery_attempts (
+    id UUID DEFAULT gen_random_uuid() PRIMARY KEY,
+    delivery_id UUID NOT NULL REFERENCES webhook_deliveries(id) ON DELETE CASCADE,
+    attempt INTEGER NOT NULL,
+    response_status INTEGER,
+    response_body TEXT,
+    error TEXT,
+    duration_ms BIGINT,
+    created_at TIMESTAMPTZ DEFAULT NOW()
+);
+
+CREATE INDEX IF NOT EXISTS idx_webhook_delivery_attempts_delivery ON webhook_delivery_attempts(delivery_id, attempt);
+
+COMMENT ON TABLE webhooks IS 'Outbound webhook endpoints and their event subscriptions';
+COMMENT ON TABLE webhook_deliveries IS 'Events queued for delivery to webhook endpoints';
+COMMENT ON TABLE webhook_delivery_attempts IS 'Log of HTTP requests made for webhook deliveries';
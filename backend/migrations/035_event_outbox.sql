@@ -0,0 +1,24 @@
+-- Durable outbox of the internal domain event bus (EVENT_OUTBOX_ENABLED)
+-- Handlers insert events in the same transaction as the change; the backend relays them
+-- to subscribers in seq order and retries the subscribers that failed.
+
+CREATE TABLE IF NOT EXISTS event_outbox (
+    id UUID PRIMARY KEY,
+    seq BIGSERIAL NOT NULL,
+    name VARCHAR(100) NOT NULL,
+    actor_id UUID,
+    payload JSONB NOT NULL,
+    occurred_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    attempts INTEGER NOT NULL DEFAULT 0,
+    next_attempt_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    completed_subscribers JSONB NOT NULL DEFAULT '[]',  -- subscribers that handled the event
+    last_error TEXT,
+    processed_at TIMESTAMPTZ,                            -- every subscriber succeeded
+    failed_at TIMESTAMPTZ                                -- retries exhausted
+);
+
+CREATE UNIQUE INDEX IF NOT EXISTS idx_event_outbox_seq ON event_outbox(seq);
+CREATE INDEX IF NOT EXISTS idx_event_outbox_due ON event_outbox(seq)
+    WHERE processed_at IS NULL AND failed_at IS NULL;
+
+COMMENT ON TABLE event_outbox IS 'Domain events waiting for or delivered to in-process subscribers';
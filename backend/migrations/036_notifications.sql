@@ -0,0 +1,79 @@
+-- User notifications
+-- Every notification is stored in the recipient's inbox (in-app channel) and queued for
+-- email and Telegram according to the user's preferences for its type. Deliveries falling
+-- into quiet hours wait until they end; digest deliveries are batched into one message a day.
+
+CREATE TABLE IF NOT EXISTS notifications (
+    id UUID DEFAULT gen_random_uuid() PRIMARY KEY,
+    user_id UUID NOT NULL REFERENCES employees(id) ON DELETE CASCADE,
+    type VARCHAR(100) NOT NULL,              -- task.assigned, ticket.sla_breached, ...
+    title TEXT NOT NULL,
+    body TEXT,
+    link TEXT,                               -- frontend route of the subject
+    data JSONB NOT NULL DEFAULT '{}',
+    event_id UUID,                           -- domain event that caused it
+    in_app BOOLEAN NOT NULL DEFAULT true,    -- false - sent by email/Telegram only, hidden in the inbox
+    read_at TIMESTAMPTZ,
+    created_at TIMESTAMPTZ DEFAULT NOW()
+);
+
+CREATE INDEX IF NOT EXISTS idx_notifications_user ON notifications(user_id, created_at DESC) WHERE in_app;
+CREATE INDEX IF NOT EXISTS idx_notifications_unread ON notifications(user_id) WHERE in_app AND read_at IS NULL;
+-- An event delivered again by the outbox does not notify twice
+CREATE UNIQUE INDEX IF NOT EXISTS idx_notifications_event ON notifications(user_id, type, event_id) WHERE event_id IS NOT NULL;
+
+-- Channels per user and notification type; types without a row use the defaults of the type
+CREATE TABLE IF NOT EXISTS notification_preferences (
+    user_id UUID NOT NULL REFERENCES employees(id) ON DELETE CASCADE,
+    type VARCHAR(100) NOT NULL,
+    in_app BOOLEAN NOT NULL DEFAULT true,
+    email BOOLEAN NOT NULL DEFAULT false,
+    telegram BOOLEAN NOT NULL DEFAULT false,
+    digest BOOLEAN NOT NULL DEFAULT false,   -- email/Telegram once a day instead of immediately
+    updated_at TIMESTAMPTZ DEFAULT NOW(),
+    PRIMARY KEY (user_id, type)
+);
+
+CREATE TABLE IF NOT EXISTS notification_settings (
+    user_id UUID PRIMARY KEY REFERENCES employees(id) ON DELETE CASCADE,
+    quiet_hours_start VARCHAR(5),            -- "22:00" in the business timezone, NULL - no quiet hours
+    quiet_hours_end VARCHAR(5),              -- "08:00"
+    digest_hour SMALLINT NOT NULL DEFAULT 9 CHECK (digest_hour BETWEEN 0 AND 23),
+    updated_at TIMESTAMPTZ DEFAULT NOW()
+);
+
+CREATE TABLE IF NOT EXISTS notification_deliveries (
+    id UUID DEFAULT gen_random_uuid() PRIMARY KEY,
+    notification_id UUID NOT NULL REFERENCES notifications(id) ON DELETE CASCADE,
+    user_id UUID NOT NULL REFERENCES employees(id) ON DELETE CASCADE,
+    channel VARCHAR(20) NOT NULL CHECK (channel IN ('email', 'telegram')),
+    digest BOOLEAN NOT NULL DEFAULT false,
+    status VARCHAR(20) NOT NULL DEFAULT 'pending' CHECK (status IN ('pending', 'sent', 'skipped', 'failed')),
+    send_after TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    attempts INTEGER NOT NULL DEFAULT 0,
+    error TEXT,
+    sent_at TIMESTAMPTZ,
+    created_at TIMESTAMPTZ DEFAULT NOW()
+);
+
+CREATE INDEX IF NOT EXISTS idx_notification_deliveries_due ON notification_deliveries(send_after) WHERE status = 'pending';
+
+-- Users who turned off Telegram notifications in the legacy telegram_users table keep them off
+DO $$
+BEGIN
+    IF to_regclass('public.telegram_users') IS NOT NULL THEN
+        INSERT INTO notification_preferences (user_id, type, in_app, email, telegram)
+        SELECT tu.employee_id, t.type, true, false, false
+        FROM telegram_users tu
+        CROSS JOIN (VALUES ('task.assigned'), ('task.status_changed'), ('ticket.assigned'),
+                           ('ticket.sla_breached'), ('improvement.approved'), ('meeting.analyzed'),
+                           ('one_on_one.proposal')) AS t(type)
+        WHERE tu.notifications_enabled = false AND tu.employee_id IS NOT NULL
+        ON CONFLICT (user_id, type) DO NOTHING;
+    END IF;
+END $$;
+
+COMMENT ON TABLE notifications IS 'User notification inbox with read state';
+COMMENT ON TABLE notification_preferences IS 'Notification channels chosen by users per notification type';
+COMMENT ON TABLE notification_settings IS 'Quiet hours and digest time of users';
+COMMENT ON TABLE notification_deliveries IS 'Email and Telegram notifications queued for sending';
@@ -0,0 +1,2 @@
+-- Language of notifications, emails and bot messages sent to an employee (ru, en)
+ALTER TABLE employees ADD COLUMN IF NOT EXISTS locale VARCHAR(5) NOT NULL DEFAULT 'ru';
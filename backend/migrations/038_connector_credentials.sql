@@ -0,0 +1,31 @@
+-- Per-connector credentials
+-- Every on-prem connector authenticates with its own credential instead of the shared
+-- CONNECTOR_API_KEY: a key it signs short-lived tokens with, or a pinned client certificate
+-- presented over mTLS. A connector may hold several active credentials, so keys are
+-- rotated by issuing a new one before the old one expires or is revoked. The capabilities
+-- and sites of a connector with a credential come from the credential, not from the connector.
+
+CREATE TABLE IF NOT EXISTS connector_credentials (
+    id UUID DEFAULT gen_random_uuid() PRIMARY KEY,  -- key ID of token credentials
+    connector_name VARCHAR(100) NOT NULL,           -- the connector may only connect under this name
+    kind VARCHAR(20) NOT NULL CHECK (kind IN ('token', 'certificate')),
+    description TEXT,
+    encrypted_secret TEXT,                          -- token signing key, AES-GCM encrypted
+    cert_fingerprint VARCHAR(64),                   -- SHA-256 of the client certificate, hex
+    cert_subject TEXT,
+    capabilities TEXT,                              -- comma-separated (ad, ews); NULL - all
+    sites TEXT,                                     -- comma-separated domains served; NULL - any
+    expires_at TIMESTAMPTZ,                         -- NULL - does not expire
+    revoked_at TIMESTAMPTZ,
+    last_used_at TIMESTAMPTZ,
+    rotated_from UUID REFERENCES connector_credentials(id) ON DELETE SET NULL,
+    created_by UUID REFERENCES employees(id) ON DELETE SET NULL,
+    created_at TIMESTAMPTZ DEFAULT NOW(),
+    CHECK ((kind = 'token' AND encrypted_secret IS NOT NULL) OR (kind = 'certificate' AND cert_fingerprint IS NOT NULL))
+);
+
+CREATE INDEX IF NOT EXISTS idx_connector_credentials_name ON connector_credentials(connector_name);
+CREATE UNIQUE INDEX IF NOT EXISTS idx_connector_credentials_fingerprint
+    ON connector_credentials(cert_fingerprint) WHERE revoked_at IS NULL;
+
+COMMENT ON TABLE connector_credentials IS 'Credentials on-prem connectors authenticate with';
@@ -0,0 +1,28 @@
+-- Queue of connector commands
+-- Commands that can wait, such as AD syncs, are queued here while no connector can run them
+-- and sent when one connects, unless they expire first. Results are handled by the backend
+-- when the command completes; the row keeps a summary.
+
+CREATE TABLE IF NOT EXISTS connector_commands (
+    id UUID DEFAULT gen_random_uuid() PRIMARY KEY,
+    command VARCHAR(50) NOT NULL,
+    params JSONB NOT NULL DEFAULT '{}',
+    options JSONB NOT NULL DEFAULT '{}',     -- backend-side options of the result handler
+    status VARCHAR(20) NOT NULL DEFAULT 'queued'
+        CHECK (status IN ('queued', 'running', 'done', 'failed', 'expired', 'cancelled')),
+    attempts INTEGER NOT NULL DEFAULT 0,
+    progress JSONB,                          -- last progress reported by the connector
+    result JSONB,                            -- summary returned by the result handler
+    error TEXT,
+    expires_at TIMESTAMPTZ NOT NULL,
+    created_by UUID REFERENCES employees(id) ON DELETE SET NULL,
+    created_at TIMESTAMPTZ DEFAULT NOW(),
+    started_at TIMESTAMPTZ,
+    updated_at TIMESTAMPTZ DEFAULT NOW(),
+    completed_at TIMESTAMPTZ
+);
+
+CREATE INDEX IF NOT EXISTS idx_connector_commands_open ON connector_commands(created_at) WHERE status IN ('queued', 'running');
+CREATE INDEX IF NOT EXISTS idx_connector_commands_created ON connector_commands(created_at DESC);
+
+COMMENT ON TABLE connector_commands IS 'Connector commands queued while no connector could run them';
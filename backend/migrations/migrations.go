@@ -0,0 +1,11 @@
+// Package migrations embeds the SQL schema migrations into the server binary.
+// Files are applied in name order by `server migrate up` and at startup
+// (see database.Migrator); applied versions are tracked in schema_migrations.
+package migrations
+
+import "embed"
+
+// FS holds the *.sql files of this directory
+//
+//go:embed *.sql
+var FS embed.FS
@@ -0,0 +1,21 @@
+package ai
+
+// AgendaContext holds what is known about an employee before an upcoming 1-on-1
+type AgendaContext struct {
+	MeetingTitle      string
+	MeetingDate       string
+	EmployeeContext   string
+	MeetingsHistory   string
+	OpenTasks         string
+	PreviousQuestions string
+}
+
+// GenerateAgenda prepares a structured agenda for an upcoming 1-on-1 using the provider chain
+func (c *Client) GenerateAgenda(ctx AgendaContext) (map[string]interface{}, AnalysisRun, error) {
+	prompt, err := c.renderPrompt(AgendaPrompt, ctx)
+	if err != nil {
+		return nil, AnalysisRun{}, err
+	}
+
+	return c.completeJSON(prompt, AgendaSchema, c.configuredLLMProviders(c.llmChain))
+}
@@ -2,6 +2,7 @@ package ai
 
 import (
 	"bytes"
+	"context"
 	"encoding/json"
 	"fmt"
 	"io"
@@ -16,21 +17,41 @@ import (
 // Client handles AI operations
 type Client struct {
 	openaiKey      string
-	anthropicKey   string
 	yandexAPIKey   string
 	yandexFolderID string
 	httpClient     *http.Client
+
+	// Speech-to-text providers and their selection
+	transcribers         map[string]Transcriber
+	defaultTranscribers  []string
+	categoryTranscribers map[string][]string
+
+	// Text generation providers in fallback order
+	llmProviders   map[string]LLMProvider
+	llmChain       []string
+	repairAttempts int
 }
 
-// NewClient creates a new AI client
+// NewClient creates a new AI client with the built-in Whisper and Yandex transcribers
+// and the Anthropic LLM provider registered
 func NewClient(openaiKey, anthropicKey, yandexAPIKey, yandexFolderID string) *Client {
-	return &Client{
-		openaiKey:      openaiKey,
-		anthropicKey:   anthropicKey,
-		yandexAPIKey:   yandexAPIKey,
-		yandexFolderID: yandexFolderID,
-		httpClient:     &http.Client{},
-	}
+	c := &Client{
+		openaiKey:           openaiKey,
+		yandexAPIKey:        yandexAPIKey,
+		yandexFolderID:      yandexFolderID,
+		httpClient:          &http.Client{},
+		transcribers:        make(map[string]Transcriber),
+		defaultTranscribers: []string{TranscriberWhisper, TranscriberYandex},
+		llmProviders:        make(map[string]LLMProvider),
+		llmChain:            []string{LLMProviderAnthropic},
+		repairAttempts:      2,
+	}
+
+	c.RegisterTranscriber(&whisperTranscriber{client: c})
+	c.RegisterTranscriber(&yandexTranscriber{client: c})
+	c.RegisterLLMProvider(NewAnthropicProvider(anthropicKey, DefaultLLMOptions()))
+
+	return c
 }
 
 // AnalysisContext holds context for meeting analysis
@@ -42,6 +63,9 @@ type AnalysisContext struct {
 	Participants      string
 	WhisperTranscript string
 	YandexTranscript  string
+	// Diarized transcript with timestamps and speaker labels, plus talk-time per speaker
+	SpeakerTranscript string
+	SpeakerStats      string
 }
 
 // TranscribeWhisper transcribes audio using OpenAI Whisper
@@ -50,9 +74,57 @@ func (c *Client) TranscribeWhisper(filePath string) (string, error) {
 		return "", fmt.Errorf("OpenAI API key not configured")
 	}
 
+	result, err := c.postOpenAIAudio(filePath, map[string]string{
+		"model":           "whisper-1",
+		"language":        "ru",
+		"response_format": "text",
+		"prompt":          "Это рабочая встреча. Обсуждаются проекты, задачи, KPI, спринты, дедлайны.",
+	})
+	if err != nil {
+		return "", fmt.Errorf("whisper %w", err)
+	}
+
+	return string(result), nil
+}
+
+// TranscribeDiarized transcribes audio into timestamped segments with speaker labels
+// using the OpenAI diarization model. Labels are opaque ("A", "B", ...) and must be
+// mapped to employees separately.
+func (c *Client) TranscribeDiarized(filePath string) ([]TranscriptSegment, error) {
+	if c.openaiKey == "" {
+		return nil, fmt.Errorf("OpenAI API key not configured")
+	}
+
+	result, err := c.postOpenAIAudio(filePath, map[string]string{
+		"model":             "gpt-4o-transcribe-diarize",
+		"language":          "ru",
+		"response_format":   "diarized_json",
+		"chunking_strategy": "auto",
+	})
+	if err != nil {
+		return nil, fmt.Errorf("diarization %w", err)
+	}
+
+	var response struct {
+		Segments []TranscriptSegment `json:"segments"`
+	}
+	if err := json.Unmarshal(result, &response); err != nil {
+		return nil, err
+	}
+
+	return response.Segments, nil
+}
+
+// postOpenAIAudio uploads an audio file to the OpenAI transcription endpoint
+func (c *Client) postOpenAIAudio(filePath string, fields map[string]string) ([]byte, error) {
+	return postAudioForm(c.httpClient, "https://api.openai.com/v1/audio/transcriptions", c.openaiKey, filePath, fields)
+}
+
+// postAudioForm uploads an audio file as multipart form to an OpenAI-compatible transcription endpoint
+func postAudioForm(httpClient *http.Client, url, apiKey, filePath string, fields map[string]string) ([]byte, error) {
 	file, err := os.Open(filePath)
 	if err != nil {
-		return "", err
+		return nil, err
 	}
 	defer file.Close()
 
@@ -61,43 +133,44 @@ func (c *Client) TranscribeWhisper(filePath string) (string, error) {
 
 	part, err := writer.CreateFormFile("file", filepath.Base(filePath))
 	if err != nil {
-		return "", err
+		return nil, err
 	}
 
 	if _, err := io.Copy(part, file); err != nil {
-		return "", err
+		return nil, err
 	}
 
-	writer.WriteField("model", "whisper-1")
-	writer.WriteField("language", "ru")
-	writer.WriteField("response_format", "text")
-	writer.WriteField("prompt", "Это рабочая встреча. Обсуждаются проекты, задачи, KPI, спринты, дедлайны.")
+	for key, value := range fields {
+		writer.WriteField(key, value)
+	}
 	writer.Close()
 
-	req, err := http.NewRequest("POST", "https://api.openai.com/v1/audio/transcriptions", &body)
+	req, err := http.NewRequest("POST", url, &body)
 	if err != nil {
-		return "", err
+		return nil, err
 	}
 
-	req.Header.Set("Authorization", "Bearer "+c.openaiKey)
+	if apiKey != "" {
+		req.Header.Set("Authorization", "Bearer "+apiKey)
+	}
 	req.Header.Set("Content-Type", writer.FormDataContentType())
 
-	resp, err := c.httpClient.Do(req)
+	resp, err := httpClient.Do(req)
 	if err != nil {
-		return "", err
+		return nil, err
 	}
 	defer resp.Body.Close()
 
 	result, err := io.ReadAll(resp.Body)
 	if err != nil {
-		return "", err
+		return nil, err
 	}
 
 	if resp.StatusCode != 200 {
-		return "", fmt.Errorf("whisper error %d: %s", resp.StatusCode, string(result))
+		return nil, fmt.Errorf("error %d: %s", resp.StatusCode, string(result))
 	}
 
-	return string(result), nil
+	return result, nil
 }
 
 // TranscribeYandex transcribes audio using Yandex SpeechKit
@@ -165,9 +238,9 @@ func (c *Client) TranscribeYandex(filePath string) (string, error) {
 	return response.Result, nil
 }
 
-// MergeTranscripts merges two transcripts using Claude
+// MergeTranscripts merges two transcripts using the LLM provider chain
 func (c *Client) MergeTranscripts(whisper, yandex string) (string, error) {
-	if c.anthropicKey == "" {
+	if !c.LLMConfigured() {
 		if whisper != "" {
 			return whisper, nil
 		}
@@ -191,128 +264,144 @@ func (c *Client) MergeTranscripts(whisper, yandex string) (string, error) {
 		return whisper, err
 	}
 
-	return c.callClaude(prompt)
+	return c.complete(prompt)
 }
 
-// Analyze analyzes a transcript using the appropriate prompt
+// Analyze analyzes a transcript using the built-in prompt for the category
 func (c *Client) Analyze(categoryCode string, ctx AnalysisContext) (map[string]interface{}, error) {
-	promptTemplate, ok := Prompts[categoryCode]
+	return c.AnalyzeWithPrompt(categoryCode, DefaultPrompt(categoryCode), ctx)
+}
+
+// AnalyzeWithPrompt analyzes a transcript using the given prompt template and the configured provider chain
+func (c *Client) AnalyzeWithPrompt(categoryCode, promptTemplate string, ctx AnalysisContext) (map[string]interface{}, error) {
+	result, _, err := c.AnalyzeWithProviders(categoryCode, promptTemplate, ctx, nil)
+	return result, err
+}
+
+// AnalyzeWithProviders analyzes a transcript and reports which provider produced the result.
+// providers overrides the configured chain when not empty.
+// The response is validated against the category schema; invalid responses are sent
+// back to the same provider for repair, then the next provider in the chain is tried.
+func (c *Client) AnalyzeWithProviders(categoryCode, promptTemplate string, ctx AnalysisContext, providers []string) (map[string]interface{}, AnalysisRun, error) {
+	schema, ok := AnalysisSchemas[categoryCode]
 	if !ok {
-		promptTemplate = Prompts["default"]
+		schema = AnalysisSchemas["default"]
 	}
 
-	prompt, err := c.renderPrompt(promptTemplate, ctx)
+	prompt, err := c.RenderPrompt(promptTemplate, ctx)
 	if err != nil {
-		return nil, err
+		return nil, AnalysisRun{}, err
 	}
 
-	responseText, err := c.callClaude(prompt)
-	if err != nil {
-		return nil, err
+	if len(providers) == 0 {
+		providers = c.llmChain
 	}
+	return c.completeJSON(prompt, schema, c.configuredLLMProviders(providers))
+}
 
-	// Extract JSON from response
-	responseText = extractJSON(responseText)
-
-	var result map[string]interface{}
-	if err := json.Unmarshal([]byte(responseText), &result); err != nil {
-		return nil, fmt.Errorf("failed to parse analysis: %v, response: %s", err, responseText[:min(500, len(responseText))])
+// DefaultPrompt returns the built-in prompt template for a category
+func DefaultPrompt(categoryCode string) string {
+	if promptTemplate, ok := Prompts[categoryCode]; ok {
+		return promptTemplate
 	}
+	return Prompts["default"]
+}
 
-	return result, nil
+// RenderPrompt fills an analysis prompt template with meeting context
+func (c *Client) RenderPrompt(promptTemplate string, ctx AnalysisContext) (string, error) {
+	return c.renderPrompt(promptTemplate, ctx)
 }
 
-func (c *Client) renderPrompt(promptTemplate string, ctx AnalysisContext) (string, error) {
+// ValidatePromptTemplate checks that a template parses and only references AnalysisContext fields
+func ValidatePromptTemplate(promptTemplate string) error {
 	tmpl, err := template.New("prompt").Parse(promptTemplate)
 	if err != nil {
-		return "", err
+		return err
 	}
-
-	var buf bytes.Buffer
-	if err := tmpl.Execute(&buf, ctx); err != nil {
-		return "", err
-	}
-
-	return buf.String(), nil
+	return tmpl.Execute(io.Discard, AnalysisContext{})
 }
 
-func (c *Client) callClaude(prompt string) (string, error) {
-	if c.anthropicKey == "" {
-		return "", fmt.Errorf("Anthropic API key not configured")
+// completeJSON runs the prompt through providers in order until a response matches the schema
+func (c *Client) completeJSON(prompt string, schema *Schema, providers []LLMProvider) (map[string]interface{}, AnalysisRun, error) {
+	if len(providers) == 0 {
+		return nil, AnalysisRun{}, fmt.Errorf("no LLM provider configured")
 	}
 
-	payload := map[string]interface{}{
-		"model":      "claude-sonnet-4-20250514",
-		"max_tokens": 8000,
-		"messages": []map[string]string{
-			{"role": "user", "content": prompt},
-		},
+	var errs []string
+	for _, p := range providers {
+		result, err := c.completeJSONWith(p, prompt, schema)
+		if err == nil {
+			return result, AnalysisRun{Provider: p.Name(), Model: p.Model()}, nil
+		}
+		errs = append(errs, fmt.Sprintf("%s: %v", p.Name(), err))
 	}
+	return nil, AnalysisRun{}, fmt.Errorf("failed to get valid analysis: %s", strings.Join(errs, "; "))
+}
 
-	jsonData, err := json.Marshal(payload)
+func (c *Client) completeJSONWith(p LLMProvider, prompt string, schema *Schema) (map[string]interface{}, error) {
+	responseText, err := p.Complete(context.Background(), prompt)
 	if err != nil {
-		return "", err
+		return nil, err
 	}
 
-	req, err := http.NewRequest("POST", "https://api.anthropic.com/v1/messages", bytes.NewBuffer(jsonData))
-	if err != nil {
-		return "", err
-	}
+	for attempt := 0; ; attempt++ {
+		result, violations := parseAndValidate(responseText, schema)
+		if len(violations) == 0 {
+			return result, nil
+		}
+		if attempt >= c.repairAttempts {
+			return nil, fmt.Errorf("invalid response after %d repair attempts: %s, response: %s",
+				attempt, strings.Join(violations, "; "), responseText[:min(500, len(responseText))])
+		}
 
-	req.Header.Set("Content-Type", "application/json")
-	req.Header.Set("x-api-key", c.anthropicKey)
-	req.Header.Set("anthropic-version", "2023-06-01")
+		repairPrompt, err := c.renderPrompt(JSONRepairPrompt, map[string]interface{}{
+			"Format":   responseFormat(prompt),
+			"Response": responseText,
+			"Errors":   violations,
+		})
+		if err != nil {
+			return nil, err
+		}
 
-	resp, err := c.httpClient.Do(req)
-	if err != nil {
-		return "", err
+		responseText, err = p.Complete(context.Background(), repairPrompt)
+		if err != nil {
+			return nil, err
+		}
 	}
-	defer resp.Body.Close()
+}
 
-	body, err := io.ReadAll(resp.Body)
+// parseAndValidate returns the parsed object or the list of problems with it
+func parseAndValidate(text string, schema *Schema) (map[string]interface{}, []string) {
+	result, err := parseJSONObject(text)
 	if err != nil {
-		return "", err
-	}
-
-	if resp.StatusCode != 200 {
-		return "", fmt.Errorf("Claude error %d: %s", resp.StatusCode, string(body))
+		return nil, []string{"response is not a valid JSON object: " + err.Error()}
 	}
-
-	var response struct {
-		Content []struct {
-			Text string `json:"text"`
-		} `json:"content"`
-	}
-
-	if err := json.Unmarshal(body, &response); err != nil {
-		return "", err
+	if schema == nil {
+		return result, nil
 	}
+	return result, schema.Validate(result)
+}
 
-	if len(response.Content) == 0 {
-		return "", fmt.Errorf("empty response from Claude")
+// responseFormat returns the output format section of a prompt for repair requests
+func responseFormat(prompt string) string {
+	if i := strings.Index(prompt, "ФОРМАТ ОТВЕТА"); i >= 0 {
+		return prompt[i:]
 	}
-
-	return response.Content[0].Text, nil
+	return prompt
 }
 
-func extractJSON(text string) string {
-	// Try to extract JSON from markdown code blocks
-	if strings.Contains(text, "```json") {
-		parts := strings.Split(text, "```json")
-		if len(parts) > 1 {
-			jsonPart := strings.Split(parts[1], "```")[0]
-			return strings.TrimSpace(jsonPart)
-		}
+func (c *Client) renderPrompt(promptTemplate string, data interface{}) (string, error) {
+	tmpl, err := template.New("prompt").Parse(promptTemplate)
+	if err != nil {
+		return "", err
 	}
 
-	if strings.Contains(text, "```") {
-		parts := strings.Split(text, "```")
-		if len(parts) > 1 {
-			return strings.TrimSpace(parts[1])
-		}
+	var buf bytes.Buffer
+	if err := tmpl.Execute(&buf, data); err != nil {
+		return "", err
 	}
 
-	return strings.TrimSpace(text)
+	return buf.String(), nil
 }
 
 func min(a, b int) int {
@@ -0,0 +1,303 @@
+package ai
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// Built-in LLM provider names
+const (
+	LLMProviderAnthropic = "anthropic"
+	LLMProviderOpenAI    = "openai"
+	LLMProviderLocal     = "local"
+)
+
+// LLMOptions configures generation for a provider
+type LLMOptions struct {
+	Model       string
+	Temperature float64
+	MaxTokens   int
+	Timeout     time.Duration
+}
+
+// DefaultLLMOptions returns the options used when nothing is configured
+func DefaultLLMOptions() LLMOptions {
+	return LLMOptions{
+		Temperature: 0.2,
+		MaxTokens:   8000,
+		Timeout:     3 * time.Minute,
+	}
+}
+
+// AnalysisRun records which provider and model produced an analysis
+type AnalysisRun struct {
+	Provider string `json:"provider"`
+	Model    string `json:"model"`
+}
+
+// LLMProvider generates text completions for a single-turn prompt
+type LLMProvider interface {
+	Name() string
+	Model() string
+	Configured() bool
+	Complete(ctx context.Context, prompt string) (string, error)
+}
+
+// RegisterLLMProvider adds or replaces a completion provider
+func (c *Client) RegisterLLMProvider(p LLMProvider) {
+	c.llmProviders[p.Name()] = p
+}
+
+// LLMProvider returns a registered provider by name
+func (c *Client) LLMProvider(name string) (LLMProvider, bool) {
+	p, ok := c.llmProviders[name]
+	return p, ok
+}
+
+// LLMProviders returns all registered providers
+func (c *Client) LLMProviders() []LLMProvider {
+	result := make([]LLMProvider, 0, len(c.llmProviders))
+	for _, p := range c.llmProviders {
+		result = append(result, p)
+	}
+	return result
+}
+
+// SetLLMChain sets the provider fallback order. Empty chain keeps the current one.
+func (c *Client) SetLLMChain(names []string) {
+	if len(names) > 0 {
+		c.llmChain = names
+	}
+}
+
+// LLMChain returns provider names in fallback order
+func (c *Client) LLMChain() []string {
+	return c.llmChain
+}
+
+// SetRepairAttempts sets how many times an invalid JSON response is sent back for correction
+func (c *Client) SetRepairAttempts(n int) {
+	if n >= 0 {
+		c.repairAttempts = n
+	}
+}
+
+// LLMConfigured reports whether at least one provider in the chain can be called
+func (c *Client) LLMConfigured() bool {
+	return len(c.configuredLLMProviders(c.llmChain)) > 0
+}
+
+func (c *Client) configuredLLMProviders(names []string) []LLMProvider {
+	var result []LLMProvider
+	for _, name := range names {
+		if p, ok := c.llmProviders[name]; ok && p.Configured() {
+			result = append(result, p)
+		}
+	}
+	return result
+}
+
+// complete calls providers in chain order and returns the first successful response
+func (c *Client) complete(prompt string) (string, error) {
+	providers := c.configuredLLMProviders(c.llmChain)
+	if len(providers) == 0 {
+		return "", fmt.Errorf("no LLM provider configured")
+	}
+
+	var errs []string
+	for _, p := range providers {
+		text, err := p.Complete(context.Background(), prompt)
+		if err == nil {
+			return text, nil
+		}
+		errs = append(errs, fmt.Sprintf("%s: %v", p.Name(), err))
+	}
+	return "", fmt.Errorf("all LLM providers failed: %s", strings.Join(errs, "; "))
+}
+
+// AnthropicProvider calls the Anthropic Messages API
+type AnthropicProvider struct {
+	apiKey     string
+	options    LLMOptions
+	httpClient *http.Client
+}
+
+// NewAnthropicProvider creates a Claude provider
+func NewAnthropicProvider(apiKey string, options LLMOptions) *AnthropicProvider {
+	if options.Model == "" {
+		options.Model = "claude-sonnet-4-20250514"
+	}
+	return &AnthropicProvider{
+		apiKey:     apiKey,
+		options:    options,
+		httpClient: &http.Client{},
+	}
+}
+
+func (p *AnthropicProvider) Name() string { return LLMProviderAnthropic }
+
+func (p *AnthropicProvider) Model() string { return p.options.Model }
+
+func (p *AnthropicProvider) Configured() bool { return p.apiKey != "" }
+
+// Complete sends the prompt as a single user message
+func (p *AnthropicProvider) Complete(ctx context.Context, prompt string) (string, error) {
+	if p.apiKey == "" {
+		return "", fmt.Errorf("Anthropic API key not configured")
+	}
+
+	payload := map[string]interface{}{
+		"model":       p.options.Model,
+		"max_tokens":  p.options.MaxTokens,
+		"temperature": p.options.Temperature,
+		"messages": []map[string]string{
+			{"role": "user", "content": prompt},
+		},
+	}
+
+	body, err := postLLMRequest(ctx, p.httpClient, p.options.Timeout, "https://api.anthropic.com/v1/messages", payload, map[string]string{
+		"x-api-key":         p.apiKey,
+		"anthropic-version": "2023-06-01",
+	})
+	if err != nil {
+		return "", fmt.Errorf("Claude %w", err)
+	}
+
+	var response struct {
+		Content []struct {
+			Text string `json:"text"`
+		} `json:"content"`
+	}
+	if err := json.Unmarshal(body, &response); err != nil {
+		return "", err
+	}
+
+	if len(response.Content) == 0 {
+		return "", fmt.Errorf("empty response from Claude")
+	}
+
+	return response.Content[0].Text, nil
+}
+
+// OpenAICompatibleProvider calls any endpoint implementing /chat/completions:
+// OpenAI itself or a self-hosted model behind vLLM, Ollama, llama.cpp server, etc.
+type OpenAICompatibleProvider struct {
+	name       string
+	baseURL    string
+	apiKey     string
+	options    LLMOptions
+	httpClient *http.Client
+}
+
+// NewOpenAICompatibleProvider creates a provider for baseURL (e.g., http://llm.internal:8000/v1)
+func NewOpenAICompatibleProvider(name, baseURL, apiKey string, options LLMOptions) *OpenAICompatibleProvider {
+	return &OpenAICompatibleProvider{
+		name:       name,
+		baseURL:    strings.TrimRight(baseURL, "/"),
+		apiKey:     apiKey,
+		options:    options,
+		httpClient: &http.Client{},
+	}
+}
+
+func (p *OpenAICompatibleProvider) Name() string { return p.name }
+
+func (p *OpenAICompatibleProvider) Model() string { return p.options.Model }
+
+// Configured requires a URL and a model; the API key is optional for internal servers
+func (p *OpenAICompatibleProvider) Configured() bool {
+	if p.baseURL == "" || p.options.Model == "" {
+		return false
+	}
+	return p.name != LLMProviderOpenAI || p.apiKey != ""
+}
+
+// Complete sends the prompt as a single user message
+func (p *OpenAICompatibleProvider) Complete(ctx context.Context, prompt string) (string, error) {
+	if !p.Configured() {
+		return "", fmt.Errorf("%s LLM provider not configured", p.name)
+	}
+
+	payload := map[string]interface{}{
+		"model":       p.options.Model,
+		"max_tokens":  p.options.MaxTokens,
+		"temperature": p.options.Temperature,
+		"messages": []map[string]string{
+			{"role": "user", "content": prompt},
+		},
+	}
+
+	headers := map[string]string{}
+	if p.apiKey != "" {
+		headers["Authorization"] = "Bearer " + p.apiKey
+	}
+
+	body, err := postLLMRequest(ctx, p.httpClient, p.options.Timeout, p.baseURL+"/chat/completions", payload, headers)
+	if err != nil {
+		return "", fmt.Errorf("%s %w", p.name, err)
+	}
+
+	var response struct {
+		Choices []struct {
+			Message struct {
+				Content string `json:"content"`
+			} `json:"message"`
+		} `json:"choices"`
+	}
+	if err := json.Unmarshal(body, &response); err != nil {
+		return "", err
+	}
+
+	if len(response.Choices) == 0 {
+		return "", fmt.Errorf("empty response from %s", p.name)
+	}
+
+	return response.Choices[0].Message.Content, nil
+}
+
+// postLLMRequest sends a JSON request with the provider timeout and returns the response body
+func postLLMRequest(ctx context.Context, httpClient *http.Client, timeout time.Duration, url string, payload interface{}, headers map[string]string) ([]byte, error) {
+	if timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, timeout)
+		defer cancel()
+	}
+
+	jsonData, err := json.Marshal(payload)
+	if err != nil {
+		return nil, err
+	}
+
+	req, err := http.NewRequestWithContext(ctx, "POST", url, bytes.NewBuffer(jsonData))
+	if err != nil {
+		return nil, err
+	}
+
+	req.Header.Set("Content-Type", "application/json")
+	for key, value := range headers {
+		req.Header.Set(key, value)
+	}
+
+	resp, err := httpClient.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, err
+	}
+
+	if resp.StatusCode != 200 {
+		return nil, fmt.Errorf("error %d: %s", resp.StatusCode, string(body))
+	}
+
+	return body, nil
+}
@@ -40,17 +40,35 @@ var Prompts = map[string]string{
    - КТО и ЧТО должен сделать
    - Если срок не назван - не выдумывай
 
+6. ВЫПОЛНЕНИЕ ПРОШЛЫХ ДОГОВОРЁННОСТЕЙ:
+   - Сверь открытые договорённости из истории с тем, что обсуждалось
+   - Отмечай выполненными ТОЛЬКО те, о выполнении которых явно сказано на встрече
+   - Используй id договорённости из истории, не выдумывай id
+
 ТРАНСКРИПТ НОВОЙ ВСТРЕЧИ:
 {{.Transcript}}
+{{if .SpeakerTranscript}}
+ТРАНСКРИПТ ПО СПИКЕРАМ (таймкоды от начала записи):
+{{.SpeakerTranscript}}
+
+ВРЕМЯ РЕЧИ ПО СПИКЕРАМ:
+{{.SpeakerStats}}
 
+Определи по содержанию, кто из спикеров руководитель, а кто сотрудник.
+Для каждой договорённости укажи таймкод реплики, где она прозвучала.
+{{end}}
 ФОРМАТ ОТВЕТА (строго JSON):
 {
     "summary": "2-3 предложения: главное из встречи",
     "employee_agenda": ["темы сотрудника"],
     "manager_agenda": ["темы руководителя"],
     "agreements": [
-        {"task": "задача", "responsible": "кто", "deadline": "YYYY-MM-DD или null"}
+        {"task": "задача", "responsible": "кто", "deadline": "YYYY-MM-DD или null", "timestamp": "mm:ss или null"}
     ],
+    "completed_agreements": [
+        {"id": "id договорённости из истории", "evidence": "что сказано о выполнении"}
+    ],
+    "talk_time": {"manager_percent": 40, "employee_percent": 60, "comment": "баланс диалога или null, если нет данных по спикерам"},
     "development_notes": "наблюдения о росте и навыках",
     "red_flags": {
         "burnout_signs": "описание или false",
@@ -82,13 +100,19 @@ var Prompts = map[string]string{
 
 ТРАНСКРИПТ:
 {{.Transcript}}
+{{if .SpeakerTranscript}}
+ТРАНСКРИПТ ПО СПИКЕРАМ (таймкоды от начала записи):
+{{.SpeakerTranscript}}
 
+ВРЕМЯ РЕЧИ ПО СПИКЕРАМ:
+{{.SpeakerStats}}
+{{end}}
 ФОРМАТ ОТВЕТА (JSON):
 {
     "summary": "краткое резюме совещания",
     "decisions": ["принятые решения"],
     "action_items": [
-        {"task": "задача", "responsible": "кто", "deadline": "YYYY-MM-DD или null"}
+        {"task": "задача", "responsible": "кто", "deadline": "YYYY-MM-DD или null", "timestamp": "mm:ss или null"}
     ],
     "blockers": ["выявленные блокеры"],
     "risks": ["риски проекта"],
@@ -210,3 +234,56 @@ var TranscriptMergePrompt = `У тебя есть два варианта тра
 5. Если одна версия явно лучше - используй её как основу
 
 Верни ТОЛЬКО объединённый транскрипт, без комментариев.`
+
+// JSONRepairPrompt asks the model to fix a response that failed schema validation
+var JSONRepairPrompt = `Твой предыдущий ответ не соответствует требуемому формату.
+
+ТРЕБУЕМЫЙ ФОРМАТ:
+{{.Format}}
+
+ТВОЙ ОТВЕТ:
+{{.Response}}
+
+ОШИБКИ:
+{{range .Errors}}- {{.}}
+{{end}}
+Исправь ответ: сохрани содержание, но приведи его в соответствие с форматом.
+Верни ТОЛЬКО валидный JSON, без комментариев и markdown.`
+
+// AgendaPrompt prepares an agenda for an upcoming 1-on-1 from what is known before it
+var AgendaPrompt = `Ты - опытный коуч руководителей. Готовишь руководителя к предстоящей встрече 1-на-1.
+
+ВСТРЕЧА: {{.MeetingTitle}}, {{.MeetingDate}}
+
+КОНТЕКСТ СОТРУДНИКА:
+{{.EmployeeContext}}
+
+ИСТОРИЯ ПРЕДЫДУЩИХ ВСТРЕЧ:
+{{.MeetingsHistory}}
+
+ОТКРЫТЫЕ ЗАДАЧИ СОТРУДНИКА:
+{{.OpenTasks}}
+{{if .PreviousQuestions}}
+ВОПРОСЫ, ПРЕДЛОЖЕННЫЕ ПО ИТОГАМ ПРОШЛОЙ ВСТРЕЧИ:
+{{.PreviousQuestions}}
+{{end}}
+ЗАДАЧА: Составь повестку встречи на 30-60 минут.
+
+1. Начни с самочувствия и тем сотрудника, если настроение снижается - поставь это первым пунктом
+2. Пройди открытые договорённости: просроченные - обязательно, остальные - кратко
+3. Обсуди просроченные и заблокированные задачи, не перечисляй все задачи подряд
+4. Оставь время на развитие и обратную связь
+5. Используй только факты из контекста, не выдумывай проблемы
+
+ФОРМАТ ОТВЕТА (строго JSON):
+{
+    "summary": "1-2 предложения: на чём сфокусироваться",
+    "items": [
+        {"topic": "тема", "details": "что обсудить", "reason": "почему это важно сейчас", "priority": "high/medium/low", "minutes": 10}
+    ],
+    "agreements_to_review": [
+        {"id": "id договорённости из истории", "task": "договорённость", "note": "что уточнить"}
+    ],
+    "questions_to_ask": ["открытые вопросы сотруднику"],
+    "risks": ["сигналы, на которые обратить внимание"]
+}`
@@ -0,0 +1,250 @@
+package ai
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+)
+
+// Schema is a minimal JSON schema used to validate LLM analysis responses.
+// Type is one of: object, array, string, number, boolean, any.
+type Schema struct {
+	Type       string
+	Properties map[string]*Schema
+	Required   []string
+	Items      *Schema
+	Enum       []string
+	Nullable   bool
+}
+
+// Validate returns a list of violations, empty if value matches the schema
+func (s *Schema) Validate(value interface{}) []string {
+	var violations []string
+	s.validate("$", value, &violations)
+	return violations
+}
+
+func (s *Schema) validate(path string, value interface{}, violations *[]string) {
+	if value == nil {
+		if !s.Nullable && s.Type != "any" {
+			*violations = append(*violations, fmt.Sprintf("%s: must not be null", path))
+		}
+		return
+	}
+
+	switch s.Type {
+	case "object":
+		obj, ok := value.(map[string]interface{})
+		if !ok {
+			*violations = append(*violations, fmt.Sprintf("%s: expected object", path))
+			return
+		}
+		for _, key := range s.Required {
+			if _, ok := obj[key]; !ok {
+				*violations = append(*violations, fmt.Sprintf("%s.%s: required field is missing", path, key))
+			}
+		}
+		for key, prop := range s.Properties {
+			if v, ok := obj[key]; ok {
+				prop.validate(path+"."+key, v, violations)
+			}
+		}
+	case "array":
+		arr, ok := value.([]interface{})
+		if !ok {
+			*violations = append(*violations, fmt.Sprintf("%s: expected array", path))
+			return
+		}
+		if s.Items != nil {
+			for i, item := range arr {
+				s.Items.validate(fmt.Sprintf("%s[%d]", path, i), item, violations)
+			}
+		}
+	case "string":
+		str, ok := value.(string)
+		if !ok {
+			*violations = append(*violations, fmt.Sprintf("%s: expected string", path))
+			return
+		}
+		if len(s.Enum) > 0 && !containsString(s.Enum, str) {
+			*violations = append(*violations, fmt.Sprintf("%s: must be one of %s", path, strings.Join(s.Enum, ", ")))
+		}
+	case "number":
+		if _, ok := value.(float64); !ok {
+			*violations = append(*violations, fmt.Sprintf("%s: expected number", path))
+		}
+	case "boolean":
+		if _, ok := value.(bool); !ok {
+			*violations = append(*violations, fmt.Sprintf("%s: expected boolean", path))
+		}
+	}
+}
+
+// parseJSONObject extracts the first JSON object from an LLM response,
+// tolerating markdown fences and prose before or after it
+func parseJSONObject(text string) (map[string]interface{}, error) {
+	text = strings.TrimSpace(text)
+
+	var lastErr error
+	for start := strings.Index(text, "{"); start >= 0; {
+		var result map[string]interface{}
+		decoder := json.NewDecoder(strings.NewReader(text[start:]))
+		err := decoder.Decode(&result)
+		if err == nil {
+			return result, nil
+		}
+		lastErr = err
+
+		next := strings.Index(text[start+1:], "{")
+		if next < 0 {
+			break
+		}
+		start += next + 1
+	}
+
+	if lastErr == nil {
+		lastErr = fmt.Errorf("no JSON object found")
+	}
+	return nil, lastErr
+}
+
+func containsString(values []string, value string) bool {
+	for _, v := range values {
+		if v == value {
+			return true
+		}
+	}
+	return false
+}
+
+// Schema helpers keep the category definitions below readable
+func objectOf(required []string, properties map[string]*Schema) *Schema {
+	return &Schema{Type: "object", Required: required, Properties: properties}
+}
+
+func arrayOf(items *Schema) *Schema {
+	return &Schema{Type: "array", Items: items}
+}
+
+func enumOf(values ...string) *Schema {
+	return &Schema{Type: "string", Enum: values}
+}
+
+var (
+	anyValue       = &Schema{Type: "any"}
+	stringValue    = &Schema{Type: "string"}
+	nullableString = &Schema{Type: "string", Nullable: true}
+	numberValue    = &Schema{Type: "number"}
+	stringList     = arrayOf(stringValue)
+	trend          = enumOf("improving", "stable", "declining")
+)
+
+// taskItem is an agreement/action item; the task text key differs between categories
+func taskItem(textKey string) *Schema {
+	return objectOf([]string{textKey}, map[string]*Schema{
+		textKey:       stringValue,
+		"responsible": nullableString,
+		"deadline":    nullableString,
+		"timestamp":   nullableString,
+	})
+}
+
+// AnalysisSchemas contains expected response structure by meeting category
+var AnalysisSchemas = map[string]*Schema{
+	"one_on_one": objectOf([]string{"summary", "agreements", "mood_score"}, map[string]*Schema{
+		"summary":         stringValue,
+		"employee_agenda": stringList,
+		"manager_agenda":  stringList,
+		"agreements":      arrayOf(taskItem("task")),
+		"completed_agreements": arrayOf(objectOf([]string{"id"}, map[string]*Schema{
+			"id":       stringValue,
+			"evidence": nullableString,
+		})),
+		"talk_time":         {Type: "object", Nullable: true},
+		"development_notes": nullableString,
+		"red_flags": objectOf(nil, map[string]*Schema{
+			"burnout_signs":  anyValue,
+			"turnover_risk":  enumOf("low", "medium", "high"),
+			"team_conflicts": anyValue,
+			"concerns":       stringList,
+		}),
+		"mood_score":       numberValue,
+		"mood_trend":       trend,
+		"mood_indicators":  stringList,
+		"positive_signals": stringList,
+		"recommendations":  stringList,
+		"questions_to_ask": stringList,
+	}),
+
+	"team_meeting": objectOf([]string{"summary", "action_items"}, map[string]*Schema{
+		"summary":        stringValue,
+		"decisions":      stringList,
+		"action_items":   arrayOf(taskItem("task")),
+		"blockers":       stringList,
+		"risks":          stringList,
+		"open_questions": stringList,
+		"next_steps":     stringList,
+		"project_health": enumOf("green", "yellow", "red"),
+	}),
+
+	"planning": objectOf([]string{"summary", "committed_items"}, map[string]*Schema{
+		"summary": stringValue,
+		"committed_items": arrayOf(objectOf([]string{"task"}, map[string]*Schema{
+			"task":        stringValue,
+			"responsible": nullableString,
+			"priority":    {Type: "string", Enum: []string{"high", "medium", "low"}, Nullable: true},
+		})),
+		"capacity_concerns": stringList,
+		"dependencies":      stringList,
+		"risks":             stringList,
+		"team_confidence":   {Type: "number", Nullable: true},
+		"recommendations":   stringList,
+	}),
+
+	"retro": objectOf([]string{"summary", "action_items"}, map[string]*Schema{
+		"summary":          stringValue,
+		"went_well":        stringList,
+		"went_wrong":       stringList,
+		"action_items":     arrayOf(taskItem("improvement")),
+		"recurring_issues": stringList,
+		"resolved_issues":  stringList,
+		"team_morale":      {Type: "number", Nullable: true},
+		"morale_trend":     trend,
+		"patterns":         stringList,
+	}),
+
+	"interview": objectOf([]string{"summary", "recommendation"}, map[string]*Schema{
+		"summary":              stringValue,
+		"candidate_strengths":  stringList,
+		"candidate_weaknesses": stringList,
+		"red_flags":            stringList,
+		"recommendation":       enumOf("hire", "no_hire", "maybe"),
+	}),
+
+	"default": objectOf([]string{"summary"}, map[string]*Schema{
+		"summary":        stringValue,
+		"key_points":     stringList,
+		"action_items":   arrayOf(taskItem("task")),
+		"decisions":      stringList,
+		"open_questions": stringList,
+	}),
+}
+
+// AgendaSchema is the expected structure of a prepared 1-on-1 agenda
+var AgendaSchema = objectOf([]string{"summary", "items"}, map[string]*Schema{
+	"summary": stringValue,
+	"items": arrayOf(objectOf([]string{"topic"}, map[string]*Schema{
+		"topic":    stringValue,
+		"details":  nullableString,
+		"reason":   nullableString,
+		"priority": {Type: "string", Enum: []string{"high", "medium", "low"}, Nullable: true},
+		"minutes":  {Type: "number", Nullable: true},
+	})),
+	"agreements_to_review": arrayOf(objectOf([]string{"id"}, map[string]*Schema{
+		"id":   stringValue,
+		"task": nullableString,
+		"note": nullableString,
+	})),
+	"questions_to_ask": stringList,
+	"risks":            stringList,
+})
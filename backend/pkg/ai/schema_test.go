@@ -0,0 +1,133 @@
+package ai
+
+import (
+	"reflect"
+	"sort"
+	"testing"
+)
+
+func TestSchemaValidate(t *testing.T) {
+	schema := objectOf([]string{"summary", "items"}, map[string]*Schema{
+		"summary": stringValue,
+		"score":   numberValue,
+		"done":    {Type: "boolean"},
+		"trend":   trend,
+		"note":    nullableString,
+		"extra":   anyValue,
+		"items": arrayOf(objectOf([]string{"task"}, map[string]*Schema{
+			"task": stringValue,
+		})),
+	})
+
+	tests := []struct {
+		name  string
+		value interface{}
+		want  []string
+	}{
+		{
+			name: "valid",
+			value: map[string]interface{}{
+				"summary": "ok", "score": 7.0, "done": true, "trend": "stable", "note": nil, "extra": nil,
+				"items": []interface{}{map[string]interface{}{"task": "write tests"}},
+			},
+		},
+		{
+			name:  "unknown fields are allowed",
+			value: map[string]interface{}{"summary": "ok", "items": []interface{}{}, "other": 1.0},
+		},
+		{
+			name:  "not an object",
+			value: []interface{}{},
+			want:  []string{"$: expected object"},
+		},
+		{
+			name:  "null root",
+			value: nil,
+			want:  []string{"$: must not be null"},
+		},
+		{
+			name:  "missing required fields",
+			value: map[string]interface{}{},
+			want:  []string{"$.items: required field is missing", "$.summary: required field is missing"},
+		},
+		{
+			name: "wrong types",
+			value: map[string]interface{}{
+				"summary": 1.0, "score": "7", "done": "yes", "items": map[string]interface{}{},
+			},
+			want: []string{
+				"$.done: expected boolean",
+				"$.items: expected array",
+				"$.score: expected number",
+				"$.summary: expected string",
+			},
+		},
+		{
+			name:  "enum and null",
+			value: map[string]interface{}{"summary": nil, "trend": "sideways", "items": []interface{}{}},
+			want:  []string{"$.summary: must not be null", "$.trend: must be one of improving, stable, declining"},
+		},
+		{
+			name: "array items",
+			value: map[string]interface{}{"summary": "ok", "items": []interface{}{
+				map[string]interface{}{"task": "a"},
+				map[string]interface{}{},
+				"b",
+			}},
+			want: []string{"$.items[1].task: required field is missing", "$.items[2]: expected object"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := schema.Validate(tt.value)
+			sort.Strings(got)
+			if len(got) == 0 && len(tt.want) == 0 {
+				return
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("Validate() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestAnalysisSchemasAcceptMinimalResponses(t *testing.T) {
+	value := map[string]interface{}{
+		"summary":    "ok",
+		"agreements": []interface{}{map[string]interface{}{"task": "a", "responsible": nil}},
+		"mood_score": 8.0,
+	}
+	if got := AnalysisSchemas["one_on_one"].Validate(value); len(got) > 0 {
+		t.Errorf("one_on_one: %q", got)
+	}
+}
+
+func TestParseJSONObject(t *testing.T) {
+	tests := []struct {
+		name    string
+		text    string
+		want    map[string]interface{}
+		wantErr bool
+	}{
+		{name: "plain", text: `{"a": 1}`, want: map[string]interface{}{"a": 1.0}},
+		{name: "markdown fence", text: "```json\n{\"a\": \"b\"}\n```", want: map[string]interface{}{"a": "b"}},
+		{name: "prose around", text: "Here is the analysis:\n{\"a\": true}\nHope it helps.", want: map[string]interface{}{"a": true}},
+		{name: "nested", text: `{"a": {"b": [1, 2]}}`, want: map[string]interface{}{"a": map[string]interface{}{"b": []interface{}{1.0, 2.0}}}},
+		{name: "brace in prose before the object", text: "Use {curly} braces: {\"a\": 1}", want: map[string]interface{}{"a": 1.0}},
+		{name: "first of two objects", text: `{"a": 1} {"b": 2}`, want: map[string]interface{}{"a": 1.0}},
+		{name: "no object", text: "no json here", wantErr: true},
+		{name: "empty", text: "", wantErr: true},
+		{name: "truncated", text: `{"a": [1, 2`, wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := parseJSONObject(tt.text)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("parseJSONObject() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if !tt.wantErr && !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("parseJSONObject() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,101 @@
+package ai
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// TranscriptSegment is a timestamped piece of speech attributed to a speaker
+type TranscriptSegment struct {
+	Speaker string  `json:"speaker"`
+	Start   float64 `json:"start"` // Offset from recording start, seconds
+	End     float64 `json:"end"`
+	Text    string  `json:"text"`
+}
+
+// SpeakerTalkTime returns total speaking time in seconds per speaker label
+func SpeakerTalkTime(segments []TranscriptSegment) map[string]float64 {
+	talkTime := make(map[string]float64)
+	for _, s := range segments {
+		if s.End > s.Start {
+			talkTime[s.Speaker] += s.End - s.Start
+		}
+	}
+	return talkTime
+}
+
+// FormatSegments renders segments as "[mm:ss] Speaker: text" lines.
+// names maps speaker labels to display names; unmapped labels are shown as is.
+func FormatSegments(segments []TranscriptSegment, names map[string]string) string {
+	var lines []string
+	for _, s := range segments {
+		lines = append(lines, fmt.Sprintf("[%s] %s: %s", FormatOffset(s.Start), speakerName(s.Speaker, names), strings.TrimSpace(s.Text)))
+	}
+	return strings.Join(lines, "\n")
+}
+
+// FormatSpeakerStats renders talk-time share per speaker, largest first
+func FormatSpeakerStats(segments []TranscriptSegment, names map[string]string) string {
+	talkTime := SpeakerTalkTime(segments)
+
+	var total float64
+	speakers := make([]string, 0, len(talkTime))
+	for speaker, seconds := range talkTime {
+		speakers = append(speakers, speaker)
+		total += seconds
+	}
+	if total == 0 {
+		return ""
+	}
+
+	sort.Slice(speakers, func(i, j int) bool {
+		return talkTime[speakers[i]] > talkTime[speakers[j]]
+	})
+
+	var lines []string
+	for _, speaker := range speakers {
+		seconds := talkTime[speaker]
+		lines = append(lines, fmt.Sprintf("- %s: %s (%.0f%%)", speakerName(speaker, names), FormatOffset(seconds), seconds*100/total))
+	}
+	return strings.Join(lines, "\n")
+}
+
+// FormatOffset formats seconds as mm:ss (or h:mm:ss for long recordings)
+func FormatOffset(seconds float64) string {
+	total := int(seconds)
+	if total >= 3600 {
+		return fmt.Sprintf("%d:%02d:%02d", total/3600, (total%3600)/60, total%60)
+	}
+	return fmt.Sprintf("%02d:%02d", total/60, total%60)
+}
+
+// ParseOffset parses mm:ss or h:mm:ss into seconds. Only the leading component may
+// exceed 59 ("75:30" is 75 minutes), so "1:75" is rejected rather than read as 2:15.
+func ParseOffset(value string) (float64, bool) {
+	parts := strings.Split(strings.TrimSpace(value), ":")
+	if len(parts) < 2 || len(parts) > 3 {
+		return 0, false
+	}
+
+	var total float64
+	for i, part := range parts {
+		n, err := strconv.Atoi(part)
+		if err != nil || n < 0 || (i > 0 && n >= 60) {
+			return 0, false
+		}
+		total = total*60 + float64(n)
+	}
+	return total, true
+}
+
+func speakerName(label string, names map[string]string) string {
+	if name, ok := names[label]; ok && name != "" {
+		return name
+	}
+	if label == "" {
+		return "Спикер"
+	}
+	return "Спикер " + label
+}
@@ -0,0 +1,46 @@
+package ai
+
+import "testing"
+
+func TestParseOffset(t *testing.T) {
+	tests := []struct {
+		value string
+		want  float64
+		ok    bool
+	}{
+		{"00:00", 0, true},
+		{"01:05", 65, true},
+		{"75:30", 4530, true},
+		{"1:02:03", 3723, true},
+		{" 02:10 ", 130, true},
+		{"", 0, false},
+		{"42", 0, false},
+		{"1:2:3:4", 0, false},
+		{"aa:10", 0, false},
+		{"01:-5", 0, false},
+		{"01:", 0, false},
+		{"1.5:00", 0, false},
+		{"1:75", 0, false},
+		{"00:60", 0, false},
+		{"00:59", 59, true},
+		{"0:99:10", 0, false},
+		{"1:02:60", 0, false},
+		{"1:59:59", 7199, true},
+		{"120:00:00", 432000, true},
+	}
+	for _, tt := range tests {
+		got, ok := ParseOffset(tt.value)
+		if got != tt.want || ok != tt.ok {
+			t.Errorf("ParseOffset(%q) = %v, %v; want %v, %v", tt.value, got, ok, tt.want, tt.ok)
+		}
+	}
+}
+
+func TestFormatOffsetRoundTrip(t *testing.T) {
+	for _, seconds := range []float64{0, 59, 60, 3599, 3600, 3723, 86399} {
+		got, ok := ParseOffset(FormatOffset(seconds))
+		if !ok || got != seconds {
+			t.Errorf("ParseOffset(FormatOffset(%v)) = %v, %v", seconds, got, ok)
+		}
+	}
+}
@@ -0,0 +1,216 @@
+package ai
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// Built-in transcriber names
+const (
+	TranscriberWhisper = "whisper"
+	TranscriberYandex  = "yandex"
+	TranscriberLocal   = "local"
+)
+
+// Transcriber converts an audio file into plain text
+type Transcriber interface {
+	Name() string
+	Configured() bool
+	Transcribe(filePath string) (string, error)
+}
+
+// SegmentTranscriber is a Transcriber that can also return timestamped
+// segments, optionally with speaker labels
+type SegmentTranscriber interface {
+	Transcriber
+	TranscribeSegments(filePath string) ([]TranscriptSegment, error)
+}
+
+// RegisterTranscriber adds or replaces a speech-to-text provider
+func (c *Client) RegisterTranscriber(t Transcriber) {
+	c.transcribers[t.Name()] = t
+}
+
+// Transcriber returns a registered provider by name
+func (c *Client) Transcriber(name string) (Transcriber, bool) {
+	t, ok := c.transcribers[name]
+	return t, ok
+}
+
+// Transcribers returns all registered providers
+func (c *Client) Transcribers() []Transcriber {
+	result := make([]Transcriber, 0, len(c.transcribers))
+	for _, t := range c.transcribers {
+		result = append(result, t)
+	}
+	return result
+}
+
+// SetTranscriberRouting sets the deployment-wide provider list and per-category overrides.
+// Empty defaults keep the current list.
+func (c *Client) SetTranscriberRouting(defaults []string, byCategory map[string][]string) {
+	if len(defaults) > 0 {
+		c.defaultTranscribers = defaults
+	}
+	c.categoryTranscribers = byCategory
+}
+
+// TranscriberNamesFor returns provider names selected for a meeting category
+// (category override first, deployment default otherwise)
+func (c *Client) TranscriberNamesFor(categoryCode string) []string {
+	if names, ok := c.categoryTranscribers[categoryCode]; ok && len(names) > 0 {
+		return names
+	}
+	return c.defaultTranscribers
+}
+
+// TranscribersFor returns configured providers selected for a meeting category
+func (c *Client) TranscribersFor(categoryCode string) []Transcriber {
+	var result []Transcriber
+	for _, name := range c.TranscriberNamesFor(categoryCode) {
+		if t, ok := c.transcribers[name]; ok && t.Configured() {
+			result = append(result, t)
+		}
+	}
+	return result
+}
+
+// ParseTranscriberRouting parses "one_on_one=local;interview=local,yandex"
+// into a category -> providers map
+func ParseTranscriberRouting(value string) map[string][]string {
+	routing := make(map[string][]string)
+	for _, rule := range strings.Split(value, ";") {
+		category, providers, ok := strings.Cut(strings.TrimSpace(rule), "=")
+		if !ok || strings.TrimSpace(category) == "" {
+			continue
+		}
+		if names := ParseProviderList(providers); len(names) > 0 {
+			routing[strings.TrimSpace(category)] = names
+		}
+	}
+	return routing
+}
+
+// ParseProviderList parses a comma-separated provider list (STT or LLM)
+func ParseProviderList(value string) []string {
+	var names []string
+	for _, name := range strings.Split(value, ",") {
+		if name = strings.TrimSpace(name); name != "" {
+			names = append(names, name)
+		}
+	}
+	return names
+}
+
+// whisperTranscriber uses OpenAI Whisper for text and the OpenAI diarization model for segments
+type whisperTranscriber struct {
+	client *Client
+}
+
+func (t *whisperTranscriber) Name() string { return TranscriberWhisper }
+
+func (t *whisperTranscriber) Configured() bool { return t.client.openaiKey != "" }
+
+func (t *whisperTranscriber) Transcribe(filePath string) (string, error) {
+	return t.client.TranscribeWhisper(filePath)
+}
+
+func (t *whisperTranscriber) TranscribeSegments(filePath string) ([]TranscriptSegment, error) {
+	return t.client.TranscribeDiarized(filePath)
+}
+
+// yandexTranscriber uses Yandex SpeechKit
+type yandexTranscriber struct {
+	client *Client
+}
+
+func (t *yandexTranscriber) Name() string { return TranscriberYandex }
+
+func (t *yandexTranscriber) Configured() bool {
+	return t.client.yandexAPIKey != "" && t.client.yandexFolderID != ""
+}
+
+func (t *yandexTranscriber) Transcribe(filePath string) (string, error) {
+	return t.client.TranscribeYandex(filePath)
+}
+
+// LocalWhisperTranscriber calls a self-hosted server exposing the OpenAI-compatible
+// /v1/audio/transcriptions endpoint (faster-whisper-server, whisper.cpp server, WhisperX).
+// Recordings never leave the internal network.
+type LocalWhisperTranscriber struct {
+	baseURL    string
+	model      string
+	apiKey     string
+	httpClient *http.Client
+}
+
+// localWhisperTimeout limits a transcription request when no timeout is given; a long
+// recording on a CPU-only server takes minutes, a hung server must not block the job
+const localWhisperTimeout = 30 * time.Minute
+
+// NewLocalWhisperTranscriber creates a provider for a locally hosted whisper server. timeout
+// limits a whole request, upload and transcription included; 0 means 30 minutes.
+func NewLocalWhisperTranscriber(baseURL, model, apiKey string, timeout time.Duration) *LocalWhisperTranscriber {
+	if model == "" {
+		model = "whisper-1"
+	}
+	if timeout <= 0 {
+		timeout = localWhisperTimeout
+	}
+	return &LocalWhisperTranscriber{
+		baseURL:    strings.TrimRight(baseURL, "/"),
+		model:      model,
+		apiKey:     apiKey,
+		httpClient: &http.Client{Timeout: timeout},
+	}
+}
+
+func (t *LocalWhisperTranscriber) Name() string { return TranscriberLocal }
+
+func (t *LocalWhisperTranscriber) Configured() bool { return t.baseURL != "" }
+
+// Transcribe returns plain text transcription
+func (t *LocalWhisperTranscriber) Transcribe(filePath string) (string, error) {
+	result, err := t.post(filePath, "text")
+	if err != nil {
+		return "", err
+	}
+	return strings.TrimSpace(string(result)), nil
+}
+
+// TranscribeSegments returns timestamped segments. Speaker labels are present
+// only if the server performs diarization (e.g. WhisperX).
+func (t *LocalWhisperTranscriber) TranscribeSegments(filePath string) ([]TranscriptSegment, error) {
+	result, err := t.post(filePath, "verbose_json")
+	if err != nil {
+		return nil, err
+	}
+
+	var response struct {
+		Segments []TranscriptSegment `json:"segments"`
+	}
+	if err := json.Unmarshal(result, &response); err != nil {
+		return nil, err
+	}
+
+	return response.Segments, nil
+}
+
+func (t *LocalWhisperTranscriber) post(filePath, responseFormat string) ([]byte, error) {
+	if t.baseURL == "" {
+		return nil, fmt.Errorf("local STT server URL not configured")
+	}
+
+	result, err := postAudioForm(t.httpClient, t.baseURL+"/v1/audio/transcriptions", t.apiKey, filePath, map[string]string{
+		"model":           t.model,
+		"language":        "ru",
+		"response_format": responseFormat,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("local STT %w", err)
+	}
+	return result, nil
+}
@@ -0,0 +1,60 @@
+package protocol
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// sourceDir is the package this one is copied from by scripts/sync-protocol.sh
+const sourceDir = "../../../connector-service/pkg/protocol"
+
+const generatedHeader = "// Code generated by scripts/sync-protocol.sh from connector-service/pkg/protocol. DO NOT EDIT.\n\n"
+
+// TestCopyIsInSync fails when connector-service/pkg/protocol changed without running
+// scripts/sync-protocol.sh. It is skipped when the backend is built on its own.
+func TestCopyIsInSync(t *testing.T) {
+	if _, err := os.Stat(sourceDir); err != nil {
+		t.Skipf("%s is not available: %v", sourceDir, err)
+	}
+
+	sources := goFiles(t, sourceDir)
+	copies := goFiles(t, ".")
+	for name, src := range sources {
+		dst, ok := copies[name]
+		if !ok {
+			t.Errorf("%s is missing, run scripts/sync-protocol.sh", name)
+			continue
+		}
+		if dst != generatedHeader+src {
+			t.Errorf("%s is out of date, run scripts/sync-protocol.sh", name)
+		}
+	}
+	for name := range copies {
+		if _, ok := sources[name]; !ok {
+			t.Errorf("%s is not in %s, run scripts/sync-protocol.sh", name, sourceDir)
+		}
+	}
+}
+
+// goFiles reads the non-test Go files of a directory by name
+func goFiles(t *testing.T, dir string) map[string]string {
+	t.Helper()
+	paths, err := filepath.Glob(filepath.Join(dir, "*.go"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	files := make(map[string]string)
+	for _, path := range paths {
+		if strings.HasSuffix(path, "_test.go") {
+			continue
+		}
+		data, err := os.ReadFile(path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		files[filepath.Base(path)] = string(data)
+	}
+	return files
+}
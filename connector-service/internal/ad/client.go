@@ -6,6 +6,7 @@ import (
 	"fmt"
 	"strings"
 
+	"github.com/ekf/one-on-one-connector/pkg/protocol"
 	"github.com/go-ldap/ldap/v3"
 )
 
@@ -18,26 +19,8 @@ type Client struct {
 	conn         *ldap.Conn
 }
 
-type User struct {
-	DN              string   `json:"dn"`
-	Username        string   `json:"username"`
-	Login           string   `json:"login"` // Same as Username, for backend compatibility
-	Email           string   `json:"email"`
-	Name            string   `json:"name"`
-	DisplayName     string   `json:"display_name"`
-	GivenName       string   `json:"given_name"`
-	Surname         string   `json:"surname"`
-	Department      string   `json:"department"`
-	Title           string   `json:"title"`
-	Phone           string   `json:"phone"`
-	Mobile          string   `json:"mobile"`
-	Manager         string   `json:"manager"`
-	ManagerDN       string   `json:"manager_dn"`
-	PhotoBase64     string   `json:"photo_base64,omitempty"`
-	MemberOf        []string `json:"member_of"`
-	Enabled         bool     `json:"enabled"`
-	PasswordExpired bool     `json:"password_expired"`
-}
+// User is an AD user as sent to the backend
+type User = protocol.User
 
 func NewClient(url, baseDN, bindUser, bindPassword string, skipVerify bool) *Client {
 	return &Client{
@@ -141,7 +124,13 @@ func (c *Client) Authenticate(username, password string) (*User, error) {
 	return user, nil
 }
 
-func (c *Client) GetAllUsers(includePhotos bool) ([]*User, error) {
+// userPageSize is the number of users read per LDAP page; AD returns at most 1000 entries
+// to a search that does not page
+const userPageSize = 500
+
+// GetAllUsers reads the enabled users with a department. progress, if set, is called with
+// the number of users read so far after every page.
+func (c *Client) GetAllUsers(includePhotos bool, progress func(read int)) ([]*User, error) {
 	if c.conn == nil {
 		if err := c.Connect(); err != nil {
 			return nil, err
@@ -161,25 +150,38 @@ func (c *Client) GetAllUsers(includePhotos bool) ([]*User, error) {
 	// Filter: user object, has department, account enabled
 	searchFilter := "(&(objectClass=user)(objectCategory=person)(department=*)(!(userAccountControl:1.2.840.113556.1.4.803:=2)))"
 
+	paging := ldap.NewControlPaging(userPageSize)
 	searchRequest := ldap.NewSearchRequest(
 		c.BaseDN,
 		ldap.ScopeWholeSubtree, ldap.NeverDerefAliases, 0, 0, false,
 		searchFilter,
 		attributes,
-		nil,
+		[]ldap.Control{paging},
 	)
 
-	sr, err := c.conn.Search(searchRequest)
-	if err != nil {
-		return nil, fmt.Errorf("search failed: %w", err)
-	}
+	var users []*User
+	for {
+		sr, err := c.conn.Search(searchRequest)
+		if err != nil {
+			return nil, fmt.Errorf("search failed: %w", err)
+		}
 
-	users := make([]*User, 0, len(sr.Entries))
-	for _, entry := range sr.Entries {
-		user := c.parseUser(entry, includePhotos)
-		if user.Department != "" { // Double check department exists
-			users = append(users, user)
+		for _, entry := range sr.Entries {
+			user := c.parseUser(entry, includePhotos)
+			if user.Department != "" { // Double check department exists
+				users = append(users, user)
+			}
+		}
+		if progress != nil {
+			progress(len(users))
+		}
+
+		// An empty cookie ends the paged search
+		next, ok := ldap.FindControl(sr.Controls, ldap.ControlTypePaging).(*ldap.ControlPaging)
+		if !ok || len(next.Cookie) == 0 {
+			break
 		}
+		paging.SetCookie(next.Cookie)
 	}
 
 	return users, nil
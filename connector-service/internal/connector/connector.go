@@ -2,12 +2,16 @@ package connector
 
 import (
 	"crypto/tls"
+	"crypto/x509"
 	"encoding/json"
 	"fmt"
 	"log"
+	"net/http"
 	"net/url"
 	"os"
+	"sort"
 	"strings"
+	"sync"
 	"time"
 
 	"github.com/ekf/one-on-one-connector/internal/ad"
@@ -19,8 +23,18 @@ import (
 
 type Config struct {
 	Backend struct {
-		URL                string `yaml:"url"`
-		APIKey             string `yaml:"api_key"`
+		URL    string `yaml:"url"`
+		APIKey string `yaml:"api_key"` // shared key of connectors without a credential, deprecated
+		// Credential issued by the backend admin API, "<key id>.<secret>"; CredentialFile
+		// holds it instead and is read on every connect, so it can be rotated without a restart
+		Credential     string `yaml:"credential"`
+		CredentialFile string `yaml:"credential_file"`
+		// Client certificate for mTLS, read on every connect
+		CertFile           string `yaml:"cert_file"`
+		KeyFile            string `yaml:"key_file"`
+		CAFile             string `yaml:"ca_file"` // CA bundle to verify the backend with, instead of the system one
+		Name               string `yaml:"name"`    // unique per office, e.g. "msk"
+		Sites              string `yaml:"sites"`   // comma-separated AD/mail domains this connector serves
 		ReconnectInterval  int    `yaml:"reconnect_interval"`
 		HeartbeatInterval  int    `yaml:"heartbeat_interval"`
 		InsecureSkipVerify bool   `yaml:"insecure_skip_verify"`
@@ -43,13 +57,59 @@ type Config struct {
 	} `yaml:"ews"`
 }
 
+// Version is the connector build, sent to the backend in the handshake. Set it with
+// -ldflags "-X github.com/ekf/one-on-one-connector/internal/connector.Version=1.2.0".
+var Version = "dev"
+
 type Connector struct {
 	config    *Config
 	ws        *websocket.Conn
+	writeMu   sync.Mutex // heartbeats and responses are written from different goroutines
 	adClient  *ad.Client
 	ewsClient *ews.Client
 	running   bool
 	stopChan  chan struct{}
+
+	// Protocol version agreed with the backend; 1 until it answers the hello, as backends
+	// that predate the handshake never do
+	version int
+}
+
+// commandHandler runs a command with its raw params and returns the result
+type commandHandler func(c *Connector, params json.RawMessage, progress progressFunc) (interface{}, error)
+
+// progressFunc reports how far a command has got; total is 0 when unknown. Backends before
+// protocol version 3 are not sent progress.
+type progressFunc func(stage string, done, total int)
+
+// commands are the commands this connector handles, announced in the hello
+var commands = map[string]commandHandler{
+	protocol.CommandPing:            (*Connector).handlePing,
+	protocol.CommandGetCalendar:     (*Connector).handleGetCalendar,
+	protocol.CommandSyncCalendar:    (*Connector).handleGetCalendar,
+	protocol.CommandFindFreeSlots:   (*Connector).handleFindFreeSlots,
+	protocol.CommandSyncUsers:       (*Connector).handleSyncUsers,
+	protocol.CommandAuthenticate:    (*Connector).handleAuthenticate,
+	protocol.CommandGetSubordinates: (*Connector).handleGetSubordinates,
+
+	protocol.CommandGetMailFolders:       (*Connector).handleGetMailFolders,
+	protocol.CommandGetEmails:            (*Connector).handleGetEmails,
+	protocol.CommandGetEmailBody:         (*Connector).handleGetEmailBody,
+	protocol.CommandSendEmail:            (*Connector).handleSendEmail,
+	protocol.CommandMarkEmailRead:        (*Connector).handleMarkEmailRead,
+	protocol.CommandDeleteEmail:          (*Connector).handleDeleteEmail,
+	protocol.CommandGetAttachments:       (*Connector).handleGetAttachments,
+	protocol.CommandGetAttachmentContent: (*Connector).handleGetAttachmentContent,
+	protocol.CommandRespondToMeeting:     (*Connector).handleRespondToMeeting,
+	protocol.CommandCreateCalendarItem:   (*Connector).handleCreateCalendarItem,
+	protocol.CommandUpdateCalendarItem:   (*Connector).handleUpdateCalendarItem,
+	protocol.CommandDeleteCalendarItem:   (*Connector).handleDeleteCalendarItem,
+}
+
+// commandAliases are older names of commands still sent by old backends
+var commandAliases = map[string]string{
+	"sync_ad_users":   protocol.CommandSyncUsers,
+	"authenticate_ad": protocol.CommandAuthenticate,
 }
 
 func New(configPath string) (*Connector, error) {
@@ -99,24 +159,113 @@ func (c *Connector) connect() error {
 		return fmt.Errorf("invalid backend URL: %w", err)
 	}
 
-	// Add API key as query parameter
+	header := make(http.Header)
+	credential, err := c.credential()
+	if err != nil {
+		return err
+	}
+
+	// Add what this connector serves as query parameters, with the shared key if it has no
+	// credential
 	q := u.Query()
-	q.Set("token", c.config.Backend.APIKey)
+	if credential != "" {
+		keyID, secret, err := protocol.ParseCredential(credential)
+		if err != nil {
+			return err
+		}
+		token, err := protocol.SignToken(keyID, secret, time.Now(), protocol.TokenTTL)
+		if err != nil {
+			return err
+		}
+		header.Set("Authorization", "Bearer "+token)
+	} else if c.config.Backend.APIKey != "" {
+		q.Set("token", c.config.Backend.APIKey)
+	}
+	if c.config.Backend.Name != "" {
+		q.Set("name", c.config.Backend.Name)
+	}
+	q.Set("capabilities", strings.Join(c.capabilities(), ","))
+	if c.config.Backend.Sites != "" {
+		q.Set("sites", c.config.Backend.Sites)
+	}
 	u.RawQuery = q.Encode()
 
-	dialer := websocket.DefaultDialer
-	if c.config.Backend.InsecureSkipVerify {
-		dialer.TLSClientConfig = &tls.Config{InsecureSkipVerify: true}
+	tlsConfig, err := c.tlsConfig()
+	if err != nil {
+		return err
 	}
+	dialer := *websocket.DefaultDialer
+	dialer.TLSClientConfig = tlsConfig
 
-	ws, _, err := dialer.Dial(u.String(), nil)
+	ws, resp, err := dialer.Dial(u.String(), header)
 	if err != nil {
+		if resp != nil && (resp.StatusCode == http.StatusUnauthorized || resp.StatusCode == http.StatusForbidden) {
+			return fmt.Errorf("backend rejected the credentials (%s): %w", resp.Status, err)
+		}
 		return fmt.Errorf("failed to connect: %w", err)
 	}
 
 	c.ws = ws
+	c.version = 1
 	log.Printf("Connected to backend: %s", strings.Split(c.config.Backend.URL, "?")[0])
-	return nil
+
+	names := make([]string, 0, len(commands))
+	for name := range commands {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return c.sendMessage(protocol.NewHello(names, Version))
+}
+
+// credential returns the credential to sign tokens with, "" if there is none
+func (c *Connector) credential() (string, error) {
+	if c.config.Backend.CredentialFile == "" {
+		return strings.TrimSpace(c.config.Backend.Credential), nil
+	}
+	data, err := os.ReadFile(c.config.Backend.CredentialFile)
+	if err != nil {
+		return "", fmt.Errorf("failed to read credential: %w", err)
+	}
+	return strings.TrimSpace(string(data)), nil
+}
+
+// tlsConfig returns the TLS configuration to connect to the backend with. The client
+// certificate is loaded on each handshake, so renewed files are picked up on reconnect.
+func (c *Connector) tlsConfig() (*tls.Config, error) {
+	cfg := &tls.Config{InsecureSkipVerify: c.config.Backend.InsecureSkipVerify}
+	if c.config.Backend.CAFile != "" {
+		caPEM, err := os.ReadFile(c.config.Backend.CAFile)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read CA file: %w", err)
+		}
+		cfg.RootCAs = x509.NewCertPool()
+		if !cfg.RootCAs.AppendCertsFromPEM(caPEM) {
+			return nil, fmt.Errorf("no certificates in %s", c.config.Backend.CAFile)
+		}
+	}
+	if c.config.Backend.CertFile != "" {
+		certFile, keyFile := c.config.Backend.CertFile, c.config.Backend.KeyFile
+		cfg.GetClientCertificate = func(*tls.CertificateRequestInfo) (*tls.Certificate, error) {
+			cert, err := tls.LoadX509KeyPair(certFile, keyFile)
+			if err != nil {
+				return nil, fmt.Errorf("failed to load client certificate: %w", err)
+			}
+			return &cert, nil
+		}
+	}
+	return cfg, nil
+}
+
+// capabilities lists the services this connector is configured for
+func (c *Connector) capabilities() []string {
+	var caps []string
+	if c.config.AD.URL != "" {
+		caps = append(caps, "ad")
+	}
+	if c.config.EWS.URL != "" {
+		caps = append(caps, "ews")
+	}
+	return caps
 }
 
 func (c *Connector) disconnect() {
@@ -137,10 +286,36 @@ func (c *Connector) sendMessage(msg interface{}) error {
 		return err
 	}
 
+	c.writeMu.Lock()
+	defer c.writeMu.Unlock()
 	return c.ws.WriteMessage(websocket.TextMessage, data)
 }
 
 func (c *Connector) handleMessage(data []byte) {
+	var msg protocol.Message
+	if err := json.Unmarshal(data, &msg); err != nil {
+		log.Printf("Failed to parse message: %v", err)
+		return
+	}
+
+	switch msg.Type {
+	case protocol.TypeWelcome:
+		var welcome protocol.Welcome
+		json.Unmarshal(data, &welcome)
+		c.version = welcome.Version
+		log.Printf("Handshake complete: protocol version %d", welcome.Version)
+	case protocol.TypeError:
+		var rejected protocol.Error
+		json.Unmarshal(data, &rejected)
+		log.Printf("Backend rejected the connection: %s (backend protocol version %d, connector %d-%d)",
+			rejected.Error, rejected.Version, protocol.MinVersion, protocol.Version)
+	default: // a command; version 1 backends do not set the type
+		// Commands run concurrently, so mail requests do not wait for a long AD sync
+		go c.handleCommand(data)
+	}
+}
+
+func (c *Connector) handleCommand(data []byte) {
 	var cmd protocol.Command
 	if err := json.Unmarshal(data, &cmd); err != nil {
 		log.Printf("Failed to parse command: %v", err)
@@ -152,54 +327,50 @@ func (c *Connector) handleMessage(data []byte) {
 	var result interface{}
 	var cmdErr error
 
-	switch cmd.Command {
-	case "ping":
-		result = map[string]interface{}{
-			"pong":      true,
-			"timestamp": time.Now().Format(time.RFC3339),
+	progress := func(string, int, int) {}
+	if c.version >= 3 {
+		progress = func(stage string, done, total int) {
+			c.sendMessage(protocol.Progress{
+				Type:      protocol.TypeProgress,
+				RequestID: cmd.RequestID,
+				Stage:     stage,
+				Done:      done,
+				Total:     total,
+			})
 		}
+	}
 
-	case "get_calendar":
-		result, cmdErr = c.handleGetCalendar(cmd.Params)
-
-	case "sync_calendar":
-		result, cmdErr = c.handleSyncCalendar(cmd.Params)
-
-	case "find_free_slots":
-		result, cmdErr = c.handleFindFreeSlots(cmd.Params)
-
-	case "sync_ad_users":
-		result, cmdErr = c.handleSyncADUsers(cmd.Params)
-
-	case "sync_users":
-		result, cmdErr = c.handleSyncADUsers(cmd.Params)
-
-	case "authenticate_ad":
-		result, cmdErr = c.handleAuthenticateAD(cmd.Params)
-
-	case "authenticate":
-		result, cmdErr = c.handleAuthenticateAD(cmd.Params)
-
-	case "get_subordinates":
-		result, cmdErr = c.handleGetSubordinates(cmd.Params)
-
-	default:
+	name := cmd.Command
+	if alias, ok := commandAliases[name]; ok {
+		name = alias
+	}
+	if handler, ok := commands[name]; ok {
+		result, cmdErr = handler(c, cmd.Params, progress)
+	} else {
 		cmdErr = fmt.Errorf("unknown command: %s", cmd.Command)
 	}
 
 	// Send response
 	resp := protocol.Response{
-		Type:      "response",
+		Type:      protocol.TypeResponse,
 		RequestID: cmd.RequestID,
 		Command:   cmd.Command,
 		Success:   cmdErr == nil,
 		Timestamp: time.Now().Format(time.RFC3339),
 	}
 
+	if cmdErr == nil {
+		var data []byte
+		data, cmdErr = json.Marshal(result)
+		if cmdErr == nil && c.version >= 3 && len(data) > protocol.ChunkSize {
+			resp.Chunks, cmdErr = c.sendChunks(cmd.RequestID, data, progress)
+		} else {
+			resp.Result = data
+		}
+		resp.Success = cmdErr == nil
+	}
 	if cmdErr != nil {
 		resp.Error = cmdErr.Error()
-	} else {
-		resp.Result = result
 	}
 
 	if err := c.sendMessage(resp); err != nil {
@@ -207,17 +378,53 @@ func (c *Connector) handleMessage(data []byte) {
 	}
 }
 
-func (c *Connector) handleGetCalendar(params map[string]interface{}) (interface{}, error) {
-	email, ok := params["email"].(string)
-	if !ok || email == "" {
-		log.Printf("ERROR: email is required, params: %+v", params)
+// sendChunks streams a large result and returns the number of chunks
+func (c *Connector) sendChunks(requestID string, data []byte, progress progressFunc) (int, error) {
+	chunks := protocol.SplitChunks(data, protocol.ChunkSize)
+	progress("sending result", 0, len(chunks))
+	for i, part := range chunks {
+		err := c.sendMessage(protocol.Chunk{Type: protocol.TypeChunk, RequestID: requestID, Seq: i, Data: part})
+		if err != nil {
+			return 0, fmt.Errorf("failed to send result: %w", err)
+		}
+	}
+	log.Printf("Streamed result of %d bytes in %d chunks (request_id: %s)", len(data), len(chunks), requestID)
+	return len(chunks), nil
+}
+
+// decodeParams decodes the params of a command into v; missing params leave v as it is
+func decodeParams(params json.RawMessage, v interface{}) error {
+	if len(params) == 0 || string(params) == "null" {
+		return nil
+	}
+	if err := json.Unmarshal(params, v); err != nil {
+		return fmt.Errorf("invalid params: %w", err)
+	}
+	return nil
+}
+
+func (c *Connector) handlePing(json.RawMessage, progressFunc) (interface{}, error) {
+	return protocol.PingResult{Pong: true, Timestamp: time.Now().Format(time.RFC3339)}, nil
+}
+
+func (c *Connector) handleGetCalendar(raw json.RawMessage, _ progressFunc) (interface{}, error) {
+	var params protocol.CalendarParams
+	if err := decodeParams(raw, &params); err != nil {
+		return nil, err
+	}
+	if params.Email == "" {
+		log.Printf("ERROR: email is required")
 		return nil, fmt.Errorf("email is required")
 	}
 
-	username, _ := params["username"].(string)
-	password, _ := params["password"].(string)
-	daysBack := int(getFloat64(params, "days_back", 7))
-	daysForward := int(getFloat64(params, "days_forward", 30))
+	username, password := params.Username, params.Password
+	daysBack, daysForward := params.DaysBack, params.DaysForward
+	if daysBack == 0 {
+		daysBack = 7
+	}
+	if daysForward == 0 {
+		daysForward = 30
+	}
 
 	log.Printf("DEBUG: Received username='%s', password_length=%d", username, len(password))
 
@@ -231,28 +438,24 @@ func (c *Connector) handleGetCalendar(params map[string]interface{}) (interface{
 		password = c.config.EWS.Password
 	}
 
-	log.Printf("Getting calendar for %s (days: -%d to +%d, user: %s)", email, daysBack, daysForward, username)
+	log.Printf("Getting calendar for %s (days: -%d to +%d, user: %s)", params.Email, daysBack, daysForward, username)
 
-	events, err := c.ewsClient.GetCalendarEvents(email, username, password, daysBack, daysForward)
+	events, err := c.ewsClient.GetCalendarEvents(params.Email, username, password, daysBack, daysForward)
 	if err != nil {
-		log.Printf("ERROR: Failed to get calendar for %s: %v", email, err)
+		log.Printf("ERROR: Failed to get calendar for %s: %v", params.Email, err)
 		return nil, fmt.Errorf("failed to get calendar: %w", err)
 	}
 
-	log.Printf("Successfully retrieved %d events for %s", len(events), email)
-	return events, nil
-}
-
-func (c *Connector) handleSyncCalendar(params map[string]interface{}) (interface{}, error) {
-	// Same as get_calendar for now
-	return c.handleGetCalendar(params)
+	log.Printf("Successfully retrieved %d events for %s", len(events), params.Email)
+	if c.version < 2 {
+		return events, nil // version 1 sends the bare array
+	}
+	return protocol.CalendarResult{Events: events}, nil
 }
 
-func (c *Connector) handleFindFreeSlots(params map[string]interface{}) (interface{}, error) {
+func (c *Connector) handleFindFreeSlots(raw json.RawMessage, _ progressFunc) (interface{}, error) {
 	// TODO: Implement free/busy time slots
-	return map[string]interface{}{
-		"slots": []interface{}{},
-	}, nil
+	return protocol.FreeSlotsResult{Slots: []protocol.TimeSlot{}}, nil
 }
 
 func (c *Connector) heartbeatLoop() {
@@ -267,10 +470,10 @@ func (c *Connector) heartbeatLoop() {
 	for {
 		select {
 		case <-ticker.C:
-			if err := c.sendMessage(map[string]interface{}{
-				"type":      "heartbeat",
-				"timestamp": time.Now().Format(time.RFC3339),
-				"status":    "online",
+			if err := c.sendMessage(protocol.Heartbeat{
+				Type:      protocol.TypeHeartbeat,
+				Timestamp: time.Now().Format(time.RFC3339),
+				Status:    "online",
 			}); err != nil {
 				log.Printf("Heartbeat error: %v", err)
 				return
@@ -302,8 +505,20 @@ func (c *Connector) Run() error {
 	}
 
 	log.Println("Starting connector...")
+	log.Printf("Name: %s, capabilities: %s, sites: %s", c.config.Backend.Name, strings.Join(c.capabilities(), ","), c.config.Backend.Sites)
 	log.Printf("EWS URL: %s", c.config.EWS.URL)
 	log.Printf("Backend URL: %s", strings.Split(c.config.Backend.URL, "?")[0])
+	switch {
+	case c.config.Backend.CertFile != "":
+		log.Println("Authentication: client certificate")
+	case c.config.Backend.Credential != "" || c.config.Backend.CredentialFile != "":
+		log.Println("Authentication: signed token")
+	case c.config.Backend.APIKey != "":
+		log.Println("WARNING: authenticating with the shared API key; ask the administrator for a connector credential")
+	}
+	if c.config.Backend.InsecureSkipVerify {
+		log.Println("WARNING: backend TLS verification is disabled; set ca_file instead")
+	}
 
 	for c.running {
 		if err := c.connect(); err != nil {
@@ -337,32 +552,48 @@ func (c *Connector) Stop() {
 	c.disconnect()
 }
 
-func getFloat64(m map[string]interface{}, key string, defaultValue float64) float64 {
-	if v, ok := m[key]; ok {
-		if f, ok := v.(float64); ok {
-			return f
-		}
+func (c *Connector) handleSyncUsers(raw json.RawMessage, progress progressFunc) (interface{}, error) {
+	params := protocol.SyncUsersParams{IncludePhotos: true}
+	if err := decodeParams(raw, &params); err != nil {
+		return nil, err
 	}
-	return defaultValue
-}
 
-func (c *Connector) handleSyncADUsers(params map[string]interface{}) (interface{}, error) {
-	includePhotos := getBool(params, "include_photos", true)
-
-	users, err := c.adClient.GetAllUsers(includePhotos)
+	progress("reading users", 0, 0)
+	users, err := c.adClient.GetAllUsers(params.IncludePhotos, func(read int) {
+		progress("reading users", read, 0)
+	})
 	if err != nil {
 		return nil, fmt.Errorf("failed to get AD users: %w", err)
 	}
 
-	return map[string]interface{}{
-		"users": users,
-		"total": len(users),
+	stats := protocol.SyncStats{TotalInAD: len(users)}
+	result := make([]protocol.User, 0, len(users))
+	for _, user := range users {
+		if user.Department != "" {
+			stats.WithDepartment++
+		} else {
+			stats.WithoutDepartment++
+		}
+		if (params.RequireDepartment && user.Department == "") || (params.RequireEmail && user.Email == "") {
+			stats.FilteredOut++
+			continue
+		}
+		result = append(result, *user)
+	}
+
+	return protocol.SyncUsersResult{
+		Users: result,
+		Total: len(result),
+		Stats: &stats,
 	}, nil
 }
 
-func (c *Connector) handleAuthenticateAD(params map[string]interface{}) (interface{}, error) {
-	username, _ := params["username"].(string)
-	password, _ := params["password"].(string)
+func (c *Connector) handleAuthenticate(raw json.RawMessage, _ progressFunc) (interface{}, error) {
+	var params protocol.AuthenticateParams
+	if err := decodeParams(raw, &params); err != nil {
+		return nil, err
+	}
+	username, password := params.Username, params.Password
 
 	if username == "" || password == "" {
 		return nil, fmt.Errorf("username and password required")
@@ -378,39 +609,29 @@ func (c *Connector) handleAuthenticateAD(params map[string]interface{}) (interfa
 
 	user, err := c.adClient.Authenticate(username, password)
 	if err != nil {
-		return map[string]interface{}{
-			"authenticated": false,
-			"error":         err.Error(),
-		}, nil
+		return protocol.AuthenticateResult{Authenticated: false, Error: err.Error()}, nil
 	}
 
-	return map[string]interface{}{
-		"authenticated": true,
-		"user":          user,
-	}, nil
+	return protocol.AuthenticateResult{Authenticated: true, User: user}, nil
 }
 
-func (c *Connector) handleGetSubordinates(params map[string]interface{}) (interface{}, error) {
-	managerDN, _ := params["manager_dn"].(string)
-	if managerDN == "" {
+func (c *Connector) handleGetSubordinates(raw json.RawMessage, _ progressFunc) (interface{}, error) {
+	var params protocol.SubordinatesParams
+	if err := decodeParams(raw, &params); err != nil {
+		return nil, err
+	}
+	if params.ManagerDN == "" {
 		return nil, fmt.Errorf("manager_dn required")
 	}
 
-	users, err := c.adClient.GetSubordinates(managerDN)
+	users, err := c.adClient.GetSubordinates(params.ManagerDN)
 	if err != nil {
 		return nil, fmt.Errorf("failed to get subordinates: %w", err)
 	}
 
-	return map[string]interface{}{
-		"subordinates": users,
-	}, nil
-}
-
-func getBool(m map[string]interface{}, key string, defaultValue bool) bool {
-	if v, ok := m[key]; ok {
-		if b, ok := v.(bool); ok {
-			return b
-		}
+	subordinates := make([]protocol.User, len(users))
+	for i, user := range users {
+		subordinates[i] = *user
 	}
-	return defaultValue
+	return protocol.SubordinatesResult{Subordinates: subordinates}, nil
 }
@@ -0,0 +1,221 @@
+package connector
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+
+	"github.com/ekf/one-on-one-connector/pkg/protocol"
+)
+
+// Exchange mail and calendar item commands. They run as the user whose credentials the
+// backend sends, or as the EWS service account when it sends none.
+
+// credentials returns the account a mail command runs as
+func (c *Connector) credentials(m protocol.Mailbox) (string, string) {
+	if m.Username == "" || m.Password == "" {
+		return c.config.EWS.Username, c.config.EWS.Password
+	}
+	return m.Username, m.Password
+}
+
+// decodeItemParams decodes the params of a command on one item
+func decodeItemParams(raw json.RawMessage) (protocol.ItemParams, error) {
+	var params protocol.ItemParams
+	if err := decodeParams(raw, &params); err != nil {
+		return params, err
+	}
+	if params.ItemID == "" {
+		return params, fmt.Errorf("item_id is required")
+	}
+	return params, nil
+}
+
+func (c *Connector) handleGetMailFolders(raw json.RawMessage, _ progressFunc) (interface{}, error) {
+	var params protocol.Mailbox
+	if err := decodeParams(raw, &params); err != nil {
+		return nil, err
+	}
+
+	username, password := c.credentials(params)
+	folders, err := c.ewsClient.GetMailFolders(username, password)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get mail folders: %w", err)
+	}
+	return protocol.MailFoldersResult{Folders: folders}, nil
+}
+
+func (c *Connector) handleGetEmails(raw json.RawMessage, _ progressFunc) (interface{}, error) {
+	var params protocol.EmailsParams
+	if err := decodeParams(raw, &params); err != nil {
+		return nil, err
+	}
+	if params.Limit <= 0 {
+		params.Limit = 50
+	}
+
+	username, password := c.credentials(params.Mailbox)
+	emails, err := c.ewsClient.GetEmails(username, password, params.FolderID, params.Limit)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get emails: %w", err)
+	}
+	return protocol.EmailsResult{Emails: emails}, nil
+}
+
+func (c *Connector) handleGetEmailBody(raw json.RawMessage, _ progressFunc) (interface{}, error) {
+	params, err := decodeItemParams(raw)
+	if err != nil {
+		return nil, err
+	}
+
+	username, password := c.credentials(params.Mailbox)
+	body, err := c.ewsClient.GetEmailBody(username, password, params.ItemID, params.ChangeKey)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get email body: %w", err)
+	}
+	return protocol.EmailBodyResult{Body: body}, nil
+}
+
+func (c *Connector) handleSendEmail(raw json.RawMessage, _ progressFunc) (interface{}, error) {
+	var params protocol.SendEmailParams
+	if err := decodeParams(raw, &params); err != nil {
+		return nil, err
+	}
+	if len(params.To) == 0 {
+		return nil, fmt.Errorf("at least one recipient required")
+	}
+
+	username, password := c.credentials(params.Mailbox)
+	log.Printf("Sending email as %s to %d recipients with %d attachments", username, len(params.To)+len(params.CC), len(params.Attachments))
+	err := c.ewsClient.SendEmail(username, password, params.Subject, params.To, params.Body, params.CC, params.Attachments)
+	if err != nil {
+		return nil, fmt.Errorf("failed to send email: %w", err)
+	}
+	return struct{}{}, nil
+}
+
+func (c *Connector) handleMarkEmailRead(raw json.RawMessage, _ progressFunc) (interface{}, error) {
+	params, err := decodeItemParams(raw)
+	if err != nil {
+		return nil, err
+	}
+
+	username, password := c.credentials(params.Mailbox)
+	if err := c.ewsClient.MarkEmailAsRead(username, password, params.ItemID, params.ChangeKey); err != nil {
+		return nil, fmt.Errorf("failed to mark email as read: %w", err)
+	}
+	return struct{}{}, nil
+}
+
+func (c *Connector) handleDeleteEmail(raw json.RawMessage, _ progressFunc) (interface{}, error) {
+	params, err := decodeItemParams(raw)
+	if err != nil {
+		return nil, err
+	}
+
+	username, password := c.credentials(params.Mailbox)
+	if err := c.ewsClient.DeleteEmail(username, password, params.ItemID, params.ChangeKey); err != nil {
+		return nil, fmt.Errorf("failed to delete email: %w", err)
+	}
+	return struct{}{}, nil
+}
+
+func (c *Connector) handleGetAttachments(raw json.RawMessage, _ progressFunc) (interface{}, error) {
+	params, err := decodeItemParams(raw)
+	if err != nil {
+		return nil, err
+	}
+
+	username, password := c.credentials(params.Mailbox)
+	attachments, err := c.ewsClient.GetAttachments(username, password, params.ItemID, params.ChangeKey)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get attachments: %w", err)
+	}
+	return protocol.AttachmentsResult{Attachments: attachments}, nil
+}
+
+func (c *Connector) handleGetAttachmentContent(raw json.RawMessage, _ progressFunc) (interface{}, error) {
+	var params protocol.AttachmentContentParams
+	if err := decodeParams(raw, &params); err != nil {
+		return nil, err
+	}
+	if params.AttachmentID == "" {
+		return nil, fmt.Errorf("attachment_id is required")
+	}
+
+	username, password := c.credentials(params.Mailbox)
+	name, contentType, content, err := c.ewsClient.GetAttachmentContent(username, password, params.AttachmentID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get attachment: %w", err)
+	}
+	// Large attachments are streamed in chunks by handleCommand
+	return protocol.AttachmentContentResult{Name: name, ContentType: contentType, Content: content}, nil
+}
+
+func (c *Connector) handleRespondToMeeting(raw json.RawMessage, _ progressFunc) (interface{}, error) {
+	var params protocol.MeetingResponseParams
+	if err := decodeParams(raw, &params); err != nil {
+		return nil, err
+	}
+	if params.ItemID == "" || params.Response == "" {
+		return nil, fmt.Errorf("item_id and response are required")
+	}
+
+	username, password := c.credentials(params.Mailbox)
+	err := c.ewsClient.RespondToMeetingRequest(username, password, params.ItemID, params.ChangeKey, params.Response)
+	if err != nil {
+		return nil, fmt.Errorf("failed to respond to meeting: %w", err)
+	}
+	return struct{}{}, nil
+}
+
+func (c *Connector) handleCreateCalendarItem(raw json.RawMessage, _ progressFunc) (interface{}, error) {
+	var params protocol.CreateCalendarItemParams
+	if err := decodeParams(raw, &params); err != nil {
+		return nil, err
+	}
+	if params.Item.Subject == "" || params.Item.Start == "" || params.Item.End == "" {
+		return nil, fmt.Errorf("subject, start and end are required")
+	}
+
+	username, password := c.credentials(params.Mailbox)
+	log.Printf("Creating meeting as %s with %d attendees", username, len(params.Item.RequiredAttendees)+len(params.Item.OptionalAttendees))
+	itemID, err := c.ewsClient.CreateCalendarItem(username, password, params.Item)
+	if err != nil {
+		return nil, err
+	}
+	return protocol.CreateCalendarItemResult{ItemID: itemID}, nil
+}
+
+func (c *Connector) handleUpdateCalendarItem(raw json.RawMessage, _ progressFunc) (interface{}, error) {
+	var params protocol.UpdateCalendarItemParams
+	if err := decodeParams(raw, &params); err != nil {
+		return nil, err
+	}
+	if params.ItemID == "" {
+		return nil, fmt.Errorf("item_id is required")
+	}
+
+	username, password := c.credentials(params.Mailbox)
+	if err := c.ewsClient.UpdateCalendarItem(username, password, params.ItemID, params.ChangeKey, params.Updates); err != nil {
+		return nil, err
+	}
+	return struct{}{}, nil
+}
+
+func (c *Connector) handleDeleteCalendarItem(raw json.RawMessage, _ progressFunc) (interface{}, error) {
+	var params protocol.DeleteCalendarItemParams
+	if err := decodeParams(raw, &params); err != nil {
+		return nil, err
+	}
+	if params.ItemID == "" {
+		return nil, fmt.Errorf("item_id is required")
+	}
+
+	username, password := c.credentials(params.Mailbox)
+	err := c.ewsClient.DeleteCalendarItem(username, password, params.ItemID, params.ChangeKey, params.SendCancellations)
+	if err != nil {
+		return nil, err
+	}
+	return struct{}{}, nil
+}
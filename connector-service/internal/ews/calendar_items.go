@@ -0,0 +1,123 @@
+package ews
+
+import (
+	"fmt"
+	"strings"
+)
+
+// CreateCalendarItem creates a meeting in the calendar of the account, sends the
+// invitations and returns the item ID
+func (c *Client) CreateCalendarItem(username, password string, item CalendarItem) (string, error) {
+	var fields strings.Builder
+	if item.Body != "" {
+		fields.WriteString(fmt.Sprintf(`<t:Body BodyType="HTML">%s</t:Body>`, escapeXML(item.Body)))
+	}
+	fields.WriteString(`<t:ReminderIsSet>true</t:ReminderIsSet>`)
+	fields.WriteString(`<t:ReminderMinutesBeforeStart>15</t:ReminderMinutesBeforeStart>`)
+	fields.WriteString(fmt.Sprintf(`<t:Start>%s</t:Start><t:End>%s</t:End>`, escapeXML(item.Start), escapeXML(item.End)))
+	fields.WriteString(`<t:LegacyFreeBusyStatus>Busy</t:LegacyFreeBusyStatus>`) // EWS schema order matters
+	if item.Location != "" {
+		fields.WriteString(fmt.Sprintf(`<t:Location>%s</t:Location>`, escapeXML(item.Location)))
+	}
+	writeAttendees(&fields, "t:RequiredAttendees", item.RequiredAttendees)
+	writeAttendees(&fields, "t:OptionalAttendees", item.OptionalAttendees)
+
+	soap := fmt.Sprintf(soapEnvelope, fmt.Sprintf(`    <m:CreateItem SendMeetingInvitations="SendToAllAndSaveCopy">
+      <m:SavedItemFolderId>
+        <t:DistinguishedFolderId Id="calendar"/>
+      </m:SavedItemFolderId>
+      <m:Items>
+        <t:CalendarItem>
+          <t:Subject>%s</t:Subject>
+          %s
+        </t:CalendarItem>
+      </m:Items>
+    </m:CreateItem>`, escapeXML(item.Subject), fields.String()))
+
+	body, err := c.doRequest(soap, username, password)
+	if err == nil {
+		err = responseError(body)
+	}
+	if err != nil {
+		return "", fmt.Errorf("failed to create calendar item: %w", err)
+	}
+
+	return extractValue(string(body), `<t:ItemId Id="`, `"`), nil
+}
+
+// UpdateCalendarItem changes the subject, start, end or location of a meeting and
+// notifies the attendees
+func (c *Client) UpdateCalendarItem(username, password, itemID, changeKey string, updates map[string]interface{}) error {
+	var fields strings.Builder
+	for _, f := range []struct{ key, uri, tag string }{
+		{"subject", "item:Subject", "t:Subject"},
+		{"start", "calendar:Start", "t:Start"},
+		{"end", "calendar:End", "t:End"},
+		{"location", "calendar:Location", "t:Location"},
+	} {
+		value, ok := updates[f.key].(string)
+		if !ok || (value == "" && f.key != "location") {
+			continue
+		}
+		fields.WriteString(fmt.Sprintf(`
+            <t:SetItemField>
+              <t:FieldURI FieldURI="%s"/>
+              <t:CalendarItem><%s>%s</%s></t:CalendarItem>
+            </t:SetItemField>`, f.uri, f.tag, escapeXML(value), f.tag))
+	}
+	if fields.Len() == 0 {
+		return nil // Nothing to update
+	}
+
+	soap := fmt.Sprintf(soapEnvelope, fmt.Sprintf(`    <m:UpdateItem MessageDisposition="SaveOnly" ConflictResolution="AlwaysOverwrite" SendMeetingInvitationsOrCancellations="SendToAllAndSaveCopy">
+      <m:ItemChanges>
+        <t:ItemChange>
+          %s
+          <t:Updates>%s</t:Updates>
+        </t:ItemChange>
+      </m:ItemChanges>
+    </m:UpdateItem>`, itemIDElement("t:ItemId", itemID, changeKey), fields.String()))
+
+	body, err := c.doRequest(soap, username, password)
+	if err == nil {
+		err = responseError(body)
+	}
+	if err != nil {
+		return fmt.Errorf("failed to update calendar item: %w", err)
+	}
+	return nil
+}
+
+// DeleteCalendarItem moves a meeting to Deleted Items, optionally sending cancellations
+func (c *Client) DeleteCalendarItem(username, password, itemID, changeKey string, sendCancellations bool) error {
+	mode := "SendToNone"
+	if sendCancellations {
+		mode = "SendToAllAndSaveCopy"
+	}
+
+	soap := fmt.Sprintf(soapEnvelope, fmt.Sprintf(`    <m:DeleteItem DeleteType="MoveToDeletedItems" SendMeetingCancellations="%s">
+      <m:ItemIds>
+        %s
+      </m:ItemIds>
+    </m:DeleteItem>`, mode, itemIDElement("t:ItemId", itemID, changeKey)))
+
+	body, err := c.doRequest(soap, username, password)
+	if err == nil {
+		err = responseError(body)
+	}
+	if err != nil {
+		return fmt.Errorf("failed to delete calendar item: %w", err)
+	}
+	return nil
+}
+
+func writeAttendees(b *strings.Builder, tag string, emails []string) {
+	if len(emails) == 0 {
+		return
+	}
+	b.WriteString("<" + tag + ">")
+	for _, email := range emails {
+		b.WriteString(fmt.Sprintf(`<t:Attendee><t:Mailbox><t:EmailAddress>%s</t:EmailAddress></t:Mailbox></t:Attendee>`, escapeXML(email)))
+	}
+	b.WriteString("</" + tag + ">")
+}
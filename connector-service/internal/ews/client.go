@@ -9,6 +9,8 @@ import (
 	"regexp"
 	"strings"
 	"time"
+
+	"github.com/ekf/one-on-one-connector/pkg/protocol"
 )
 
 // Client handles Exchange Web Services requests
@@ -18,32 +20,17 @@ type Client struct {
 	client *http.Client
 }
 
-// CalendarEvent represents a calendar event from Exchange
-type CalendarEvent struct {
-	ID          string     `json:"id"`
-	Subject     string     `json:"subject"`
-	Start       string     `json:"start"`
-	End         string     `json:"end"`
-	Location    string     `json:"location,omitempty"`
-	Organizer   *Person    `json:"organizer,omitempty"`
-	Attendees   []Attendee `json:"attendees,omitempty"`
-	IsRecurring bool       `json:"is_recurring"`
-	IsCancelled bool       `json:"is_cancelled"`
-}
-
-// Person represents a person (organizer)
-type Person struct {
-	Name  string `json:"name"`
-	Email string `json:"email"`
-}
-
-// Attendee represents a meeting attendee
-type Attendee struct {
-	Name     string `json:"name"`
-	Email    string `json:"email"`
-	Response string `json:"response,omitempty"`
-	Optional bool   `json:"optional"`
-}
+// Calendar and mail types as sent to the backend
+type (
+	CalendarEvent   = protocol.CalendarEvent
+	Person          = protocol.Person
+	Attendee        = protocol.Attendee
+	MailFolder      = protocol.MailFolder
+	EmailMessage    = protocol.EmailMessage
+	Attachment      = protocol.Attachment
+	EmailAttachment = protocol.EmailAttachment
+	CalendarItem    = protocol.CalendarItem
+)
 
 // BusyTime represents a busy time slot
 type BusyTime struct {
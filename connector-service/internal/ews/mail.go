@@ -0,0 +1,488 @@
+package ews
+
+import (
+	"fmt"
+	"log"
+	"strings"
+)
+
+// soapEnvelope wraps an EWS request body
+const soapEnvelope = `<?xml version="1.0" encoding="utf-8"?>
+<soap:Envelope xmlns:soap="http://schemas.xmlsoap.org/soap/envelope/"
+               xmlns:t="http://schemas.microsoft.com/exchange/services/2006/types"
+               xmlns:m="http://schemas.microsoft.com/exchange/services/2006/messages">
+  <soap:Header>
+    <t:RequestServerVersion Version="Exchange2013"/>
+  </soap:Header>
+  <soap:Body>
+%s
+  </soap:Body>
+</soap:Envelope>`
+
+// GetMailFolders fetches the folders under the mailbox root
+func (c *Client) GetMailFolders(username, password string) ([]MailFolder, error) {
+	soap := fmt.Sprintf(soapEnvelope, `    <m:FindFolder Traversal="Shallow">
+      <m:FolderShape>
+        <t:BaseShape>Default</t:BaseShape>
+      </m:FolderShape>
+      <m:ParentFolderIds>
+        <t:DistinguishedFolderId Id="msgfolderroot"/>
+      </m:ParentFolderIds>
+    </m:FindFolder>`)
+
+	body, err := c.doRequest(soap, username, password)
+	if err != nil {
+		return nil, err
+	}
+
+	return parseMailFoldersResponse(string(body)), nil
+}
+
+// GetEmails fetches the latest emails of a folder, the inbox if folderID is empty
+func (c *Client) GetEmails(username, password, folderID string, limit int) ([]EmailMessage, error) {
+	folderSpec := `<t:DistinguishedFolderId Id="inbox"/>`
+	if folderID != "" {
+		folderSpec = fmt.Sprintf(`<t:FolderId Id="%s"/>`, escapeXML(folderID))
+	}
+
+	soap := fmt.Sprintf(soapEnvelope, fmt.Sprintf(`    <m:FindItem Traversal="Shallow">
+      <m:ItemShape>
+        <t:BaseShape>Default</t:BaseShape>
+        <t:AdditionalProperties>
+          <t:FieldURI FieldURI="item:Subject"/>
+          <t:FieldURI FieldURI="item:DateTimeReceived"/>
+          <t:FieldURI FieldURI="item:ConversationId"/>
+          <t:FieldURI FieldURI="item:ItemClass"/>
+          <t:FieldURI FieldURI="message:From"/>
+          <t:FieldURI FieldURI="message:ToRecipients"/>
+          <t:FieldURI FieldURI="message:IsRead"/>
+          <t:FieldURI FieldURI="item:HasAttachments"/>
+        </t:AdditionalProperties>
+      </m:ItemShape>
+      <m:IndexedPageItemView MaxEntriesReturned="%d" Offset="0" BasePoint="Beginning"/>
+      <m:SortOrder>
+        <t:FieldOrder Order="Descending">
+          <t:FieldURI FieldURI="item:DateTimeReceived"/>
+        </t:FieldOrder>
+      </m:SortOrder>
+      <m:ParentFolderIds>
+        %s
+      </m:ParentFolderIds>
+    </m:FindItem>`, limit, folderSpec))
+
+	body, err := c.doRequest(soap, username, password)
+	if err != nil {
+		return nil, err
+	}
+
+	return parseEmailsResponse(string(body)), nil
+}
+
+// GetEmailBody fetches the HTML body of an email
+func (c *Client) GetEmailBody(username, password, itemID, changeKey string) (string, error) {
+	soap := fmt.Sprintf(soapEnvelope, fmt.Sprintf(`    <m:GetItem>
+      <m:ItemShape>
+        <t:BaseShape>Default</t:BaseShape>
+        <t:BodyType>HTML</t:BodyType>
+        <t:AdditionalProperties>
+          <t:FieldURI FieldURI="item:Body"/>
+        </t:AdditionalProperties>
+      </m:ItemShape>
+      <m:ItemIds>
+        %s
+      </m:ItemIds>
+    </m:GetItem>`, itemIDElement("t:ItemId", itemID, changeKey)))
+
+	body, err := c.doRequest(soap, username, password)
+	if err != nil {
+		return "", err
+	}
+	if err := responseError(body); err != nil {
+		return "", err
+	}
+
+	return extractBodyContent(string(body)), nil
+}
+
+// GetAttachments lists the attachments of an email
+func (c *Client) GetAttachments(username, password, itemID, changeKey string) ([]Attachment, error) {
+	soap := fmt.Sprintf(soapEnvelope, fmt.Sprintf(`    <m:GetItem>
+      <m:ItemShape>
+        <t:BaseShape>IdOnly</t:BaseShape>
+        <t:AdditionalProperties>
+          <t:FieldURI FieldURI="item:Attachments"/>
+        </t:AdditionalProperties>
+      </m:ItemShape>
+      <m:ItemIds>
+        %s
+      </m:ItemIds>
+    </m:GetItem>`, itemIDElement("t:ItemId", itemID, changeKey)))
+
+	body, err := c.doRequest(soap, username, password)
+	if err != nil {
+		return nil, err
+	}
+	if err := responseError(body); err != nil {
+		return nil, err
+	}
+
+	return parseAttachmentsResponse(string(body)), nil
+}
+
+// GetAttachmentContent fetches an attachment and returns its name, content type and
+// base64 content
+func (c *Client) GetAttachmentContent(username, password, attachmentID string) (string, string, string, error) {
+	soap := fmt.Sprintf(soapEnvelope, fmt.Sprintf(`    <m:GetAttachment>
+      <m:AttachmentIds>
+        <t:AttachmentId Id="%s"/>
+      </m:AttachmentIds>
+    </m:GetAttachment>`, escapeXML(attachmentID)))
+
+	body, err := c.doRequest(soap, username, password)
+	if err != nil {
+		return "", "", "", err
+	}
+	if err := responseError(body); err != nil {
+		return "", "", "", err
+	}
+
+	xml := string(body)
+	content := strings.NewReplacer("\n", "", "\r", "", " ", "").
+		Replace(extractValue(xml, "<t:Content>", "</t:Content>"))
+	return extractValue(xml, "<t:Name>", "</t:Name>"), extractValue(xml, "<t:ContentType>", "</t:ContentType>"), content, nil
+}
+
+// SendEmail sends an email and saves a copy to Sent Items. With attachments it is
+// created as a draft first, as EWS adds attachments only to existing items.
+func (c *Client) SendEmail(username, password, subject string, toEmails []string, body string, ccEmails []string, attachments []EmailAttachment) error {
+	var recipients strings.Builder
+	recipients.WriteString("<t:ToRecipients>")
+	for _, email := range toEmails {
+		recipients.WriteString(fmt.Sprintf(`<t:Mailbox><t:EmailAddress>%s</t:EmailAddress></t:Mailbox>`, escapeXML(email)))
+	}
+	recipients.WriteString("</t:ToRecipients>")
+	if len(ccEmails) > 0 {
+		recipients.WriteString("<t:CcRecipients>")
+		for _, email := range ccEmails {
+			recipients.WriteString(fmt.Sprintf(`<t:Mailbox><t:EmailAddress>%s</t:EmailAddress></t:Mailbox>`, escapeXML(email)))
+		}
+		recipients.WriteString("</t:CcRecipients>")
+	}
+
+	disposition, folder := "SendAndSaveCopy", "sentitems"
+	if len(attachments) > 0 {
+		disposition, folder = "SaveOnly", "drafts"
+	}
+
+	createSoap := fmt.Sprintf(soapEnvelope, fmt.Sprintf(`    <m:CreateItem MessageDisposition="%s">
+      <m:SavedItemFolderId>
+        <t:DistinguishedFolderId Id="%s"/>
+      </m:SavedItemFolderId>
+      <m:Items>
+        <t:Message>
+          <t:Subject>%s</t:Subject>
+          <t:Body BodyType="HTML">%s</t:Body>
+          %s
+        </t:Message>
+      </m:Items>
+    </m:CreateItem>`, disposition, folder, escapeXML(subject), escapeXML(body), recipients.String()))
+
+	createResp, err := c.doRequest(createSoap, username, password)
+	if err != nil {
+		return err
+	}
+	if err := responseError(createResp); err != nil {
+		return err
+	}
+	if len(attachments) == 0 {
+		return nil
+	}
+
+	itemID := extractValue(string(createResp), `<t:ItemId Id="`, `"`)
+	changeKey := extractChangeKey(string(createResp))
+	if itemID == "" {
+		return fmt.Errorf("failed to get item ID from create response")
+	}
+
+	for _, att := range attachments {
+		attachSoap := fmt.Sprintf(soapEnvelope, fmt.Sprintf(`    <m:CreateAttachment>
+      <m:ParentItemId Id="%s" ChangeKey="%s"/>
+      <m:Attachments>
+        <t:FileAttachment>
+          <t:Name>%s</t:Name>
+          <t:Content>%s</t:Content>
+        </t:FileAttachment>
+      </m:Attachments>
+    </m:CreateAttachment>`, escapeXML(itemID), escapeXML(changeKey), escapeXML(att.Name), att.Content))
+
+		attachResp, err := c.doRequest(attachSoap, username, password)
+		if err == nil {
+			err = responseError(attachResp)
+		}
+		if err != nil {
+			return fmt.Errorf("failed to add attachment %s: %w", att.Name, err)
+		}
+		// Every attachment changes the item
+		if key := extractValue(string(attachResp), `RootItemChangeKey="`, `"`); key != "" {
+			changeKey = key
+		}
+	}
+
+	sendSoap := fmt.Sprintf(soapEnvelope, fmt.Sprintf(`    <m:SendItem SaveItemToFolder="true">
+      <m:ItemIds>
+        %s
+      </m:ItemIds>
+      <m:SavedItemFolderId>
+        <t:DistinguishedFolderId Id="sentitems"/>
+      </m:SavedItemFolderId>
+    </m:SendItem>`, itemIDElement("t:ItemId", itemID, changeKey)))
+
+	sendResp, err := c.doRequest(sendSoap, username, password)
+	if err == nil {
+		err = responseError(sendResp)
+	}
+	if err != nil {
+		return fmt.Errorf("failed to send email: %w", err)
+	}
+	return nil
+}
+
+// MarkEmailAsRead marks an email as read
+func (c *Client) MarkEmailAsRead(username, password, itemID, changeKey string) error {
+	soap := fmt.Sprintf(soapEnvelope, fmt.Sprintf(`    <m:UpdateItem MessageDisposition="SaveOnly" ConflictResolution="AlwaysOverwrite">
+      <m:ItemChanges>
+        <t:ItemChange>
+          %s
+          <t:Updates>
+            <t:SetItemField>
+              <t:FieldURI FieldURI="message:IsRead"/>
+              <t:Message>
+                <t:IsRead>true</t:IsRead>
+              </t:Message>
+            </t:SetItemField>
+          </t:Updates>
+        </t:ItemChange>
+      </m:ItemChanges>
+    </m:UpdateItem>`, itemIDElement("t:ItemId", itemID, changeKey)))
+
+	body, err := c.doRequest(soap, username, password)
+	if err != nil {
+		return err
+	}
+	return responseError(body)
+}
+
+// DeleteEmail moves an email to Deleted Items
+func (c *Client) DeleteEmail(username, password, itemID, changeKey string) error {
+	soap := fmt.Sprintf(soapEnvelope, fmt.Sprintf(`    <m:DeleteItem DeleteType="MoveToDeletedItems">
+      <m:ItemIds>
+        %s
+      </m:ItemIds>
+    </m:DeleteItem>`, itemIDElement("t:ItemId", itemID, changeKey)))
+
+	body, err := c.doRequest(soap, username, password)
+	if err != nil {
+		return err
+	}
+	return responseError(body)
+}
+
+// RespondToMeetingRequest accepts, declines or tentatively accepts a meeting invitation
+func (c *Client) RespondToMeetingRequest(username, password, itemID, changeKey, response string) error {
+	var element string
+	switch response {
+	case "Accept":
+		element = "t:AcceptItem"
+	case "Decline":
+		element = "t:DeclineItem"
+	case "Tentative":
+		element = "t:TentativelyAcceptItem"
+	default:
+		return fmt.Errorf("invalid response type: %s (must be Accept, Decline, or Tentative)", response)
+	}
+
+	soap := fmt.Sprintf(soapEnvelope, fmt.Sprintf(`    <m:CreateItem MessageDisposition="SendAndSaveCopy">
+      <m:Items>
+        <%s>
+          %s
+        </%s>
+      </m:Items>
+    </m:CreateItem>`, element, itemIDElement("t:ReferenceItemId", itemID, changeKey), element))
+
+	body, err := c.doRequest(soap, username, password)
+	if err != nil {
+		return err
+	}
+	return responseError(body)
+}
+
+func parseMailFoldersResponse(xml string) []MailFolder {
+	var folders []MailFolder
+
+	for _, item := range strings.Split(xml, "<t:Folder>")[1:] {
+		endIdx := strings.Index(item, "</t:Folder>")
+		if endIdx == -1 {
+			continue
+		}
+		itemXML := item[:endIdx]
+
+		folder := MailFolder{
+			ID:          extractValue(itemXML, `<t:FolderId Id="`, `"`),
+			DisplayName: extractValue(itemXML, "<t:DisplayName>", "</t:DisplayName>"),
+			UnreadCount: parseInt(extractValue(itemXML, "<t:UnreadCount>", "</t:UnreadCount>")),
+			TotalCount:  parseInt(extractValue(itemXML, "<t:TotalCount>", "</t:TotalCount>")),
+		}
+		if folder.DisplayName != "" {
+			folders = append(folders, folder)
+		}
+	}
+
+	return folders
+}
+
+func parseEmailsResponse(xml string) []EmailMessage {
+	var emails []EmailMessage
+
+	for _, item := range strings.Split(xml, "<t:Message>")[1:] {
+		endIdx := strings.Index(item, "</t:Message>")
+		if endIdx == -1 {
+			continue
+		}
+		itemXML := item[:endIdx]
+
+		email := EmailMessage{
+			ID:             extractValue(itemXML, `<t:ItemId Id="`, `"`),
+			ChangeKey:      extractChangeKey(itemXML),
+			ConversationID: extractValue(itemXML, `<t:ConversationId Id="`, `"`),
+			ItemClass:      extractValue(itemXML, "<t:ItemClass>", "</t:ItemClass>"),
+			Subject:        extractValue(itemXML, "<t:Subject>", "</t:Subject>"),
+			ReceivedAt:     extractValue(itemXML, "<t:DateTimeReceived>", "</t:DateTimeReceived>"),
+			IsRead:         strings.Contains(strings.ToLower(itemXML), "<t:isread>true"),
+			HasAttach:      strings.Contains(strings.ToLower(itemXML), "<t:hasattachments>true"),
+		}
+
+		if fromXML := extractSection(itemXML, "<t:From>", "</t:From>"); fromXML != "" {
+			email.From = &Person{
+				Name:  extractValue(fromXML, "<t:Name>", "</t:Name>"),
+				Email: extractValue(fromXML, "<t:EmailAddress>", "</t:EmailAddress>"),
+			}
+		}
+		for _, mailbox := range strings.Split(extractSection(itemXML, "<t:ToRecipients>", "</t:ToRecipients>"), "<t:Mailbox>")[1:] {
+			if end := strings.Index(mailbox, "</t:Mailbox>"); end != -1 {
+				email.To = append(email.To, Person{
+					Name:  extractValue(mailbox[:end], "<t:Name>", "</t:Name>"),
+					Email: extractValue(mailbox[:end], "<t:EmailAddress>", "</t:EmailAddress>"),
+				})
+			}
+		}
+
+		if email.Subject == "" {
+			email.Subject = "(Без темы)"
+		}
+		emails = append(emails, email)
+	}
+
+	return emails
+}
+
+func parseAttachmentsResponse(xml string) []Attachment {
+	var attachments []Attachment
+
+	for _, kind := range []string{"FileAttachment", "ItemAttachment"} {
+		endTag := "</t:" + kind + ">"
+		for _, item := range strings.Split(xml, "<t:"+kind+">")[1:] {
+			endIdx := strings.Index(item, endTag)
+			if endIdx == -1 {
+				continue
+			}
+			attXML := item[:endIdx]
+
+			attachment := Attachment{
+				ID:          extractValue(attXML, `<t:AttachmentId Id="`, `"`),
+				Name:        extractValue(attXML, "<t:Name>", "</t:Name>"),
+				ContentType: extractValue(attXML, "<t:ContentType>", "</t:ContentType>"),
+				Size:        parseInt(extractValue(attXML, "<t:Size>", "</t:Size>")),
+				IsInline:    strings.Contains(strings.ToLower(attXML), "<t:isinline>true"),
+				ContentID:   extractValue(attXML, "<t:ContentId>", "</t:ContentId>"),
+			}
+			if attachment.ID != "" {
+				attachments = append(attachments, attachment)
+			}
+		}
+	}
+
+	return attachments
+}
+
+// extractBodyContent extracts the HTML of <t:Body BodyType="HTML">...</t:Body>
+func extractBodyContent(xml string) string {
+	startIdx := strings.Index(xml, "<t:Body")
+	if startIdx == -1 {
+		return ""
+	}
+	closeIdx := strings.Index(xml[startIdx:], ">")
+	if closeIdx == -1 {
+		return ""
+	}
+	contentStart := startIdx + closeIdx + 1
+
+	endIdx := strings.Index(xml[contentStart:], "</t:Body>")
+	if endIdx == -1 {
+		return ""
+	}
+	return unescapeXML(xml[contentStart : contentStart+endIdx])
+}
+
+// extractSection returns the XML between startTag and the following endTag
+func extractSection(xml, startTag, endTag string) string {
+	start := strings.Index(xml, startTag)
+	if start == -1 {
+		return ""
+	}
+	end := strings.Index(xml[start:], endTag)
+	if end == -1 {
+		return ""
+	}
+	return xml[start : start+end]
+}
+
+func extractChangeKey(xml string) string {
+	// ChangeKey is in <t:ItemId Id="..." ChangeKey="..."/>
+	return extractValue(xml, `ChangeKey="`, `"`)
+}
+
+// itemIDElement builds an item ID element; the change key is optional
+func itemIDElement(tag, itemID, changeKey string) string {
+	if changeKey == "" {
+		return fmt.Sprintf(`<%s Id="%s"/>`, tag, escapeXML(itemID))
+	}
+	return fmt.Sprintf(`<%s Id="%s" ChangeKey="%s"/>`, tag, escapeXML(itemID), escapeXML(changeKey))
+}
+
+// responseError returns the message of the first error in an EWS response. EWS answers
+// 200 with ResponseClass="Error" when an item cannot be found or changed.
+func responseError(body []byte) error {
+	xml := string(body)
+	if !strings.Contains(xml, `ResponseClass="Error"`) {
+		return nil
+	}
+	if msg := extractValue(xml, "<m:MessageText>", "</m:MessageText>"); msg != "" {
+		log.Printf("EWS error: %s", msg)
+		return fmt.Errorf("exchange error: %s", msg)
+	}
+	return fmt.Errorf("exchange error: %s", extractValue(xml, "<m:ResponseCode>", "</m:ResponseCode>"))
+}
+
+func parseInt(s string) int {
+	var n int
+	fmt.Sscanf(s, "%d", &n)
+	return n
+}
+
+func escapeXML(s string) string {
+	return strings.NewReplacer("&", "&amp;", "<", "&lt;", ">", "&gt;", `"`, "&quot;", "'", "&apos;").Replace(s)
+}
+
+func unescapeXML(s string) string {
+	return strings.NewReplacer("&lt;", "<", "&gt;", ">", "&quot;", `"`, "&apos;", "'", "&amp;", "&").Replace(s)
+}
@@ -0,0 +1,138 @@
+package protocol
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+)
+
+// A connector authenticates the WebSocket upgrade with a client certificate or with a
+// short-lived token in the Authorization header, signed with the key of one of its
+// credentials. A credential is issued by the backend admin API as "<key id>.<secret>";
+// a connector may hold several during a key rotation.
+
+const (
+	// TokenTTL is the lifetime of the tokens connectors sign; a token is only checked when
+	// the connection is opened
+	TokenTTL = 5 * time.Minute
+	// MaxTokenTTL is the longest lifetime the backend accepts
+	MaxTokenTTL = 15 * time.Minute
+	// tokenAudience keeps connector tokens from being accepted elsewhere
+	tokenAudience = "connector"
+	// clockSkew is the clock difference tolerated between connector and backend
+	clockSkew = time.Minute
+)
+
+var (
+	ErrInvalidCredential = errors.New("invalid connector credential: expected <key id>.<secret>")
+	ErrInvalidToken      = errors.New("invalid connector token")
+	ErrTokenExpired      = errors.New("connector token expired")
+)
+
+// TokenClaims are the checked contents of a connector token
+type TokenClaims struct {
+	KeyID     string
+	IssuedAt  time.Time
+	ExpiresAt time.Time
+}
+
+// tokenHeader and tokenPayload make the token a JWT signed with HS256
+type tokenHeader struct {
+	Alg string `json:"alg"`
+	Typ string `json:"typ"`
+	Kid string `json:"kid"`
+}
+
+type tokenPayload struct {
+	Aud string `json:"aud"`
+	Iat int64  `json:"iat"`
+	Exp int64  `json:"exp"`
+}
+
+// FormatCredential joins a key ID and its secret into the credential given to a connector
+func FormatCredential(keyID, secret string) string {
+	return keyID + "." + secret
+}
+
+// ParseCredential splits a credential into its key ID and secret
+func ParseCredential(credential string) (keyID, secret string, err error) {
+	keyID, secret, ok := strings.Cut(strings.TrimSpace(credential), ".")
+	if !ok || keyID == "" || secret == "" {
+		return "", "", ErrInvalidCredential
+	}
+	return keyID, secret, nil
+}
+
+// SignToken returns a token valid for ttl, signed with the secret of key keyID
+func SignToken(keyID, secret string, now time.Time, ttl time.Duration) (string, error) {
+	header, err := json.Marshal(tokenHeader{Alg: "HS256", Typ: "JWT", Kid: keyID})
+	if err != nil {
+		return "", err
+	}
+	payload, err := json.Marshal(tokenPayload{Aud: tokenAudience, Iat: now.Unix(), Exp: now.Add(ttl).Unix()})
+	if err != nil {
+		return "", err
+	}
+	signed := encodeSegment(header) + "." + encodeSegment(payload)
+	return signed + "." + encodeSegment(sign(secret, signed)), nil
+}
+
+// ParseToken checks a token and returns its claims. secret returns the secret of a key
+// ID, or an error when the key is unknown, revoked or expired.
+func ParseToken(token string, secret func(keyID string) (string, error), now time.Time) (*TokenClaims, error) {
+	parts := strings.Split(token, ".")
+	if len(parts) != 3 {
+		return nil, ErrInvalidToken
+	}
+
+	var header tokenHeader
+	if err := decodeSegment(parts[0], &header); err != nil || header.Alg != "HS256" || header.Kid == "" {
+		return nil, ErrInvalidToken
+	}
+	key, err := secret(header.Kid)
+	if err != nil {
+		return nil, err
+	}
+	signature, err := base64.RawURLEncoding.DecodeString(parts[2])
+	if err != nil || !hmac.Equal(signature, sign(key, parts[0]+"."+parts[1])) {
+		return nil, ErrInvalidToken
+	}
+
+	var payload tokenPayload
+	if err := decodeSegment(parts[1], &payload); err != nil || payload.Aud != tokenAudience {
+		return nil, ErrInvalidToken
+	}
+	claims := &TokenClaims{KeyID: header.Kid, IssuedAt: time.Unix(payload.Iat, 0), ExpiresAt: time.Unix(payload.Exp, 0)}
+	switch {
+	case claims.ExpiresAt.Sub(claims.IssuedAt) > MaxTokenTTL:
+		return nil, fmt.Errorf("%w: lifetime over %s", ErrInvalidToken, MaxTokenTTL)
+	case claims.IssuedAt.After(now.Add(clockSkew)):
+		return nil, fmt.Errorf("%w: issued in the future, check the connector clock", ErrInvalidToken)
+	case now.After(claims.ExpiresAt.Add(clockSkew)):
+		return nil, ErrTokenExpired
+	}
+	return claims, nil
+}
+
+func sign(secret, data string) []byte {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(data))
+	return mac.Sum(nil)
+}
+
+func encodeSegment(data []byte) string {
+	return base64.RawURLEncoding.EncodeToString(data)
+}
+
+func decodeSegment(segment string, v interface{}) error {
+	data, err := base64.RawURLEncoding.DecodeString(segment)
+	if err != nil {
+		return err
+	}
+	return json.Unmarshal(data, v)
+}
@@ -0,0 +1,113 @@
+package protocol
+
+import (
+	"encoding/base64"
+	"errors"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestParseCredential(t *testing.T) {
+	tests := []struct {
+		credential, keyID, secret string
+		ok                        bool
+	}{
+		{"key-1.s3cret", "key-1", "s3cret", true},
+		{"  key-1.s3cret\n", "key-1", "s3cret", true},
+		{"key-1.with.dots", "key-1", "with.dots", true},
+		{FormatCredential("k", "s"), "k", "s", true},
+		{"", "", "", false},
+		{"no-separator", "", "", false},
+		{".secret", "", "", false},
+		{"key.", "", "", false},
+	}
+	for _, tt := range tests {
+		keyID, secret, err := ParseCredential(tt.credential)
+		if tt.ok != (err == nil) || keyID != tt.keyID || secret != tt.secret {
+			t.Errorf("ParseCredential(%q) = %q, %q, %v", tt.credential, keyID, secret, err)
+		}
+	}
+}
+
+func TestParseToken(t *testing.T) {
+	now := time.Date(2026, 10, 17, 12, 0, 0, 0, time.UTC)
+	secrets := map[string]string{"key-1": "secret-1", "key-2": "secret-2"}
+	errRevoked := errors.New("credential revoked")
+	lookup := func(keyID string) (string, error) {
+		if keyID == "revoked" {
+			return "", errRevoked
+		}
+		if s, ok := secrets[keyID]; ok {
+			return s, nil
+		}
+		return "", errors.New("unknown key")
+	}
+	sign := func(keyID, secret string, issued time.Time, ttl time.Duration) string {
+		token, err := SignToken(keyID, secret, issued, ttl)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return token
+	}
+	valid := sign("key-1", "secret-1", now, TokenTTL)
+	parts := strings.Split(valid, ".")
+
+	tests := []struct {
+		name    string
+		token   string
+		wantErr error // nil - valid; ErrInvalidToken matches wrapped errors too
+		anyErr  bool
+	}{
+		{name: "valid", token: valid},
+		{name: "second key", token: sign("key-2", "secret-2", now, TokenTTL)},
+		{name: "near the end of its lifetime", token: sign("key-1", "secret-1", now.Add(-TokenTTL), TokenTTL)},
+		{name: "clock slightly ahead", token: sign("key-1", "secret-1", now.Add(30*time.Second), TokenTTL)},
+		{name: "expired", token: sign("key-1", "secret-1", now.Add(-10*time.Minute), TokenTTL), wantErr: ErrTokenExpired},
+		{name: "issued in the future", token: sign("key-1", "secret-1", now.Add(5*time.Minute), TokenTTL), wantErr: ErrInvalidToken},
+		{name: "lifetime too long", token: sign("key-1", "secret-1", now, MaxTokenTTL+time.Minute), wantErr: ErrInvalidToken},
+		{name: "bad signature", token: sign("key-1", "wrong-secret", now, TokenTTL), wantErr: ErrInvalidToken},
+		{name: "signed with another key", token: parts[0] + "." + parts[1] + "." + strings.Split(sign("key-2", "secret-2", now, TokenTTL), ".")[2], wantErr: ErrInvalidToken},
+		{name: "tampered payload", token: parts[0] + "." + encodeSegment([]byte(`{"aud":"connector","iat":1,"exp":9999999999}`)) + "." + parts[2], wantErr: ErrInvalidToken},
+		{name: "signature not base64", token: parts[0] + "." + parts[1] + ".!!!", wantErr: ErrInvalidToken},
+		{name: "revoked key", token: sign("revoked", "x", now, TokenTTL), wantErr: errRevoked},
+		{name: "unknown key", token: sign("key-3", "secret-3", now, TokenTTL), anyErr: true},
+		{name: "two segments", token: parts[0] + "." + parts[1], wantErr: ErrInvalidToken},
+		{name: "empty", token: "", wantErr: ErrInvalidToken},
+		{name: "header not JSON", token: base64.RawURLEncoding.EncodeToString([]byte("x")) + "." + parts[1] + "." + parts[2], wantErr: ErrInvalidToken},
+		{name: "algorithm none", token: encodeSegment([]byte(`{"alg":"none","typ":"JWT","kid":"key-1"}`)) + "." + parts[1] + ".", wantErr: ErrInvalidToken},
+		{name: "no key ID", token: encodeSegment([]byte(`{"alg":"HS256","typ":"JWT"}`)) + "." + parts[1] + "." + parts[2], wantErr: ErrInvalidToken},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			claims, err := ParseToken(tt.token, lookup, now)
+			switch {
+			case tt.anyErr:
+				if err == nil {
+					t.Error("ParseToken succeeded")
+				}
+			case tt.wantErr != nil:
+				if !errors.Is(err, tt.wantErr) {
+					t.Errorf("ParseToken error = %v, want %v", err, tt.wantErr)
+				}
+			case err != nil:
+				t.Errorf("ParseToken: %v", err)
+			case claims.KeyID == "" || !claims.ExpiresAt.After(claims.IssuedAt):
+				t.Errorf("claims = %+v", claims)
+			}
+		})
+	}
+}
+
+func TestTokenFromAnotherAudience(t *testing.T) {
+	now := time.Now()
+	header := encodeSegment([]byte(`{"alg":"HS256","typ":"JWT","kid":"key-1"}`))
+	payload := encodeSegment([]byte(`{"aud":"web","iat":` + strconv.FormatInt(now.Unix(), 10) + `,"exp":` + strconv.FormatInt(now.Add(time.Minute).Unix(), 10) + `}`))
+	token := header + "." + payload + "." + encodeSegment(sign("secret-1", header+"."+payload))
+
+	_, err := ParseToken(token, func(string) (string, error) { return "secret-1", nil }, now)
+	if !errors.Is(err, ErrInvalidToken) {
+		t.Errorf("ParseToken error = %v, want ErrInvalidToken", err)
+	}
+}
@@ -0,0 +1,206 @@
+package protocol
+
+import "encoding/json"
+
+// Commands
+const (
+	CommandPing            = "ping"
+	CommandGetCalendar     = "get_calendar"
+	CommandSyncCalendar    = "sync_calendar" // same as get_calendar
+	CommandFindFreeSlots   = "find_free_slots"
+	CommandSyncUsers       = "sync_users"
+	CommandAuthenticate    = "authenticate"
+	CommandGetSubordinates = "get_subordinates"
+
+	// Exchange mail and calendar items, see mail.go
+	CommandGetMailFolders       = "get_mail_folders"
+	CommandGetEmails            = "get_emails"
+	CommandGetEmailBody         = "get_email_body"
+	CommandSendEmail            = "send_email"
+	CommandMarkEmailRead        = "mark_email_read"
+	CommandDeleteEmail          = "delete_email"
+	CommandGetAttachments       = "get_attachments"
+	CommandGetAttachmentContent = "get_attachment_content"
+	CommandRespondToMeeting     = "respond_to_meeting"
+	CommandCreateCalendarItem   = "create_calendar_item"
+	CommandUpdateCalendarItem   = "update_calendar_item"
+	CommandDeleteCalendarItem   = "delete_calendar_item"
+)
+
+// Commands are the commands of the current version
+var Commands = []string{
+	CommandPing,
+	CommandGetCalendar,
+	CommandSyncCalendar,
+	CommandFindFreeSlots,
+	CommandSyncUsers,
+	CommandAuthenticate,
+	CommandGetSubordinates,
+	CommandGetMailFolders,
+	CommandGetEmails,
+	CommandGetEmailBody,
+	CommandSendEmail,
+	CommandMarkEmailRead,
+	CommandDeleteEmail,
+	CommandGetAttachments,
+	CommandGetAttachmentContent,
+	CommandRespondToMeeting,
+	CommandCreateCalendarItem,
+	CommandUpdateCalendarItem,
+	CommandDeleteCalendarItem,
+}
+
+// LegacyCommands are the commands of version 1 connectors, which do not list them
+var LegacyCommands = []string{
+	CommandPing,
+	CommandGetCalendar,
+	CommandSyncCalendar,
+	CommandFindFreeSlots,
+	CommandSyncUsers,
+	CommandAuthenticate,
+	CommandGetSubordinates,
+}
+
+// PingResult is the result of CommandPing
+type PingResult struct {
+	Pong      bool   `json:"pong"`
+	Timestamp string `json:"timestamp"`
+}
+
+// CalendarParams are the params of CommandGetCalendar and CommandSyncCalendar. Without
+// credentials the connector uses its service account.
+type CalendarParams struct {
+	Email       string `json:"email"`
+	Username    string `json:"username,omitempty"` // DOMAIN\login
+	Password    string `json:"password,omitempty"`
+	DaysBack    int    `json:"days_back,omitempty"`    // 7 if not set
+	DaysForward int    `json:"days_forward,omitempty"` // 30 if not set
+}
+
+// CalendarResult is the result of CommandGetCalendar and CommandSyncCalendar
+type CalendarResult struct {
+	Events []CalendarEvent `json:"events"`
+}
+
+// UnmarshalJSON also accepts the bare event array sent in version 1
+func (r *CalendarResult) UnmarshalJSON(data []byte) error {
+	if len(data) > 0 && data[0] == '[' {
+		return json.Unmarshal(data, &r.Events)
+	}
+	type plain CalendarResult
+	return json.Unmarshal(data, (*plain)(r))
+}
+
+// CalendarEvent is an Exchange calendar item
+type CalendarEvent struct {
+	ID          string     `json:"id"`
+	Subject     string     `json:"subject"`
+	Start       string     `json:"start"`
+	End         string     `json:"end"`
+	Location    string     `json:"location,omitempty"`
+	Organizer   *Person    `json:"organizer,omitempty"`
+	Attendees   []Attendee `json:"attendees,omitempty"`
+	IsRecurring bool       `json:"is_recurring"`
+	IsCancelled bool       `json:"is_cancelled"`
+}
+
+// Person is the organizer of an event
+type Person struct {
+	Name  string `json:"name"`
+	Email string `json:"email"`
+}
+
+// Attendee is an attendee of an event
+type Attendee struct {
+	Name     string `json:"name"`
+	Email    string `json:"email"`
+	Response string `json:"response,omitempty"` // Accept, Decline, Tentative, Unknown
+	Optional bool   `json:"optional"`
+}
+
+// FreeSlotsParams are the params of CommandFindFreeSlots
+type FreeSlotsParams struct {
+	Emails []string `json:"emails"`
+	Start  string   `json:"start"` // RFC3339
+	End    string   `json:"end"`
+}
+
+// FreeSlotsResult is the result of CommandFindFreeSlots
+type FreeSlotsResult struct {
+	Slots []TimeSlot `json:"slots"`
+}
+
+// TimeSlot is a period of time
+type TimeSlot struct {
+	Start string `json:"start"`
+	End   string `json:"end"`
+}
+
+// SyncUsersParams are the params of CommandSyncUsers
+type SyncUsersParams struct {
+	IncludePhotos     bool   `json:"include_photos"`
+	RequireDepartment bool   `json:"require_department"` // skip users without a department
+	RequireEmail      bool   `json:"require_email"`      // skip users without an email
+	Domain            string `json:"domain,omitempty"`   // site the command is routed to
+}
+
+// SyncUsersResult is the result of CommandSyncUsers
+type SyncUsersResult struct {
+	Users []User     `json:"users"`
+	Total int        `json:"total"`
+	Stats *SyncStats `json:"stats,omitempty"` // not sent in version 1
+}
+
+// SyncStats counts the users read from AD
+type SyncStats struct {
+	TotalInAD         int `json:"total_in_ad"`
+	WithDepartment    int `json:"with_department"`
+	WithoutDepartment int `json:"without_department"`
+	FilteredOut       int `json:"filtered_out"`
+}
+
+// AuthenticateParams are the params of CommandAuthenticate
+type AuthenticateParams struct {
+	Username string `json:"username"` // login, DOMAIN\login or login@domain
+	Password string `json:"password"`
+}
+
+// AuthenticateResult is the result of CommandAuthenticate. Wrong credentials are a
+// successful response with Authenticated false.
+type AuthenticateResult struct {
+	Authenticated bool   `json:"authenticated"`
+	User          *User  `json:"user,omitempty"`
+	Error         string `json:"error,omitempty"`
+}
+
+// SubordinatesParams are the params of CommandGetSubordinates
+type SubordinatesParams struct {
+	ManagerDN string `json:"manager_dn"`
+}
+
+// SubordinatesResult is the result of CommandGetSubordinates
+type SubordinatesResult struct {
+	Subordinates []User `json:"subordinates"`
+}
+
+// User is an Active Directory user
+type User struct {
+	DN              string   `json:"dn"`
+	Username        string   `json:"username"`
+	Login           string   `json:"login"` // same as Username
+	Email           string   `json:"email"`
+	Name            string   `json:"name"`
+	DisplayName     string   `json:"display_name"`
+	GivenName       string   `json:"given_name"`
+	Surname         string   `json:"surname"`
+	Department      string   `json:"department"`
+	Title           string   `json:"title"`
+	Phone           string   `json:"phone"`
+	Mobile          string   `json:"mobile"`
+	Manager         string   `json:"manager"`
+	ManagerDN       string   `json:"manager_dn"`
+	PhotoBase64     string   `json:"photo_base64,omitempty"`
+	MemberOf        []string `json:"member_of"`
+	Enabled         bool     `json:"enabled"`
+	PasswordExpired bool     `json:"password_expired"`
+}
@@ -0,0 +1,157 @@
+package protocol
+
+// Mailbox is the Exchange account a mail or calendar item command runs as. Without
+// credentials the connector uses its service account.
+type Mailbox struct {
+	Username string `json:"username,omitempty"` // DOMAIN\login
+	Password string `json:"password,omitempty"`
+}
+
+// ItemParams are the params of the commands on one item: CommandGetEmailBody,
+// CommandMarkEmailRead, CommandDeleteEmail and CommandGetAttachments
+type ItemParams struct {
+	Mailbox
+	ItemID    string `json:"item_id"`
+	ChangeKey string `json:"change_key,omitempty"`
+}
+
+// MailFoldersResult is the result of CommandGetMailFolders, whose params are a Mailbox
+type MailFoldersResult struct {
+	Folders []MailFolder `json:"folders"`
+}
+
+// MailFolder is a folder under the mailbox root
+type MailFolder struct {
+	ID          string `json:"id"`
+	DisplayName string `json:"display_name"`
+	UnreadCount int    `json:"unread_count"`
+	TotalCount  int    `json:"total_count"`
+}
+
+// EmailsParams are the params of CommandGetEmails
+type EmailsParams struct {
+	Mailbox
+	FolderID string `json:"folder_id,omitempty"` // inbox if not set
+	Limit    int    `json:"limit,omitempty"`     // 50 if not set
+}
+
+// EmailsResult is the result of CommandGetEmails, newest first
+type EmailsResult struct {
+	Emails []EmailMessage `json:"emails"`
+}
+
+// EmailMessage is an Exchange message. Lists leave Body empty, see CommandGetEmailBody.
+type EmailMessage struct {
+	ID             string   `json:"id"`
+	ChangeKey      string   `json:"change_key,omitempty"`
+	ConversationID string   `json:"conversation_id,omitempty"`
+	ItemClass      string   `json:"item_class,omitempty"` // IPM.Schedule.Meeting.Request for invitations
+	Subject        string   `json:"subject"`
+	From           *Person  `json:"from,omitempty"`
+	To             []Person `json:"to,omitempty"`
+	CC             []Person `json:"cc,omitempty"`
+	Body           string   `json:"body"`
+	BodyPreview    string   `json:"body_preview,omitempty"`
+	ReceivedAt     string   `json:"received_at"`
+	IsRead         bool     `json:"is_read"`
+	HasAttach      bool     `json:"has_attachments"`
+	FolderID       string   `json:"folder_id,omitempty"`
+}
+
+// EmailBodyResult is the result of CommandGetEmailBody
+type EmailBodyResult struct {
+	Body string `json:"body"` // HTML
+}
+
+// SendEmailParams are the params of CommandSendEmail, which has no result
+type SendEmailParams struct {
+	Mailbox
+	To          []string          `json:"to"`
+	CC          []string          `json:"cc,omitempty"`
+	Subject     string            `json:"subject"`
+	Body        string            `json:"body"` // HTML
+	Attachments []EmailAttachment `json:"attachments,omitempty"`
+}
+
+// EmailAttachment is a file attached to a sent email
+type EmailAttachment struct {
+	Name    string `json:"name"`
+	Content string `json:"content"` // base64
+}
+
+// AttachmentsResult is the result of CommandGetAttachments
+type AttachmentsResult struct {
+	Attachments []Attachment `json:"attachments"`
+}
+
+// Attachment describes an attachment of a message
+type Attachment struct {
+	ID          string `json:"id"`
+	Name        string `json:"name"`
+	ContentType string `json:"content_type"`
+	Size        int    `json:"size"`
+	IsInline    bool   `json:"is_inline"`
+	ContentID   string `json:"content_id,omitempty"`
+}
+
+// AttachmentContentParams are the params of CommandGetAttachmentContent
+type AttachmentContentParams struct {
+	Mailbox
+	AttachmentID string `json:"attachment_id"`
+}
+
+// AttachmentContentResult is the result of CommandGetAttachmentContent
+type AttachmentContentResult struct {
+	Name        string `json:"name"`
+	ContentType string `json:"content_type"`
+	Content     string `json:"content"` // base64
+}
+
+// MeetingResponseParams are the params of CommandRespondToMeeting, which has no result
+type MeetingResponseParams struct {
+	Mailbox
+	ItemID    string `json:"item_id"` // the meeting request message
+	ChangeKey string `json:"change_key,omitempty"`
+	Response  string `json:"response"` // Accept, Decline or Tentative
+}
+
+// CalendarItem is a meeting to create; invitations are sent to the attendees
+type CalendarItem struct {
+	Subject           string   `json:"subject"`
+	Body              string   `json:"body,omitempty"` // HTML
+	Start             string   `json:"start"`          // ISO 8601
+	End               string   `json:"end"`
+	Location          string   `json:"location,omitempty"`
+	RequiredAttendees []string `json:"required_attendees,omitempty"` // emails
+	OptionalAttendees []string `json:"optional_attendees,omitempty"`
+	IsOnlineMeeting   bool     `json:"is_online_meeting,omitempty"`
+}
+
+// CreateCalendarItemParams are the params of CommandCreateCalendarItem
+type CreateCalendarItemParams struct {
+	Mailbox
+	Item CalendarItem `json:"item"`
+}
+
+// CreateCalendarItemResult is the result of CommandCreateCalendarItem
+type CreateCalendarItemResult struct {
+	ItemID string `json:"item_id"` // empty if Exchange did not return one
+}
+
+// UpdateCalendarItemParams are the params of CommandUpdateCalendarItem, which has no
+// result. Attendees are notified of the changes.
+type UpdateCalendarItemParams struct {
+	Mailbox
+	ItemID    string `json:"item_id"`
+	ChangeKey string `json:"change_key,omitempty"`
+	// Fields to change: "subject", "start", "end" and "location"; others are ignored
+	Updates map[string]interface{} `json:"updates"`
+}
+
+// DeleteCalendarItemParams are the params of CommandDeleteCalendarItem, which has no result
+type DeleteCalendarItemParams struct {
+	Mailbox
+	ItemID            string `json:"item_id"`
+	ChangeKey         string `json:"change_key,omitempty"`
+	SendCancellations bool   `json:"send_cancellations"`
+}
@@ -1,19 +1,152 @@
+// Package protocol is the wire protocol between the backend and on-prem connectors.
+//
+// This package is the canonical copy: the backend builds from its own module, so
+// backend/pkg/protocol is a copy made by scripts/sync-protocol.sh. Change it here and run
+// the script.
+//
+// The WebSocket upgrade is authenticated with a client certificate or a signed token, see
+// auth.go. A connection then starts with a handshake. The connector sends a Hello with the protocol
+// versions and commands it supports; the backend answers with a Welcome carrying the
+// negotiated version and the commands it may send, or with an Error and closes the
+// connection when no version is common. Connectors that predate the handshake never send
+// a Hello and are treated as version 1 with LegacyCommands.
+//
+// After the handshake the backend sends Commands and the connector answers each with a
+// Response; both carry the typed params and results defined in commands.go and mail.go.
+// The connector also sends a Heartbeat every 15-30 seconds.
+//
+// Since version 3 a connector reports the Progress of long commands, and streams results
+// larger than ChunkSize as Chunks before a Response without a result. Every such message
+// restarts the timeout of the command on the backend.
 package protocol
 
+import (
+	"encoding/json"
+	"fmt"
+	"unicode/utf8"
+)
+
+// Version is the protocol version implemented by this package; MinVersion is the oldest
+// version it still speaks
+const (
+	Version    = 3
+	MinVersion = 1
+)
+
+// Message types
+const (
+	TypeHello     = "hello"     // connector -> backend, first message
+	TypeWelcome   = "welcome"   // backend -> connector, handshake accepted
+	TypeError     = "error"     // backend -> connector, handshake rejected
+	TypeCommand   = "command"   // backend -> connector
+	TypeResponse  = "response"  // connector -> backend
+	TypeHeartbeat = "heartbeat" // connector -> backend
+	TypeProgress  = "progress"  // connector -> backend, version 3
+	TypeChunk     = "chunk"     // connector -> backend, version 3
+)
+
+// ChunkSize is the largest result sent in a Response; larger ones are streamed in Chunks
+const ChunkSize = 256 << 10
+
+// Message is the part common to all messages, used to dispatch on the type
+type Message struct {
+	Type string `json:"type"`
+}
+
+// Hello opens the handshake
+type Hello struct {
+	Type       string   `json:"type"` // TypeHello
+	Version    int      `json:"version"`
+	MinVersion int      `json:"min_version"`
+	Commands   []string `json:"commands"`           // commands the connector handles
+	Software   string   `json:"software,omitempty"` // connector build, for diagnostics
+}
+
+// Welcome accepts the handshake
+type Welcome struct {
+	Type     string   `json:"type"` // TypeWelcome
+	Version  int      `json:"version"`
+	Commands []string `json:"commands"` // commands the backend may send
+}
+
+// Error rejects the handshake
+type Error struct {
+	Type    string `json:"type"` // TypeError
+	Error   string `json:"error"`
+	Version int    `json:"version"` // the version of the rejecting side
+}
+
 // Command represents a command from backend to connector
 type Command struct {
-	Command   string                 `json:"command"`
-	RequestID string                 `json:"request_id"`
-	Params    map[string]interface{} `json:"params"`
+	Type      string          `json:"type,omitempty"` // TypeCommand; empty in version 1
+	Command   string          `json:"command"`
+	RequestID string          `json:"request_id"`
+	Params    json.RawMessage `json:"params"`
 }
 
 // Response represents a response from connector to backend
 type Response struct {
-	Type      string      `json:"type"`
-	RequestID string      `json:"request_id"`
-	Command   string      `json:"command"`
-	Success   bool        `json:"success"`
-	Error     string      `json:"error,omitempty"`
-	Result    interface{} `json:"result,omitempty"`
-	Timestamp string      `json:"timestamp"`
+	Type      string          `json:"type"` // TypeResponse
+	RequestID string          `json:"request_id"`
+	Command   string          `json:"command"`
+	Success   bool            `json:"success"`
+	Error     string          `json:"error,omitempty"`
+	Result    json.RawMessage `json:"result,omitempty"`
+	Chunks    int             `json:"chunks,omitempty"` // the result was streamed in this many Chunks
+	Timestamp string          `json:"timestamp"`
+}
+
+// Progress reports how far a command has got
+type Progress struct {
+	Type      string `json:"type"` // TypeProgress
+	RequestID string `json:"request_id"`
+	Stage     string `json:"stage"`           // e.g. "reading users", "sending result"
+	Done      int    `json:"done"`            // items done in the stage
+	Total     int    `json:"total,omitempty"` // 0 - unknown
+}
+
+// Chunk is a part of a streamed result. Chunks are numbered from 0 and their data,
+// joined in order, is the JSON result.
+type Chunk struct {
+	Type      string `json:"type"` // TypeChunk
+	RequestID string `json:"request_id"`
+	Seq       int    `json:"seq"`
+	Data      string `json:"data"`
+}
+
+// Heartbeat tells the backend the connector is alive
+type Heartbeat struct {
+	Type      string `json:"type"` // TypeHeartbeat
+	Timestamp string `json:"timestamp"`
+	Status    string `json:"status"`
+}
+
+// NewHello returns the Hello of a connector handling commands
+func NewHello(commands []string, software string) Hello {
+	return Hello{Type: TypeHello, Version: Version, MinVersion: MinVersion, Commands: commands, Software: software}
+}
+
+// SplitChunks splits a result into parts of at most size bytes, cutting between UTF-8
+// characters so that every part survives JSON encoding
+func SplitChunks(data []byte, size int) []string {
+	var chunks []string
+	for len(data) > 0 {
+		n := min(size, len(data))
+		for n < len(data) && n > 1 && !utf8.RuneStart(data[n]) {
+			n--
+		}
+		chunks = append(chunks, string(data[:n]))
+		data = data[n:]
+	}
+	return chunks
+}
+
+// Negotiate returns the highest version both sides speak
+func Negotiate(peerMin, peerMax int) (int, error) {
+	version := min(Version, peerMax)
+	if version < max(MinVersion, peerMin) {
+		return 0, fmt.Errorf("no common protocol version: peer speaks %d-%d, this side %d-%d",
+			peerMin, peerMax, MinVersion, Version)
+	}
+	return version, nil
 }
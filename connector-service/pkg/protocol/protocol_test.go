@@ -0,0 +1,75 @@
+package protocol
+
+import (
+	"encoding/json"
+	"strings"
+	"testing"
+	"unicode/utf8"
+)
+
+func TestSplitChunks(t *testing.T) {
+	results := map[string]string{
+		"empty":    "",
+		"ascii":    `{"users":[{"name":"Ivan"},{"name":"Anna"}]}`,
+		"cyrillic": `{"name":"Иванов Иван Иванович","department":"Отдел разработки"}`,
+		"emoji":    strings.Repeat("👍 ok ", 50),
+		"mixed":    strings.Repeat("aé€😀", 100),
+	}
+	// Sizes start at utf8.UTFMax, so a part always holds at least one character
+	sizes := []int{utf8.UTFMax, 5, 7, 16, 100, 1 << 20}
+
+	for name, result := range results {
+		for _, size := range sizes {
+			chunks := SplitChunks([]byte(result), size)
+			if result == "" && len(chunks) != 0 {
+				t.Errorf("%s/%d: %d chunks for an empty result", name, size, len(chunks))
+			}
+
+			var joined strings.Builder
+			for i, chunk := range chunks {
+				if chunk == "" || len(chunk) > size {
+					t.Errorf("%s/%d: chunk %d has %d bytes", name, size, i, len(chunk))
+				}
+				if !utf8.ValidString(chunk) {
+					t.Errorf("%s/%d: chunk %d cuts a character", name, size, i)
+				}
+				joined.WriteString(chunk)
+			}
+			if joined.String() != result {
+				t.Errorf("%s/%d: joined chunks differ from the result", name, size)
+			}
+		}
+	}
+}
+
+// Chunks travel as JSON messages; the receiver joins their data in seq order
+func TestChunksSurviveJSONReassembly(t *testing.T) {
+	result, _ := json.Marshal(map[string]interface{}{
+		"users": []string{"Иванов", "Петрова", "O'Brien \"Bob\"", "emoji 🎉"},
+		"total": 4,
+	})
+
+	var messages [][]byte
+	for i, data := range SplitChunks(result, 7) {
+		msg, err := json.Marshal(Chunk{Type: TypeChunk, RequestID: "r1", Seq: i, Data: data})
+		if err != nil {
+			t.Fatal(err)
+		}
+		messages = append(messages, msg)
+	}
+
+	var reassembled []byte
+	for i, msg := range messages {
+		var chunk Chunk
+		if err := json.Unmarshal(msg, &chunk); err != nil {
+			t.Fatal(err)
+		}
+		if chunk.Seq != i || chunk.Type != TypeChunk || chunk.RequestID != "r1" {
+			t.Fatalf("chunk %d decoded as %+v", i, chunk)
+		}
+		reassembled = append(reassembled, chunk.Data...)
+	}
+	if string(reassembled) != string(result) {
+		t.Errorf("reassembled %s, want %s", reassembled, result)
+	}
+}
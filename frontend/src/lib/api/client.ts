@@ -290,6 +290,20 @@ export const messenger = {
 		request<{ success: boolean; webhook_url: string; message: string }>(`/channels/${channelId}/telegram`, { method: 'POST', body: data }),
 };
 
+// Notifications (new ones arrive over the messenger WebSocket as { type: 'notification', data })
+export const notifications = {
+	list: (params?: { unread?: boolean; limit?: number; cursor?: string }) => {
+		const query = params ? '?' + new URLSearchParams(params as Record<string, string>).toString() : '';
+		return request<AppNotification[]>(`/notifications${query}`);
+	},
+	unreadCount: () => request<{ count: number }>('/notifications/unread-count'),
+	markRead: (id: string) => request<{ success: boolean }>(`/notifications/${id}/read`, { method: 'POST' }),
+	markAllRead: () => request<{ updated: number }>('/notifications/read-all', { method: 'POST' }),
+	getPreferences: () => request<NotificationPreferences>('/notifications/preferences'),
+	updatePreferences: (data: { preferences?: NotificationPreference[]; settings?: NotificationSettings }) =>
+		request<NotificationPreferences>('/notifications/preferences', { method: 'PUT', body: data }),
+};
+
 // Connector
 export const connector = {
 	status: () => request<ConnectorStatus>('/connector/status'),
@@ -741,6 +755,26 @@ export interface ConnectorStatus {
 	yandex_configured?: boolean;
 	openai_configured?: boolean;
 	anthropic_configured?: boolean;
+	pending_requests?: number;
+	connectors?: ConnectorHealth[];
+}
+
+export interface ConnectorHealth {
+	name: string;
+	capabilities: string[]; // "ad", "ews"
+	sites: string[] | null; // null - serves any domain
+	auth: { method: 'certificate' | 'token' | 'shared_key' | 'none'; credential_id?: string; expires_at?: string };
+	protocol_version: number;
+	software?: string;
+	commands: string[];
+	healthy: boolean;
+	connected_at: string;
+	last_seen: string;
+	in_flight: number;
+	running: { command: string; started_at: string; progress?: { stage: string; done: number; total?: number } }[];
+	completed: number;
+	failed: number;
+	last_error?: string;
 }
 
 export interface CalendarPerson {
@@ -825,6 +859,39 @@ export interface Message {
 	reply_to?: Message;
 }
 
+// Notification types
+export interface AppNotification {
+	id: string;
+	user_id: string;
+	type: string; // task.assigned, ticket.sla_breached, ...
+	title: string;
+	body?: string;
+	link?: string;
+	data?: Record<string, unknown>;
+	read_at?: string;
+	created_at?: string;
+}
+
+export interface NotificationPreference {
+	type: string;
+	in_app: boolean;
+	email: boolean;
+	telegram: boolean;
+	digest: boolean;
+}
+
+export interface NotificationSettings {
+	quiet_hours_start: string | null; // "22:00"
+	quiet_hours_end: string | null;
+	digest_hour: number;
+	locale: 'ru' | 'en';
+}
+
+export interface NotificationPreferences {
+	preferences: NotificationPreference[];
+	settings: NotificationSettings;
+}
+
 // Mail types
 export interface MailFolder {
 	id: string;
@@ -1070,6 +1137,12 @@ export interface AuditLog {
 	new_value?: Record<string, unknown>;
 	ip_address?: string;
 	user_agent?: string;
+	request_id?: string;
+	method?: string;
+	path?: string;
+	status?: number;
+	seq?: number;
+	hash?: string;
 	created_at: string;
 	user?: { id: string; name: string };
 }
@@ -1225,7 +1298,16 @@ export const admin = {
 	getSettings: () => adminRequest<SystemSetting[]>('/settings'),
 	updateSetting: (key: string, value: unknown) =>
 		adminRequest('/settings', { method: 'PUT', body: { key, value } }),
-	getAuditLogs: (params?: { limit?: number; offset?: number; action?: string; entity_type?: string }) => {
+	getAuditLogs: (params?: {
+		limit?: number;
+		offset?: number;
+		action?: string;
+		entity_type?: string;
+		entity_id?: string;
+		user_id?: string;
+		from?: string;
+		to?: string;
+	}) => {
 		const query = params ? '?' + new URLSearchParams(params as Record<string, string>).toString() : '';
 		return adminRequest<AuditLog[]>(`/audit-logs${query}`);
 	},
@@ -1611,6 +1693,7 @@ export const api = {
 	analytics,
 	calendar,
 	messenger,
+	notifications,
 	connector,
 	files,
 	bpmn,
@@ -0,0 +1,49 @@
+#!/bin/bash
+
+# Copy the connector protocol package to the backend. Both build as separate modules,
+# so connector-service/pkg/protocol is the source and backend/pkg/protocol a copy.
+# Tests are not copied: the source package has its own, and backend/pkg/protocol/sync_test.go
+# fails `go test` in the backend while the copy is out of date.
+# With --check, only report whether the copy is up to date (for CI and `make check`).
+
+set -e
+
+SCRIPT_DIR="$(cd "$(dirname "${BASH_SOURCE[0]}")" && pwd)"
+PROJECT_DIR="$(dirname "$SCRIPT_DIR")"
+SRC_DIR="$PROJECT_DIR/connector-service/pkg/protocol"
+DST_DIR="$PROJECT_DIR/backend/pkg/protocol"
+HEADER="// Code generated by scripts/sync-protocol.sh from connector-service/pkg/protocol. DO NOT EDIT."
+
+render() {
+    echo "$HEADER"
+    echo ""
+    cat "$1"
+}
+
+if [ "$1" = "--check" ]; then
+    status=0
+    for src in "$SRC_DIR"/*.go; do
+        [[ "$src" == *_test.go ]] && continue
+        dst="$DST_DIR/$(basename "$src")"
+        if [ ! -f "$dst" ] || ! diff -q <(render "$src") "$dst" > /dev/null; then
+            echo "Out of date: $dst"
+            status=1
+        fi
+    done
+    for dst in "$DST_DIR"/*.go; do
+        [[ "$dst" == *_test.go ]] && continue
+        if [ ! -f "$SRC_DIR/$(basename "$dst")" ]; then
+            echo "Not in the source: $dst"
+            status=1
+        fi
+    done
+    exit $status
+fi
+
+mkdir -p "$DST_DIR"
+find "$DST_DIR" -maxdepth 1 -name '*.go' ! -name '*_test.go' -delete
+for src in "$SRC_DIR"/*.go; do
+    [[ "$src" == *_test.go ]] && continue
+    render "$src" > "$DST_DIR/$(basename "$src")"
+done
+echo "Synced $SRC_DIR -> $DST_DIR"